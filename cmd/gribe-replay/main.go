@@ -0,0 +1,72 @@
+// Command gribe-replay re-feeds the client events of a recorded session
+// against a running server and reports differences in the server events.
+//
+// Usage:
+//
+//	gribe-replay -file recordings/session-....jsonl -url ws://localhost:8080/v1/realtime
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/recording"
+	"github.com/gorilla/websocket"
+)
+
+func main() {
+	file := flag.String("file", "", "Recording file to replay (required)")
+	url := flag.String("url", "ws://localhost:8080/v1/realtime", "Realtime endpoint URL (include ?intent=transcription if needed)")
+	apiKey := flag.String("api-key", "", "API key sent as a Bearer token")
+	speed := flag.Float64("speed", 1, "Playback speed (1 = original pacing, 0 = no delays)")
+	wait := flag.Duration("wait", 5*time.Second, "Time to wait for server events after the last client event")
+	verbose := flag.Bool("v", false, "Print every server event received")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	entries, err := recording.ReadFile(*file)
+	if err != nil {
+		log.Fatalf("Failed to read recording: %v", err)
+	}
+
+	header := http.Header{}
+	if *apiKey != "" {
+		header.Set("Authorization", "Bearer "+*apiKey)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(*url, header)
+	if err != nil {
+		log.Fatalf("Failed to connect to %s: %v", *url, err)
+	}
+
+	received, err := recording.Replay(conn, entries, recording.ReplayOptions{Speed: *speed, Wait: *wait})
+	if err != nil {
+		log.Printf("Replay error: %v", err)
+	}
+
+	if *verbose {
+		for _, entry := range received {
+			fmt.Println(string(entry.Event))
+		}
+	}
+
+	diffs := recording.CompareEventTypes(entries, received)
+	if len(diffs) == 0 {
+		fmt.Printf("OK: %d server events match the recording\n", len(received))
+		return
+	}
+
+	fmt.Println("Server events differ from the recording:")
+	for _, diff := range diffs {
+		fmt.Println("  " + diff)
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/eval"
+)
+
+// runEval runs the eval subcommand: it transcribes a labeled dataset with
+// each model, through a server with -url or in process otherwise, and prints
+// their WER, CER and latencies. It returns the exit code.
+func runEval(args []string) int {
+	flags := flag.NewFlagSet("gribe eval", flag.ExitOnError)
+	dataset := flags.String("dataset", "", "JSONL manifest or directory of .wav/.txt pairs (required)")
+	models := flags.String("models", "", "Comma-separated models to evaluate (default: the default model)")
+	url := flags.String("url", "", "Base URL of a running server to evaluate, e.g. http://localhost:8080 (default: in process)")
+	apiKey := flags.String("api-key", "", "API key sent as a Bearer token with -url")
+	configPath := flags.String("config", "config.yaml", "Config file of the models, in process")
+	language := flags.String("language", "", "Language of samples that name none (default: each model's first)")
+	timeout := flags.Duration("timeout", 0, "Time allowed to transcribe one sample (default: no limit)")
+	format := flags.String("format", "text", "Report format: text or json")
+	out := flags.String("out", "", "File to write the report to (default: stdout)")
+	verbose := flags.Bool("v", false, "Log each sample's transcript")
+	flags.Parse(args)
+
+	if *dataset == "" || (*format != "text" && *format != "json") {
+		flags.Usage()
+		return 2
+	}
+	samples, err := eval.LoadDataset(*dataset)
+	if err != nil {
+		log.Printf("Failed to load dataset: %v", err)
+		return 1
+	}
+
+	opts := eval.Options{Language: *language, Timeout: *timeout}
+	for _, model := range strings.Split(*models, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			opts.Models = append(opts.Models, model)
+		}
+	}
+	if *verbose {
+		opts.Progress = func(model string, result *eval.Result) {
+			if result.Error != "" {
+				log.Printf("%s %s: failed: %s", model, result.ID, result.Error)
+				return
+			}
+			log.Printf("%s %s: WER %.2f%% in %dms %q (reference %q)", model, result.ID,
+				result.WER*100, result.LatencyMs, result.Transcript, result.Reference)
+		}
+	}
+
+	var transcriber eval.Transcriber
+	if *url != "" {
+		if len(opts.Models) == 0 {
+			log.Printf("-models is required with -url")
+			return 2
+		}
+		transcriber = &eval.ServerTranscriber{URL: *url, APIKey: *apiKey}
+	} else {
+		cfg := config.LoadWithYAML(*configPath)
+		if len(opts.Models) == 0 {
+			opts.Models = []string{cfg.ASR.DefaultModel}
+		}
+		provider := eval.NewProviderTranscriber(&cfg.ASR, nil)
+		defer provider.Close()
+		transcriber = provider
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Evaluating %s on %d samples of %s", strings.Join(opts.Models, ", "), len(samples), *dataset)
+	report := eval.Run(ctx, samples, transcriber, opts)
+	report.Dataset = *dataset
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Printf("Failed to create report: %v", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	if *format == "json" {
+		err = report.WriteJSON(w)
+	} else {
+		err = report.WriteText(w)
+	}
+	if err != nil {
+		log.Printf("Failed to write report: %v", err)
+		return 1
+	}
+	for _, model := range report.Models {
+		if model.Failed == model.Samples {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// handleBans lists the IPs banned for abuse
+func (h *Handler) handleBans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET is supported")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   h.Abuse.Bans(),
+	})
+}
+
+// handleBan serves /admin/bans/{ip}: DELETE lifts the IP's ban and forgets
+// its history, so a later ban starts again at the shortest duration
+func (h *Handler) handleBan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only DELETE is supported")
+		return
+	}
+	ip := strings.TrimPrefix(r.URL.Path, "/admin/bans/")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ip":      ip,
+		"deleted": h.Abuse.Unban(ip),
+	})
+}
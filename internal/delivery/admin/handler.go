@@ -0,0 +1,167 @@
+// Package admin serves operator endpoints under /admin/. Reads require the
+// admin:read scope and all other methods admin:write.
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Handler handles admin HTTP requests
+type Handler struct {
+	UseCase *usecase.SessionUsecase
+	Config  *config.Config
+	Auth    *middleware.Authenticator
+	Abuse   *middleware.AbuseDetector
+	mux     *http.ServeMux
+}
+
+// NewHandler creates the admin handler
+func NewHandler(uc *usecase.SessionUsecase, cfg *config.Config, auth *middleware.Authenticator, abuse *middleware.AbuseDetector) *Handler {
+	h := &Handler{
+		UseCase: uc,
+		Config:  cfg,
+		Auth:    auth,
+		Abuse:   abuse,
+		mux:     http.NewServeMux(),
+	}
+	h.mux.HandleFunc("/admin/quotas", h.handleQuotas)
+	h.mux.HandleFunc("/admin/models/reload", h.handleModelReload)
+	h.mux.HandleFunc("/admin/sessions", h.handleSessions)
+	h.mux.HandleFunc("/admin/sessions/summary", h.handleSessionSummary)
+	h.mux.HandleFunc("/admin/diagnostics", h.handleDiagnostics)
+	h.mux.HandleFunc("/admin/speakers", h.handleSpeakers)
+	h.mux.HandleFunc("/admin/speakers/", h.handleSpeaker)
+	h.mux.HandleFunc("/admin/retention", h.handleRetention)
+	h.mux.HandleFunc("/admin/bans", h.handleBans)
+	h.mux.HandleFunc("/admin/bans/", h.handleBan)
+	return h
+}
+
+// ServeHTTP implements http.Handler, rejecting requests without the required scope
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Protect(h.mux).ServeHTTP(w, r)
+}
+
+// Protect serves next only to callers with the admin scopes the admin
+// endpoints require: admin:read for reads and admin:write for other methods.
+// It guards the other operational endpoints, such as metrics and pprof.
+func (h *Handler) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope := config.ScopeAdminWrite
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			scope = config.ScopeAdminRead
+		}
+
+		principal, err := h.Auth.Authenticate(r)
+		if err != nil {
+			log.Printf("Unauthorized admin request from IP %s: %v", middleware.GetClientIP(r), err)
+			writeError(w, http.StatusUnauthorized, domain.CodeInvalidAPIKey, "A valid admin API key is required")
+			return
+		}
+		if !principal.HasScope(scope) {
+			log.Printf("Admin request without %s scope from IP: %s", scope, middleware.GetClientIP(r))
+			writeError(w, http.StatusForbidden, domain.CodeInsufficientScope, "Credentials lack the "+scope+" scope")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleQuotas reports audio quota usage. With ?key=<api key> it returns that
+// key's usage, or tenant:<id> that of a tenant with a quota of its own,
+// otherwise all accounts seen since startup with API keys masked.
+func (h *Handler) handleQuotas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET is supported")
+		return
+	}
+
+	quota := h.UseCase.Quota()
+	if key, ok := r.URL.Query()["key"]; ok {
+		usage := quota.Usage(key[0])
+		usage.Key = maskKey(usage.Key)
+		writeJSON(w, http.StatusOK, usage)
+		return
+	}
+
+	all := quota.AllUsage()
+	for i := range all {
+		all[i].Key = maskKey(all[i].Key)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   all,
+	})
+}
+
+// handleModelReload reloads ?model=<name> from its files on disk, after an
+// operator replaced them, without dropping the sessions using it
+func (h *Handler) handleModelReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only POST is supported")
+		return
+	}
+	model := r.URL.Query().Get("model")
+	if model == "" {
+		writeError(w, http.StatusBadRequest, domain.CodeMissingField, "model is required")
+		return
+	}
+
+	reloaded, err := h.UseCase.ReloadModel(model)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not available"):
+			writeError(w, http.StatusServiceUnavailable, domain.CodeConfigUnavailable, err.Error())
+		case strings.Contains(err.Error(), "not found"):
+			writeError(w, http.StatusNotFound, domain.CodeInvalidModel, err.Error())
+		default:
+			// The previous instance keeps serving
+			log.Printf("Reloading model %s failed: %v", model, err)
+			writeError(w, http.StatusInternalServerError, domain.CodeProviderInitFailed, err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"model":    model,
+		"reloaded": reloaded,
+	})
+}
+
+// maskKey hides all but the edges of an API key. Tenant accounts are not
+// secret and shown whole.
+func maskKey(key string) string {
+	switch {
+	case strings.HasPrefix(key, "tenant:"):
+		return key
+	case key == "":
+		return "(anonymous)"
+	case len(key) <= 8:
+		return "****"
+	}
+	return key[:4] + "..." + key[len(key)-4:]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"type":      "invalid_request_error",
+			"code":      code,
+			"message":   message,
+			"retryable": domain.IsRetryableCode(code),
+		},
+	})
+}
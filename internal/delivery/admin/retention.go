@@ -0,0 +1,28 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// handleRetention serves the retention policy of stored data: GET reports
+// what a sweep would delete without touching anything, POST sweeps now,
+// deleting unless retention.dry_run is set
+func (h *Handler) handleRetention(w http.ResponseWriter, r *http.Request) {
+	retention := h.UseCase.Retention()
+	if retention == nil {
+		writeError(w, http.StatusServiceUnavailable, domain.CodeConfigUnavailable,
+			"Retention is not enabled, set retention.days or a retention_days")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, retention.Expired())
+	case http.MethodPost:
+		writeJSON(w, http.StatusOK, retention.Sweep(retention.DryRun()))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET and POST are supported")
+	}
+}
@@ -0,0 +1,80 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// handleSessions lists the live sessions, newest first. Query parameters
+// filter them (api_key, model, ip, min_duration and idle in seconds) and
+// order them (sort, one of usecase.SessionSortFields, and order=asc|desc).
+func (h *Handler) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET is supported")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := usecase.SessionFilter{
+		APIKey:   query.Get("api_key"),
+		Model:    query.Get("model"),
+		ClientIP: query.Get("ip"),
+		Sort:     query.Get("sort"),
+	}
+	for param, bound := range map[string]*time.Duration{"min_duration": &filter.MinDuration, "idle": &filter.MinIdle} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds < 0 {
+			writeError(w, http.StatusBadRequest, domain.CodeInvalidValue, param+" must be a number of seconds")
+			return
+		}
+		*bound = time.Duration(seconds * float64(time.Second))
+	}
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		writeError(w, http.StatusBadRequest, domain.CodeInvalidValue, "order must be asc or desc")
+		return
+	}
+
+	sessions, err := h.UseCase.Sessions(filter)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, domain.CodeInvalidValue, err.Error())
+		return
+	}
+	for i := range sessions {
+		sessions[i].APIKey = maskKey(sessions[i].APIKey)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   sessions,
+	})
+}
+
+// handleSessionSummary counts the live sessions by model and provider
+func (h *Handler) handleSessionSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET is supported")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.UseCase.Summary())
+}
+
+// handleDiagnostics reports goroutines by subsystem and the resources held
+// for sessions, listing those left behind by ended sessions
+func (h *Handler) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET is supported")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.UseCase.Diagnostics())
+}
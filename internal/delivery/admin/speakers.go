@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aira-id/gribe/internal/delivery/gateway"
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+	"github.com/aira-id/gribe/internal/pkg/pcm"
+	"github.com/aira-id/gribe/internal/pkg/wav"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// maxEnrollmentBytes bounds an enrollment recording, over five minutes of
+// 16kHz audio, far more than a voiceprint needs
+const maxEnrollmentBytes = 10 << 20
+
+// handleSpeakers lists the enrolled speaker profiles (GET) or enrolls a new
+// speaker (POST) from a WAV recording uploaded as the "file" field of a
+// multipart form, named by the "name" field
+func (h *Handler) handleSpeakers(w http.ResponseWriter, r *http.Request) {
+	speakers := h.speakers(w)
+	if speakers == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		profiles := speakers.Profiles()
+		for i := range profiles {
+			profiles[i].Voiceprint = nil
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"object": "list",
+			"data":   profiles,
+		})
+
+	case http.MethodPost:
+		h.enroll(w, r, speakers, "", http.StatusCreated)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET and POST are supported")
+	}
+}
+
+// handleSpeaker serves /admin/speakers/{id}: GET returns the profile, POST
+// adds another recording to its voiceprint (and renames it with "name"), and
+// DELETE removes it
+func (h *Handler) handleSpeaker(w http.ResponseWriter, r *http.Request) {
+	speakers := h.speakers(w)
+	if speakers == nil {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/admin/speakers/")
+
+	switch r.Method {
+	case http.MethodGet:
+		profile, err := speakers.Profile(id)
+		if err != nil {
+			writeSpeakerError(w, err)
+			return
+		}
+		profile.Voiceprint = nil
+		writeJSON(w, http.StatusOK, profile)
+
+	case http.MethodPost:
+		h.enroll(w, r, speakers, id, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := speakers.Delete(id); err != nil {
+			writeSpeakerError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":      id,
+			"deleted": true,
+		})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET, POST and DELETE are supported")
+	}
+}
+
+// enroll reads the uploaded recording and enrolls it for profile id, a new
+// profile when id is empty
+func (h *Handler) enroll(w http.ResponseWriter, r *http.Request, speakers *usecase.SpeakerIdentifier, id string, status int) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxEnrollmentBytes+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, domain.CodeInvalidRequest, "A WAV recording is required as the file field of a multipart form")
+		return
+	}
+	defer file.Close()
+	name := r.FormValue("name")
+	if id == "" && name == "" {
+		writeError(w, http.StatusBadRequest, domain.CodeMissingField, "name is required")
+		return
+	}
+
+	format, err := wav.ReadHeader(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, domain.CodeInvalidAudioFormat, "Send a 16-bit PCM WAV file: "+err.Error())
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxEnrollmentBytes+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, domain.CodeInvalidRequest, err.Error())
+		return
+	}
+	if len(data) > maxEnrollmentBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, domain.CodeFileTooLarge,
+			fmt.Sprintf("Enrollment recordings are limited to %d bytes", maxEnrollmentBytes))
+		return
+	}
+	frames := len(data) / (2 * format.Channels) * 2 * format.Channels
+	samples := pcm.ToFloat32(gateway.Downmix(data[:frames], format.Channels))
+	defer bufpool.PutFloat32s(samples)
+
+	profile, err := speakers.Enroll(id, name, samples, format.SampleRate)
+	if err != nil {
+		writeSpeakerError(w, err)
+		return
+	}
+	profile.Voiceprint = nil
+	writeJSON(w, status, profile)
+}
+
+// speakers returns the speaker identifier, answering 503 when speaker
+// identification is not configured
+func (h *Handler) speakers(w http.ResponseWriter) *usecase.SpeakerIdentifier {
+	speakers := h.UseCase.Speakers()
+	if speakers == nil {
+		writeError(w, http.StatusServiceUnavailable, domain.CodeConfigUnavailable,
+			"Speaker identification is not enabled, set asr.speaker.model")
+	}
+	return speakers
+}
+
+func writeSpeakerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, domain.ErrSpeakerNotFound) {
+		writeError(w, http.StatusNotFound, domain.CodeSpeakerNotFound, err.Error())
+		return
+	}
+	writeError(w, http.StatusBadRequest, domain.CodeInvalidRequest, err.Error())
+}
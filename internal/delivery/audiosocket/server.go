@@ -0,0 +1,234 @@
+// Package audiosocket serves Asterisk's AudioSocket protocol, so a dialplan
+// can stream a call to gribe with the AudioSocket() application or dial
+// string. Each connection is transcribed in its own transcription session.
+//
+// The protocol is a sequence of frames over TCP: a one-byte kind, a two-byte
+// big-endian payload length and the payload. Asterisk opens with the call's
+// UUID, then sends 20ms frames of 8kHz 16-bit signed linear audio.
+package audiosocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/delivery/gateway"
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/google/uuid"
+)
+
+// Frame kinds
+const (
+	kindHangup = 0x00 // Call ended, no payload
+	kindUUID   = 0x01 // 16-byte call UUID
+	kindDTMF   = 0x03 // One ASCII digit
+	kindAudio  = 0x10 // 8kHz mono 16-bit signed linear, little-endian
+	kindError  = 0xff // One-byte error code
+)
+
+// Server accepts AudioSocket connections and transcribes their audio
+type Server struct {
+	UseCase *usecase.SessionUsecase
+	Config  *config.AudioSocketConfig
+
+	// OnTranscript, if set, receives each final transcript of a call
+	OnTranscript func(callID, transcript string)
+
+	listener net.Listener
+	peers    gateway.PeerList
+	mu       sync.Mutex
+	conns    map[net.Conn]bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// callsActive counts the connected calls of all servers in the process
+var callsActive = metrics.NewGauge("gribe_audiosocket_calls_active", "Currently connected AudioSocket calls")
+
+// NewServer creates an AudioSocket server; call Start to begin accepting calls
+func NewServer(uc *usecase.SessionUsecase, cfg *config.AudioSocketConfig) *Server {
+	s := &Server{
+		UseCase: uc,
+		Config:  cfg,
+		peers:   gateway.ParsePeers(cfg.AllowedPeers),
+		conns:   make(map[net.Conn]bool),
+	}
+	return s
+}
+
+// Start listens on Config.Listen and serves connections in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.Config.Listen)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	log.Printf("[INFO] AudioSocket listening on tcp %s", listener.Addr())
+	s.wg.Add(1)
+	go s.serve()
+	return nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// ActiveCalls returns the number of connected calls
+func (s *Server) ActiveCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Close stops accepting calls, hangs up the connected ones and waits for
+// their transcriptions
+func (s *Server) Close() {
+	s.listener.Close()
+
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		writeFrame(conn, kindHangup, nil)
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("[WARN] AudioSocket accept error: %v", err)
+			continue
+		}
+
+		remote := conn.RemoteAddr().(*net.TCPAddr)
+		if !s.peers.Allows(remote.IP) {
+			log.Printf("[WARN] AudioSocket connection from disallowed peer %s", remote)
+			conn.Close()
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.mu.Unlock()
+		callsActive.Inc()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer panics.Recover("AudioSocket connection from "+conn.RemoteAddr().String(), nil)
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				callsActive.Dec()
+				conn.Close()
+			}()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle transcribes one call until it hangs up or stops sending audio
+func (s *Server) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(s.Config.MediaTimeout))
+	kind, payload, err := readFrame(reader)
+	if err != nil || kind != kindUUID || len(payload) != 16 {
+		log.Printf("[WARN] AudioSocket connection from %s did not start with a UUID frame", conn.RemoteAddr())
+		return
+	}
+	callID := uuid.Must(uuid.FromBytes(payload)).String()
+	label := "AudioSocket call " + callID
+	log.Printf("[INFO] %s connected from %s", label, conn.RemoteAddr())
+
+	session := gateway.StartSession(s.UseCase, gateway.SessionOptions{
+		Model:    s.Config.Model,
+		Language: s.Config.Language,
+		APIKey:   s.Config.APIKey,
+		Label:    label,
+		ClientIP: conn.RemoteAddr().(*net.TCPAddr).IP.String(),
+		Metadata: map[string]string{"call_id": callID},
+		OnTranscript: func(transcript string) {
+			if s.OnTranscript != nil {
+				s.OnTranscript(callID, transcript)
+			}
+		},
+	})
+	defer func() {
+		session.End()
+		log.Printf("[INFO] %s ended", label)
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.Config.MediaTimeout))
+		kind, payload, err := readFrame(reader)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("[WARN] %s: no audio for %v, hanging up", label, s.Config.MediaTimeout)
+				writeFrame(conn, kindHangup, nil)
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("[WARN] %s: %v", label, err)
+			}
+			return
+		}
+
+		switch kind {
+		case kindAudio:
+			session.Write8k(payload)
+		case kindHangup:
+			return
+		case kindError:
+			log.Printf("[WARN] %s: Asterisk reported error %x", label, payload)
+			return
+		}
+		// UUID repeats and DTMF digits need no handling
+	}
+}
+
+// readFrame reads one frame
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [3]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[1:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, fmt.Errorf("truncated frame: %w", err)
+	}
+	return header[0], payload, nil
+}
+
+// writeFrame writes one frame
+func writeFrame(w io.Writer, kind byte, payload []byte) error {
+	frame := make([]byte, 3+len(payload))
+	frame[0] = kind
+	binary.BigEndian.PutUint16(frame[1:], uint16(len(payload)))
+	copy(frame[3:], payload)
+	_, err := w.Write(frame)
+	return err
+}
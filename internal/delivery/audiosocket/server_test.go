@@ -0,0 +1,88 @@
+package audiosocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+	"github.com/google/uuid"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	writeFrame(&buf, kindDTMF, []byte("5"))
+	writeFrame(&buf, kindHangup, nil)
+
+	if !bytes.Equal(buf.Bytes()[:4], []byte{kindDTMF, 0, 1, '5'}) {
+		t.Errorf("Unexpected encoding %v", buf.Bytes())
+	}
+	kind, payload, err := readFrame(&buf)
+	if err != nil || kind != kindDTMF || string(payload) != "5" {
+		t.Errorf("Unexpected frame %x %q %v", kind, payload, err)
+	}
+	kind, payload, err = readFrame(&buf)
+	if err != nil || kind != kindHangup || len(payload) != 0 {
+		t.Errorf("Unexpected frame %x %q %v", kind, payload, err)
+	}
+	if _, _, err := readFrame(bytes.NewReader([]byte{kindAudio, 0, 4, 1})); err == nil {
+		t.Error("Expected a truncated frame to fail")
+	}
+}
+
+func TestAudioSocketCall(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	s := NewServer(srv.UseCase, &config.AudioSocketConfig{
+		Listen:       "127.0.0.1:0",
+		MediaTimeout: 5 * time.Second,
+		Model:        realtimetest.MockModel,
+		Language:     "en",
+	})
+	transcripts := make(chan string, 1)
+	s.OnTranscript = func(callID, transcript string) {
+		transcripts <- callID + ": " + transcript
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	callID := uuid.New()
+	writeFrame(conn, kindUUID, callID[:])
+
+	// 100ms of silence, then 400ms of speech, in 20ms frames
+	for i := 0; i < 25; i++ {
+		frame := make([]byte, 320)
+		if i >= 5 {
+			for j := 0; j < len(frame); j += 4 {
+				binary.LittleEndian.PutUint16(frame[j:], uint16(8000))
+				binary.LittleEndian.PutUint16(frame[j+2:], uint16(0xFFFF-8000+1))
+			}
+		}
+		writeFrame(conn, kindAudio, frame)
+	}
+	writeFrame(conn, kindDTMF, []byte("1"))
+	writeFrame(conn, kindHangup, nil)
+
+	select {
+	case transcript := <-transcripts:
+		if transcript != callID.String()+": hello" {
+			t.Errorf("Unexpected transcript %q", transcript)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the transcript")
+	}
+}
@@ -0,0 +1,150 @@
+// Package conversations serves GET /v1/conversations/{id} and
+// GET /v1/conversations/{id}/items, the conversations of ended sessions kept
+// by the conversation store, so transcripts can be fetched after the
+// connection is gone.
+package conversations
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Handler serves stored conversations
+type Handler struct {
+	UseCase *usecase.SessionUsecase
+	Auth    *middleware.Authenticator
+}
+
+// NewHandler creates the handler; mount it at /v1/conversations/
+func NewHandler(uc *usecase.SessionUsecase, auth *middleware.Authenticator) *Handler {
+	return &Handler{UseCase: uc, Auth: auth}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/conversations/"), "/")
+	if id == "" || (rest != "" && rest != "items") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET is supported")
+		return
+	}
+
+	clientIP := middleware.GetClientIP(r)
+	principal, err := h.Auth.Authenticate(r)
+	if err != nil {
+		log.Printf("Invalid credentials for conversation from IP %s: %v", clientIP, err)
+		writeError(w, http.StatusUnauthorized, domain.CodeInvalidAPIKey, "A valid API key is required")
+		return
+	}
+	admin := principal.HasScope(config.ScopeAdminRead)
+	if !admin && !principal.HasScope(config.ScopeRealtimeTranscribe) {
+		log.Printf("Conversation request without %s scope from IP: %s", config.ScopeRealtimeTranscribe, clientIP)
+		writeError(w, http.StatusForbidden, domain.CodeInsufficientScope, "Credentials lack the "+config.ScopeRealtimeTranscribe+" scope")
+		return
+	}
+	opts := usecase.SubscribeOptions{
+		APIKey:     principal.ID,
+		Tenant:     h.UseCase.ResolveTenant(principal.APIKey, principal.TenantClaim),
+		AnySession: admin,
+	}
+
+	if rest == "" {
+		conversation, err := h.UseCase.Conversation(id, opts)
+		if err != nil {
+			writeStoreError(w, id, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, conversation)
+		return
+	}
+
+	page, err := parseItemPage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, domain.CodeInvalidValue, err.Error())
+		return
+	}
+	items, err := h.UseCase.ConversationItems(id, opts, page)
+	if errors.Is(err, usecase.ErrItemNotFound) {
+		writeError(w, http.StatusBadRequest, domain.CodeInvalidValue, "after names no item of conversation "+id)
+		return
+	} else if err != nil {
+		writeStoreError(w, id, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// parseItemPage reads the limit, after, omit_audio, role and has_transcript
+// query parameters
+func parseItemPage(r *http.Request) (usecase.ItemPage, error) {
+	query := r.URL.Query()
+	page := usecase.ItemPage{After: query.Get("after"), Role: query.Get("role")}
+	switch page.Role {
+	case "", "user", "assistant", "system":
+	default:
+		return page, errors.New("role must be user, assistant or system")
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > usecase.MaxItemPageSize {
+			return page, errors.New("limit must be between 1 and " + strconv.Itoa(usecase.MaxItemPageSize))
+		}
+		page.Limit = limit
+	}
+	if value := query.Get("omit_audio"); value != "" {
+		omit, err := strconv.ParseBool(value)
+		if err != nil {
+			return page, errors.New("omit_audio must be true or false")
+		}
+		page.OmitAudio = omit
+	}
+	if value := query.Get("has_transcript"); value != "" {
+		has, err := strconv.ParseBool(value)
+		if err != nil {
+			return page, errors.New("has_transcript must be true or false")
+		}
+		page.HasTranscript = &has
+	}
+	return page, nil
+}
+
+// writeStoreError reports a conversation that could not be read. Conversations
+// of other credentials are reported as missing so IDs cannot be probed.
+func writeStoreError(w http.ResponseWriter, id string, err error) {
+	if errors.Is(err, domain.ErrConversationNotFound) {
+		writeError(w, http.StatusNotFound, domain.CodeConversationNotFound, "No stored conversation "+id)
+		return
+	}
+	log.Printf("[ERROR] Failed to read conversation %s: %v", id, err)
+	writeError(w, http.StatusInternalServerError, domain.CodeServerError, "Could not read the conversation")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"type":      "invalid_request_error",
+			"code":      code,
+			"message":   message,
+			"retryable": domain.IsRetryableCode(code),
+		},
+	})
+}
@@ -0,0 +1,139 @@
+package conversations
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/convstore"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+// get requests path of the conversations API with key
+func get(t *testing.T, server *httptest.Server, path, key string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp
+}
+
+func TestStoredConversation(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Auth.APIKeys = []string{"owner-key", "other-key"}
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+	srv.UseCase.SetConversationStore(convstore.NewMemory(10))
+
+	conversations := httptest.NewServer(NewHandler(srv.UseCase, srv.Handler.Auth))
+	defer conversations.Close()
+
+	client, err := srv.Dial(url.Values{"intent": {"transcription"}}, http.Header{"Authorization": {"Bearer owner-key"}})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	created, err := client.Expect(domain.EventTranscriptionSessionCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session domain.TranscriptionSessionCreatedEvent
+	if err := created.Decode(&session); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := client.AppendAudio(make([]byte, 3200)); err != nil {
+			t.Fatalf("AppendAudio failed: %v", err)
+		}
+		if err := client.Commit(); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if _, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.Close()
+
+	// The conversation is stored once the session ends
+	path := "/v1/conversations/" + session.Session.ID
+	var conversation domain.StoredConversation
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		resp := get(t, conversations, path, "owner-key")
+		if resp.StatusCode == http.StatusOK {
+			err := json.NewDecoder(resp.Body).Decode(&conversation)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			break
+		}
+		resp.Body.Close()
+		if time.Now().After(deadline) {
+			t.Fatalf("Conversation was not stored, last status %d", resp.StatusCode)
+		}
+	}
+	if conversation.SessionID != session.Session.ID || conversation.ItemCount != 3 {
+		t.Errorf("Expected 3 items of session %s, got %+v", session.Session.ID, conversation)
+	}
+
+	for _, tc := range []struct {
+		path, key string
+		status    int
+	}{
+		{"/v1/conversations/conv_missing", "owner-key", http.StatusNotFound},
+		{path, "other-key", http.StatusNotFound},
+		{path, "wrong-key", http.StatusUnauthorized},
+		{path + "/items?limit=0", "owner-key", http.StatusBadRequest},
+		{path + "/items?after=item_missing", "owner-key", http.StatusBadRequest},
+		{"/v1/conversations/" + conversation.ID, "owner-key", http.StatusOK},
+	} {
+		resp := get(t, conversations, tc.path, tc.key)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("Expected %d for %s with %s, got %d", tc.status, tc.path, tc.key, resp.StatusCode)
+		}
+	}
+
+	// Items are paged in order, after the last item of the previous page
+	var seen []string
+	after := ""
+	for page := 0; ; page++ {
+		resp := get(t, conversations, path+"/items?limit=2&omit_audio=true&after="+after, "owner-key")
+		var list domain.ItemList
+		err := json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		for _, item := range list.Data {
+			seen = append(seen, item.ID)
+			if item.Content[0].Audio != "" || item.Content[0].Transcript != "hello" {
+				t.Errorf("Expected the transcript without audio, got %+v", item.Content[0])
+			}
+		}
+		if !list.HasMore {
+			break
+		}
+		if page > 1 {
+			t.Fatal("Expected the items to fit on two pages")
+		}
+		after = list.LastID
+	}
+	if len(seen) != 3 || seen[0] == seen[1] || seen[1] == seen[2] {
+		t.Errorf("Expected 3 distinct items, got %v", seen)
+	}
+}
@@ -0,0 +1,125 @@
+// Package erasure serves the deletion of stored session data on request:
+// DELETE /v1/sessions/{id}/data erases the data of one session and
+// DELETE /v1/data that of every session of the caller, or with admin:write
+// of any credentials, optionally within a time range. Both answer with a
+// deletion receipt.
+package erasure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Handler erases stored session data
+type Handler struct {
+	UseCase *usecase.SessionUsecase
+	Auth    *middleware.Authenticator
+}
+
+// NewHandler creates the handler; mount it at /v1/data and route
+// /v1/sessions/{id}/data to it
+func NewHandler(uc *usecase.SessionUsecase, auth *middleware.Authenticator) *Handler {
+	return &Handler{UseCase: uc, Auth: auth}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var erasure usecase.Erasure
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/v1/sessions/"); ok {
+		erasure.SessionID = strings.TrimSuffix(rest, "/data")
+	} else if r.URL.Path != "/v1/data" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only DELETE is supported")
+		return
+	}
+
+	clientIP := middleware.GetClientIP(r)
+	principal, err := h.Auth.Authenticate(r)
+	if err != nil {
+		log.Printf("Invalid credentials for data erasure from IP %s: %v", clientIP, err)
+		writeError(w, http.StatusUnauthorized, domain.CodeInvalidAPIKey, "A valid API key is required")
+		return
+	}
+	admin := principal.HasScope(config.ScopeAdminWrite)
+	if !admin && !principal.HasScope(config.ScopeRealtimeTranscribe) {
+		log.Printf("Data erasure request without %s scope from IP: %s", config.ScopeRealtimeTranscribe, clientIP)
+		writeError(w, http.StatusForbidden, domain.CodeInsufficientScope, "Credentials lack the "+config.ScopeRealtimeTranscribe+" scope")
+		return
+	}
+
+	query := r.URL.Query()
+	for name, bound := range map[string]*time.Time{"since": &erasure.Since, "until": &erasure.Until} {
+		if value := query.Get(name); value != "" {
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seconds < 0 {
+				writeError(w, http.StatusBadRequest, domain.CodeInvalidValue, fmt.Sprintf("%s must be Unix seconds, got %q", name, value))
+				return
+			}
+			*bound = time.Unix(seconds, 0)
+		}
+	}
+
+	// Callers erase their own sessions; admins those of any credentials,
+	// narrowed by api_key, but never all data at once by accident
+	opts := usecase.SubscribeOptions{
+		APIKey:     principal.ID,
+		Tenant:     h.UseCase.ResolveTenant(principal.APIKey, principal.TenantClaim),
+		AnySession: admin,
+	}
+	if erasure.SessionID == "" {
+		erasure.APIKey = principal.ID
+		if admin {
+			erasure.APIKey = query.Get("api_key")
+			if erasure.APIKey == "" && erasure.Since.IsZero() && erasure.Until.IsZero() {
+				writeError(w, http.StatusBadRequest, domain.CodeMissingField, "Admin erasures require api_key, since or until")
+				return
+			}
+		}
+	}
+
+	receipt, err := h.UseCase.Erase(erasure, opts)
+	switch {
+	case errors.Is(err, usecase.ErrSessionNotFound):
+		writeError(w, http.StatusNotFound, domain.CodeSessionNotFound, "No stored data of session "+erasure.SessionID)
+	case errors.Is(err, usecase.ErrSessionActive):
+		writeError(w, http.StatusConflict, domain.CodeSessionActive, "Session "+erasure.SessionID+" is still active, erase its data once it ends")
+	case err != nil:
+		log.Printf("[ERROR] Data erasure failed: %v", err)
+		writeError(w, http.StatusInternalServerError, domain.CodeServerError, "Some data could not be erased, try again")
+	default:
+		log.Printf("Data erasure %s deleted %d stored items", receipt.ID, len(receipt.Deleted))
+		writeJSON(w, http.StatusOK, receipt)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"type":      "invalid_request_error",
+			"code":      code,
+			"message":   message,
+			"retryable": domain.IsRetryableCode(code),
+		},
+	})
+}
@@ -0,0 +1,91 @@
+package erasure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/pkg/convstore"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+// erase sends DELETE path with key
+func erase(t *testing.T, server *httptest.Server, path, key string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodDelete, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp
+}
+
+func TestErasure(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Auth.APIKeys = []string{"owner-key", "other-key"}
+	cfg.Admin.APIKeys = []string{"admin-key"}
+	uc := usecase.NewSessionUsecase()
+	store := convstore.NewMemory(10)
+	uc.SetConversationStore(store)
+	for _, conversation := range []*domain.StoredConversation{
+		{ID: "conv_1", SessionID: "sess_1", CreatedAt: 100, Owner: domain.OwnerHash("owner-key")},
+		{ID: "conv_2", SessionID: "sess_2", CreatedAt: 200, Owner: domain.OwnerHash("owner-key")},
+		{ID: "conv_3", SessionID: "sess_3", CreatedAt: 300, Owner: domain.OwnerHash("other-key")},
+	} {
+		if err := store.Save(conversation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	handler := NewHandler(uc, middleware.NewAuthenticator(cfg))
+	mux.Handle("/v1/data", handler)
+	mux.Handle("/v1/sessions/", handler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		key     string
+		status  int
+		deleted int
+	}{
+		{"another key's session", "/v1/sessions/sess_1/data", "other-key", http.StatusNotFound, 0},
+		{"own session", "/v1/sessions/sess_1/data", "owner-key", http.StatusOK, 1},
+		{"erased session", "/v1/sessions/sess_1/data", "owner-key", http.StatusNotFound, 0},
+		{"bad time range", "/v1/data?since=yesterday", "owner-key", http.StatusBadRequest, 0},
+		{"admin without filter", "/v1/data", "admin-key", http.StatusBadRequest, 0},
+		{"own sessions in range", "/v1/data?until=250", "owner-key", http.StatusOK, 1},
+		{"admin by key", "/v1/data?api_key=other-key", "admin-key", http.StatusOK, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := erase(t, server, tc.path, tc.key)
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.status {
+				t.Fatalf("Expected status %d, got %d", tc.status, resp.StatusCode)
+			}
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			var receipt domain.DeletionReceipt
+			if err := json.NewDecoder(resp.Body).Decode(&receipt); err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if receipt.Object != "deletion.receipt" || len(receipt.Deleted) != tc.deleted {
+				t.Errorf("Expected a receipt of %d deletions, got %+v", tc.deleted, receipt)
+			}
+		})
+	}
+	if conversations, _ := store.List(); len(conversations) != 0 {
+		t.Errorf("Expected every conversation to be erased, got %d left", len(conversations))
+	}
+}
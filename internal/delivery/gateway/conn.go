@@ -0,0 +1,194 @@
+// Package gateway adapts non-WebSocket audio transports (SIP/RTP, AudioSocket,
+// HTTP streaming) to transcription sessions. A transport drives a session
+// through a Conn the same way a WebSocket client would, by queueing client
+// events, and receives the server events through a callback.
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/gorilla/websocket"
+)
+
+// SampleRate is the PCM16 rate transports feed sessions at, the rate the ASR
+// providers decode
+const SampleRate = 16000
+
+// clientEventQueue is the number of client events a session can fall behind
+// before sends block
+const clientEventQueue = 256
+
+// ErrClosed is returned when sending on a closed Conn
+var ErrClosed = errors.New("gateway connection closed")
+
+// EventFunc receives each server event as its type and raw JSON
+type EventFunc func(eventType domain.EventType, raw []byte)
+
+// Conn implements usecase.Conn for a session driven in-process. ReadMessage
+// returns the queued client events and blocks until Close once none remain.
+type Conn struct {
+	onEvent EventFunc
+	events  chan []byte
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.Mutex // Serializes onEvent calls
+	turns   int64      // Detected turns not yet transcribed, accessed atomically
+}
+
+// NewConn creates a connection passing server events to onEvent, which may be nil
+func NewConn(onEvent EventFunc) *Conn {
+	return &Conn{
+		onEvent: onEvent,
+		events:  make(chan []byte, clientEventQueue),
+		done:    make(chan struct{}),
+	}
+}
+
+// Send queues a client event for the session
+func (c *Conn) Send(event interface{}) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return c.SendRaw(data)
+}
+
+// SendRaw queues a client event received as JSON, such as a message relayed
+// from a client, without validating it
+func (c *Conn) SendRaw(data []byte) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	select {
+	case c.events <- data:
+		return nil
+	case <-c.done:
+		return ErrClosed
+	}
+}
+
+// Configure selects the transcription model and language, enables server VAD
+// and sets the input format to SampleRate PCM16. Without a model and
+// language the server's defaults apply, with no prompt or temperature.
+// include adds details to completed transcripts.
+func (c *Conn) Configure(transcription domain.TranscriptionConfig, include []string) error {
+	input := map[string]interface{}{
+		"format":         &domain.AudioFormat{Type: "audio/pcm", Rate: SampleRate},
+		"turn_detection": map[string]interface{}{"type": "server_vad"},
+	}
+	if transcription.Model != "" || transcription.Language != "" || transcription.Translate {
+		input["transcription"] = &transcription
+	}
+	session := map[string]interface{}{"audio": map[string]interface{}{"input": input}}
+	if len(include) > 0 {
+		session["include"] = include
+	}
+	return c.Send(map[string]interface{}{
+		"type":    domain.EventSessionUpdate,
+		"session": session,
+	})
+}
+
+// AppendAudio queues SampleRate PCM16 audio for the session
+func (c *Conn) AppendAudio(pcm []byte) error {
+	return c.Send(&domain.InputAudioBufferAppendEvent{
+		BaseEvent: domain.BaseEvent{Type: domain.EventInputAudioBufferAppend},
+		Audio:     base64.StdEncoding.EncodeToString(pcm),
+	})
+}
+
+// ReadMessage returns the next queued client event
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	select {
+	case data := <-c.events:
+		return websocket.TextMessage, data, nil
+	case <-c.done:
+		return 0, nil, ErrClosed
+	}
+}
+
+// WriteJSON passes a server event to the event callback
+func (c *Conn) WriteJSON(v interface{}) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	if c.onEvent == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var base domain.BaseEvent
+	if err := json.Unmarshal(data, &base); err != nil {
+		return err
+	}
+	switch base.Type {
+	case domain.EventInputAudioBufferSpeechStarted:
+		atomic.AddInt64(&c.turns, 1)
+	case domain.EventConversationItemInputAudioTranscriptionCompleted,
+		domain.EventConversationItemInputAudioTranscriptionFailed:
+		atomic.AddInt64(&c.turns, -1)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvent(base.Type, data)
+	return nil
+}
+
+// Finish ends the session when its audio stream ends. It appends trailing
+// silence so server VAD closes the current turn, then waits up to timeout for
+// the queued audio to be processed and the detected turns transcribed.
+func (c *Conn) Finish(trailing []byte, timeout time.Duration) {
+	if len(trailing) > 0 {
+		c.AppendAudio(trailing)
+	}
+
+	deadline := time.Now().Add(timeout)
+	idle := 0
+	for time.Now().Before(deadline) && idle < 2 {
+		time.Sleep(20 * time.Millisecond)
+		if len(c.events) == 0 && atomic.LoadInt64(&c.turns) <= 0 {
+			idle++
+		} else {
+			idle = 0
+		}
+	}
+	c.Close()
+}
+
+// Close ends the session once the events already read are handled
+func (c *Conn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+// Upsample2x doubles the sample rate of PCM16 audio by linear interpolation,
+// turning 8kHz telephony audio into the SampleRate providers expect
+func Upsample2x(pcm []byte) []byte {
+	n := len(pcm) / 2
+	out := make([]byte, n*4)
+	for i := 0; i < n; i++ {
+		cur := int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+		next := cur
+		if i+1 < n {
+			next = int16(binary.LittleEndian.Uint16(pcm[i*2+2:]))
+		}
+		binary.LittleEndian.PutUint16(out[i*4:], uint16(cur))
+		binary.LittleEndian.PutUint16(out[i*4+2:], uint16(int16((int32(cur)+int32(next))/2)))
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package gateway
+
+import "encoding/binary"
+
+// Downmix averages the channels of interleaved PCM16 audio into mono
+func Downmix(pcm []byte, channels int) []byte {
+	if channels <= 1 {
+		return pcm
+	}
+	frames := len(pcm) / (2 * channels)
+	out := make([]byte, frames*2)
+	for i := 0; i < frames; i++ {
+		var sum int32
+		for c := 0; c < channels; c++ {
+			sum += int32(int16(binary.LittleEndian.Uint16(pcm[(i*channels+c)*2:])))
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(sum/int32(channels))))
+	}
+	return out
+}
+
+// Resampler converts mono PCM16 audio to SampleRate by linear interpolation.
+// It keeps its position across calls, so a stream can be fed in chunks of
+// any length.
+type Resampler struct {
+	from   int     // Input sample rate
+	pos    float64 // Position of the next output sample, relative to prev
+	prev   int16   // Last input sample of the previous chunk
+	primed bool
+}
+
+// NewResampler creates a resampler for audio at rate from
+func NewResampler(from int) *Resampler {
+	return &Resampler{from: from}
+}
+
+// Resample converts the next chunk of the stream
+func (r *Resampler) Resample(pcm []byte) []byte {
+	if r.from == SampleRate {
+		return pcm
+	}
+	n := len(pcm) / 2
+	if n == 0 {
+		return nil
+	}
+	sample := func(i int) int16 {
+		if i < 0 {
+			return r.prev
+		}
+		return int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+	}
+	if !r.primed {
+		// Start on the first input sample rather than interpolating from silence
+		r.prev = sample(0)
+		r.primed = true
+	}
+
+	step := float64(r.from) / SampleRate
+	out := make([]byte, 0, int(float64(n)/step+1)*2)
+	// Output samples lie between input samples i-1 and i, with prev at -1
+	for ; r.pos < float64(n); r.pos += step {
+		i := int(r.pos)
+		frac := r.pos - float64(i)
+		a, b := sample(i-1), sample(i)
+		v := int16(float64(a) + (float64(b)-float64(a))*frac)
+		out = binary.LittleEndian.AppendUint16(out, uint16(v))
+	}
+	r.pos -= float64(n)
+	r.prev = sample(n - 1)
+	return out
+}
@@ -0,0 +1,32 @@
+package gateway
+
+import "net"
+
+// PeerList restricts which addresses may connect to a transport
+type PeerList []*net.IPNet
+
+// ParsePeers parses IPs and CIDRs, skipping invalid entries (rejected by config validation)
+func ParsePeers(peers []string) PeerList {
+	var list PeerList
+	for _, peer := range peers {
+		if _, network, err := net.ParseCIDR(peer); err == nil {
+			list = append(list, network)
+		} else if ip := net.ParseIP(peer); ip != nil {
+			list = append(list, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		}
+	}
+	return list
+}
+
+// Allows reports whether ip may connect. An empty list allows everyone.
+func (l PeerList) Allows(ip net.IP) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, network := range l {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package gateway
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+const (
+	chunkMs           = 100              // Audio batched into each input_audio_buffer.append
+	trailingSilenceMs = 1000             // Silence appended at the end so VAD ends the last turn
+	finishTimeout     = 10 * time.Second // Time to finish transcribing once the audio ends
+)
+
+// SessionOptions configures a transcription session fed by a transport
+type SessionOptions struct {
+	Model       string // Transcription model, empty for the server default
+	Language    string
+	Prompt      string         // Transcription prompt, for models that support one
+	Temperature float64        // Transcription temperature, for models that support one
+	Translate   bool           // Translate to English, for models that support it
+	APIKey      string         // Key the session is accounted to for quotas and tenants
+	Tenant      *domain.Tenant // Tenant of the session, resolved from APIKey if nil
+	Label       string         // Identifies the session in logs, e.g. "SIP call abc stream 0"
+	ClientIP    string         // Address of the peer sending the audio, empty when unknown
+	Include     []string       // Details added to completed transcripts, see domain.IncludeWords
+
+	// Metadata is attached to the session, see domain.Session.Metadata
+	Metadata map[string]string
+
+	// FinishTimeout bounds the wait for the last transcripts in End, 10s if zero
+	FinishTimeout time.Duration
+
+	// OnTranscript, if set, receives each final transcript
+	OnTranscript func(transcript string)
+
+	// OnEvent, if set, receives every server event of the session
+	OnEvent EventFunc
+}
+
+// Session is a transcription session fed with PCM16 audio by a transport
+type Session struct {
+	conn    *Conn
+	opts    SessionOptions
+	pending []byte
+	done    chan struct{}
+}
+
+// StartSession opens a transcription session with server VAD
+func StartSession(uc *usecase.SessionUsecase, opts SessionOptions) *Session {
+	s := &Session{opts: opts, done: make(chan struct{})}
+	s.conn = NewConn(s.handleEvent)
+	tenant := opts.Tenant
+	if tenant == nil {
+		tenant = uc.ResolveTenant(opts.APIKey, "")
+	}
+
+	go func() {
+		defer close(s.done)
+		defer panics.Recover(opts.Label, nil)
+		uc.HandleNewConnectionWithOptions(s.conn, usecase.ConnectOptions{
+			Intent:   usecase.IntentTranscription,
+			APIKey:   opts.APIKey,
+			Tenant:   tenant,
+			ClientIP: opts.ClientIP,
+			Metadata: opts.Metadata,
+		})
+	}()
+	if err := s.conn.Configure(domain.TranscriptionConfig{
+		Model:       opts.Model,
+		Language:    opts.Language,
+		Prompt:      opts.Prompt,
+		Temperature: opts.Temperature,
+		Translate:   opts.Translate,
+	}, opts.Include); err != nil {
+		log.Printf("[WARN] %s: %v", opts.Label, err)
+	}
+	return s
+}
+
+// Write8k queues 8kHz PCM16 audio
+func (s *Session) Write8k(pcm []byte) {
+	s.Write(Upsample2x(pcm))
+}
+
+// Write queues SampleRate PCM16 audio, appended to the session in chunkMs
+// pieces; VAD judges each append as a whole, so large writes are split
+func (s *Session) Write(pcm []byte) {
+	const chunkBytes = SampleRate * 2 * chunkMs / 1000
+	s.pending = append(s.pending, pcm...)
+	for len(s.pending) >= chunkBytes {
+		s.conn.AppendAudio(s.pending[:chunkBytes])
+		s.pending = s.pending[chunkBytes:]
+	}
+}
+
+// End sends the remaining audio, waits for its transcription and closes the session
+func (s *Session) End() {
+	if len(s.pending) > 0 {
+		s.conn.AppendAudio(s.pending)
+		s.pending = nil
+	}
+	timeout := s.opts.FinishTimeout
+	if timeout <= 0 {
+		timeout = finishTimeout
+	}
+	s.conn.Finish(make([]byte, SampleRate*2*trailingSilenceMs/1000), timeout)
+	<-s.done
+}
+
+// handleEvent reports the transcripts and errors of the session
+func (s *Session) handleEvent(eventType domain.EventType, raw []byte) {
+	if s.opts.OnEvent != nil {
+		s.opts.OnEvent(eventType, raw)
+	}
+
+	switch eventType {
+	case domain.EventConversationItemInputAudioTranscriptionCompleted:
+		var event domain.ConversationItemInputAudioTranscriptionCompletedEvent
+		if err := json.Unmarshal(raw, &event); err != nil || event.Transcript == "" {
+			return
+		}
+		log.Printf("[INFO] %s: %s", s.opts.Label, event.Transcript)
+		if s.opts.OnTranscript != nil {
+			s.opts.OnTranscript(event.Transcript)
+		}
+
+	case domain.EventError, domain.EventConversationItemInputAudioTranscriptionFailed:
+		log.Printf("[WARN] %s: %s", s.opts.Label, raw)
+	}
+}
@@ -0,0 +1,113 @@
+// Package mqtt publishes session transcripts to an MQTT broker, for devices
+// that consume results over MQTT instead of holding the realtime WebSocket.
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// connectTimeout bounds the initial connection attempt in Start
+const connectTimeout = 10 * time.Second
+
+// Publisher metrics are shared by the publishers of the process
+var (
+	messagesPublished = metrics.NewCounter("gribe_mqtt_messages_published_total",
+		"Transcript messages published to the MQTT broker")
+	publishErrors = metrics.NewCounter("gribe_mqtt_publish_errors_total",
+		"Transcript messages the MQTT broker did not accept")
+)
+
+// Publisher forwards final transcripts, and optionally transcription deltas,
+// of every session. Events are published unchanged as JSON.
+type Publisher struct {
+	Config *config.MQTTConfig
+
+	client paho.Client
+}
+
+// NewPublisher creates a publisher; call Start to connect to the broker
+func NewPublisher(cfg *config.MQTTConfig) *Publisher {
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(paho.Client) {
+			log.Printf("[INFO] MQTT connected to %s", cfg.Broker)
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Printf("[WARN] MQTT connection to %s lost: %v", cfg.Broker, err)
+		})
+
+	return newPublisher(cfg, paho.NewClient(opts))
+}
+
+func newPublisher(cfg *config.MQTTConfig, client paho.Client) *Publisher {
+	return &Publisher{
+		Config: cfg,
+		client: client,
+	}
+}
+
+// Start connects to the broker. An unreachable broker is not an error: the
+// client keeps retrying in the background.
+func (p *Publisher) Start() error {
+	token := p.client.Connect()
+	if token.WaitTimeout(connectTimeout) && token.Error() != nil {
+		return fmt.Errorf("connecting to %s: %w", p.Config.Broker, token.Error())
+	}
+	if !p.client.IsConnected() {
+		log.Printf("[WARN] MQTT broker %s not reachable yet, retrying in the background", p.Config.Broker)
+	}
+	return nil
+}
+
+// Observe publishes transcription events; register it with
+// SessionUsecase.AddEventObserver
+func (p *Publisher) Observe(sessionID string, event interface{}) {
+	var topic string
+	switch event.(type) {
+	case *domain.ConversationItemInputAudioTranscriptionCompletedEvent:
+		topic = p.Config.TranscriptTopic
+	case *domain.ConversationItemInputAudioTranscriptionDeltaEvent:
+		topic = p.Config.DeltaTopic
+	}
+	if topic == "" {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[WARN] MQTT could not encode event for session %s: %v", sessionID, err)
+		return
+	}
+	topic = strings.ReplaceAll(topic, "{session_id}", sessionID)
+
+	// Observers must not block the session, so completion is checked aside
+	token := p.client.Publish(topic, byte(p.Config.QoS), false, payload)
+	go func() {
+		token.Wait()
+		if err := token.Error(); err != nil {
+			publishErrors.Inc()
+			log.Printf("[WARN] MQTT publish to %s failed: %v", topic, err)
+			return
+		}
+		messagesPublished.Inc()
+	}()
+}
+
+// Close disconnects from the broker, giving in-flight messages a second
+func (p *Publisher) Close() {
+	p.client.Disconnect(1000)
+}
@@ -0,0 +1,132 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeClient records publishes instead of talking to a broker
+type fakeClient struct {
+	paho.Client
+
+	mu       sync.Mutex
+	messages []message
+}
+
+type message struct {
+	topic   string
+	qos     byte
+	payload []byte
+}
+
+func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload interface{}) paho.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, message{topic, qos, payload.([]byte)})
+	return &doneToken{}
+}
+
+func (c *fakeClient) published() []message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]message(nil), c.messages...)
+}
+
+// doneToken is a token for an operation that already succeeded
+type doneToken struct{}
+
+func (*doneToken) Wait() bool                     { return true }
+func (*doneToken) WaitTimeout(time.Duration) bool { return true }
+func (*doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+func (*doneToken) Error() error { return nil }
+
+func TestPublishTranscripts(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello", " world"})
+
+	client := &fakeClient{}
+	publisher := newPublisher(&config.MQTTConfig{
+		TranscriptTopic: "kiosk/{session_id}/transcript",
+		DeltaTopic:      "kiosk/{session_id}/delta",
+		QoS:             1,
+	}, client)
+	srv.UseCase.AddEventObserver(publisher.Observe)
+
+	conn, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	created, err := conn.Expect(domain.EventTranscriptionSessionCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session domain.TranscriptionSessionCreatedEvent
+	if err := created.Decode(&session); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if _, err := conn.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AppendAudio(make([]byte, 3200)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+	if err := conn.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, err := conn.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := client.published()
+	if len(messages) < 2 {
+		t.Fatalf("Expected deltas and a transcript, got %d message(s)", len(messages))
+	}
+	for _, msg := range messages[:len(messages)-1] {
+		if msg.topic != "kiosk/"+session.Session.ID+"/delta" {
+			t.Errorf("Expected delta topic, got %q", msg.topic)
+		}
+	}
+
+	final := messages[len(messages)-1]
+	if final.topic != "kiosk/"+session.Session.ID+"/transcript" || final.qos != 1 {
+		t.Errorf("Expected transcript on kiosk/%s/transcript at QoS 1, got %q at QoS %d",
+			session.Session.ID, final.topic, final.qos)
+	}
+	var completed domain.ConversationItemInputAudioTranscriptionCompletedEvent
+	if err := json.Unmarshal(final.payload, &completed); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if completed.Transcript != "hello world" {
+		t.Errorf("Expected transcript 'hello world', got %q", completed.Transcript)
+	}
+}
+
+func TestDeltasOptional(t *testing.T) {
+	client := &fakeClient{}
+	publisher := newPublisher(&config.MQTTConfig{TranscriptTopic: "transcripts"}, client)
+
+	publisher.Observe("sess_1", &domain.ConversationItemInputAudioTranscriptionDeltaEvent{Delta: "hel"})
+	publisher.Observe("sess_1", &domain.InputAudioBufferCommittedEvent{})
+	publisher.Observe("sess_1", &domain.ConversationItemInputAudioTranscriptionCompletedEvent{Transcript: "hello"})
+
+	messages := client.published()
+	if len(messages) != 1 || messages[0].topic != "transcripts" {
+		t.Errorf("Expected only the transcript on 'transcripts', got %+v", messages)
+	}
+}
@@ -0,0 +1,107 @@
+// Package sessions serves the HTTP API of realtime sessions under
+// /v1/sessions/{id}: the time-aligned transcript of a session, its event
+// stream served by package sse, and the erasure of its stored data served by
+// package erasure.
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aira-id/gribe/internal/delivery/erasure"
+	"github.com/aira-id/gribe/internal/delivery/sse"
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Handler routes session requests
+type Handler struct {
+	UseCase *usecase.SessionUsecase
+	Auth    *middleware.Authenticator
+	Events  http.Handler // GET /v1/sessions/{id}/events
+	Data    http.Handler // DELETE /v1/sessions/{id}/data
+}
+
+// NewHandler creates the handler; mount it at /v1/sessions/
+func NewHandler(uc *usecase.SessionUsecase, auth *middleware.Authenticator) *Handler {
+	return &Handler{UseCase: uc, Auth: auth, Events: sse.NewHandler(uc, auth), Data: erasure.NewHandler(uc, auth)}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/sessions/"), "/")
+	if sessionID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	switch rest {
+	case "events":
+		h.Events.ServeHTTP(w, r)
+	case "transcript":
+		h.serveTranscript(w, r, sessionID)
+	case "data":
+		h.Data.ServeHTTP(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveTranscript handles GET /v1/sessions/{id}/transcript
+func (h *Handler) serveTranscript(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET is supported")
+		return
+	}
+
+	clientIP := middleware.GetClientIP(r)
+	principal, err := h.Auth.Authenticate(r)
+	if err != nil {
+		log.Printf("Invalid credentials for session transcript from IP %s: %v", clientIP, err)
+		writeError(w, http.StatusUnauthorized, domain.CodeInvalidAPIKey, "A valid API key is required")
+		return
+	}
+	admin := principal.HasScope(config.ScopeAdminRead)
+	if !admin && !principal.HasScope(config.ScopeRealtimeTranscribe) {
+		log.Printf("Session transcript request without %s scope from IP: %s", config.ScopeRealtimeTranscribe, clientIP)
+		writeError(w, http.StatusForbidden, domain.CodeInsufficientScope, "Credentials lack the "+config.ScopeRealtimeTranscribe+" scope")
+		return
+	}
+
+	transcript, err := h.UseCase.Transcript(sessionID, usecase.SubscribeOptions{
+		APIKey:     principal.ID,
+		Tenant:     h.UseCase.ResolveTenant(principal.APIKey, principal.TenantClaim),
+		AnySession: admin,
+	})
+	// Sessions of other credentials are reported as missing so IDs cannot be probed
+	if errors.Is(err, usecase.ErrSessionNotFound) || errors.Is(err, usecase.ErrSessionForbidden) {
+		writeError(w, http.StatusNotFound, domain.CodeSessionNotFound, "No live session "+sessionID)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, domain.CodeServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, transcript)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"type":      "invalid_request_error",
+			"code":      code,
+			"message":   message,
+			"retryable": domain.IsRetryableCode(code),
+		},
+	})
+}
@@ -0,0 +1,110 @@
+package sessions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+// getTranscript requests the session's transcript with key
+func getTranscript(t *testing.T, server *httptest.Server, sessionID, key string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/sessions/"+sessionID+"/transcript", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp
+}
+
+func TestSessionTranscript(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Auth.APIKeys = []string{"owner-key", "other-key"}
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello world"})
+
+	sessions := httptest.NewServer(NewHandler(srv.UseCase, srv.Handler.Auth))
+	defer sessions.Close()
+
+	client, err := srv.Dial(url.Values{"intent": {"transcription"}}, http.Header{"Authorization": {"Bearer owner-key"}})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	created, err := client.Expect(domain.EventTranscriptionSessionCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session domain.TranscriptionSessionCreatedEvent
+	if err := created.Decode(&session); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two turns of 500ms and 300ms of 24kHz pcm16
+	for _, size := range []int{24000, 14400} {
+		if err := client.AppendAudio(make([]byte, size)); err != nil {
+			t.Fatalf("AppendAudio failed: %v", err)
+		}
+		if err := client.Commit(); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if _, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		sessionID, key string
+		status         int
+	}{
+		{"sess_missing", "owner-key", http.StatusNotFound},
+		{session.Session.ID, "other-key", http.StatusNotFound},
+		{session.Session.ID, "wrong-key", http.StatusUnauthorized},
+	} {
+		resp := getTranscript(t, sessions, tc.sessionID, tc.key)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("Expected %d for %s with %s, got %d", tc.status, tc.sessionID, tc.key, resp.StatusCode)
+		}
+	}
+
+	resp := getTranscript(t, sessions, session.Session.ID, "owner-key")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	var transcript domain.SessionTranscript
+	if err := json.NewDecoder(resp.Body).Decode(&transcript); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if transcript.Text != "hello world hello world" || transcript.DurationMs != 800 {
+		t.Errorf("Expected 800ms of \"hello world hello world\", got %dms of %q", transcript.DurationMs, transcript.Text)
+	}
+	if len(transcript.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %+v", transcript.Items)
+	}
+	second := transcript.Items[1]
+	if second.StartMs != 500 || second.EndMs != 800 {
+		t.Errorf("Expected the second item at 500-800ms, got %d-%d", second.StartMs, second.EndMs)
+	}
+	// Words are placed on the session's audio, not the item's
+	if len(second.Words) != 2 || second.Words[0].StartMs != 500 || second.Words[1].EndMs != 600 {
+		t.Errorf("Expected the second item's words at 500-600ms, got %+v", second.Words)
+	}
+}
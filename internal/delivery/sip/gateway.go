@@ -0,0 +1,514 @@
+// Package sip is an optional SIP/RTP ingestion gateway. It answers INVITEs
+// over UDP, including SIPREC recording sessions, receives the G.711 RTP audio
+// of each call and transcribes every audio stream in its own transcription
+// session. The PBX reaches it as a static SIP trunk; registration, digest
+// authentication and Record-Route are not supported.
+package sip
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/delivery/gateway"
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/internal/pkg/g711"
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// 200 OK retransmission until ACK (RFC 3261 timers T1, T2 and 64*T1)
+const (
+	timerT1 = 500 * time.Millisecond
+	timerT2 = 4 * time.Second
+	timerH  = 64 * timerT1
+)
+
+// allowedMethods is sent in Allow headers
+const allowedMethods = "INVITE, ACK, BYE, CANCEL, OPTIONS"
+
+// Gateway accepts SIP calls and feeds their audio to transcription sessions
+type Gateway struct {
+	UseCase *usecase.SessionUsecase
+	Config  *config.SIPConfig
+
+	// OnTranscript, if set, receives each final transcript of a call stream
+	OnTranscript func(callID string, stream int, transcript string)
+
+	conn     *net.UDPConn
+	bindIP   net.IP
+	peers    gateway.PeerList
+	mu       sync.Mutex
+	calls    map[string]*call // Call-ID -> call
+	ports    map[int]bool     // RTP ports in use
+	nextPort int
+	wg       sync.WaitGroup
+}
+
+// call is an answered INVITE dialog
+type call struct {
+	id         string
+	peer       *net.UDPAddr
+	invite     *message
+	inviteCSeq int
+	tag        string // Our To tag
+	answer     []byte // SDP answer
+	response   []byte // 200 OK to the INVITE, resent until ACK
+	streams    []*stream
+	acked      chan struct{}
+	ackOnce    sync.Once
+	done       chan struct{}
+	endOnce    sync.Once
+}
+
+// stream is one received audio stream of a call, e.g. one SIPREC participant
+type stream struct {
+	index       int
+	conn        *net.UDPConn
+	port        int
+	payloadType int
+	encoding    string
+	session     *gateway.Session
+}
+
+// callsActive counts the answered calls of all gateways in the process
+var callsActive = metrics.NewGauge("gribe_sip_calls_active", "Currently answered SIP calls")
+
+// NewGateway creates a SIP gateway; call Start to begin accepting calls
+func NewGateway(uc *usecase.SessionUsecase, cfg *config.SIPConfig) *Gateway {
+	g := &Gateway{
+		UseCase: uc,
+		Config:  cfg,
+		calls:   make(map[string]*call),
+		ports:   make(map[int]bool),
+		peers:   gateway.ParsePeers(cfg.AllowedPeers),
+	}
+	return g
+}
+
+// Start listens for SIP on Config.Listen and serves requests in the background
+func (g *Gateway) Start() error {
+	addr, err := net.ResolveUDPAddr("udp", g.Config.Listen)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+	g.conn = conn
+	if addr.IP != nil && !addr.IP.IsUnspecified() {
+		g.bindIP = addr.IP
+	}
+
+	log.Printf("[INFO] SIP gateway listening on udp %s (RTP ports %d-%d)",
+		conn.LocalAddr(), g.Config.RTPPortMin, g.Config.RTPPortMax)
+	g.wg.Add(1)
+	go g.serve()
+	return nil
+}
+
+// Addr returns the address the gateway listens on
+func (g *Gateway) Addr() net.Addr {
+	return g.conn.LocalAddr()
+}
+
+// ActiveCalls returns the number of answered calls
+func (g *Gateway) ActiveCalls() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.calls)
+}
+
+// Close hangs up all calls, waits for their transcriptions and stops listening
+func (g *Gateway) Close() {
+	g.mu.Lock()
+	calls := make([]*call, 0, len(g.calls))
+	for _, c := range g.calls {
+		calls = append(calls, c)
+	}
+	g.mu.Unlock()
+
+	for _, c := range calls {
+		g.endCall(c, true)
+	}
+	g.conn.Close()
+	g.wg.Wait()
+}
+
+func (g *Gateway) serve() {
+	defer g.wg.Done()
+
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("[WARN] SIP read error: %v", err)
+			continue
+		}
+
+		msg, err := parseMessage(append([]byte(nil), buf[:n]...))
+		if err != nil {
+			log.Printf("[WARN] Ignoring malformed SIP message from %s: %v", addr, err)
+			continue
+		}
+		if msg.status > 0 {
+			// Responses to our BYEs need no handling
+			continue
+		}
+		g.handleRequest(msg, addr)
+	}
+}
+
+func (g *Gateway) handleRequest(req *message, addr *net.UDPAddr) {
+	if !g.peers.Allows(addr.IP) {
+		log.Printf("[WARN] SIP %s from disallowed peer %s", req.method, addr)
+		if req.method != "ACK" {
+			g.respond(req, addr, 403, "Forbidden")
+		}
+		return
+	}
+
+	callID := req.get("Call-ID")
+	g.mu.Lock()
+	c := g.calls[callID]
+	g.mu.Unlock()
+
+	switch req.method {
+	case "INVITE":
+		g.handleInvite(req, addr, c)
+
+	case "ACK":
+		if c != nil {
+			c.ackOnce.Do(func() { close(c.acked) })
+		}
+
+	case "BYE":
+		if c == nil {
+			g.respond(req, addr, 481, "Call/Transaction Does Not Exist")
+			return
+		}
+		g.respond(req, addr, 200, "OK")
+		g.endCall(c, false)
+
+	case "CANCEL":
+		// INVITEs are answered at once, so the caller follows up with BYE
+		if c == nil {
+			g.respond(req, addr, 481, "Call/Transaction Does Not Exist")
+			return
+		}
+		g.respond(req, addr, 200, "OK")
+
+	case "OPTIONS":
+		resp := req.response(200, "OK")
+		resp.add("Allow", allowedMethods)
+		resp.add("Accept", "application/sdp")
+		g.send(resp, addr)
+
+	default:
+		resp := req.response(405, "Method Not Allowed")
+		resp.add("Allow", allowedMethods)
+		g.send(resp, addr)
+	}
+}
+
+func (g *Gateway) handleInvite(req *message, addr *net.UDPAddr, existing *call) {
+	callID := req.get("Call-ID")
+	if callID == "" {
+		g.respond(req, addr, 400, "Missing Call-ID")
+		return
+	}
+	seq, _ := req.cseq()
+
+	if existing != nil {
+		if seq == existing.inviteCSeq {
+			// Retransmitted INVITE
+			g.conn.WriteToUDP(existing.response, addr)
+			return
+		}
+		// Re-INVITE, e.g. a session refresh: keep the negotiated media
+		resp := g.answerResponse(req, existing.tag, existing.answer, addr)
+		g.send(resp, addr)
+		return
+	}
+
+	body, err := sdpBody(req)
+	if err != nil {
+		log.Printf("[WARN] SIP INVITE %s rejected: %v", callID, err)
+		g.respond(req, addr, 488, "Not Acceptable Here")
+		return
+	}
+	offer, err := parseSDP(body)
+	if err != nil {
+		log.Printf("[WARN] SIP INVITE %s rejected: invalid SDP: %v", callID, err)
+		g.respond(req, addr, 488, "Not Acceptable Here")
+		return
+	}
+	g.respond(req, addr, 100, "Trying")
+
+	c := &call{
+		id:         callID,
+		peer:       addr,
+		invite:     req,
+		inviteCSeq: seq,
+		tag:        newToken(),
+		acked:      make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	// Accept every audio stream offering G.711, reject the rest
+	answers := make([]sdpAnswer, len(offer.media))
+	for i, media := range offer.media {
+		answers[i].offer = media
+		payloadType, encoding, ok := media.codec()
+		if !ok {
+			continue
+		}
+		conn, port, err := g.listenRTP()
+		if err != nil {
+			log.Printf("[WARN] SIP INVITE %s rejected: %v", callID, err)
+			g.closeStreams(c)
+			g.respond(req, addr, 503, "Service Unavailable")
+			return
+		}
+		answers[i].port, answers[i].payloadType, answers[i].encoding = port, payloadType, encoding
+		c.streams = append(c.streams, &stream{
+			index:       len(c.streams),
+			conn:        conn,
+			port:        port,
+			payloadType: payloadType,
+			encoding:    encoding,
+		})
+	}
+	if len(c.streams) == 0 {
+		log.Printf("[WARN] SIP INVITE %s rejected: no G.711 audio offered", callID)
+		g.respond(req, addr, 488, "Not Acceptable Here")
+		return
+	}
+
+	c.answer = answerSDP(g.localIP(addr), time.Now().Unix(), answers)
+	c.response = g.answerResponse(req, c.tag, c.answer, addr).bytes()
+
+	g.mu.Lock()
+	g.calls[callID] = c
+	g.mu.Unlock()
+	callsActive.Inc()
+
+	log.Printf("[INFO] SIP call %s from %s answered with %d audio stream(s)", callID, req.get("From"), len(c.streams))
+	g.conn.WriteToUDP(c.response, addr)
+
+	g.wg.Add(1)
+	go g.retransmit(c)
+	for _, s := range c.streams {
+		g.startStream(c, s)
+	}
+}
+
+// answerResponse builds a 200 OK carrying the SDP answer
+func (g *Gateway) answerResponse(req *message, tag string, answer []byte, addr *net.UDPAddr) *message {
+	resp := req.response(200, "OK")
+	resp.setTag(tag)
+	resp.add("Contact", fmt.Sprintf("<sip:gribe@%s>", g.localHostPort(addr)))
+	resp.add("Allow", allowedMethods)
+	resp.add("Content-Type", "application/sdp")
+	resp.body = answer
+	return resp
+}
+
+// retransmit resends the 200 OK until the caller ACKs it, hanging up if it never does
+func (g *Gateway) retransmit(c *call) {
+	defer g.wg.Done()
+
+	interval := timerT1
+	deadline := time.After(timerH)
+	for {
+		select {
+		case <-c.acked:
+			return
+		case <-c.done:
+			return
+		case <-deadline:
+			log.Printf("[WARN] SIP call %s was never acknowledged, hanging up", c.id)
+			g.endCall(c, true)
+			return
+		case <-time.After(interval):
+			g.conn.WriteToUDP(c.response, c.peer)
+			interval = min(interval*2, timerT2)
+		}
+	}
+}
+
+// startStream opens a transcription session for s and starts receiving its RTP
+func (g *Gateway) startStream(c *call, s *stream) {
+	s.session = gateway.StartSession(g.UseCase, gateway.SessionOptions{
+		Model:    g.Config.Model,
+		Language: g.Config.Language,
+		APIKey:   g.Config.APIKey,
+		Label:    fmt.Sprintf("SIP call %s stream %d", c.id, s.index),
+		ClientIP: c.peer.IP.String(),
+		Metadata: map[string]string{"call_id": c.id, "stream": strconv.Itoa(s.index)},
+		OnTranscript: func(transcript string) {
+			if g.OnTranscript != nil {
+				g.OnTranscript(c.id, s.index, transcript)
+			}
+		},
+	})
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer panics.Recover("RTP of SIP call "+c.id, nil)
+		g.receiveRTP(c, s)
+	}()
+}
+
+// receiveRTP decodes the stream's RTP audio into its session until the call
+// ends or no media arrives for Config.MediaTimeout
+func (g *Gateway) receiveRTP(c *call, s *stream) {
+	decode := g711.UlawToPCM16
+	if s.encoding == domain.EncodingG711Alaw {
+		decode = g711.AlawToPCM16
+	}
+	buf := make([]byte, 2048)
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(g.Config.MediaTimeout))
+		n, _, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("[WARN] SIP call %s: no RTP for %v, hanging up", c.id, g.Config.MediaTimeout)
+				g.endCall(c, true)
+			}
+			break
+		}
+
+		packet, err := parseRTP(buf[:n])
+		if err != nil || packet.payloadType != s.payloadType {
+			// Malformed, or another payload such as DTMF events
+			continue
+		}
+		s.session.Write8k(decode(packet.payload))
+	}
+	s.session.End()
+}
+
+// endCall tears down a call once, optionally sending BYE to the caller
+func (g *Gateway) endCall(c *call, sendBye bool) {
+	c.endOnce.Do(func() {
+		g.mu.Lock()
+		delete(g.calls, c.id)
+		g.mu.Unlock()
+		callsActive.Dec()
+
+		close(c.done)
+		if sendBye {
+			g.sendBye(c)
+		}
+		g.closeStreams(c)
+		log.Printf("[INFO] SIP call %s ended", c.id)
+	})
+}
+
+// closeStreams releases the RTP sockets of a call, which ends their sessions
+func (g *Gateway) closeStreams(c *call) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, s := range c.streams {
+		s.conn.Close()
+		delete(g.ports, s.port)
+	}
+}
+
+// sendBye hangs up a call from our side of the dialog
+func (g *Gateway) sendBye(c *call) {
+	target := headerURI(c.invite.get("Contact"))
+	if target == "" {
+		target = headerURI(c.invite.get("From"))
+	}
+
+	from := c.invite.get("To")
+	if headerParam(from, "tag") == "" {
+		from += ";tag=" + c.tag
+	}
+
+	bye := &message{method: "BYE", uri: target}
+	bye.add("Via", fmt.Sprintf("SIP/2.0/UDP %s;branch=z9hG4bK%s", g.localHostPort(c.peer), newToken()))
+	bye.add("Max-Forwards", "70")
+	bye.add("From", from)
+	bye.add("To", c.invite.get("From"))
+	bye.add("Call-ID", c.id)
+	bye.add("CSeq", "1 BYE")
+	g.send(bye, c.peer)
+}
+
+// listenRTP opens a UDP socket on a free even port of the configured range
+func (g *Gateway) listenRTP() (*net.UDPConn, int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	first := g.Config.RTPPortMin + g.Config.RTPPortMin%2
+	count := (g.Config.RTPPortMax-first)/2 + 1
+	for i := 0; i < count; i++ {
+		port := first + 2*((g.nextPort+i)%count)
+		if g.ports[port] {
+			continue
+		}
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: g.bindIP, Port: port})
+		if err != nil {
+			continue
+		}
+		g.ports[port] = true
+		g.nextPort = (g.nextPort + i + 1) % count
+		return conn, port, nil
+	}
+	return nil, 0, errors.New("no free RTP port")
+}
+
+// localIP returns the address advertised to the peer in SDP and headers
+func (g *Gateway) localIP(peer *net.UDPAddr) string {
+	if g.Config.PublicAddress != "" {
+		return g.Config.PublicAddress
+	}
+	if g.bindIP != nil {
+		return g.bindIP.String()
+	}
+	// The source address of the route to the peer; no packets are sent
+	if conn, err := net.DialUDP("udp", nil, peer); err == nil {
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).IP.String()
+	}
+	return "127.0.0.1"
+}
+
+func (g *Gateway) localHostPort(peer *net.UDPAddr) string {
+	port := g.conn.LocalAddr().(*net.UDPAddr).Port
+	return net.JoinHostPort(g.localIP(peer), strconv.Itoa(port))
+}
+
+func (g *Gateway) respond(req *message, addr *net.UDPAddr, status int, reason string) {
+	g.send(req.response(status, reason), addr)
+}
+
+func (g *Gateway) send(msg *message, addr *net.UDPAddr) {
+	if _, err := g.conn.WriteToUDP(msg.bytes(), addr); err != nil {
+		log.Printf("[WARN] SIP write to %s failed: %v", addr, err)
+	}
+}
+
+// newToken returns a random tag or branch suffix
+func newToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
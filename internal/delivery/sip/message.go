@@ -0,0 +1,206 @@
+package sip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// compactForms maps RFC 3261 compact header names to their full names
+var compactForms = map[string]string{
+	"v": "Via",
+	"f": "From",
+	"t": "To",
+	"i": "Call-ID",
+	"m": "Contact",
+	"l": "Content-Length",
+	"c": "Content-Type",
+	"k": "Supported",
+}
+
+// message is a SIP request or response. Header values are kept verbatim so
+// they can be echoed back in responses.
+type message struct {
+	method  string // Request method, empty for responses
+	uri     string // Request-URI
+	status  int    // Response status code, 0 for requests
+	reason  string
+	headers []headerField
+	body    []byte
+}
+
+type headerField struct {
+	name  string
+	value string
+}
+
+// parseMessage parses a SIP message received as one UDP datagram
+func parseMessage(data []byte) (*message, error) {
+	head, body := data, []byte(nil)
+	if i := bytes.Index(data, []byte("\r\n\r\n")); i >= 0 {
+		head, body = data[:i], data[i+4:]
+	} else if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+		head, body = data[:i], data[i+2:]
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(head), "\r\n", "\n"), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, errors.New("empty message")
+	}
+
+	msg := &message{}
+	start := strings.SplitN(lines[0], " ", 3)
+	if len(start) != 3 {
+		return nil, fmt.Errorf("malformed start line %q", lines[0])
+	}
+	if strings.HasPrefix(start[0], "SIP/") {
+		status, err := strconv.Atoi(start[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed status line %q", lines[0])
+		}
+		msg.status, msg.reason = status, start[2]
+	} else {
+		if !strings.HasPrefix(start[2], "SIP/") {
+			return nil, fmt.Errorf("malformed request line %q", lines[0])
+		}
+		msg.method, msg.uri = start[0], start[1]
+	}
+
+	for _, line := range lines[1:] {
+		// Continuation lines fold into the previous header
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(msg.headers) > 0 {
+			msg.headers[len(msg.headers)-1].value += " " + strings.TrimSpace(line)
+			continue
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[:colon])
+		if full, ok := compactForms[strings.ToLower(name)]; ok {
+			name = full
+		}
+		msg.headers = append(msg.headers, headerField{name: name, value: strings.TrimSpace(line[colon+1:])})
+	}
+
+	if length := msg.get("Content-Length"); length != "" {
+		n, err := strconv.Atoi(length)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid Content-Length %q", length)
+		}
+		if n < len(body) {
+			body = body[:n]
+		}
+	}
+	msg.body = body
+	return msg, nil
+}
+
+// get returns the first value of the named header
+func (m *message) get(name string) string {
+	for _, h := range m.headers {
+		if strings.EqualFold(h.name, name) {
+			return h.value
+		}
+	}
+	return ""
+}
+
+// getAll returns every value of the named header in order
+func (m *message) getAll(name string) []string {
+	var values []string
+	for _, h := range m.headers {
+		if strings.EqualFold(h.name, name) {
+			values = append(values, h.value)
+		}
+	}
+	return values
+}
+
+// add appends a header
+func (m *message) add(name, value string) {
+	m.headers = append(m.headers, headerField{name: name, value: value})
+}
+
+// response creates a response to the request m, copying the headers that
+// identify the transaction and dialog
+func (m *message) response(status int, reason string) *message {
+	resp := &message{status: status, reason: reason}
+	for _, h := range m.headers {
+		switch strings.ToLower(h.name) {
+		case "via", "from", "to", "call-id", "cseq":
+			resp.add(h.name, h.value)
+		}
+	}
+	return resp
+}
+
+// setTag adds a tag parameter to the To header if it has none
+func (m *message) setTag(tag string) {
+	for i, h := range m.headers {
+		if strings.EqualFold(h.name, "To") {
+			if headerParam(h.value, "tag") == "" {
+				m.headers[i].value += ";tag=" + tag
+			}
+			return
+		}
+	}
+}
+
+// cseq returns the sequence number and method of the CSeq header
+func (m *message) cseq() (int, string) {
+	fields := strings.Fields(m.get("CSeq"))
+	if len(fields) != 2 {
+		return 0, ""
+	}
+	n, _ := strconv.Atoi(fields[0])
+	return n, fields[1]
+}
+
+// bytes serializes the message, computing Content-Length from the body
+func (m *message) bytes() []byte {
+	var b bytes.Buffer
+	if m.status > 0 {
+		fmt.Fprintf(&b, "SIP/2.0 %d %s\r\n", m.status, m.reason)
+	} else {
+		fmt.Fprintf(&b, "%s %s SIP/2.0\r\n", m.method, m.uri)
+	}
+	for _, h := range m.headers {
+		if strings.EqualFold(h.name, "Content-Length") {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\r\n", h.name, h.value)
+	}
+	fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n", len(m.body))
+	b.Write(m.body)
+	return b.Bytes()
+}
+
+// headerParam returns a ;name=value parameter of a header value, e.g. the tag
+// of a From header
+func headerParam(value, name string) string {
+	// Parameters inside <...> belong to the URI, not the header
+	if end := strings.LastIndexByte(value, '>'); end >= 0 {
+		value = value[end+1:]
+	}
+	for _, param := range strings.Split(value, ";")[1:] {
+		key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(key, name) {
+			return val
+		}
+	}
+	return ""
+}
+
+// headerURI returns the URI of a From, To or Contact header value
+func headerURI(value string) string {
+	if start := strings.IndexByte(value, '<'); start >= 0 {
+		if end := strings.IndexByte(value[start:], '>'); end > 0 {
+			return value[start+1 : start+end]
+		}
+	}
+	uri, _, _ := strings.Cut(value, ";")
+	return strings.TrimSpace(uri)
+}
@@ -0,0 +1,46 @@
+package sip
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// rtpPacket is the part of an RTP packet (RFC 3550) the gateway uses
+type rtpPacket struct {
+	payloadType int
+	sequence    uint16
+	payload     []byte
+}
+
+// parseRTP parses an RTP packet, skipping CSRCs, the header extension and padding
+func parseRTP(data []byte) (*rtpPacket, error) {
+	if len(data) < 12 {
+		return nil, errors.New("packet too short")
+	}
+	if data[0]>>6 != 2 {
+		return nil, errors.New("not RTP version 2")
+	}
+
+	padding := data[0]&0x20 != 0
+	extension := data[0]&0x10 != 0
+	offset := 12 + 4*int(data[0]&0x0F)
+	if extension {
+		if len(data) < offset+4 {
+			return nil, errors.New("truncated header extension")
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:]))
+	}
+	end := len(data)
+	if padding && end > 0 {
+		end -= int(data[end-1])
+	}
+	if offset > end {
+		return nil, errors.New("truncated packet")
+	}
+
+	return &rtpPacket{
+		payloadType: int(data[1] & 0x7F),
+		sequence:    binary.BigEndian.Uint16(data[2:]),
+		payload:     data[offset:end],
+	}, nil
+}
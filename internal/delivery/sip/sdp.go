@@ -0,0 +1,178 @@
+package sip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strconv"
+	"strings"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Static RTP payload types for G.711 (RFC 3551)
+const (
+	payloadPCMU = 0
+	payloadPCMA = 8
+)
+
+// sdpOffer is the part of an SDP offer the gateway needs
+type sdpOffer struct {
+	media []sdpMedia
+}
+
+// sdpMedia is one m= line of an offer
+type sdpMedia struct {
+	kind    string            // "audio", "video", ...
+	port    int               // 0 when the stream is disabled
+	proto   string            // "RTP/AVP", ...
+	formats []string          // Payload types in order of preference
+	rtpmap  map[string]string // Payload type -> upper-case encoding name
+	addr    string            // Connection address, media-level or inherited
+}
+
+// codec returns the first G.711 payload type offered, with its encoding
+func (m *sdpMedia) codec() (int, string, bool) {
+	if m.kind != "audio" || m.port == 0 {
+		return 0, "", false
+	}
+	for _, format := range m.formats {
+		pt, err := strconv.Atoi(format)
+		if err != nil {
+			continue
+		}
+		name := m.rtpmap[format]
+		switch {
+		case name == "PCMU" || (name == "" && pt == payloadPCMU):
+			return pt, domain.EncodingG711Ulaw, true
+		case name == "PCMA" || (name == "" && pt == payloadPCMA):
+			return pt, domain.EncodingG711Alaw, true
+		}
+	}
+	return 0, "", false
+}
+
+// parseSDP parses the session and media descriptions of an SDP body
+func parseSDP(body []byte) (*sdpOffer, error) {
+	offer := &sdpOffer{}
+	var sessionAddr string
+	var current *sdpMedia
+
+	for _, line := range strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n") {
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+		value := line[2:]
+		switch line[0] {
+		case 'c':
+			// c=IN IP4 192.0.2.1
+			fields := strings.Fields(value)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("malformed connection line %q", line)
+			}
+			addr, _, _ := strings.Cut(fields[2], "/")
+			if current != nil {
+				current.addr = addr
+			} else {
+				sessionAddr = addr
+			}
+		case 'm':
+			// m=audio 49170 RTP/AVP 0 8 101
+			fields := strings.Fields(value)
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("malformed media line %q", line)
+			}
+			portField, _, _ := strings.Cut(fields[1], "/")
+			port, err := strconv.Atoi(portField)
+			if err != nil {
+				return nil, fmt.Errorf("malformed media port %q", fields[1])
+			}
+			offer.media = append(offer.media, sdpMedia{
+				kind:    fields[0],
+				port:    port,
+				proto:   fields[2],
+				formats: fields[3:],
+				rtpmap:  make(map[string]string),
+				addr:    sessionAddr,
+			})
+			current = &offer.media[len(offer.media)-1]
+		case 'a':
+			// a=rtpmap:0 PCMU/8000
+			if current == nil || !strings.HasPrefix(value, "rtpmap:") {
+				continue
+			}
+			pt, encoding, ok := strings.Cut(strings.TrimPrefix(value, "rtpmap:"), " ")
+			if !ok {
+				continue
+			}
+			name, _, _ := strings.Cut(encoding, "/")
+			current.rtpmap[pt] = strings.ToUpper(name)
+		}
+	}
+
+	if len(offer.media) == 0 {
+		return nil, errors.New("no media descriptions")
+	}
+	return offer, nil
+}
+
+// sdpBody returns the SDP of an INVITE. SIPREC INVITEs carry it in a
+// multipart/mixed body next to the recording metadata.
+func sdpBody(msg *message) ([]byte, error) {
+	mediaType, params, err := mime.ParseMediaType(msg.get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Type: %v", err)
+	}
+	switch mediaType {
+	case "application/sdp":
+		return msg.body, nil
+	case "multipart/mixed":
+		reader := multipart.NewReader(bytes.NewReader(msg.body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				return nil, errors.New("no application/sdp part in multipart body")
+			}
+			if partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); partType == "application/sdp" {
+				return io.ReadAll(part)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported body type %q", mediaType)
+	}
+}
+
+// sdpAnswer is the gateway's answer to one offered media line
+type sdpAnswer struct {
+	offer       sdpMedia
+	port        int // 0 rejects the stream
+	payloadType int
+	encoding    string
+}
+
+// answerSDP builds a receive-only SDP answer with one m= line per offered line
+func answerSDP(ip string, sessionID int64, answers []sdpAnswer) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "v=0\r\n")
+	fmt.Fprintf(&b, "o=gribe %d %d IN IP4 %s\r\n", sessionID, sessionID, ip)
+	fmt.Fprintf(&b, "s=gribe\r\n")
+	fmt.Fprintf(&b, "c=IN IP4 %s\r\n", ip)
+	fmt.Fprintf(&b, "t=0 0\r\n")
+	for _, answer := range answers {
+		if answer.port == 0 {
+			fmt.Fprintf(&b, "m=%s 0 %s %s\r\n", answer.offer.kind, answer.offer.proto, answer.offer.formats[0])
+			continue
+		}
+		name := "PCMU"
+		if answer.encoding == domain.EncodingG711Alaw {
+			name = "PCMA"
+		}
+		fmt.Fprintf(&b, "m=audio %d RTP/AVP %d\r\n", answer.port, answer.payloadType)
+		fmt.Fprintf(&b, "a=rtpmap:%d %s/8000\r\n", answer.payloadType, name)
+		fmt.Fprintf(&b, "a=recvonly\r\n")
+	}
+	return b.Bytes()
+}
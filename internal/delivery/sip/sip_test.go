@@ -0,0 +1,299 @@
+package sip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+func TestParseMessage(t *testing.T) {
+	raw := "INVITE sip:gribe@192.0.2.10 SIP/2.0\r\n" +
+		"v: SIP/2.0/UDP 192.0.2.1:5060;branch=z9hG4bK1\r\n" +
+		"Via: SIP/2.0/UDP 192.0.2.2:5060;branch=z9hG4bK2\r\n" +
+		"f: \"PBX\" <sip:pbx@192.0.2.1>;tag=abc\r\n" +
+		"t: <sip:gribe@192.0.2.10>\r\n" +
+		"i: call-1\r\n" +
+		"CSeq: 7 INVITE\r\n" +
+		"Subject: folded\r\n" +
+		" header\r\n" +
+		"l: 4\r\n" +
+		"\r\n" +
+		"bodyextra"
+
+	msg, err := parseMessage([]byte(raw))
+	if err != nil {
+		t.Fatalf("parseMessage failed: %v", err)
+	}
+	if msg.method != "INVITE" || msg.uri != "sip:gribe@192.0.2.10" {
+		t.Errorf("Unexpected request line %q %q", msg.method, msg.uri)
+	}
+	if msg.get("Call-ID") != "call-1" || len(msg.getAll("via")) != 2 {
+		t.Errorf("Expected compact headers to be expanded, got %+v", msg.headers)
+	}
+	if msg.get("Subject") != "folded header" {
+		t.Errorf("Expected folded header, got %q", msg.get("Subject"))
+	}
+	if string(msg.body) != "body" {
+		t.Errorf("Expected body cut at Content-Length, got %q", msg.body)
+	}
+	if seq, method := msg.cseq(); seq != 7 || method != "INVITE" {
+		t.Errorf("Unexpected CSeq %d %s", seq, method)
+	}
+	if headerParam(msg.get("From"), "tag") != "abc" || headerURI(msg.get("From")) != "sip:pbx@192.0.2.1" {
+		t.Errorf("Unexpected From parsing of %q", msg.get("From"))
+	}
+
+	resp := msg.response(200, "OK")
+	resp.setTag("xyz")
+	out, err := parseMessage(resp.bytes())
+	if err != nil {
+		t.Fatalf("Parsing response failed: %v", err)
+	}
+	if out.status != 200 || len(out.getAll("Via")) != 2 || out.get("Subject") != "" {
+		t.Errorf("Expected transaction headers only, got %+v", out.headers)
+	}
+	if headerParam(out.get("To"), "tag") != "xyz" || out.get("Content-Length") != "0" {
+		t.Errorf("Unexpected response headers %+v", out.headers)
+	}
+}
+
+func TestParseSIPRECOffer(t *testing.T) {
+	sdp := "v=0\r\no=- 1 1 IN IP4 192.0.2.1\r\ns=-\r\nc=IN IP4 192.0.2.1\r\nt=0 0\r\n" +
+		"m=audio 4000 RTP/AVP 8 101\r\na=rtpmap:101 telephone-event/8000\r\n" +
+		"m=audio 4002 RTP/AVP 96 0\r\nc=IN IP4 192.0.2.2\r\na=rtpmap:96 opus/48000/2\r\n" +
+		"m=video 4004 RTP/AVP 97\r\n"
+	body := "--XYZ\r\nContent-Type: application/sdp\r\n\r\n" + sdp +
+		"--XYZ\r\nContent-Type: application/rs-metadata+xml\r\n\r\n<recording/>\r\n--XYZ--\r\n"
+	msg := &message{method: "INVITE", body: []byte(body)}
+	msg.add("Content-Type", `multipart/mixed;boundary=XYZ`)
+
+	extracted, err := sdpBody(msg)
+	if err != nil {
+		t.Fatalf("sdpBody failed: %v", err)
+	}
+	offer, err := parseSDP(extracted)
+	if err != nil {
+		t.Fatalf("parseSDP failed: %v", err)
+	}
+	if len(offer.media) != 3 {
+		t.Fatalf("Expected 3 media lines, got %d", len(offer.media))
+	}
+
+	tests := []struct {
+		pt       int
+		encoding string
+		ok       bool
+		addr     string
+	}{
+		{8, domain.EncodingG711Alaw, true, "192.0.2.1"},
+		{0, domain.EncodingG711Ulaw, true, "192.0.2.2"},
+		{0, "", false, "192.0.2.1"},
+	}
+	for i, tt := range tests {
+		pt, encoding, ok := offer.media[i].codec()
+		if pt != tt.pt || encoding != tt.encoding || ok != tt.ok || offer.media[i].addr != tt.addr {
+			t.Errorf("media %d: got %d %q %v %s", i, pt, encoding, ok, offer.media[i].addr)
+		}
+	}
+
+	answer := string(answerSDP("192.0.2.10", 1, []sdpAnswer{
+		{offer: offer.media[0], port: 10000, payloadType: 8, encoding: domain.EncodingG711Alaw},
+		{offer: offer.media[1], port: 10002, payloadType: 0, encoding: domain.EncodingG711Ulaw},
+		{offer: offer.media[2]},
+	}))
+	for _, line := range []string{"m=audio 10000 RTP/AVP 8", "a=rtpmap:8 PCMA/8000", "m=audio 10002 RTP/AVP 0", "m=video 0 RTP/AVP 97"} {
+		if !strings.Contains(answer, line+"\r\n") {
+			t.Errorf("Expected answer to contain %q, got:\n%s", line, answer)
+		}
+	}
+}
+
+func TestParseRTP(t *testing.T) {
+	packet := []byte{
+		0xB1, 0x00, 0x00, 0x2A, // V=2, padding, extension, 1 CSRC; PT 0; seq 42
+		0, 0, 0, 0, 0, 0, 0, 0, // timestamp, SSRC
+		0, 0, 0, 1, // CSRC
+		0xBE, 0xDE, 0x00, 0x01, 0, 0, 0, 0, // one-word header extension
+		0x11, 0x22, 0x33, // payload
+		0x00, 0x02, // padding
+	}
+	rtp, err := parseRTP(packet)
+	if err != nil {
+		t.Fatalf("parseRTP failed: %v", err)
+	}
+	if rtp.payloadType != 0 || rtp.sequence != 42 || !bytes.Equal(rtp.payload, []byte{0x11, 0x22, 0x33}) {
+		t.Errorf("Unexpected packet %+v", rtp)
+	}
+	if _, err := parseRTP(packet[:8]); err == nil {
+		t.Error("Expected short packet to be rejected")
+	}
+}
+
+func TestGatewayCall(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	g := NewGateway(srv.UseCase, &config.SIPConfig{
+		Listen:       "127.0.0.1:0",
+		RTPPortMin:   41000,
+		RTPPortMax:   41100,
+		MediaTimeout: 5 * time.Second,
+		Model:        realtimetest.MockModel,
+		Language:     "en",
+	})
+	transcripts := make(chan string, 1)
+	g.OnTranscript = func(callID string, stream int, transcript string) {
+		transcripts <- fmt.Sprintf("%s/%d: %s", callID, stream, transcript)
+	}
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer g.Close()
+
+	pbx, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pbx.Close()
+	gatewayAddr := g.Addr().(*net.UDPAddr)
+
+	sdp := "v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=-\r\nc=IN IP4 127.0.0.1\r\nt=0 0\r\n" +
+		fmt.Sprintf("m=audio %d RTP/AVP 0\r\n", pbx.LocalAddr().(*net.UDPAddr).Port)
+	invite := &message{method: "INVITE", uri: "sip:gribe@" + gatewayAddr.String(), body: []byte(sdp)}
+	invite.add("Via", "SIP/2.0/UDP "+pbx.LocalAddr().String()+";branch=z9hG4bK1")
+	invite.add("From", "<sip:pbx@127.0.0.1>;tag=pbx")
+	invite.add("To", "<sip:gribe@127.0.0.1>")
+	invite.add("Call-ID", "call-1")
+	invite.add("CSeq", "1 INVITE")
+	invite.add("Contact", "<sip:pbx@"+pbx.LocalAddr().String()+">")
+	invite.add("Content-Type", "application/sdp")
+	pbx.WriteToUDP(invite.bytes(), gatewayAddr)
+
+	read := func() *message {
+		t.Helper()
+		buf := make([]byte, 65535)
+		pbx.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pbx.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("Reading from gateway failed: %v", err)
+		}
+		msg, err := parseMessage(buf[:n])
+		if err != nil {
+			t.Fatalf("Gateway sent a malformed message: %v", err)
+		}
+		return msg
+	}
+
+	var ok *message
+	for ok == nil {
+		if msg := read(); msg.status == 200 {
+			ok = msg
+		} else if msg.status != 100 {
+			t.Fatalf("Unexpected response %d %s", msg.status, msg.reason)
+		}
+	}
+	answer, err := parseSDP(ok.body)
+	if err != nil {
+		t.Fatalf("Invalid SDP answer: %v", err)
+	}
+	if g.ActiveCalls() != 1 {
+		t.Errorf("Expected 1 active call, got %d", g.ActiveCalls())
+	}
+
+	ack := &message{method: "ACK", uri: invite.uri}
+	ack.add("Call-ID", "call-1")
+	ack.add("CSeq", "1 ACK")
+	pbx.WriteToUDP(ack.bytes(), gatewayAddr)
+
+	// 100ms of silence, then 400ms of speech, in 20ms PCMU packets
+	rtpAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: answer.media[0].port}
+	for i := 0; i < 25; i++ {
+		payload := bytes.Repeat([]byte{0xFF}, 160)
+		if i >= 5 {
+			payload = bytes.Repeat([]byte{0x80, 0x00}, 80)
+		}
+		packet := make([]byte, 12, 12+len(payload))
+		packet[0] = 0x80
+		binary.BigEndian.PutUint16(packet[2:], uint16(i))
+		pbx.WriteToUDP(append(packet, payload...), rtpAddr)
+	}
+
+	// Hanging up mid-utterance still transcribes it
+	bye := &message{method: "BYE", uri: invite.uri}
+	bye.add("Via", "SIP/2.0/UDP "+pbx.LocalAddr().String()+";branch=z9hG4bK2")
+	bye.add("From", "<sip:pbx@127.0.0.1>;tag=pbx")
+	bye.add("To", ok.get("To"))
+	bye.add("Call-ID", "call-1")
+	bye.add("CSeq", "2 BYE")
+	pbx.WriteToUDP(bye.bytes(), gatewayAddr)
+	if resp := read(); resp.status != 200 || resp.get("CSeq") != "2 BYE" {
+		t.Fatalf("Expected 200 OK to BYE, got %d %s", resp.status, resp.get("CSeq"))
+	}
+
+	select {
+	case transcript := <-transcripts:
+		if transcript != "call-1/0: hello" {
+			t.Errorf("Unexpected transcript %q", transcript)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the transcript")
+	}
+	if g.ActiveCalls() != 0 {
+		t.Errorf("Expected the call to end, %d active", g.ActiveCalls())
+	}
+
+	// Unknown dialogs are rejected
+	pbx.WriteToUDP(bye.bytes(), gatewayAddr)
+	if resp := read(); resp.status != 481 {
+		t.Errorf("Expected 481 for an ended call, got %d", resp.status)
+	}
+}
+
+func TestGatewayRejectsPeers(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+
+	g := NewGateway(srv.UseCase, &config.SIPConfig{
+		Listen:       "127.0.0.1:0",
+		RTPPortMin:   41200,
+		RTPPortMax:   41210,
+		MediaTimeout: time.Second,
+		AllowedPeers: []string{"192.0.2.0/24"},
+	})
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer g.Close()
+
+	pbx, err := net.DialUDP("udp", nil, g.Addr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pbx.Close()
+
+	options := &message{method: "OPTIONS", uri: "sip:gribe@127.0.0.1"}
+	options.add("Call-ID", "probe")
+	options.add("CSeq", "1 OPTIONS")
+	pbx.Write(options.bytes())
+
+	buf := make([]byte, 2048)
+	pbx.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := pbx.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(buf[:n]), "SIP/2.0 "+strconv.Itoa(403)) {
+		t.Errorf("Expected 403 for a disallowed peer, got %q", buf[:n])
+	}
+}
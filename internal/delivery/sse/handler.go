@@ -0,0 +1,127 @@
+// Package sse serves GET /v1/sessions/{id}/events, a read-only Server-Sent
+// Events stream of a live session's server events for consumers other than
+// the session's own WebSocket, such as dashboards or supervisor views.
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// keepAliveInterval is how often an idle stream sends a comment so proxies
+// do not close it
+const keepAliveInterval = 15 * time.Second
+
+// Handler streams session events
+type Handler struct {
+	UseCase *usecase.SessionUsecase
+	Auth    *middleware.Authenticator
+}
+
+// NewHandler creates the handler; mount it at /v1/sessions/
+func NewHandler(uc *usecase.SessionUsecase, auth *middleware.Authenticator) *Handler {
+	return &Handler{UseCase: uc, Auth: auth}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/sessions/"), "/")
+	if sessionID == "" || rest != "events" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientIP := middleware.GetClientIP(r)
+	principal, err := h.Auth.Authenticate(r)
+	if err != nil {
+		log.Printf("Invalid credentials for session events from IP %s: %v", clientIP, err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	admin := principal.HasScope(config.ScopeAdminRead)
+	if !admin && !principal.HasScope(config.ScopeRealtimeTranscribe) {
+		log.Printf("Session events request without %s scope from IP: %s", config.ScopeRealtimeTranscribe, clientIP)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub, err := h.UseCase.Subscribe(sessionID, usecase.SubscribeOptions{
+		APIKey:     principal.ID,
+		Tenant:     h.UseCase.ResolveTenant(principal.APIKey, principal.TenantClaim),
+		AnySession: admin,
+	})
+	// Sessions of other credentials are reported as missing so IDs cannot be probed
+	if errors.Is(err, usecase.ErrSessionNotFound) || errors.Is(err, usecase.ErrSessionForbidden) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer sub.Close()
+
+	log.Printf("Streaming events of session %s to IP %s", sessionID, clientIP)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case data, ok := <-sub.Events:
+			if !ok {
+				// Session ended
+				return
+			}
+			if err := writeEvent(w, data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes a server event as an SSE message named after its type
+func writeEvent(w http.ResponseWriter, data []byte) error {
+	var base domain.BaseEvent
+	if err := json.Unmarshal(data, &base); err != nil {
+		return err
+	}
+	if base.EventID != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", base.EventID); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", base.Type, data)
+	return err
+}
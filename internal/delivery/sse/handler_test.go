@@ -0,0 +1,131 @@
+package sse
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+// openStream requests the session's event stream with key
+func openStream(t *testing.T, server *httptest.Server, sessionID, key string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/sessions/"+sessionID+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp
+}
+
+func TestSessionEvents(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Auth.APIKeys = []string{"owner-key", "other-key"}
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	events := httptest.NewServer(NewHandler(srv.UseCase, srv.Handler.Auth))
+	defer events.Close()
+
+	client, err := srv.Dial(url.Values{"intent": {"transcription"}}, http.Header{"Authorization": {"Bearer owner-key"}})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	created, err := client.Expect(domain.EventTranscriptionSessionCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session domain.TranscriptionSessionCreatedEvent
+	if err := created.Decode(&session); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		sessionID, key string
+		status         int
+	}{
+		{"sess_missing", "owner-key", http.StatusNotFound},
+		{session.Session.ID, "other-key", http.StatusNotFound},
+		{session.Session.ID, "wrong-key", http.StatusUnauthorized},
+	} {
+		resp := openStream(t, events, tc.sessionID, tc.key)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("Expected %d for %s with %s, got %d", tc.status, tc.sessionID, tc.key, resp.StatusCode)
+		}
+	}
+
+	resp := openStream(t, events, session.Session.ID, "owner-key")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AppendAudio(make([]byte, 3200)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	// The stream mirrors the session's events until the session ends
+	var seen []string
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("Stream ended early after %v", seen)
+			}
+			if name, found := strings.CutPrefix(line, "event: "); found {
+				seen = append(seen, name)
+			}
+			if strings.HasPrefix(line, "data: ") && strings.Contains(line, `"transcript":"hello"`) {
+				done = true
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for the transcript, saw %v", seen)
+		}
+	}
+	if len(seen) == 0 || seen[0] != string(domain.EventSessionUpdated) {
+		t.Errorf("Expected the stream to start with the session update, saw %v", seen)
+	}
+
+	client.Close()
+	for {
+		select {
+		case _, ok := <-lines:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Stream did not end with the session")
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package transcription
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/pkg/fetch"
+	"github.com/aira-id/gribe/internal/pkg/wav"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// multipartMemory is how much of an upload is kept in memory before it is
+// spooled to a temporary file
+const multipartMemory = 8 << 20
+
+// formFieldSlack allows for the form fields next to an upload of the maximum size
+const formFieldSlack = 1 << 20
+
+// batchRequest holds the parameters of a batch transcription
+type batchRequest struct {
+	URL            string  `json:"url"`
+	Model          string  `json:"model"`
+	Language       string  `json:"language"`
+	Prompt         string  `json:"prompt"`      // For models that support one, see domain.PromptCapable
+	Temperature    float64 `json:"temperature"` // 0-1, for models that support it
+	ResponseFormat string  `json:"response_format"`
+	CallbackURL    string  `json:"callback_url"` // Jobs only
+	Translate      bool    `json:"-"`            // Set by the translations endpoint
+
+	// TimestampGranularities of verbose_json: segment (the default) and word
+	TimestampGranularities []string `json:"timestamp_granularities"`
+
+	// Metadata is attached to the session transcribing the audio and echoed
+	// in the job, see domain.Session.Metadata
+	Metadata map[string]string `json:"metadata"`
+}
+
+// include returns the segment details the session reports for the request
+func (r *batchRequest) include() []string {
+	if r.ResponseFormat != FormatVerboseJSON {
+		return nil
+	}
+	return []string{domain.IncludeLogprobs, domain.IncludeWords}
+}
+
+// wordTimestamps reports whether the request asks for timed words
+func (r *batchRequest) wordTimestamps() bool {
+	for _, granularity := range r.TimestampGranularities {
+		if granularity == "word" {
+			return true
+		}
+	}
+	return false
+}
+
+// batchError is a failed batch transcription and the status it is answered with
+type batchError struct {
+	status int
+	detail *domain.ErrorDetail
+}
+
+func newBatchError(status int, code, message string) *batchError {
+	return &batchError{status: status, detail: domain.NewErrorDetail("invalid_request_error", code, message, nil)}
+}
+
+// handleTranscriptions transcribes a complete audio file, like OpenAI's
+// transcriptions endpoint. The file is uploaded as the "file" field of a
+// multipart form, or fetched from a source URL given as the "url" field or in
+// a JSON body. The response is {"text": ...}, plain text, SRT subtitles or
+// verbose_json with segments (and words, with timestamp_granularities[]=word),
+// selected with response_format.
+func (h *Handler) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
+	h.handleBatch(w, r, false)
+}
+
+// handleTranslations transcribes a complete audio file translated to
+// English, like OpenAI's translations endpoint. It takes the same request as
+// handleTranscriptions; language names the spoken language rather than the
+// output, and defaults to the model's. The model must support translation.
+func (h *Handler) handleTranslations(w http.ResponseWriter, r *http.Request) {
+	h.handleBatch(w, r, true)
+}
+
+// handleBatch serves handleTranscriptions and handleTranslations
+func (h *Handler) handleBatch(w http.ResponseWriter, r *http.Request, translate bool) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only POST is supported")
+		return
+	}
+	principal := h.authenticate(w, r)
+	if principal == nil {
+		return
+	}
+
+	req, audio, berr := h.readBatchRequest(w, r)
+	if berr != nil {
+		writeBatchError(w, berr)
+		return
+	}
+	if audio != nil {
+		defer audio.Close()
+	}
+	if req.CallbackURL != "" {
+		writeError(w, http.StatusBadRequest, domain.CodeInvalidRequest, "callback_url is only supported by /v1/transcription-jobs")
+		return
+	}
+	if translate {
+		req.Translate = true
+		h.sourceLanguage(req)
+	}
+
+	transcript, berr := h.transcribeBatch(r.Context(), req, audio, FileOptions{
+		Model:       req.Model,
+		Language:    req.Language,
+		Prompt:      req.Prompt,
+		Temperature: req.Temperature,
+		Translate:   req.Translate,
+		APIKey:      principal.ID,
+		Tenant:      h.UseCase.ResolveTenant(principal.APIKey, principal.TenantClaim),
+		Label:       "Batch transcription from " + middleware.GetClientIP(r),
+		Include:     req.include(),
+		Metadata:    req.Metadata,
+	})
+	if berr != nil {
+		writeBatchError(w, berr)
+		return
+	}
+
+	switch req.ResponseFormat {
+	case FormatJSON:
+		writeJSON(w, http.StatusOK, map[string]string{"text": transcript.Text})
+	case FormatVerboseJSON:
+		verbose := transcript.Verbose(req.wordTimestamps())
+		if req.Translate {
+			verbose.Task = "translate"
+			verbose.Language = "en"
+		}
+		writeJSON(w, http.StatusOK, verbose)
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		transcript.Write(w, req.ResponseFormat)
+	}
+}
+
+// sourceLanguage fills in the spoken language of a translation, which OpenAI
+// clients leave out: the server's default model and language, or the first
+// language of the model named
+func (h *Handler) sourceLanguage(req *batchRequest) {
+	if req.Language != "" {
+		return
+	}
+	if req.Model == "" {
+		req.Model, req.Language = h.UseCase.DefaultTranscription()
+		return
+	}
+	if model, ok := h.Config.ASR.Models[req.Model]; ok && len(model.Languages) > 0 {
+		req.Language = model.Languages[0]
+	}
+}
+
+// transcribeBatch transcribes a WAV file, fetching req.URL first when audio is nil
+func (h *Handler) transcribeBatch(ctx context.Context, req *batchRequest, audio io.Reader, opts FileOptions) (*Transcript, *batchError) {
+	if audio == nil {
+		source, err := h.fetchSource(ctx, req.URL)
+		if err != nil {
+			switch {
+			case errors.Is(err, fetch.ErrTooLarge):
+				return nil, h.tooLarge()
+			case errors.Is(err, fetch.ErrNotAllowed):
+				return nil, newBatchError(http.StatusBadRequest, domain.CodeURLNotAllowed, err.Error())
+			default:
+				return nil, newBatchError(http.StatusBadRequest, domain.CodeURLFetchFailed, "Could not fetch the source URL: "+err.Error())
+			}
+		}
+		defer source.Close()
+		audio = source
+	}
+
+	format, err := wav.ReadHeader(audio)
+	if err == nil {
+		err = checkSampleRate(format.SampleRate)
+	}
+	if err != nil {
+		return nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidAudioFormat, "Send a 16-bit PCM WAV file: "+err.Error())
+	}
+
+	transcript, err := TranscribePCM(h.UseCase, audio, format, opts)
+	if err != nil {
+		var sessionErr *SessionError
+		if errors.As(err, &sessionErr) && sessionErr.Detail.Type == "invalid_request_error" {
+			return nil, &batchError{status: http.StatusBadRequest, detail: sessionErr.Detail}
+		}
+		log.Printf("[WARN] Batch transcription failed: %v", err)
+		berr := newBatchError(http.StatusInternalServerError, domain.CodeTranscriptionFailed, err.Error())
+		berr.detail.Type = "server_error"
+		return nil, berr
+	}
+	return transcript, nil
+}
+
+// readBatchRequest parses a multipart upload or a JSON body. The returned
+// audio is nil when the request names a source URL instead.
+func (h *Handler) readBatchRequest(w http.ResponseWriter, r *http.Request) (*batchRequest, io.ReadCloser, *batchError) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(h.Config.Batch.MaxBytes)+formFieldSlack)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	req := &batchRequest{}
+	var audio io.ReadCloser
+
+	switch mediaType {
+	case "application/json":
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			return nil, nil, h.invalidBody(fmt.Errorf("invalid JSON body: %w", err))
+		}
+		if req.URL == "" {
+			return nil, nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidRequest,
+				"url is required in a JSON body, upload files as multipart/form-data")
+		}
+
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(multipartMemory); err != nil {
+			return nil, nil, h.invalidBody(err)
+		}
+		req.URL = r.FormValue("url")
+		req.Model = r.FormValue("model")
+		req.Language = r.FormValue("language")
+		req.Prompt = r.FormValue("prompt")
+		if temperature := r.FormValue("temperature"); temperature != "" {
+			var err error
+			if req.Temperature, err = strconv.ParseFloat(temperature, 64); err != nil {
+				return nil, nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidRequest,
+					fmt.Sprintf("temperature must be a number, got %q", temperature))
+			}
+		}
+		req.ResponseFormat = r.FormValue("response_format")
+		req.CallbackURL = r.FormValue("callback_url")
+		// OpenAI clients send the list as repeated timestamp_granularities[] fields
+		req.TimestampGranularities = append(r.MultipartForm.Value["timestamp_granularities[]"],
+			r.MultipartForm.Value["timestamp_granularities"]...)
+		// Metadata comes as metadata[<key>] fields, as in the realtime query
+		metadata, err := middleware.MetadataValues(r.MultipartForm.Value)
+		if err != nil {
+			return nil, nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidValue, err.Error())
+		}
+		req.Metadata = metadata
+
+		file, header, err := r.FormFile("file")
+		switch {
+		case err == nil && req.URL != "":
+			file.Close()
+			return nil, nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidRequest, "Send either file or url, not both")
+		case err == nil:
+			if header.Size > int64(h.Config.Batch.MaxBytes) {
+				file.Close()
+				return nil, nil, h.tooLarge()
+			}
+			audio = file
+		case req.URL == "":
+			return nil, nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidRequest, "file or url is required")
+		}
+
+	default:
+		return nil, nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidRequest,
+			fmt.Sprintf("Unsupported content type %q, use multipart/form-data or application/json", mediaType))
+	}
+
+	switch req.ResponseFormat {
+	case "", FormatJSON:
+		req.ResponseFormat = FormatJSON
+	case "text":
+		req.ResponseFormat = FormatText
+	case FormatSRT, FormatVerboseJSON:
+	default:
+		if audio != nil {
+			audio.Close()
+		}
+		return nil, nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidResponseFormat,
+			"response_format must be json, text, srt or verbose_json")
+	}
+	if req.Temperature < 0 || req.Temperature > 1 {
+		if audio != nil {
+			audio.Close()
+		}
+		return nil, nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidRequest,
+			fmt.Sprintf("temperature must be between 0 and 1, got %g", req.Temperature))
+	}
+	if err := domain.ValidateMetadata("metadata", req.Metadata); err != nil {
+		if audio != nil {
+			audio.Close()
+		}
+		return nil, nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidValue, err.Error())
+	}
+	for _, granularity := range req.TimestampGranularities {
+		var message string
+		switch {
+		case granularity != "segment" && granularity != "word":
+			message = fmt.Sprintf("Unsupported timestamp granularity %q, use segment or word", granularity)
+		case req.ResponseFormat != FormatVerboseJSON:
+			message = "timestamp_granularities requires response_format verbose_json"
+		default:
+			continue
+		}
+		if audio != nil {
+			audio.Close()
+		}
+		return nil, nil, newBatchError(http.StatusBadRequest, domain.CodeInvalidRequest, message)
+	}
+	return req, audio, nil
+}
+
+// invalidBody maps a body read error, which may be the upload limit
+func (h *Handler) invalidBody(err error) *batchError {
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		return h.tooLarge()
+	}
+	return newBatchError(http.StatusBadRequest, domain.CodeInvalidRequest, err.Error())
+}
+
+func (h *Handler) tooLarge() *batchError {
+	return newBatchError(http.StatusRequestEntityTooLarge, domain.CodeFileTooLarge,
+		fmt.Sprintf("Audio files are limited to %d bytes", h.Config.Batch.MaxBytes))
+}
+
+// fetchSource downloads a source URL to a temporary file, removed on Close
+func (h *Handler) fetchSource(ctx context.Context, sourceURL string) (io.ReadCloser, error) {
+	if len(h.Config.Batch.AllowedHosts) == 0 {
+		return nil, fmt.Errorf("%w: source URLs are not enabled on this server", fetch.ErrNotAllowed)
+	}
+	if err := h.fetcher.Check(sourceURL); err != nil {
+		return nil, err
+	}
+
+	source, err := newTempFile()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.fetcher.Download(ctx, sourceURL, source); err != nil {
+		source.Close()
+		return nil, err
+	}
+	if _, err := source.Seek(0, io.SeekStart); err != nil {
+		source.Close()
+		return nil, err
+	}
+	return source, nil
+}
+
+func writeBatchError(w http.ResponseWriter, berr *batchError) {
+	writeJSON(w, berr.status, map[string]interface{}{"error": berr.detail})
+}
+
+// tempFile is a temporary file deleted when closed
+type tempFile struct {
+	*os.File
+}
+
+func newTempFile() (*tempFile, error) {
+	file, err := os.CreateTemp("", "gribe-source-*")
+	if err != nil {
+		return nil, err
+	}
+	return &tempFile{file}, nil
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
@@ -0,0 +1,254 @@
+package transcription
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/audiogen"
+	"github.com/aira-id/gribe/internal/pkg/wav"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+// speechWAV returns a 48kHz stereo WAV file with one turn of speech
+func speechWAV() []byte {
+	audio := append(append(stereoTone(100, 0), stereoTone(400, 8000)...), stereoTone(300, 0)...)
+	return append(wav.Header(wav.Format{SampleRate: 48000, Channels: 2, BitsPerSample: 16}, len(audio)), audio...)
+}
+
+// uploadForm builds a multipart form with the file (if any) and fields
+func uploadForm(file []byte, fields map[string]string) (string, *bytes.Buffer) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if file != nil {
+		part, _ := form.CreateFormFile("file", "audio.wav")
+		part.Write(file)
+	}
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	form.Close()
+	return form.FormDataContentType(), &body
+}
+
+func TestBatchTranscription(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(speechWAV())
+	}))
+	defer source.Close()
+	srv.Config.Batch.AllowedHosts = []string{"127.0.0.1"}
+
+	api := httptest.NewServer(NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth))
+	defer api.Close()
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        io.Reader
+		want        string
+	}{
+		{"upload", "", nil, `{"text":"hello"}`},
+		{"upload srt", "", nil, "1\n00:00:00,"},
+		{"url form field", "", nil, `{"text":"hello"}`},
+		{"url JSON body", "application/json", strings.NewReader(`{"url":"` + source.URL + `/a.wav","model":"` +
+			realtimetest.MockModel + `","language":"en","response_format":"text"}`), "hello\n"},
+	} {
+		fields := map[string]string{"model": realtimetest.MockModel, "language": "en"}
+		switch tc.name {
+		case "upload":
+			tc.contentType, tc.body = uploadForm(speechWAV(), fields)
+		case "upload srt":
+			fields["response_format"] = "srt"
+			tc.contentType, tc.body = uploadForm(speechWAV(), fields)
+		case "url form field":
+			fields["url"] = source.URL + "/a.wav"
+			tc.contentType, tc.body = uploadForm(nil, fields)
+		}
+
+		resp, err := http.Post(api.URL+"/v1/audio/transcriptions", tc.contentType, tc.body)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tc.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(strings.TrimSpace(string(body)), strings.TrimSpace(tc.want)) {
+			t.Errorf("%s: expected 200 %q, got %d %q", tc.name, tc.want, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestTranscribeWAVTurns(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	// A 44.1kHz stereo recording of three turns over background noise
+	spec := audiogen.Spec{
+		SampleRate: 44100,
+		Channels:   2,
+		Segments:   audiogen.Turns(3, time.Second, 900*time.Millisecond),
+		NoiseLevel: 0.005,
+	}
+	transcript, err := TranscribeWAV(srv.UseCase, bytes.NewReader(spec.WAV()),
+		FileOptions{Model: realtimetest.MockModel, Language: "en"})
+	if err != nil {
+		t.Fatalf("TranscribeWAV failed: %v", err)
+	}
+	spans := spec.Speech()
+	if len(transcript.Segments) != len(spans) || transcript.DurationMs != int(spec.Duration().Milliseconds()) {
+		t.Fatalf("Expected a segment per turn of %v, got %+v", spans, transcript)
+	}
+	for i, segment := range transcript.Segments {
+		// Segments include the prefix padding before speech and the silence after it
+		if start := int(spans[i].Start.Milliseconds()); segment.StartMs < start-400 || segment.StartMs > start+100 ||
+			segment.EndMs < int(spans[i].End.Milliseconds()) || segment.Text != "hello" {
+			t.Errorf("Segment %d: expected speech at %v, got %+v", i, spans[i], segment)
+		}
+	}
+}
+
+func TestBatchVerboseJSON(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello", " big world"})
+	api := httptest.NewServer(NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth))
+	defer api.Close()
+
+	contentType, body := uploadForm(speechWAV(), map[string]string{
+		"model": realtimetest.MockModel, "language": "en",
+		"response_format": "verbose_json", "timestamp_granularities[]": "word",
+	})
+	resp, err := http.Post(api.URL+"/v1/audio/transcriptions", contentType, body)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var verbose VerboseTranscript
+	if err := json.NewDecoder(resp.Body).Decode(&verbose); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected a verbose_json transcript, got %d: %v", resp.StatusCode, err)
+	}
+
+	if verbose.Task != "transcribe" || verbose.Language != "en" || verbose.Text != "hello big world" || verbose.Duration != 0.8 {
+		t.Errorf("Unexpected transcript %+v", verbose)
+	}
+	if len(verbose.Segments) != 1 {
+		t.Fatalf("Expected one segment, got %+v", verbose.Segments)
+	}
+	segment := verbose.Segments[0]
+	if segment.AvgLogprob != -0.25 || segment.NoSpeechProb != 0.01 || segment.CompressionRatio <= 0 || segment.Tokens == nil {
+		t.Errorf("Expected the provider's details in the segment, got %+v", segment)
+	}
+	if len(verbose.Words) != 3 || verbose.Words[2].Word != "world" {
+		t.Fatalf("Expected three timed words, got %+v", verbose.Words)
+	}
+	// Words are timed from the start of the file, not of the segment
+	if verbose.Words[0].Start != segment.Start || verbose.Words[2].End <= verbose.Words[2].Start {
+		t.Errorf("Expected words timed within the segment at %v, got %+v", segment.Start, verbose.Words)
+	}
+}
+
+func TestBatchTranslation(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+	api := httptest.NewServer(NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth))
+	defer api.Close()
+
+	translate := func() (*http.Response, error) {
+		// Like OpenAI clients, name no language
+		contentType, body := uploadForm(speechWAV(), map[string]string{
+			"model": realtimetest.MockModel, "response_format": "verbose_json",
+		})
+		return http.Post(api.URL+"/v1/audio/translations", contentType, body)
+	}
+
+	resp, err := translate()
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var result struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || result.Error.Code != "translation_not_supported" {
+		t.Fatalf("Expected 400 translation_not_supported from a model that cannot translate, got %d %s",
+			resp.StatusCode, result.Error.Code)
+	}
+
+	srv.Provider.SetTranslationSupport(true)
+	resp, err = translate()
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var verbose VerboseTranscript
+	if err := json.NewDecoder(resp.Body).Decode(&verbose); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected a verbose_json translation, got %d: %v", resp.StatusCode, err)
+	}
+	if verbose.Task != "translate" || verbose.Language != "en" || verbose.Text != "hello" {
+		t.Errorf("Unexpected translation %+v", verbose)
+	}
+	config := srv.Provider.LastConfig()
+	if !config.Translate || config.Language != srv.Provider.GetSupportedLanguages()[0] {
+		t.Errorf("Expected a translation from the model's first language, got %+v", config)
+	}
+}
+
+func TestBatchRejectsInput(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Config.Batch.MaxBytes = 1024
+	srv.Config.Batch.AllowedHosts = []string{"audio.example.com"}
+	api := httptest.NewServer(NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth))
+	defer api.Close()
+
+	for _, tc := range []struct {
+		name   string
+		file   []byte
+		fields map[string]string
+		status int
+		code   string
+	}{
+		{"no input", nil, nil, http.StatusBadRequest, "invalid_request"},
+		{"file and url", []byte("RIFF"), map[string]string{"url": "https://audio.example.com/a.wav"}, http.StatusBadRequest, "invalid_request"},
+		{"not a wav", []byte("ID3 mp3 data"), nil, http.StatusBadRequest, "invalid_audio_format"},
+		{"too large", make([]byte, 2048), nil, http.StatusRequestEntityTooLarge, "file_too_large"},
+		{"host not allowed", nil, map[string]string{"url": "http://169.254.169.254/latest/meta-data"}, http.StatusBadRequest, "url_not_allowed"},
+		{"unknown format", []byte("RIFF"), map[string]string{"response_format": "docx"}, http.StatusBadRequest, "invalid_response_format"},
+		{"temperature out of range", []byte("RIFF"), map[string]string{"temperature": "1.5"}, http.StatusBadRequest, "invalid_request"},
+		{"granularity without verbose_json", []byte("RIFF"), map[string]string{"timestamp_granularities[]": "word"}, http.StatusBadRequest, "invalid_request"},
+	} {
+		contentType, body := uploadForm(tc.file, tc.fields)
+		resp, err := http.Post(api.URL+"/v1/audio/transcriptions", contentType, body)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tc.name, err)
+		}
+		var result struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status || result.Error.Code != tc.code {
+			t.Errorf("%s: expected %d %s, got %d %s", tc.name, tc.status, tc.code, resp.StatusCode, result.Error.Code)
+		}
+	}
+}
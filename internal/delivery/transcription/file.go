@@ -0,0 +1,213 @@
+package transcription
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/delivery/gateway"
+	"github.com/aira-id/gribe/internal/pkg/wav"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// fileFinishTimeout bounds the wait for the last turns of a file, which may
+// queue far more audio than a live stream
+const fileFinishTimeout = 2 * time.Minute
+
+// Segment is one transcribed turn of an audio file
+type Segment struct {
+	StartMs int    `json:"start_ms"`
+	EndMs   int    `json:"end_ms"`
+	Text    string `json:"text"`
+
+	// Details reported by the provider, with FileOptions.Include. Words are
+	// timed from the start of the file.
+	Words        []domain.WordTiming `json:"words,omitempty"`
+	AvgLogprob   float64             `json:"avg_logprob,omitempty"`
+	NoSpeechProb float64             `json:"no_speech_prob,omitempty"`
+}
+
+// Transcript is the transcription of a complete audio file
+type Transcript struct {
+	Text       string    `json:"text"`
+	Language   string    `json:"language,omitempty"` // Transcription language of the session
+	DurationMs int       `json:"duration_ms"`
+	Segments   []Segment `json:"segments"`
+}
+
+// SessionError is an error event reported by the session transcribing a file
+type SessionError struct {
+	Detail *domain.ErrorDetail
+}
+
+func (e *SessionError) Error() string {
+	return e.Detail.Message
+}
+
+// FileOptions configures the session transcribing a file
+type FileOptions struct {
+	Model       string // Transcription model, empty for the server default
+	Language    string
+	Prompt      string         // Transcription prompt, for models that support one
+	Temperature float64        // Transcription temperature, for models that support one
+	Translate   bool           // Translate to English, for models that support it
+	APIKey      string         // Key the session is accounted to for quotas and tenants
+	Tenant      *domain.Tenant // Tenant of the session, resolved from APIKey if nil
+	Label       string         // Identifies the session in logs
+	Include     []string       // Segment details to request, see domain.IncludeWords
+
+	// Metadata is attached to the session, see domain.Session.Metadata
+	Metadata map[string]string
+}
+
+// TranscribeWAV transcribes a 16-bit PCM WAV file read from r
+func TranscribeWAV(uc *usecase.SessionUsecase, r io.Reader, opts FileOptions) (*Transcript, error) {
+	format, err := wav.ReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	return TranscribePCM(uc, r, format, opts)
+}
+
+// TranscribePCM transcribes little-endian PCM16 audio of format read from r.
+// The file is split into turns by server VAD, each becoming a segment.
+func TranscribePCM(uc *usecase.SessionUsecase, r io.Reader, format *wav.Format, opts FileOptions) (*Transcript, error) {
+	if err := checkSampleRate(format.SampleRate); err != nil {
+		return nil, err
+	}
+
+	collector := &segmentCollector{timer: newTurnTimer()}
+	session := gateway.StartSession(uc, gateway.SessionOptions{
+		Model:         opts.Model,
+		Language:      opts.Language,
+		Prompt:        opts.Prompt,
+		Temperature:   opts.Temperature,
+		Translate:     opts.Translate,
+		APIKey:        opts.APIKey,
+		Tenant:        opts.Tenant,
+		Label:         opts.Label,
+		Include:       opts.Include,
+		Metadata:      opts.Metadata,
+		OnEvent:       collector.handleEvent,
+		FinishTimeout: fileFinishTimeout,
+	})
+	n, err := feed(session, r, format)
+	session.End()
+	if err != nil {
+		return nil, err
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.err != nil {
+		return nil, collector.err
+	}
+	// Turns are transcribed concurrently and may complete out of order
+	sort.SliceStable(collector.segments, func(i, j int) bool {
+		return collector.segments[i].StartMs < collector.segments[j].StartMs
+	})
+	texts := make([]string, len(collector.segments))
+	for i, segment := range collector.segments {
+		texts[i] = segment.Text
+	}
+	return &Transcript{
+		Text:       strings.Join(texts, " "),
+		Language:   collector.language,
+		DurationMs: int(n / int64(2*format.Channels) * 1000 / int64(format.SampleRate)),
+		Segments:   collector.segments,
+	}, nil
+}
+
+// segmentCollector gathers the final transcripts of a file's session
+type segmentCollector struct {
+	mu       sync.Mutex // Guards the results read once the session ended
+	timer    *turnTimer
+	segments []Segment
+	language string        // Transcription language, from the session events
+	err      *SessionError // First error reported by the session
+}
+
+func (c *segmentCollector) handleEvent(eventType domain.EventType, raw []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timer.observe(eventType, raw)
+	switch eventType {
+	case domain.EventSessionCreated, domain.EventSessionUpdated:
+		var event domain.SessionUpdatedEvent
+		if json.Unmarshal(raw, &event) == nil && event.Session != nil && event.Session.Audio != nil &&
+			event.Session.Audio.Input != nil && event.Session.Audio.Input.Transcription != nil {
+			c.language = event.Session.Audio.Input.Transcription.Language
+		}
+
+	case domain.EventConversationItemInputAudioTranscriptionCompleted:
+		var event domain.ConversationItemInputAudioTranscriptionCompletedEvent
+		if json.Unmarshal(raw, &event) != nil {
+			return
+		}
+		segment := Segment{Text: strings.TrimSpace(event.Transcript), NoSpeechProb: event.NoSpeechProb}
+		segment.StartMs, segment.EndMs = c.timer.finish(event.ItemID)
+		for _, word := range event.Words {
+			word.StartMs += segment.StartMs
+			word.EndMs += segment.StartMs
+			segment.Words = append(segment.Words, word)
+		}
+		if len(event.Logprobs) > 0 {
+			for _, logprob := range event.Logprobs {
+				segment.AvgLogprob += logprob.Logprob
+			}
+			segment.AvgLogprob /= float64(len(event.Logprobs))
+		}
+		if segment.Text != "" {
+			c.segments = append(c.segments, segment)
+		}
+
+	case domain.EventError, domain.EventConversationItemInputAudioTranscriptionFailed:
+		var event domain.ErrorServerEvent
+		if json.Unmarshal(raw, &event) == nil && event.Error != nil && c.err == nil {
+			c.err = &SessionError{Detail: event.Error}
+		}
+	}
+}
+
+// turnTimer times each turn from the VAD events so its transcript can carry
+// the span of audio it covers. Events arrive one at a time, see gateway.Conn.
+type turnTimer struct {
+	startMs int                 // Start of the turn in progress
+	turns   map[string]*Segment // Item ID -> span of turns being transcribed
+}
+
+func newTurnTimer() *turnTimer {
+	return &turnTimer{turns: make(map[string]*Segment)}
+}
+
+// observe records the start and end of turns
+func (t *turnTimer) observe(eventType domain.EventType, raw []byte) {
+	switch eventType {
+	case domain.EventInputAudioBufferSpeechStarted:
+		var event domain.InputAudioBufferSpeechStartedEvent
+		if json.Unmarshal(raw, &event) == nil {
+			t.startMs = event.AudioStartMs
+		}
+
+	case domain.EventInputAudioBufferSpeechStopped:
+		// The stopped event carries the item ID the transcription will use
+		var event domain.InputAudioBufferSpeechStoppedEvent
+		if json.Unmarshal(raw, &event) == nil {
+			t.turns[event.ItemID] = &Segment{StartMs: t.startMs, EndMs: event.AudioEndMs}
+		}
+	}
+}
+
+// finish returns the span of a transcribed item, zero if unknown, and forgets it
+func (t *turnTimer) finish(itemID string) (startMs, endMs int) {
+	turn := t.turns[itemID]
+	if turn == nil {
+		return 0, 0
+	}
+	delete(t.turns, itemID)
+	return turn.StartMs, turn.EndMs
+}
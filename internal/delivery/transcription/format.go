@@ -0,0 +1,142 @@
+package transcription
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Transcript output formats
+const (
+	FormatText = "txt"
+	FormatJSON = "json"
+	FormatSRT  = "srt"
+
+	// FormatVerboseJSON is OpenAI's verbose_json, see Verbose
+	FormatVerboseJSON = "verbose_json"
+)
+
+// VerboseTranscript is a transcript in OpenAI's verbose_json response format
+type VerboseTranscript struct {
+	Task     string           `json:"task"`
+	Language string           `json:"language"`
+	Duration float64          `json:"duration"` // Seconds
+	Text     string           `json:"text"`
+	Segments []VerboseSegment `json:"segments"`
+	Words    []VerboseWord    `json:"words,omitempty"`
+}
+
+// VerboseSegment is a segment of a VerboseTranscript. Providers do not
+// expose tokens or sampling, so tokens is empty and temperature 0.
+type VerboseSegment struct {
+	ID               int     `json:"id"`
+	Seek             int     `json:"seek"`
+	Start            float64 `json:"start"`
+	End              float64 `json:"end"`
+	Text             string  `json:"text"`
+	Tokens           []int   `json:"tokens"`
+	Temperature      float64 `json:"temperature"`
+	AvgLogprob       float64 `json:"avg_logprob"`
+	CompressionRatio float64 `json:"compression_ratio"`
+	NoSpeechProb     float64 `json:"no_speech_prob"`
+}
+
+// VerboseWord is a timed word of a VerboseTranscript
+type VerboseWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Verbose returns the transcript in OpenAI's verbose_json format, with its
+// words if words is set. Times are in seconds; the language is the session's
+// language code, not its English name.
+func (t *Transcript) Verbose(words bool) *VerboseTranscript {
+	verbose := &VerboseTranscript{
+		Task:     "transcribe",
+		Language: t.Language,
+		Duration: seconds(t.DurationMs),
+		Text:     t.Text,
+		Segments: make([]VerboseSegment, len(t.Segments)),
+	}
+	for i, segment := range t.Segments {
+		verbose.Segments[i] = VerboseSegment{
+			ID:               i,
+			Seek:             segment.StartMs / 10, // In 10ms frames, like Whisper
+			Start:            seconds(segment.StartMs),
+			End:              seconds(segment.EndMs),
+			Text:             segment.Text,
+			Tokens:           []int{},
+			AvgLogprob:       segment.AvgLogprob,
+			CompressionRatio: compressionRatio(segment.Text),
+			NoSpeechProb:     segment.NoSpeechProb,
+		}
+		if !words {
+			continue
+		}
+		for _, word := range segment.Words {
+			verbose.Words = append(verbose.Words, VerboseWord{Word: word.Word, Start: seconds(word.StartMs), End: seconds(word.EndMs)})
+		}
+	}
+	if words && verbose.Words == nil {
+		verbose.Words = []VerboseWord{}
+	}
+	return verbose
+}
+
+// seconds converts milliseconds to seconds
+func seconds(ms int) float64 {
+	return float64(ms) / 1000
+}
+
+// compressionRatio is how well text compresses, as Whisper computes it to
+// spot repetitive hallucinations: its size over its zlib-compressed size
+func compressionRatio(text string) float64 {
+	if text == "" {
+		return 0
+	}
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write([]byte(text))
+	writer.Close()
+	return float64(len(text)) / float64(compressed.Len())
+}
+
+// Write renders the transcript in format: plain text, the Transcript as
+// JSON, verbose_json with words, or SubRip subtitles with one cue per segment
+func (t *Transcript) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText:
+		_, err := fmt.Fprintln(w, t.Text)
+		return err
+
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(t)
+
+	case FormatVerboseJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(t.Verbose(true))
+
+	case FormatSRT:
+		for i, segment := range t.Segments {
+			_, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, srtTime(segment.StartMs), srtTime(segment.EndMs), segment.Text)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown transcript format %q", format)
+	}
+}
+
+// srtTime formats milliseconds as HH:MM:SS,mmm
+func srtTime(ms int) string {
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,34 @@
+package transcription
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTranscriptFormats(t *testing.T) {
+	transcript := &Transcript{
+		Text:       "hello there",
+		DurationMs: 3723500,
+		Segments: []Segment{
+			{StartMs: 250, EndMs: 1500, Text: "hello"},
+			{StartMs: 3721000, EndMs: 3723004, Text: "there"},
+		},
+	}
+
+	for format, want := range map[string]string{
+		FormatText: "hello there\n",
+		FormatSRT:  "1\n00:00:00,250 --> 00:00:01,500\nhello\n\n2\n01:02:01,000 --> 01:02:03,004\nthere\n\n",
+	} {
+		var buf bytes.Buffer
+		if err := transcript.Write(&buf, format); err != nil {
+			t.Fatalf("%s: Write failed: %v", format, err)
+		}
+		if buf.String() != want {
+			t.Errorf("%s: expected %q, got %q", format, want, buf.String())
+		}
+	}
+
+	if err := transcript.Write(&bytes.Buffer{}, "docx"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
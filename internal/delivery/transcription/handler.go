@@ -0,0 +1,95 @@
+// Package transcription serves the HTTP transcription endpoints under
+// /v1/audio/ and the transcription jobs under /v1/transcription-jobs, for
+// callers that prefer plain requests to the realtime WebSocket.
+package transcription
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/pkg/fetch"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Handler handles transcription HTTP requests
+type Handler struct {
+	UseCase *usecase.SessionUsecase
+	Config  *config.Config
+	Auth    *middleware.Authenticator
+	mux     *http.ServeMux
+	fetcher *fetch.Fetcher
+	jobs    *jobQueue
+}
+
+// NewHandler creates the handler and starts the job workers; mount it at
+// /v1/audio/, /v1/transcription-jobs and /v1/transcription-jobs/
+func NewHandler(uc *usecase.SessionUsecase, cfg *config.Config, auth *middleware.Authenticator) *Handler {
+	h := &Handler{
+		UseCase: uc,
+		Config:  cfg,
+		Auth:    auth,
+		mux:     http.NewServeMux(),
+		fetcher: fetch.NewFetcher(fetch.Options{
+			AllowedHosts: cfg.Batch.AllowedHosts,
+			MaxBytes:     int64(cfg.Batch.MaxBytes),
+			Timeout:      cfg.Batch.FetchTimeout,
+			S3:           fetch.Credentials(cfg.Batch.S3),
+			GCS:          fetch.Credentials(cfg.Batch.GCS),
+		}),
+	}
+	h.mux.HandleFunc("/v1/audio/transcriptions", h.handleTranscriptions)
+	h.mux.HandleFunc("/v1/audio/transcriptions:stream", h.handleStream)
+	h.mux.HandleFunc("/v1/audio/translations", h.handleTranslations)
+	h.mux.HandleFunc("/v1/transcription-jobs", h.handleJobs)
+	h.mux.HandleFunc("/v1/transcription-jobs/", h.handleJobs)
+	h.jobs = newJobQueue(h)
+	return h
+}
+
+// Close stops the job workers after their current jobs
+func (h *Handler) Close() {
+	h.jobs.close()
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// authenticate returns the caller if it may transcribe, otherwise it writes
+// the error response and returns nil
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request) *middleware.Principal {
+	principal, err := h.Auth.Authenticate(r)
+	if err != nil {
+		log.Printf("Invalid credentials from IP %s: %v", middleware.GetClientIP(r), err)
+		writeError(w, http.StatusUnauthorized, domain.CodeInvalidAPIKey, "A valid API key is required")
+		return nil
+	}
+	if !principal.HasScope(config.ScopeRealtimeTranscribe) {
+		log.Printf("Credentials without %s scope from IP: %s", config.ScopeRealtimeTranscribe, middleware.GetClientIP(r))
+		writeError(w, http.StatusForbidden, domain.CodeInsufficientScope, "Credentials lack the "+config.ScopeRealtimeTranscribe+" scope")
+		return nil
+	}
+	return principal
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"type":      "invalid_request_error",
+			"code":      code,
+			"message":   message,
+			"retryable": domain.IsRetryableCode(code),
+		},
+	})
+}
@@ -0,0 +1,399 @@
+package transcription
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	"github.com/aira-id/gribe/internal/pkg/webhook"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Callback delivery statuses
+const (
+	CallbackPending   = "pending"
+	CallbackDelivered = "delivered"
+	CallbackFailed    = "failed"
+)
+
+// Job is an asynchronous batch transcription
+type Job struct {
+	ID          string              `json:"id"`
+	Object      string              `json:"object"`
+	Status      string              `json:"status"`
+	Model       string              `json:"model,omitempty"`
+	Language    string              `json:"language,omitempty"`
+	CreatedAt   int64               `json:"created_at"`
+	CompletedAt int64               `json:"completed_at,omitempty"`
+	Result      *Transcript         `json:"result,omitempty"`
+	Error       *domain.ErrorDetail `json:"error,omitempty"`
+
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackStatus string `json:"callback_status,omitempty"` // Set once the job has finished
+
+	Metadata map[string]string `json:"metadata,omitempty"` // Set by the client, echoed unchanged
+
+	owner    string
+	finished time.Time
+	request  *batchRequest
+	audio    io.ReadCloser // Spooled upload, nil for a source URL
+	opts     FileOptions
+}
+
+// callbackTimeout bounds each callback delivery attempt
+const callbackTimeout = 10 * time.Second
+
+// Job metrics are shared by the job queues of the process
+var (
+	jobsQueued    = metrics.NewGauge("gribe_transcription_jobs_queued", "Transcription jobs waiting for a worker")
+	jobsCompleted = metrics.NewCounter("gribe_transcription_jobs_completed_total",
+		"Transcription jobs that completed")
+	jobsFailed = metrics.NewCounter("gribe_transcription_jobs_failed_total",
+		"Transcription jobs that failed")
+	jobCallbacksFailed = metrics.NewCounter("gribe_transcription_job_callbacks_failed_total",
+		"Job callbacks that could not be delivered after all attempts")
+)
+
+// jobQueue runs transcription jobs on a fixed pool of workers and keeps
+// finished jobs for the configured retention
+type jobQueue struct {
+	handler *Handler
+	queue   chan *Job
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	once    sync.Once
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+
+	callbacks *webhook.Sender
+}
+
+func newJobQueue(h *Handler) *jobQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &jobQueue{
+		handler: h,
+		queue:   make(chan *Job, h.Config.Batch.QueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		jobs:    make(map[string]*Job),
+		callbacks: webhook.NewSender(webhook.Options{
+			AllowedHosts: h.Config.Batch.CallbackHosts,
+			Secret:       h.Config.Batch.CallbackSecret,
+			MaxAttempts:  h.Config.Batch.CallbackAttempts,
+			Backoff:      h.Config.Batch.CallbackBackoff,
+			Timeout:      callbackTimeout,
+		}),
+	}
+
+	for i := 0; i < h.Config.Batch.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	q.wg.Add(1)
+	go q.expire()
+	return q
+}
+
+// submit queues a job, returning false when the queue is full
+func (q *jobQueue) submit(job *Job) bool {
+	if q.ctx.Err() != nil {
+		return false
+	}
+	q.mu.Lock()
+	q.jobs[job.ID] = job
+	q.mu.Unlock()
+
+	select {
+	case q.queue <- job:
+		jobsQueued.Inc()
+		return true
+	default:
+		q.mu.Lock()
+		delete(q.jobs, job.ID)
+		q.mu.Unlock()
+		return false
+	}
+}
+
+// get returns a snapshot of the job if it belongs to owner
+func (q *jobQueue) get(id, owner string) *Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok || job.owner != owner {
+		return nil
+	}
+	snapshot := *job
+	return &snapshot
+}
+
+func (q *jobQueue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case job := <-q.queue:
+			jobsQueued.Dec()
+			q.run(job)
+		}
+	}
+}
+
+func (q *jobQueue) run(job *Job) {
+	q.setStatus(job, JobRunning)
+	if job.audio != nil {
+		defer job.audio.Close()
+	}
+
+	var audio io.Reader
+	if job.audio != nil {
+		audio = job.audio
+	}
+	transcript, berr := q.transcribe(job, audio)
+
+	q.mu.Lock()
+	job.finished = time.Now()
+	job.CompletedAt = job.finished.Unix()
+	if berr != nil {
+		job.Status = JobFailed
+		job.Error = berr.detail
+	} else {
+		job.Status = JobCompleted
+		job.Result = transcript
+	}
+	if job.CallbackURL != "" {
+		job.CallbackStatus = CallbackPending
+	}
+	snapshot := *job
+	q.mu.Unlock()
+
+	if berr != nil {
+		jobsFailed.Inc()
+		log.Printf("[INFO] Transcription job %s failed: %s", job.ID, berr.detail.Message)
+	} else {
+		jobsCompleted.Inc()
+		log.Printf("[INFO] Transcription job %s completed: %d segments", job.ID, len(transcript.Segments))
+	}
+
+	// Delivery retries must not hold up the next job
+	if job.CallbackURL != "" {
+		q.wg.Add(1)
+		go q.deliver(job, &snapshot)
+	}
+}
+
+// transcribe runs the job's transcription, failing it with a server_error if
+// the transcription panics
+func (q *jobQueue) transcribe(job *Job, audio io.Reader) (transcript *Transcript, berr *batchError) {
+	defer panics.Recover("transcription job "+job.ID, func(error) {
+		transcript, berr = nil, newBatchError(http.StatusInternalServerError, domain.CodeServerError, "The server failed to transcribe the audio")
+	})
+	return q.handler.transcribeBatch(q.ctx, job.request, audio, job.opts)
+}
+
+// deliver posts the finished job to its callback URL
+func (q *jobQueue) deliver(job *Job, snapshot *Job) {
+	defer q.wg.Done()
+	snapshot.CallbackStatus = ""
+	body, _ := json.Marshal(snapshot)
+
+	status := CallbackDelivered
+	if err := q.callbacks.Send(q.ctx, job.CallbackURL, body); err != nil {
+		status = CallbackFailed
+		jobCallbacksFailed.Inc()
+		log.Printf("[WARN] Callback for transcription job %s failed: %v", job.ID, err)
+	}
+	q.setCallbackStatus(job, status)
+}
+
+func (q *jobQueue) setStatus(job *Job, status string) {
+	q.mu.Lock()
+	job.Status = status
+	q.mu.Unlock()
+}
+
+func (q *jobQueue) setCallbackStatus(job *Job, status string) {
+	q.mu.Lock()
+	job.CallbackStatus = status
+	q.mu.Unlock()
+}
+
+// expire drops finished jobs once their retention has passed
+func (q *jobQueue) expire() {
+	defer q.wg.Done()
+	retention := q.handler.Config.Batch.JobRetention
+	ticker := time.NewTicker(min(retention, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case now := <-ticker.C:
+			q.mu.Lock()
+			for id, job := range q.jobs {
+				if !job.finished.IsZero() && now.Sub(job.finished) > retention {
+					delete(q.jobs, id)
+				}
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+// close stops the workers once their current jobs finish; queued jobs are dropped
+func (q *jobQueue) close() {
+	q.once.Do(q.cancel)
+	q.wg.Wait()
+	for {
+		select {
+		case job := <-q.queue:
+			jobsQueued.Dec()
+			if job.audio != nil {
+				job.audio.Close()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// handleJobs serves POST /v1/transcription-jobs, which queues a batch
+// transcription taking the same input as /v1/audio/transcriptions and answers
+// 202 with the job, and GET /v1/transcription-jobs/{id}, which reports its
+// status and, once completed, the transcript.
+func (h *Handler) handleJobs(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v1/transcription-jobs"), "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		h.createJob(w, r)
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodGet:
+		h.getJob(w, r, id)
+	case id == "":
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only POST is supported")
+	default:
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only GET is supported")
+	}
+}
+
+func (h *Handler) createJob(w http.ResponseWriter, r *http.Request) {
+	principal := h.authenticate(w, r)
+	if principal == nil {
+		return
+	}
+	req, upload, berr := h.readBatchRequest(w, r)
+	if berr == nil && req.CallbackURL != "" {
+		berr = h.checkCallback(req.CallbackURL)
+		if berr != nil && upload != nil {
+			upload.Close()
+		}
+	}
+	if berr != nil {
+		writeBatchError(w, berr)
+		return
+	}
+
+	job := &Job{
+		ID:          "job_" + uuid.New().String(),
+		Object:      "transcription.job",
+		Status:      JobQueued,
+		Model:       req.Model,
+		Language:    req.Language,
+		CreatedAt:   time.Now().Unix(),
+		CallbackURL: req.CallbackURL,
+		Metadata:    req.Metadata,
+		owner:       principal.ID,
+		request:     req,
+		opts: FileOptions{
+			Model:       req.Model,
+			Language:    req.Language,
+			Prompt:      req.Prompt,
+			Temperature: req.Temperature,
+			APIKey:      principal.ID,
+			Tenant:      h.UseCase.ResolveTenant(principal.APIKey, principal.TenantClaim),
+			Label:       "Transcription job from " + middleware.GetClientIP(r),
+			Include:     req.include(),
+			Metadata:    req.Metadata,
+		},
+	}
+	// The upload is removed when the request ends, so keep a copy for the worker
+	if upload != nil {
+		spooled, err := newTempFile()
+		if err == nil {
+			if _, err = io.Copy(spooled, upload); err == nil {
+				_, err = spooled.Seek(0, io.SeekStart)
+			}
+			if err != nil {
+				spooled.Close()
+			}
+		}
+		upload.Close()
+		if err != nil {
+			log.Printf("[WARN] Could not spool upload for transcription job: %v", err)
+			writeError(w, http.StatusInternalServerError, domain.CodeServerError, "Could not store the uploaded file")
+			return
+		}
+		job.audio = spooled
+	}
+
+	snapshot := *job
+	if !h.jobs.submit(job) {
+		if job.audio != nil {
+			job.audio.Close()
+		}
+		w.Header().Set("Retry-After", "30")
+		writeError(w, http.StatusServiceUnavailable, domain.CodeQueueFull, "Too many transcription jobs are queued, retry later")
+		return
+	}
+	log.Printf("[INFO] Queued transcription job %s for %s", job.ID, middleware.GetClientIP(r))
+	w.Header().Set("Location", middleware.ExternalPath(r, "/v1/transcription-jobs/"+job.ID))
+	writeJSON(w, http.StatusAccepted, &snapshot)
+}
+
+func (h *Handler) getJob(w http.ResponseWriter, r *http.Request, id string) {
+	principal := h.authenticate(w, r)
+	if principal == nil {
+		return
+	}
+	job := h.jobs.get(id, principal.ID)
+	if job == nil {
+		writeError(w, http.StatusNotFound, domain.CodeJobNotFound, "No transcription job "+id)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// checkCallback validates a job's callback_url against batch.callback_hosts
+func (h *Handler) checkCallback(callbackURL string) *batchError {
+	if len(h.Config.Batch.CallbackHosts) == 0 {
+		return newBatchError(http.StatusBadRequest, domain.CodeCallbackNotAllowed, "Callbacks are not enabled on this server")
+	}
+	if err := h.jobs.callbacks.Check(callbackURL); err != nil {
+		if errors.Is(err, webhook.ErrNotAllowed) {
+			return newBatchError(http.StatusBadRequest, domain.CodeCallbackNotAllowed, err.Error())
+		}
+		return newBatchError(http.StatusBadRequest, domain.CodeInvalidRequest, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package transcription
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/webhook"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+// waitForJob polls a job until it has finished
+func waitForJob(t *testing.T, baseURL, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(baseURL + "/v1/transcription-jobs/" + id)
+		if err != nil {
+			t.Fatalf("Status request failed: %v", err)
+		}
+		var job Job
+		json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200 for job %s, got %d", id, resp.StatusCode)
+		}
+		if job.Status == JobCompleted || job.Status == JobFailed {
+			return &job
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", id)
+	return nil
+}
+
+func TestTranscriptionJobs(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	handler := NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth)
+	defer handler.Close()
+	api := httptest.NewServer(handler)
+	defer api.Close()
+
+	contentType, body := uploadForm(speechWAV(), map[string]string{"model": realtimetest.MockModel, "language": "en"})
+	resp, err := http.Post(api.URL+"/v1/transcription-jobs", contentType, body)
+	if err != nil {
+		t.Fatalf("Create request failed: %v", err)
+	}
+	var created Job
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || !strings.HasPrefix(created.ID, "job_") || created.Status != JobQueued {
+		t.Fatalf("Expected 202 with a queued job, got %d %+v", resp.StatusCode, created)
+	}
+	if resp.Header.Get("Location") != "/v1/transcription-jobs/"+created.ID {
+		t.Errorf("Unexpected Location %q", resp.Header.Get("Location"))
+	}
+
+	job := waitForJob(t, api.URL, created.ID)
+	if job.Status != JobCompleted || job.Result == nil || job.Result.Text != "hello" || len(job.Result.Segments) != 1 {
+		t.Fatalf("Expected a completed job with the transcript, got %+v", job)
+	}
+	if job.CompletedAt == 0 || job.Error != nil {
+		t.Errorf("Unexpected completion fields: %+v", job)
+	}
+
+	// Source URLs are fetched by the worker, so refusals fail the job
+	resp, err = http.Post(api.URL+"/v1/transcription-jobs", "application/json",
+		strings.NewReader(`{"url":"https://evil.example.com/a.wav"}`))
+	if err != nil {
+		t.Fatalf("Create request failed: %v", err)
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if job = waitForJob(t, api.URL, created.ID); job.Status != JobFailed || job.Error == nil || job.Error.Code != "url_not_allowed" {
+		t.Errorf("Expected the job to fail with url_not_allowed, got %+v", job)
+	}
+
+	resp, err = http.Get(api.URL + "/v1/transcription-jobs/job_missing")
+	if err != nil {
+		t.Fatalf("Status request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown job, got %d", resp.StatusCode)
+	}
+}
+
+func TestTranscriptionJobQueueFull(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Config.Batch.Workers = 0 // Nothing drains the queue
+	srv.Config.Batch.QueueSize = 1
+
+	handler := NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth)
+	defer handler.Close()
+	api := httptest.NewServer(handler)
+	defer api.Close()
+
+	for i, want := range []int{http.StatusAccepted, http.StatusServiceUnavailable} {
+		contentType, body := uploadForm(speechWAV(), nil)
+		resp, err := http.Post(api.URL+"/v1/transcription-jobs", contentType, body)
+		if err != nil {
+			t.Fatalf("Create request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Job %d: expected %d, got %d", i, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestTranscriptionJobCallback(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	delivered := make(chan *http.Request, 1)
+	var attempts atomic.Int32
+	var payload []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		payload, _ = io.ReadAll(r.Body)
+		delivered <- r
+	}))
+	defer receiver.Close()
+	srv.Config.Batch.CallbackHosts = []string{"127.0.0.1"}
+	srv.Config.Batch.CallbackSecret = "hook-secret"
+
+	handler := NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth)
+	defer handler.Close()
+	api := httptest.NewServer(handler)
+	defer api.Close()
+
+	contentType, body := uploadForm(speechWAV(), map[string]string{"model": realtimetest.MockModel, "language": "en",
+		"callback_url": "https://hooks.example.com/done"})
+	resp, err := http.Post(api.URL+"/v1/transcription-jobs", contentType, body)
+	if err != nil {
+		t.Fatalf("Create request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a callback outside callback_hosts to be refused, got %d", resp.StatusCode)
+	}
+
+	contentType, body = uploadForm(speechWAV(), map[string]string{"model": realtimetest.MockModel, "language": "en",
+		"callback_url": receiver.URL + "/done"})
+	resp, err = http.Post(api.URL+"/v1/transcription-jobs", contentType, body)
+	if err != nil {
+		t.Fatalf("Create request failed: %v", err)
+	}
+	var created Job
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+
+	var req *http.Request
+	select {
+	case req = <-delivered:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Callback was not delivered")
+	}
+	var job Job
+	if err := json.Unmarshal(payload, &job); err != nil || job.ID != created.ID || job.Status != JobCompleted || job.Result.Text != "hello" {
+		t.Fatalf("Unexpected callback payload %s", payload)
+	}
+	signature := req.Header.Get(webhook.SignatureHeader)
+	timestamp, _ := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+	if signature != webhook.Sign("hook-secret", time.Unix(timestamp, 0), payload) {
+		t.Errorf("Callback signature %q does not match the payload", signature)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for waitForJob(t, api.URL, created.ID).CallbackStatus != CallbackDelivered {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected callback_status delivered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,107 @@
+package transcription
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+)
+
+// SelfTest transcribes a known sample with every configured model, loading
+// them, as asr.self_test configures. Each outcome is logged and recorded in
+// the model's health; the error names the models that failed.
+func SelfTest(uc *usecase.SessionUsecase, cfg *config.ASRConfig) error {
+	names := make([]string, 0, len(cfg.Models))
+	for name := range cfg.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var failed []string
+	for _, name := range names {
+		sample := selfTestSample(cfg, name)
+		if sample == "" {
+			log.Printf("[WARN] Self-test of model %s skipped: no test_wavs/0.wav in its directory and no asr.self_test.sample", name)
+			continue
+		}
+		result := selfTestModel(uc, cfg, name, sample)
+		uc.ASRRegistry().RecordSelfTest(name, result)
+		if !result.Passed {
+			log.Printf("[ERROR] Self-test of model %s with %s FAILED: %s", name, sample, result.Error)
+			failed = append(failed, name)
+			continue
+		}
+		log.Printf("[INFO] Self-test of model %s passed in %dms: %q", name, result.DurationMs, result.Transcript)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("models failed their self-test: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// selfTestSample returns the WAV a model is tested with: the test_wavs/0.wav
+// shipped in its directory, else the configured sample
+func selfTestSample(cfg *config.ASRConfig, name string) string {
+	bundled := filepath.Join(cfg.ModelsDir, name, "test_wavs", "0.wav")
+	if _, err := os.Stat(bundled); err == nil {
+		return bundled
+	}
+	return cfg.SelfTest.Sample
+}
+
+// selfTestModel transcribes sample with a model through a session, in the
+// model's first language, expecting a non-empty transcript within the timeout
+func selfTestModel(uc *usecase.SessionUsecase, cfg *config.ASRConfig, name, sample string) usecase.SelfTestResult {
+	type outcome struct {
+		transcript *Transcript
+		err        error
+	}
+	done := make(chan outcome, 1)
+	started := time.Now()
+	go func() {
+		file, err := os.Open(sample)
+		if err != nil {
+			done <- outcome{err: err}
+			return
+		}
+		defer file.Close()
+		language := ""
+		if languages := cfg.Models[name].Languages; len(languages) > 0 {
+			language = languages[0]
+		}
+		transcript, err := TranscribeWAV(uc, file, FileOptions{
+			Model:    name,
+			Language: language,
+			Label:    "Self-test of model " + name,
+			Metadata: map[string]string{"self_test": "true"},
+		})
+		done <- outcome{transcript, err}
+	}()
+
+	var result outcome
+	select {
+	case result = <-done:
+	case <-time.After(cfg.SelfTest.Timeout):
+		result.err = fmt.Errorf("no transcript within %v", cfg.SelfTest.Timeout)
+	}
+	if result.err == nil && strings.TrimSpace(result.transcript.Text) == "" {
+		result.err = errors.New("empty transcript")
+	}
+	selfTest := usecase.SelfTestResult{
+		Passed:     result.err == nil,
+		DurationMs: int(time.Since(started).Milliseconds()),
+	}
+	if result.err != nil {
+		selfTest.Error = result.err.Error()
+	} else {
+		selfTest.Transcript = result.transcript.Text
+	}
+	return selfTest
+}
@@ -0,0 +1,60 @@
+package transcription
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+func TestSelfTest(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	asr := &srv.Config.ASR
+	asr.ModelsDir = t.TempDir()
+	asr.SelfTest.Timeout = 5 * time.Second
+	if err := SelfTest(srv.UseCase, asr); err != nil {
+		t.Fatalf("Expected models without a sample to be skipped, got %v", err)
+	}
+	if model := srv.UseCase.Health().Models[realtimetest.MockModel]; model.SelfTest != nil {
+		t.Fatalf("Expected no self-test result without a sample, got %+v", model.SelfTest)
+	}
+
+	// The sample shipped in the model's directory
+	dir := filepath.Join(asr.ModelsDir, realtimetest.MockModel, "test_wavs")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "0.wav"), speechWAV(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SelfTest(srv.UseCase, asr); err != nil {
+		t.Fatalf("Expected the self-test to pass, got %v", err)
+	}
+	model := srv.UseCase.Health().Models[realtimetest.MockModel]
+	if model.SelfTest == nil || !model.SelfTest.Passed || model.SelfTest.Transcript != "hello" {
+		t.Fatalf("Expected a passed self-test, got %+v", model.SelfTest)
+	}
+
+	srv.Provider.SetMockResults([]string{" "})
+	if err := SelfTest(srv.UseCase, asr); err == nil {
+		t.Fatal("Expected an empty transcript to fail the self-test")
+	}
+	health := srv.UseCase.Health()
+	model = health.Models[realtimetest.MockModel]
+	if health.Status != usecase.HealthDegraded || model.SelfTest == nil || model.SelfTest.Passed || model.SelfTest.Error != "empty transcript" {
+		t.Errorf("Expected the failed self-test to degrade the server, got %+v, %+v", health.Status, model.SelfTest)
+	}
+
+	srv.Provider.SetDelay(time.Second, 0)
+	asr.SelfTest.Timeout = 50 * time.Millisecond
+	if err := SelfTest(srv.UseCase, asr); err == nil {
+		t.Error("Expected a slow model to fail the self-test")
+	}
+}
@@ -0,0 +1,200 @@
+package transcription
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/aira-id/gribe/internal/delivery/gateway"
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/pkg/wav"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Accepted input sample rates
+const (
+	minSampleRate = 8000
+	maxSampleRate = 48000
+)
+
+// streamReadSize is how much of the request body is read at a time
+const streamReadSize = 32 * 1024
+
+// handleStream transcribes a chunked upload of raw PCM16 or WAV audio as it
+// arrives. The response is an NDJSON stream of TranscriptionChunk objects:
+// deltas while a turn is transcribed and one final chunk per turn, with
+// errors reported as {"error": {...}} lines.
+func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, domain.CodeMethodNotAllowed, "Only POST is supported")
+		return
+	}
+	principal := h.authenticate(w, r)
+	if principal == nil {
+		return
+	}
+
+	metadata, err := middleware.Metadata(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, domain.CodeInvalidValue, err.Error())
+		return
+	}
+
+	body := bufio.NewReaderSize(r.Body, streamReadSize)
+	format, err := inputFormat(r, body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, domain.CodeInvalidAudioFormat, err.Error())
+		return
+	}
+
+	// Respond while the upload is still being read
+	rc := http.NewResponseController(w)
+	if err := rc.EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("[WARN] Could not enable full-duplex streaming: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	clientIP := middleware.GetClientIP(r)
+	out := &chunkWriter{w: w, rc: rc, timer: newTurnTimer()}
+	query := r.URL.Query()
+	session := gateway.StartSession(h.UseCase, gateway.SessionOptions{
+		Model:    query.Get("model"),
+		Language: query.Get("language"),
+		APIKey:   principal.ID,
+		Tenant:   h.UseCase.ResolveTenant(principal.APIKey, principal.TenantClaim),
+		Label:    "HTTP stream from " + clientIP,
+		ClientIP: clientIP,
+		Metadata: metadata,
+		OnEvent:  out.handleEvent,
+	})
+
+	if _, err := feed(session, body, format); err != nil {
+		log.Printf("[WARN] HTTP stream from %s: %v", clientIP, err)
+	}
+	session.End()
+}
+
+// feed writes PCM16 audio of format read from r to session until EOF and
+// returns the number of bytes read
+func feed(session *gateway.Session, r io.Reader, format *wav.Format) (int64, error) {
+	resampler := gateway.NewResampler(format.SampleRate)
+	frameSize := 2 * format.Channels
+	buf := make([]byte, streamReadSize)
+	var total int64
+	var partial []byte // Incomplete frame carried to the next read
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			total += int64(n)
+			data := append(partial, buf[:n]...)
+			whole := len(data) / frameSize * frameSize
+			session.Write(resampler.Resample(gateway.Downmix(data[:whole], format.Channels)))
+			partial = append([]byte(nil), data[whole:]...)
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// inputFormat determines the sample format of the upload: a WAV header when
+// the content type says so, otherwise raw little-endian PCM16 described by the
+// sample_rate (default 16000) and channels (default 1) query parameters
+func inputFormat(r *http.Request, body *bufio.Reader) (*wav.Format, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "audio/wav", "audio/wave", "audio/x-wav", "audio/vnd.wave":
+		format, err := wav.ReadHeader(body)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSampleRate(format.SampleRate); err != nil {
+			return nil, err
+		}
+		return format, nil
+
+	case "", "audio/pcm", "application/octet-stream":
+		format := &wav.Format{SampleRate: gateway.SampleRate, Channels: 1, BitsPerSample: 16}
+		query := r.URL.Query()
+		if value := query.Get("sample_rate"); value != "" {
+			rate, err := strconv.Atoi(value)
+			if err != nil || rate < minSampleRate || rate > maxSampleRate {
+				return nil, fmt.Errorf("sample_rate must be %d-%d, got %q", minSampleRate, maxSampleRate, value)
+			}
+			format.SampleRate = rate
+		}
+		if value := query.Get("channels"); value != "" {
+			channels, err := strconv.Atoi(value)
+			if err != nil || channels < 1 || channels > 8 {
+				return nil, fmt.Errorf("channels must be 1-8, got %q", value)
+			}
+			format.Channels = channels
+		}
+		return format, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported content type %q, send audio/pcm or audio/wav", mediaType)
+	}
+}
+
+// checkSampleRate reports whether audio at rate can be transcribed
+func checkSampleRate(rate int) error {
+	if rate < minSampleRate || rate > maxSampleRate {
+		return fmt.Errorf("sample rate %dHz is not supported, use %d-%dHz", rate, minSampleRate, maxSampleRate)
+	}
+	return nil
+}
+
+// chunkWriter turns the session's server events into NDJSON lines. Events
+// arrive one at a time, see gateway.Conn.
+type chunkWriter struct {
+	w     http.ResponseWriter
+	rc    *http.ResponseController
+	timer *turnTimer
+}
+
+func (c *chunkWriter) handleEvent(eventType domain.EventType, raw []byte) {
+	c.timer.observe(eventType, raw)
+	switch eventType {
+	case domain.EventConversationItemInputAudioTranscriptionDelta:
+		var event domain.ConversationItemInputAudioTranscriptionDeltaEvent
+		if json.Unmarshal(raw, &event) == nil {
+			c.write(&domain.TranscriptionChunk{Text: event.Delta})
+		}
+
+	case domain.EventConversationItemInputAudioTranscriptionCompleted:
+		var event domain.ConversationItemInputAudioTranscriptionCompletedEvent
+		if json.Unmarshal(raw, &event) != nil {
+			return
+		}
+		chunk := &domain.TranscriptionChunk{Text: event.Transcript, IsFinal: true}
+		chunk.StartMs, chunk.EndMs = c.timer.finish(event.ItemID)
+		c.write(chunk)
+
+	case domain.EventError, domain.EventConversationItemInputAudioTranscriptionFailed:
+		var event domain.ErrorServerEvent
+		if json.Unmarshal(raw, &event) == nil && event.Error != nil {
+			c.write(map[string]interface{}{"error": event.Error})
+		}
+	}
+}
+
+// write sends one NDJSON line; a client that went away is noticed by the
+// upload failing
+func (c *chunkWriter) write(v interface{}) {
+	if err := json.NewEncoder(c.w).Encode(v); err != nil {
+		return
+	}
+	c.rc.Flush()
+}
@@ -0,0 +1,109 @@
+package transcription
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aira-id/gribe/internal/pkg/wav"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+// stereoTone returns ms of 48kHz stereo PCM16, a 1kHz square wave at amplitude
+func stereoTone(ms int, amplitude int16) []byte {
+	frames := 48 * ms
+	pcm := make([]byte, frames*4)
+	for i := 0; i < frames; i++ {
+		v := amplitude
+		if (i/24)%2 == 1 {
+			v = -amplitude
+		}
+		binary.LittleEndian.PutUint16(pcm[i*4:], uint16(v))
+		binary.LittleEndian.PutUint16(pcm[i*4+2:], uint16(v))
+	}
+	return pcm
+}
+
+func TestStreamTranscription(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	api := httptest.NewServer(NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth))
+	defer api.Close()
+
+	// Lead with more silence than the default prefix padding, and upload in
+	// odd-sized pieces so frames are split across reads
+	audio := append(stereoTone(500, 0), stereoTone(400, 8000)...)
+	upload, writer := io.Pipe()
+	go func() {
+		writer.Write(wav.Header(wav.Format{SampleRate: 48000, Channels: 2, BitsPerSample: 16}, len(audio)))
+		for len(audio) > 0 {
+			n := min(1001, len(audio))
+			writer.Write(audio[:n])
+			audio = audio[n:]
+		}
+		writer.Close()
+	}()
+
+	resp, err := http.Post(api.URL+"/v1/audio/transcriptions:stream?model="+realtimetest.MockModel+"&language=en",
+		"audio/wav", upload)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected an NDJSON stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	var finals []domain.TranscriptionChunk
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var chunk domain.TranscriptionChunk
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatalf("Invalid line %q: %v", scanner.Text(), err)
+		}
+		if chunk.IsFinal {
+			finals = append(finals, chunk)
+		}
+	}
+
+	if len(finals) != 1 || finals[0].Text != "hello" {
+		t.Fatalf("Expected one final chunk 'hello', got %+v", finals)
+	}
+	if finals[0].StartMs < 50 || finals[0].EndMs <= finals[0].StartMs {
+		t.Errorf("Expected the turn's timing after the leading silence, got %d-%dms", finals[0].StartMs, finals[0].EndMs)
+	}
+}
+
+func TestStreamRejectsInput(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	api := httptest.NewServer(NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth))
+	defer api.Close()
+
+	for _, tc := range []struct {
+		query, contentType, body string
+	}{
+		{"", "audio/mpeg", "ID3"},
+		{"", "audio/wav", "not a wav file"},
+		{"?sample_rate=4000", "audio/pcm", ""},
+		{"?channels=0", "audio/pcm", ""},
+	} {
+		resp, err := http.Post(api.URL+"/v1/audio/transcriptions:stream"+tc.query, tc.contentType, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s%s, got %d", tc.contentType, tc.query, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package watcher runs the directory watcher batch mode: WAV files dropped
+// into a directory are transcribed one at a time and a transcript file per
+// configured format (txt, json, srt) is written beside them, or into an output
+// directory, under the audio file's base name.
+//
+// A file is picked up once its size and modification time are unchanged
+// between two scans, so copies in progress are left alone. Files whose
+// transcripts all exist are skipped, so the watcher resumes after a restart
+// and a file is transcribed again by deleting its transcripts.
+package watcher
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/delivery/transcription"
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+)
+
+// Watcher metrics are shared by the watchers of the process
+var (
+	filesTranscribed = metrics.NewCounter("gribe_watch_files_transcribed_total",
+		"Audio files transcribed by the directory watcher")
+	filesFailed = metrics.NewCounter("gribe_watch_files_failed_total",
+		"Audio files the directory watcher could not transcribe")
+)
+
+// fileState identifies a version of a file
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher transcribes the audio files appearing in a directory
+type Watcher struct {
+	UseCase *usecase.SessionUsecase
+	Config  *config.WatchConfig
+
+	seen   map[string]fileState // Path -> state at the previous scan
+	failed map[string]fileState // Path -> state that failed, retried once changed
+	stop   chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+}
+
+// NewWatcher creates a directory watcher; call Start to begin scanning
+func NewWatcher(uc *usecase.SessionUsecase, cfg *config.WatchConfig) *Watcher {
+	return &Watcher{
+		UseCase: uc,
+		Config:  cfg,
+		seen:    make(map[string]fileState),
+		failed:  make(map[string]fileState),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start scans Config.Dir every Config.PollInterval in the background
+func (w *Watcher) Start() error {
+	if info, err := os.Stat(w.Config.Dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", w.Config.Dir)
+	}
+	if err := os.MkdirAll(w.outputDir(), 0755); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Watching %s for audio files, writing %s transcripts to %s",
+		w.Config.Dir, strings.Join(w.Config.Formats, "/"), w.outputDir())
+	w.wg.Add(1)
+	go w.run()
+	return nil
+}
+
+// Close stops scanning, waiting for the file being transcribed
+func (w *Watcher) Close() {
+	w.once.Do(func() { close(w.stop) })
+	w.wg.Wait()
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.scan()
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// scan transcribes the files that stopped changing since the previous scan
+func (w *Watcher) scan() {
+	entries, err := os.ReadDir(w.Config.Dir)
+	if err != nil {
+		log.Printf("[WARN] Could not scan %s: %v", w.Config.Dir, err)
+		return
+	}
+
+	seen := make(map[string]fileState)
+	var ready []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.EqualFold(filepath.Ext(name), ".wav") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(w.Config.Dir, name)
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		seen[path] = state
+
+		if previous, ok := w.seen[path]; !ok || previous != state {
+			continue // New or still being written
+		}
+		if failed, ok := w.failed[path]; ok && failed == state {
+			continue
+		}
+		if w.done(name) {
+			continue
+		}
+		ready = append(ready, path)
+	}
+	w.seen = seen
+	for path := range w.failed {
+		if _, ok := seen[path]; !ok {
+			delete(w.failed, path)
+		}
+	}
+
+	sort.Strings(ready)
+	for _, path := range ready {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+		if err := w.transcribe(path); err != nil {
+			log.Printf("[WARN] Could not transcribe %s: %v", path, err)
+			w.failed[path] = seen[path]
+			filesFailed.Inc()
+			continue
+		}
+		filesTranscribed.Inc()
+	}
+}
+
+// transcribe transcribes one audio file and writes its transcripts
+func (w *Watcher) transcribe(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	start := time.Now()
+	transcript, err := transcription.TranscribeWAV(w.UseCase, file, transcription.FileOptions{
+		Model:    w.Config.Model,
+		Language: w.Config.Language,
+		APIKey:   w.Config.APIKey,
+		Label:    "Watched file " + filepath.Base(path),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, format := range w.Config.Formats {
+		if err := w.writeTranscript(filepath.Base(path), format, transcript); err != nil {
+			return err
+		}
+	}
+	log.Printf("[INFO] Transcribed %s (%.1fs of audio, %d segments) in %v", path,
+		float64(transcript.DurationMs)/1000, len(transcript.Segments), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// writeTranscript writes the transcript file of format, replacing it at once
+// so readers never see a partial file
+func (w *Watcher) writeTranscript(audioName, format string, transcript *transcription.Transcript) error {
+	path := w.transcriptPath(audioName, format)
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := transcript.Write(tmp, format); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// done reports whether every transcript of the audio file exists
+func (w *Watcher) done(audioName string) bool {
+	for _, format := range w.Config.Formats {
+		if _, err := os.Stat(w.transcriptPath(audioName, format)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// transcriptPath returns where the transcript of format is written
+func (w *Watcher) transcriptPath(audioName, format string) string {
+	base := strings.TrimSuffix(audioName, filepath.Ext(audioName))
+	return filepath.Join(w.outputDir(), base+"."+format)
+}
+
+func (w *Watcher) outputDir() string {
+	if w.Config.OutputDir != "" {
+		return w.Config.OutputDir
+	}
+	return w.Config.Dir
+}
@@ -0,0 +1,93 @@
+package watcher
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/delivery/transcription"
+	"github.com/aira-id/gribe/internal/pkg/wav"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+// speechWAV returns a 16kHz mono WAV file: silence, a 1kHz square wave, silence
+func speechWAV() []byte {
+	var pcm []byte
+	for _, part := range []struct {
+		ms        int
+		amplitude int16
+	}{{100, 0}, {400, 8000}, {300, 0}} {
+		for i := 0; i < part.ms*16; i++ {
+			v := part.amplitude
+			if (i/8)%2 == 1 {
+				v = -v
+			}
+			pcm = binary.LittleEndian.AppendUint16(pcm, uint16(v))
+		}
+	}
+	return append(wav.Header(wav.Format{SampleRate: 16000, Channels: 1, BitsPerSample: 16}, len(pcm)), pcm...)
+}
+
+func TestWatcherTranscribesFiles(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	dir, out := t.TempDir(), t.TempDir()
+	w := NewWatcher(srv.UseCase, &config.WatchConfig{
+		Dir:          dir,
+		OutputDir:    out,
+		Formats:      []string{"txt", "json", "srt"},
+		PollInterval: time.Hour,
+		Model:        realtimetest.MockModel,
+		Language:     "en",
+	})
+	for name, data := range map[string][]byte{"call.wav": speechWAV(), "broken.wav": []byte("not a wav file"), "notes.txt": []byte("ignored")} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	transcribed, failed := filesTranscribed.Value(), filesFailed.Value()
+
+	// The first scan only notes the files, the second finds them unchanged
+	w.scan()
+	if entries, _ := os.ReadDir(out); len(entries) != 0 {
+		t.Fatalf("Expected files to wait a scan, got %d transcripts", len(entries))
+	}
+	w.scan()
+
+	text, err := os.ReadFile(filepath.Join(out, "call.txt"))
+	if err != nil || strings.TrimSpace(string(text)) != "hello" {
+		t.Fatalf("Expected call.txt with 'hello', got %q (%v)", text, err)
+	}
+	var transcript transcription.Transcript
+	data, _ := os.ReadFile(filepath.Join(out, "call.json"))
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		t.Fatalf("Invalid call.json: %v", err)
+	}
+	if len(transcript.Segments) != 1 || transcript.DurationMs != 800 {
+		t.Errorf("Expected one segment in 800ms of audio, got %+v", transcript)
+	}
+	if srt, _ := os.ReadFile(filepath.Join(out, "call.srt")); !strings.HasPrefix(string(srt), "1\n00:00:00,") {
+		t.Errorf("Expected an SRT cue, got %q", srt)
+	}
+	if _, err := os.Stat(filepath.Join(out, "broken.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected no transcript for an invalid file, got %v", err)
+	}
+	if filesTranscribed.Value()-transcribed != 1 || filesFailed.Value()-failed != 1 {
+		t.Errorf("Expected 1 transcribed and 1 failed file, got %d and %d", filesTranscribed.Value()-transcribed, filesFailed.Value()-failed)
+	}
+
+	// Transcribed and failed files are not retried while unchanged
+	w.scan()
+	if filesTranscribed.Value()-transcribed != 1 || filesFailed.Value()-failed != 1 {
+		t.Errorf("Expected no retries, got %d transcribed and %d failed", filesTranscribed.Value()-transcribed, filesFailed.Value()-failed)
+	}
+}
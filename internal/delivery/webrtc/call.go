@@ -0,0 +1,192 @@
+package webrtc
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/aira-id/gribe/internal/delivery/gateway"
+	"github.com/aira-id/gribe/internal/pkg/g711"
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/pion/opus"
+	"github.com/pion/webrtc/v4"
+)
+
+// chunkBytes is the track audio batched into each input_audio_buffer.append,
+// 100ms at gateway.SampleRate
+const chunkBytes = gateway.SampleRate * 2 / 10
+
+// opusFrameBytes holds one decoded 20ms Opus frame of 48kHz PCM16
+const opusFrameBytes = 48000 * 2 / 50
+
+// call is one peer connection and the session it feeds. Client events arrive
+// as data channel text messages, track audio is decoded to PCM16 and appended,
+// and server events are sent back on the data channel.
+type call struct {
+	handler *Handler
+	pc      *webrtc.PeerConnection
+	conn    *gateway.Conn
+	opts    usecase.ConnectOptions
+	label   string
+
+	mu       sync.Mutex
+	channel  *webrtc.DataChannel
+	hasTrack bool
+	once     sync.Once
+}
+
+func newCall(h *Handler, pc *webrtc.PeerConnection, opts usecase.ConnectOptions, label string) *call {
+	c := &call{handler: h, pc: pc, opts: opts, label: label}
+	c.conn = gateway.NewConn(c.sendEvent)
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateDisconnected, webrtc.PeerConnectionStateClosed:
+			go c.end()
+		}
+	})
+	pc.OnDataChannel(c.handleDataChannel)
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		if track.Kind() != webrtc.RTPCodecTypeAudio {
+			return
+		}
+		c.mu.Lock()
+		first := !c.hasTrack
+		c.hasTrack = true
+		c.mu.Unlock()
+		if !first {
+			log.Printf("[WARN] %s: ignoring additional audio track %s", label, track.ID())
+			return
+		}
+		go c.readTrack(track)
+	})
+	return c
+}
+
+// handleDataChannel starts the session once the client's event channel opens
+func (c *call) handleDataChannel(dc *webrtc.DataChannel) {
+	c.mu.Lock()
+	if c.channel != nil {
+		c.mu.Unlock()
+		log.Printf("[WARN] %s: ignoring additional data channel %q", c.label, dc.Label())
+		return
+	}
+	c.channel = dc
+	c.mu.Unlock()
+
+	dc.OnOpen(func() {
+		// Track audio is appended as PCM16 at the rate the providers decode
+		c.conn.Send(map[string]interface{}{
+			"type": domain.EventSessionUpdate,
+			"session": map[string]interface{}{"audio": map[string]interface{}{"input": map[string]interface{}{
+				"format": &domain.AudioFormat{Type: "audio/pcm", Rate: gateway.SampleRate},
+			}}},
+		})
+		go func() {
+			defer c.end()
+			defer panics.Recover(c.label, nil)
+			c.handler.UseCase.HandleNewConnectionWithOptions(c.conn, c.opts)
+		}()
+	})
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		if msg.IsString {
+			c.conn.SendRaw(msg.Data)
+		} else {
+			// Binary messages carry gateway.SampleRate PCM16 for clients without a track
+			c.conn.AppendAudio(msg.Data)
+		}
+	})
+	dc.OnClose(func() { go c.end() })
+}
+
+// sendEvent relays a server event to the client
+func (c *call) sendEvent(_ domain.EventType, raw []byte) {
+	c.mu.Lock()
+	dc := c.channel
+	c.mu.Unlock()
+	if dc == nil {
+		return
+	}
+	if err := dc.SendText(string(raw)); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		log.Printf("[WARN] %s: sending event: %v", c.label, err)
+	}
+}
+
+// readTrack decodes the audio track and appends it to the session
+func (c *call) readTrack(track *webrtc.TrackRemote) {
+	codec := track.Codec().MimeType
+	log.Printf("%s: receiving %s audio", c.label, codec)
+
+	var decode func(payload []byte) ([]byte, error)
+	switch {
+	case codecIs(codec, webrtc.MimeTypePCMU):
+		decode = func(payload []byte) ([]byte, error) {
+			return gateway.Upsample2x(g711.UlawToPCM16(payload)), nil
+		}
+	case codecIs(codec, webrtc.MimeTypePCMA):
+		decode = func(payload []byte) ([]byte, error) {
+			return gateway.Upsample2x(g711.AlawToPCM16(payload)), nil
+		}
+	case codecIs(codec, webrtc.MimeTypeOpus):
+		decoder := opus.NewDecoder()
+		resampler := gateway.NewResampler(48000)
+		frame := make([]byte, opusFrameBytes)
+		decode = func(payload []byte) ([]byte, error) {
+			if _, _, err := decoder.Decode(payload, frame); err != nil {
+				return nil, err
+			}
+			return resampler.Resample(frame), nil
+		}
+	default:
+		log.Printf("[WARN] %s: unsupported codec %s", c.label, codec)
+		return
+	}
+
+	var pending []byte
+	warned := false
+	for {
+		packet, _, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		if len(packet.Payload) == 0 {
+			continue
+		}
+		pcm, err := decode(packet.Payload)
+		if err != nil {
+			if !warned {
+				log.Printf("[WARN] %s: dropping undecodable %s packets: %v", c.label, codec, err)
+				warned = true
+			}
+			continue
+		}
+		pending = append(pending, pcm...)
+		if len(pending) >= chunkBytes {
+			if c.conn.AppendAudio(pending) != nil {
+				return
+			}
+			pending = nil
+		}
+	}
+}
+
+// end closes the session and the peer connection
+func (c *call) end() {
+	c.once.Do(func() {
+		c.conn.Close()
+		if err := c.pc.Close(); err != nil {
+			log.Printf("[WARN] %s: closing peer connection: %v", c.label, err)
+		}
+		c.handler.remove(c)
+		log.Printf("%s ended", c.label)
+	})
+}
+
+// codecIs compares MIME types, which are case-insensitive
+func codecIs(mimeType, want string) bool {
+	return strings.EqualFold(mimeType, want)
+}
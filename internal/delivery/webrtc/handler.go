@@ -0,0 +1,243 @@
+// Package webrtc accepts realtime sessions over WebRTC. A client posts an SDP
+// offer to POST /v1/realtime/calls and receives the answer; microphone audio
+// then arrives as a media track and the usual client and server events are
+// exchanged as JSON text messages over a data channel, without base64 audio.
+//
+// Opus tracks are decoded in pure Go, which handles SILK frames only. The
+// answer therefore caps the Opus playback rate at 16kHz, which makes
+// encoders use wideband SILK. PCMU and PCMA tracks are accepted as well.
+package webrtc
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/pion/ice/v4"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	maxOfferSize     = 64 * 1024        // Largest SDP offer accepted
+	gatheringTimeout = 10 * time.Second // Time to gather candidates before answering
+)
+
+// Handler handles WebRTC signaling requests
+type Handler struct {
+	UseCase *usecase.SessionUsecase
+	Config  *config.Config
+	Auth    *middleware.Authenticator
+	api     *webrtc.API
+
+	mu    sync.Mutex
+	calls map[*call]bool
+}
+
+// callsActive counts the connected peers of all handlers in the process
+var callsActive = metrics.NewGauge("gribe_webrtc_calls_active", "Currently connected WebRTC sessions")
+
+// NewHandler creates the signaling handler; mount it at /v1/realtime/calls
+func NewHandler(uc *usecase.SessionUsecase, cfg *config.Config, auth *middleware.Authenticator) (*Handler, error) {
+	media := &webrtc.MediaEngine{}
+	for _, codec := range []webrtc.RTPCodecParameters{
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, PayloadType: 111},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMU, ClockRate: 8000}, PayloadType: 0},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMA, ClockRate: 8000}, PayloadType: 8},
+	} {
+		if err := media.RegisterCodec(codec, webrtc.RTPCodecTypeAudio); err != nil {
+			return nil, err
+		}
+	}
+
+	settings := webrtc.SettingEngine{}
+	settings.SetICEMulticastDNSMode(ice.MulticastDNSModeDisabled)
+	if len(cfg.WebRTC.PublicIPs) > 0 {
+		settings.SetNAT1To1IPs(cfg.WebRTC.PublicIPs, webrtc.ICECandidateTypeHost)
+	}
+	if cfg.WebRTC.UDPPortMin > 0 {
+		if err := settings.SetEphemeralUDPPortRange(uint16(cfg.WebRTC.UDPPortMin), uint16(cfg.WebRTC.UDPPortMax)); err != nil {
+			return nil, fmt.Errorf("webrtc UDP port range: %w", err)
+		}
+	}
+
+	h := &Handler{
+		UseCase: uc,
+		Config:  cfg,
+		Auth:    auth,
+		api:     webrtc.NewAPI(webrtc.WithMediaEngine(media), webrtc.WithSettingEngine(settings)),
+		calls:   make(map[*call]bool),
+	}
+	return h, nil
+}
+
+// ActiveCalls returns the number of connected peers
+func (h *Handler) ActiveCalls() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.calls)
+}
+
+// ServeHTTP answers an SDP offer sent as application/sdp
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientIP := middleware.GetClientIP(r)
+	principal, err := h.Auth.Authenticate(r)
+	if err != nil {
+		log.Printf("Invalid credentials from IP %s: %v", clientIP, err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !principal.HasScope(config.ScopeRealtimeTranscribe) {
+		log.Printf("Credentials without %s scope from IP: %s", config.ScopeRealtimeTranscribe, clientIP)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	metadata, err := middleware.Metadata(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	offer, err := io.ReadAll(io.LimitReader(r.Body, maxOfferSize+1))
+	if err != nil || len(offer) > maxOfferSize {
+		http.Error(w, "Invalid SDP offer", http.StatusBadRequest)
+		return
+	}
+	if !strings.Contains(string(offer), "m=application") {
+		http.Error(w, "The offer must include a data channel for events", http.StatusBadRequest)
+		return
+	}
+
+	pc, err := h.api.NewPeerConnection(webrtc.Configuration{
+		ICEServers: []webrtc.ICEServer{{URLs: h.Config.WebRTC.ICEServers}},
+	})
+	if err != nil {
+		log.Printf("[WARN] WebRTC peer connection failed: %v", err)
+		http.Error(w, "Could not create peer connection", http.StatusInternalServerError)
+		return
+	}
+
+	intent := usecase.IntentRealtime
+	if r.URL.Query().Get("intent") == "transcription" {
+		intent = usecase.IntentTranscription
+	}
+	c := newCall(h, pc, usecase.ConnectOptions{
+		Intent:   intent,
+		APIKey:   principal.ID,
+		Tenant:   h.UseCase.ResolveTenant(principal.APIKey, principal.TenantClaim),
+		ClientIP: clientIP,
+		Metadata: metadata,
+	}, "WebRTC session from "+clientIP)
+
+	answer, err := h.answer(pc, string(offer))
+	if err != nil {
+		c.end()
+		log.Printf("[WARN] WebRTC offer from %s rejected: %v", clientIP, err)
+		http.Error(w, "Invalid SDP offer: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	h.calls[c] = true
+	h.mu.Unlock()
+	callsActive.Inc()
+
+	log.Printf("Accepted WebRTC offer from IP: %s", clientIP)
+	w.Header().Set("Content-Type", "application/sdp")
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(answer))
+}
+
+// answer applies the offer and returns the answer with all candidates
+func (h *Handler) answer(pc *webrtc.PeerConnection, offer string) (string, error) {
+	// The answer echoes the offer's Opus parameters and may not be edited itself
+	offer = limitOpusBandwidth(offer)
+	if err := pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offer}); err != nil {
+		return "", err
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return "", err
+	}
+
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return "", err
+	}
+	select {
+	case <-gathered:
+	case <-time.After(gatheringTimeout):
+		log.Printf("[WARN] WebRTC candidate gathering timed out, answering with the candidates found")
+	}
+	return pc.LocalDescription().SDP, nil
+}
+
+// remove forgets an ended call
+func (h *Handler) remove(c *call) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.calls[c] {
+		delete(h.calls, c)
+		callsActive.Dec()
+	}
+}
+
+// Close ends all calls
+func (h *Handler) Close() {
+	h.mu.Lock()
+	calls := make([]*call, 0, len(h.calls))
+	for c := range h.calls {
+		calls = append(calls, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range calls {
+		c.end()
+	}
+}
+
+var opusRtpmap = regexp.MustCompile(`(?mi)^a=rtpmap:(\d+) opus/48000`)
+
+// limitOpusBandwidth asks the sender to encode Opus for 16kHz playback, which
+// keeps it in the SILK mode the decoder supports
+func limitOpusBandwidth(sdp string) string {
+	match := opusRtpmap.FindStringSubmatch(sdp)
+	if match == nil {
+		return sdp
+	}
+	const params = "maxplaybackrate=16000;sprop-maxcapturerate=16000"
+
+	fmtp := "a=fmtp:" + match[1] + " "
+	lines := strings.Split(sdp, "\r\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, fmtp) {
+			if !strings.Contains(line, "maxplaybackrate") {
+				lines[i] = line + ";" + params
+			}
+			return strings.Join(lines, "\r\n")
+		}
+	}
+	// No fmtp line yet, add one after the rtpmap
+	for i, line := range lines {
+		if opusRtpmap.MatchString(line) {
+			lines = append(lines[:i+1], append([]string{fmtp + params}, lines[i+1:]...)...)
+			break
+		}
+	}
+	return strings.Join(lines, "\r\n")
+}
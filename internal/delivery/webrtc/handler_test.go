@@ -0,0 +1,218 @@
+package webrtc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+	"github.com/pion/ice/v4"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// ulawTone returns ms of 8kHz G.711 u-law audio, a 1kHz square wave or
+// silence
+func ulawTone(ms int, loud bool) []byte {
+	audio := make([]byte, ms*8)
+	for i := range audio {
+		switch {
+		case !loud:
+			audio[i] = 0xFF
+		case i/4%2 == 0:
+			audio[i] = 0x9F
+		default:
+			audio[i] = 0x1F
+		}
+	}
+	return audio
+}
+
+// dialCall connects a peer sending a PCMU track and an event channel to the
+// handler and returns the peer, the channel and the events received on it
+func dialCall(t *testing.T, url string) (*webrtc.PeerConnection, *webrtc.TrackLocalStaticSample, *webrtc.DataChannel, chan []byte, chan struct{}) {
+	t.Helper()
+	engine := &webrtc.MediaEngine{}
+	if err := engine.RegisterDefaultCodecs(); err != nil {
+		t.Fatal(err)
+	}
+	settings := webrtc.SettingEngine{}
+	settings.SetICEMulticastDNSMode(ice.MulticastDNSModeDisabled)
+	settings.SetIncludeLoopbackCandidate(true)
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(engine), webrtc.WithSettingEngine(settings))
+
+	pc, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMU, ClockRate: 8000}, "audio", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.AddTrack(track); err != nil {
+		t.Fatal(err)
+	}
+	dc, err := pc.CreateDataChannel("events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan []byte, 256)
+	opened := make(chan struct{})
+	dc.OnOpen(func() { close(opened) })
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) { events <- msg.Data })
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(pc.LocalDescription().SDP))
+	req.Header.Set("Content-Type", "application/sdp")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Content-Type") != "application/sdp" {
+		t.Fatalf("Expected an SDP answer, got %d: %s", resp.StatusCode, answer)
+	}
+	if !strings.Contains(string(answer), "maxplaybackrate=16000") {
+		t.Errorf("Expected the answer to cap the Opus playback rate:\n%s", answer)
+	}
+	if err := pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: string(answer)}); err != nil {
+		t.Fatalf("Invalid answer: %v", err)
+	}
+	return pc, track, dc, events, opened
+}
+
+// expectEvent returns the next event of type eventType received within 5s
+func expectEvent(t *testing.T, events chan []byte, eventType domain.EventType) []byte {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case raw := <-events:
+			var base domain.BaseEvent
+			if json.Unmarshal(raw, &base) == nil && base.Type == eventType {
+				return raw
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s", eventType)
+		}
+	}
+}
+
+func TestWebRTCTranscription(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	handler, err := NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	defer handler.Close()
+	api := httptest.NewServer(handler)
+	defer api.Close()
+
+	pc, track, dc, events, opened := dialCall(t, api.URL+"?intent=transcription")
+	defer pc.Close()
+
+	select {
+	case <-opened:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the data channel")
+	}
+	expectEvent(t, events, domain.EventTranscriptionSessionCreated)
+	if err := dc.SendText(`{"type":"transcription_session.update","session":{"input_audio_transcription":{"model":"` + realtimetest.MockModel +
+		`","language":"en"},"turn_detection":{"type":"server_vad","threshold":0.5,"prefix_padding_ms":0,"silence_duration_ms":200}}}`); err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	expectEvent(t, events, domain.EventTranscriptionSessionUpdated)
+	if handler.ActiveCalls() != 1 {
+		t.Errorf("Expected 1 active call, got %d", handler.ActiveCalls())
+	}
+
+	// Silence to calibrate the noise floor, a burst of speech, then enough
+	// silence to end the turn, in 20ms packets
+	audio := append(append(ulawTone(100, false), ulawTone(400, true)...), ulawTone(600, false)...)
+	for len(audio) > 0 {
+		if err := track.WriteSample(media.Sample{Data: audio[:160], Duration: 20 * time.Millisecond}); err != nil {
+			t.Fatalf("WriteSample failed: %v", err)
+		}
+		audio = audio[160:]
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var completed domain.ConversationItemInputAudioTranscriptionCompletedEvent
+	if err := json.Unmarshal(expectEvent(t, events, domain.EventConversationItemInputAudioTranscriptionCompleted), &completed); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if completed.Transcript != "hello" {
+		t.Errorf("Expected transcript 'hello', got %q", completed.Transcript)
+	}
+
+	pc.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for handler.ActiveCalls() != 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if handler.ActiveCalls() != 0 {
+		t.Errorf("Expected the call to end with the peer, %d still active", handler.ActiveCalls())
+	}
+}
+
+func TestWebRTCRejectsOffer(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	handler, err := NewHandler(srv.UseCase, srv.Config, srv.Handler.Auth)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	api := httptest.NewServer(handler)
+	defer api.Close()
+
+	for _, tc := range []struct {
+		method, body string
+		status       int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "v=0\r\nm=audio 9 UDP/TLS/RTP/SAVPF 0\r\n", http.StatusBadRequest},
+		{http.MethodPost, "v=0\r\nm=application 9 UDP/DTLS/SCTP webrtc-datachannel\r\n", http.StatusBadRequest},
+	} {
+		req, _ := http.NewRequest(tc.method, api.URL, strings.NewReader(tc.body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("Expected %d for %s %q, got %d", tc.status, tc.method, tc.body, resp.StatusCode)
+		}
+	}
+}
+
+func TestLimitOpusBandwidth(t *testing.T) {
+	sdp := "m=audio 9 UDP/TLS/RTP/SAVPF 111\r\na=rtpmap:111 opus/48000/2\r\na=fmtp:111 minptime=10;useinbandfec=1\r\n"
+	got := limitOpusBandwidth(sdp)
+	if !strings.Contains(got, "a=fmtp:111 minptime=10;useinbandfec=1;maxplaybackrate=16000;sprop-maxcapturerate=16000\r\n") {
+		t.Errorf("Expected the fmtp line to cap the playback rate, got %q", got)
+	}
+
+	got = limitOpusBandwidth("a=rtpmap:109 opus/48000/2\r\n")
+	if got != "a=rtpmap:109 opus/48000/2\r\na=fmtp:109 maxplaybackrate=16000;sprop-maxcapturerate=16000\r\n" {
+		t.Errorf("Expected an fmtp line to be added, got %q", got)
+	}
+}
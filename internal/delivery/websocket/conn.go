@@ -0,0 +1,238 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/gorilla/websocket"
+)
+
+// writeTimeout bounds how long a batch of outbound messages may block on a
+// slow client before the connection is treated as dead
+const writeTimeout = 10 * time.Second
+
+// writeQueueSize is how many outbound messages may wait for the writer before
+// senders block
+const writeQueueSize = 256
+
+// maxBatchSize is how many delta events a batched frame holds at most
+const maxBatchSize = 64
+
+// errConnClosed is returned for writes after the connection was closed
+var errConnClosed = errors.New("websocket connection closed")
+
+// outbound is a message queued for the writer goroutine
+type outbound struct {
+	messageType int
+	data        []byte
+	delta       bool // A delta event, which may wait to be batched
+}
+
+// SafeConn wraps a WebSocket connection so that any goroutine can write to
+// it. Messages are queued to a single writer goroutine per connection, which
+// keeps them in the order they were sent and applies write deadlines.
+//
+// With batching, delta events, written as usecase.Delta, are held for up to the batch interval and sent
+// together as a JSON array in one frame, saving the frame and syscall per
+// delta of fast streaming. Any other message sends the held deltas first, so
+// the order of events is kept.
+type SafeConn struct {
+	conn     *websocket.Conn
+	send     chan outbound
+	stop     chan struct{} // Closed by Close
+	done     chan struct{} // Closed when the writer exits
+	stopOnce sync.Once
+	err      error // Why the writer exited, set before done is closed
+
+	batchInterval time.Duration // How long deltas may wait for a batch, 0 sends each in its own frame
+	batch         [][]byte      // Deltas held for the next batch, used by the writer only
+}
+
+// NewSafeConn creates a new WebSocket connection wrapper and starts its writer
+func NewSafeConn(conn *websocket.Conn) *SafeConn {
+	return NewSafeConnWithBatching(conn, 0)
+}
+
+// NewSafeConnWithBatching creates a connection wrapper sending the delta
+// events of each interval batched in one frame; 0 disables batching
+func NewSafeConnWithBatching(conn *websocket.Conn, interval time.Duration) *SafeConn {
+	sc := &SafeConn{
+		conn:          conn,
+		send:          make(chan outbound, writeQueueSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+		batchInterval: interval,
+	}
+	go sc.writeLoop()
+	return sc
+}
+
+// WriteJSON queues v for the writer. It is encoded immediately, so the caller
+// may modify v once WriteJSON returns.
+func (sc *SafeConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, delta := v.(usecase.Delta)
+	return sc.enqueue(outbound{messageType: websocket.TextMessage, data: data, delta: delta && sc.batchInterval > 0})
+}
+
+// ReadMessage reads a message from the connection
+func (sc *SafeConn) ReadMessage() (messageType int, p []byte, err error) {
+	return sc.conn.ReadMessage()
+}
+
+// CloseWithReason queues a close frame with the given code and reason, after
+// any messages already sent
+func (sc *SafeConn) CloseWithReason(code int, reason string) error {
+	return sc.enqueue(outbound{messageType: websocket.CloseMessage, data: websocket.FormatCloseMessage(code, reason)})
+}
+
+// Close flushes queued messages, waiting at most writeTimeout, and closes the
+// underlying connection
+func (sc *SafeConn) Close() error {
+	sc.stopOnce.Do(func() { close(sc.stop) })
+	<-sc.done
+	if sc.err == errConnClosed {
+		return nil
+	}
+	return sc.err
+}
+
+// Conn returns the underlying websocket connection (use with caution)
+func (sc *SafeConn) Conn() *websocket.Conn {
+	return sc.conn
+}
+
+func (sc *SafeConn) enqueue(m outbound) error {
+	select {
+	case <-sc.stop:
+		return errConnClosed
+	default:
+	}
+	select {
+	case sc.send <- m:
+		return nil
+	case <-sc.stop:
+		return errConnClosed
+	case <-sc.done:
+		return sc.err
+	}
+}
+
+// writeLoop is the only goroutine writing to the connection. Messages that
+// queued up while it was writing go out as one batch under a shared deadline.
+func (sc *SafeConn) writeLoop() {
+	defer close(sc.done)
+	var batchDue <-chan time.Time // Fires when the held deltas must be sent, nil while none are
+	for {
+		select {
+		case m := <-sc.send:
+			if err := sc.flush(m, time.Now().Add(writeTimeout)); err != nil {
+				sc.err = err
+				sc.conn.Close()
+				return
+			}
+			if len(sc.batch) == 0 {
+				// Sent along with other messages or for being full
+				batchDue = nil
+			} else if batchDue == nil {
+				// The first delta of a batch waits at most the interval
+				batchDue = time.After(sc.batchInterval)
+			}
+		case <-batchDue:
+			batchDue = nil
+			if err := sc.writeBatch(time.Now().Add(writeTimeout)); err != nil {
+				sc.err = err
+				sc.conn.Close()
+				return
+			}
+		case <-sc.stop:
+			sc.err = errConnClosed
+			deadline := time.Now().Add(writeTimeout)
+			var err error
+			select {
+			case m := <-sc.send:
+				err = sc.flush(m, deadline)
+			default:
+			}
+			if err == nil {
+				err = sc.writeBatch(deadline)
+			}
+			if err != nil {
+				sc.err = err
+			}
+			if err := sc.conn.Close(); err != nil && sc.err == errConnClosed {
+				sc.err = err
+			}
+			return
+		}
+	}
+}
+
+// flush writes m and every message queued behind it, holding deltas back for
+// the batch
+func (sc *SafeConn) flush(m outbound, deadline time.Time) error {
+	if err := sc.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	for {
+		if err := sc.write(m, deadline); err != nil {
+			return err
+		}
+		select {
+		case m = <-sc.send:
+		default:
+			return nil
+		}
+	}
+}
+
+func (sc *SafeConn) write(m outbound, deadline time.Time) error {
+	if m.delta {
+		sc.batch = append(sc.batch, m.data)
+		if len(sc.batch) < maxBatchSize {
+			return nil
+		}
+		return sc.writeBatch(deadline)
+	}
+	if err := sc.writeBatch(deadline); err != nil {
+		return err
+	}
+	if m.messageType == websocket.CloseMessage {
+		return sc.conn.WriteControl(m.messageType, m.data, deadline)
+	}
+	return sc.conn.WriteMessage(m.messageType, m.data)
+}
+
+// writeBatch sends the held deltas, as a JSON array when there are several
+func (sc *SafeConn) writeBatch(deadline time.Time) error {
+	if len(sc.batch) == 0 {
+		return nil
+	}
+	batch := sc.batch
+	sc.batch = nil
+	if err := sc.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	if len(batch) == 1 {
+		return sc.conn.WriteMessage(websocket.TextMessage, batch[0])
+	}
+	size := len(batch) + 1
+	for _, data := range batch {
+		size += len(data)
+	}
+	frame := make([]byte, 0, size)
+	frame = append(frame, '[')
+	for i, data := range batch {
+		if i > 0 {
+			frame = append(frame, ',')
+		}
+		frame = append(frame, data...)
+	}
+	return sc.conn.WriteMessage(websocket.TextMessage, append(frame, ']'))
+}
@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/gorilla/websocket"
+)
+
+func TestSafeConnOrdering(t *testing.T) {
+	const writers, perWriter = 8, 200
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		sc := NewSafeConn(conn)
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(writer int) {
+				defer wg.Done()
+				for seq := 0; seq < perWriter; seq++ {
+					sc.WriteJSON(map[string]int{"writer": writer, "seq": seq})
+				}
+			}(i)
+		}
+		wg.Wait()
+		sc.CloseWithReason(websocket.ClosePolicyViolation, "done")
+		sc.Close()
+		if err := sc.WriteJSON("late"); err == nil {
+			t.Error("Expected a write after Close to fail")
+		}
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	next := make([]int, writers)
+	for received := 0; ; received++ {
+		var msg struct{ Writer, Seq int }
+		if err := client.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+				t.Fatalf("Expected the close frame after all messages, got %v", err)
+			}
+			if received != writers*perWriter {
+				t.Fatalf("Received %d messages, want %d", received, writers*perWriter)
+			}
+			return
+		}
+		if msg.Seq != next[msg.Writer] {
+			t.Fatalf("Writer %d: got seq %d, want %d", msg.Writer, msg.Seq, next[msg.Writer])
+		}
+		next[msg.Writer]++
+	}
+}
+
+func TestSafeConnBatching(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		sc := NewSafeConnWithBatching(conn, 200*time.Millisecond)
+		delta := func(data string) usecase.Delta { return usecase.Delta{RawMessage: json.RawMessage(data)} }
+		for i := 0; i < 3; i++ {
+			sc.WriteJSON(delta(fmt.Sprintf(`{"delta":%d,"type":"response.output_text.delta"}`, i)))
+		}
+		time.Sleep(10 * time.Millisecond) // The deltas start a batch
+		sc.WriteJSON(map[string]string{"type": "response.output_text.done"})
+
+		// The timer of the batch just sent is dropped, so the next delta
+		// waits the full interval for company
+		time.Sleep(100 * time.Millisecond)
+		sc.WriteJSON(delta(`{"delta":3,"type":"response.output_text.delta"}`))
+		time.Sleep(150 * time.Millisecond)
+		sc.WriteJSON(delta(`{"delta":4,"type":"response.output_text.delta"}`))
+		time.Sleep(300 * time.Millisecond)                                    // The batch goes out with the interval
+		sc.WriteJSON(map[string]string{"type": "response.output_text.delta"}) // Not tagged as a delta
+		sc.WriteJSON(map[string]string{"type": "response.done"})
+		sc.Close()
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	var frames []string
+	for {
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			break
+		}
+		frames = append(frames, string(data))
+	}
+	want := []string{
+		`[{"delta":0,"type":"response.output_text.delta"},{"delta":1,"type":"response.output_text.delta"},{"delta":2,"type":"response.output_text.delta"}]`,
+		`{"type":"response.output_text.done"}`,
+		`[{"delta":3,"type":"response.output_text.delta"},{"delta":4,"type":"response.output_text.delta"}]`,
+		`{"type":"response.output_text.delta"}`,
+		`{"type":"response.done"}`,
+	}
+	if strings.Join(frames, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected frames\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(frames, "\n"))
+	}
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/gorilla/websocket"
+)
+
+// evictionReason is the close reason sent to a connection evicted for a new
+// one from the same IP
+const evictionReason = "idle connection replaced by a new connection from the same IP"
+
+// connTracker keeps the open connections of each client IP so that, under
+// the evict_idle connection limit policy, a client at the per-IP limit can
+// take over the slot of its connection that has been idle longest, as
+// clients that crashed and reconnect need
+type connTracker struct {
+	mu   sync.Mutex
+	byIP map[string]map[*trackedConn]struct{}
+}
+
+// trackedConn is a connection of a connTracker, recording when its client
+// last sent a message
+type trackedConn struct {
+	usecase.Conn
+	safeConn *SafeConn
+	ip       string
+	activity atomic.Int64 // Unix nanoseconds of the latest client message
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{byIP: make(map[string]map[*trackedConn]struct{})}
+}
+
+// add tracks conn, a connection from ip written through safeConn
+func (t *connTracker) add(conn usecase.Conn, safeConn *SafeConn, ip string) *trackedConn {
+	c := &trackedConn{Conn: conn, safeConn: safeConn, ip: ip}
+	c.activity.Store(time.Now().UnixNano())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.byIP[ip] == nil {
+		t.byIP[ip] = make(map[*trackedConn]struct{})
+	}
+	t.byIP[ip][c] = struct{}{}
+	return c
+}
+
+// remove stops tracking a closed connection and reports whether it still
+// held its connection slot, false if it was evicted and the slot passed on
+func (t *connTracker) remove(c *trackedConn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns := t.byIP[c.ip]
+	if _, ok := conns[c]; !ok {
+		return false
+	}
+	delete(conns, c)
+	if len(conns) == 0 {
+		delete(t.byIP, c.ip)
+	}
+	return true
+}
+
+// evictIdle closes the connection from ip that has been idle longest, if it
+// has been idle at least minIdle, and reports whether it did. The evicted
+// connection's slot passes to the caller.
+func (t *connTracker) evictIdle(ip string, minIdle time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := time.Now().Add(-minIdle).UnixNano()
+	var victim *trackedConn
+	for c := range t.byIP[ip] {
+		if last := c.activity.Load(); last <= cutoff && (victim == nil || last < victim.activity.Load()) {
+			victim = c
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	delete(t.byIP[ip], victim)
+
+	go func() {
+		victim.safeConn.CloseWithReason(websocket.ClosePolicyViolation, evictionReason)
+		victim.safeConn.Close()
+	}()
+	return true
+}
+
+// ReadMessage records the client's activity
+func (c *trackedConn) ReadMessage() (int, []byte, error) {
+	messageType, message, err := c.Conn.ReadMessage()
+	if err == nil {
+		c.activity.Store(time.Now().UnixNano())
+	}
+	return messageType, message, err
+}
@@ -3,21 +3,43 @@ package websocket
 import (
 	"log"
 	"net/http"
-	"strings"
-	"sync"
+	"strconv"
+	"sync/atomic"
+	"time"
 
-	"github.com/aira-id/gribe/internal/config"
+	"github.com/aira-id/gribe/internal/metrics"
 	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	"github.com/aira-id/gribe/internal/pkg/recording"
 	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
 	"github.com/gorilla/websocket"
 )
 
+// sessionRetryAfterSeconds is sent in Retry-After when the session cap or
+// memory budget is reached
+const sessionRetryAfterSeconds = "5"
+
+// Session metrics are registered once and shared by every handler of the
+// process, so a server embedding several keeps them all counted
+var (
+	sessionsActive   = metrics.NewGauge("gribe_sessions_active", "Currently open realtime sessions")
+	sessionsMax      = metrics.NewGauge("gribe_sessions_max", "Server-wide session cap (0 = unlimited)")
+	sessionsRejected = metrics.NewCounter("gribe_sessions_rejected_total",
+		"Connections rejected because the server-wide session cap was reached")
+)
+
 // Handler handles WebSocket connections
 type Handler struct {
 	UseCase     *usecase.SessionUsecase
 	Config      *config.Config
 	RateLimiter *middleware.RateLimiter
+	Auth        *middleware.Authenticator
 	upgrader    websocket.Upgrader
+	connections *connTracker // Open connections per IP, for the evict_idle policy
+
+	activeSessions int64 // accessed atomically
 }
 
 // NewHandler creates a new WebSocket handler
@@ -26,6 +48,8 @@ func NewHandler(uc *usecase.SessionUsecase, cfg *config.Config) *Handler {
 		UseCase:     uc,
 		Config:      cfg,
 		RateLimiter: middleware.NewRateLimiter(&cfg.Rate),
+		Auth:        middleware.NewAuthenticator(cfg),
+		connections: newConnTracker(),
 	}
 
 	h.upgrader = websocket.Upgrader{
@@ -34,9 +58,37 @@ func NewHandler(uc *usecase.SessionUsecase, cfg *config.Config) *Handler {
 		WriteBufferSize: 1024,
 	}
 
+	sessionsMax.Set(int64(cfg.Server.MaxSessions))
+
 	return h
 }
 
+// ActiveSessions returns the number of sessions currently being served
+func (h *Handler) ActiveSessions() int {
+	return int(atomic.LoadInt64(&h.activeSessions))
+}
+
+// acquireSession reserves a session slot, returning false when the cap is reached
+func (h *Handler) acquireSession() bool {
+	max := int64(h.Config.Server.MaxSessions)
+	for {
+		current := atomic.LoadInt64(&h.activeSessions)
+		if max > 0 && current >= max {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&h.activeSessions, current, current+1) {
+			sessionsActive.Inc()
+			return true
+		}
+	}
+}
+
+// releaseSession frees a slot reserved by acquireSession
+func (h *Handler) releaseSession() {
+	atomic.AddInt64(&h.activeSessions, -1)
+	sessionsActive.Dec()
+}
+
 // checkOrigin validates the request origin against allowed origins
 func (h *Handler) checkOrigin(r *http.Request) bool {
 	origin := r.Header.Get("Origin")
@@ -53,38 +105,150 @@ func (h *Handler) checkOrigin(r *http.Request) bool {
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	clientIP := middleware.GetClientIP(r)
 
+	// Banned IPs are refused before their rate is consulted
+	if ban, banned := h.RateLimiter.Abuse.Banned(clientIP); banned {
+		middleware.RefuseBanned(w, ban)
+		return
+	}
+
 	// Check rate limit for connection attempts
-	if !h.RateLimiter.Allow(clientIP) {
+	rate := h.RateLimiter.Take(clientIP)
+	rate.SetHeaders(w.Header())
+	if !rate.Allowed {
 		log.Printf("Rate limit exceeded for IP: %s", clientIP)
 		http.Error(w, "Too many requests", http.StatusTooManyRequests)
 		return
 	}
 
-	// Check connection limit per IP
+	// Check connection limit per IP. Under the evict_idle policy a client at
+	// the limit takes over the slot of its longest idle connection.
 	if !h.RateLimiter.AddConnection(clientIP) {
-		log.Printf("Connection limit exceeded for IP: %s", clientIP)
-		http.Error(w, "Too many connections", http.StatusTooManyRequests)
-		return
+		if h.Config.Rate.ConnectionLimitPolicy != config.ConnectionLimitEvictIdle ||
+			!h.connections.evictIdle(clientIP, h.Config.Rate.EvictIdleAfter) {
+			log.Printf("Connection limit exceeded for IP: %s", clientIP)
+			h.RateLimiter.Abuse.RecordRateViolation(clientIP)
+			w.Header().Set("Retry-After", sessionRetryAfterSeconds)
+			http.Error(w, "Too many connections", http.StatusTooManyRequests)
+			return
+		}
+		log.Printf("Connection limit reached for IP %s, evicted its longest idle connection", clientIP)
 	}
 
-	// Validate API key
-	if !h.validateAPIKey(r) {
+	// Authenticate API key or JWT
+	principal, err := h.Auth.Authenticate(r)
+	if err != nil {
 		h.RateLimiter.RemoveConnection(clientIP)
-		log.Printf("Invalid API key from IP: %s", clientIP)
+		h.RateLimiter.Abuse.RecordAuthFailure(clientIP)
+		log.Printf("Invalid credentials from IP %s: %v", clientIP, err)
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if !principal.HasScope(config.ScopeRealtimeTranscribe) {
+		h.RateLimiter.RemoveConnection(clientIP)
+		log.Printf("Credentials without %s scope from IP: %s", config.ScopeRealtimeTranscribe, clientIP)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// A client that lost its connection may resume its session, replaying
+	// the events after the last one it received
+	resumeID := r.URL.Query().Get("session_id")
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		// Browsers cannot set headers on WebSocket upgrades
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastEventSequence uint64
+	if lastEventID != "" {
+		if lastEventSequence, err = strconv.ParseUint(lastEventID, 10, 64); err != nil || resumeID == "" {
+			h.RateLimiter.RemoveConnection(clientIP)
+			http.Error(w, "Last-Event-ID must be the sequence number of an event of the resumed session_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Clients pick the event protocol with ?protocol=, or announce the beta
+	// protocol with the OpenAI-Beta header; others get the server default
+	var protocol domain.Protocol
+	if name := r.URL.Query().Get("protocol"); name != "" {
+		var ok bool
+		if protocol, ok = domain.ParseProtocol(name); !ok {
+			h.RateLimiter.RemoveConnection(clientIP)
+			http.Error(w, "Unsupported protocol: "+name, http.StatusBadRequest)
+			return
+		}
+	} else if r.Header.Get("OpenAI-Beta") == "realtime=v1" {
+		protocol = domain.Protocol2024
+	}
+
+	// Metadata attached to the session as it opens, e.g. ?metadata[call_id]=...
+	metadata, err := middleware.Metadata(r)
+	if err != nil {
+		h.RateLimiter.RemoveConnection(clientIP)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Shed new sessions while session audio nears the memory budget
+	if resumeID == "" && !h.UseCase.AdmitSession() {
+		h.RateLimiter.RemoveConnection(clientIP)
+		w.Header().Set("Retry-After", sessionRetryAfterSeconds)
+		http.Error(w, "Server at capacity", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Reserve a slot under the server-wide session cap
+	if !h.acquireSession() {
+		h.RateLimiter.RemoveConnection(clientIP)
+		sessionsRejected.Inc()
+		log.Printf("Session cap reached (%d), rejecting IP: %s", h.Config.Server.MaxSessions, clientIP)
+		w.Header().Set("Retry-After", sessionRetryAfterSeconds)
+		http.Error(w, "Server at capacity", http.StatusServiceUnavailable)
+		return
+	}
 
 	// Upgrade connection
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		h.releaseSession()
 		h.RateLimiter.RemoveConnection(clientIP)
 		log.Println("Upgrade error:", err)
 		return
 	}
 
-	// Wrap connection with thread-safe writer
-	safeConn := NewSafeConn(conn)
+	// Wrap connection with thread-safe writer. Clients that can read arrays of
+	// events opt in to batched deltas with ?batch=true.
+	var batchInterval time.Duration
+	if r.URL.Query().Get("batch") == "true" {
+		batchInterval = h.Config.Server.EventBatchInterval
+	}
+	safeConn := NewSafeConnWithBatching(conn, batchInterval)
+	var sessionConn usecase.Conn = safeConn
+
+	tenant := h.UseCase.ResolveTenant(principal.APIKey, principal.TenantClaim)
+
+	// Optionally record all events of this session
+	recordDir := h.Config.Record.Dir
+	if tenant != nil && tenant.RecordDir != "" {
+		recordDir = tenant.RecordDir
+	}
+	if recordDir != "" {
+		header := recording.Header{Owner: domain.OwnerHash(principal.ID)}
+		if tenant != nil {
+			header.TenantID = tenant.ID
+		}
+		recorder, err := recording.NewRecorder(recordDir, header)
+		if err != nil {
+			log.Printf("[WARN] Session recording disabled: %v", err)
+		} else {
+			log.Printf("Recording session for IP %s to %s", clientIP, recorder.Path())
+			sessionConn = recording.NewConn(sessionConn, recorder)
+		}
+	}
+
+	// Track the connection's activity, and enforce per-connection message rate limits
+	tracked := h.connections.add(sessionConn, safeConn, clientIP)
+	sessionConn = newLimitedConn(tracked, safeConn, middleware.NewMessageLimiter(&h.Config.Rate), clientIP)
 
 	// Parse intent from query parameter (OpenAI compatible: ?intent=transcription)
 	intent := usecase.IntentRealtime
@@ -96,76 +260,30 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Handle connection in goroutine and track cleanup
 	go func() {
-		defer h.RateLimiter.RemoveConnection(clientIP)
-		defer safeConn.Close()
-		h.UseCase.HandleNewConnectionWithIntent(safeConn, intent)
+		defer panics.Recover("connection from "+clientIP, nil)
+		defer h.releaseSession()
+		defer func() {
+			// An evicted connection's slot was taken over by its replacement
+			if h.connections.remove(tracked) {
+				h.RateLimiter.RemoveConnection(clientIP)
+			}
+		}()
+		defer sessionConn.Close()
+		h.UseCase.HandleNewConnectionWithOptions(sessionConn, usecase.ConnectOptions{
+			Intent:            intent,
+			APIKey:            principal.ID,
+			Tenant:            tenant,
+			ClientIP:          clientIP,
+			Protocol:          protocol,
+			ResumeSessionID:   resumeID,
+			LastEventSequence: lastEventSequence,
+			Metadata:          metadata,
+		})
 	}()
 }
 
-// validateAPIKey checks if the request has a valid API key
-func (h *Handler) validateAPIKey(r *http.Request) bool {
-	// Check Authorization header (Bearer token)
-	authHeader := r.Header.Get("Authorization")
-	if authHeader != "" {
-		// Support "Bearer <key>" format
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			apiKey := strings.TrimPrefix(authHeader, "Bearer ")
-			return h.Config.IsAPIKeyValid(apiKey)
-		}
-		// Also support raw key in Authorization header
-		return h.Config.IsAPIKeyValid(authHeader)
-	}
-
-	// Check OpenAI-style header
-	apiKey := r.Header.Get("OpenAI-Api-Key")
-	if apiKey != "" {
-		return h.Config.IsAPIKeyValid(apiKey)
-	}
-
-	// Check query parameter (for WebSocket clients that can't set headers)
-	apiKey = r.URL.Query().Get("api_key")
-	if apiKey != "" {
-		return h.Config.IsAPIKeyValid(apiKey)
-	}
-
-	// If no API keys configured, allow without auth
-	return h.Config.IsAPIKeyValid("")
-}
-
 // Close cleans up handler resources
 func (h *Handler) Close() {
 	h.RateLimiter.Close()
-}
-
-// SafeConn wraps a WebSocket connection with thread-safe write operations
-type SafeConn struct {
-	conn    *websocket.Conn
-	writeMu sync.Mutex
-}
-
-// NewSafeConn creates a new thread-safe WebSocket connection wrapper
-func NewSafeConn(conn *websocket.Conn) *SafeConn {
-	return &SafeConn{conn: conn}
-}
-
-// WriteJSON writes JSON data in a thread-safe manner
-func (sc *SafeConn) WriteJSON(v interface{}) error {
-	sc.writeMu.Lock()
-	defer sc.writeMu.Unlock()
-	return sc.conn.WriteJSON(v)
-}
-
-// ReadMessage reads a message from the connection
-func (sc *SafeConn) ReadMessage() (messageType int, p []byte, err error) {
-	return sc.conn.ReadMessage()
-}
-
-// Close closes the underlying connection
-func (sc *SafeConn) Close() error {
-	return sc.conn.Close()
-}
-
-// Conn returns the underlying websocket connection (use with caution)
-func (sc *SafeConn) Conn() *websocket.Conn {
-	return sc.conn
+	h.Auth.Close()
 }
@@ -0,0 +1,36 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+)
+
+func TestSessionMetricsSharedByHandlers(t *testing.T) {
+	newHandler := func() *Handler {
+		cfg := &config.Config{
+			Server: config.ServerConfig{MaxSessions: 1},
+			Rate:   config.RateLimitConfig{CleanupInterval: time.Minute},
+		}
+		h := NewHandler(usecase.NewSessionUsecase(), cfg)
+		t.Cleanup(h.RateLimiter.Close)
+		return h
+	}
+	first, second := newHandler(), newHandler()
+	active := sessionsActive.Value()
+
+	// A later handler must not hide the sessions of an earlier one
+	if !first.acquireSession() || !second.acquireSession() {
+		t.Fatal("Expected each handler to have a free slot")
+	}
+	if got := sessionsActive.Value() - active; got != 2 {
+		t.Errorf("Expected 2 active sessions, got %d", got)
+	}
+	first.releaseSession()
+	second.releaseSession()
+	if got := sessionsActive.Value() - active; got != 0 {
+		t.Errorf("Expected released sessions to leave the gauge, got %d", got)
+	}
+}
@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/gorilla/websocket"
+)
+
+// errFlood is returned from ReadMessage once a connection is disconnected for abuse
+var errFlood = errors.New("connection closed: message rate limits repeatedly exceeded")
+
+// limitedConn drops client messages that exceed the per-connection rate
+// limits, returning each as a usecase.RejectedMessage for the session to
+// answer, and ends the connection once the violation budget is exhausted
+type limitedConn struct {
+	usecase.Conn
+	safeConn *SafeConn
+	limiter  *middleware.MessageLimiter
+	clientIP string
+	flooded  bool // The violation budget is exhausted, close on the next read
+}
+
+func newLimitedConn(conn usecase.Conn, safeConn *SafeConn, limiter *middleware.MessageLimiter, clientIP string) *limitedConn {
+	return &limitedConn{
+		Conn:     conn,
+		safeConn: safeConn,
+		limiter:  limiter,
+		clientIP: clientIP,
+	}
+}
+
+// ReadMessage returns the next message, or a usecase.RejectedMessage for one
+// that exceeds the limits
+func (c *limitedConn) ReadMessage() (int, []byte, error) {
+	if c.flooded {
+		// The session has answered the last message, now close
+		c.safeConn.CloseWithReason(websocket.ClosePolicyViolation, "rate limit exceeded")
+		return 0, nil, errFlood
+	}
+
+	messageType, message, err := c.Conn.ReadMessage()
+	if err != nil {
+		return messageType, message, err
+	}
+
+	var base domain.BaseEvent
+	json.Unmarshal(message, &base)
+
+	allowed, limit := c.limiter.Allow(base.Type == domain.EventInputAudioBufferAppend || base.Type == domain.EventOutputAudioBufferAppend, len(message))
+	if allowed {
+		return messageType, message, nil
+	}
+
+	reason := fmt.Sprintf("Event %s dropped: %s exceeded", base.Type, limit)
+	if c.limiter.Exhausted() {
+		log.Printf("Disconnecting IP %s: message rate limits repeatedly exceeded", c.clientIP)
+		c.flooded = true
+		reason = "Too many rate limit violations, closing connection"
+	}
+	return 0, nil, &usecase.RejectedMessage{
+		EventID: base.EventID,
+		Type:    "rate_limit_error",
+		Code:    domain.CodeRateLimitExceeded,
+		Message: reason,
+		Param:   limit,
+	}
+}
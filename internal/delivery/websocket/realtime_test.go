@@ -0,0 +1,212 @@
+// Tests of connection handling over the realtime harness, which imports this package.
+
+package websocket_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+	"github.com/gorilla/websocket"
+)
+
+func TestEventFloodProtection(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Rate.MaxEventsPerSecond = 5
+	cfg.Rate.MaxViolations = 3
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+
+	client, err := srv.Dial(nil, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 10; i++ {
+		client.SendRaw([]byte(`{"type":"input_audio_buffer.clear"}`))
+	}
+
+	event, err := client.Expect(domain.EventError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errEvent domain.ErrorServerEvent
+	event.Decode(&errEvent)
+	if errEvent.Error.Code != "rate_limit_exceeded" {
+		t.Errorf("Expected code rate_limit_exceeded, got %s", errEvent.Error.Code)
+	}
+	if !errEvent.Error.Retryable {
+		t.Error("Expected rate limit errors to be retryable")
+	}
+
+	// The connection is closed once the violation budget is spent
+	for {
+		if _, err := client.Next(); err != nil {
+			if !errors.Is(err, realtimetest.ErrClosed) {
+				t.Errorf("Expected connection to be closed, got %v", err)
+			}
+			break
+		}
+	}
+}
+
+func TestSessionCap(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Server.MaxSessions = 1
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+
+	first, err := srv.Dial(nil, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	if _, err := first.Expect(domain.EventSessionCreated); err != nil {
+		t.Fatal(err)
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(srv.URL(), nil)
+	if err == nil {
+		t.Fatal("Expected second connection to be rejected")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %v", resp)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+
+	// Closing the first session frees the slot
+	first.Close()
+	deadline := time.Now().Add(realtimetest.DefaultTimeout)
+	for srv.Handler.ActiveSessions() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Session slot was not released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	second, err := srv.Dial(nil, nil)
+	if err != nil {
+		t.Fatalf("Dial after release failed: %v", err)
+	}
+	second.Close()
+}
+
+func TestIdleConnectionEviction(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Rate.MaxConnectionsPerIP = 1
+	cfg.Rate.ConnectionLimitPolicy = config.ConnectionLimitEvictIdle
+	cfg.Rate.EvictIdleAfter = 200 * time.Millisecond
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+
+	first, err := srv.Dial(nil, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer first.Close()
+	if _, err := first.Expect(domain.EventSessionCreated); err != nil {
+		t.Fatal(err)
+	}
+
+	// A connection that is not idle long enough keeps its slot
+	_, resp, err := websocket.DefaultDialer.Dial(srv.URL(), nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 while the first connection is active, got %v", resp)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	second, err := srv.Dial(nil, nil)
+	if err != nil {
+		t.Fatalf("Expected the idle connection to be evicted, dial failed: %v", err)
+	}
+	defer second.Close()
+	if _, err := second.Expect(domain.EventSessionCreated); err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		_, err := first.Next()
+		if err == nil {
+			continue
+		}
+		if !strings.Contains(err.Error(), "1008") || !strings.Contains(err.Error(), "replaced by a new connection") {
+			t.Fatalf("Expected the evicted connection to be closed with a reason, got %v", err)
+		}
+		break
+	}
+}
+
+func TestProtocolNegotiation(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Server.Protocol = "ga"
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	if _, err := srv.Dial(url.Values{"protocol": {"2023-01"}}, nil); err == nil {
+		t.Fatal("Expected an unknown protocol to be refused")
+	}
+
+	// A beta client shares the GA server with the flat session format
+	client, err := srv.Dial(url.Values{"protocol": {"2024-10"}}, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	created, err := client.Expect(domain.EventSessionCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session struct {
+		Session map[string]json.RawMessage `json:"session"`
+	}
+	if err := created.Decode(&session); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if _, ok := session.Session["input_audio_format"]; !ok {
+		t.Errorf("Expected a flat session, got %s", created.Raw)
+	}
+	if _, ok := session.Session["audio"]; ok {
+		t.Errorf("Expected no GA audio object, got %s", created.Raw)
+	}
+
+	err = client.SendRaw([]byte(`{"type":"session.update","session":{"input_audio_transcription":{"model":"` + realtimetest.MockModel + `","language":"en"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := client.Expect(domain.EventSessionUpdated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var beta domain.BetaSessionUpdatedEvent
+	if err := updated.Decode(&beta); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if beta.Session.InputAudioTranscription == nil || beta.Session.InputAudioTranscription.Model != realtimetest.MockModel {
+		t.Fatalf("Expected the transcription model applied, got %s", updated.Raw)
+	}
+
+	if err := client.AppendAudio(make([]byte, 3200)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, err := client.ExpectSequence(
+		domain.EventInputAudioBufferCommitted,
+		domain.EventConversationItemCreated,
+	); err != nil {
+		t.Fatal(err)
+	}
+}
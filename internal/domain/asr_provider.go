@@ -1,4 +0,0 @@
-package domain
-
-// This file is intentionally empty.
-// The actual ASRProvider interface is defined in asr.go
@@ -0,0 +1,202 @@
+// Package metrics provides a minimal in-process metrics registry exposed in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type metric interface {
+	write(w io.Writer, name string)
+}
+
+type entry struct {
+	name   string
+	help   string
+	kind   string // "gauge" or "counter"
+	metric metric
+}
+
+// Registry holds named metrics
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]*entry
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string]*entry)}
+}
+
+// Default is the process-wide registry served by Handler
+var Default = NewRegistry()
+
+func (r *Registry) register(name, help, kind string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[name] = &entry{name: name, help: help, kind: kind, metric: m}
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	value int64
+}
+
+// NewGauge registers a gauge in the registry
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(name, help, "gauge", g)
+	return g
+}
+
+// Inc increments the gauge by one
+func (g *Gauge) Inc() { atomic.AddInt64(&g.value, 1) }
+
+// Dec decrements the gauge by one
+func (g *Gauge) Dec() { atomic.AddInt64(&g.value, -1) }
+
+// Add adds delta to the gauge
+func (g *Gauge) Add(delta int64) { atomic.AddInt64(&g.value, delta) }
+
+// Set sets the gauge value
+func (g *Gauge) Set(v int64) { atomic.StoreInt64(&g.value, v) }
+
+// Value returns the current value
+func (g *Gauge) Value() int64 { return atomic.LoadInt64(&g.value) }
+
+func (g *Gauge) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %d\n", name, g.Value())
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	value uint64
+}
+
+// NewCounter registers a counter in the registry
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(name, help, "counter", c)
+	return c
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc() { atomic.AddUint64(&c.value, 1) }
+
+// Add adds delta to the counter
+func (c *Counter) Add(delta uint64) { atomic.AddUint64(&c.value, delta) }
+
+// Value returns the current value
+func (c *Counter) Value() uint64 { return atomic.LoadUint64(&c.value) }
+
+func (c *Counter) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %d\n", name, c.Value())
+}
+
+// CounterVec is a family of counters told apart by the values of its labels
+type CounterVec struct {
+	labels   []string
+	mu       sync.Mutex
+	counters map[string]*Counter // By the rendered label set
+}
+
+// NewCounterVec registers a counter family with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{labels: labels, counters: make(map[string]*Counter)}
+	r.register(name, help, "counter", v)
+	return v
+}
+
+// With returns the counter of the label values, one per label name in order
+func (v *CounterVec) With(values ...string) *Counter {
+	var b strings.Builder
+	for i, label := range v.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		fmt.Fprintf(&b, "%s=%q", label, value)
+	}
+	key := b.String()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.counters[key]
+	if !ok {
+		c = &Counter{}
+		v.counters[key] = c
+	}
+	return c
+}
+
+func (v *CounterVec) write(w io.Writer, name string) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.counters))
+	for key := range v.counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]uint64, len(keys))
+	for i, key := range keys {
+		values[i] = v.counters[key].Value()
+	}
+	v.mu.Unlock()
+	for i, key := range keys {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, key, values[i])
+	}
+}
+
+// gaugeFunc reports a value computed at scrape time
+type gaugeFunc func() float64
+
+func (f gaugeFunc) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %g\n", name, f())
+}
+
+// NewGaugeFunc registers a gauge whose value is computed by fn on each scrape
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, help, "gauge", gaugeFunc(fn))
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.RLock()
+	entries := make([]*entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	r.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, e := range entries {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", e.name, e.help, e.name, e.kind)
+		e.metric.write(w, e.name)
+	}
+}
+
+// NewGauge registers a gauge in the default registry
+func NewGauge(name, help string) *Gauge { return Default.NewGauge(name, help) }
+
+// NewCounter registers a counter in the default registry
+func NewCounter(name, help string) *Counter { return Default.NewCounter(name, help) }
+
+// NewCounterVec registers a counter family in the default registry
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// NewGaugeFunc registers a computed gauge in the default registry
+func NewGaugeFunc(name, help string, fn func() float64) { Default.NewGaugeFunc(name, help, fn) }
+
+// Handler returns the HTTP handler for the default registry
+func Handler() http.Handler { return Default }
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Reasons an IP is banned for
+const (
+	BanReasonAuthFailures   = "auth_failures"
+	BanReasonRateViolations = "rate_violations"
+)
+
+// Ban is an IP refused until Until for abuse
+type Ban struct {
+	IP     string    `json:"ip"`
+	Reason string    `json:"reason"`
+	Count  int       `json:"count"` // Bans of the IP in a row, each twice as long as the one before
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
+// AbuseDetector counts rejected credentials and rate-limited requests per IP,
+// banning an IP that reaches the thresholds of its RateLimitConfig. Bans
+// escalate until the IP stays clean for MaxBanDuration after one ends.
+type AbuseDetector struct {
+	config *config.RateLimitConfig
+	mu     sync.Mutex
+	ips    map[string]*abuseState
+	now    func() time.Time
+}
+
+type abuseState struct {
+	windowStart    time.Time
+	authFailures   int
+	rateViolations int
+	bans           int  // Bans in a row, reset once the IP stays clean
+	ban            *Ban // Latest ban, possibly over
+}
+
+// NewAbuseDetector creates a detector with the thresholds of cfg
+func NewAbuseDetector(cfg *config.RateLimitConfig) *AbuseDetector {
+	return &AbuseDetector{
+		config: cfg,
+		ips:    make(map[string]*abuseState),
+		now:    time.Now,
+	}
+}
+
+// RecordAuthFailure counts a request from ip with rejected credentials
+func (d *AbuseDetector) RecordAuthFailure(ip string) {
+	d.record(ip, BanReasonAuthFailures, d.config.BanAfterAuthFailures)
+}
+
+// RecordRateViolation counts a request from ip refused by the rate limiter
+func (d *AbuseDetector) RecordRateViolation(ip string) {
+	d.record(ip, BanReasonRateViolations, d.config.BanAfterRateViolations)
+}
+
+func (d *AbuseDetector) record(ip, reason string, threshold int) {
+	if threshold <= 0 {
+		return
+	}
+
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, exists := d.ips[ip]
+	if !exists {
+		state = &abuseState{}
+		d.ips[ip] = state
+	}
+	if state.banned(now) {
+		return
+	}
+	if now.Sub(state.windowStart) > d.config.BanWindow {
+		state.windowStart = now
+		state.authFailures, state.rateViolations = 0, 0
+	}
+
+	count := &state.authFailures
+	if reason == BanReasonRateViolations {
+		count = &state.rateViolations
+	}
+	if *count++; *count < threshold {
+		return
+	}
+
+	if state.ban != nil && now.Sub(state.ban.Until) > d.config.MaxBanDuration {
+		state.bans = 0
+	}
+	state.bans++
+	duration := d.config.BanDuration
+	for i := 1; i < state.bans && duration < d.config.MaxBanDuration; i++ {
+		duration *= 2
+	}
+	duration = min(duration, d.config.MaxBanDuration)
+
+	state.ban = &Ban{IP: ip, Reason: reason, Count: state.bans, Since: now, Until: now.Add(duration)}
+	state.authFailures, state.rateViolations = 0, 0
+	log.Printf("Banned IP %s for %v after %d %s (ban #%d)", ip, duration, threshold, reason, state.bans)
+}
+
+func (s *abuseState) banned(now time.Time) bool {
+	return s.ban != nil && now.Before(s.ban.Until)
+}
+
+// Banned returns the ban ip is refused for, if any
+func (d *AbuseDetector) Banned(ip string) (Ban, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, exists := d.ips[ip]
+	if !exists || !state.banned(d.now()) {
+		return Ban{}, false
+	}
+	return *state.ban, true
+}
+
+// Bans lists the bans in force, by IP
+func (d *AbuseDetector) Bans() []Ban {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	bans := []Ban{}
+	for _, state := range d.ips {
+		if state.banned(now) {
+			bans = append(bans, *state.ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].IP < bans[j].IP })
+	return bans
+}
+
+// Unban lifts the ban of ip and forgets its history, reporting whether it
+// was banned
+func (d *AbuseDetector) Unban(ip string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, exists := d.ips[ip]
+	if !exists {
+		return false
+	}
+	delete(d.ips, ip)
+	return state.banned(d.now())
+}
+
+// cleanup forgets IPs without a ban in force, strikes in the current window
+// or a ban recent enough to escalate
+func (d *AbuseDetector) cleanup() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	for ip, state := range d.ips {
+		if state.banned(now) || now.Sub(state.windowStart) <= d.config.BanWindow {
+			continue
+		}
+		if state.ban != nil && now.Sub(state.ban.Until) <= d.config.MaxBanDuration {
+			continue
+		}
+		delete(d.ips, ip)
+	}
+}
+
+// RefuseBanned answers a request from a banned IP with 403, telling the
+// client in Retry-After when the ban ends
+func RefuseBanned(w http.ResponseWriter, ban Ban) {
+	retryAfter := max(ceilSeconds(time.Until(ban.Until)), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeError(w, http.StatusForbidden, "invalid_request_error", domain.CodeIPBanned,
+		"Too many "+banReasonText(ban.Reason)+" from this IP, retry after "+strconv.Itoa(retryAfter)+"s")
+}
+
+func banReasonText(reason string) string {
+	if reason == BanReasonAuthFailures {
+		return "requests with invalid credentials"
+	}
+	return "rate-limited requests"
+}
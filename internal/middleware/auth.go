@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aira-id/gribe/internal/pkg/jwks"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// ErrInvalidCredentials is returned when a request's API key or token is not accepted
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	ID          string   // API key, or "jwt:<sub>" for token callers; used for quota accounting
+	APIKey      string   // Set for API key callers
+	Scopes      []string // Granted scopes
+	TenantClaim string   // Value of auth.jwt.tenant_claim for token callers
+}
+
+// HasScope reports whether the principal was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope || granted == config.ScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator resolves requests to principals using API keys and, when
+// configured, JWT bearer tokens verified against a JWKS endpoint
+type Authenticator struct {
+	config   *config.Config
+	verifier *jwks.Verifier // nil when JWT auth is disabled
+}
+
+// NewAuthenticator creates an authenticator, starting JWKS refresh if JWT auth is enabled
+func NewAuthenticator(cfg *config.Config) *Authenticator {
+	a := &Authenticator{config: cfg}
+	if cfg.Auth.JWT.Enabled() {
+		keys := jwks.NewKeySet(cfg.Auth.JWT.JWKSURL, cfg.Auth.JWT.RefreshInterval)
+		keys.Start()
+		a.verifier = jwks.NewVerifier(keys, cfg.Auth.JWT.Issuer, cfg.Auth.JWT.Audience)
+	}
+	return a
+}
+
+type principalKey struct{}
+
+// Middleware refuses requests without valid credentials with 401 before
+// they reach next, and keeps the principal of the others in the request
+// context, so next's own Authenticate does not verify them again. Scopes are
+// left to next.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			log.Printf("Invalid credentials for %s from IP %s: %v", r.URL.Path, GetClientIP(r), err)
+			writeError(w, http.StatusUnauthorized, "invalid_request_error", domain.CodeInvalidAPIKey, "A valid API key is required")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+// Authenticate returns the principal for the request's credentials
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if principal, ok := r.Context().Value(principalKey{}).(*Principal); ok {
+		return principal, nil
+	}
+	token := APIKeyFromRequest(r)
+
+	if a.verifier != nil && jwks.LooksLikeJWT(token) {
+		claims, err := a.verifier.Verify(r.Context(), token)
+		if err != nil {
+			return nil, err
+		}
+
+		// Tokens of different subjects must not share quotas, limits and sessions
+		if claims.String("sub") == "" {
+			return nil, errors.New("token has no subject")
+		}
+
+		scopes := claims.Strings(a.config.Auth.JWT.ScopeClaim)
+		if len(scopes) == 0 {
+			// Tokens without scopes may only transcribe
+			scopes = []string{config.ScopeRealtimeTranscribe}
+		}
+		principal := &Principal{ID: "jwt:" + claims.String("sub"), Scopes: scopes}
+		if a.config.Auth.JWT.TenantClaim != "" {
+			principal.TenantClaim = claims.String(a.config.Auth.JWT.TenantClaim)
+		}
+		return principal, nil
+	}
+
+	scopes := a.config.ScopesForAPIKey(token)
+	if a.config.HasScope(token, config.ScopeRealtimeTranscribe) && !containsScope(scopes, config.ScopeRealtimeTranscribe) {
+		// Transcription is open when no auth is configured
+		scopes = append(scopes, config.ScopeRealtimeTranscribe)
+	}
+	if len(scopes) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{ID: token, APIKey: token, Scopes: scopes}, nil
+}
+
+// Close stops background JWKS refresh
+func (a *Authenticator) Close() {
+	if a.verifier != nil {
+		a.verifier.Keys.Close()
+	}
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyFromRequest extracts the client API key from the request, or "" if none was sent
+func APIKeyFromRequest(r *http.Request) string {
+	// Check Authorization header (Bearer token)
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" {
+		// Support "Bearer <key>" format, also raw key in Authorization header
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+
+	// Check OpenAI-style header
+	if apiKey := r.Header.Get("OpenAI-Api-Key"); apiKey != "" {
+		return apiKey
+	}
+
+	// Check query parameter (for WebSocket clients that can't set headers)
+	return r.URL.Query().Get("api_key")
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type basePathKey struct{}
+
+// forwardedPrefix matches the X-Forwarded-Prefix values ExternalPath honours:
+// plain path segments. Any client can send the header, and a value such as
+// "//evil.example" would turn the path into another host's URL.
+var forwardedPrefix = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+// BasePath serves next under prefix (e.g. "/stt"), stripping it from request
+// paths so handlers keep routing on their own paths. Requests outside prefix
+// are not found. An empty prefix serves next at the root.
+func BasePath(prefix string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok || (path != "" && path[0] != '/') {
+			http.NotFound(w, r)
+			return
+		}
+		if path == "" {
+			path = "/"
+		}
+
+		stripped := r.WithContext(context.WithValue(r.Context(), basePathKey{}, prefix))
+		stripped.URL = new(url.URL)
+		*stripped.URL = *r.URL
+		stripped.URL.Path = path
+		stripped.URL.RawPath = ""
+		next.ServeHTTP(w, stripped)
+	})
+}
+
+// ExternalPath returns path as clients address it: under the base path the
+// request was served at and the X-Forwarded-Prefix of a path-rewriting proxy
+func ExternalPath(r *http.Request, path string) string {
+	prefix, _ := r.Context().Value(basePathKey{}).(string)
+	if forwarded := strings.TrimSuffix(r.Header.Get("X-Forwarded-Prefix"), "/"); forwardedPrefix.MatchString(forwarded) {
+		prefix = forwarded + prefix
+	}
+	return prefix + path
+}
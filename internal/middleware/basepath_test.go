@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	handler := BasePath("/stt", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + ExternalPath(r, "/v1/transcription-jobs/job_1")))
+	}))
+
+	for _, tc := range []struct {
+		path, forwardedPrefix string
+		status                int
+		body                  string
+	}{
+		{path: "/stt/health", status: http.StatusOK, body: "/health /stt/v1/transcription-jobs/job_1"},
+		{path: "/stt", status: http.StatusOK, body: "/ /stt/v1/transcription-jobs/job_1"},
+		{path: "/stt/health", forwardedPrefix: "/api/", status: http.StatusOK, body: "/health /api/stt/v1/transcription-jobs/job_1"},
+		{path: "/stt/health", forwardedPrefix: "//evil.example", status: http.StatusOK, body: "/health /stt/v1/transcription-jobs/job_1"},
+		{path: "/stt/health", forwardedPrefix: "/\\evil.example", status: http.StatusOK, body: "/health /stt/v1/transcription-jobs/job_1"},
+		{path: "/health", status: http.StatusNotFound},
+		{path: "/sttx/health", status: http.StatusNotFound},
+	} {
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.forwardedPrefix != "" {
+			r.Header.Set("X-Forwarded-Prefix", tc.forwardedPrefix)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.path, w.Code, tc.status)
+			continue
+		}
+		if tc.status == http.StatusOK && w.Body.String() != tc.body {
+			t.Errorf("%s (prefix %q): got %q, want %q", tc.path, tc.forwardedPrefix, w.Body.String(), tc.body)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/config"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := &config.Config{Server: config.ServerConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		CORS: config.CORSConfig{
+			AllowedMethods: []string{"GET", "POST"},
+			AllowedHeaders: []string{"Authorization", "Content-Type"},
+			MaxAge:         10 * time.Minute,
+		},
+	}}
+	reached := 0
+	handler := CORS(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached++
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(method, origin string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/v1/sessions/sess_1/transcript", nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	preflight := http.Header{"Access-Control-Request-Method": {"POST"}}
+
+	w := serve(http.MethodOptions, "https://app.example.com", preflight)
+	if w.Code != http.StatusNoContent || reached != 0 {
+		t.Fatalf("Preflight: status %d, reached handler %d times", w.Code, reached)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, POST",
+		"Access-Control-Allow-Headers": "Authorization, Content-Type",
+		"Access-Control-Max-Age":       "600",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("Preflight %s = %q, want %q", header, got, want)
+		}
+	}
+
+	w = serve(http.MethodGet, "https://app.example.com", nil)
+	if w.Code != http.StatusOK || reached != 1 {
+		t.Fatalf("Request: status %d, reached handler %d times", w.Code, reached)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Errorf("Request lacks CORS headers: %v", w.Header())
+	}
+
+	// Other origins get no CORS headers, and their preflights are refused
+	if w = serve(http.MethodOptions, "https://evil.example.com", preflight); w.Code != http.StatusForbidden {
+		t.Errorf("Preflight from another origin: status %d, want 403", w.Code)
+	}
+	w = serve(http.MethodGet, "https://evil.example.com", nil)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Request from another origin got Access-Control-Allow-Origin %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// Requests without an Origin are not cross-origin
+	if w = serve(http.MethodGet, "", nil); w.Code != http.StatusOK || w.Header().Get("Vary") != "" {
+		t.Errorf("Same-origin request: status %d, headers %v", w.Code, w.Header())
+	}
+}
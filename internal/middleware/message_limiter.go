@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/clock"
+	"github.com/aira-id/gribe/pkg/config"
+)
+
+// Names of the per-connection limits reported by MessageLimiter.Allow
+const (
+	LimitEvents  = "max_events_per_second"
+	LimitAppends = "max_appends_per_second"
+	LimitBytes   = "max_bytes_per_second"
+)
+
+// violationWindow is how long rejected messages count towards
+// max_violations, so a long-lived stream is not disconnected for bursts
+// spread over hours
+const violationWindow = time.Minute
+
+// MessageLimiter enforces per-connection message rate limits after the
+// WebSocket upgrade. Each limit is a token bucket holding one second's worth
+// of capacity. A message larger than that, such as an append of several
+// seconds of audio, passes when the bucket is full and leaves it in debt, so
+// the rate holds on average but no message is too large to ever pass.
+type MessageLimiter struct {
+	config      *config.RateLimitConfig
+	events      bucket
+	appends     bucket
+	bytes       bucket
+	violations  int       // Rejected messages since windowStart
+	windowStart time.Time // Start of the violation window
+	mu          sync.Mutex
+	clock       clock.Clock
+}
+
+type bucket struct {
+	rate       float64
+	tokens     float64
+	lastUpdate time.Time
+}
+
+// allows refills the bucket and reports whether n tokens are available, or
+// the bucket is full when n exceeds its capacity. A bucket with zero rate is
+// unlimited.
+func (b *bucket) allows(n float64, now time.Time) bool {
+	if b.rate <= 0 {
+		return true
+	}
+
+	b.tokens += now.Sub(b.lastUpdate).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.lastUpdate = now
+	return b.tokens >= n || b.tokens >= b.rate
+}
+
+// take consumes n tokens the bucket allows, leaving it in debt past its
+// capacity
+func (b *bucket) take(n float64) {
+	if b.rate > 0 {
+		b.tokens -= n
+	}
+}
+
+func newBucket(rate int, now time.Time) bucket {
+	return bucket{rate: float64(rate), tokens: float64(rate), lastUpdate: now}
+}
+
+// NewMessageLimiter creates a limiter for a single connection
+func NewMessageLimiter(cfg *config.RateLimitConfig) *MessageLimiter {
+	return NewMessageLimiterWithClock(cfg, clock.Real{})
+}
+
+// NewMessageLimiterWithClock creates a limiter for a single connection,
+// refilling its buckets by c
+func NewMessageLimiterWithClock(cfg *config.RateLimitConfig, c clock.Clock) *MessageLimiter {
+	now := c.Now()
+	return &MessageLimiter{
+		config:  cfg,
+		clock:   c,
+		events:  newBucket(cfg.MaxEventsPerSecond, now),
+		appends: newBucket(cfg.MaxAppendsPerSecond, now),
+		bytes:   newBucket(cfg.MaxBytesPerSecond, now),
+	}
+}
+
+// Allow records an inbound message and reports whether it is within limits.
+// When rejected, the name of the exceeded limit is returned.
+func (l *MessageLimiter) Allow(isAppend bool, size int) (bool, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A message is checked against every limit before it is charged to any,
+	// so one refused by a limit does not spend the others' tokens
+	now := l.clock.Now()
+	limit := ""
+	switch {
+	case !l.events.allows(1, now):
+		limit = LimitEvents
+	case isAppend && !l.appends.allows(1, now):
+		limit = LimitAppends
+	case !l.bytes.allows(float64(size), now):
+		limit = LimitBytes
+	}
+	if limit != "" {
+		if now.Sub(l.windowStart) > violationWindow {
+			l.windowStart = now
+			l.violations = 0
+		}
+		l.violations++
+		return false, limit
+	}
+
+	l.events.take(1)
+	if isAppend {
+		l.appends.take(1)
+	}
+	l.bytes.take(float64(size))
+	return true, ""
+}
+
+// Exhausted reports whether the connection has exceeded its violation budget
+// within the violation window and should be disconnected
+func (l *MessageLimiter) Exhausted() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.config.MaxViolations > 0 && l.violations >= l.config.MaxViolations &&
+		l.clock.Now().Sub(l.windowStart) <= violationWindow
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Metadata collects the session metadata a client attaches to a request as
+// metadata[<key>]=<value> query parameters, nil when there is none. Browsers
+// cannot set headers on WebSocket upgrades, so the query carries it.
+func Metadata(r *http.Request) (map[string]string, error) {
+	return MetadataValues(r.URL.Query())
+}
+
+// MetadataValues collects session metadata from metadata[<key>] query
+// parameters or form fields, nil when there is none
+func MetadataValues(values map[string][]string) (map[string]string, error) {
+	var metadata map[string]string
+	for name, value := range values {
+		key, ok := strings.CutPrefix(name, "metadata[")
+		if !ok || !strings.HasSuffix(key, "]") || len(value) == 0 {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[strings.TrimSuffix(key, "]")] = value[0]
+	}
+	if err := domain.ValidateMetadata("metadata", metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
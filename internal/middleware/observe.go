@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aira-id/gribe/internal/metrics"
+)
+
+var httpRequests = metrics.NewCounterVec("gribe_http_requests_total",
+	"HTTP requests answered, by route and status class", "route", "status")
+
+// AccessLog logs each request once answered, with its status, the bytes
+// written, how long it took and the client IP. Upgraded WebSocket requests
+// are logged with status 101 once upgraded, not when their session ends.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		log.Printf("%s %s %d %dB %v from %s", r.Method, r.URL.Path, recorder.code(), recorder.bytes,
+			time.Since(started).Round(time.Millisecond), GetClientIP(r))
+	})
+}
+
+// Metrics counts answered requests in gribe_http_requests_total by their
+// Router route and status class, such as "2xx"
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		httpRequests.With(Route(r), strconv.Itoa(recorder.code()/100)+"xx").Inc()
+	})
+}
@@ -1,20 +1,30 @@
 package middleware
 
 import (
+	"encoding/json"
+	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
-	"github.com/aira-id/gribe/internal/config"
+	"github.com/aira-id/gribe/internal/pkg/clock"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
 // RateLimiter implements IP-based rate limiting
 type RateLimiter struct {
+	// Abuse bans IPs with repeated rate violations or rejected credentials,
+	// refused before their rate is consulted
+	Abuse *AbuseDetector
+
 	config      *config.RateLimitConfig
 	connections map[string]*clientState
 	mu          sync.RWMutex
 	stopCleanup chan struct{}
+	clock       clock.Clock // Refills buckets and paces cleanup
 }
 
 type clientState struct {
@@ -25,11 +35,20 @@ type clientState struct {
 
 // NewRateLimiter creates a new rate limiter
 func NewRateLimiter(cfg *config.RateLimitConfig) *RateLimiter {
+	return NewRateLimiterWithClock(cfg, clock.Real{})
+}
+
+// NewRateLimiterWithClock creates a rate limiter, and its abuse detector,
+// timed by c
+func NewRateLimiterWithClock(cfg *config.RateLimitConfig, c clock.Clock) *RateLimiter {
 	rl := &RateLimiter{
+		Abuse:       NewAbuseDetector(cfg),
 		config:      cfg,
 		connections: make(map[string]*clientState),
 		stopCleanup: make(chan struct{}),
+		clock:       c,
 	}
+	rl.Abuse.now = c.Now
 
 	// Start cleanup goroutine
 	go rl.cleanupLoop()
@@ -39,6 +58,31 @@ func NewRateLimiter(cfg *config.RateLimitConfig) *RateLimiter {
 
 // Allow checks if a request from the given IP should be allowed
 func (rl *RateLimiter) Allow(ip string) bool {
+	return rl.Take(ip).Allowed
+}
+
+// RateLimitStatus is the outcome of a request against an IP's token bucket
+type RateLimitStatus struct {
+	Allowed    bool
+	Limit      int           // Requests the bucket holds when full
+	Remaining  int           // Requests left in the bucket
+	Reset      time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until the next request is allowed, 0 when allowed
+}
+
+// SetHeaders reports the status in X-RateLimit-* headers, and in Retry-After
+// when the request is refused. Durations are rounded up to whole seconds.
+func (s RateLimitStatus) SetHeaders(h http.Header) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(s.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(s.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(s.Reset)))
+	if !s.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(s.RetryAfter), 1)))
+	}
+}
+
+// Take spends a token of the IP's bucket for a request, if one is left
+func (rl *RateLimiter) Take(ip string) RateLimitStatus {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
@@ -47,13 +91,13 @@ func (rl *RateLimiter) Allow(ip string) bool {
 		state = &clientState{
 			connections: 0,
 			tokens:      float64(rl.config.BurstSize),
-			lastUpdate:  time.Now(),
+			lastUpdate:  rl.clock.Now(),
 		}
 		rl.connections[ip] = state
 	}
 
 	// Refill tokens based on time elapsed
-	now := time.Now()
+	now := rl.clock.Now()
 	elapsed := now.Sub(state.lastUpdate).Seconds()
 	state.tokens += elapsed * float64(rl.config.RequestsPerSecond)
 	if state.tokens > float64(rl.config.BurstSize) {
@@ -62,12 +106,61 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	state.lastUpdate = now
 
 	// Check if we have tokens available
-	if state.tokens < 1 {
-		return false
+	status := RateLimitStatus{Allowed: state.tokens >= 1, Limit: rl.config.BurstSize}
+	if status.Allowed {
+		state.tokens--
+	} else {
+		rl.Abuse.RecordRateViolation(ip)
+	}
+	status.Remaining = int(state.tokens)
+	rate := float64(rl.config.RequestsPerSecond)
+	if rate > 0 {
+		status.Reset = time.Duration((float64(rl.config.BurstSize) - state.tokens) / rate * float64(time.Second))
+		if !status.Allowed {
+			status.RetryAfter = time.Duration((1 - state.tokens) / rate * float64(time.Second))
+		}
 	}
+	return status
+}
 
-	state.tokens--
-	return true
+// Middleware applies the per-IP request rate to next, reporting the client's
+// bucket in X-RateLimit-* headers and refusing requests past it with 429.
+// Banned IPs are refused first, and 401 answers of next count towards a ban.
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := GetClientIP(r)
+		if ban, banned := rl.Abuse.Banned(clientIP); banned {
+			RefuseBanned(w, ban)
+			return
+		}
+		status := rl.Take(clientIP)
+		status.SetHeaders(w.Header())
+		if !status.Allowed {
+			log.Printf("Rate limit exceeded for IP: %s", clientIP)
+			writeError(w, http.StatusTooManyRequests, "rate_limit_error", domain.CodeRateLimitExceeded, "Too many requests")
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == http.StatusUnauthorized {
+			rl.Abuse.RecordAuthFailure(clientIP)
+		}
+	})
+}
+
+// writeError writes an error response in the format of the API's error events
+func writeError(w http.ResponseWriter, status int, errType, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": domain.NewErrorDetail(errType, code, message, nil),
+	})
+}
+
+// ceilSeconds rounds d up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
 }
 
 // AddConnection tracks a new connection from an IP
@@ -81,7 +174,7 @@ func (rl *RateLimiter) AddConnection(ip string) bool {
 		state = &clientState{
 			connections: 0,
 			tokens:      float64(rl.config.BurstSize),
-			lastUpdate:  time.Now(),
+			lastUpdate:  rl.clock.Now(),
 		}
 		rl.connections[ip] = state
 	}
@@ -109,13 +202,14 @@ func (rl *RateLimiter) RemoveConnection(ip string) {
 
 // cleanupLoop periodically removes stale entries
 func (rl *RateLimiter) cleanupLoop() {
-	ticker := time.NewTicker(rl.config.CleanupInterval)
+	ticker := rl.clock.NewTicker(rl.config.CleanupInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-ticker.C():
 			rl.cleanup()
+			rl.Abuse.cleanup()
 		case <-rl.stopCleanup:
 			return
 		}
@@ -127,7 +221,7 @@ func (rl *RateLimiter) cleanup() {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	now := time.Now()
+	now := rl.clock.Now()
 	staleThreshold := 5 * time.Minute
 
 	for ip, state := range rl.connections {
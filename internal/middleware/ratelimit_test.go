@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/clock"
+	"github.com/aira-id/gribe/pkg/config"
+)
+
+func TestRateLimiterRefill(t *testing.T) {
+	c := clock.NewFake(time.Unix(1735689600, 0))
+	rl := NewRateLimiterWithClock(&config.RateLimitConfig{
+		RequestsPerSecond: 2,
+		BurstSize:         2,
+		CleanupInterval:   time.Minute,
+	}, c)
+	defer rl.Close()
+
+	for i := 0; i < 2; i++ {
+		if status := rl.Take("10.0.0.1"); !status.Allowed {
+			t.Fatalf("Expected request %d within the burst, got %+v", i+1, status)
+		}
+	}
+	status := rl.Take("10.0.0.1")
+	if status.Allowed || status.RetryAfter != 500*time.Millisecond || status.Reset != time.Second {
+		t.Fatalf("Expected a refusal for 500ms with a reset in 1s, got %+v", status)
+	}
+
+	c.Advance(499 * time.Millisecond)
+	if rl.Allow("10.0.0.1") {
+		t.Error("Expected no token before 500ms")
+	}
+	c.Advance(500 * time.Millisecond)
+	if !rl.Allow("10.0.0.1") {
+		t.Error("Expected a token after 500ms")
+	}
+	if !rl.Allow("10.0.0.2") {
+		t.Error("Expected other IPs to have their own bucket")
+	}
+}
+
+func TestMessageLimiterRefill(t *testing.T) {
+	c := clock.NewFake(time.Unix(1735689600, 0))
+	limiter := NewMessageLimiterWithClock(&config.RateLimitConfig{
+		MaxEventsPerSecond: 10,
+		MaxBytesPerSecond:  1000,
+		MaxViolations:      2,
+	}, c)
+
+	if ok, limit := limiter.Allow(true, 1000); !ok {
+		t.Fatalf("Expected a second's worth of bytes to pass, got %s", limit)
+	}
+	if ok, limit := limiter.Allow(true, 200); ok || limit != LimitBytes {
+		t.Fatalf("Expected %s, got %v %s", LimitBytes, ok, limit)
+	}
+	c.Advance(200 * time.Millisecond)
+	if ok, limit := limiter.Allow(true, 200); !ok {
+		t.Errorf("Expected 200 bytes after 200ms, got %s", limit)
+	}
+	if limiter.Exhausted() {
+		t.Error("Expected one violation to be within the budget")
+	}
+}
+
+func TestMessageLimiterLargeMessage(t *testing.T) {
+	c := clock.NewFake(time.Unix(1735689600, 0))
+	limiter := NewMessageLimiterWithClock(&config.RateLimitConfig{MaxBytesPerSecond: 1000}, c)
+
+	// A message beyond a second's worth passes on a full bucket, then the
+	// debt is paid off at the rate
+	if ok, limit := limiter.Allow(true, 3000); !ok {
+		t.Fatalf("Expected a message larger than the bucket to pass, got %s", limit)
+	}
+	c.Advance(2 * time.Second)
+	if ok, _ := limiter.Allow(true, 1); ok {
+		t.Error("Expected the bucket to still be paying off the large message")
+	}
+	c.Advance(time.Second)
+	if ok, limit := limiter.Allow(true, 3000); !ok {
+		t.Errorf("Expected another large message once the bucket refilled, got %s", limit)
+	}
+}
+
+func TestMessageLimiterChecksBeforeCharging(t *testing.T) {
+	c := clock.NewFake(time.Unix(1735689600, 0))
+	limiter := NewMessageLimiterWithClock(&config.RateLimitConfig{
+		MaxEventsPerSecond:  2,
+		MaxAppendsPerSecond: 1,
+	}, c)
+
+	if ok, limit := limiter.Allow(true, 10); !ok {
+		t.Fatalf("Expected the first append to pass, got %s", limit)
+	}
+	// Refused appends do not spend event tokens, so other events still pass
+	for i := 0; i < 3; i++ {
+		if ok, limit := limiter.Allow(true, 10); ok || limit != LimitAppends {
+			t.Fatalf("Expected %s, got %v %s", LimitAppends, ok, limit)
+		}
+	}
+	if ok, limit := limiter.Allow(false, 10); !ok {
+		t.Errorf("Expected an event within the event limit to pass, got %s", limit)
+	}
+}
+
+func TestMessageLimiterViolationWindow(t *testing.T) {
+	c := clock.NewFake(time.Unix(1735689600, 0))
+	limiter := NewMessageLimiterWithClock(&config.RateLimitConfig{
+		MaxEventsPerSecond: 1,
+		MaxViolations:      2,
+	}, c)
+
+	// Bursts spread over more than the window do not add up
+	for i := 0; i < 3; i++ {
+		limiter.Allow(false, 1)
+		if ok, _ := limiter.Allow(false, 1); ok {
+			t.Fatal("Expected the second event of a burst to be refused")
+		}
+		if limiter.Exhausted() {
+			t.Fatalf("Expected burst %d alone to be within the budget", i+1)
+		}
+		c.Advance(2 * violationWindow)
+	}
+
+	limiter.Allow(false, 1)
+	limiter.Allow(false, 1)
+	limiter.Allow(false, 1)
+	if !limiter.Exhausted() {
+		t.Error("Expected two violations within the window to exhaust the budget")
+	}
+	c.Advance(2 * violationWindow)
+	if limiter.Exhausted() {
+		t.Error("Expected the violations to expire with the window")
+	}
+}
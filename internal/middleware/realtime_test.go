@@ -0,0 +1,85 @@
+// Tests of the realtime endpoint's protection, over the realtime harness, which imports this package.
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+	"github.com/gorilla/websocket"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Rate.RequestsPerSecond = 1
+	cfg.Rate.BurstSize = 1
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+
+	client, err := srv.Dial(nil, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial(srv.URL(), nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 past the burst, got %v", resp)
+	}
+	for header, want := range map[string]string{
+		"X-RateLimit-Limit":     "1",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     "1",
+		"Retry-After":           "1",
+	} {
+		if got := resp.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestAbuseBan(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Auth.APIKeys = []string{"good-key"}
+	cfg.Rate.BanAfterAuthFailures = 2
+	cfg.Rate.BanWindow = time.Minute
+	cfg.Rate.BanDuration = time.Minute
+	cfg.Rate.MaxBanDuration = time.Hour
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+
+	dial := func(key string) *http.Response {
+		conn, resp, err := websocket.DefaultDialer.Dial(srv.URL(), http.Header{"Authorization": {"Bearer " + key}})
+		if err == nil {
+			conn.Close()
+		}
+		return resp
+	}
+	for i := 0; i < 2; i++ {
+		if resp := dial("bad-key"); resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("Expected 401 for bad credentials, got %v", resp)
+		}
+	}
+
+	// Banned IPs are refused even with valid credentials
+	resp := dial("good-key")
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected 403 once banned, got %v", resp)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	bans := srv.Handler.RateLimiter.Abuse.Bans()
+	if len(bans) != 1 || bans[0].Reason != middleware.BanReasonAuthFailures || bans[0].Count != 1 {
+		t.Fatalf("Expected one auth_failures ban, got %+v", bans)
+	}
+
+	if !srv.Handler.RateLimiter.Abuse.Unban(bans[0].IP) {
+		t.Fatal("Expected Unban to lift the ban")
+	}
+	if resp := dial("good-key"); resp == nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected the upgrade to succeed once unbanned, got %v", resp)
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+)
+
+// Middleware wraps a handler, e.g. to refuse requests or observe answers
+type Middleware func(next http.Handler) http.Handler
+
+// Chain wraps h in middlewares, the first outermost
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+type routeKey struct{}
+
+// Route returns the pattern of the Router route serving r, "" outside one
+func Route(r *http.Request) string {
+	route, _ := r.Context().Value(routeKey{}).(string)
+	return route
+}
+
+// Router is a ServeMux whose routes are wrapped in middleware: that of the
+// router, then that of the group (see With) and route they were added with
+type Router struct {
+	mux         *http.ServeMux
+	middlewares []Middleware
+}
+
+// NewRouter creates a router wrapping all its routes in middlewares
+func NewRouter(middlewares ...Middleware) *Router {
+	return &Router{mux: http.NewServeMux(), middlewares: middlewares}
+}
+
+// With returns a group of the router's routes: routes added to it are also
+// wrapped in middlewares, inside the router's own
+func (rt *Router) With(middlewares ...Middleware) *Router {
+	return &Router{mux: rt.mux, middlewares: append(append([]Middleware{}, rt.middlewares...), middlewares...)}
+}
+
+// Handle serves pattern (as in http.ServeMux) with h wrapped in the
+// router's middleware, then middlewares
+func (rt *Router) Handle(pattern string, h http.Handler, middlewares ...Middleware) {
+	h = Chain(h, append(append([]Middleware{}, rt.middlewares...), middlewares...)...)
+	rt.mux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeKey{}, pattern)))
+	}))
+}
+
+// HandleFunc serves pattern with f, see Handle
+func (rt *Router) HandleFunc(pattern string, f http.HandlerFunc, middlewares ...Middleware) {
+	rt.Handle(pattern, f, middlewares...)
+}
+
+// ServeHTTP implements http.Handler
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// statusRecorder remembers the status a handler answered with and the
+// bytes it wrote. Flush, Hijack and Unwrap keep streaming responses and
+// WebSocket upgrades working through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && r.status == 0 {
+		// Upgraded: the handler answered 101 Switching Protocols itself
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// code returns the recorded status, 200 for handlers that wrote nothing
+func (r *statusRecorder) code() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
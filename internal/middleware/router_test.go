@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aira-id/gribe/pkg/config"
+)
+
+// tag appends name to the X-Chain header on the way in
+func tag(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Chain", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := NewRouter(tag("router"))
+	group := router.With(tag("group"))
+	route := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(Route(r))) }
+	router.HandleFunc("/open", route)
+	group.HandleFunc("/v1/items/", route, tag("route"))
+
+	for path, want := range map[string]struct{ chain, route string }{
+		"/open":         {"router", "/open"},
+		"/v1/items/abc": {"router,group,route", "/v1/items/"},
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if chain := strings.Join(recorder.Header().Values("X-Chain"), ","); chain != want.chain {
+			t.Errorf("Expected %s to run %q, got %q", path, want.chain, chain)
+		}
+		if body := recorder.Body.String(); body != want.route {
+			t.Errorf("Expected %s to be served as route %q, got %q", path, want.route, body)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if recorder.Code != http.StatusNotFound || recorder.Header().Get("X-Chain") != "" {
+		t.Errorf("Expected unrouted paths to get a bare 404, got %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestMetricsByRoute(t *testing.T) {
+	router := NewRouter(Metrics)
+	router.HandleFunc("/v1/things/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			http.NotFound(w, r)
+		}
+	})
+
+	ok := httpRequests.With("/v1/things/", "2xx")
+	notFound := httpRequests.With("/v1/things/", "4xx")
+	okBefore, notFoundBefore := ok.Value(), notFound.Value()
+	for _, path := range []string{"/v1/things/a", "/v1/things/b", "/v1/things/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if n := ok.Value() - okBefore; n != 2 {
+		t.Errorf("Expected 2 requests counted as 2xx, got %d", n)
+	}
+	if n := notFound.Value() - notFoundBefore; n != 1 {
+		t.Errorf("Expected 1 request counted as 4xx, got %d", n)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	auth := NewAuthenticator(&config.Config{Auth: config.AuthConfig{APIKeys: []string{"sk-test"}}})
+	defer auth.Close()
+	var principal *Principal
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = auth.Authenticate(r)
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/data", nil))
+	if recorder.Code != http.StatusUnauthorized || principal != nil {
+		t.Fatalf("Expected 401 without reaching the handler, got %d", recorder.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/data", nil)
+	r.Header.Set("Authorization", "Bearer sk-test")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	if recorder.Code != http.StatusOK || principal == nil || principal.APIKey != "sk-test" {
+		t.Errorf("Expected the handler to get the key's principal, got %d %+v", recorder.Code, principal)
+	}
+}
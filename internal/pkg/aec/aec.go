@@ -0,0 +1,126 @@
+// Package aec cancels acoustic echo: the audio a client played, such as a
+// voice bot's speech, picked up again by its microphone. The echo is
+// estimated from the played reference with a normalized least mean squares
+// (NLMS) adaptive filter, as in the speex echo canceller, and subtracted
+// from the microphone audio.
+package aec
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+	"github.com/aira-id/gribe/internal/pkg/pcm"
+)
+
+const (
+	step           = 0.5   // NLMS adaptation rate, 0-1
+	doubleTalk     = 0.5   // Microphone samples above this share of the reference peak are near-end speech (Geigel)
+	doubleTalkMs   = 30    // Adaptation stays paused this long after near-end speech
+	maxReferenceMs = 10000 // Reference queued ahead of the microphone audio; older reference is dropped
+	epsilon        = 1e-6  // Keeps the step finite on a silent reference
+)
+
+// Canceller removes the echo of a reference from 16-bit PCM microphone audio
+// of one stream. Reference audio is queued with Reference as it is played and
+// consumed sample for sample by the microphone audio passed to Process.
+type Canceller struct {
+	mu         sync.Mutex
+	sampleRate int
+	tailMs     int
+	weights    []float64 // Estimated echo path, newest reference sample first
+	history    []float64 // Last len(weights) reference samples, twice so history[pos:] is contiguous
+	pos        int
+	energy     float64   // Of the reference samples in the filter
+	pending    []float32 // Reference not yet matched with microphone audio
+	maxPending int
+	hold       int // Samples adaptation stays paused
+	holdFor    int
+}
+
+// New creates a canceller for audio of sampleRate, cancelling echoes that
+// arrive up to tailMs after the reference was played
+func New(sampleRate, tailMs int) *Canceller {
+	taps := max(sampleRate*tailMs/1000, 1)
+	return &Canceller{
+		sampleRate: sampleRate,
+		tailMs:     tailMs,
+		weights:    make([]float64, taps),
+		history:    make([]float64, 2*taps),
+		maxPending: sampleRate * maxReferenceMs / 1000,
+		holdFor:    sampleRate * doubleTalkMs / 1000,
+	}
+}
+
+// TailMs returns the longest echo delay the canceller covers
+func (c *Canceller) TailMs() int {
+	return c.tailMs
+}
+
+// SampleRate returns the rate of the audio the canceller takes
+func (c *Canceller) SampleRate() int {
+	return c.sampleRate
+}
+
+// Reference queues 16-bit little-endian PCM that was played, in the order
+// it was played
+func (c *Canceller) Reference(audio []byte) {
+	samples := pcm.ToFloat32(audio)
+	defer bufpool.PutFloat32s(samples)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = append(c.pending, samples...)
+	if over := len(c.pending) - c.maxPending; over > 0 {
+		c.pending = append(c.pending[:0], c.pending[over:]...)
+	}
+}
+
+// Process cancels the echo in 16-bit little-endian PCM microphone audio in
+// place. Audio recorded while nothing was played passes unchanged.
+func (c *Canceller) Process(audio []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	taps := len(c.weights)
+	n := len(audio) / 2
+	used := min(n, len(c.pending))
+	for i := 0; i < n; i++ {
+		var x float64
+		if i < used {
+			x = float64(c.pending[i])
+		}
+
+		// Shift the reference into the filter
+		c.pos = (c.pos + taps - 1) % taps
+		oldest := c.history[c.pos+taps]
+		c.energy = max(c.energy+x*x-oldest*oldest, 0)
+		c.history[c.pos], c.history[c.pos+taps] = x, x
+		window := c.history[c.pos : c.pos+taps]
+
+		var echo, peak float64
+		for k, w := range c.weights {
+			echo += w * window[k]
+			peak = max(peak, math.Abs(window[k]))
+		}
+		d := float64(int16(binary.LittleEndian.Uint16(audio[2*i:]))) / 32768
+		e := d - echo
+
+		if math.Abs(d) > doubleTalk*peak {
+			c.hold = c.holdFor
+		} else if c.hold > 0 {
+			c.hold--
+		}
+		if c.hold == 0 && peak > 0 {
+			g := step * e / (c.energy + epsilon)
+			for k := range c.weights {
+				c.weights[k] += g * window[k]
+			}
+		}
+
+		out := max(min(math.Round(e*32768), math.MaxInt16), math.MinInt16)
+		binary.LittleEndian.PutUint16(audio[2*i:], uint16(int16(out)))
+	}
+	c.pending = append(c.pending[:0], c.pending[used:]...)
+}
@@ -0,0 +1,90 @@
+package aec
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// toPCM16 encodes samples in [-1, 1) as 16-bit little-endian PCM
+func toPCM16(samples []float64) []byte {
+	out := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(s*32768)))
+	}
+	return out
+}
+
+// power returns the mean square of 16-bit PCM
+func power(audio []byte) float64 {
+	var sum float64
+	for i := 0; i+1 < len(audio); i += 2 {
+		s := float64(int16(binary.LittleEndian.Uint16(audio[i:]))) / 32768
+		sum += s * s
+	}
+	return sum / float64(len(audio)/2)
+}
+
+func TestCanceller(t *testing.T) {
+	const rate, seconds, chunk = 8000, 4, 160
+	rng := rand.New(rand.NewSource(1))
+	reference := make([]float64, rate*seconds)
+	for i := range reference {
+		reference[i] = 0.3 * (2*rng.Float64() - 1)
+	}
+	// The room returns the played audio twice, 5ms and 12.5ms later
+	echo := make([]float64, len(reference))
+	for i := range echo {
+		if i >= 40 {
+			echo[i] += 0.3 * reference[i-40]
+		}
+		if i >= 100 {
+			echo[i] += 0.1 * reference[i-100]
+		}
+	}
+	// The near end speaks over the echo in the last second
+	mic := make([]float64, len(echo))
+	near := make([]float64, len(echo))
+	for i := range mic {
+		if i >= rate*(seconds-1) {
+			near[i] = 0.3 * math.Sin(2*math.Pi*300*float64(i)/rate)
+		}
+		mic[i] = echo[i] + near[i]
+	}
+
+	c := New(rate, 32)
+	refPCM, micPCM := toPCM16(reference), toPCM16(mic)
+	for i := 0; i < len(micPCM); i += 2 * chunk {
+		c.Reference(refPCM[i : i+2*chunk])
+		c.Process(micPCM[i : i+2*chunk])
+	}
+
+	// After converging, the echo is cancelled
+	second := 2 * rate
+	echoPower := power(toPCM16(echo[(seconds-2)*rate : (seconds-1)*rate]))
+	residual := power(micPCM[(seconds-2)*second : (seconds-1)*second])
+	if attenuation := 10 * math.Log10(echoPower/residual); attenuation < 20 {
+		t.Errorf("Expected the echo attenuated by at least 20 dB, got %.1f dB", attenuation)
+	}
+
+	// Near-end speech over the echo is kept
+	nearPCM := toPCM16(near)
+	var errSum float64
+	for i := (seconds - 1) * second; i < len(micPCM); i += 2 {
+		diff := float64(int16(binary.LittleEndian.Uint16(micPCM[i:]))-int16(binary.LittleEndian.Uint16(nearPCM[i:]))) / 32768
+		errSum += diff * diff
+	}
+	nearPower := power(nearPCM[(seconds-1)*second:])
+	if distortion := 10 * math.Log10(nearPower/(errSum/float64(rate))); distortion < 15 {
+		t.Errorf("Expected near-end speech kept at least 15 dB above the residual, got %.1f dB", distortion)
+	}
+
+	// Without reference the audio passes unchanged
+	silence := toPCM16(near[len(near)-chunk:])
+	before := append([]byte(nil), silence...)
+	New(rate, 32).Process(silence)
+	if string(silence) != string(before) {
+		t.Error("Expected audio without reference to pass unchanged")
+	}
+}
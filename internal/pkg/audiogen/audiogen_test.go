@@ -0,0 +1,78 @@
+package audiogen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/wav"
+)
+
+// rms returns the RMS amplitude of mono PCM16 as a fraction of full scale
+func rms(pcm []byte) float64 {
+	var sum float64
+	for i := 0; i+1 < len(pcm); i += 2 {
+		x := float64(int16(binary.LittleEndian.Uint16(pcm[i:]))) / 32768
+		sum += x * x
+	}
+	return math.Sqrt(sum / float64(len(pcm)/2))
+}
+
+func TestSpec(t *testing.T) {
+	spec := Spec{
+		SampleRate: 8000,
+		Segments:   Turns(2, 500*time.Millisecond, 250*time.Millisecond),
+		NoiseLevel: 0.01,
+		Seed:       7,
+	}
+	pcm := spec.PCM()
+	if len(pcm) != 8000*2*7/4 || spec.Duration() != 1750*time.Millisecond {
+		t.Fatalf("Expected 1.75s of 8kHz audio, got %d bytes", len(pcm))
+	}
+	if !bytes.Equal(pcm, spec.PCM()) {
+		t.Error("Expected a spec to generate the same audio every time")
+	}
+	spec.Seed = 8
+	if bytes.Equal(pcm, spec.PCM()) {
+		t.Error("Expected another seed to generate other audio")
+	}
+
+	want := []Span{{250 * time.Millisecond, 750 * time.Millisecond}, {1000 * time.Millisecond, 1500 * time.Millisecond}}
+	if spans := spec.Speech(); len(spans) != 2 || spans[0] != want[0] || spans[1] != want[1] {
+		t.Errorf("Expected speech at %v, got %v", want, spans)
+	}
+
+	gap, speech := pcm[:8000*2/4], pcm[8000*2/4:8000*2*3/4]
+	if level := rms(gap); level < 0.008 || level > 0.012 {
+		t.Errorf("Expected gaps at the noise level, got RMS %.4f", level)
+	}
+	if level := rms(speech); level < 0.05 || level > DefaultSpeechLevel {
+		t.Errorf("Expected speech well above the noise, got RMS %.4f", level)
+	}
+	if silent := (Spec{Segments: []Segment{Silence(time.Second)}}).PCM(); rms(silent) != 0 {
+		t.Error("Expected silence without noise to be digital silence")
+	}
+}
+
+func TestWAVAndChunks(t *testing.T) {
+	spec := Spec{SampleRate: 48000, Channels: 2, Segments: []Segment{Speech(time.Second), Silence(50 * time.Millisecond)}}
+	file := bytes.NewReader(spec.WAV())
+	format, err := wav.ReadHeader(file)
+	if err != nil {
+		t.Fatalf("ReadHeader failed: %v", err)
+	}
+	if format.SampleRate != 48000 || format.Channels != 2 || file.Len() != 48000*2*2*105/100 {
+		t.Errorf("Unexpected WAV format %+v with %d bytes of samples", format, file.Len())
+	}
+
+	chunks := spec.Chunks(100 * time.Millisecond)
+	if len(chunks) != 11 || len(chunks[0]) != 4800*2*2 || len(chunks[10]) != 2400*2*2 {
+		t.Errorf("Expected 10 chunks of 100ms and one of 50ms, got %d", len(chunks))
+	}
+	pcm := spec.PCM()
+	if left, right := pcm[2000:2002], pcm[2002:2004]; !bytes.Equal(left, right) {
+		t.Error("Expected every channel to carry the same signal")
+	}
+}
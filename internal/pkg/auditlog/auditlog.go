@@ -0,0 +1,44 @@
+// Package auditlog appends audit records to a JSON Lines file, one record
+// per line, so deletions and other irreversible actions can be proven later.
+package auditlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Log is an append-only JSON Lines file
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open opens the log at path for appending, creating it if needed
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &Log{file: file}, nil
+}
+
+// Write appends record as one line and syncs it to disk
+func (l *Log) Write(record interface{}) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Close closes the log
+func (l *Log) Close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,80 @@
+// Package bufpool recycles the byte and sample slices allocated on the audio
+// path, so that many concurrent sessions do not turn every appended chunk
+// into garbage. Slices are pooled in power-of-two size classes; requests
+// larger than the biggest class are allocated and dropped as usual.
+package bufpool
+
+import (
+	"math/bits"
+	"sync"
+)
+
+const (
+	minShift = 9  // Smallest class, 512 elements
+	maxShift = 24 // Largest class, 16Mi elements
+)
+
+// pool keeps one sync.Pool per size class
+type pool[T any] [maxShift - minShift + 1]sync.Pool
+
+var (
+	bytePool  pool[byte]
+	floatPool pool[float32]
+)
+
+// Bytes returns a byte slice of length n. Its contents are undefined.
+func Bytes(n int) []byte {
+	return bytePool.get(n)
+}
+
+// PutBytes recycles b. The caller must not use b afterwards.
+func PutBytes(b []byte) {
+	bytePool.put(b)
+}
+
+// Append appends src to dst like the builtin append, except that when dst is
+// full it moves to a larger pooled slice and recycles the old one. dst must
+// be owned by the caller.
+func Append(dst, src []byte) []byte {
+	if len(dst)+len(src) <= cap(dst) {
+		return append(dst, src...)
+	}
+	grown := Bytes(max(2*cap(dst), len(dst)+len(src)))[:len(dst)]
+	copy(grown, dst)
+	PutBytes(dst)
+	return append(grown, src...)
+}
+
+// Float32s returns a float32 slice of length n. Its contents are undefined.
+func Float32s(n int) []float32 {
+	return floatPool.get(n)
+}
+
+// PutFloat32s recycles s. The caller must not use s afterwards.
+func PutFloat32s(s []float32) {
+	floatPool.put(s)
+}
+
+func (p *pool[T]) get(n int) []T {
+	if n > 1<<maxShift {
+		return make([]T, n)
+	}
+	class := 0
+	if n > 1<<minShift {
+		class = bits.Len(uint(n-1)) - minShift
+	}
+	if s, ok := p[class].Get().(*[]T); ok {
+		return (*s)[:n]
+	}
+	return make([]T, n, 1<<(class+minShift))
+}
+
+func (p *pool[T]) put(s []T) {
+	c := cap(s)
+	if c < 1<<minShift || c > 1<<maxShift {
+		return
+	}
+	// The class is rounded down so every slice in it can hold the class size
+	s = s[:0]
+	p[bits.Len(uint(c))-1-minShift].Put(&s)
+}
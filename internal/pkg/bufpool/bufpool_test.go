@@ -0,0 +1,51 @@
+package bufpool
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		n       int
+		wantCap int
+	}{
+		{0, 512},
+		{100, 512},
+		{512, 512},
+		{513, 1024},
+		{48000, 65536},
+		{1<<24 + 1, 1<<24 + 1},
+	}
+	for _, tt := range tests {
+		b := Bytes(tt.n)
+		if len(b) != tt.n || cap(b) != tt.wantCap {
+			t.Errorf("Bytes(%d): got len %d cap %d, want cap %d", tt.n, len(b), cap(b), tt.wantCap)
+		}
+		PutBytes(b)
+	}
+
+	// A recycled slice of any capacity comes back large enough
+	PutBytes(make([]byte, 0, 1500))
+	if b := Bytes(1024); len(b) != 1024 || cap(b) < 1024 {
+		t.Errorf("Expected a 1024 byte slice, got len %d cap %d", len(b), cap(b))
+	}
+	if s := Float32s(4800); len(s) != 4800 || cap(s) < 4800 {
+		t.Errorf("Expected 4800 samples, got len %d cap %d", len(s), cap(s))
+	}
+}
+
+func TestAppend(t *testing.T) {
+	var want, got []byte
+	chunk := bytes.Repeat([]byte{1, 2, 3}, 100)
+	for i := 0; i < 50; i++ {
+		want = append(want, chunk...)
+		got = Append(got, chunk)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Append produced %d bytes, want %d matching", len(got), len(want))
+	}
+	if cap(got)&(cap(got)-1) != 0 {
+		t.Errorf("Expected a pooled power-of-two capacity, got %d", cap(got))
+	}
+}
@@ -0,0 +1,165 @@
+// Package cgroup reads the CPU and memory limits of the cgroup the process
+// runs in, v2 or v1, so the Go runtime can be fitted to a container's limits
+// instead of the host's core count and memory.
+package cgroup
+
+import (
+	"bufio"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Limits of a cgroup, zero when unlimited
+type Limits struct {
+	CPU    float64 // CPUs the quota allows per period
+	Memory int64   // Bytes
+}
+
+// unlimitedMemory is the smallest memory.limit_in_bytes cgroup v1 reports
+// for no limit, a page-rounded math.MaxInt64
+const unlimitedMemory = 1 << 62
+
+// Read returns the limits of the process's cgroup
+func Read() (Limits, error) {
+	return read(os.DirFS("/"))
+}
+
+func read(fsys fs.FS) (Limits, error) {
+	file, err := fsys.Open("proc/self/cgroup")
+	if err != nil {
+		return Limits{}, err
+	}
+	defer file.Close()
+
+	// Lines are hierarchy-ID:controllers:path, with 0::path for cgroup v2
+	var limits Limits
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		controllers, group := fields[1], fields[2]
+		if fields[0] == "0" && controllers == "" {
+			if v2, ok := readV2(fsys, group); ok {
+				return v2, nil
+			}
+			continue
+		}
+		for _, controller := range strings.Split(controllers, ",") {
+			switch controller {
+			case "cpu":
+				limits.CPU = readV1CPU(fsys, "sys/fs/cgroup/"+controllers, group)
+			case "memory":
+				limits.Memory = readV1Memory(fsys, "sys/fs/cgroup/memory", group)
+			}
+		}
+	}
+	return limits, scanner.Err()
+}
+
+// readV2 reads cpu.max ("max 100000" or "<quota> <period>") and memory.max,
+// reporting whether the cgroup v2 hierarchy is mounted
+func readV2(fsys fs.FS, group string) (Limits, bool) {
+	dir, ok := groupDir(fsys, "sys/fs/cgroup", group, "cgroup.controllers")
+	if !ok {
+		return Limits{}, false
+	}
+	var limits Limits
+	if fields := strings.Fields(readFile(fsys, dir+"/cpu.max")); len(fields) == 2 {
+		limits.CPU = quota(fields[0], fields[1])
+	}
+	if memory, err := strconv.ParseInt(readFile(fsys, dir+"/memory.max"), 10, 64); err == nil {
+		limits.Memory = memory
+	}
+	return limits, true
+}
+
+func readV1CPU(fsys fs.FS, mount, group string) float64 {
+	dir, ok := groupDir(fsys, mount, group, "cpu.cfs_quota_us")
+	if !ok {
+		return 0
+	}
+	return quota(readFile(fsys, dir+"/cpu.cfs_quota_us"), readFile(fsys, dir+"/cpu.cfs_period_us"))
+}
+
+func readV1Memory(fsys fs.FS, mount, group string) int64 {
+	dir, ok := groupDir(fsys, mount, group, "memory.limit_in_bytes")
+	if !ok {
+		return 0
+	}
+	memory, err := strconv.ParseInt(readFile(fsys, dir+"/memory.limit_in_bytes"), 10, 64)
+	if err != nil || memory >= unlimitedMemory {
+		return 0
+	}
+	return memory
+}
+
+// groupDir finds the directory of group under mount. Inside a container the
+// cgroup namespace usually mounts the group itself at mount, so the mount is
+// used when the group's own path does not exist there.
+func groupDir(fsys fs.FS, mount, group, probe string) (string, bool) {
+	for _, dir := range []string{path.Join(mount, group), mount} {
+		if _, err := fs.Stat(fsys, dir+"/"+probe); err == nil {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// quota returns the CPUs of a quota and period in microseconds, 0 for
+// "max" or -1 (no quota)
+func quota(quotaUS, periodUS string) float64 {
+	q, err := strconv.ParseFloat(quotaUS, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseFloat(periodUS, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+func readFile(fsys fs.FS, name string) string {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// FitGOMAXPROCS sets GOMAXPROCS to the CPU quota, rounded down but at least
+// 1, returning the value set. It leaves GOMAXPROCS alone, returning 0, when
+// there is no quota, the quota exceeds it, or the GOMAXPROCS environment
+// variable chose it.
+func FitGOMAXPROCS(limits Limits) int {
+	if limits.CPU <= 0 || os.Getenv("GOMAXPROCS") != "" {
+		return 0
+	}
+	procs := max(int(math.Floor(limits.CPU)), 1)
+	if procs >= runtime.GOMAXPROCS(0) {
+		return 0
+	}
+	runtime.GOMAXPROCS(procs)
+	return procs
+}
+
+// FitMemoryLimit sets the Go memory limit to ratio of the memory limit, so
+// the garbage collector works harder before the container is OOM-killed,
+// returning the limit set. It leaves the limit alone, returning 0, when
+// there is no memory limit or the GOMEMLIMIT environment variable chose it.
+func FitMemoryLimit(limits Limits, ratio float64) int64 {
+	if limits.Memory <= 0 || ratio <= 0 || os.Getenv("GOMEMLIMIT") != "" {
+		return 0
+	}
+	limit := int64(float64(limits.Memory) * ratio)
+	debug.SetMemoryLimit(limit)
+	return limit
+}
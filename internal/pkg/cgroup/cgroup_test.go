@@ -0,0 +1,70 @@
+package cgroup
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestRead(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fsys fstest.MapFS
+		want Limits
+	}{
+		{
+			name: "v2 namespaced",
+			fsys: fstest.MapFS{
+				"proc/self/cgroup":                       {Data: []byte("0::/\n")},
+				"sys/fs/cgroup/cgroup.controllers":       {Data: []byte("cpu memory\n")},
+				"sys/fs/cgroup/cpu.max":                  {Data: []byte("250000 100000\n")},
+				"sys/fs/cgroup/memory.max":               {Data: []byte("2147483648\n")},
+				"sys/fs/cgroup/other/cpu.max":            {Data: []byte("max 100000\n")},
+				"sys/fs/cgroup/other/cgroup.controllers": {Data: []byte("cpu\n")},
+			},
+			want: Limits{CPU: 2.5, Memory: 2 << 30},
+		},
+		{
+			name: "v2 nested group without limits",
+			fsys: fstest.MapFS{
+				"proc/self/cgroup": {Data: []byte("0::/system.slice/gribe.service\n")},
+				"sys/fs/cgroup/system.slice/gribe.service/cgroup.controllers": {Data: []byte("cpu memory\n")},
+				"sys/fs/cgroup/system.slice/gribe.service/cpu.max":            {Data: []byte("max 100000\n")},
+				"sys/fs/cgroup/system.slice/gribe.service/memory.max":         {Data: []byte("max\n")},
+			},
+			want: Limits{},
+		},
+		{
+			name: "v1",
+			fsys: fstest.MapFS{
+				"proc/self/cgroup":                            {Data: []byte("4:memory:/docker/abc\n3:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc\n")},
+				"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  {Data: []byte("150000\n")},
+				"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": {Data: []byte("100000\n")},
+				"sys/fs/cgroup/memory/memory.limit_in_bytes":  {Data: []byte("536870912\n")},
+			},
+			want: Limits{CPU: 1.5, Memory: 512 << 20},
+		},
+		{
+			name: "v1 unlimited",
+			fsys: fstest.MapFS{
+				"proc/self/cgroup":                            {Data: []byte("4:memory:/\n3:cpu,cpuacct:/\n")},
+				"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  {Data: []byte("-1\n")},
+				"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": {Data: []byte("100000\n")},
+				"sys/fs/cgroup/memory/memory.limit_in_bytes":  {Data: []byte("9223372036854771712\n")},
+			},
+			want: Limits{},
+		},
+	} {
+		got, err := read(tc.fsys)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := read(fstest.MapFS{}); err == nil {
+		t.Error("Expected an error without /proc/self/cgroup")
+	}
+}
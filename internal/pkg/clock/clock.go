@@ -0,0 +1,55 @@
+// Package clock abstracts reading the time and waiting for it, so logic that
+// depends on time, such as rate limits and session expiry, can be tested by
+// advancing a Fake clock instead of sleeping.
+package clock
+
+import "time"
+
+// Clock tells the time and schedules work after durations. Real is the
+// system clock; Fake is moved by tests.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	// After returns a channel receiving the time once d has passed
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc calls f in its own goroutine once d has passed
+	AfterFunc(d time.Duration, f func()) Timer
+	// NewTicker returns a ticker sending the time every d
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a pending call of Clock.AfterFunc
+type Timer interface {
+	// Stop cancels the call, reporting false if it already ran or was stopped
+	Stop() bool
+	// Reset schedules the call again d from now, reporting whether it was pending
+	Reset(d time.Duration) bool
+}
+
+// Ticker sends the time at intervals until stopped
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the system clock
+type Real struct{}
+
+func (Real) Now() time.Time                         { return time.Now() }
+func (Real) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (Real) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (Real) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker exposes a time.Ticker's channel as a method
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
@@ -0,0 +1,153 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves when Advance is called. Timers, tickers
+// and After channels fire in order of their due time as it passes, and
+// AfterFunc calls run on the goroutine calling Advance, so a test knows they
+// are done when it returns.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is a pending timer, ticker or After channel of a Fake
+type waiter struct {
+	clock  *Fake
+	at     time.Time
+	period time.Duration // Of tickers, 0 for one-shot waiters
+	f      func()        // Of AfterFunc timers
+	ch     chan time.Time
+}
+
+// NewFake returns a fake clock reading now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Fake) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *Fake) After(d time.Duration) <-chan time.Time {
+	w := &waiter{clock: c, ch: make(chan time.Time, 1)}
+	c.schedule(w, d)
+	return w.ch
+}
+
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	w := &waiter{clock: c, f: f}
+	c.schedule(w, d)
+	return w
+}
+
+func (c *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	w := &waiter{clock: c, period: d, ch: make(chan time.Time, 1)}
+	c.schedule(w, d)
+	return fakeTicker{w}
+}
+
+// Advance moves the clock forward by d, firing what falls due on the way
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		if len(c.waiters) == 0 || c.waiters[0].at.After(end) {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		w := c.waiters[0]
+		c.waiters = c.waiters[1:]
+		c.now = w.at
+		if w.period > 0 {
+			c.insert(w, w.at.Add(w.period))
+		}
+		now := c.now
+		c.mu.Unlock()
+
+		if w.f != nil {
+			w.f()
+			continue
+		}
+		select {
+		case w.ch <- now:
+		default:
+			// Like time.Ticker, drop ticks the receiver is not ready for
+		}
+	}
+}
+
+// Waiters returns the number of pending timers, tickers and After channels,
+// so tests can wait for the code under test to start waiting
+func (c *Fake) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// schedule adds w, due d from now
+func (c *Fake) schedule(w *waiter, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.insert(w, c.now.Add(d))
+}
+
+// insert adds w due at at, after waiters due at the same time. The caller
+// holds c.mu.
+func (c *Fake) insert(w *waiter, at time.Time) {
+	w.at = at
+	i := sort.Search(len(c.waiters), func(i int) bool { return c.waiters[i].at.After(at) })
+	c.waiters = append(c.waiters, nil)
+	copy(c.waiters[i+1:], c.waiters[i:])
+	c.waiters[i] = w
+}
+
+// remove drops w, reporting whether it was pending. The caller holds c.mu.
+func (c *Fake) remove(w *waiter) bool {
+	for i, pending := range c.waiters {
+		if pending == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *waiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.remove(w)
+}
+
+func (w *waiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	pending := w.clock.remove(w)
+	w.clock.insert(w, w.clock.now.Add(d))
+	return pending
+}
+
+// fakeTicker is a ticker of a Fake
+type fakeTicker struct {
+	*waiter
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.ch }
+func (t fakeTicker) Stop()               { t.waiter.Stop() }
@@ -0,0 +1,66 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Unix(1735689600, 0)
+	c := NewFake(start)
+
+	var calls []string
+	c.AfterFunc(2*time.Second, func() { calls = append(calls, "2s") })
+	stopped := c.AfterFunc(time.Second, func() { calls = append(calls, "stopped") })
+	var reset Timer
+	reset = c.AfterFunc(time.Second, func() {
+		calls = append(calls, "1s")
+		if len(calls) == 1 {
+			reset.Reset(3 * time.Second)
+		}
+	})
+	after := c.After(1500 * time.Millisecond)
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Expected the first Stop to cancel the timer and the second to report it gone")
+	}
+
+	c.Advance(time.Second)
+	if c.Since(start) != time.Second || len(calls) != 1 || calls[0] != "1s" {
+		t.Fatalf("Expected the 1s timer after a second, got %v at %v", calls, c.Since(start))
+	}
+	select {
+	case <-after:
+		t.Fatal("Expected After not to fire before 1.5s")
+	default:
+	}
+
+	c.Advance(4 * time.Second)
+	if want := []string{"1s", "2s", "1s"}; !equal(calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, calls)
+	}
+	if fired := <-after; !fired.Equal(start.Add(1500 * time.Millisecond)) {
+		t.Errorf("Expected After to receive its due time, got %v", fired)
+	}
+	// The ticker ticked 5 times, but keeps only the first unreceived tick
+	if tick := <-ticker.C(); !tick.Equal(start.Add(time.Second)) {
+		t.Errorf("Expected the first tick, got %v", tick)
+	}
+	if n := c.Waiters(); n != 1 {
+		t.Errorf("Expected only the ticker pending, got %d waiters", n)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,236 @@
+// Package convstore keeps the conversations of ended sessions, in memory or
+// as JSON files in a directory, for retrieval after the connection is gone.
+package convstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Memory keeps the most recent conversations in memory, evicting the oldest
+// saved first
+type Memory struct {
+	mu            sync.Mutex
+	maxEntries    int
+	conversations map[string]*domain.StoredConversation // By conversation and by session ID
+	order         []*domain.StoredConversation          // Oldest saved first
+}
+
+// NewMemory creates a store of at most maxEntries conversations
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{maxEntries: maxEntries, conversations: make(map[string]*domain.StoredConversation)}
+}
+
+// Save implements domain.ConversationStore
+func (m *Memory) Save(conversation *domain.StoredConversation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if previous, exists := m.conversations[conversation.ID]; exists {
+		for i, stored := range m.order {
+			if stored == previous {
+				m.order = append(m.order[:i], m.order[i+1:]...)
+				break
+			}
+		}
+	}
+	m.order = append(m.order, conversation)
+	m.conversations[conversation.ID] = conversation
+	m.conversations[conversation.SessionID] = conversation
+	for len(m.order) > m.maxEntries {
+		delete(m.conversations, m.order[0].ID)
+		delete(m.conversations, m.order[0].SessionID)
+		m.order = m.order[1:]
+	}
+	return nil
+}
+
+// Get implements domain.ConversationStore
+func (m *Memory) Get(id string) (*domain.StoredConversation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conversation, ok := m.conversations[id]
+	if !ok {
+		return nil, domain.ErrConversationNotFound
+	}
+	return conversation, nil
+}
+
+// List implements domain.ConversationStore, oldest saved first
+func (m *Memory) List() ([]*domain.StoredConversation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*domain.StoredConversation(nil), m.order...), nil
+}
+
+// Delete implements domain.ConversationStore
+func (m *Memory) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conversation, ok := m.conversations[id]
+	if !ok {
+		return domain.ErrConversationNotFound
+	}
+	delete(m.conversations, conversation.ID)
+	delete(m.conversations, conversation.SessionID)
+	for i, stored := range m.order {
+		if stored == conversation {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Dir keeps each conversation in a JSON file of a directory, named after
+// its ID and linked from a file named after its session, so conversations
+// survive restarts
+type Dir struct {
+	dir string
+}
+
+// record is the file of a conversation, with the fields kept out of its
+// API representation
+type record struct {
+	*domain.StoredConversation
+	Items    []domain.Item `json:"items"`
+	Owner    string        `json:"owner"`
+	TenantID string        `json:"tenant_id,omitempty"`
+}
+
+// NewDir creates a store in dir, creating the directory if needed
+func NewDir(dir string) (*Dir, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("conversation store: %w", err)
+	}
+	return &Dir{dir: dir}, nil
+}
+
+// Save implements domain.ConversationStore. The file is replaced at once so
+// a crash never leaves it half written.
+func (d *Dir) Save(conversation *domain.StoredConversation) error {
+	path, err := d.path(conversation.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(record{
+		StoredConversation: conversation,
+		Items:              conversation.Items,
+		Owner:              conversation.Owner,
+		TenantID:           conversation.TenantID,
+	})
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	link, err := d.path(conversation.SessionID)
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(filepath.Base(path), link); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+// Get implements domain.ConversationStore
+func (d *Dir) Get(id string) (*domain.StoredConversation, error) {
+	path, err := d.path(id)
+	if err != nil {
+		return nil, domain.ErrConversationNotFound
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, domain.ErrConversationNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	rec := record{StoredConversation: &domain.StoredConversation{}}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("invalid conversation file %s: %w", path, err)
+	}
+	conversation := rec.StoredConversation
+	conversation.Items, conversation.Owner, conversation.TenantID = rec.Items, rec.Owner, rec.TenantID
+	return conversation, nil
+}
+
+// List implements domain.ConversationStore
+func (d *Dir) List() ([]*domain.StoredConversation, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var conversations []*domain.StoredConversation
+	for _, entry := range entries {
+		// Session links name the same conversations again
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		conversation, err := d.Get(id)
+		if errors.Is(err, domain.ErrConversationNotFound) {
+			continue // Deleted meanwhile
+		} else if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, nil
+}
+
+// Delete implements domain.ConversationStore, removing the conversation's
+// file and its session link
+func (d *Dir) Delete(id string) error {
+	conversation, err := d.Get(id)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{conversation.SessionID, conversation.ID} {
+		path, err := d.path(name)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns the file of a conversation, refusing IDs that are not plain
+// file names
+func (d *Dir) path(id string) (string, error) {
+	if !validID(id) {
+		return "", fmt.Errorf("invalid conversation ID %q", id)
+	}
+	return filepath.Join(d.dir, id+".json"), nil
+}
+
+// validID reports whether id holds only letters, digits, '_' and '-'
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package convstore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func conversation(id, sessionID string) *domain.StoredConversation {
+	return &domain.StoredConversation{
+		ID:        id,
+		Object:    "realtime.conversation",
+		SessionID: sessionID,
+		ItemCount: 1,
+		Items:     []domain.Item{*domain.NewItem("item_1", "message", "user")},
+		Owner:     domain.OwnerHash("key"),
+		TenantID:  "acme",
+	}
+}
+
+func TestMemory(t *testing.T) {
+	store := NewMemory(2)
+	for _, id := range []string{"1", "2", "3"} {
+		if err := store.Save(conversation("conv_"+id, "sess_"+id)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	// The oldest conversation is evicted under both of its IDs
+	for _, id := range []string{"conv_1", "sess_1"} {
+		if _, err := store.Get(id); !errors.Is(err, domain.ErrConversationNotFound) {
+			t.Errorf("Expected %s to be evicted, got %v", id, err)
+		}
+	}
+	for _, id := range []string{"conv_3", "sess_3"} {
+		if stored, err := store.Get(id); err != nil || stored.ID != "conv_3" {
+			t.Errorf("Expected conv_3 for %s, got %v, %v", id, stored, err)
+		}
+	}
+}
+
+func TestDir(t *testing.T) {
+	store, err := NewDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDir failed: %v", err)
+	}
+	saved := conversation("conv_1", "sess_1")
+	for i := 0; i < 2; i++ {
+		if err := store.Save(saved); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	for _, id := range []string{"conv_1", "sess_1"} {
+		stored, err := store.Get(id)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", id, err)
+		}
+		if stored.ID != "conv_1" || stored.SessionID != "sess_1" || len(stored.Items) != 1 ||
+			stored.Owner != saved.Owner || stored.TenantID != "acme" {
+			t.Errorf("Expected the saved conversation for %s, got %+v", id, stored)
+		}
+	}
+
+	for _, id := range []string{"conv_missing", "../conv_1", ""} {
+		if _, err := store.Get(id); !errors.Is(err, domain.ErrConversationNotFound) {
+			t.Errorf("Expected %q not to be found, got %v", id, err)
+		}
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	dir, err := NewDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDir failed: %v", err)
+	}
+	for name, store := range map[string]domain.ConversationStore{"memory": NewMemory(10), "dir": dir} {
+		for _, id := range []string{"1", "2"} {
+			if err := store.Save(conversation("conv_"+id, "sess_"+id)); err != nil {
+				t.Fatalf("%s: Save failed: %v", name, err)
+			}
+		}
+		if listed, err := store.List(); err != nil || len(listed) != 2 {
+			t.Fatalf("%s: Expected 2 conversations, got %v, %v", name, listed, err)
+		}
+
+		// Deleting by session ID removes the conversation under both IDs
+		if err := store.Delete("sess_1"); err != nil {
+			t.Fatalf("%s: Delete failed: %v", name, err)
+		}
+		for _, id := range []string{"conv_1", "sess_1"} {
+			if _, err := store.Get(id); !errors.Is(err, domain.ErrConversationNotFound) {
+				t.Errorf("%s: Expected %s to be deleted, got %v", name, id, err)
+			}
+		}
+		if err := store.Delete("conv_1"); !errors.Is(err, domain.ErrConversationNotFound) {
+			t.Errorf("%s: Expected deleting again to fail with not found, got %v", name, err)
+		}
+		if listed, _ := store.List(); len(listed) != 1 || listed[0].ID != "conv_2" {
+			t.Errorf("%s: Expected conv_2 left, got %v", name, listed)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Package fetch downloads audio from source URLs for batch transcription, so
+// callers need not proxy large files themselves. http and https URLs are
+// fetched directly; s3:// and gs:// objects are fetched over HTTPS, signed with
+// AWS Signature V4 when credentials are configured (Google Cloud Storage via
+// its S3-compatible XML API and HMAC keys).
+//
+// Only hosts on an allowlist are fetched, including after redirects, and
+// downloads are bounded in size and time.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotAllowed is returned for URLs outside the allowed hosts
+	ErrNotAllowed = errors.New("source URL host is not allowed")
+	// ErrTooLarge is returned when the source exceeds the size limit
+	ErrTooLarge = errors.New("source file exceeds the size limit")
+)
+
+// maxRedirects bounds the redirects followed for http(s) sources
+const maxRedirects = 5
+
+// Credentials sign requests to an object store. Without an access key,
+// objects are fetched anonymously.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string // Signing region, us-east-1 for S3 and auto for GCS if empty
+	Endpoint        string // Base URL replacing the provider's, e.g. "https://minio:9000"; objects are then addressed path-style
+}
+
+// Options configures a Fetcher
+type Options struct {
+	// AllowedHosts lists the hostnames http(s) sources may use, exactly or as
+	// "*.example.com", and the buckets of object store sources, as
+	// "s3://bucket" or "gs://bucket"
+	AllowedHosts []string
+	MaxBytes     int64         // Largest source accepted
+	Timeout      time.Duration // Time allowed for a whole download
+	S3           Credentials
+	GCS          Credentials
+}
+
+// Fetcher downloads source files
+type Fetcher struct {
+	opts   Options
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewFetcher creates a fetcher
+func NewFetcher(opts Options) *Fetcher {
+	f := &Fetcher{opts: opts, now: time.Now}
+	f.client = &http.Client{
+		Timeout: opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if !f.hostAllowed(req.URL) {
+				return fmt.Errorf("%w: redirected to %s", ErrNotAllowed, req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// Check validates a source URL without fetching it
+func (f *Fetcher) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid source URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Hostname() == "" {
+			return fmt.Errorf("invalid source URL %q: no host", rawURL)
+		}
+		if !f.hostAllowed(u) {
+			return fmt.Errorf("%w: %s", ErrNotAllowed, u.Hostname())
+		}
+	case "s3", "gs":
+		if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return fmt.Errorf("invalid source URL %q: expected %s://bucket/object", rawURL, u.Scheme)
+		}
+		if !containsFold(f.opts.AllowedHosts, u.Scheme+"://"+u.Host) {
+			return fmt.Errorf("%w: %s://%s", ErrNotAllowed, u.Scheme, u.Host)
+		}
+	default:
+		return fmt.Errorf("unsupported source URL scheme %q, use http, https, s3 or gs", u.Scheme)
+	}
+	return nil
+}
+
+// Download writes the source file to w and returns its size
+func (f *Fetcher) Download(ctx context.Context, rawURL string, w io.Writer) (int64, error) {
+	if err := f.Check(rawURL); err != nil {
+		return 0, err
+	}
+	req, err := f.request(ctx, rawURL)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("source returned %s", resp.Status)
+	}
+	if resp.ContentLength > f.opts.MaxBytes {
+		return 0, ErrTooLarge
+	}
+
+	n, err := io.Copy(w, io.LimitReader(resp.Body, f.opts.MaxBytes+1))
+	if err != nil {
+		return n, err
+	}
+	if n > f.opts.MaxBytes {
+		return n, ErrTooLarge
+	}
+	return n, nil
+}
+
+// request builds the GET request for a checked source URL
+func (f *Fetcher) request(ctx context.Context, rawURL string) (*http.Request, error) {
+	u, _ := url.Parse(rawURL)
+	switch u.Scheme {
+	case "s3":
+		creds := f.opts.S3
+		if creds.Region == "" {
+			creds.Region = "us-east-1"
+		}
+		return f.signedRequest(ctx, S3ObjectURL(u.Host, u.Path, creds), creds)
+
+	case "gs":
+		creds := f.opts.GCS
+		if creds.Region == "" {
+			creds.Region = "auto"
+		}
+		endpoint := "https://storage.googleapis.com"
+		if creds.Endpoint != "" {
+			endpoint = strings.TrimSuffix(creds.Endpoint, "/")
+		}
+		return f.signedRequest(ctx, endpoint+"/"+u.Host+"/"+escapeKey(u.Path), creds)
+
+	default:
+		return http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	}
+}
+
+// S3ObjectURL returns the HTTPS URL of an S3 object in creds.Region, on
+// creds.Endpoint if set
+func S3ObjectURL(bucket, key string, creds Credentials) string {
+	if creds.Endpoint != "" {
+		return strings.TrimSuffix(creds.Endpoint, "/") + "/" + bucket + "/" + escapeKey(key)
+	}
+	return "https://" + bucket + ".s3." + creds.Region + ".amazonaws.com/" + escapeKey(key)
+}
+
+// signedRequest builds an object store GET, signed if credentials are set
+func (f *Fetcher) signedRequest(ctx context.Context, target string, creds Credentials) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if creds.AccessKeyID != "" {
+		SignV4(req, creds, f.now())
+	}
+	return req, nil
+}
+
+// hostAllowed reports whether an http(s) URL's host is on the allowlist
+func (f *Fetcher) hostAllowed(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range f.opts.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if allowed == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeKey escapes each segment of an object key for use in a URL path
+func escapeKey(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+// Package g711 decodes G.711 μ-law and A-law audio (ITU-T G.711) to linear
+// 16-bit PCM, as sent by telephony clients using audio/pcmu and audio/pcma.
+package g711
+
+import "encoding/binary"
+
+var (
+	ulawTable [256]int16
+	alawTable [256]int16
+)
+
+func init() {
+	for i := 0; i < 256; i++ {
+		ulawTable[i] = decodeUlaw(byte(i))
+		alawTable[i] = decodeAlaw(byte(i))
+	}
+}
+
+func decodeUlaw(u byte) int16 {
+	u = ^u
+	exponent := (u >> 4) & 0x07
+	mantissa := int(u & 0x0F)
+	sample := ((mantissa << 3) + 0x84) << exponent
+	sample -= 0x84
+	if u&0x80 != 0 {
+		return int16(-sample)
+	}
+	return int16(sample)
+}
+
+func decodeAlaw(a byte) int16 {
+	a ^= 0x55
+	exponent := (a >> 4) & 0x07
+	mantissa := int(a & 0x0F)
+	var sample int
+	if exponent == 0 {
+		sample = (mantissa << 4) + 8
+	} else {
+		sample = ((mantissa << 4) + 0x108) << (exponent - 1)
+	}
+	if a&0x80 != 0 {
+		return int16(sample)
+	}
+	return int16(-sample)
+}
+
+// Ulaw returns the linear value of a μ-law sample
+func Ulaw(u byte) int16 {
+	return ulawTable[u]
+}
+
+// Alaw returns the linear value of an A-law sample
+func Alaw(a byte) int16 {
+	return alawTable[a]
+}
+
+// UlawToPCM16 decodes μ-law audio to little-endian 16-bit PCM
+func UlawToPCM16(src []byte) []byte {
+	return toPCM16(src, &ulawTable)
+}
+
+// AlawToPCM16 decodes A-law audio to little-endian 16-bit PCM
+func AlawToPCM16(src []byte) []byte {
+	return toPCM16(src, &alawTable)
+}
+
+func toPCM16(src []byte, table *[256]int16) []byte {
+	pcm := make([]byte, len(src)*2)
+	for i, b := range src {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(table[b]))
+	}
+	return pcm
+}
@@ -0,0 +1,32 @@
+package g711
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode func(byte) int16
+		in     byte
+		want   int16
+	}{
+		{"ulaw silence", Ulaw, 0xFF, 0},
+		{"ulaw max negative", Ulaw, 0x00, -32124},
+		{"ulaw max positive", Ulaw, 0x80, 32124},
+		{"alaw small positive", Alaw, 0xD5, 8},
+		{"alaw small negative", Alaw, 0x55, -8},
+		{"alaw max positive", Alaw, 0xAA, 32256},
+	}
+	for _, tt := range tests {
+		if got := tt.decode(tt.in); got != tt.want {
+			t.Errorf("%s: decode(0x%02X) = %d, want %d", tt.name, tt.in, got, tt.want)
+		}
+	}
+
+	pcm := UlawToPCM16([]byte{0xFF, 0x80})
+	if len(pcm) != 4 || int16(binary.LittleEndian.Uint16(pcm[2:])) != 32124 {
+		t.Errorf("Unexpected PCM16 output %v", pcm)
+	}
+}
@@ -0,0 +1,71 @@
+// Package goroutines counts the running goroutines by the package that
+// started them, to tell which subsystem leaks goroutines.
+package goroutines
+
+import (
+	"bufio"
+	"bytes"
+	"runtime"
+	"strings"
+)
+
+// module is the import path prefix trimmed from the server's own packages
+const module = "github.com/aira-id/gribe/internal/"
+
+// BySubsystem counts the running goroutines by the package of the function
+// that started them, e.g. "usecase", "pkg/sherpa" or "net/http". The main
+// goroutine counts as "main".
+func BySubsystem() map[string]int {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return count(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// count parses the goroutine dump of runtime.Stack
+func count(dump []byte) map[string]int {
+	counts := make(map[string]int)
+	goroutine := false
+	creator := ""
+	flush := func() {
+		if !goroutine {
+			return
+		}
+		if creator == "" {
+			creator = "main"
+		}
+		counts[creator]++
+		goroutine, creator = false, ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	scanner.Buffer(make([]byte, 0, 4096), len(dump)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			flush()
+			goroutine = true
+		case strings.HasPrefix(line, "created by "):
+			function, _, _ := strings.Cut(strings.TrimPrefix(line, "created by "), " in goroutine ")
+			creator = subsystem(function)
+		}
+	}
+	flush()
+	return counts
+}
+
+// subsystem returns the package of a function as printed in stack traces,
+// e.g. "usecase" for "github.com/aira-id/gribe/internal/usecase.(*vadWorker).Stop"
+func subsystem(function string) string {
+	dir, name := "", function
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		dir, name = function[:i+1], function[i+1:]
+	}
+	pkg, _, _ := strings.Cut(name, ".")
+	return strings.TrimPrefix(dir+pkg, module)
+}
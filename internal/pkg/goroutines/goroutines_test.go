@@ -0,0 +1,48 @@
+package goroutines
+
+import "testing"
+
+func TestCount(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/src/main.go:10 +0x1d
+
+goroutine 7 [chan receive]:
+github.com/aira-id/gribe/internal/usecase.(*SessionUsecase).startVADWorker.func1()
+	/src/internal/usecase/vad_worker.go:40 +0x5a
+created by github.com/aira-id/gribe/internal/usecase.(*SessionUsecase).startVADWorker in goroutine 6
+	/src/internal/usecase/vad_worker.go:38 +0x11c
+
+goroutine 8 [select]:
+github.com/aira-id/gribe/internal/pkg/sherpa.(*Provider).StreamTranscribe.func1()
+	/src/internal/pkg/sherpa/provider.go:120 +0x5a
+created by github.com/aira-id/gribe/internal/pkg/sherpa.(*Provider).StreamTranscribe in goroutine 7
+	/src/internal/pkg/sherpa/provider.go:110 +0x11c
+
+goroutine 9 [IO wait]:
+net/http.(*conn).serve(0xc000)
+	/go/src/net/http/server.go:2009 +0x5f4
+created by net/http.(*Server).Serve in goroutine 1
+	/go/src/net/http/server.go:3086 +0x5cb
+
+goroutine 10 [chan receive]:
+github.com/aira-id/gribe/internal/usecase.(*SessionUsecase).transcribeAudio()
+	/src/internal/usecase/session_usecase.go:1040 +0x5a
+created by github.com/aira-id/gribe/internal/usecase.(*SessionUsecase).commitItem in goroutine 6
+	/src/internal/usecase/session_usecase.go:1036 +0x11c
+`
+	want := map[string]int{"main": 1, "usecase": 2, "pkg/sherpa": 1, "net/http": 1}
+	got := count([]byte(dump))
+	if len(got) != len(want) {
+		t.Fatalf("count = %v, want %v", got, want)
+	}
+	for subsystem, n := range want {
+		if got[subsystem] != n {
+			t.Errorf("count[%q] = %d, want %d", subsystem, got[subsystem], n)
+		}
+	}
+
+	if live := BySubsystem(); live["main"] == 0 && live["testing"] == 0 {
+		t.Errorf("BySubsystem() = %v, missing the test's own goroutines", live)
+	}
+}
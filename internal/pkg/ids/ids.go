@@ -0,0 +1,192 @@
+// Package ids generates the unique part of the IDs of sessions, items,
+// events and other objects, by one of several strategies. Callers add the
+// prefix naming the kind of object.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Strategies
+const (
+	StrategyShortUUID  = "short-uuid" // First 12 characters of a random UUID
+	StrategyUUID       = "uuid"       // Random (version 4) UUID
+	StrategyULID       = "ulid"       // 26-character ULID, sorting by creation time
+	StrategySnowflake  = "snowflake"  // Decimal 63-bit snowflake ID of a node, sorting by creation time
+	StrategySequential = "sequential" // Counter per kind of object, deterministic for tests
+)
+
+// MaxNode is the largest snowflake node ID
+const MaxNode = 1<<snowflakeNodeBits - 1
+
+// Generator generates IDs
+type Generator interface {
+	// New returns a new ID for an object of kind, e.g. "session"
+	New(kind string) string
+}
+
+// New creates a generator of strategy; node distinguishes the snowflake IDs
+// of the servers of a deployment, which must each have their own
+func New(strategy string, node int) (Generator, error) {
+	switch strategy {
+	case "", StrategyShortUUID:
+		return ShortUUID{}, nil
+	case StrategyUUID:
+		return UUID{}, nil
+	case StrategyULID:
+		return NewULID(), nil
+	case StrategySnowflake:
+		return NewSnowflake(node)
+	case StrategySequential:
+		return NewSequence(), nil
+	}
+	return nil, fmt.Errorf("unknown ID strategy %q", strategy)
+}
+
+// ShortUUID generates the first 12 characters of random UUIDs: 48 random
+// bits, the historical format
+type ShortUUID struct{}
+
+// New returns a shortened UUID
+func (ShortUUID) New(string) string {
+	return uuid.New().String()[:12]
+}
+
+// UUID generates random UUIDs
+type UUID struct{}
+
+// New returns a random UUID
+func (UUID) New(string) string {
+	return uuid.New().String()
+}
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID generates ULIDs: a millisecond timestamp then 80 random bits, in
+// Crockford base32. IDs of the same millisecond increment the random bits,
+// so they sort in the order they were generated.
+type ULID struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	lastMs  uint64
+	entropy [10]byte // Random bits of the last ID
+}
+
+// NewULID creates a ULID generator
+func NewULID() *ULID {
+	return &ULID{now: time.Now}
+}
+
+// New returns a ULID
+func (g *ULID) New(string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ms := uint64(g.now().UnixMilli())
+	if ms > g.lastMs {
+		g.lastMs = ms
+		rand.Read(g.entropy[:])
+	} else {
+		// Same millisecond, or the clock went back: continue from the last ID
+		for i := len(g.entropy) - 1; i >= 0; i-- {
+			g.entropy[i]++
+			if g.entropy[i] != 0 {
+				break
+			}
+		}
+	}
+
+	// 128 bits as 26 characters of 5 bits, the first holding the top 3
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:], uint16(g.lastMs>>32))
+	binary.BigEndian.PutUint32(id[2:], uint32(g.lastMs))
+	copy(id[6:], g.entropy[:])
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// Snowflake layout: 41 bits of milliseconds since the epoch, 10 of node
+// and 12 of sequence within the millisecond
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+)
+
+// snowflakeEpoch is the start of snowflake time, 2024-01-01 UTC, leaving
+// them good until 2093
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake generates snowflake IDs. Up to 4096 are generated per
+// millisecond; more wait for the next one.
+type Snowflake struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	node     int64
+	lastMs   int64
+	sequence int64
+}
+
+// NewSnowflake creates a snowflake generator for node, 0 to MaxNode
+func NewSnowflake(node int) (*Snowflake, error) {
+	if node < 0 || node > MaxNode {
+		return nil, fmt.Errorf("snowflake node must be 0-%d, got %d", MaxNode, node)
+	}
+	return &Snowflake{now: time.Now, node: int64(node)}, nil
+}
+
+// New returns a snowflake ID
+func (g *Snowflake) New(string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ms := g.now().Sub(snowflakeEpoch).Milliseconds()
+	if ms < g.lastMs {
+		ms = g.lastMs // The clock went back: keep IDs increasing
+	}
+	if ms == g.lastMs {
+		g.sequence = (g.sequence + 1) & (1<<snowflakeSequenceBits - 1)
+		if g.sequence == 0 {
+			for ms <= g.lastMs {
+				time.Sleep(100 * time.Microsecond)
+				ms = g.now().Sub(snowflakeEpoch).Milliseconds()
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = ms
+	id := ms<<(snowflakeNodeBits+snowflakeSequenceBits) | g.node<<snowflakeSequenceBits | g.sequence
+	return strconv.FormatInt(id, 10)
+}
+
+// Sequence numbers the objects of each kind from 1, so the same run always
+// produces the same IDs
+type Sequence struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewSequence creates a sequential generator
+func NewSequence() *Sequence {
+	return &Sequence{counts: make(map[string]int)}
+}
+
+// New returns the next number of kind, zero-padded to 12 digits
+func (g *Sequence) New(kind string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.counts[kind]++
+	return fmt.Sprintf("%012d", g.counts[kind])
+}
@@ -0,0 +1,89 @@
+package ids
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStrategies(t *testing.T) {
+	for strategy, format := range map[string]string{
+		"":                 `^[0-9a-f]{8}-[0-9a-f]{3}$`,
+		StrategyShortUUID:  `^[0-9a-f]{8}-[0-9a-f]{3}$`,
+		StrategyUUID:       `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+		StrategyULID:       `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`,
+		StrategySnowflake:  `^[1-9][0-9]{10,18}$`,
+		StrategySequential: `^0{11}1$`,
+	} {
+		gen, err := New(strategy, 1)
+		if err != nil {
+			t.Fatalf("New(%q) failed: %v", strategy, err)
+		}
+		seen := make(map[string]bool)
+		for i := 0; i < 1000; i++ {
+			id := gen.New("session")
+			if i == 0 && !regexp.MustCompile(format).MatchString(id) {
+				t.Errorf("%q: unexpected ID %q", strategy, id)
+			}
+			if seen[id] {
+				t.Fatalf("%q: ID %q generated twice", strategy, id)
+			}
+			seen[id] = true
+		}
+	}
+
+	if _, err := New("random", 0); err == nil {
+		t.Error("Expected an unknown strategy to be refused")
+	}
+	if _, err := New(StrategySnowflake, MaxNode+1); err == nil {
+		t.Error("Expected a node beyond MaxNode to be refused")
+	}
+}
+
+func TestULIDOrder(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	gen := NewULID()
+	gen.now = func() time.Time { return now }
+
+	first, second := gen.New(""), gen.New("")
+	now = now.Add(-time.Second) // Clock adjusted backwards
+	third := gen.New("")
+	now = now.Add(time.Hour)
+	fourth := gen.New("")
+	if !(first < second && second < third && third < fourth) {
+		t.Errorf("Expected increasing ULIDs, got %s %s %s %s", first, second, third, fourth)
+	}
+	if first[:10] != second[:10] || first[:10] == fourth[:10] {
+		t.Errorf("Expected the timestamp in the first 10 characters, got %s and %s", first, fourth)
+	}
+	// 1700000000000ms is 01HF7YAT00 in Crockford base32
+	if first[:10] != "01HF7YAT00" {
+		t.Errorf("Expected timestamp 01HF7YAT00, got %s", first[:10])
+	}
+}
+
+func TestSnowflake(t *testing.T) {
+	now := snowflakeEpoch.Add(time.Hour)
+	gen, _ := NewSnowflake(5)
+	gen.now = func() time.Time { return now }
+
+	id, _ := strconv.ParseInt(gen.New(""), 10, 64)
+	next, _ := strconv.ParseInt(gen.New(""), 10, 64)
+	if ms := id >> 22; ms != time.Hour.Milliseconds() {
+		t.Errorf("Expected an hour since the epoch, got %dms", ms)
+	}
+	if node, sequence := id>>12&MaxNode, id&4095; node != 5 || sequence != 0 {
+		t.Errorf("Expected node 5 and sequence 0, got %d and %d", node, sequence)
+	}
+	if next != id+1 {
+		t.Errorf("Expected the next ID of the millisecond to increment the sequence, got %d after %d", next, id)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	gen := NewSequence()
+	if a, b, c := gen.New("item"), gen.New("item"), gen.New("event"); a != "000000000001" || b != "000000000002" || c != "000000000001" {
+		t.Errorf("Expected items and events numbered apart, got %s %s %s", a, b, c)
+	}
+}
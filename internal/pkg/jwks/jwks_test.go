@@ -0,0 +1,152 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testIdP struct {
+	mu    sync.Mutex
+	keys  map[string]*rsa.PrivateKey
+	calls int
+}
+
+func (p *testIdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	for kid, key := range p.keys {
+		doc.Keys = append(doc.Keys, jsonWebKey{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(doc)
+}
+
+func (p *testIdP) rotate(t *testing.T, kid string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.keys = map[string]*rsa.PrivateKey{kid: key}
+	p.mu.Unlock()
+	return key
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyWithKeyRotation(t *testing.T) {
+	idp := &testIdP{}
+	key1 := idp.rotate(t, "k1")
+	srv := httptest.NewServer(idp)
+	defer srv.Close()
+
+	keys := NewKeySet(srv.URL, time.Hour)
+	keys.minRefetch = 0
+	keys.Start()
+	defer keys.Close()
+	verifier := NewVerifier(keys, "https://idp.example", "gribe")
+
+	claims := map[string]interface{}{
+		"iss":   "https://idp.example",
+		"aud":   []string{"gribe"},
+		"sub":   "user-1",
+		"scope": "realtime:transcribe admin:read",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	got, err := verifier.Verify(context.Background(), sign(t, key1, "k1", claims))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got.String("sub") != "user-1" || len(got.Strings("scope")) != 2 {
+		t.Errorf("Unexpected claims: %v", got)
+	}
+
+	// The IdP rotates keys; a token with the new kid triggers a refetch
+	key2 := idp.rotate(t, "k2")
+	if _, err := verifier.Verify(context.Background(), sign(t, key2, "k2", claims)); err != nil {
+		t.Fatalf("Verify after rotation failed: %v", err)
+	}
+	if idp.calls != 2 {
+		t.Errorf("Expected 2 JWKS fetches, got %d", idp.calls)
+	}
+
+	// Tokens signed by the retired key are rejected
+	if _, err := verifier.Verify(context.Background(), sign(t, key1, "k1", claims)); err == nil {
+		t.Error("Expected token signed with retired key to be rejected")
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	idp := &testIdP{}
+	key := idp.rotate(t, "k1")
+	srv := httptest.NewServer(idp)
+	defer srv.Close()
+
+	keys := NewKeySet(srv.URL, time.Hour)
+	keys.Start()
+	defer keys.Close()
+	verifier := NewVerifier(keys, "https://idp.example", "gribe")
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": "https://idp.example",
+			"aud": "gribe",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	expired := valid()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	wrongIssuer := valid()
+	wrongIssuer["iss"] = "https://evil.example"
+	wrongAudience := valid()
+	wrongAudience["aud"] = "other"
+	noExpiry := valid()
+	delete(noExpiry, "exp")
+
+	tests := map[string]string{
+		"expired":        sign(t, key, "k1", expired),
+		"wrong issuer":   sign(t, key, "k1", wrongIssuer),
+		"wrong audience": sign(t, key, "k1", wrongAudience),
+		"no expiry":      sign(t, key, "k1", noExpiry),
+		"unknown kid":    sign(t, key, "k9", valid()),
+		"tampered":       sign(t, key, "k1", valid()) + "x",
+		"malformed":      "not-a-token",
+	}
+	for name, token := range tests {
+		if _, err := verifier.Verify(context.Background(), token); err == nil {
+			t.Errorf("%s: expected token to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package jwks verifies JWT bearer tokens against signing keys published at a
+// JWKS URL. Keys are cached, refreshed in the background and refetched when a
+// token references an unknown key id, so IdP key rotation needs no restart.
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound is returned when no key with the requested id is published
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// defaultMinRefetch bounds how often an unknown kid can trigger a fetch
+const defaultMinRefetch = 30 * time.Second
+
+// jsonWebKey is a single entry of a JWKS document
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet caches the public keys published at a JWKS URL
+type KeySet struct {
+	url        string
+	refresh    time.Duration
+	minRefetch time.Duration
+	client     *http.Client
+	keys       map[string]crypto.PublicKey
+	lastFetch  time.Time
+	mu         sync.RWMutex
+	fetchMu    sync.Mutex
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// NewKeySet creates a key set for url, refreshed every refresh interval once started
+func NewKeySet(url string, refresh time.Duration) *KeySet {
+	if refresh <= 0 {
+		refresh = time.Hour
+	}
+	return &KeySet{
+		url:        url,
+		refresh:    refresh,
+		minRefetch: defaultMinRefetch,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		keys:       make(map[string]crypto.PublicKey),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start performs the initial fetch and begins background refresh.
+// A failed initial fetch is logged; keys are fetched again on first use.
+func (s *KeySet) Start() {
+	if err := s.Refresh(context.Background()); err != nil {
+		log.Printf("[WARN] Initial JWKS fetch from %s failed: %v", s.url, err)
+	}
+	go s.refreshLoop()
+}
+
+// Close stops background refresh
+func (s *KeySet) Close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+func (s *KeySet) refreshLoop() {
+	ticker := time.NewTicker(s.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Refresh(context.Background()); err != nil {
+				log.Printf("[WARN] JWKS refresh from %s failed, keeping cached keys: %v", s.url, err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Key returns the public key with the given id, refetching the key set if
+// the id is unknown and the last fetch is old enough
+func (s *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	lastFetch := s.lastFetch
+	s.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if time.Since(lastFetch) < s.minRefetch {
+		return nil, ErrKeyNotFound
+	}
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Refresh fetches the key set now, replacing the cached keys on success
+func (s *KeySet) Refresh(ctx context.Context) error {
+	// Serialize fetches so a burst of unknown kids causes a single request
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	s.mu.Lock()
+	s.lastFetch = time.Now()
+	s.mu.Unlock()
+
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *KeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS document: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Printf("[WARN] Skipping JWKS key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS document contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey converts the JWK into an RSA or ECDSA public key
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
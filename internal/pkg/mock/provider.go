@@ -3,16 +3,24 @@ package mock
 import (
 	"context"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/aira-id/gribe/internal/domain"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
 // Provider is a mock implementation of ASRProvider for testing
 type Provider struct {
+	mu          sync.Mutex
 	delay       time.Duration
 	chunkDelay  time.Duration
 	mockResults []string
+	scenario    *Scenario
+	calls       int                        // Transcribe calls made since the scenario was set
+	inFlight    int                        // Transcribe calls still streaming
+	prompts     bool                       // Whether it claims prompt and temperature support
+	translation bool                       // Whether it claims translation support
+	lastConfig  domain.TranscriptionConfig // Of the latest Transcribe call
 }
 
 // New creates a new mock ASR provider
@@ -32,21 +40,40 @@ func New() *Provider {
 
 // Transcribe implements ASRProvider.Transcribe
 func (m *Provider) Transcribe(ctx context.Context, audio []byte, config *domain.TranscriptionConfig) (<-chan domain.TranscriptionChunk, error) {
-	resultChan := make(chan domain.TranscriptionChunk, len(m.mockResults)+1)
+	m.mu.Lock()
+	m.lastConfig = *config
+	m.mu.Unlock()
+	step := m.nextStep(audio)
+	if step.Error != "" {
+		return nil, step.err(step.Error)
+	}
+
+	resultChan := make(chan domain.TranscriptionChunk, len(step.Partials)+1)
 
+	m.track(1)
 	go func() {
+		defer m.track(-1)
 		defer close(resultChan)
 
 		// Simulate processing delay based on audio length
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(m.delay):
+		case <-time.After(step.Delay):
+		}
+
+		if step.Hang {
+			<-ctx.Done()
+			return
 		}
 
 		// Stream mock transcription chunks
 		var fullText strings.Builder
-		for i, text := range m.mockResults {
+		for i, text := range step.Partials {
+			if step.StreamError != "" && i == step.FailAfter {
+				break
+			}
+
 			select {
 			case <-ctx.Done():
 				return
@@ -54,26 +81,87 @@ func (m *Provider) Transcribe(ctx context.Context, audio []byte, config *domain.
 			}
 
 			fullText.WriteString(text)
-			isLast := i == len(m.mockResults)-1
+			isLast := i == len(step.Partials)-1 && step.StreamError == ""
 
 			chunk := domain.TranscriptionChunk{
-				Text:    text,
-				IsFinal: isLast,
-				StartMs: i * 100,
-				EndMs:   (i + 1) * 100,
+				Text:     text,
+				IsFinal:  isLast,
+				StartMs:  i * 100,
+				EndMs:    (i + 1) * 100,
+				Logprobs: []domain.Logprob{{Token: text, Logprob: -0.25}},
+				Words:    words(text, i*100, (i+1)*100),
+			}
+			if isLast {
+				chunk.NoSpeechProb = 0.01
 			}
 
 			resultChan <- chunk
 
 			if !isLast {
-				time.Sleep(m.chunkDelay)
+				time.Sleep(step.ChunkDelay)
 			}
 		}
+
+		if step.StreamError != "" {
+			resultChan <- domain.TranscriptionChunk{Err: step.err(step.StreamError)}
+		}
 	}()
 
 	return resultChan, nil
 }
 
+// words spreads the words of text evenly over startMs to endMs
+func words(text string, startMs, endMs int) []domain.WordTiming {
+	fields := strings.Fields(text)
+	timings := make([]domain.WordTiming, len(fields))
+	for i, field := range fields {
+		span := (endMs - startMs) / len(fields)
+		timings[i] = domain.WordTiming{Word: field, StartMs: startMs + i*span, EndMs: startMs + (i+1)*span}
+	}
+	return timings
+}
+
+func (m *Provider) track(delta int) {
+	m.mu.Lock()
+	m.inFlight += delta
+	m.mu.Unlock()
+}
+
+// InFlight returns the number of Transcribe calls still streaming results
+func (m *Provider) InFlight() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inFlight
+}
+
+// nextStep returns the scripted behaviour for the next Transcribe call
+func (m *Provider) nextStep(audio []byte) Step {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	defaults := Step{
+		Delay:      m.delay,
+		ChunkDelay: m.chunkDelay,
+		Partials:   m.mockResults,
+	}
+	if m.scenario == nil {
+		return defaults
+	}
+
+	step := m.scenario.resolve(m.calls, audio)
+	m.calls++
+	if step.Delay == 0 {
+		step.Delay = defaults.Delay
+	}
+	if step.ChunkDelay == 0 {
+		step.ChunkDelay = defaults.ChunkDelay
+	}
+	if len(step.Partials) == 0 {
+		step.Partials = defaults.Partials
+	}
+	return step
+}
+
 // TranscribeStream implements ASRProvider.TranscribeStream
 func (m *Provider) TranscribeStream(ctx context.Context, config *domain.TranscriptionConfig) (chan<- []byte, <-chan domain.TranscriptionChunk, error) {
 	audioIn := make(chan []byte, 100)
@@ -163,6 +251,11 @@ func (m *Provider) GetSupportedLanguages() []string {
 	return []string{"en", "es", "fr", "de", "ja", "zh"}
 }
 
+// HealthCheck implements ASRProvider.HealthCheck
+func (m *Provider) HealthCheck() domain.ProviderHealth {
+	return domain.ProviderHealth{ModelLoaded: true, Mock: true}
+}
+
 // Close implements ASRProvider.Close
 func (m *Provider) Close() error {
 	return nil
@@ -170,11 +263,75 @@ func (m *Provider) Close() error {
 
 // SetMockResults allows setting custom mock transcription results
 func (m *Provider) SetMockResults(results []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.mockResults = results
 }
 
 // SetDelay allows setting custom delays for testing
 func (m *Provider) SetDelay(initial, chunk time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.delay = initial
 	m.chunkDelay = chunk
 }
+
+// SetPromptSupport makes the provider claim support for transcription
+// prompts and temperature, or not (the default)
+func (m *Provider) SetPromptSupport(supported bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompts = supported
+}
+
+// SupportsPrompt implements domain.PromptCapable
+func (m *Provider) SupportsPrompt() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.prompts
+}
+
+// SupportsTemperature implements domain.PromptCapable
+func (m *Provider) SupportsTemperature() bool {
+	return m.SupportsPrompt()
+}
+
+// SetTranslationSupport makes the provider claim support for translating
+// to English, or not (the default)
+func (m *Provider) SetTranslationSupport(supported bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.translation = supported
+}
+
+// SupportsTranslation implements domain.TranslationCapable
+func (m *Provider) SupportsTranslation() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.translation
+}
+
+// LastConfig returns the transcription config of the latest Transcribe call
+func (m *Provider) LastConfig() domain.TranscriptionConfig {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastConfig
+}
+
+// SetScenario scripts subsequent Transcribe calls (nil restores the defaults)
+func (m *Provider) SetScenario(scenario *Scenario) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scenario = scenario
+	m.calls = 0
+}
+
+// LoadScenario reads a scenario file and applies it
+func (m *Provider) LoadScenario(path string) error {
+	scenario, err := LoadScenario(path)
+	if err != nil {
+		return err
+	}
+	m.SetScenario(scenario)
+	return nil
+}
@@ -0,0 +1,105 @@
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Step scripts the behaviour of a single Transcribe call
+type Step struct {
+	Delay       time.Duration `yaml:"delay"`        // Delay before the first chunk (0 = scenario default)
+	ChunkDelay  time.Duration `yaml:"chunk_delay"`  // Delay between chunks (0 = scenario default)
+	Partials    []string      `yaml:"partials"`     // Chunks to stream (empty = scenario default)
+	Error       string        `yaml:"error"`        // Returned from Transcribe before streaming starts
+	StreamError string        `yaml:"stream_error"` // Sent as a failing chunk after FailAfter partials
+	FailAfter   int           `yaml:"fail_after"`   // Number of partials to emit before StreamError
+	Hang        bool          `yaml:"hang"`         // Never finish, so the caller's timeout fires
+	Transient   bool          `yaml:"transient"`    // Error and StreamError wrap domain.ErrTransient, so they are retried
+}
+
+// Scenario is a fixture that drives the mock provider deterministically.
+// Top-level step fields are the defaults. Steps are consumed one per
+// Transcribe call; once exhausted the defaults apply again. Transcripts
+// maps the sha256 of the audio (see AudioHash) to the partials to return
+// and takes precedence over Steps.
+type Scenario struct {
+	Step        `yaml:",inline"`
+	Steps       []Step              `yaml:"steps"`
+	Transcripts map[string][]string `yaml:"transcripts"`
+}
+
+// ParseScenario parses a YAML (or JSON) scenario
+func ParseScenario(data []byte) (*Scenario, error) {
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("invalid mock scenario: %w", err)
+	}
+	if scenario.StreamError == "" && scenario.FailAfter > 0 {
+		return nil, fmt.Errorf("invalid mock scenario: fail_after set without stream_error")
+	}
+	for i, step := range scenario.Steps {
+		if step.StreamError == "" && step.FailAfter > 0 {
+			return nil, fmt.Errorf("invalid mock scenario: step %d sets fail_after without stream_error", i)
+		}
+	}
+	return &scenario, nil
+}
+
+// LoadScenario reads a scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+// err builds the step's scripted error
+func (s Step) err(message string) error {
+	if s.Transient {
+		return fmt.Errorf("%w: %s", domain.ErrTransient, message)
+	}
+	return errors.New(message)
+}
+
+// AudioHash returns the key used for Scenario.Transcripts
+func AudioHash(audio []byte) string {
+	sum := sha256.Sum256(audio)
+	return hex.EncodeToString(sum[:])
+}
+
+// resolve picks the step for the given call number and audio,
+// filling unset fields from the scenario defaults
+func (s *Scenario) resolve(call int, audio []byte) Step {
+	step := s.Step
+	if partials, ok := s.Transcripts[AudioHash(audio)]; ok {
+		step.Partials = partials
+		step.Error = ""
+		step.StreamError = ""
+		step.Hang = false
+		return step
+	}
+
+	if call < len(s.Steps) {
+		override := s.Steps[call]
+		if override.Delay == 0 {
+			override.Delay = step.Delay
+		}
+		if override.ChunkDelay == 0 {
+			override.ChunkDelay = step.ChunkDelay
+		}
+		if len(override.Partials) == 0 {
+			override.Partials = step.Partials
+		}
+		step = override
+	}
+	return step
+}
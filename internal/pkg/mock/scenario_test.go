@@ -0,0 +1,81 @@
+// Scenario tests run the realtime harness, which imports this package.
+
+package mock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+func TestScenarioFailurePaths(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Audio.TranscriptionTimeout = 200 * time.Millisecond
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+
+	if err := srv.Provider.LoadScenario("testdata/failures.yaml"); err != nil {
+		t.Fatalf("LoadScenario failed: %v", err)
+	}
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectFailure := func(code string) {
+		t.Helper()
+		event, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionFailed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var failed domain.ErrorServerEvent
+		if err := event.Decode(&failed); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if failed.Error.Code != code {
+			t.Errorf("Expected code %s, got %s", code, failed.Error.Code)
+		}
+	}
+
+	commit := func() {
+		t.Helper()
+		if err := client.AppendAudio(make([]byte, 320)); err != nil {
+			t.Fatalf("AppendAudio failed: %v", err)
+		}
+		if err := client.Commit(); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+
+	// Step 1: normal transcript
+	commit()
+	event, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var completed domain.ConversationItemInputAudioTranscriptionCompletedEvent
+	event.Decode(&completed)
+	if completed.Transcript != "first segment" {
+		t.Errorf("Expected 'first segment', got %q", completed.Transcript)
+	}
+
+	// Step 2: provider rejects the request
+	commit()
+	expectFailure("transcription_failed")
+
+	// Step 3: provider fails mid-stream
+	commit()
+	expectFailure("transcription_failed")
+
+	// Step 4: provider hangs until the transcription timeout
+	commit()
+	expectFailure("transcription_timeout")
+}
@@ -0,0 +1,142 @@
+// Package modelfetch downloads model files missing on disk, so deployments
+// can start from an empty models directory. Downloads go to a ".part" file
+// next to the target and resume where they stopped, across attempts and
+// restarts, with an HTTP range request. A file only takes its final name
+// once complete and, if a checksum is given, verified.
+package modelfetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Attempts is how many times a download is tried, each resuming the last
+const Attempts = 3
+
+// Fetcher downloads model files
+type Fetcher struct {
+	Client  *http.Client
+	Backoff time.Duration // Wait before the second attempt, doubling after
+}
+
+// NewFetcher creates a fetcher with the default HTTP client
+func NewFetcher() *Fetcher {
+	return &Fetcher{Client: http.DefaultClient, Backoff: time.Second}
+}
+
+// Fetch downloads rawURL to path unless path exists. A non-empty checksum
+// is the file's hex sha256; a download that does not match is discarded.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL, path, checksum string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	part := path + ".part"
+	backoff := f.Backoff
+	var err error
+	for attempt := 1; attempt <= Attempts; attempt++ {
+		if err = f.download(ctx, rawURL, part); err == nil {
+			break
+		}
+		if ctx.Err() != nil || attempt == Attempts {
+			return fmt.Errorf("downloading %s: %w", rawURL, err)
+		}
+		log.Printf("[WARN] Downloading %s failed (attempt %d of %d), resuming in %v: %v",
+			rawURL, attempt, Attempts, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+
+	if checksum != "" {
+		got, err := fileSHA256(part)
+		if err != nil {
+			return err
+		}
+		if got != strings.ToLower(checksum) {
+			os.Remove(part)
+			return fmt.Errorf("downloaded %s has sha256 %s, expected %s", rawURL, got, checksum)
+		}
+	}
+	return os.Rename(part, path)
+}
+
+// download fetches rawURL into part, continuing from the bytes it holds
+func (f *Fetcher) download(ctx context.Context, rawURL, part string) error {
+	file, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		log.Printf("[INFO] Resuming download of %s at %d bytes", rawURL, offset)
+	case http.StatusOK:
+		// The server sent the whole file
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		log.Printf("[INFO] Downloading %s", rawURL)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Nothing past what we have: the previous attempt got it all
+		return nil
+	default:
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// fileSHA256 returns the hex sha256 of a file
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
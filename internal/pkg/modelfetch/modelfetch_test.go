@@ -0,0 +1,84 @@
+package modelfetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	content := []byte(strings.Repeat("model weights ", 100))
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	var ranges []string
+	failFirst := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.URL.Path == "/flaky" && failFirst {
+			// Cut the first download short
+			failFirst = false
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			w.Write(content[:100])
+			return
+		}
+		var offset int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &offset); err == nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[offset:])
+			return
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	f := &Fetcher{Client: server.Client()}
+
+	// An interrupted download resumes where it stopped
+	path := filepath.Join(dir, "model", "encoder.onnx")
+	if err := f.Fetch(context.Background(), server.URL+"/flaky", path, checksum); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(content) {
+		t.Fatalf("Expected the complete file, got %d bytes", len(data))
+	}
+	if len(ranges) != 2 || ranges[1] != "bytes=100-" {
+		t.Errorf("Expected a second request resuming at byte 100, got ranges %q", ranges)
+	}
+
+	// Existing files are not fetched again
+	ranges = nil
+	if err := f.Fetch(context.Background(), server.URL+"/flaky", path, checksum); err != nil || len(ranges) != 0 {
+		t.Errorf("Expected an existing file to be kept, got %v after %d requests", err, len(ranges))
+	}
+
+	// A partial file left by an earlier run is resumed
+	path = filepath.Join(dir, "tokens.txt")
+	os.WriteFile(path+".part", content[:300], 0644)
+	if err := f.Fetch(context.Background(), server.URL+"/tokens.txt", path, ""); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(content) {
+		t.Errorf("Expected the resumed file to be complete, got %d bytes", len(data))
+	}
+
+	// Downloads that do not match their checksum are discarded
+	path = filepath.Join(dir, "joiner.onnx")
+	if err := f.Fetch(context.Background(), server.URL+"/joiner.onnx", path, strings.Repeat("0", 64)); err == nil {
+		t.Fatal("Expected a checksum mismatch")
+	}
+	for _, name := range []string{path, path + ".part"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Package panics keeps a panic in a goroutine serving one client from
+// crashing the server: it is recovered, logged with its stack and reported
+// to whoever can tell the client.
+package panics
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+
+	"github.com/aira-id/gribe/internal/metrics"
+)
+
+// recovered counts the panics recovered since startup
+var recovered = metrics.NewGauge("gribe_panics_recovered", "Panics recovered in client goroutines since startup")
+
+// Error is a recovered panic
+type Error struct {
+	Value interface{} // What was passed to panic
+	Stack []byte      // Stack of the panicking goroutine
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Recover recovers a panic of the calling goroutine, logging it as a panic in
+// what and passing it to report, if not nil. It must be deferred directly:
+//
+//	defer panics.Recover("event handler", func(err error) { ... })
+func Recover(what string, report func(err error)) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	recovered.Inc()
+	err := &Error{Value: value, Stack: debug.Stack()}
+	log.Printf("[ERROR] Recovered panic in %s: %v\n%s", what, value, err.Stack)
+	if report != nil {
+		report(err)
+	}
+}
+
+// Count returns the panics recovered since startup
+func Count() int64 {
+	return recovered.Value()
+}
@@ -0,0 +1,37 @@
+package panics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	before := Count()
+	var reported error
+	func() {
+		defer Recover("test", func(err error) { reported = err })
+		var items []int
+		_ = items[1]
+	}()
+
+	var panicErr *Error
+	if !errors.As(reported, &panicErr) || !strings.Contains(panicErr.Error(), "index out of range") {
+		t.Fatalf("Expected the panic to be reported, got %v", reported)
+	}
+	if !strings.Contains(string(panicErr.Stack), "TestRecover") {
+		t.Errorf("Expected the stack of the panicking goroutine, got:\n%s", panicErr.Stack)
+	}
+	if Count() != before+1 {
+		t.Errorf("Count = %d, want %d", Count(), before+1)
+	}
+
+	// Without a panic nothing is reported
+	reported = nil
+	func() {
+		defer Recover("test", func(err error) { reported = err })
+	}()
+	if reported != nil {
+		t.Errorf("Expected no report without a panic, got %v", reported)
+	}
+}
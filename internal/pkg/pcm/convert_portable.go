@@ -0,0 +1,7 @@
+//go:build !(amd64 || arm64) || purego
+
+package pcm
+
+func convert(dst []float32, src []byte) {
+	convertPortable(dst, src)
+}
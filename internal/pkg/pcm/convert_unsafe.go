@@ -0,0 +1,18 @@
+//go:build (amd64 || arm64) && !purego
+
+package pcm
+
+import "unsafe"
+
+// convert reinterprets the bytes as int16 samples in place, which is valid on
+// little-endian architectures that allow unaligned loads. Build with the
+// purego tag to use the portable path.
+func convert(dst []float32, src []byte) {
+	if len(dst) == 0 {
+		return
+	}
+	samples := unsafe.Slice((*int16)(unsafe.Pointer(&src[0])), len(dst))
+	for i, s := range samples {
+		dst[i] = float32(s) * scale
+	}
+}
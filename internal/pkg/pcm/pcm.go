@@ -0,0 +1,30 @@
+// Package pcm converts little-endian 16-bit PCM to the float32 samples local
+// recognizers take, into pooled slices so the conversion allocates nothing
+// in steady state.
+package pcm
+
+import (
+	"encoding/binary"
+
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+)
+
+// scale maps int16 samples to [-1, 1)
+const scale = 1.0 / 32768
+
+// ToFloat32 converts 16-bit little-endian PCM to float32 samples in [-1, 1).
+// A trailing odd byte is ignored. The slice comes from bufpool; recycle it
+// with bufpool.PutFloat32s once consumed.
+func ToFloat32(src []byte) []float32 {
+	dst := bufpool.Float32s(len(src) / 2)
+	convert(dst, src)
+	return dst
+}
+
+// convertPortable reads samples with encoding/binary, for any architecture
+func convertPortable(dst []float32, src []byte) {
+	src = src[:len(dst)*2]
+	for i := range dst {
+		dst[i] = float32(int16(binary.LittleEndian.Uint16(src[2*i:]))) * scale
+	}
+}
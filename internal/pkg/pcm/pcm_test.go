@@ -0,0 +1,31 @@
+package pcm
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestToFloat32(t *testing.T) {
+	values := []int16{0, 1, -1, 16384, -16384, 32767, -32768}
+	src := make([]byte, len(values)*2+1) // Trailing odd byte is ignored
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(src[2*i:], uint16(v))
+	}
+
+	got := ToFloat32(src)
+	if len(got) != len(values) {
+		t.Fatalf("Expected %d samples, got %d", len(values), len(got))
+	}
+	portable := make([]float32, len(values))
+	convertPortable(portable, src)
+	for i, v := range values {
+		want := float32(v) / 32768
+		if got[i] != want || portable[i] != want {
+			t.Errorf("Sample %d: expected %v, got %v (portable %v)", v, want, got[i], portable[i])
+		}
+	}
+
+	if len(ToFloat32(nil)) != 0 || len(ToFloat32([]byte{1})) != 0 {
+		t.Error("Expected no samples from less than two bytes")
+	}
+}
@@ -0,0 +1,311 @@
+// Package recording captures the client and server events of a realtime
+// session to a JSON Lines file and reads such captures back for replay.
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Direction identifies who sent a recorded event
+type Direction string
+
+const (
+	DirectionClient Direction = "client"
+	DirectionServer Direction = "server"
+	DirectionHeader Direction = "header" // The Header, first in a recording
+)
+
+// Entry is a single recorded event
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	Direction Direction       `json:"direction"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// Header names whose session a recording captured, so that its owner's
+// retention applies to it
+type Header struct {
+	Owner    string `json:"owner,omitempty"`     // domain.OwnerHash of the session's credential
+	TenantID string `json:"tenant_id,omitempty"` // The session's tenant, if any
+}
+
+// flushInterval bounds how long recorded events wait in memory before they
+// are written to the file
+const flushInterval = time.Second
+
+// Recorder appends entries to a recording file. Entries are buffered and
+// written within flushInterval, and on Close.
+type Recorder struct {
+	mu     sync.Mutex
+	file   *os.File
+	buf    *bufio.Writer
+	path   string
+	flush  *time.Timer // Pending write of buffered entries, nil when none
+	closed bool
+}
+
+// NewRecorder creates a new recording file in dir, starting with header
+func NewRecorder(dir string, header Header) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	name := fmt.Sprintf("session-%s-%s.jsonl",
+		time.Now().UTC().Format("20060102T150405"), uuid.New().String()[:8])
+	path := filepath.Join(dir, name)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording file: %w", err)
+	}
+
+	r := &Recorder{file: file, buf: bufio.NewWriter(file), path: path}
+	data, err := json.Marshal(&header)
+	if err == nil {
+		err = r.write(DirectionHeader, data)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write recording header: %w", err)
+	}
+	return r, nil
+}
+
+// Path returns the recording file path
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Record writes one event. Messages that are not valid JSON are stored as JSON strings.
+func (r *Recorder) Record(direction Direction, message []byte) error {
+	event := json.RawMessage(message)
+	if !json.Valid(message) {
+		quoted, err := json.Marshal(string(message))
+		if err != nil {
+			return err
+		}
+		event = quoted
+	}
+	return r.write(direction, event)
+}
+
+// write buffers one entry
+func (r *Recorder) write(direction Direction, event json.RawMessage) error {
+	line, err := json.Marshal(&Entry{Time: time.Now(), Direction: direction, Event: event})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	if _, err := r.buf.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if r.flush == nil {
+		r.flush = time.AfterFunc(flushInterval, r.flushBuffered)
+	}
+	return nil
+}
+
+// flushBuffered writes the buffered entries to the file. A failure is kept
+// by the buffer and returned by the next Record or Close.
+func (r *Recorder) flushBuffered() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flush = nil
+	if !r.closed {
+		r.buf.Flush()
+	}
+}
+
+// Close flushes and closes the recording file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.flush != nil {
+		r.flush.Stop()
+		r.flush = nil
+	}
+	if err := r.buf.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// File is a recording file
+type File struct {
+	Path    string
+	ModTime time.Time // When the recording was last written
+}
+
+// Files lists the recording files in dir, none if dir does not exist
+func Files(dir string) ([]File, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "session-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, File{Path: path, ModTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// Session describes the session a recording captured
+type Session struct {
+	ID      string    // From the first server event naming a session, empty if there is none
+	Started time.Time // When the recording started
+	Header            // Empty in recordings made before recordings had headers
+}
+
+// ReadSession reads the header of a recording and the ID of the session it
+// captured
+func ReadSession(path string) (*Session, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	session := &Session{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024) // audio appends can be large
+	for scanner.Scan() {
+		var entry Entry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		if session.Started.IsZero() {
+			session.Started = entry.Time
+		}
+		if entry.Direction == DirectionHeader {
+			json.Unmarshal(entry.Event, &session.Header)
+			continue
+		}
+		if entry.Direction != DirectionServer {
+			continue
+		}
+		var event struct {
+			Session struct {
+				ID string `json:"id"`
+			} `json:"session"`
+		}
+		if json.Unmarshal(entry.Event, &event) == nil && event.Session.ID != "" {
+			session.ID = event.Session.ID
+			return session, nil
+		}
+	}
+	return session, scanner.Err()
+}
+
+// ReadFile loads the recorded events from a recording file, without its header
+func ReadFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024) // audio appends can be large
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if entry.Direction == DirectionHeader {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// EventType extracts the "type" field of a recorded event
+func (e *Entry) EventType() string {
+	var base struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(e.Event, &base)
+	return base.Type
+}
+
+// ============================================================================
+// CONNECTION WRAPPER
+// ============================================================================
+
+// WebSocketConn is the connection shape used by the session usecase
+type WebSocketConn interface {
+	WriteJSON(v interface{}) error
+	ReadMessage() (messageType int, p []byte, err error)
+	Close() error
+}
+
+// Conn records every message passing through the wrapped connection
+type Conn struct {
+	WebSocketConn
+	recorder *Recorder
+}
+
+// NewConn wraps conn so that reads and writes are recorded
+func NewConn(conn WebSocketConn, recorder *Recorder) *Conn {
+	return &Conn{WebSocketConn: conn, recorder: recorder}
+}
+
+// WriteJSON records the server event and forwards it
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err == nil {
+		c.recorder.Record(DirectionServer, data)
+	}
+	return c.WebSocketConn.WriteJSON(v)
+}
+
+// ReadMessage forwards the read and records client text messages
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType, message, err := c.WebSocketConn.ReadMessage()
+	if err == nil && messageType == websocket.TextMessage {
+		c.recorder.Record(DirectionClient, message)
+	}
+	return messageType, message, err
+}
+
+// Close closes the connection and the recording
+func (c *Conn) Close() error {
+	err := c.WebSocketConn.Close()
+	if rerr := c.recorder.Close(); rerr != nil && err == nil {
+		err = rerr
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package recording
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// scriptedConn is a session connection reading scripted client messages
+type scriptedConn struct {
+	reads  [][]byte
+	writes []interface{}
+	closed bool
+}
+
+func (c *scriptedConn) WriteJSON(v interface{}) error {
+	c.writes = append(c.writes, v)
+	return nil
+}
+
+func (c *scriptedConn) ReadMessage() (int, []byte, error) {
+	if len(c.reads) == 0 {
+		return 0, nil, websocket.ErrCloseSent
+	}
+	message := c.reads[0]
+	c.reads = c.reads[1:]
+	return websocket.TextMessage, message, nil
+}
+
+func (c *scriptedConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestConnRecords(t *testing.T) {
+	recorder, err := NewRecorder(t.TempDir(), Header{Owner: "owner-hash", TenantID: "acme"})
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	inner := &scriptedConn{reads: [][]byte{[]byte(`{"type":"input_audio_buffer.commit"}`), []byte("not json")}}
+	conn := NewConn(inner, recorder)
+
+	conn.WriteJSON(map[string]interface{}{"type": "session.created", "session": map[string]string{"id": "sess_1"}})
+	conn.ReadMessage()
+	conn.ReadMessage()
+	conn.WriteJSON(map[string]string{"type": "input_audio_buffer.committed"})
+
+	// Entries are buffered until the flush interval or Close
+	if info, err := os.Stat(recorder.Path()); err != nil || info.Size() != 0 {
+		t.Errorf("Expected nothing written before the flush, got %v (%v)", info.Size(), err)
+	}
+	if err := conn.Close(); err != nil || !inner.closed {
+		t.Fatalf("Expected Close to close the connection and the recording, got %v", err)
+	}
+	if err := recorder.Record(DirectionServer, []byte(`{}`)); err == nil {
+		t.Error("Expected Record after Close to fail")
+	}
+
+	entries, err := ReadFile(recorder.Path())
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	var got []string
+	for i := range entries {
+		got = append(got, string(entries[i].Direction)+" "+entries[i].EventType())
+	}
+	want := "server session.created, client input_audio_buffer.commit, client , server input_audio_buffer.committed"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, ", "))
+	}
+	var text string
+	if err := json.Unmarshal(entries[2].Event, &text); err != nil || text != "not json" {
+		t.Errorf("Expected a message that is not JSON stored as a string, got %s", entries[2].Event)
+	}
+
+	// The header, left out of the entries, names the session's owner
+	session, err := ReadSession(recorder.Path())
+	if err != nil || session.ID != "sess_1" || session.Started.After(entries[0].Time) {
+		t.Fatalf("Expected session sess_1 started by the first entry, got %+v (%v)", session, err)
+	}
+	if session.Owner != "owner-hash" || session.TenantID != "acme" {
+		t.Errorf("Expected the header's owner and tenant, got %+v", session.Header)
+	}
+}
+
+func TestRecorderFlushInterval(t *testing.T) {
+	recorder, err := NewRecorder(t.TempDir(), Header{})
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	defer recorder.Close()
+	recorder.Record(DirectionClient, []byte(`{"type":"input_audio_buffer.clear"}`))
+
+	deadline := time.Now().Add(5 * flushInterval)
+	for time.Now().Before(deadline) {
+		if entries, _ := ReadFile(recorder.Path()); len(entries) == 1 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("Expected the entry written within the flush interval")
+}
+
+func TestReplayAndCompare(t *testing.T) {
+	// The server echoes the type of each client event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			entry := Entry{Event: message}
+			conn.WriteJSON(map[string]string{"type": "echo." + entry.EventType()})
+		}
+	}))
+	defer server.Close()
+
+	at := time.Unix(1735689600, 0)
+	recorded := []Entry{
+		{Time: at, Direction: DirectionServer, Event: json.RawMessage(`{"type":"session.created"}`)},
+		{Time: at, Direction: DirectionClient, Event: json.RawMessage(`{"type":"input_audio_buffer.commit"}`)},
+		{Time: at.Add(time.Second), Direction: DirectionServer, Event: json.RawMessage(`{"type":"input_audio_buffer.committed"}`)},
+		{Time: at.Add(time.Second), Direction: DirectionClient, Event: json.RawMessage(`{"type":"input_audio_buffer.clear"}`)},
+		{Time: at.Add(time.Second), Direction: DirectionServer, Event: json.RawMessage(`{"type":"input_audio_buffer.cleared"}`)},
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	started := time.Now()
+	replayed, err := Replay(conn, recorded, ReplayOptions{Speed: 10, Wait: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected the second between client events replayed in 100ms, took %v", elapsed)
+	}
+	if len(replayed) != 2 || replayed[0].EventType() != "echo.input_audio_buffer.commit" || replayed[0].Direction != DirectionServer {
+		t.Fatalf("Expected the server's answers to both client events, got %+v", replayed)
+	}
+
+	diffs := CompareEventTypes(recorded, replayed)
+	want := []string{
+		"echo.input_audio_buffer.clear: recorded 0, replayed 1",
+		"echo.input_audio_buffer.commit: recorded 0, replayed 1",
+		"input_audio_buffer.cleared: recorded 1, replayed 0",
+		"input_audio_buffer.committed: recorded 1, replayed 0",
+		"session.created: recorded 1, replayed 0",
+	}
+	if strings.Join(diffs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected differences:\n%s", strings.Join(diffs, "\n"))
+	}
+	if diffs := CompareEventTypes(recorded, recorded); diffs != nil {
+		t.Errorf("Expected a recording to match itself, got %v", diffs)
+	}
+}
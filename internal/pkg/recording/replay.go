@@ -0,0 +1,106 @@
+package recording
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ReplayOptions controls how a recording is re-fed to a server
+type ReplayOptions struct {
+	Speed float64       // 1 = original pacing, 2 = twice as fast, 0 = no delays
+	Wait  time.Duration // How long to collect server events after the last client event
+}
+
+// Replay sends the client events of a recording over conn, preserving their
+// relative timing, and returns the server events received meanwhile.
+func Replay(conn *websocket.Conn, entries []Entry, opts ReplayOptions) ([]Entry, error) {
+	var (
+		mu       sync.Mutex
+		received []Entry
+		done     = make(chan struct{})
+	)
+
+	go func() {
+		defer close(done)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			received = append(received, Entry{Time: time.Now(), Direction: DirectionServer, Event: message})
+			mu.Unlock()
+		}
+	}()
+
+	var last time.Time
+	for _, entry := range entries {
+		if entry.Direction != DirectionClient {
+			continue
+		}
+
+		if !last.IsZero() && opts.Speed > 0 {
+			time.Sleep(time.Duration(float64(entry.Time.Sub(last)) / opts.Speed))
+		}
+		last = entry.Time
+
+		if err := conn.WriteMessage(websocket.TextMessage, entry.Event); err != nil {
+			return received, fmt.Errorf("failed to send %s: %w", entry.EventType(), err)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(opts.Wait):
+	}
+	conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	return received, nil
+}
+
+// CompareEventTypes reports differences in server event type counts between
+// a recording and a replay. IDs and payloads are ignored since they are not
+// expected to be stable across runs.
+func CompareEventTypes(recorded, replayed []Entry) []string {
+	expected := countServerTypes(recorded)
+	actual := countServerTypes(replayed)
+
+	types := make([]string, 0, len(expected)+len(actual))
+	for eventType := range expected {
+		types = append(types, eventType)
+	}
+	for eventType := range actual {
+		if _, seen := expected[eventType]; !seen {
+			types = append(types, eventType)
+		}
+	}
+	sort.Strings(types)
+
+	var diffs []string
+	for _, eventType := range types {
+		if expected[eventType] != actual[eventType] {
+			diffs = append(diffs, fmt.Sprintf("%s: recorded %d, replayed %d",
+				eventType, expected[eventType], actual[eventType]))
+		}
+	}
+	return diffs
+}
+
+func countServerTypes(entries []Entry) map[string]int {
+	counts := make(map[string]int)
+	for i := range entries {
+		if entries[i].Direction == DirectionServer {
+			counts[entries[i].EventType()]++
+		}
+	}
+	return counts
+}
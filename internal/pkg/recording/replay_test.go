@@ -0,0 +1,67 @@
+// Replay is tested against the realtime harness, which imports this package.
+
+package recording_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/recording"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+	"github.com/gorilla/websocket"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Record.Dir = t.TempDir()
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+	client.AppendAudio(make([]byte, 320))
+	client.Commit()
+	if _, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	// Wait for the server to finish the session and close the recording
+	var files []string
+	for i := 0; i < 50 && len(files) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		files, _ = filepath.Glob(filepath.Join(cfg.Record.Dir, "*.jsonl"))
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 recording, got %d", len(files))
+	}
+
+	entries, err := recording.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(entries) == 0 || entries[0].EventType() != string(domain.EventTranscriptionSessionCreated) {
+		t.Fatalf("Expected recording to start with transcription_session.created, got %v", entries)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(srv.URL()+"?intent=transcription", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	replayed, err := recording.Replay(conn, entries, recording.ReplayOptions{Speed: 0, Wait: 500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	if diffs := recording.CompareEventTypes(entries, replayed); len(diffs) != 0 {
+		t.Errorf("Replay differs from recording: %v", diffs)
+	}
+}
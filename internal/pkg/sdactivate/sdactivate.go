@@ -0,0 +1,72 @@
+// Package sdactivate takes over listening sockets passed by systemd socket
+// activation (see sd_listen_fds(3)). The sockets belong to a .socket unit, so
+// they stay open across restarts of the service and connections made while
+// it restarts wait in their backlog instead of being refused.
+package sdactivate
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor systemd passes
+const listenFDsStart = 3
+
+// Listener is an inherited socket with its FileDescriptorName= from the
+// socket unit, which defaults to the unit's name
+type Listener struct {
+	net.Listener
+	Name string
+}
+
+// Listeners returns the sockets passed to this process, none when it was not
+// socket activated. The LISTEN_* variables are unset so child processes do
+// not take them for their own.
+func Listeners() ([]Listener, error) {
+	pid, fds, names := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil // Not activated, or the variables were meant for a parent
+	}
+	return listeners(fds, names, listenFDsStart)
+}
+
+func listeners(fds, names string, start int) ([]Listener, error) {
+	if fds == "" {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	var nameList []string
+	if names != "" {
+		nameList = strings.Split(names, ":")
+	}
+
+	result := make([]Listener, 0, count)
+	for i := 0; i < count; i++ {
+		name := "unknown"
+		if i < len(nameList) {
+			name = nameList[i]
+		}
+		// FileListener duplicates the descriptor, close-on-exec, so the
+		// inherited one is closed either way
+		file := os.NewFile(uintptr(start+i), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, l := range result {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket %d (%s) is not a listener: %w", start+i, name, err)
+		}
+		result = append(result, Listener{Listener: listener, Name: name})
+	}
+	return result, nil
+}
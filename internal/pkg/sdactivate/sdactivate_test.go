@@ -0,0 +1,62 @@
+package sdactivate
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestListeners(t *testing.T) {
+	// Not socket activated
+	if got, err := Listeners(); err != nil || got != nil {
+		t.Fatalf("Expected no listeners without LISTEN_FDS, got %v, %v", got, err)
+	}
+
+	// Pass two sockets as consecutive descriptors, as systemd does from 3
+	var files []*os.File
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := l.(*net.TCPListener).File()
+		l.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	start := int(files[0].Fd())
+	if int(files[1].Fd()) != start+1 {
+		t.Skipf("Descriptors %d and %d are not consecutive", files[0].Fd(), files[1].Fd())
+	}
+
+	got, err := listeners("2", "http", start)
+	if err != nil {
+		t.Fatalf("listeners failed: %v", err)
+	}
+	defer func() {
+		for _, l := range got {
+			l.Close()
+		}
+	}()
+	if len(got) != 2 || got[0].Name != "http" || got[1].Name != "unknown" {
+		t.Fatalf("Expected listeners http and unknown, got %+v", got)
+	}
+
+	// The listener accepts connections on the inherited socket
+	conn, err := net.Dial("tcp", got[0].Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	conn.Close()
+	accepted, err := got[0].Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	accepted.Close()
+
+	if _, err := listeners("x", "", start); err == nil {
+		t.Error("Expected an error for an invalid LISTEN_FDS")
+	}
+}
@@ -4,16 +4,23 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 
-	"github.com/aira-id/gribe/internal/domain"
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	"github.com/aira-id/gribe/internal/pkg/pcm"
+	"github.com/aira-id/gribe/pkg/domain"
 	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
 )
 
 // Config holds sherpa-onnx specific configuration
 type Config struct {
-	Provider   string   // cpu or gpu
+	Provider   string   // cpu or cuda
+	DeviceID   int      // CUDA device, see pinCUDADevice
 	NumThreads int      // Number of threads for inference
 	ModelsDir  string   // Base directory for models
 	ModelName  string   // Model directory name
@@ -23,6 +30,26 @@ type Config struct {
 	Tokens     string   // Tokens file name
 	Languages  []string // Supported languages
 	Language   string   // Current language for transcription
+
+	// Precision is "int8" to load the Int8 files instead of the
+	// full-precision ones, "fp32" or empty otherwise
+	Precision   string
+	Int8Encoder string // Quantized encoder file name
+	Int8Decoder string // Quantized decoder file name
+	Int8Joiner  string // Quantized joiner file name, empty keeps Joiner
+}
+
+// transducerFiles returns the encoder, decoder and joiner file names at the
+// configured precision
+func (c *Config) transducerFiles() (encoder, decoder, joiner string) {
+	if c.Precision != "int8" {
+		return c.Encoder, c.Decoder, c.Joiner
+	}
+	joiner = c.Joiner
+	if c.Int8Joiner != "" {
+		joiner = c.Int8Joiner
+	}
+	return c.Int8Encoder, c.Int8Decoder, joiner
 }
 
 // Provider implements the ASRProvider interface using sherpa-onnx
@@ -43,13 +70,14 @@ func New(config *Config) (*Provider, error) {
 	if config.ModelName == "" {
 		return nil, fmt.Errorf("model_name is required in sherpa config")
 	}
-	if config.Encoder == "" {
+	encoder, decoder, joiner := config.transducerFiles()
+	if encoder == "" {
 		return nil, fmt.Errorf("encoder is required in sherpa config")
 	}
-	if config.Decoder == "" {
+	if decoder == "" {
 		return nil, fmt.Errorf("decoder is required in sherpa config")
 	}
-	if config.Joiner == "" {
+	if joiner == "" {
 		return nil, fmt.Errorf("joiner is required in sherpa config")
 	}
 	if config.Tokens == "" {
@@ -101,13 +129,30 @@ func (c *Config) IsLanguageSupported(lang string) bool {
 	return false
 }
 
+// pinCUDADevice makes device the only CUDA device visible to the process,
+// as sherpa-onnx always creates its sessions on the first visible one. It
+// must run before the first CUDA model loads, and leaves a
+// CUDA_VISIBLE_DEVICES set by the operator alone.
+func pinCUDADevice(device int) {
+	pinDevice.Do(func() {
+		if visible, set := os.LookupEnv("CUDA_VISIBLE_DEVICES"); set {
+			log.Printf("[INFO] CUDA_VISIBLE_DEVICES is %q, not pinning device %d", visible, device)
+			return
+		}
+		os.Setenv("CUDA_VISIBLE_DEVICES", strconv.Itoa(device))
+		log.Printf("[INFO] Running CUDA models on device %d", device)
+	})
+}
+
+var pinDevice sync.Once
+
 // initializeRecognizer initializes the sherpa-onnx recognizer
 func (p *Provider) initializeRecognizer() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	log.Printf("Initializing sherpa-onnx recognizer with model: %s (language: %s)",
-		p.config.ModelName, p.config.Language)
+	log.Printf("Initializing sherpa-onnx recognizer with model: %s (language: %s, precision: %s)",
+		p.config.ModelName, p.config.Language, p.config.Precision)
 
 	recognizerConfig := &sherpa.OnlineRecognizerConfig{}
 	recognizerConfig.FeatConfig.SampleRate = 16000
@@ -115,13 +160,17 @@ func (p *Provider) initializeRecognizer() error {
 
 	// Build model paths from config
 	modelDir := filepath.Join(p.config.ModelsDir, p.config.ModelName)
-	recognizerConfig.ModelConfig.Transducer.Encoder = filepath.Join(modelDir, p.config.Encoder)
-	recognizerConfig.ModelConfig.Transducer.Decoder = filepath.Join(modelDir, p.config.Decoder)
-	recognizerConfig.ModelConfig.Transducer.Joiner = filepath.Join(modelDir, p.config.Joiner)
+	encoder, decoder, joiner := p.config.transducerFiles()
+	recognizerConfig.ModelConfig.Transducer.Encoder = filepath.Join(modelDir, encoder)
+	recognizerConfig.ModelConfig.Transducer.Decoder = filepath.Join(modelDir, decoder)
+	recognizerConfig.ModelConfig.Transducer.Joiner = filepath.Join(modelDir, joiner)
 	recognizerConfig.ModelConfig.Tokens = filepath.Join(modelDir, p.config.Tokens)
 
 	recognizerConfig.ModelConfig.NumThreads = p.config.NumThreads
 	recognizerConfig.ModelConfig.Provider = p.config.Provider
+	if p.config.Provider == "cuda" {
+		pinCUDADevice(p.config.DeviceID)
+	}
 	recognizerConfig.ModelConfig.Debug = 0
 	recognizerConfig.DecodingMethod = "greedy_search"
 	recognizerConfig.MaxActivePaths = 4
@@ -161,6 +210,9 @@ func (p *Provider) Transcribe(ctx context.Context, audio []byte, config *domain.
 
 	go func() {
 		defer close(resultChan)
+		defer panics.Recover("sherpa-onnx transcription", func(err error) {
+			sendFailure(ctx, resultChan, err)
+		})
 
 		p.mu.Lock()
 		defer p.mu.Unlock()
@@ -173,17 +225,17 @@ func (p *Provider) Transcribe(ctx context.Context, audio []byte, config *domain.
 		defer sherpa.DeleteOnlineStream(stream)
 
 		// Convert bytes to float32 samples
-		samples := bytesToFloat32(audio)
+		samples := pcm.ToFloat32(audio)
+		numSamples := len(samples)
 
 		// Add left padding (0.3 seconds of silence)
-		leftPadding := make([]float32, 4800) // 16000 * 0.3
 		stream.AcceptWaveform(16000, leftPadding)
 
-		// Process the audio
+		// Process the audio; the stream copies the samples
 		stream.AcceptWaveform(16000, samples)
+		bufpool.PutFloat32s(samples)
 
 		// Add right padding (0.6 seconds of silence)
-		rightPadding := make([]float32, 9600) // 16000 * 0.6
 		stream.AcceptWaveform(16000, rightPadding)
 
 		// Input finished
@@ -208,7 +260,7 @@ func (p *Provider) Transcribe(ctx context.Context, audio []byte, config *domain.
 				Text:    result.Text,
 				IsFinal: true,
 				StartMs: 0,
-				EndMs:   len(samples) * 1000 / 16000,
+				EndMs:   numSamples * 1000 / 16000,
 			}
 			select {
 			case <-ctx.Done():
@@ -235,11 +287,11 @@ func (p *Provider) TranscribeStream(ctx context.Context, config *domain.Transcri
 
 	go func() {
 		defer close(resultOut)
+		defer panics.Recover("sherpa-onnx streaming transcription", func(err error) {
+			sendFailure(ctx, resultOut, err)
+		})
 
-		p.mu.Lock()
-		stream := sherpa.NewOnlineStream(p.recognizer)
-		p.mu.Unlock()
-
+		stream := p.newStream()
 		if stream == nil {
 			log.Printf("Error: failed to create OnlineStream")
 			return
@@ -257,69 +309,43 @@ func (p *Provider) TranscribeStream(ctx context.Context, config *domain.Transcri
 				if !ok {
 					// Channel closed, finalize
 					stream.InputFinished()
+					result := p.decode(stream, nil)
 
-					p.mu.Lock()
-					// Finalize decoding
-					for p.recognizer.IsReady(stream) {
-						p.recognizer.Decode(stream)
+					// Send final result, with empty text if nothing new was recognized
+					chunk := domain.TranscriptionChunk{IsFinal: true}
+					if result != nil {
+						chunk.Text = appendedText(lastPartialResult, result.Text)
+						chunk.Hypothesis = result.Text
 					}
-					result := p.recognizer.GetResult(stream)
-					p.mu.Unlock()
-
-					// Send final result
-					if result != nil && result.Text != "" && result.Text != lastPartialResult {
-						chunk := domain.TranscriptionChunk{
-							Text:    result.Text[len(lastPartialResult):],
-							IsFinal: true,
-						}
-						select {
-						case <-ctx.Done():
-							return
-						case resultOut <- chunk:
-						}
-					} else {
-						// Send empty final chunk if no new text
-						chunk := domain.TranscriptionChunk{
-							Text:    "",
-							IsFinal: true,
-						}
-						resultOut <- chunk
+					select {
+					case <-ctx.Done():
+						return
+					case resultOut <- chunk:
 					}
 
 					return
 				}
 
 				// Convert bytes to float32 samples
-				samples := bytesToFloat32(audio)
-
-				p.mu.Lock()
-				// Accept waveform
-				stream.AcceptWaveform(16000, samples)
+				samples := pcm.ToFloat32(audio)
 
-				// Decode if ready
-				for p.recognizer.IsReady(stream) {
-					p.recognizer.Decode(stream)
-				}
-
-				// Get current result
-				result := p.recognizer.GetResult(stream)
-				p.mu.Unlock()
+				result := p.decode(stream, samples)
+				bufpool.PutFloat32s(samples)
 
-				// Send delta event if result changed
+				// Send the new hypothesis if the result changed. The recognizer
+				// may revise earlier words, so Text is only the appended part.
 				if result != nil && result.Text != "" && result.Text != lastPartialResult {
-					delta := result.Text[len(lastPartialResult):]
-					if delta != "" {
-						chunk := domain.TranscriptionChunk{
-							Text:    delta,
-							IsFinal: false,
-						}
-						select {
-						case <-ctx.Done():
-							return
-						case resultOut <- chunk:
-						}
-						lastPartialResult = result.Text
+					chunk := domain.TranscriptionChunk{
+						Text:       appendedText(lastPartialResult, result.Text),
+						IsFinal:    false,
+						Hypothesis: result.Text,
+					}
+					select {
+					case <-ctx.Done():
+						return
+					case resultOut <- chunk:
 					}
+					lastPartialResult = result.Text
 				}
 			}
 		}
@@ -328,6 +354,45 @@ func (p *Provider) TranscribeStream(ctx context.Context, config *domain.Transcri
 	return audioIn, resultOut, nil
 }
 
+// newStream creates a stream of the shared recognizer
+func (p *Provider) newStream() *sherpa.OnlineStream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return sherpa.NewOnlineStream(p.recognizer)
+}
+
+// decode feeds samples, if any, to stream, decodes what it has ready and
+// returns its result so far. The recognizer is held with a deferred unlock,
+// so a panic cannot leave it locked for the other streams.
+func (p *Provider) decode(stream *sherpa.OnlineStream, samples []float32) *sherpa.OnlineRecognizerResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if samples != nil {
+		stream.AcceptWaveform(16000, samples)
+	}
+	for p.recognizer.IsReady(stream) {
+		p.recognizer.Decode(stream)
+	}
+	return p.recognizer.GetResult(stream)
+}
+
+// sendFailure reports a failed transcription on results, unless ctx ended
+func sendFailure(ctx context.Context, results chan<- domain.TranscriptionChunk, err error) {
+	select {
+	case results <- domain.TranscriptionChunk{Err: err, IsFinal: true}:
+	case <-ctx.Done():
+	}
+}
+
+// appendedText returns what current adds to previous, or "" when the
+// recognizer revised previous instead of extending it
+func appendedText(previous, current string) string {
+	if !strings.HasPrefix(current, previous) {
+		return ""
+	}
+	return current[len(previous):]
+}
+
 // GetSupportedModels returns list of supported ASR models
 func (p *Provider) GetSupportedModels() []string {
 	return []string{p.config.ModelName}
@@ -338,6 +403,13 @@ func (p *Provider) GetSupportedLanguages() []string {
 	return p.config.Languages
 }
 
+// HealthCheck reports whether the recognizer is loaded
+func (p *Provider) HealthCheck() domain.ProviderHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return domain.ProviderHealth{ModelLoaded: p.isInitialized && p.recognizer != nil}
+}
+
 // Close releases any resources held by the provider
 func (p *Provider) Close() error {
 	p.mu.Lock()
@@ -353,20 +425,8 @@ func (p *Provider) Close() error {
 	return nil
 }
 
-// bytesToFloat32 converts byte array (PCM 16-bit little-endian) to float32 array
-func bytesToFloat32(data []byte) []float32 {
-	numSamples := len(data) / 2
-	samples := make([]float32, numSamples)
-
-	for i := 0; i < numSamples; i++ {
-		// Read 16-bit signed integer in little-endian
-		b1 := int16(data[i*2])
-		b2 := int16(data[i*2+1])
-		sample := (b2 << 8) | (b1 & 0xFF)
-
-		// Convert to float32 in range [-1, 1)
-		samples[i] = float32(sample) / 32768.0
-	}
-
-	return samples
-}
+// Silence fed around offline audio. The stream copies samples, so they are shared.
+var (
+	leftPadding  = make([]float32, 4800) // 16000 * 0.3
+	rightPadding = make([]float32, 9600) // 16000 * 0.6
+)
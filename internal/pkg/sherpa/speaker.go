@@ -0,0 +1,67 @@
+package sherpa
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// SpeakerConfig holds the configuration of a speaker embedding model, such
+// as the 3D-Speaker or WeSpeaker models released for sherpa-onnx
+type SpeakerConfig struct {
+	Model      string // Path to the .onnx model
+	Provider   string // cpu or cuda
+	NumThreads int
+}
+
+// SpeakerEmbedder implements domain.SpeakerEmbedder with a sherpa-onnx
+// speaker embedding extractor
+type SpeakerEmbedder struct {
+	mu        sync.Mutex // The extractor computes one embedding at a time
+	extractor *sherpa.SpeakerEmbeddingExtractor
+}
+
+// NewSpeakerEmbedder loads the speaker embedding model of config
+func NewSpeakerEmbedder(config *SpeakerConfig) (*SpeakerEmbedder, error) {
+	extractor := sherpa.NewSpeakerEmbeddingExtractor(&sherpa.SpeakerEmbeddingExtractorConfig{
+		Model:      config.Model,
+		NumThreads: config.NumThreads,
+		Provider:   config.Provider,
+	})
+	if extractor == nil {
+		return nil, fmt.Errorf("failed to load speaker embedding model %s", config.Model)
+	}
+	log.Printf("Speaker embedding model loaded: %s (%d dimensions)", config.Model, extractor.Dim())
+	return &SpeakerEmbedder{extractor: extractor}, nil
+}
+
+// Embed implements domain.SpeakerEmbedder
+func (e *SpeakerEmbedder) Embed(samples []float32, sampleRate int) ([]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.extractor == nil {
+		return nil, fmt.Errorf("speaker embedder is closed")
+	}
+
+	stream := e.extractor.CreateStream()
+	defer sherpa.DeleteOnlineStream(stream)
+	stream.AcceptWaveform(sampleRate, samples)
+	stream.InputFinished()
+	if !e.extractor.IsReady(stream) {
+		return nil, fmt.Errorf("audio is too short for a voiceprint")
+	}
+	return e.extractor.Compute(stream), nil
+}
+
+// Close implements domain.SpeakerEmbedder
+func (e *SpeakerEmbedder) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.extractor != nil {
+		sherpa.DeleteSpeakerEmbeddingExtractor(e.extractor)
+		e.extractor = nil
+	}
+	return nil
+}
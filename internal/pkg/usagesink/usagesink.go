@@ -0,0 +1,233 @@
+// Package usagesink delivers the usage records of ended sessions for billing:
+// appended to a JSONL file, written as objects to S3 or posted to a webhook.
+// A Queue delivers them off the session's goroutine, retrying failures.
+package usagesink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/fetch"
+	"github.com/aira-id/gribe/internal/pkg/webhook"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// writeTimeout bounds each delivery to S3 or a webhook
+const writeTimeout = 30 * time.Second
+
+var (
+	// ErrQueueFull is returned for records arriving while the queue is full
+	ErrQueueFull = errors.New("usage record queue is full")
+	// ErrQueueClosed is returned for records arriving after the queue closed
+	ErrQueueClosed = errors.New("usage record queue is closed")
+)
+
+// File appends records to a JSONL file
+type File struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFile opens path for appending, creating it if needed
+func NewFile(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage file: %w", err)
+	}
+	return &File{file: file}, nil
+}
+
+// Write implements domain.UsageSink. Each record is written as one line at
+// once, so concurrent writers never interleave.
+func (f *File) Write(record *domain.UsageRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file
+func (f *File) Close() error {
+	return f.file.Close()
+}
+
+// S3 writes each record as a JSON object under a bucket prefix, keyed
+// <prefix><yyyy>/<mm>/<dd>/<session id>.json by the day the session ended
+type S3 struct {
+	bucket string
+	prefix string
+	creds  fetch.Credentials
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3 creates a sink writing to s3://bucket/prefix. The region defaults to
+// us-east-1.
+func NewS3(bucket, prefix string, creds fetch.Credentials) *S3 {
+	if creds.Region == "" {
+		creds.Region = "us-east-1"
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+	return &S3{
+		bucket: bucket,
+		prefix: prefix,
+		creds:  creds,
+		client: &http.Client{Timeout: writeTimeout},
+		now:    time.Now,
+	}
+}
+
+// Write implements domain.UsageSink
+func (s *S3) Write(record *domain.UsageRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	key := s.prefix + time.Unix(record.EndedAt, 0).UTC().Format("2006/01/02/") + record.SessionID + ".json"
+	req, err := http.NewRequest(http.MethodPut, fetch.S3ObjectURL(s.bucket, key, s.creds), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.creds.AccessKeyID != "" {
+		fetch.SignV4(req, s.creds, s.now())
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("s3 returned %s for %s", resp.Status, key)
+	}
+	return nil
+}
+
+// Webhook posts each record, signed like batch job callbacks
+type Webhook struct {
+	url    string
+	sender *webhook.Sender
+}
+
+// NewWebhook creates a sink posting to rawURL, signing payloads with secret
+func NewWebhook(rawURL, secret string) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid usage webhook URL: %w", err)
+	}
+	sender := webhook.NewSender(webhook.Options{
+		AllowedHosts: []string{u.Hostname()},
+		Secret:       secret,
+		MaxAttempts:  1, // The queue retries
+		Timeout:      writeTimeout,
+	})
+	if err := sender.Check(rawURL); err != nil {
+		return nil, err
+	}
+	return &Webhook{url: rawURL, sender: sender}, nil
+}
+
+// Write implements domain.UsageSink
+func (w *Webhook) Write(record *domain.UsageRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return w.sender.Send(context.Background(), w.url, body)
+}
+
+// Queue delivers records to a sink on its own goroutine, so sessions end
+// without waiting for it. Failed deliveries are retried with exponential
+// backoff; records that still fail are logged in full, so they can be
+// recovered from the log.
+type Queue struct {
+	sink     domain.UsageSink
+	records  chan *domain.UsageRecord
+	attempts int
+	backoff  time.Duration
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewQueue starts delivering to sink, holding up to size records
+func NewQueue(sink domain.UsageSink, size, attempts int, backoff time.Duration) *Queue {
+	q := &Queue{
+		sink:     sink,
+		records:  make(chan *domain.UsageRecord, size),
+		attempts: max(attempts, 1),
+		backoff:  backoff,
+		done:     make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Write implements domain.UsageSink, queueing the record. It returns
+// ErrQueueFull rather than wait for the sink.
+func (q *Queue) Write(record *domain.UsageRecord) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.records <- record:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close delivers the queued records and stops the queue
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.records)
+	}
+	q.mu.Unlock()
+	<-q.done
+	if closer, ok := q.sink.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	for record := range q.records {
+		var err error
+		for attempt := 1; attempt <= q.attempts; attempt++ {
+			if err = q.sink.Write(record); err == nil {
+				break
+			}
+			if attempt < q.attempts {
+				time.Sleep(q.backoff << (attempt - 1))
+			}
+		}
+		if err != nil {
+			line, _ := json.Marshal(record)
+			log.Printf("[ERROR] Failed to export usage of session %s: %v: %s", record.SessionID, err, line)
+		}
+	}
+}
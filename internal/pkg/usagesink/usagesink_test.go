@@ -0,0 +1,122 @@
+package usagesink
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/fetch"
+	"github.com/aira-id/gribe/internal/pkg/webhook"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func testRecord(sessionID string) *domain.UsageRecord {
+	return &domain.UsageRecord{
+		SessionID: sessionID,
+		Type:      "transcription",
+		StartedAt: time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC).Unix(),
+		EndedAt:   time.Date(2026, 3, 2, 0, 1, 0, 0, time.UTC).Unix(),
+		Usage:     domain.SessionUsage{AudioInputSeconds: 120},
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	sink, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("NewFile failed: %v", err)
+	}
+	for _, id := range []string{"sess_1", "sess_2"} {
+		if err := sink.Write(testRecord(id)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	sink.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", data)
+	}
+	var record domain.UsageRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil || record.SessionID != "sess_2" || record.Usage.AudioInputSeconds != 120 {
+		t.Errorf("Unexpected record %q: %v", lines[1], err)
+	}
+}
+
+func TestS3(t *testing.T) {
+	var method, path, auth string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, auth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	sink := NewS3("billing", "/gribe/usage/", fetch.Credentials{AccessKeyID: "key", SecretAccessKey: "secret", Endpoint: server.URL})
+	if err := sink.Write(testRecord("sess_1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if method != http.MethodPut || path != "/billing/gribe/usage/2026/03/02/sess_1.json" {
+		t.Errorf("Expected PUT of the day's object, got %s %s", method, path)
+	}
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") || !strings.Contains(string(body), `"session_id":"sess_1"`) {
+		t.Errorf("Expected a signed record, got %q: %s", auth, body)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(webhook.SignatureHeader)
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhook(server.URL+"/usage", "secret")
+	if err != nil {
+		t.Fatalf("NewWebhook failed: %v", err)
+	}
+	if err := sink.Write(testRecord("sess_1")); err != nil || !strings.Contains(signature, "v1=") {
+		t.Errorf("Expected a signed delivery, got %q and %v", signature, err)
+	}
+}
+
+// flakySink fails the first writes
+type flakySink struct {
+	failures int
+	written  []string
+}
+
+func (s *flakySink) Write(record *domain.UsageRecord) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("unavailable")
+	}
+	s.written = append(s.written, record.SessionID)
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	sink := &flakySink{failures: 2}
+	queue := NewQueue(sink, 10, 3, time.Millisecond)
+	for _, id := range []string{"sess_1", "sess_2"} {
+		if err := queue.Write(testRecord(id)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	queue.Close()
+
+	if len(sink.written) != 2 || sink.written[0] != "sess_1" {
+		t.Errorf("Expected both records delivered after retries, got %v", sink.written)
+	}
+	if err := queue.Write(testRecord("sess_3")); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Expected ErrQueueClosed after Close, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Package wav reads the header of RIFF/WAVE files holding 16-bit PCM, so the
+// samples that follow can be streamed without buffering the file.
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// formatPCM is the WAVE_FORMAT_PCM format tag
+const formatPCM = 1
+
+// formatExtensible is WAVE_FORMAT_EXTENSIBLE, whose sub-format names the encoding
+const formatExtensible = 0xFFFE
+
+// ErrNotWAV is returned for input that is not a RIFF/WAVE file
+var ErrNotWAV = errors.New("not a RIFF/WAVE file")
+
+// Format describes the samples of a WAV file
+type Format struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+}
+
+// ReadHeader reads the RIFF header and chunks up to the start of the sample
+// data, leaving r positioned at the first sample. Only 16-bit PCM is accepted.
+func ReadHeader(r io.Reader) (*Format, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, ErrNotWAV
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, ErrNotWAV
+	}
+
+	var format *Format
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("no data chunk: %w", err)
+		}
+		id := string(header[0:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 || size > 1024 {
+				return nil, fmt.Errorf("invalid fmt chunk size %d", size)
+			}
+			chunk := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return nil, fmt.Errorf("truncated fmt chunk: %w", err)
+			}
+			var err error
+			if format, err = parseFormat(chunk); err != nil {
+				return nil, err
+			}
+		case "data":
+			if format == nil {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+			return format, nil
+		default:
+			// Skip LIST, fact and other metadata, padded to even sizes
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, fmt.Errorf("truncated %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+// parseFormat decodes a fmt chunk
+func parseFormat(chunk []byte) (*Format, error) {
+	tag := binary.LittleEndian.Uint16(chunk[0:])
+	if tag == formatExtensible && len(chunk) >= 26 {
+		// The sub-format GUID starts with the format tag
+		tag = binary.LittleEndian.Uint16(chunk[24:])
+	}
+	format := &Format{
+		Channels:      int(binary.LittleEndian.Uint16(chunk[2:])),
+		SampleRate:    int(binary.LittleEndian.Uint32(chunk[4:])),
+		BitsPerSample: int(binary.LittleEndian.Uint16(chunk[14:])),
+	}
+	if tag != formatPCM || format.BitsPerSample != 16 {
+		return nil, fmt.Errorf("unsupported WAV encoding (format %d, %d bits), only 16-bit PCM is supported",
+			tag, format.BitsPerSample)
+	}
+	if format.Channels < 1 || format.SampleRate < 1 {
+		return nil, fmt.Errorf("invalid WAV format: %d channel(s) at %dHz", format.Channels, format.SampleRate)
+	}
+	return format, nil
+}
+
+// Header returns a canonical 44-byte header for 16-bit PCM data of dataSize bytes
+func Header(format Format, dataSize int) []byte {
+	blockAlign := format.Channels * format.BitsPerSample / 8
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], uint32(36+dataSize))
+	copy(h[8:], "WAVE")
+	copy(h[12:], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], formatPCM)
+	binary.LittleEndian.PutUint16(h[22:], uint16(format.Channels))
+	binary.LittleEndian.PutUint32(h[24:], uint32(format.SampleRate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(format.SampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(h[32:], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(h[34:], uint16(format.BitsPerSample))
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], uint32(dataSize))
+	return h
+}
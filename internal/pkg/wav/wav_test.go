@@ -0,0 +1,40 @@
+package wav
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestReadHeader(t *testing.T) {
+	header := Header(Format{SampleRate: 44100, Channels: 2, BitsPerSample: 16}, 4)
+	// A LIST chunk with an odd size, padded, between fmt and data
+	withList := append(append([]byte{}, header[:36]...), "LIST\x03\x00\x00\x00abc\x00"...)
+	withList = append(withList, header[36:]...)
+
+	for name, file := range map[string][]byte{"canonical": header, "with metadata": withList} {
+		r := bytes.NewReader(append(file, 1, 2, 3, 4))
+		format, err := ReadHeader(r)
+		if err != nil {
+			t.Fatalf("%s: ReadHeader failed: %v", name, err)
+		}
+		if *format != (Format{SampleRate: 44100, Channels: 2, BitsPerSample: 16}) {
+			t.Errorf("%s: unexpected format %+v", name, *format)
+		}
+		if rest, _ := io.ReadAll(r); !bytes.Equal(rest, []byte{1, 2, 3, 4}) {
+			t.Errorf("%s: expected to be positioned at the samples, got %v", name, rest)
+		}
+	}
+}
+
+func TestReadHeaderRejects(t *testing.T) {
+	if _, err := ReadHeader(bytes.NewReader([]byte("ID3\x03 not a wav file"))); !errors.Is(err, ErrNotWAV) {
+		t.Errorf("Expected ErrNotWAV, got %v", err)
+	}
+
+	eightBit := Header(Format{SampleRate: 8000, Channels: 1, BitsPerSample: 8}, 0)
+	if _, err := ReadHeader(bytes.NewReader(eightBit)); err == nil {
+		t.Error("Expected 8-bit PCM to be rejected")
+	}
+}
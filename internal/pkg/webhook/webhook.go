@@ -0,0 +1,144 @@
+// Package webhook delivers signed JSON notifications to caller-supplied URLs,
+// retrying transient failures with exponential backoff.
+//
+// Each request carries a Gribe-Signature header of the form "t=<unix
+// time>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed with the shared secret>", so
+// receivers can authenticate the payload and reject replays.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the payload signature
+const SignatureHeader = "Gribe-Signature"
+
+// ErrNotAllowed is returned for URLs outside the allowed hosts
+var ErrNotAllowed = errors.New("callback URL host is not allowed")
+
+// Options configures a Sender
+type Options struct {
+	// AllowedHosts lists the hostnames callbacks may target, exactly or as "*.example.com"
+	AllowedHosts []string
+	Secret       string        // HMAC key signing each payload
+	MaxAttempts  int           // Deliveries tried before giving up, at least 1
+	Backoff      time.Duration // Delay before the first retry, doubled for each further one
+	Timeout      time.Duration // Time allowed for each attempt
+}
+
+// Sender posts signed payloads
+type Sender struct {
+	opts   Options
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewSender creates a sender
+func NewSender(opts Options) *Sender {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	return &Sender{
+		opts: opts,
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			// A redirect could lead anywhere, so it counts as a failed delivery
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		now: time.Now,
+	}
+}
+
+// Check validates a callback URL without calling it
+func (s *Sender) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid callback URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported callback URL scheme %q, use http or https", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("invalid callback URL %q: no host", rawURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range s.opts.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if allowed == host {
+			return nil
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNotAllowed, u.Hostname())
+}
+
+// Send posts body to rawURL until it is accepted with a 2xx status, a
+// non-retryable status is returned, the attempts run out or ctx is done
+func (s *Sender) Send(ctx context.Context, rawURL string, body []byte) error {
+	if err := s.Check(rawURL); err != nil {
+		return err
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		if retry, err = s.post(ctx, rawURL, body); err == nil || !retry || attempt == s.opts.MaxAttempts {
+			return err
+		}
+		delay := s.opts.Backoff << (attempt - 1)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last attempt: %v)", ctx.Err(), err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// post makes one delivery attempt, reporting whether a failure is worth retrying
+func (s *Sender) post(ctx context.Context, rawURL string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gribe-webhook")
+	req.Header.Set(SignatureHeader, Sign(s.opts.Secret, s.now(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("callback returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("callback returned %s", resp.Status)
+	}
+}
+
+// Sign returns the signature header value for body sent at time t
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
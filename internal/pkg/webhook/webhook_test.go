@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	var calls atomic.Int32
+	var gotBody []byte
+	var gotSignature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			gotBody, _ = io.ReadAll(r.Body)
+			gotSignature = r.Header.Get(SignatureHeader)
+		case "/rejected":
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		case "/redirect":
+			calls.Add(1)
+			http.Redirect(w, r, "/flaky", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	s := NewSender(Options{AllowedHosts: []string{"127.0.0.1"}, Secret: "secret", MaxAttempts: 3, Backoff: time.Millisecond, Timeout: time.Second})
+	now := time.Unix(1718000000, 0)
+	s.now = func() time.Time { return now }
+
+	if err := s.Send(context.Background(), server.URL+"/flaky", []byte(`{"id":"job_1"}`)); err != nil || calls.Load() != 3 {
+		t.Fatalf("Expected delivery on the third attempt, got %v after %d", err, calls.Load())
+	}
+	if string(gotBody) != `{"id":"job_1"}` || gotSignature != Sign("secret", now, gotBody) {
+		t.Errorf("Unexpected delivery %q with signature %q", gotBody, gotSignature)
+	}
+
+	for _, path := range []string{"/rejected", "/redirect"} {
+		calls.Store(0)
+		if err := s.Send(context.Background(), server.URL+path, []byte("{}")); err == nil || calls.Load() != 1 {
+			t.Errorf("%s: expected one failed attempt, got %v after %d", path, err, calls.Load())
+		}
+	}
+	if err := s.Send(context.Background(), "https://evil.example.com/hook", []byte("{}")); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("Expected ErrNotAllowed, got %v", err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1718000000.{"id":"job_1"}' | openssl dgst -sha256 -hmac secret
+	want := "t=1718000000,v1=2a58d672d6fceea82765592ab25f8d61cf22a7f977cced9986ab16cbc1cafcb7"
+	if got := Sign("secret", time.Unix(1718000000, 0), []byte(`{"id":"job_1"}`)); got != want {
+		t.Errorf("Unexpected signature:\n got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+// Package wer scores transcripts against reference transcripts by their
+// word error rate (WER) and character error rate (CER): the substitutions,
+// deletions and insertions turning the reference into the transcript, over
+// the length of the reference.
+package wer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Result counts the edits turning a reference into a transcript
+type Result struct {
+	Substitutions int `json:"substitutions"`
+	Deletions     int `json:"deletions"`
+	Insertions    int `json:"insertions"`
+	Reference     int `json:"reference"` // Words or characters of the reference
+}
+
+// Errors returns the edits in total
+func (r Result) Errors() int {
+	return r.Substitutions + r.Deletions + r.Insertions
+}
+
+// Rate returns the errors over the reference length, 0 for an empty
+// reference and transcript and 1 for a transcript of an empty reference
+func (r Result) Rate() float64 {
+	if r.Reference == 0 {
+		if r.Errors() == 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(r.Errors()) / float64(r.Reference)
+}
+
+// Add sums the counts of two results, to score a corpus: its rate weighs
+// every word alike rather than averaging the rates of its utterances
+func (r Result) Add(other Result) Result {
+	return Result{
+		Substitutions: r.Substitutions + other.Substitutions,
+		Deletions:     r.Deletions + other.Deletions,
+		Insertions:    r.Insertions + other.Insertions,
+		Reference:     r.Reference + other.Reference,
+	}
+}
+
+// Words scores the words of hypothesis against reference, both normalized
+func Words(reference, hypothesis string) Result {
+	return align(strings.Fields(Normalize(reference)), strings.Fields(Normalize(hypothesis)))
+}
+
+// Chars scores the characters of hypothesis against reference, both
+// normalized, without the spaces between words
+func Chars(reference, hypothesis string) Result {
+	return align(chars(reference), chars(hypothesis))
+}
+
+func chars(text string) []string {
+	var out []string
+	for _, r := range Normalize(text) {
+		if r != ' ' {
+			out = append(out, string(r))
+		}
+	}
+	return out
+}
+
+// Normalize lowercases text, drops punctuation other than apostrophes within
+// words, and separates words by single spaces, so transcripts differing only
+// in casing or punctuation score alike
+func Normalize(text string) string {
+	runes := []rune(strings.ToLower(text))
+	var b strings.Builder
+	for i, r := range runes {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r):
+			b.WriteRune(r)
+		case r == '\'' && i > 0 && i+1 < len(runes) && unicode.IsLetter(runes[i-1]) && unicode.IsLetter(runes[i+1]):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// align counts the fewest edits turning reference into hypothesis
+// (Levenshtein distance), preferring substitutions on ties
+func align(reference, hypothesis []string) Result {
+	// prev and cur are rows of the edit table: cell j holds the edits
+	// turning the reference so far into hypothesis[:j]
+	prev := make([]Result, len(hypothesis)+1)
+	cur := make([]Result, len(hypothesis)+1)
+	for j := range prev {
+		prev[j] = Result{Insertions: j}
+	}
+	for i := 1; i <= len(reference); i++ {
+		cur[0] = Result{Deletions: i}
+		for j := 1; j <= len(hypothesis); j++ {
+			best := prev[j-1]
+			if reference[i-1] != hypothesis[j-1] {
+				best.Substitutions++
+			}
+			if deletion := prev[j]; deletion.Errors()+1 < best.Errors() {
+				best = deletion
+				best.Deletions++
+			}
+			if insertion := cur[j-1]; insertion.Errors()+1 < best.Errors() {
+				best = insertion
+				best.Insertions++
+			}
+			cur[j] = best
+		}
+		prev, cur = cur, prev
+	}
+	result := prev[len(hypothesis)]
+	result.Reference = len(reference)
+	return result
+}
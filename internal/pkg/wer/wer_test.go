@@ -0,0 +1,35 @@
+package wer
+
+import "testing"
+
+func TestWords(t *testing.T) {
+	for _, tc := range []struct {
+		reference, hypothesis string
+		want                  Result
+	}{
+		{"turn left here", "Turn left, here.", Result{Reference: 3}},
+		{"turn left here", "turn right here", Result{Substitutions: 1, Reference: 3}},
+		{"turn left here", "turn here", Result{Deletions: 1, Reference: 3}},
+		{"turn left here", "turn left over here", Result{Insertions: 1, Reference: 3}},
+		{"it's the end", "its the end", Result{Substitutions: 1, Reference: 3}},
+		{"a b c d", "", Result{Deletions: 4, Reference: 4}},
+		{"", "hello", Result{Insertions: 1}},
+	} {
+		if got := Words(tc.reference, tc.hypothesis); got != tc.want {
+			t.Errorf("Words(%q, %q) = %+v, want %+v", tc.reference, tc.hypothesis, got, tc.want)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	corpus := Words("turn left here", "turn right here").Add(Words("stop", "stop"))
+	if rate := corpus.Rate(); rate != 0.25 {
+		t.Errorf("Expected a corpus WER of 1/4, got %v", rate)
+	}
+	if rate := Chars("kitten", "sitting").Rate(); rate != 0.5 {
+		t.Errorf("Expected a CER of 3/6, got %v", rate)
+	}
+	if Words("", "").Rate() != 0 || Words("", "noise").Rate() != 1 {
+		t.Error("Expected empty references to score 0 when matched and 1 otherwise")
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"sync"
 
-	"github.com/aira-id/gribe/internal/domain"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
 // Provider implements the ASRProvider interface using whisper.cpp
@@ -79,6 +79,12 @@ func (p *Provider) Transcribe(ctx context.Context, audio []byte, config *domain.
 	return resultChan, nil
 }
 
+// SupportsTranslation implements domain.TranslationCapable with whisper's
+// translate task
+func (p *Provider) SupportsTranslation() bool {
+	return true
+}
+
 // TranscribeStream processes audio data in streaming mode
 func (p *Provider) TranscribeStream(ctx context.Context, config *domain.TranscriptionConfig) (chan<- []byte, <-chan domain.TranscriptionChunk, error) {
 	audioIn := make(chan []byte, 100)
@@ -110,6 +116,14 @@ func (p *Provider) GetSupportedLanguages() []string {
 	return p.supportedLangs
 }
 
+// HealthCheck reports the recognizer as loaded but mock: until whisper_full
+// runs, transcripts are placeholders
+func (p *Provider) HealthCheck() domain.ProviderHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return domain.ProviderHealth{ModelLoaded: p.isInitialized, Mock: true}
+}
+
 // Close releases any resources held by the provider
 func (p *Provider) Close() error {
 	p.mu.Lock()
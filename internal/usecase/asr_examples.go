@@ -4,8 +4,8 @@ import (
 	"context"
 	"log"
 
-	"github.com/aira-id/gribe/internal/config"
-	"github.com/aira-id/gribe/internal/domain"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
 // Example functions demonstrating modular ASR provider usage with the registry pattern
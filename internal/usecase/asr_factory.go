@@ -1,13 +1,14 @@
 package usecase
 
 import (
-	"github.com/aira-id/gribe/internal/domain"
 	"github.com/aira-id/gribe/internal/pkg/mock"
 	"github.com/aira-id/gribe/internal/pkg/sherpa"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
 // Ensure domain is used (for ASRProvider interface)
 var _ domain.ASRProvider = (*sherpa.Provider)(nil)
+var _ domain.SpeakerEmbedder = (*sherpa.SpeakerEmbedder)(nil)
 
 // ASRProviderType represents the type of ASR provider
 type ASRProviderType string
@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aira-id/gribe/internal/pkg/modelfetch"
+	"github.com/aira-id/gribe/pkg/config"
+)
+
+// FetchModels downloads the files missing from models_dir of the models that
+// have a url, verifying those with a checksum. It runs before the
+// configuration is validated, so a deployment may start with an empty
+// models volume.
+func FetchModels(ctx context.Context, cfg *config.ASRConfig, fetcher *modelfetch.Fetcher) error {
+	names := make([]string, 0, len(cfg.Models))
+	for name := range cfg.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		model := cfg.Models[name]
+		if model.URL == "" {
+			continue
+		}
+		modelDir := filepath.Join(cfg.ModelsDir, name)
+		for _, file := range model.Files() {
+			source := model.URL
+			if file != "." {
+				source = strings.TrimSuffix(model.URL, "/") + "/" + filepath.ToSlash(file)
+			}
+			if err := fetcher.Fetch(ctx, source, filepath.Join(modelDir, file), model.Checksums[file]); err != nil {
+				return fmt.Errorf("model %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -5,7 +5,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/aira-id/gribe/internal/domain"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
 // MockASRProvider is a mock implementation of ASRProvider for testing
@@ -163,6 +163,11 @@ func (m *MockASRProvider) GetSupportedLanguages() []string {
 	return []string{"en", "es", "fr", "de", "ja", "zh"}
 }
 
+// HealthCheck implements ASRProvider.HealthCheck
+func (m *MockASRProvider) HealthCheck() domain.ProviderHealth {
+	return domain.ProviderHealth{ModelLoaded: true, Mock: true}
+}
+
 // Close implements ASRProvider.Close
 func (m *MockASRProvider) Close() error {
 	return nil
@@ -3,12 +3,15 @@ package usecase
 import (
 	"fmt"
 	"log"
+	"path/filepath"
+	"runtime"
 	"sync"
+	"time"
 
-	"github.com/aira-id/gribe/internal/config"
-	"github.com/aira-id/gribe/internal/domain"
 	"github.com/aira-id/gribe/internal/pkg/sherpa"
 	"github.com/aira-id/gribe/internal/pkg/whisper"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
 // ASRModelRegistry manages ASR provider instances with singleton pattern.
@@ -16,8 +19,13 @@ import (
 type ASRModelRegistry struct {
 	mu            sync.RWMutex
 	globalConfig  *config.ASRConfig
-	loadedModels  map[string]domain.ASRProvider // modelName -> provider instance
+	loadedModels  map[string]*swappableProvider // modelName -> provider instance
 	providerTypes map[ASRProviderType]ProviderCreator
+	reloadMu      sync.Mutex             // Serializes reloads
+	loadFailures  map[string]loadFailure // Latest failed load of models not loaded since
+	selfTests     map[string]SelfTestResult
+	gpu           *resourceBudget // Estimated memory of each GPU
+	cpu           *resourceBudget // Inference threads of the server
 }
 
 // ProviderCreator is a function that creates an ASR provider from config
@@ -27,8 +35,14 @@ type ProviderCreator func(globalConfig *config.ASRConfig, modelName string, mode
 func NewASRModelRegistry(cfg *config.ASRConfig) *ASRModelRegistry {
 	registry := &ASRModelRegistry{
 		globalConfig:  cfg,
-		loadedModels:  make(map[string]domain.ASRProvider),
+		loadedModels:  make(map[string]*swappableProvider),
 		providerTypes: make(map[ASRProviderType]ProviderCreator),
+		loadFailures:  make(map[string]loadFailure),
+		selfTests:     make(map[string]SelfTestResult),
+	}
+	if cfg != nil {
+		registry.gpu = newResourceBudget("GPU memory", "MB", cfg.GPUMemoryMB)
+		registry.cpu = newResourceBudget("CPU", "threads", cpuBudget(cfg))
 	}
 
 	// Register built-in provider creators
@@ -96,6 +110,43 @@ func (r *ASRModelRegistry) GetModel(modelName, language string) (domain.ASRProvi
 		return provider, nil
 	}
 
+	creator, err := r.creator(modelName, &modelConfig)
+	if err != nil {
+		return nil, r.loadFailed(modelName, err)
+	}
+	free, err := r.reserve(modelName, &modelConfig)
+	if err != nil {
+		return nil, r.loadFailed(modelName, err)
+	}
+	provider, err := r.load(creator, modelName, &modelConfig)
+	if err != nil {
+		free()
+		return nil, r.loadFailed(modelName, err)
+	}
+
+	// Cache the loaded provider
+	swappable := newSwappableProvider(modelName, provider, free)
+	r.loadedModels[modelName] = swappable
+	delete(r.loadFailures, modelName)
+	log.Printf("[INFO] Successfully loaded and cached model: %s", modelName)
+
+	return swappable, nil
+}
+
+// loadFailure is a model's failed load, reported by Health
+type loadFailure struct {
+	err error
+	at  time.Time
+}
+
+// loadFailed records a model's failed load and returns err. The caller holds r.mu.
+func (r *ASRModelRegistry) loadFailed(modelName string, err error) error {
+	r.loadFailures[modelName] = loadFailure{err: err, at: time.Now()}
+	return err
+}
+
+// creator returns the creator of a model's provider type. The caller holds r.mu.
+func (r *ASRModelRegistry) creator(modelName string, modelConfig *config.ModelConfig) (ProviderCreator, error) {
 	// Get provider type from model config
 	providerType := ASRProviderType(modelConfig.Provider)
 	if providerType == "" {
@@ -107,19 +158,93 @@ func (r *ASRModelRegistry) GetModel(modelName, language string) (domain.ASRProvi
 	if !exists {
 		return nil, fmt.Errorf("unsupported provider type: %s", providerType)
 	}
+	return creator, nil
+}
 
-	// Load the model
-	log.Printf("[INFO] Loading model: %s (provider: %s)", modelName, providerType)
-	provider, err := creator(r.globalConfig, modelName, &modelConfig)
+// load creates a new instance of a model
+func (r *ASRModelRegistry) load(creator ProviderCreator, modelName string, modelConfig *config.ModelConfig) (domain.ASRProvider, error) {
+	if err := modelConfig.VerifyChecksums(filepath.Join(r.globalConfig.ModelsDir, modelName)); err != nil {
+		return nil, fmt.Errorf("model '%s' failed verification: %w", modelName, err)
+	}
+	log.Printf("[INFO] Loading model: %s (provider: %s)", modelName, modelConfig.Provider)
+	provider, err := creator(r.globalConfig, modelName, modelConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load model '%s': %w", modelName, err)
 	}
+	return provider, nil
+}
 
-	// Cache the loaded provider
-	r.loadedModels[modelName] = provider
-	log.Printf("[INFO] Successfully loaded and cached model: %s", modelName)
+// reserve takes what a new instance of a model needs of the budgets: the
+// estimated memory of its GPU for a CUDA model, else its inference threads.
+// It returns the func that gives them back.
+func (r *ASRModelRegistry) reserve(modelName string, modelConfig *config.ModelConfig) (func(), error) {
+	if r.globalConfig.ExecutionProvider(modelConfig) == config.ExecutionCUDA {
+		return r.gpu.reserve(modelName, fmt.Sprintf("device %d", modelConfig.DeviceID), modelConfig.VRAMMB)
+	}
+	return r.cpu.reserve(modelName, "the server", r.globalConfig.Threads(modelConfig))
+}
 
-	return provider, nil
+// cpuBudget returns the inference threads loaded models may use together. With
+// fit_gomaxprocs it leaves one of the Go runtime's CPUs to the event loops,
+// so decoding cannot take them all.
+func cpuBudget(cfg *config.ASRConfig) int {
+	budget := cfg.CPUBudget
+	if !cfg.FitGOMAXPROCS {
+		return budget
+	}
+	available := runtime.GOMAXPROCS(0) - 1
+	if available < 1 {
+		available = 1
+	}
+	if budget == 0 || budget > available {
+		log.Printf("[INFO] Limiting inference to %d threads, GOMAXPROCS is %d", available, runtime.GOMAXPROCS(0))
+		budget = available
+	}
+	return budget
+}
+
+// ReloadModel loads a model again from its files on disk, after they were
+// replaced, while sessions keep using it: new transcriptions go to the new
+// instance and the previous one is closed once its transcriptions finish. A
+// model that fails to load keeps its previous instance. It reports false if
+// the model was not loaded, as its first use loads the new files anyway.
+func (r *ASRModelRegistry) ReloadModel(modelName string) (bool, error) {
+	if r.globalConfig == nil {
+		return false, fmt.Errorf("ASR configuration not available")
+	}
+	modelConfig, exists := r.globalConfig.Models[modelName]
+	if !exists {
+		return false, fmt.Errorf("model '%s' not found. Available models: %v", modelName, r.GetAvailableModels())
+	}
+
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	r.mu.RLock()
+	swappable, loaded := r.loadedModels[modelName]
+	creator, err := r.creator(modelName, &modelConfig)
+	r.mu.RUnlock()
+	if !loaded || err != nil {
+		return false, err
+	}
+
+	// Sessions keep transcribing with the loaded instance meanwhile, so the
+	// new one needs GPU memory and threads of its own until the old one is closed
+	free, err := r.reserve(modelName, &modelConfig)
+	if err != nil {
+		return false, err
+	}
+	provider, err := r.load(creator, modelName, &modelConfig)
+	if err != nil {
+		free()
+		return false, err
+	}
+	swappable.swap(provider, free)
+	r.mu.Lock()
+	delete(r.selfTests, modelName) // Taken by the previous files
+	r.mu.Unlock()
+	log.Printf("[INFO] Successfully reloaded model: %s", modelName)
+	return true, nil
 }
 
 // GetAvailableModels returns a list of available model names
@@ -149,6 +274,75 @@ func (r *ASRModelRegistry) GetModelLanguages(modelName string) ([]string, error)
 	return modelConfig.Languages, nil
 }
 
+// GetModelDefaults returns the session defaults declared for a model, or nil if it has none
+func (r *ASRModelRegistry) GetModelDefaults(modelName string) *config.ModelDefaults {
+	if r.globalConfig == nil {
+		return nil
+	}
+
+	modelConfig, exists := r.globalConfig.Models[modelName]
+	if !exists {
+		return nil
+	}
+	return modelConfig.Defaults
+}
+
+// GetModelProvider returns the provider a model runs on, e.g. "sherpa-onnx",
+// or "" for unknown models
+func (r *ASRModelRegistry) GetModelProvider(modelName string) string {
+	if r.globalConfig == nil {
+		return ""
+	}
+	if modelConfig, exists := r.globalConfig.Models[modelName]; exists {
+		return modelConfig.Provider
+	}
+	return ""
+}
+
+// Health reports the health of every configured model: that of its provider
+// once loaded, else whether its latest load failed. Models are loaded on
+// first use, so one never used is not loaded yet without being unhealthy.
+func (r *ASRModelRegistry) Health() map[string]ModelHealth {
+	if r.globalConfig == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	models := make(map[string]ModelHealth, len(r.globalConfig.Models))
+	for name, modelConfig := range r.globalConfig.Models {
+		model := ModelHealth{Provider: modelConfig.Provider, Status: HealthNotLoaded}
+		if provider, loaded := r.loadedModels[name]; loaded {
+			model.ProviderHealth = provider.HealthCheck()
+			model.Status = HealthOK
+			if !model.ModelLoaded || model.Mock || model.Failing {
+				model.Status = HealthDegraded
+			}
+		} else if failure, failed := r.loadFailures[name]; failed {
+			at := failure.at
+			model.LastError = failure.err.Error()
+			model.LastErrorAt = &at
+			model.Failing = true
+			model.Status = HealthDegraded
+		}
+		if result, tested := r.selfTests[name]; tested {
+			model.SelfTest = &result
+			if !result.Passed {
+				model.Status = HealthDegraded
+			}
+		}
+		models[name] = model
+	}
+	return models
+}
+
+// RecordSelfTest records the outcome of a model's startup self-test for Health
+func (r *ASRModelRegistry) RecordSelfTest(modelName string, result SelfTestResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.selfTests[modelName] = result
+}
+
 // IsModelLoaded checks if a model is already loaded
 func (r *ASRModelRegistry) IsModelLoaded(modelName string) bool {
 	r.mu.RLock()
@@ -176,15 +370,13 @@ func (r *ASRModelRegistry) Close() error {
 
 	var lastErr error
 	for name, provider := range r.loadedModels {
-		if closer, ok := provider.(interface{ Close() error }); ok {
-			if err := closer.Close(); err != nil {
-				log.Printf("[WARN] Failed to close model '%s': %v", name, err)
-				lastErr = err
-			}
+		if err := provider.Close(); err != nil {
+			log.Printf("[WARN] Failed to close model '%s': %v", name, err)
+			lastErr = err
 		}
 	}
 
-	r.loadedModels = make(map[string]domain.ASRProvider)
+	r.loadedModels = make(map[string]*swappableProvider)
 	return lastErr
 }
 
@@ -192,8 +384,9 @@ func (r *ASRModelRegistry) Close() error {
 
 func createSherpaProvider(globalConfig *config.ASRConfig, modelName string, modelConfig *config.ModelConfig) (domain.ASRProvider, error) {
 	sherpaConfig := &sherpa.Config{
-		Provider:   globalConfig.Provider,
-		NumThreads: globalConfig.NumThreads,
+		Provider:   globalConfig.ExecutionProvider(modelConfig),
+		DeviceID:   modelConfig.DeviceID,
+		NumThreads: globalConfig.Threads(modelConfig),
 		ModelsDir:  globalConfig.ModelsDir,
 		ModelName:  modelName,
 		Encoder:    modelConfig.Encoder,
@@ -204,6 +397,12 @@ func createSherpaProvider(globalConfig *config.ASRConfig, modelName string, mode
 		// Note: Language is set per-transcription, not per-model
 		Language: modelConfig.Languages[0], // Default to first language
 	}
+	if modelConfig.Precision == config.PrecisionInt8 && modelConfig.Int8 != nil {
+		sherpaConfig.Precision = modelConfig.Precision
+		sherpaConfig.Int8Encoder = modelConfig.Int8.Encoder
+		sherpaConfig.Int8Decoder = modelConfig.Int8.Decoder
+		sherpaConfig.Int8Joiner = modelConfig.Int8.Joiner
+	}
 
 	return sherpa.New(sherpaConfig)
 }
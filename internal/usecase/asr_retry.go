@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// asrRetries counts provider calls repeated after a transient failure
+var asrRetries = metrics.NewCounter("gribe_asr_retries_total",
+	"ASR provider calls retried after a transient failure")
+
+// retryPolicy retries transient provider failures with exponential backoff
+type retryPolicy struct {
+	retries int           // Attempts after the first, 0 disables retries
+	backoff time.Duration // Delay before the first retry, doubled for each further one
+}
+
+// transcribe calls provider.Transcribe, retrying transient failures until a
+// chunk has been passed on. Once text has reached the caller a retry would
+// repeat it, so later failures surface as they are.
+func (p retryPolicy) transcribe(ctx context.Context, provider domain.ASRProvider, audio []byte, config *domain.TranscriptionConfig) (<-chan domain.TranscriptionChunk, error) {
+	attempt := 0
+	start := func() (<-chan domain.TranscriptionChunk, error) {
+		results, err := provider.Transcribe(ctx, audio, config)
+		for err != nil && p.retryable(ctx, attempt, err) {
+			attempt++
+			results, err = provider.Transcribe(ctx, audio, config)
+		}
+		return results, err
+	}
+
+	results, err := start()
+	if err != nil || p.retries == 0 {
+		return results, err
+	}
+
+	out := make(chan domain.TranscriptionChunk)
+	go func() {
+		defer close(out)
+		forwarded := false
+		for {
+			chunk, ok := <-results
+			if !ok {
+				return
+			}
+			if chunk.Err != nil && !forwarded && p.retryable(ctx, attempt, chunk.Err) {
+				attempt++
+				if results, err = start(); err != nil {
+					p.send(ctx, out, domain.TranscriptionChunk{Err: err})
+					return
+				}
+				continue
+			}
+			forwarded = true
+			if !p.send(ctx, out, chunk) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// retryable reports whether err is worth another attempt, sleeping for the
+// backoff if so
+func (p retryPolicy) retryable(ctx context.Context, attempt int, err error) bool {
+	if attempt >= p.retries || !domain.IsTransient(err) || ctx.Err() != nil {
+		return false
+	}
+	delay := p.backoff << attempt
+	log.Printf("[WARN] Transient ASR failure, retrying in %v (%d/%d): %v", delay, attempt+1, p.retries, err)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+	}
+	asrRetries.Inc()
+	return true
+}
+
+// send passes a chunk on unless the caller has given up
+func (p retryPolicy) send(ctx context.Context, out chan<- domain.TranscriptionChunk, chunk domain.TranscriptionChunk) bool {
+	select {
+	case out <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
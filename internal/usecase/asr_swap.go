@@ -0,0 +1,201 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// swappableProvider is the ASRProvider the registry hands out for a model. It
+// forwards to the model's current instance, so a reloaded model serves new
+// transcriptions at once while those in flight finish on the instance they
+// started on, which is closed once they have drained.
+type swappableProvider struct {
+	name    string
+	mu      sync.Mutex
+	current *providerInstance
+
+	// Outcome of the model's transcriptions, for HealthCheck
+	lastErr   error
+	lastErrAt time.Time
+	failing   bool
+}
+
+// providerInstance is one loaded instance of a model
+type providerInstance struct {
+	domain.ASRProvider
+	inFlight int    // Transcriptions still streaming from the instance
+	retired  bool   // Replaced by a reload, closed once inFlight drops to 0
+	free     func() // Gives back the instance's GPU memory once closed
+}
+
+func newSwappableProvider(name string, provider domain.ASRProvider, free func()) *swappableProvider {
+	return &swappableProvider{name: name, current: &providerInstance{ASRProvider: provider, free: free}}
+}
+
+// acquire returns the current instance for a new transcription
+func (p *swappableProvider) acquire() *providerInstance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current.inFlight++
+	return p.current
+}
+
+// release ends a transcription on instance, closing it if it was retired and
+// this was its last one
+func (p *swappableProvider) release(instance *providerInstance) {
+	p.mu.Lock()
+	instance.inFlight--
+	drained := instance.retired && instance.inFlight == 0
+	p.mu.Unlock()
+	if drained {
+		p.closeInstance(instance)
+	}
+}
+
+// swap makes provider serve new transcriptions and retires the instance it
+// replaces
+func (p *swappableProvider) swap(provider domain.ASRProvider, free func()) {
+	p.mu.Lock()
+	old := p.current
+	p.current = &providerInstance{ASRProvider: provider, free: free}
+	old.retired = true
+	drained := old.inFlight == 0
+	p.mu.Unlock()
+	if drained {
+		p.closeInstance(old)
+	} else {
+		log.Printf("[INFO] Model %s reloaded, closing the previous instance after %d transcriptions", p.name, old.inFlight)
+	}
+}
+
+func (p *swappableProvider) closeInstance(instance *providerInstance) {
+	if err := instance.Close(); err != nil {
+		log.Printf("[WARN] Failed to close previous instance of model '%s': %v", p.name, err)
+	}
+	instance.free()
+}
+
+func (p *swappableProvider) Transcribe(ctx context.Context, audio []byte, config *domain.TranscriptionConfig) (<-chan domain.TranscriptionChunk, error) {
+	instance := p.acquire()
+	results, err := instance.Transcribe(ctx, audio, config)
+	if err != nil {
+		p.release(instance)
+		p.recordOutcome(err)
+		return nil, err
+	}
+	return p.releaseAfter(ctx, instance, results), nil
+}
+
+func (p *swappableProvider) TranscribeStream(ctx context.Context, config *domain.TranscriptionConfig) (chan<- []byte, <-chan domain.TranscriptionChunk, error) {
+	instance := p.acquire()
+	audioIn, results, err := instance.TranscribeStream(ctx, config)
+	if err != nil {
+		p.release(instance)
+		p.recordOutcome(err)
+		return nil, nil, err
+	}
+	return audioIn, p.releaseAfter(ctx, instance, results), nil
+}
+
+// releaseAfter forwards results and releases instance once the provider
+// closes them, recording whether it failed. Results nobody reads after ctx
+// ends are drained and dropped.
+func (p *swappableProvider) releaseAfter(ctx context.Context, instance *providerInstance,
+	results <-chan domain.TranscriptionChunk) <-chan domain.TranscriptionChunk {
+	out := make(chan domain.TranscriptionChunk, cap(results))
+	go func() {
+		defer close(out)
+		defer p.release(instance)
+		var err error
+		defer func() {
+			if ctx.Err() == nil {
+				p.recordOutcome(err)
+			}
+		}()
+		for chunk := range results {
+			if chunk.Err != nil {
+				err = chunk.Err
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				for range results {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (p *swappableProvider) GetSupportedModels() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.current.GetSupportedModels()
+}
+
+func (p *swappableProvider) GetSupportedLanguages() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.current.GetSupportedLanguages()
+}
+
+func (p *swappableProvider) SupportsPrompt() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	capable, ok := p.current.ASRProvider.(domain.PromptCapable)
+	return ok && capable.SupportsPrompt()
+}
+
+func (p *swappableProvider) SupportsTemperature() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	capable, ok := p.current.ASRProvider.(domain.PromptCapable)
+	return ok && capable.SupportsTemperature()
+}
+
+func (p *swappableProvider) SupportsTranslation() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return domain.SupportsTranslation(p.current.ASRProvider)
+}
+
+// recordOutcome records how a transcription ended, nil for a success
+func (p *swappableProvider) recordOutcome(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failing = err != nil
+	if err != nil {
+		p.lastErr = err
+		p.lastErrAt = time.Now()
+	}
+}
+
+// HealthCheck reports the current instance's health with the model's
+// transcriptions in flight and their latest failure
+func (p *swappableProvider) HealthCheck() domain.ProviderHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	health := p.current.HealthCheck()
+	health.QueueDepth += p.current.inFlight
+	if p.lastErr != nil {
+		at := p.lastErrAt
+		health.LastError = p.lastErr.Error()
+		health.LastErrorAt = &at
+	}
+	health.Failing = health.Failing || p.failing
+	return health
+}
+
+// Close closes the current instance. Retired instances close once drained.
+func (p *swappableProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.current.Close()
+	p.current.free()
+	return err
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// gatedProvider streams one chunk per transcription once done is closed
+type gatedProvider struct {
+	MockASRProvider
+	version int
+	done    chan struct{}
+	closed  atomic.Bool
+}
+
+func (p *gatedProvider) Transcribe(ctx context.Context, audio []byte, config *domain.TranscriptionConfig) (<-chan domain.TranscriptionChunk, error) {
+	results := make(chan domain.TranscriptionChunk, 1)
+	go func() {
+		defer close(results)
+		<-p.done
+		results <- domain.TranscriptionChunk{Text: string(rune('0' + p.version)), IsFinal: true}
+	}()
+	return results, nil
+}
+
+func (p *gatedProvider) Close() error {
+	p.closed.Store(true)
+	return nil
+}
+
+func TestReloadModel(t *testing.T) {
+	registry := NewASRModelRegistry(&config.ASRConfig{
+		Models: map[string]config.ModelConfig{"model": {Provider: string(ProviderMock), Languages: []string{"en"}}},
+	})
+	var instances []*gatedProvider
+	registry.RegisterProviderType(ProviderMock, func(*config.ASRConfig, string, *config.ModelConfig) (domain.ASRProvider, error) {
+		p := &gatedProvider{version: len(instances) + 1, done: make(chan struct{})}
+		instances = append(instances, p)
+		return p, nil
+	})
+
+	if reloaded, err := registry.ReloadModel("model"); reloaded || err != nil {
+		t.Fatalf("Expected a model not loaded yet to be left alone, got %v, %v", reloaded, err)
+	}
+	provider, err := registry.GetModel("model", "en")
+	if err != nil {
+		t.Fatalf("GetModel failed: %v", err)
+	}
+	inFlight, err := provider.Transcribe(context.Background(), nil, &domain.TranscriptionConfig{})
+	if err != nil {
+		t.Fatalf("Transcribe failed: %v", err)
+	}
+
+	if reloaded, err := registry.ReloadModel("model"); !reloaded || err != nil {
+		t.Fatalf("Expected the model to be reloaded, got %v, %v", reloaded, err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("Expected a second instance, got %d", len(instances))
+	}
+	old, current := instances[0], instances[1]
+	if old.closed.Load() {
+		t.Fatal("Expected the previous instance to stay open while a transcription runs on it")
+	}
+
+	// New transcriptions go to the new instance through the same provider
+	close(current.done)
+	results, err := provider.Transcribe(context.Background(), nil, &domain.TranscriptionConfig{})
+	if err != nil {
+		t.Fatalf("Transcribe failed: %v", err)
+	}
+	if chunk := <-results; chunk.Text != "2" {
+		t.Errorf("Expected the new instance to transcribe, got %q", chunk.Text)
+	}
+
+	// The transcription in flight finishes on the previous instance, which then closes
+	close(old.done)
+	if chunk := <-inFlight; chunk.Text != "1" {
+		t.Errorf("Expected the previous instance to finish its transcription, got %q", chunk.Text)
+	}
+	for range inFlight {
+	}
+	if !old.closed.Load() || current.closed.Load() {
+		t.Errorf("Expected only the previous instance to be closed once drained, got %v and %v",
+			old.closed.Load(), current.closed.Load())
+	}
+}
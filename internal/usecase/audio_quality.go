@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"math"
+	"sort"
+
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+const (
+	qualityFrameMs = 20 // Frames whose levels estimate noise and silence
+
+	clippingLevel   = 0.99 // Sample magnitude counted as clipped
+	silenceDBFS     = -50  // Frames below this level are silent
+	floorDBFS       = -100 // Level of digital silence
+	tooQuietDBFS    = -35  // Speech quieter than this is too quiet
+	clippingPercent = 1    // More clipped samples than this is clipping
+	noisySNRDB      = 10   // Speech less this far above the noise is noisy
+)
+
+// measureAudioQuality measures the level, clipping, noise and silence of a
+// turn. Speech is taken as the loudest tenth of its frames and noise as the
+// quietest tenth, so a turn without pauses estimates a low SNR.
+func measureAudioQuality(samples []float32, sampleRate int) domain.AudioQuality {
+	var quality domain.AudioQuality
+	if len(samples) == 0 {
+		return quality
+	}
+
+	var sum float64
+	clipped := 0
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+		if math.Abs(float64(s)) >= clippingLevel {
+			clipped++
+		}
+	}
+	quality.ClippingPercent = round1(100 * float64(clipped) / float64(len(samples)))
+	quality.RMSDBFS = round1(dbfs(sum / float64(len(samples))))
+
+	frame := max(qualityFrameMs*sampleRate/1000, 1)
+	levels := make([]float64, 0, len(samples)/frame+1)
+	silent := 0
+	for start := 0; start < len(samples); start += frame {
+		end := min(start+frame, len(samples))
+		if end-start < frame && len(levels) > 0 {
+			break // A partial last frame would skew the levels
+		}
+		var energy float64
+		for _, s := range samples[start:end] {
+			energy += float64(s) * float64(s)
+		}
+		level := dbfs(energy / float64(end-start))
+		if level < silenceDBFS {
+			silent++
+		}
+		levels = append(levels, level)
+	}
+	quality.SilenceRatio = round1(float64(silent) / float64(len(levels)))
+
+	sort.Float64s(levels)
+	noise := levels[len(levels)/10]
+	speech := levels[len(levels)*9/10]
+	quality.SNRDB = round1(speech - noise)
+
+	switch {
+	case speech < tooQuietDBFS:
+		quality.Issues = append(quality.Issues, domain.AudioIssueTooQuiet)
+	case quality.SNRDB < noisySNRDB:
+		quality.Issues = append(quality.Issues, domain.AudioIssueNoisy)
+	}
+	if quality.ClippingPercent > clippingPercent {
+		quality.Issues = append(quality.Issues, domain.AudioIssueClipping)
+	}
+	return quality
+}
+
+// dbfs converts a mean square sample to dB relative to full scale
+func dbfs(meanSquare float64) float64 {
+	if meanSquare <= 0 {
+		return floorDBFS
+	}
+	return max(10*math.Log10(meanSquare), floorDBFS)
+}
+
+// round1 rounds to one decimal, as precise as the estimates are
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// sendAudioQuality sends input_audio_buffer.quality for a committed item
+func (u *SessionUsecase) sendAudioQuality(conn Conn, state *domain.SessionState, itemID string, audio []byte) {
+	var format *domain.AudioFormat
+	if state.Config.Audio != nil && state.Config.Audio.Input != nil {
+		format = state.Config.Audio.Input.Format
+	}
+	samples := inputSamples(audio, format)
+	defer bufpool.PutFloat32s(samples)
+	conn.WriteJSON(&domain.InputAudioBufferQualityEvent{
+		BaseEvent: domain.BaseEvent{
+			EventID: u.idGen.GenerateEventID(),
+			Type:    domain.EventInputAudioBufferQuality,
+		},
+		ItemID:       itemID,
+		AudioQuality: measureAudioQuality(samples, format.SampleRate()),
+	})
+}
@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// tone returns a second of a 16kHz 440Hz sine of amplitude, clipped at full
+// scale, over noise of amplitude noise
+func tone(amplitude, noise float64) []float32 {
+	samples := make([]float32, 16000)
+	for i := range samples {
+		s := amplitude*math.Sin(2*math.Pi*440*float64(i)/16000) + noise*math.Sin(2*math.Pi*3001*float64(i)/16000)
+		samples[i] = float32(max(min(s, 1), -1))
+	}
+	return samples
+}
+
+func TestMeasureAudioQuality(t *testing.T) {
+	// Half a second of speech, half a second of pause
+	clean := append(tone(0.3, 0)[:8000], make([]float32, 8000)...)
+	quality := measureAudioQuality(clean, 16000)
+	if len(quality.Issues) != 0 || quality.ClippingPercent != 0 || quality.SilenceRatio != 0.5 || quality.SNRDB < 50 {
+		t.Errorf("Expected clean audio without issues, got %+v", quality)
+	}
+	if quality.RMSDBFS < -17 || quality.RMSDBFS > -16 {
+		t.Errorf("Expected a level of about -16.5 dBFS, got %v", quality.RMSDBFS)
+	}
+
+	quiet := append(tone(0.005, 0)[:8000], make([]float32, 8000)...)
+	if quality := measureAudioQuality(quiet, 16000); !slices.Equal(quality.Issues, []string{domain.AudioIssueTooQuiet}) {
+		t.Errorf("Expected quiet audio to be too quiet, got %+v", quality)
+	}
+
+	if quality := measureAudioQuality(tone(2, 0), 16000); !slices.Contains(quality.Issues, domain.AudioIssueClipping) || quality.ClippingPercent < 50 {
+		t.Errorf("Expected overdriven audio to clip, got %+v", quality)
+	}
+
+	noisy := append(tone(0.3, 0.2)[:8000], tone(0, 0.2)[8000:]...)
+	if quality := measureAudioQuality(noisy, 16000); !slices.Equal(quality.Issues, []string{domain.AudioIssueNoisy}) || quality.SilenceRatio != 0 {
+		t.Errorf("Expected speech over loud noise to be noisy, got %+v", quality)
+	}
+
+	if quality := measureAudioQuality(nil, 16000); quality.Issues != nil {
+		t.Errorf("Expected no issues without audio, got %+v", quality)
+	}
+}
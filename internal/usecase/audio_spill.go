@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"os"
+
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+var audioSpills = metrics.NewCounter("gribe_audio_buffer_spills_total",
+	"Audio buffers moved to disk after outgrowing the spill threshold")
+
+// spillFile is a temp file holding a spilled audio buffer, removed on Close
+type spillFile struct {
+	*os.File
+}
+
+func (f spillFile) Close() error {
+	f.File.Close()
+	return os.Remove(f.Name())
+}
+
+// spillOpener returns a function creating spill files in dir, or in the
+// system temp directory when dir is empty
+func spillOpener(dir string) func() (domain.SpillFile, error) {
+	return func() (domain.SpillFile, error) {
+		f, err := os.CreateTemp(dir, "gribe-audio-*.pcm")
+		if err != nil {
+			return nil, err
+		}
+		audioSpills.Inc()
+		return spillFile{f}, nil
+	}
+}
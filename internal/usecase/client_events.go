@@ -0,0 +1,138 @@
+package usecase
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// maxRememberedEvents bounds the client events a session remembers within the
+// dedup window
+const maxRememberedEvents = 1024
+
+// duplicateClientEvents counts client events answered from the log
+var duplicateClientEvents = metrics.NewCounter("gribe_duplicate_client_events_total",
+	"Client events retransmitted within the dedup window and answered without handling them again")
+
+// clientEventLog remembers the client events a session handled within the
+// dedup window, with the server events each one answered, so a client
+// retransmitting an event after a network blip gets the same answer again
+// instead of having the event handled twice
+type clientEventLog struct {
+	mu      sync.Mutex
+	window  time.Duration
+	handled map[string]*handledEvent // Client event ID -> its handling
+	order   []string                 // Client event IDs, oldest first
+}
+
+// handledEvent is a client event handled within the window
+type handledEvent struct {
+	at      time.Time
+	replies []json.RawMessage // Server events sent while handling it
+}
+
+func newClientEventLog(window time.Duration) *clientEventLog {
+	return &clientEventLog{window: window, handled: make(map[string]*handledEvent)}
+}
+
+// replies returns the server events that answered eventID, and whether it
+// was handled within the window
+func (l *clientEventLog) replies(eventID string, now time.Time) ([]json.RawMessage, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	event, ok := l.handled[eventID]
+	if !ok || now.Sub(event.at) >= l.window {
+		return nil, false
+	}
+	return event.replies, true
+}
+
+// add remembers eventID as handled at now with its replies, forgetting
+// events past the window or beyond maxRememberedEvents
+func (l *clientEventLog) add(eventID string, now time.Time, replies []json.RawMessage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.handled[eventID]; !ok {
+		l.order = append(l.order, eventID)
+	}
+	l.handled[eventID] = &handledEvent{at: now, replies: replies}
+	for len(l.order) > 0 {
+		oldest := l.handled[l.order[0]]
+		if len(l.order) <= maxRememberedEvents && now.Sub(oldest.at) < l.window {
+			break
+		}
+		delete(l.handled, l.order[0])
+		l.order = l.order[1:]
+	}
+}
+
+// replyRecorder records the server events sent while a client event is
+// handled. Handlers may keep the connection for events sent later, such as
+// transcription results; those pass through unrecorded.
+type replyRecorder struct {
+	Conn
+	mu      sync.Mutex
+	done    bool
+	replies []json.RawMessage
+}
+
+func (c *replyRecorder) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	if !c.done {
+		// Encoded now, as the event may change once sent
+		if data, err := json.Marshal(v); err == nil {
+			c.replies = append(c.replies, data)
+		}
+	}
+	c.mu.Unlock()
+	return c.Conn.WriteJSON(v)
+}
+
+// stop ends the recording and returns the events recorded
+func (c *replyRecorder) stop() []json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done = true
+	return c.replies
+}
+
+// isAudioAppend reports whether a client event appends audio, which is never
+// deduplicated
+func isAudioAppend(t domain.EventType) bool {
+	return t == domain.EventInputAudioBufferAppend || t == domain.EventOutputAudioBufferAppend
+}
+
+// clientEvents returns the log of the client events of a session, nil when
+// deduplication is disabled
+func (u *SessionUsecase) clientEvents(sessionID string) *clientEventLog {
+	if u.dedupWindow <= 0 {
+		return nil
+	}
+	events, _ := u.clientEventLogs.LoadOrStore(sessionID, newClientEventLog(u.dedupWindow))
+	return events.(*clientEventLog)
+}
+
+// answerDuplicate sends again the replies to a client event already handled
+// within the window and reports whether it was. The replies go to the client
+// only: observers and subscribers saw them the first time.
+func (u *SessionUsecase) answerDuplicate(conn Conn, events *clientEventLog, sessionID, eventID string) bool {
+	replies, ok := events.replies(eventID, u.clock.Now())
+	if !ok {
+		return false
+	}
+	log.Printf("Session %s: client event %s already handled, sending its %d replies again", sessionID, eventID, len(replies))
+	duplicateClientEvents.Inc()
+	if observed, ok := conn.(*observedConn); ok {
+		conn = observed.Conn
+	}
+	for _, reply := range replies {
+		if err := conn.WriteJSON(reply); err != nil {
+			break
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package usecase
+
+import (
+	"errors"
+	"log"
+
+	"github.com/aira-id/gribe/internal/pkg/convstore"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+const (
+	// DefaultItemPageSize and MaxItemPageSize bound a page of stored items
+	DefaultItemPageSize = 20
+	MaxItemPageSize     = 100
+)
+
+// ErrItemNotFound is returned for an item page that starts after an item
+// the conversation does not hold
+var ErrItemNotFound = errors.New("item not found")
+
+// ItemPage selects a page of a stored conversation's items
+type ItemPage struct {
+	After     string // ID of the item the page starts after, empty for the first page
+	Limit     int    // Items on the page, DefaultItemPageSize when 0
+	OmitAudio bool   // Drop the audio of the items, keeping their transcripts
+
+	// Filters of the items on the page; the After cursor names any item
+	Role          string // Only items of the role, e.g. "user", when set
+	HasTranscript *bool  // Only items with, or without, a transcript when set
+}
+
+// matches reports whether an item passes the page's filters
+func (page ItemPage) matches(item *domain.Item) bool {
+	if page.Role != "" && item.Role != page.Role {
+		return false
+	}
+	if page.HasTranscript != nil && hasTranscript(item) != *page.HasTranscript {
+		return false
+	}
+	return true
+}
+
+// hasTranscript reports whether any content part of an item is transcribed
+func hasTranscript(item *domain.Item) bool {
+	for _, part := range item.Content {
+		if part.Transcript != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// NewConversationStoreWithConfig creates the configured conversation store:
+// files in a directory, or the most recent conversations in memory
+func NewConversationStoreWithConfig(cfg *config.ConversationsConfig) (domain.ConversationStore, error) {
+	if cfg.Dir != "" {
+		return convstore.NewDir(cfg.Dir)
+	}
+	return convstore.NewMemory(cfg.MaxEntries), nil
+}
+
+// SetConversationStore keeps the conversations of ended sessions in store
+func (u *SessionUsecase) SetConversationStore(store domain.ConversationStore) {
+	u.conversations = store
+}
+
+// saveConversation stores the conversation of an ending session
+func (u *SessionUsecase) saveConversation(state *domain.SessionState) {
+	if u.conversations == nil || state.Conversation.Len() == 0 {
+		return
+	}
+	items := state.Conversation.List()
+	conversation := &domain.StoredConversation{
+		ID:        state.Conversation.ID,
+		Object:    "realtime.conversation",
+		SessionID: state.ID,
+		CreatedAt: state.CreatedAt.Unix(),
+		EndedAt:   u.clock.Now().Unix(),
+		ItemCount: len(items),
+		Items:     items,
+		Metadata:  state.Config.Metadata,
+		Owner:     domain.OwnerHash(state.APIKey),
+	}
+	if state.Tenant != nil {
+		conversation.TenantID = state.Tenant.ID
+	}
+	if err := u.conversations.Save(conversation); err != nil {
+		log.Printf("[ERROR] Failed to store conversation %s of session %s: %v", conversation.ID, state.ID, err)
+	}
+}
+
+// Conversation returns a stored conversation by its ID or its session's.
+// Conversations are visible to the same credentials as their sessions were,
+// see Subscribe; others get domain.ErrConversationNotFound.
+func (u *SessionUsecase) Conversation(id string, opts SubscribeOptions) (*domain.StoredConversation, error) {
+	if u.conversations == nil {
+		return nil, domain.ErrConversationNotFound
+	}
+	conversation, err := u.conversations.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.owns(conversation) {
+		return nil, domain.ErrConversationNotFound
+	}
+	return conversation, nil
+}
+
+// ConversationItems returns a page of a stored conversation's items, in order
+func (u *SessionUsecase) ConversationItems(id string, opts SubscribeOptions, page ItemPage) (*domain.ItemList, error) {
+	conversation, err := u.Conversation(id, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	items := conversation.Items
+	if page.After != "" {
+		found := false
+		for i, item := range items {
+			if item.ID == page.After {
+				items, found = items[i+1:], true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrItemNotFound
+		}
+	}
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultItemPageSize
+	}
+	list := &domain.ItemList{Object: "list", Data: []domain.Item{}}
+	for _, item := range items {
+		if !page.matches(&item) {
+			continue
+		}
+		if len(list.Data) == limit {
+			list.HasMore = true
+			break
+		}
+		if page.OmitAudio {
+			item.Content = append([]domain.ContentPart(nil), item.Content...)
+			for i := range item.Content {
+				item.Content[i].Audio = ""
+			}
+		}
+		list.Data = append(list.Data, item)
+	}
+	if len(list.Data) > 0 {
+		list.FirstID, list.LastID = list.Data[0].ID, list.Data[len(list.Data)-1].ID
+	}
+	return list, nil
+}
+
+// owns reports whether the credentials may see a stored conversation
+func (opts SubscribeOptions) owns(conversation *domain.StoredConversation) bool {
+	return opts.ownsData(conversation.Owner, conversation.TenantID)
+}
+
+// ownsData reports whether the credentials may see the stored data of an
+// owner, a domain.OwnerHash, and tenant
+func (opts SubscribeOptions) ownsData(owner, tenantID string) bool {
+	if opts.AnySession {
+		return true
+	}
+	if tenantID != "" {
+		return opts.Tenant != nil && opts.Tenant.ID == tenantID
+	}
+	return domain.OwnerHash(opts.APIKey) == owner
+}
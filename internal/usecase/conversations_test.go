@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aira-id/gribe/internal/pkg/convstore"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestConversationItems(t *testing.T) {
+	u := NewSessionUsecase()
+	store := convstore.NewMemory(1)
+	u.SetConversationStore(store)
+
+	// Transcribed user turns, with an assistant reply and an untranscribed
+	// turn every third item
+	conversation := &domain.StoredConversation{ID: "conv_1", SessionID: "sess_1", Owner: domain.OwnerHash("key")}
+	for i := 0; i < 30; i++ {
+		item := domain.NewItem(fmt.Sprintf("item_%d", i), "message", "user")
+		part := domain.ContentPart{Type: "input_audio", Audio: "AAAA", Transcript: "hello"}
+		switch i % 3 {
+		case 1:
+			item.Role, part = "assistant", domain.ContentPart{Type: "text", Text: "hi"}
+		case 2:
+			part.Transcript = ""
+		}
+		item.Content = append(item.Content, part)
+		conversation.Items = append(conversation.Items, *item)
+	}
+	if err := store.Save(conversation); err != nil {
+		t.Fatal(err)
+	}
+	owner := SubscribeOptions{APIKey: "key"}
+
+	yes, no := true, false
+	for _, tc := range []struct {
+		name  string
+		page  ItemPage
+		first string
+		last  string
+		count int
+		more  bool
+	}{
+		{"first page", ItemPage{}, "item_0", "item_19", 20, true},
+		{"after a cursor", ItemPage{After: "item_19"}, "item_20", "item_29", 10, false},
+		{"by role", ItemPage{Role: "user", Limit: 5}, "item_0", "item_6", 5, true},
+		{"transcribed after a filtered-out item", ItemPage{After: "item_20", HasTranscript: &yes}, "item_21", "item_27", 3, false},
+		{"untranscribed user turns", ItemPage{Role: "user", HasTranscript: &no}, "item_2", "item_29", 10, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := u.ConversationItems("sess_1", owner, tc.page)
+			if err != nil {
+				t.Fatalf("ConversationItems failed: %v", err)
+			}
+			if len(list.Data) != tc.count || list.FirstID != tc.first || list.LastID != tc.last || list.HasMore != tc.more {
+				t.Errorf("Expected %d items %s..%s (more %v), got %d items %s..%s (more %v)",
+					tc.count, tc.first, tc.last, tc.more, len(list.Data), list.FirstID, list.LastID, list.HasMore)
+			}
+		})
+	}
+
+	if _, err := u.ConversationItems("conv_1", owner, ItemPage{After: "item_missing"}); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("Expected ErrItemNotFound for an unknown cursor, got %v", err)
+	}
+	if _, err := u.ConversationItems("conv_1", SubscribeOptions{APIKey: "other"}, ItemPage{}); !errors.Is(err, domain.ErrConversationNotFound) {
+		t.Errorf("Expected other credentials not to find the conversation, got %v", err)
+	}
+}
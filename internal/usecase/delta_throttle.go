@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// deltaThrottle coalesces transcription deltas so slow clients are not
+// flooded with one event per decode frame. Text is held back until at least
+// minChars characters are pending and interval has passed since the last
+// emission; nothing is dropped, Flush returns whatever is still held.
+type deltaThrottle struct {
+	interval time.Duration
+	minChars int
+	pending  string
+	lastSent time.Time
+	now      func() time.Time
+}
+
+// newDeltaThrottle creates a throttle; zero interval and minChars pass every delta through
+func newDeltaThrottle(interval time.Duration, minChars int) *deltaThrottle {
+	return &deltaThrottle{
+		interval: interval,
+		minChars: minChars,
+		now:      time.Now,
+	}
+}
+
+// Add buffers text and returns the delta to send now, or "" to hold it back
+func (t *deltaThrottle) Add(text string) string {
+	t.pending += text
+	if t.pending == "" || utf8.RuneCountInString(t.pending) < t.minChars {
+		return ""
+	}
+
+	now := t.now()
+	if t.interval > 0 && !t.lastSent.IsZero() && now.Sub(t.lastSent) < t.interval {
+		return ""
+	}
+
+	t.lastSent = now
+	delta := t.pending
+	t.pending = ""
+	return delta
+}
+
+// Flush returns the held-back text, to be sent before the completed transcript
+func (t *deltaThrottle) Flush() string {
+	delta := t.pending
+	t.pending = ""
+	return delta
+}
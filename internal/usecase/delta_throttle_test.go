@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeltaThrottle(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttle := newDeltaThrottle(100*time.Millisecond, 3)
+	throttle.now = func() time.Time { return now }
+
+	if delta := throttle.Add("he"); delta != "" {
+		t.Errorf("Expected delta below min chars to be held, got %q", delta)
+	}
+	if delta := throttle.Add("llo"); delta != "hello" {
+		t.Errorf("Expected first delta to be sent immediately, got %q", delta)
+	}
+
+	now = now.Add(50 * time.Millisecond)
+	if delta := throttle.Add(" wor"); delta != "" {
+		t.Errorf("Expected delta within interval to be held, got %q", delta)
+	}
+
+	now = now.Add(50 * time.Millisecond)
+	if delta := throttle.Add("ld"); delta != " world" {
+		t.Errorf("Expected coalesced delta after interval, got %q", delta)
+	}
+
+	throttle.Add("!")
+	if delta := throttle.Flush(); delta != "!" {
+		t.Errorf("Expected flush to return held text, got %q", delta)
+	}
+	if delta := throttle.Flush(); delta != "" {
+		t.Errorf("Expected empty flush, got %q", delta)
+	}
+
+	// Zero limits pass every delta through
+	passthrough := newDeltaThrottle(0, 0)
+	if delta := passthrough.Add("a"); delta != "a" {
+		t.Errorf("Expected passthrough delta, got %q", delta)
+	}
+	if delta := passthrough.Add("b"); delta != "b" {
+		t.Errorf("Expected passthrough delta, got %q", delta)
+	}
+}
@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/aira-id/gribe/internal/pkg/goroutines"
+	"github.com/aira-id/gribe/internal/pkg/panics"
+)
+
+// Diagnostics reports the goroutines and per-session resources the server
+// holds, to tell leaks from load. Resources of sessions that are no longer
+// live are leaks, once they show in consecutive snapshots: a session starting
+// or ending while the snapshot is taken may show for one.
+type Diagnostics struct {
+	Goroutines            int                    `json:"goroutines"`
+	GoroutinesBySubsystem map[string]int         `json:"goroutines_by_subsystem"` // By the package that started them
+	Sessions              int                    `json:"sessions"`                // Live sessions
+	VADWorkers            int                    `json:"vad_workers"`             // Sessions' VAD providers and their goroutines
+	Transcriptions        int64                  `json:"transcriptions"`          // In flight, including queued ones
+	TranscriptionsQueued  int                    `json:"transcriptions_queued"`   // Waiting for a transcription slot
+	AudioBuffers          AudioBufferDiagnostics `json:"audio_buffers"`
+	PanicsRecovered       int64                  `json:"panics_recovered"` // Since startup
+	// Orphans lists the sessions no longer live whose state is left in a
+	// registry, by registry, e.g. "vad_workers"; empty without leaks
+	Orphans map[string][]string `json:"orphans"`
+}
+
+// AudioBufferDiagnostics reports the audio held for live sessions
+type AudioBufferDiagnostics struct {
+	NonEmpty          int `json:"non_empty"`          // Input buffers holding audio
+	Spilled           int `json:"spilled"`            // Input buffers moved to disk
+	BufferedBytes     int `json:"buffered_bytes"`     // Audio in input buffers
+	MemoryBytes       int `json:"memory_bytes"`       // Held in memory by input buffers, including spare capacity
+	ConversationBytes int `json:"conversation_bytes"` // Audio kept with conversation items
+	PendingChunks     int `json:"pending_chunks"`     // Chunks of long utterances not yet transcribing
+}
+
+// Diagnostics takes a snapshot of the server's goroutines and resources
+func (u *SessionUsecase) Diagnostics() Diagnostics {
+	d := Diagnostics{
+		Goroutines:            runtime.NumGoroutine(),
+		GoroutinesBySubsystem: goroutines.BySubsystem(),
+		Transcriptions:        u.transcribing.Load(),
+		PanicsRecovered:       panics.Count(),
+		Orphans:               make(map[string][]string),
+	}
+
+	live := make(map[string]bool)
+	for _, state := range u.sessionManager.Sessions() {
+		live[state.ID] = true
+		d.Sessions++
+		buffers := &d.AudioBuffers
+		if size := state.AudioBuffer.GetSize(); size > 0 {
+			buffers.NonEmpty++
+			buffers.BufferedBytes += size
+		}
+		if state.AudioBuffer.IsSpilled() {
+			buffers.Spilled++
+		}
+		buffers.MemoryBytes += state.AudioBuffer.MemorySize()
+		buffers.ConversationBytes += state.Conversation.AudioBytes()
+	}
+
+	u.vadMu.RLock()
+	d.VADWorkers = len(u.vadWorkers)
+	for sessionID := range u.vadWorkers {
+		if !live[sessionID] {
+			d.Orphans["vad_workers"] = append(d.Orphans["vad_workers"], sessionID)
+		}
+	}
+	u.vadMu.RUnlock()
+
+	d.TranscriptionsQueued = syncMapLen(&u.queuedItems)
+	d.AudioBuffers.PendingChunks = syncMapLen(&u.utteranceChunks)
+	for registry, sessions := range map[string]*sync.Map{
+		"stats":           &u.stats,
+		"diarizers":       &u.diarizers,
+		"echo_cancellers": &u.echoCancellers,
+		"utterances":      &u.utterances,
+	} {
+		sessions.Range(func(key, _ interface{}) bool {
+			if sessionID := key.(string); !live[sessionID] {
+				d.Orphans[registry] = append(d.Orphans[registry], sessionID)
+			}
+			return true
+		})
+	}
+	for _, sessionIDs := range d.Orphans {
+		sort.Strings(sessionIDs)
+	}
+	return d
+}
+
+func syncMapLen(m *sync.Map) int {
+	n := 0
+	m.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
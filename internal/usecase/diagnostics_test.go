@@ -0,0 +1,27 @@
+package usecase
+
+import "testing"
+
+func TestDiagnostics(t *testing.T) {
+	uc := NewSessionUsecase()
+	state := uc.sessionManager.CreateTranscriptionSession("sess_live", "model", "conv_live", "en")
+	if err := state.AudioBuffer.Append(make([]byte, 3200)); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	uc.stats.Store("sess_live", &sessionStats{})
+	// State of a session that ended without cleaning up
+	uc.stats.Store("sess_gone", &sessionStats{})
+	uc.diarizers.Store("sess_gone", nil)
+
+	d := uc.Diagnostics()
+	if d.Sessions != 1 || d.AudioBuffers.NonEmpty != 1 || d.AudioBuffers.BufferedBytes != 3200 {
+		t.Errorf("Expected 1 session buffering 3200 bytes, got %+v", d)
+	}
+	if d.Goroutines == 0 || len(d.GoroutinesBySubsystem) == 0 {
+		t.Errorf("Expected goroutine counts, got %d and %v", d.Goroutines, d.GoroutinesBySubsystem)
+	}
+	if len(d.Orphans) != 2 || len(d.Orphans["stats"]) != 1 || d.Orphans["stats"][0] != "sess_gone" ||
+		len(d.Orphans["diarizers"]) != 1 {
+		t.Errorf("Expected sess_gone orphaned in stats and diarizers, got %v", d.Orphans)
+	}
+}
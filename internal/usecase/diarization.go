@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// diarizationWindowMs is the audio each voiceprint of a diarized turn is
+// computed from: long enough to tell voices apart, short enough to catch a
+// quick exchange within one turn
+const diarizationWindowMs = 1500
+
+// speakerSegment is a span of a turn's samples spoken by one speaker
+type speakerSegment struct {
+	start, end int // Samples
+	speaker    *domain.Speaker
+}
+
+// diarizer labels the voices of one session: enrolled speakers with their
+// profile, others as anonymous speakers numbered in the order they are heard
+type diarizer struct {
+	speakers *SpeakerIdentifier
+
+	mu     sync.Mutex
+	voices []*anonymousVoice
+}
+
+// anonymousVoice is a voice heard in the session that matches no profile
+type anonymousVoice struct {
+	speaker    domain.Speaker
+	voiceprint []float32 // Mean of the normalized voiceprints it was heard with
+	n          int
+}
+
+// segment splits a turn into spans of consecutive windows with the same
+// speaker. Turns too short for a voiceprint are not split and get no speaker.
+func (d *diarizer) segment(samples []float32, sampleRate int) ([]speakerSegment, error) {
+	if len(samples)*1000 < minSpeakerAudioMs*sampleRate {
+		return []speakerSegment{{start: 0, end: len(samples)}}, nil
+	}
+
+	window := diarizationWindowMs * sampleRate / 1000
+	var segments []speakerSegment
+	for start := 0; start < len(samples); {
+		end := start + window
+		if len(samples)-end < window/2 {
+			// A short tail joins the last window
+			end = len(samples)
+		}
+		voiceprint, err := d.speakers.embedder.Embed(samples[start:end], sampleRate)
+		if err != nil {
+			return nil, err
+		}
+		speaker := d.label(voiceprint)
+		if n := len(segments); n > 0 && segments[n-1].speaker.ID == speaker.ID {
+			segments[n-1].end = end
+		} else {
+			segments = append(segments, speakerSegment{start: start, end: end, speaker: speaker})
+		}
+		start = end
+	}
+	return segments, nil
+}
+
+// label returns the speaker of a voiceprint: the enrolled speaker it
+// matches, else the closest anonymous voice of the session, else a new one
+func (d *diarizer) label(voiceprint []float32) *domain.Speaker {
+	if speaker := d.speakers.match(voiceprint); speaker != nil {
+		return speaker
+	}
+	normalize(voiceprint)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var best *anonymousVoice
+	var bestSimilarity float64
+	for _, voice := range d.voices {
+		if similarity := cosineSimilarity(voiceprint, voice.voiceprint); similarity >= d.speakers.threshold && similarity > bestSimilarity {
+			best, bestSimilarity = voice, similarity
+		}
+	}
+	if best == nil {
+		n := len(d.voices) + 1
+		best = &anonymousVoice{
+			speaker:    domain.Speaker{ID: fmt.Sprintf("speaker_%d", n), Name: fmt.Sprintf("Speaker %d", n)},
+			voiceprint: voiceprint,
+		}
+		d.voices = append(d.voices, best)
+		bestSimilarity = 1
+	} else {
+		addVoiceprint(best.voiceprint, best.n, voiceprint)
+	}
+	best.n++
+	speaker := best.speaker
+	speaker.Similarity = bestSimilarity
+	return &speaker
+}
+
+// diarizedTurn is the audio of one speaker in a committed turn
+type diarizedTurn struct {
+	audio   []byte
+	speaker *domain.Speaker
+}
+
+// diarize splits a committed turn of a diarized session by speaker. A turn
+// of one speaker keeps its audio; otherwise each part gets a copy and the
+// turn's audio is recycled.
+func (u *SessionUsecase) diarize(state *domain.SessionState, audio []byte) []diarizedTurn {
+	var format *domain.AudioFormat
+	if state.Config.Audio != nil && state.Config.Audio.Input != nil {
+		format = state.Config.Audio.Input.Format
+	}
+	value, _ := u.diarizers.LoadOrStore(state.ID, &diarizer{speakers: u.speakers})
+	samples := inputSamples(audio, format)
+	segments, err := value.(*diarizer).segment(samples, format.SampleRate())
+	bufpool.PutFloat32s(samples)
+	if err != nil {
+		log.Printf("[WARN] Session %s: diarization failed, keeping the turn whole: %v", state.ID, err)
+		return []diarizedTurn{{audio: audio}}
+	}
+	if len(segments) == 1 {
+		return []diarizedTurn{{audio: audio, speaker: segments[0].speaker}}
+	}
+
+	bytesPerSample := format.BytesPerSample()
+	turns := make([]diarizedTurn, len(segments))
+	for i, segment := range segments {
+		part := audio[segment.start*bytesPerSample : segment.end*bytesPerSample]
+		turns[i] = diarizedTurn{audio: append(bufpool.Bytes(len(part))[:0], part...), speaker: segment.speaker}
+	}
+	bufpool.PutBytes(audio)
+	return turns
+}
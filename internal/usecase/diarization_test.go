@@ -0,0 +1,57 @@
+package usecase
+
+import "testing"
+
+// conversation returns 16kHz audio of voices taking turns, one diarization
+// window each
+func conversation(voices ...float32) []float32 {
+	window := diarizationWindowMs * 16
+	samples := make([]float32, window*len(voices))
+	for i, voice := range voices {
+		samples[i*window] = voice
+	}
+	return samples
+}
+
+func TestDiarizer(t *testing.T) {
+	speakers, err := NewSpeakerIdentifier(voiceEmbedder{}, 0.8, "")
+	if err != nil {
+		t.Fatalf("Failed to create identifier: %v", err)
+	}
+	alice, err := speakers.Enroll("", "Alice", recording(0.1), 16000)
+	if err != nil {
+		t.Fatalf("Failed to enroll Alice: %v", err)
+	}
+	d := &diarizer{speakers: speakers}
+
+	// Alice twice, then a voice nobody enrolled
+	segments, err := d.segment(conversation(0.1, 0.2, 0.3), 16000)
+	if err != nil {
+		t.Fatalf("Failed to diarize: %v", err)
+	}
+	window := diarizationWindowMs * 16
+	if len(segments) != 2 {
+		t.Fatalf("Expected 2 speaker turns, got %+v", segments)
+	}
+	if segments[0].speaker.ID != alice.ID || segments[0].start != 0 || segments[0].end != 2*window {
+		t.Errorf("Expected Alice's turn first, got %+v %+v", segments[0], segments[0].speaker)
+	}
+	if segments[1].speaker.ID != "speaker_1" || segments[1].start != 2*window || segments[1].end != 3*window {
+		t.Errorf("Expected an anonymous speaker's turn second, got %+v %+v", segments[1], segments[1].speaker)
+	}
+
+	// The anonymous speaker keeps its label across turns, a new voice gets the next
+	segments, err = d.segment(conversation(0.4, 0.3), 16000)
+	if err != nil {
+		t.Fatalf("Failed to diarize: %v", err)
+	}
+	if len(segments) != 2 || segments[0].speaker.ID != "speaker_2" || segments[1].speaker.ID != "speaker_1" {
+		t.Errorf("Expected speaker_2 then speaker_1, got %+v", segments)
+	}
+
+	// Too short a turn is kept whole, without a speaker
+	segments, err = d.segment(recording(0.1)[:4000], 16000)
+	if err != nil || len(segments) != 1 || segments[0].speaker != nil || segments[0].end != 4000 {
+		t.Errorf("Expected a short turn kept whole, got %+v %v", segments, err)
+	}
+}
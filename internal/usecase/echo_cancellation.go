@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"encoding/base64"
+
+	"github.com/aira-id/gribe/internal/pkg/aec"
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// echoCanceller returns the session's echo canceller, nil unless its pcm16
+// input has echo cancellation. A changed tail or sample rate starts over
+// with a new canceller.
+func (u *SessionUsecase) echoCanceller(state *domain.SessionState) *aec.Canceller {
+	var ec *domain.EchoCancellation
+	var format *domain.AudioFormat
+	if state.Config.Audio != nil && state.Config.Audio.Input != nil {
+		ec = state.Config.Audio.Input.EchoCancellation
+		format = state.Config.Audio.Input.Format
+	}
+	if ec == nil || format.Encoding() != domain.EncodingPCM16 {
+		u.echoCancellers.Delete(state.ID)
+		return nil
+	}
+	if value, ok := u.echoCancellers.Load(state.ID); ok {
+		if canceller := value.(*aec.Canceller); canceller.TailMs() == ec.Tail() && canceller.SampleRate() == format.SampleRate() {
+			return canceller
+		}
+	}
+	canceller := aec.New(format.SampleRate(), ec.Tail())
+	u.echoCancellers.Store(state.ID, canceller)
+	return canceller
+}
+
+// appendInputAudio decodes base64 audio into the session's buffer, through
+// its echo canceller if it has one, and returns the decoded chunk, which
+// must be copied to be kept
+func (u *SessionUsecase) appendInputAudio(state *domain.SessionState, src []byte) ([]byte, error) {
+	canceller := u.echoCanceller(state)
+	if canceller == nil {
+		return state.AudioBuffer.AppendBase64(src)
+	}
+	audio := make([]byte, base64.StdEncoding.DecodedLen(len(src)))
+	n, err := base64.StdEncoding.Decode(audio, src)
+	if err != nil {
+		return nil, err
+	}
+	audio = audio[:n]
+	canceller.Process(audio)
+	if err := state.AudioBuffer.Append(audio); err != nil {
+		return nil, err
+	}
+	return audio, nil
+}
+
+// handleOutputAudioBufferAppend queues audio the client played as the
+// reference of the session's echo canceller
+func (u *SessionUsecase) handleOutputAudioBufferAppend(conn Conn, state *domain.SessionState, message []byte) {
+	var event appendEvent
+	if !u.decodeClientEvent(conn, message, &event) {
+		return
+	}
+	defer bufpool.PutBytes(event.Audio)
+
+	canceller := u.echoCanceller(state)
+	if canceller == nil {
+		u.sendError(conn, event.EventID, "invalid_request_error", domain.CodeEchoCancellationOff,
+			"Reference audio needs audio.input.echo_cancellation on pcm16 input", nil)
+		return
+	}
+	audio := bufpool.Bytes(base64.StdEncoding.DecodedLen(len(event.Audio)))
+	defer bufpool.PutBytes(audio)
+	n, err := base64.StdEncoding.Decode(audio, event.Audio)
+	if err != nil {
+		u.sendError(conn, event.EventID, "invalid_request_error", domain.CodeInvalidAudio, "Invalid base64 audio data", "audio")
+		return
+	}
+	canceller.Reference(audio[:n])
+}
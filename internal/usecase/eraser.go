@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/auditlog"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Eraser deletes stored data and keeps an audit record of every deletion,
+// in the audit file when one is configured and in the log otherwise
+type Eraser struct {
+	audit      *auditlog.Log // nil logs deletions only
+	recordDirs []string      // Directories session recordings are written to
+	now        func() time.Time
+}
+
+// NewEraserWithConfig creates an eraser auditing deletions to
+// retention.audit_file, or only to the log when it is empty, that finds
+// recordings in record.dir and tenants' record_dir
+func NewEraserWithConfig(cfg *config.Config) (*Eraser, error) {
+	e := newEraser()
+	dirs := []string{cfg.Record.Dir}
+	for _, tenant := range cfg.Tenants {
+		dirs = append(dirs, tenant.RecordDir)
+	}
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		if !seen[dir] {
+			seen[dir] = true
+			e.recordDirs = append(e.recordDirs, dir)
+		}
+	}
+	if cfg.Retention.AuditFile != "" {
+		audit, err := auditlog.Open(cfg.Retention.AuditFile)
+		if err != nil {
+			return nil, err
+		}
+		e.audit = audit
+	}
+	return e, nil
+}
+
+// newEraser creates an eraser that logs deletions and knows no recordings
+func newEraser() *Eraser {
+	return &Eraser{now: time.Now}
+}
+
+// erase runs remove unless the record is a dry run, then audits the
+// deletion. Deletions that fail are not audited.
+func (e *Eraser) erase(record *domain.DeletionRecord, remove func() error) error {
+	if !record.DryRun {
+		if err := remove(); err != nil {
+			return err
+		}
+	}
+	record.Time = e.now().Unix()
+
+	if e.audit != nil {
+		if err := e.audit.Write(record); err != nil {
+			log.Printf("[ERROR] Failed to audit deletion of %s %s: %v", record.Kind, record.ID, err)
+		}
+		return nil
+	}
+	line, _ := json.Marshal(record)
+	log.Printf("Deletion: %s", line)
+	return nil
+}
+
+// Close closes the audit file
+func (e *Eraser) Close() error {
+	if e.audit == nil {
+		return nil
+	}
+	return e.audit.Close()
+}
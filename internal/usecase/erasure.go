@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	recordings "github.com/aira-id/gribe/internal/pkg/recording"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// ErrSessionActive is returned for erasing the data of a session that is
+// still live, or may still be resumed, and would store more when it ends
+var ErrSessionActive = errors.New("session is still active")
+
+// Erasure selects the stored data to erase. Sessions match when they pass
+// every filter that is set.
+type Erasure struct {
+	SessionID string    // Data of the session, by its ID or its conversation's
+	APIKey    string    // Data of sessions opened with the credential
+	Since     time.Time // Data of sessions started at or after Since
+	Until     time.Time // Data of sessions started before Until
+}
+
+// matches reports whether a session's data is selected
+func (e Erasure) matches(sessionID, conversationID, owner string, started time.Time) bool {
+	if e.SessionID != "" && e.SessionID != sessionID && e.SessionID != conversationID {
+		return false
+	}
+	if e.APIKey != "" && domain.OwnerHash(e.APIKey) != owner {
+		return false
+	}
+	if !e.Since.IsZero() && started.Before(e.Since) {
+		return false
+	}
+	return e.Until.IsZero() || started.Before(e.Until)
+}
+
+// SetEraser deletes stored data through eraser, which audits the deletions
+func (u *SessionUsecase) SetEraser(eraser *Eraser) {
+	u.eraser = eraser
+}
+
+// Erase deletes the stored conversations and session recordings selected by
+// erasure that the credentials may see, see Subscribe, and returns a receipt
+// listing them. Recordings naming their owner are erased as conversations
+// are. Older recordings are erased along with the conversation of their
+// session, and only admins (opts.AnySession) erase those of sessions whose
+// conversation is not stored, by session ID or time range. Data that failed to be deleted is left out of the receipt and
+// reported in the error.
+func (u *SessionUsecase) Erase(erasure Erasure, opts SubscribeOptions) (*domain.DeletionReceipt, error) {
+	if erasure.SessionID != "" && u.sessionActive(erasure.SessionID, opts) {
+		return nil, ErrSessionActive
+	}
+	eraser := u.eraser
+	if eraser == nil {
+		eraser = newEraser()
+	}
+
+	receipt := &domain.DeletionReceipt{
+		ID:        u.idGen.GenerateDeletionID(),
+		Object:    "deletion.receipt",
+		CreatedAt: eraser.now().Unix(),
+		SessionID: erasure.SessionID,
+		Deleted:   []domain.DeletionRecord{},
+	}
+	if erasure.APIKey != "" {
+		receipt.Owner = domain.OwnerHash(erasure.APIKey)
+	}
+	if !erasure.Since.IsZero() {
+		receipt.Since = erasure.Since.Unix()
+	}
+	if !erasure.Until.IsZero() {
+		receipt.Until = erasure.Until.Unix()
+	}
+
+	var errs []error
+	erase := func(record domain.DeletionRecord, remove func() error) {
+		record.Reason, record.Receipt = domain.DeletionReasonErasure, receipt.ID
+		if err := eraser.erase(&record, remove); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", record.Kind, record.ID, err))
+			return
+		}
+		receipt.Deleted = append(receipt.Deleted, record)
+	}
+
+	// Sessions whose conversations are erased, keyed by session ID
+	sessions := make(map[string]*domain.StoredConversation)
+	if u.conversations != nil {
+		conversations, err := u.conversations.List()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("listing conversations: %w", err))
+		}
+		for _, conversation := range conversations {
+			if !opts.owns(conversation) ||
+				!erasure.matches(conversation.SessionID, conversation.ID, conversation.Owner, time.Unix(conversation.CreatedAt, 0)) {
+				continue
+			}
+			sessions[conversation.SessionID] = conversation
+			id := conversation.ID
+			erase(domain.DeletionRecord{
+				Kind:      domain.StoredConversationKind,
+				ID:        id,
+				SessionID: conversation.SessionID,
+				Owner:     conversation.Owner,
+				TenantID:  conversation.TenantID,
+				CreatedAt: conversation.CreatedAt,
+			}, func() error { return u.conversations.Delete(id) })
+		}
+	}
+
+	for _, dir := range eraser.recordDirs {
+		files, err := recordings.Files(dir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("listing recordings in %s: %w", dir, err))
+			continue
+		}
+		for _, file := range files {
+			recorded, err := recordings.ReadSession(file.Path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("reading recording %s: %w", file.Path, err))
+				continue
+			}
+			record := domain.DeletionRecord{
+				Kind:      domain.StoredRecordingKind,
+				ID:        filepath.Base(file.Path),
+				SessionID: recorded.ID,
+				Owner:     recorded.Owner,
+				TenantID:  recorded.TenantID,
+				CreatedAt: recorded.Started.Unix(),
+			}
+			if conversation, ok := sessions[recorded.ID]; ok && recorded.ID != "" {
+				record.Owner, record.TenantID = conversation.Owner, conversation.TenantID
+			} else if recorded.Owner != "" {
+				if !opts.ownsData(recorded.Owner, recorded.TenantID) ||
+					!erasure.matches(recorded.ID, "", recorded.Owner, recorded.Started) {
+					continue
+				}
+			} else if !opts.AnySession || erasure.APIKey != "" || !erasure.matches(recorded.ID, "", "", recorded.Started) {
+				continue
+			}
+			path := file.Path
+			erase(record, func() error { return os.Remove(path) })
+		}
+	}
+
+	if erasure.SessionID != "" && len(receipt.Deleted) == 0 && len(errs) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return receipt, errors.Join(errs...)
+}
+
+// sessionActive reports whether a session the credentials may see is live,
+// or may still be resumed, by its ID or its conversation's
+func (u *SessionUsecase) sessionActive(id string, opts SubscribeOptions) bool {
+	for _, state := range u.sessionManager.Sessions() {
+		if state.ID != id && (state.Conversation == nil || state.Conversation.ID != id) {
+			continue
+		}
+		return opts.allow(state.APIKey, state.Tenant)
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/convstore"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestErase(t *testing.T) {
+	start := time.Unix(1735689600, 0)
+	cfg := &config.Config{Record: config.RecordConfig{Dir: t.TempDir()}}
+	eraser, err := NewEraserWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := NewSessionUsecase()
+	u.SetEraser(eraser)
+	store := convstore.NewMemory(10)
+	u.SetConversationStore(store)
+
+	// Sessions of two keys an hour apart, each recorded, and a recording of
+	// a session whose conversation is not stored
+	for i, key := range []string{"key-a", "key-a", "key-b"} {
+		started := start.Add(time.Duration(i) * time.Hour)
+		sessionID := fmt.Sprintf("sess_%d", i)
+		if err := store.Save(&domain.StoredConversation{
+			ID: fmt.Sprintf("conv_%d", i), SessionID: sessionID, CreatedAt: started.Unix(), Owner: domain.OwnerHash(key),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		writeSessionRecording(t, cfg.Record.Dir, sessionID, started, "")
+	}
+	writeSessionRecording(t, cfg.Record.Dir, "sess_unstored", start, "")
+	writeSessionRecording(t, cfg.Record.Dir, "sess_headed", start, domain.OwnerHash("key-b"))
+
+	owner := func(key string) SubscribeOptions { return SubscribeOptions{APIKey: key} }
+
+	if _, err := u.Erase(Erasure{SessionID: "sess_0"}, owner("key-b")); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected another key's session to be not found, got %v", err)
+	}
+	if _, err := u.Erase(Erasure{SessionID: "sess_unstored"}, owner("key-a")); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected an unowned recording to be left to admins, got %v", err)
+	}
+
+	receipt, err := u.Erase(Erasure{SessionID: "conv_0"}, owner("key-a"))
+	if err != nil {
+		t.Fatalf("Erase failed: %v", err)
+	}
+	if receipt.Object != "deletion.receipt" || receipt.ID == "" || len(receipt.Deleted) != 2 {
+		t.Fatalf("Expected a receipt of a conversation and a recording, got %+v", receipt)
+	}
+	for _, record := range receipt.Deleted {
+		if record.SessionID != "sess_0" || record.Owner != domain.OwnerHash("key-a") ||
+			record.Reason != domain.DeletionReasonErasure || record.Receipt != receipt.ID {
+			t.Errorf("Unexpected deletion %+v", record)
+		}
+	}
+	if _, err := store.Get("sess_0"); !errors.Is(err, domain.ErrConversationNotFound) {
+		t.Errorf("Expected the conversation to be erased, got %v", err)
+	}
+	if _, err := u.Erase(Erasure{SessionID: "sess_0"}, owner("key-a")); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected nothing left to erase, got %v", err)
+	}
+
+	// By key and time range: key-a's sessions from the first hour on
+	receipt, err = u.Erase(Erasure{APIKey: "key-a", Since: start.Add(time.Hour)}, owner("key-a"))
+	if err != nil {
+		t.Fatalf("Erase failed: %v", err)
+	}
+	if len(receipt.Deleted) != 2 || receipt.Deleted[0].SessionID != "sess_1" || receipt.Since != start.Add(time.Hour).Unix() {
+		t.Errorf("Expected sess_1's data to be erased, got %+v", receipt)
+	}
+	if _, err := store.Get("sess_2"); err != nil {
+		t.Errorf("Expected key-b's conversation to be kept, got %v", err)
+	}
+
+	// A recording naming its owner is the owner's to erase
+	if _, err := u.Erase(Erasure{SessionID: "sess_headed"}, owner("key-a")); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected another key's recording to be not found, got %v", err)
+	}
+	receipt, err = u.Erase(Erasure{SessionID: "sess_headed"}, owner("key-b"))
+	if err != nil || len(receipt.Deleted) != 1 || receipt.Deleted[0].Owner != domain.OwnerHash("key-b") {
+		t.Errorf("Expected key-b's recording to be erased, got %+v (%v)", receipt, err)
+	}
+
+	// Admins erase recordings without a stored conversation by time range
+	receipt, err = u.Erase(Erasure{Until: start.Add(time.Minute)}, SubscribeOptions{AnySession: true})
+	if err != nil {
+		t.Fatalf("Erase failed: %v", err)
+	}
+	if len(receipt.Deleted) != 1 || receipt.Deleted[0].SessionID != "sess_unstored" {
+		t.Errorf("Expected the unstored session's recording to be erased, got %+v", receipt.Deleted)
+	}
+	files, _ := filepath.Glob(filepath.Join(cfg.Record.Dir, "*.jsonl"))
+	if len(files) != 1 {
+		t.Errorf("Expected only key-b's recording to be left, got %v", files)
+	}
+}
+
+func TestEraseActiveSession(t *testing.T) {
+	u := NewSessionUsecase()
+	u.SetConversationStore(convstore.NewMemory(10))
+	state := u.sessionManager.CreateTranscriptionSession("sess_live", "", "conv_live", "")
+	state.APIKey = "key"
+
+	if _, err := u.Erase(Erasure{SessionID: "sess_live"}, SubscribeOptions{APIKey: "key"}); !errors.Is(err, ErrSessionActive) {
+		t.Errorf("Expected the live session to be refused, got %v", err)
+	}
+	if _, err := u.Erase(Erasure{SessionID: "sess_live"}, SubscribeOptions{APIKey: "other"}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected other credentials not to learn of the session, got %v", err)
+	}
+}
+
+// writeSessionRecording writes a recording of a session started at started,
+// with a header naming owner unless it is empty
+func writeSessionRecording(t *testing.T, dir, sessionID string, started time.Time, owner string) {
+	t.Helper()
+	var header string
+	if owner != "" {
+		header = fmt.Sprintf(`{"time":%q,"direction":"header","event":{"owner":%q}}`+"\n", started.Format(time.RFC3339Nano), owner)
+	}
+	line := header + fmt.Sprintf(`{"time":%q,"direction":"server","event":{"type":"transcription_session.created","session":{"id":%q}}}`+"\n",
+		started.Format(time.RFC3339Nano), sessionID)
+	if err := os.WriteFile(filepath.Join(dir, "session-"+sessionID+".jsonl"), []byte(line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
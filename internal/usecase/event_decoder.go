@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// decodeClientEvent strictly decodes message into event and runs the event's
+// own field validation. On failure an invalid_request_error with the offending
+// field in param is sent to the client and false is returned.
+func (u *SessionUsecase) decodeClientEvent(conn Conn, message []byte, event interface{}) bool {
+	verr := decodeStrict(message, event)
+	if verr == nil {
+		if validator, ok := event.(domain.Validator); ok {
+			verr = validator.Validate()
+		}
+	}
+	if verr == nil {
+		return true
+	}
+
+	// Echo back the client event_id even if the rest of the event was rejected
+	var base domain.BaseEvent
+	json.Unmarshal(message, &base)
+
+	var param interface{}
+	if verr.Param != "" {
+		param = verr.Param
+	}
+	u.sendError(conn, base.EventID, "invalid_request_error", verr.Code, verr.Message, param)
+	return false
+}
+
+// decodeStrict unmarshals data rejecting unknown fields and trailing data,
+// translating encoding/json errors into field-level validation errors
+func decodeStrict(data []byte, v interface{}) *domain.ValidationError {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after top-level value")
+	}
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &domain.ValidationError{Code: domain.CodeInvalidJSON, Message: "Failed to parse event: " + syntaxErr.Error()}
+
+	case errors.As(err, &typeErr):
+		return &domain.ValidationError{
+			Code:    domain.CodeInvalidType,
+			Param:   typeErr.Field,
+			Message: fmt.Sprintf("expected %s, got %s", jsonTypeName(typeErr.Type.Kind().String()), typeErr.Value),
+		}
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &domain.ValidationError{
+			Code:    domain.CodeUnknownField,
+			Param:   field,
+			Message: fmt.Sprintf("unknown field '%s'", field),
+		}
+	}
+
+	return &domain.ValidationError{Code: domain.CodeInvalidEvent, Message: "Failed to parse event: " + err.Error()}
+}
+
+// appendEvent is input_audio_buffer.append with the audio kept as the raw
+// base64 text, so it is decoded straight into the session's buffer rather
+// than through an intermediate string and decoded slice
+type appendEvent struct {
+	domain.BaseEvent
+	Audio base64Text `json:"audio"`
+}
+
+// Validate checks input_audio_buffer.append fields
+func (e *appendEvent) Validate() *domain.ValidationError {
+	if len(e.Audio) == 0 {
+		return &domain.ValidationError{Code: domain.CodeMissingField, Param: "audio", Message: "audio is required"}
+	}
+	return nil
+}
+
+// base64Text holds the contents of the audio JSON string in a pooled slice.
+// Recycle it with bufpool.PutBytes once decoded.
+type base64Text []byte
+
+func (t *base64Text) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if data[0] != '"' {
+		// encoding/json does not add the field to errors from Unmarshalers
+		return &json.UnmarshalTypeError{Value: jsonValueName(data[0]), Type: reflect.TypeOf(""), Field: "audio"}
+	}
+
+	text := data[1 : len(data)-1]
+	if bytes.IndexByte(text, '\\') >= 0 {
+		// Some encoders escape '/' as \/, so unquote those the slow way
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		text = []byte(s)
+	}
+	*t = append(bufpool.Bytes(len(text))[:0], text...)
+	return nil
+}
+
+// jsonValueName names the JSON type of a value from its first byte
+func jsonValueName(first byte) string {
+	switch first {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "bool"
+	}
+	return "number"
+}
+
+// jsonTypeName maps Go kinds to JSON type names for error messages
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64":
+		return "number"
+	case "bool":
+		return "boolean"
+	case "slice", "array":
+		return "array"
+	case "struct", "map", "ptr":
+		return "object"
+	}
+	return kind
+}
@@ -0,0 +1,45 @@
+package usecase
+
+import "github.com/aira-id/gribe/pkg/domain"
+
+// Health statuses of the server and its models
+const (
+	HealthOK        = "ok"
+	HealthDegraded  = "degraded"   // Up, but some model is failing, unloaded or mock
+	HealthNotLoaded = "not_loaded" // Model not used yet; loaded on first use
+)
+
+// Health is the detailed health of the server, for /health?verbose=1
+type Health struct {
+	Status string                 `json:"status"` // HealthOK or HealthDegraded
+	Models map[string]ModelHealth `json:"models"` // By model name
+}
+
+// ModelHealth is the health of a configured model
+type ModelHealth struct {
+	Provider string `json:"provider"` // e.g. "sherpa-onnx"
+	Status   string `json:"status"`
+	domain.ProviderHealth
+	SelfTest *SelfTestResult `json:"self_test,omitempty"` // Startup self-test, if the model took one
+}
+
+// SelfTestResult is the outcome of a model's startup self-test, see
+// config.SelfTestConfig. A failed one degrades the model until it is
+// reloaded.
+type SelfTestResult struct {
+	Passed     bool   `json:"passed"`
+	Error      string `json:"error,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+	DurationMs int    `json:"duration_ms"`
+}
+
+// Health reports the health of the models, degraded when any is
+func (u *SessionUsecase) Health() Health {
+	health := Health{Status: HealthOK, Models: u.asrRegistry.Health()}
+	for _, model := range health.Models {
+		if model.Status == HealthDegraded {
+			health.Status = HealthDegraded
+		}
+	}
+	return health
+}
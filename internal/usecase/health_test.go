@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// flakyProvider is a real (not mock) provider whose transcriptions fail
+// mid-stream while err is set, and wait for gate when it is not nil
+type flakyProvider struct {
+	MockASRProvider
+	err  error
+	gate chan struct{}
+}
+
+func (p *flakyProvider) Transcribe(ctx context.Context, audio []byte, config *domain.TranscriptionConfig) (<-chan domain.TranscriptionChunk, error) {
+	results := make(chan domain.TranscriptionChunk, 1)
+	err, gate := p.err, p.gate
+	go func() {
+		defer close(results)
+		if gate != nil {
+			<-gate
+		}
+		results <- domain.TranscriptionChunk{Text: "ok", IsFinal: true, Err: err}
+	}()
+	return results, nil
+}
+
+func (p *flakyProvider) HealthCheck() domain.ProviderHealth {
+	return domain.ProviderHealth{ModelLoaded: true}
+}
+
+func TestHealth(t *testing.T) {
+	uc := NewSessionUsecaseWithConfig(&config.Config{ASR: config.ASRConfig{Models: map[string]config.ModelConfig{
+		"real":   {Provider: "flaky", Languages: []string{"en"}},
+		"broken": {Provider: "unloadable", Languages: []string{"en"}},
+		"mock":   {Provider: string(ProviderMock), Languages: []string{"en"}},
+	}}})
+	flaky := &flakyProvider{}
+	registry := uc.ASRRegistry()
+	registry.RegisterProviderType("flaky", func(*config.ASRConfig, string, *config.ModelConfig) (domain.ASRProvider, error) {
+		return flaky, nil
+	})
+	registry.RegisterProviderType("unloadable", func(*config.ASRConfig, string, *config.ModelConfig) (domain.ASRProvider, error) {
+		return nil, errors.New("model file missing")
+	})
+	registry.RegisterProviderType(ProviderMock, func(*config.ASRConfig, string, *config.ModelConfig) (domain.ASRProvider, error) {
+		return NewMockASRProvider(), nil
+	})
+	transcribe := func() {
+		t.Helper()
+		provider, err := registry.GetModel("real", "en")
+		if err != nil {
+			t.Fatalf("GetModel failed: %v", err)
+		}
+		results, err := provider.Transcribe(context.Background(), nil, &domain.TranscriptionConfig{})
+		if err != nil {
+			t.Fatalf("Transcribe failed: %v", err)
+		}
+		for range results {
+		}
+	}
+
+	health := uc.Health()
+	if health.Status != HealthOK || len(health.Models) != 3 || health.Models["real"].Status != HealthNotLoaded {
+		t.Fatalf("Expected unused models to leave the server healthy, got %+v", health)
+	}
+
+	transcribe()
+	if model := uc.Health().Models["real"]; model.Status != HealthOK || !model.ModelLoaded || model.Provider != "flaky" {
+		t.Errorf("Expected the loaded model to be healthy, got %+v", model)
+	}
+
+	if _, err := registry.GetModel("broken", "en"); err == nil {
+		t.Fatal("Expected the broken model to fail to load")
+	}
+	health = uc.Health()
+	if model := health.Models["broken"]; health.Status != HealthDegraded || model.Status != HealthDegraded ||
+		!model.Failing || model.LastError == "" || model.LastErrorAt == nil {
+		t.Errorf("Expected a failed load to degrade the server, got %+v", health)
+	}
+
+	if _, err := registry.GetModel("mock", "en"); err != nil {
+		t.Fatalf("GetModel failed: %v", err)
+	}
+	if model := uc.Health().Models["mock"]; model.Status != HealthDegraded || !model.Mock {
+		t.Errorf("Expected a mock model to be reported degraded, got %+v", model)
+	}
+
+	flaky.err = errors.New("decoder crashed")
+	transcribe()
+	if model := uc.Health().Models["real"]; model.Status != HealthDegraded || !model.Failing || model.LastError != "decoder crashed" {
+		t.Errorf("Expected a failed transcription to degrade the model, got %+v", model)
+	}
+	flaky.err = nil
+	transcribe()
+	if model := uc.Health().Models["real"]; model.Status != HealthOK || model.Failing || model.LastError != "decoder crashed" {
+		t.Errorf("Expected a success to heal the model and keep its last error, got %+v", model)
+	}
+
+	flaky.gate = make(chan struct{})
+	provider, _ := registry.GetModel("real", "en")
+	results, _ := provider.Transcribe(context.Background(), nil, &domain.TranscriptionConfig{})
+	if depth := uc.Health().Models["real"].QueueDepth; depth != 1 {
+		t.Errorf("Expected a queue depth of 1, got %d", depth)
+	}
+	close(flaky.gate)
+	for range results {
+	}
+}
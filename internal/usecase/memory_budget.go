@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// memoryShedRatio is the share of the budget past which conversation audio is
+// pruned and, if that is not enough, new sessions are refused
+const memoryShedRatio = 0.9
+
+// memoryRelieveInterval limits how often commits rescan sessions for usage
+const memoryRelieveInterval = time.Second
+
+// Memory metrics add up the budgets of the process
+var (
+	memoryUsage = metrics.NewGauge("gribe_memory_usage_bytes",
+		"Session audio held in memory, as of the last budget check")
+	memoryBudgetBytes = metrics.NewGauge("gribe_memory_budget_bytes",
+		"Ceiling on session audio held in memory")
+	memoryPruned = metrics.NewCounter("gribe_memory_pruned_bytes_total",
+		"Conversation audio dropped to stay under the memory budget")
+	memoryRefused = metrics.NewCounter("gribe_memory_sessions_refused_total",
+		"Sessions refused because memory use approached the memory budget")
+)
+
+// memoryBudget accounts the audio live sessions hold in memory, their input
+// buffers and conversation audio, against a server-wide ceiling. A nil budget
+// admits everything.
+type memoryBudget struct {
+	limit    int64
+	sessions *SessionManager
+	mu       sync.Mutex
+	relieved time.Time
+	reported int64 // Usage last added to the memoryUsage gauge
+	now      func() time.Time
+}
+
+// newMemoryBudget returns nil, disabling the budget, when limit is 0
+func newMemoryBudget(limit int, sessions *SessionManager) *memoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	memoryBudgetBytes.Add(int64(limit))
+	return &memoryBudget{limit: int64(limit), sessions: sessions, now: time.Now}
+}
+
+// report moves this budget's share of the memoryUsage gauge to usage
+func (b *memoryBudget) report(usage int64) {
+	memoryUsage.Add(usage - b.reported)
+	b.reported = usage
+}
+
+// usage returns the bytes of audio live sessions hold in memory
+func (b *memoryBudget) usage() int64 {
+	var total int64
+	for _, state := range b.sessions.Sessions() {
+		total += int64(state.AudioBuffer.MemorySize() + state.Conversation.AudioBytes())
+	}
+	return total
+}
+
+// shedLevel is the usage past which the budget sheds load
+func (b *memoryBudget) shedLevel() int64 {
+	return int64(float64(b.limit) * memoryShedRatio)
+}
+
+// admit reports whether a new session may start. Near the budget it first
+// prunes conversation audio and refuses only if that does not free enough.
+func (b *memoryBudget) admit() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	usage := b.usage()
+	if usage >= b.shedLevel() {
+		usage = b.prune(usage)
+	}
+	b.report(usage)
+	if usage >= b.shedLevel() {
+		memoryRefused.Inc()
+		log.Printf("[WARN] Memory use %d bytes is near the budget of %d bytes, refusing new session", usage, b.limit)
+		return false
+	}
+	return true
+}
+
+// relieve prunes conversation audio when usage approaches the budget. It is
+// called as conversations grow and scans at most once a memoryRelieveInterval.
+func (b *memoryBudget) relieve() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if now.Sub(b.relieved) < memoryRelieveInterval {
+		return
+	}
+	b.relieved = now
+	usage := b.usage()
+	if usage >= b.shedLevel() {
+		usage = b.prune(usage)
+	}
+	b.report(usage)
+}
+
+// prune drops conversation audio, largest conversations first, until usage
+// falls below the shed level, and returns the new usage
+func (b *memoryBudget) prune(usage int64) int64 {
+	sessions := b.sessions.Sessions()
+	sizes := make(map[*domain.SessionState]int, len(sessions))
+	for _, state := range sessions {
+		sizes[state] = state.Conversation.AudioBytes()
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sizes[sessions[i]] > sizes[sessions[j]] })
+
+	for _, state := range sessions {
+		if usage < b.shedLevel() || sizes[state] == 0 {
+			break
+		}
+		pruned := state.Conversation.PruneAudio()
+		usage -= int64(pruned)
+		memoryPruned.Add(uint64(pruned))
+		log.Printf("[WARN] Memory use near the budget of %d bytes, dropped %d bytes of conversation audio from session %s",
+			b.limit, pruned, state.ID)
+	}
+	return usage
+}
@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	var unlimited *memoryBudget
+	if !unlimited.admit() {
+		t.Fatal("Expected a nil budget to admit every session")
+	}
+
+	sessions := NewSessionManager()
+	b := newMemoryBudget(10000, sessions)
+
+	small := sessions.CreateSession("sess_small", "model", "conv_small")
+	large := sessions.CreateSession("sess_large", "model", "conv_large")
+	addAudioItem(small, "item_1", 1000)
+	addAudioItem(large, "item_2", 4000)
+	if !b.admit() {
+		t.Fatalf("Expected a session to be admitted at %d bytes", b.usage())
+	}
+
+	// Past 90% of the budget the largest conversation's audio is dropped first
+	addAudioItem(large, "item_3", 4500)
+	if !b.admit() {
+		t.Fatal("Expected pruning to make room for a new session")
+	}
+	if large.Conversation.AudioBytes() != 0 || small.Conversation.AudioBytes() != 1000 {
+		t.Errorf("Expected only the large conversation pruned, have %d and %d bytes",
+			large.Conversation.AudioBytes(), small.Conversation.AudioBytes())
+	}
+	if item := large.Conversation.GetItem("item_2"); item.Content[0].Transcript != "hello" {
+		t.Error("Expected pruning to keep transcripts")
+	}
+
+	// Buffered audio cannot be pruned, so new sessions are refused
+	large.AudioBuffer.Append(make([]byte, 9500))
+	if b.admit() {
+		t.Errorf("Expected a session to be refused at %d bytes", b.usage())
+	}
+}
+
+func addAudioItem(state *domain.SessionState, itemID string, size int) {
+	item := domain.NewItem(itemID, "message", "user")
+	item.Content = []domain.ContentPart{{Type: "input_audio", Audio: strings.Repeat("A", size), Transcript: "hello"}}
+	state.Conversation.AddItem(item)
+}
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/aira-id/gribe/internal/pkg/convstore"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestSessionMetadata(t *testing.T) {
+	uc := NewSessionUsecase()
+	var closed *domain.SessionClosedEvent
+	uc.AddEventObserver(func(sessionID string, event interface{}) {
+		if e, ok := event.(*domain.SessionClosedEvent); ok {
+			closed = e
+		}
+	})
+	sink := &usageRecorder{}
+	uc.SetUsageSink(sink)
+	store := convstore.NewMemory(10)
+	uc.SetConversationStore(store)
+	state := uc.sessionManager.CreateTranscriptionSession("sess_1", "", "conv_1", "")
+	state.Config.Metadata = map[string]string{"call_id": "call-1"}
+	uc.stats.Store(state.ID, &sessionStats{})
+	conn := &recordingConn{}
+
+	// An update replaces the metadata as a whole and echoes it
+	uc.ProcessMessage(conn, state, []byte(`{"type":"transcription_session.update","session":{"metadata":{"agent_id":"agent-7","consent":"true"}}}`))
+	var updated *domain.TranscriptionSessionUpdatedEvent
+	for _, event := range conn.events {
+		if e, ok := event.(*domain.TranscriptionSessionUpdatedEvent); ok {
+			updated = e
+		}
+	}
+	want := map[string]string{"agent_id": "agent-7", "consent": "true"}
+	if updated == nil || !equalMetadata(updated.Session.Metadata, want) {
+		t.Fatalf("Expected transcription_session.updated with %v, got %+v", want, updated)
+	}
+
+	// Updates without metadata keep it
+	uc.ProcessMessage(conn, state, []byte(`{"type":"transcription_session.update","session":{"diarization":true}}`))
+	if !equalMetadata(state.Config.Metadata, want) {
+		t.Errorf("Expected the metadata to be kept, got %v", state.Config.Metadata)
+	}
+
+	uc.ProcessMessage(conn, state, []byte(`{"type":"conversation.item.create","item":{"type":"message","role":"user","content":[{"type":"input_text","text":"hello"}]}}`))
+	uc.saveConversation(state)
+	uc.reportUsage(&liveSession{state: state})
+
+	if stored, err := store.Get("sess_1"); err != nil || !equalMetadata(stored.Metadata, want) {
+		t.Errorf("Expected the stored conversation to carry %v, got %+v (%v)", want, stored, err)
+	}
+	if closed == nil || !equalMetadata(closed.Metadata, want) {
+		t.Errorf("Expected session.closed to carry %v, got %+v", want, closed)
+	}
+	if len(sink.records) != 1 || !equalMetadata(sink.records[0].Metadata, want) {
+		t.Errorf("Expected a usage record with %v, got %+v", want, sink.records)
+	}
+
+	// null clears it
+	uc.ProcessMessage(conn, state, []byte(`{"type":"transcription_session.update","session":{"metadata":null}}`))
+	if state.Config.Metadata != nil {
+		t.Errorf("Expected null to clear the metadata, got %v", state.Config.Metadata)
+	}
+
+	// Oversized metadata is refused
+	conn.events = nil
+	uc.ProcessMessage(conn, state, []byte(`{"type":"transcription_session.update","session":{"metadata":{"":"empty key"}}}`))
+	if detail := conn.lastError(t); detail.Code != "invalid_value" {
+		t.Errorf("Expected invalid_value, got %s", detail.Code)
+	}
+}
+
+func equalMetadata(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+package usecase
+
+// EventObserver receives a copy of every server event sent to a session, e.g.
+// to forward transcripts to a message broker. It runs on the goroutine that
+// sent the event, which may be the session's VAD worker or a transcription,
+// so it must be safe for concurrent use and must not block.
+type EventObserver func(sessionID string, event interface{})
+
+// AddEventObserver registers an observer for the events of all sessions. It
+// must be called before connections are handled.
+func (u *SessionUsecase) AddEventObserver(observer EventObserver) {
+	u.observers = append(u.observers, observer)
+}
+
+// observedConn passes every event written to a session to the observers and
+// the session's subscribers, and counts it in the session's usage
+type observedConn struct {
+	Conn
+	sessionID string
+	observers []EventObserver
+	events    *eventHub
+	stats     *sessionStats
+}
+
+func (c *observedConn) WriteJSON(v interface{}) error {
+	err := c.Conn.WriteJSON(v)
+	c.stats.countEvent(false)
+	for _, observe := range c.observers {
+		observe(c.sessionID, v)
+	}
+	c.events.publish(c.sessionID, v)
+	return err
+}
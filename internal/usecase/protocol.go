@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// sendSessionEvent sends session.created, or session.updated when created is
+// false. Transcription sessions of the default and beta protocols get the
+// beta transcription_session.* events with the flattened configuration
+// instead, and realtime sessions of the beta protocol a flat session.
+func (u *SessionUsecase) sendSessionEvent(conn Conn, state *domain.SessionState, created bool) error {
+	base := domain.BaseEvent{EventID: u.idGen.GenerateEventID(), Type: domain.EventSessionUpdated}
+	if created {
+		base.Type = domain.EventSessionCreated
+	}
+
+	if state.Config.Type != "transcription" && state.Protocol == domain.Protocol2024 {
+		session := domain.NewBetaSessionConfig(state.Config)
+		if created {
+			return conn.WriteJSON(&domain.BetaSessionCreatedEvent{BaseEvent: base, Session: session})
+		}
+		return conn.WriteJSON(&domain.BetaSessionUpdatedEvent{BaseEvent: base, Session: session})
+	}
+	if state.Config.Type != "transcription" || state.Protocol == domain.ProtocolGA {
+		if created {
+			return conn.WriteJSON(&domain.SessionCreatedEvent{BaseEvent: base, Session: state.Config})
+		}
+		return conn.WriteJSON(&domain.SessionUpdatedEvent{BaseEvent: base, Session: state.Config})
+	}
+
+	session := domain.NewTranscriptionSessionConfig(state.Config)
+	if created {
+		base.Type = domain.EventTranscriptionSessionCreated
+		return conn.WriteJSON(&domain.TranscriptionSessionCreatedEvent{BaseEvent: base, Session: session})
+	}
+	base.Type = domain.EventTranscriptionSessionUpdated
+	return conn.WriteJSON(&domain.TranscriptionSessionUpdatedEvent{BaseEvent: base, Session: session})
+}
+
+// handleBetaSessionUpdate handles session.update with a flat beta session
+func (u *SessionUsecase) handleBetaSessionUpdate(conn Conn, state *domain.SessionState, message []byte) {
+	var event domain.BetaSessionUpdateClientEvent
+	if !u.decodeClientEvent(conn, message, &event) {
+		return
+	}
+	u.applyFlatSessionUpdate(conn, state, event.EventID, &event.Session.TranscriptionSessionConfig, event.Session, message)
+}
+
+// sendItemAdded announces an item added to the conversation, as
+// conversation.item.created, or conversation.item.added without echoing input
+// audio back for GA
+func (u *SessionUsecase) sendItemAdded(conn Conn, state *domain.SessionState, item *domain.Item, previousItemID *string) {
+	eventType := domain.EventConversationItemCreated
+	if state.Protocol == domain.ProtocolGA {
+		eventType = domain.EventConversationItemAdded
+		item = withoutInputAudio(item)
+	}
+	conn.WriteJSON(&domain.ConversationItemAddedEvent{
+		BaseEvent: domain.BaseEvent{
+			EventID: u.idGen.GenerateEventID(),
+			Type:    eventType,
+		},
+		Item:           item,
+		PreviousItemID: previousItemID,
+	})
+}
+
+// sendItemDone sends conversation.item.done with the finalized item. Its
+// input audio was sent with the item already and is not repeated. The beta
+// protocol has no such event.
+func (u *SessionUsecase) sendItemDone(conn Conn, state *domain.SessionState, item *domain.Item, previousItemID *string) {
+	if state.Protocol == domain.Protocol2024 {
+		return
+	}
+	conn.WriteJSON(&domain.ConversationItemDoneEvent{
+		BaseEvent: domain.BaseEvent{
+			EventID: u.idGen.GenerateEventID(),
+			Type:    domain.EventConversationItemDone,
+		},
+		Item:           withoutInputAudio(item),
+		PreviousItemID: previousItemID,
+	})
+}
+
+// withoutInputAudio returns a copy of item without the audio data and format
+// of its input_audio parts, which GA items do not carry
+func withoutInputAudio(item *domain.Item) *domain.Item {
+	stripped := *item
+	stripped.Content = make([]domain.ContentPart, len(item.Content))
+	for i, part := range item.Content {
+		if part.Type == "input_audio" {
+			part.Audio, part.Format = "", ""
+		}
+		stripped.Content[i] = part
+	}
+	return &stripped
+}
@@ -0,0 +1,225 @@
+package usecase
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Rate limit names reported in rate_limits.updated for audio quotas
+const (
+	QuotaDailyAudioSeconds   = "audio_seconds_daily"
+	QuotaMonthlyAudioSeconds = "audio_seconds_monthly"
+)
+
+// TenantQuotaKey is the account of the quota shared by the keys of tenant id,
+// when the tenant has a quota of its own
+func TenantQuotaKey(id string) string {
+	return "tenant:" + id
+}
+
+// quotaKey returns the account a session's audio is charged to: its
+// tenant's shared one, or else its API key's
+func quotaKey(state *domain.SessionState) string {
+	if state.Tenant != nil && state.Tenant.SharedQuota {
+		return TenantQuotaKey(state.Tenant.ID)
+	}
+	return state.APIKey
+}
+
+// QuotaUsage is a snapshot of one API key's audio usage
+type QuotaUsage struct {
+	Key            string  `json:"key"`
+	DailySeconds   float64 `json:"daily_seconds"`
+	DailyLimit     int     `json:"daily_limit"`
+	DailyReset     int64   `json:"daily_reset_at"` // Unix seconds
+	MonthlySeconds float64 `json:"monthly_seconds"`
+	MonthlyLimit   int     `json:"monthly_limit"`
+	MonthlyReset   int64   `json:"monthly_reset_at"` // Unix seconds
+	TotalSeconds   float64 `json:"total_seconds"`
+	Exceeded       bool    `json:"exceeded"`
+}
+
+type keyUsage struct {
+	dayStart   time.Time
+	monthStart time.Time
+	daily      float64
+	monthly    float64
+	total      float64
+}
+
+// QuotaTracker accounts transcribed audio duration per API key over daily
+// and monthly UTC windows
+type QuotaTracker struct {
+	config    *config.QuotaConfig
+	keyLimits map[string]*config.QuotaConfig // Per-key overrides of config
+	usage     map[string]*keyUsage
+	mu        sync.Mutex
+	now       func() time.Time
+}
+
+// NewQuotaTracker creates a tracker for the given limits
+func NewQuotaTracker(cfg *config.QuotaConfig) *QuotaTracker {
+	return &QuotaTracker{
+		config:    cfg,
+		keyLimits: make(map[string]*config.QuotaConfig),
+		usage:     make(map[string]*keyUsage),
+		now:       time.Now,
+	}
+}
+
+// SetKeyLimits overrides the default limits for one API key or tenant
+func (q *QuotaTracker) SetKeyLimits(key string, limits *config.QuotaConfig) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.keyLimits[key] = limits
+}
+
+// Enabled reports whether any quota window applies to key
+func (q *QuotaTracker) Enabled(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	limits := q.limits(key)
+	return limits.DailyAudioSeconds > 0 || limits.MonthlyAudioSeconds > 0
+}
+
+// SoftLimit reports whether exceeding key's quota is only reported, not enforced
+func (q *QuotaTracker) SoftLimit(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.limits(key).SoftLimit
+}
+
+// Exceeded reports whether key has used up any of its quota windows
+func (q *QuotaTracker) Exceeded(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.exceeded(q.limits(key), q.current(key))
+}
+
+// Record adds seconds of transcribed audio to key's usage
+func (q *QuotaTracker) Record(key string, seconds float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	usage := q.current(key)
+	usage.daily += seconds
+	usage.monthly += seconds
+	usage.total += seconds
+}
+
+// RateLimits returns key's remaining quota in rate_limits.updated form
+func (q *QuotaTracker) RateLimits(key string) []domain.RateLimit {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	usage := q.current(key)
+	cfg := q.limits(key)
+	now := q.now()
+	var limits []domain.RateLimit
+	if cfg.DailyAudioSeconds > 0 {
+		limits = append(limits, rateLimit(QuotaDailyAudioSeconds, cfg.DailyAudioSeconds,
+			usage.daily, usage.dayStart.AddDate(0, 0, 1).Sub(now)))
+	}
+	if cfg.MonthlyAudioSeconds > 0 {
+		limits = append(limits, rateLimit(QuotaMonthlyAudioSeconds, cfg.MonthlyAudioSeconds,
+			usage.monthly, usage.monthStart.AddDate(0, 1, 0).Sub(now)))
+	}
+	return limits
+}
+
+// Usage returns a snapshot of key's usage
+func (q *QuotaTracker) Usage(key string) QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, exists := q.usage[key]; !exists {
+		dayStart, monthStart := q.windows()
+		return q.snapshot(key, &keyUsage{dayStart: dayStart, monthStart: monthStart})
+	}
+	return q.snapshot(key, q.current(key))
+}
+
+// AllUsage returns usage for every key seen so far, sorted by key
+func (q *QuotaTracker) AllUsage() []QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	result := make([]QuotaUsage, 0, len(q.usage))
+	for key := range q.usage {
+		result = append(result, q.snapshot(key, q.current(key)))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// current returns key's usage with expired windows reset. Caller holds q.mu.
+func (q *QuotaTracker) current(key string) *keyUsage {
+	dayStart, monthStart := q.windows()
+
+	usage, exists := q.usage[key]
+	if !exists {
+		usage = &keyUsage{dayStart: dayStart, monthStart: monthStart}
+		q.usage[key] = usage
+	}
+	if usage.dayStart.Before(dayStart) {
+		usage.dayStart = dayStart
+		usage.daily = 0
+	}
+	if usage.monthStart.Before(monthStart) {
+		usage.monthStart = monthStart
+		usage.monthly = 0
+	}
+	return usage
+}
+
+// limits returns the quota configuration for key. Caller holds q.mu.
+func (q *QuotaTracker) limits(key string) *config.QuotaConfig {
+	if limits, ok := q.keyLimits[key]; ok {
+		return limits
+	}
+	return q.config
+}
+
+// windows returns the start of the current UTC day and month
+func (q *QuotaTracker) windows() (time.Time, time.Time) {
+	now := q.now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (q *QuotaTracker) exceeded(limits *config.QuotaConfig, usage *keyUsage) bool {
+	return (limits.DailyAudioSeconds > 0 && usage.daily >= float64(limits.DailyAudioSeconds)) ||
+		(limits.MonthlyAudioSeconds > 0 && usage.monthly >= float64(limits.MonthlyAudioSeconds))
+}
+
+func (q *QuotaTracker) snapshot(key string, usage *keyUsage) QuotaUsage {
+	limits := q.limits(key)
+	return QuotaUsage{
+		Key:            key,
+		DailySeconds:   usage.daily,
+		DailyLimit:     limits.DailyAudioSeconds,
+		DailyReset:     usage.dayStart.AddDate(0, 0, 1).Unix(),
+		MonthlySeconds: usage.monthly,
+		MonthlyLimit:   limits.MonthlyAudioSeconds,
+		MonthlyReset:   usage.monthStart.AddDate(0, 1, 0).Unix(),
+		TotalSeconds:   usage.total,
+		Exceeded:       q.exceeded(limits, usage),
+	}
+}
+
+func rateLimit(name string, limit int, used float64, untilReset time.Duration) domain.RateLimit {
+	remaining := limit - int(used)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return domain.RateLimit{
+		Name:         name,
+		Limit:        limit,
+		Remaining:    remaining,
+		ResetSeconds: int(untilReset.Seconds()),
+	}
+}
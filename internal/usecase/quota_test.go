@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestQuotaTrackerWindows(t *testing.T) {
+	now := time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC)
+	q := NewQuotaTracker(&config.QuotaConfig{DailyAudioSeconds: 10, MonthlyAudioSeconds: 15})
+	q.now = func() time.Time { return now }
+
+	q.Record("key", 10)
+	if !q.Exceeded("key") {
+		t.Fatal("Expected daily quota to be exceeded")
+	}
+
+	limits := q.RateLimits("key")
+	if len(limits) != 2 {
+		t.Fatalf("Expected 2 rate limits, got %d", len(limits))
+	}
+	if limits[0].Name != QuotaDailyAudioSeconds || limits[0].Remaining != 0 || limits[0].ResetSeconds != 3600 {
+		t.Errorf("Unexpected daily limit: %+v", limits[0])
+	}
+	if limits[1].Remaining != 5 {
+		t.Errorf("Expected 5 monthly seconds remaining, got %d", limits[1].Remaining)
+	}
+
+	// Crossing into February resets both windows
+	now = now.Add(2 * time.Hour)
+	q.Record("key", 4)
+	usage := q.Usage("key")
+	if usage.DailySeconds != 4 || usage.MonthlySeconds != 4 || usage.TotalSeconds != 14 {
+		t.Errorf("Unexpected usage after month rollover: %+v", usage)
+	}
+
+	// A new day within the month resets only the daily window
+	now = time.Date(2025, 2, 2, 1, 0, 0, 0, time.UTC)
+	q.Record("key", 12)
+	if !q.Exceeded("key") {
+		t.Error("Expected monthly quota to be exceeded")
+	}
+	if usage := q.Usage("key"); usage.DailySeconds != 12 || usage.MonthlySeconds != 16 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+}
+
+func TestQuotaChargedOnAppend(t *testing.T) {
+	uc := NewSessionUsecase()
+	uc.quota = NewQuotaTracker(&config.QuotaConfig{DailyAudioSeconds: 1})
+	state := uc.sessionManager.CreateSession("sess", "model", "conv")
+	state.APIKey = "key"
+
+	conn := &recordingConn{}
+	uc.ProcessMessage(conn, state, []byte(`{"type":"session.update","session":{"audio":{"input":{"format":{"type":"audio/pcmu"}}}}}`))
+	appendAudio := func(n int) {
+		uc.ProcessMessage(conn, state, []byte(`{"type":"input_audio_buffer.append","audio":"`+
+			base64.StdEncoding.EncodeToString(make([]byte, n))+`"}`))
+	}
+
+	// G.711 is a byte a sample at 8kHz: 4000 bytes are half a second
+	appendAudio(4000)
+	if usage := uc.quota.Usage("key"); usage.DailySeconds != 0.5 || usage.Exceeded {
+		t.Fatalf("Expected half a second charged on append, got %+v", usage)
+	}
+	appendAudio(4000)
+	if usage := uc.quota.Usage("key"); usage.DailySeconds != 1 || !usage.Exceeded {
+		t.Fatalf("Expected the quota used up, got %+v", usage)
+	}
+
+	// Once used up, appends are refused and not charged
+	appendAudio(4000)
+	if detail := conn.lastError(t); detail.Code != domain.CodeInsufficientQuota {
+		t.Errorf("Expected insufficient_quota, got %s", detail.Code)
+	}
+	if usage := uc.quota.Usage("key"); usage.DailySeconds != 1 {
+		t.Errorf("Expected a refused append not to be charged, got %+v", usage)
+	}
+	if size := state.AudioBuffer.GetSize(); size != 8000 {
+		t.Errorf("Expected a refused append not to be buffered, got %d bytes", size)
+	}
+}
+
+func TestTenantQuotaShared(t *testing.T) {
+	uc := NewSessionUsecaseWithConfig(&config.Config{
+		Tenants: map[string]config.TenantConfig{
+			"acme": {APIKeys: []string{"acme-1", "acme-2"}, JWTClaimValues: []string{"acme"},
+				Quota: &config.QuotaConfig{DailyAudioSeconds: 1}},
+		},
+	})
+	conn := &recordingConn{}
+	session := func(id, apiKey, claim string) *domain.SessionState {
+		state := uc.sessionManager.CreateSession(id, "model", "conv")
+		state.APIKey = apiKey
+		state.Tenant = uc.ResolveTenant(apiKey, claim)
+		uc.ProcessMessage(conn, state, []byte(`{"type":"session.update","session":{"audio":{"input":{"format":{"type":"audio/pcmu"}}}}}`))
+		return state
+	}
+	appendAudio := func(state *domain.SessionState, n int) {
+		uc.ProcessMessage(conn, state, []byte(`{"type":"input_audio_buffer.append","audio":"`+
+			base64.StdEncoding.EncodeToString(make([]byte, n))+`"}`))
+	}
+
+	// Two keys of the tenant drain one budget
+	first, second := session("sess_1", "acme-1", ""), session("sess_2", "acme-2", "")
+	appendAudio(first, 4000)
+	appendAudio(second, 4000)
+	if usage := uc.quota.Usage(TenantQuotaKey("acme")); usage.DailySeconds != 1 || !usage.Exceeded {
+		t.Fatalf("Expected both keys charged to the tenant, got %+v", usage)
+	}
+
+	// So does a JWT subject of the tenant, without an account of its own
+	third := session("sess_3", "jwt:alice", "acme")
+	appendAudio(third, 4000)
+	if detail := conn.lastError(t); detail.Code != domain.CodeInsufficientQuota {
+		t.Errorf("Expected insufficient_quota for the tenant's JWT subject, got %s", detail.Code)
+	}
+	for _, key := range []string{"acme-1", "acme-2", "jwt:alice"} {
+		if usage := uc.quota.Usage(key); usage.DailySeconds != 0 {
+			t.Errorf("Expected nothing charged to %s, got %+v", key, usage)
+		}
+	}
+	if n := len(uc.quota.AllUsage()); n != 1 {
+		t.Errorf("Expected only the tenant's account tracked, got %d", n)
+	}
+}
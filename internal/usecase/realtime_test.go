@@ -0,0 +1,951 @@
+// End-to-end session tests over the realtime harness, which imports this package.
+
+package usecase_test
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/mock"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/realtimetest"
+)
+
+func TestTransientFailuresRetried(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Audio.TranscriptionRetries = 2
+	cfg.Audio.RetryBackoff = time.Millisecond
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+
+	if err := srv.Provider.LoadScenario("testdata/retries.yaml"); err != nil {
+		t.Fatalf("LoadScenario failed: %v", err)
+	}
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func() {
+		t.Helper()
+		if err := client.AppendAudio(make([]byte, 320)); err != nil {
+			t.Fatalf("AppendAudio failed: %v", err)
+		}
+		if err := client.Commit(); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+	expectTranscript := func(want string) {
+		t.Helper()
+		event, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var completed domain.ConversationItemInputAudioTranscriptionCompletedEvent
+		event.Decode(&completed)
+		if completed.Transcript != want {
+			t.Errorf("Expected %q, got %q", want, completed.Transcript)
+		}
+	}
+	expectFailure := func(message string) {
+		t.Helper()
+		event, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionFailed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var failed domain.ErrorServerEvent
+		event.Decode(&failed)
+		if failed.Error.Code != "transcription_failed" || !strings.Contains(failed.Error.Message, message) {
+			t.Errorf("Expected transcription_failed with %q, got %s %q", message, failed.Error.Code, failed.Error.Message)
+		}
+	}
+
+	commit()
+	expectTranscript("first turn")
+	commit()
+	expectTranscript("second turn")
+	commit()
+	expectFailure("connection reset")
+	commit()
+	expectFailure("unsupported audio")
+	commit()
+	expectFailure("backend overloaded")
+
+	// The scenario is exhausted only if each retry made exactly one call
+	commit()
+	expectTranscript("recovered")
+}
+
+func TestDisconnectCancelsTranscription(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetScenario(&mock.Scenario{Step: mock.Step{Hang: true}})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AppendAudio(make([]byte, 320)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	waitFor := func(want int) bool {
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if srv.Provider.InFlight() == want {
+				return true
+			}
+		}
+		return false
+	}
+	if !waitFor(1) {
+		t.Fatal("Expected the transcription to start")
+	}
+
+	// The transcription timeout is 5s, so only the disconnect can end it this soon
+	client.Close()
+	if !waitFor(0) {
+		t.Error("Expected the disconnect to cancel the transcription")
+	}
+}
+
+func TestAudioQuota(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Quota.DailyAudioSeconds = 1
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+
+	client, err := srv.Dial(url.Values{"intent": {"transcription"}, "api_key": {"key-a"}}, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Expect(domain.EventRateLimitsUpdated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	// One second of 24kHz PCM16 uses up the whole daily quota
+	client.AppendAudio(make([]byte, 48000))
+	client.Commit()
+	if _, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted); err != nil {
+		t.Fatal(err)
+	}
+	event, err := client.Expect(domain.EventRateLimitsUpdated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updated domain.RateLimitsUpdatedEvent
+	event.Decode(&updated)
+	if len(updated.RateLimits) != 1 || updated.RateLimits[0].Remaining != 0 {
+		t.Fatalf("Expected no remaining quota, got %+v", updated.RateLimits)
+	}
+
+	client.AppendAudio(make([]byte, 3200))
+	event, err = client.Expect(domain.EventError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed domain.ErrorServerEvent
+	event.Decode(&failed)
+	if failed.Error.Code != "insufficient_quota" {
+		t.Errorf("Expected code insufficient_quota, got %s", failed.Error.Code)
+	}
+	if failed.Error.Retryable {
+		t.Error("Expected an exhausted quota not to be retryable")
+	}
+
+	if usage := srv.UseCase.Quota().Usage("key-a"); usage.DailySeconds != 1 || !usage.Exceeded {
+		t.Errorf("Unexpected usage for key-a: %+v", usage)
+	}
+	if usage := srv.UseCase.Quota().Usage("key-b"); usage.Exceeded {
+		t.Error("Quota of one key should not affect another")
+	}
+}
+
+func TestTenantModelRestriction(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Tenants = map[string]config.TenantConfig{
+		"acme": {APIKeys: []string{"acme-key"}, AllowedLanguages: []string{"es"}},
+	}
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+
+	client, err := srv.Dial(url.Values{"intent": {"transcription"}, "api_key": {"acme-key"}}, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	_, err = client.ConfigureTranscription(realtimetest.MockModel, "en")
+	if err == nil || !strings.Contains(err.Error(), "language_not_allowed") {
+		t.Fatalf("Expected disallowed language to be rejected, got %v", err)
+	}
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "es"); err != nil {
+		t.Fatalf("Expected allowed language to be accepted: %v", err)
+	}
+
+	// Keys outside the tenant are no longer accepted once tenant keys exist
+	if _, err := srv.Dial(url.Values{"api_key": {"other"}}, nil); err == nil {
+		t.Error("Expected unknown key to be rejected")
+	}
+}
+
+func TestModelDefaults(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.ASR.Models["tuned"] = config.ModelConfig{
+		Provider:  string(usecase.ProviderMock),
+		Languages: []string{"en"},
+		Defaults: &config.ModelDefaults{
+			TurnDetection: config.TurnDetectionDefaults{Threshold: 0.7, SilenceDurationMs: 900},
+			Prompt:        "medical vocabulary",
+		},
+	}
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+
+	client, err := srv.Dial(url.Values{"intent": {"transcription"}}, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	event, err := client.ConfigureTranscription("tuned", "en")
+	if err != nil {
+		t.Fatalf("ConfigureTranscription failed: %v", err)
+	}
+	var updated domain.SessionUpdatedEvent
+	if err := event.Decode(&updated); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	input := updated.Session.Audio.Input
+	if input.TurnDetection.Threshold != 0.7 || input.TurnDetection.SilenceDurationMs != 900 {
+		t.Errorf("Expected model turn detection defaults, got %+v", input.TurnDetection)
+	}
+	if input.TurnDetection.PrefixPaddingMs != 300 {
+		t.Errorf("Expected unset defaults to keep the session value, got prefix padding %d", input.TurnDetection.PrefixPaddingMs)
+	}
+	if input.Transcription.Prompt != "medical vocabulary" {
+		t.Errorf("Expected model prompt, got %q", input.Transcription.Prompt)
+	}
+
+	// Switching to a model without defaults keeps the current settings
+	event, err = client.ConfigureTranscription(realtimetest.MockModel, "en")
+	if err != nil {
+		t.Fatalf("ConfigureTranscription failed: %v", err)
+	}
+	if err := event.Decode(&updated); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if updated.Session.Audio.Input.TurnDetection.SilenceDurationMs != 900 {
+		t.Errorf("Expected settings to persist, got %+v", updated.Session.Audio.Input.TurnDetection)
+	}
+}
+
+func TestLanguageRoutes(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.ASR.Models["mock-ja"] = config.ModelConfig{Provider: string(usecase.ProviderMock), Languages: []string{"ja"}}
+	cfg.ASR.LanguageRoutes = map[string]string{"ja": "mock-ja", "es": realtimetest.MockModel}
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+
+	client, err := srv.Dial(url.Values{"intent": {"transcription"}}, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	configure := func(model, language string) *domain.TranscriptionConfig {
+		t.Helper()
+		event, err := client.ConfigureTranscription(model, language)
+		if err != nil {
+			t.Fatalf("ConfigureTranscription failed: %v", err)
+		}
+		var updated domain.SessionUpdatedEvent
+		if err := event.Decode(&updated); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		return updated.Session.Audio.Input.Transcription
+	}
+
+	// A language alone selects its routed model
+	if tr := configure("", "ja"); tr.Model != "mock-ja" || tr.Language != "ja" {
+		t.Errorf("Expected mock-ja for ja, got %+v", tr)
+	}
+	if tr := configure("", "es"); tr.Model != realtimetest.MockModel {
+		t.Errorf("Expected %s for es, got %+v", realtimetest.MockModel, tr)
+	}
+
+	// A model named by the client wins over the route
+	if tr := configure(realtimetest.MockModel, "ja"); tr.Model != realtimetest.MockModel {
+		t.Errorf("Expected the requested model, got %+v", tr)
+	}
+}
+
+func TestDefaultModelPreselected(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Audio.DefaultModel = realtimetest.MockModel
+	cfg.Audio.DefaultLanguage = "es"
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hola"})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	event, err := client.Expect(domain.EventTranscriptionSessionCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created domain.TranscriptionSessionCreatedEvent
+	if err := event.Decode(&created); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if tr := created.Session.InputAudioTranscription; tr == nil || tr.Model != realtimetest.MockModel || tr.Language != "es" {
+		t.Fatalf("Expected default model and language in session, got %+v", tr)
+	}
+
+	// No session.update needed before transcribing
+	if err := client.AppendAudio(make([]byte, 3200)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFinalOnlyTranscription(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello", " world"})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendRaw([]byte(`{"type":"transcription_session.update","session":{"transcription_deltas":false}}`)); err != nil {
+		t.Fatalf("SendRaw failed: %v", err)
+	}
+	if _, err := client.Expect(domain.EventTranscriptionSessionUpdated); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.AppendAudio(make([]byte, 3200)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	for {
+		event, err := client.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Type == domain.EventConversationItemInputAudioTranscriptionDelta {
+			t.Fatalf("Expected no delta events, got %s", event.Raw)
+		}
+		if event.Type == domain.EventConversationItemInputAudioTranscriptionCompleted {
+			var completed domain.ConversationItemInputAudioTranscriptionCompletedEvent
+			if err := event.Decode(&completed); err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if completed.Transcript != "hello world" {
+				t.Errorf("Expected transcript 'hello world', got %q", completed.Transcript)
+			}
+			return
+		}
+	}
+}
+
+func TestServerVADAutoCommit(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendRaw([]byte(`{"type":"transcription_session.update","session":{"turn_detection":{"type":"server_vad","threshold":0.5,"prefix_padding_ms":0,"silence_duration_ms":200}}}`)); err != nil {
+		t.Fatalf("SendRaw failed: %v", err)
+	}
+	if _, err := client.Expect(domain.EventTranscriptionSessionUpdated); err != nil {
+		t.Fatal(err)
+	}
+
+	// Silence to calibrate the noise floor, a burst of speech, then enough
+	// silence to end the turn
+	for _, chunk := range [][]byte{tone(100, 0), tone(300, 8000), tone(300, 0)} {
+		if err := client.AppendAudio(chunk); err != nil {
+			t.Fatalf("AppendAudio failed: %v", err)
+		}
+	}
+
+	events, err := client.ExpectSequence(
+		domain.EventInputAudioBufferSpeechStarted,
+		domain.EventInputAudioBufferSpeechStopped,
+		domain.EventInputAudioBufferCommitted,
+		domain.EventConversationItemInputAudioTranscriptionCompleted,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stopped domain.InputAudioBufferSpeechStoppedEvent
+	if err := events[1].Decode(&stopped); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if stopped.AudioEndMs != 400 {
+		t.Errorf("Expected speech to stop at 400ms, got %d", stopped.AudioEndMs)
+	}
+
+	// All events of the segment refer to the same item
+	for _, event := range events {
+		var ref struct {
+			ItemID string `json:"item_id"`
+		}
+		if err := event.Decode(&ref); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if ref.ItemID == "" || ref.ItemID != stopped.ItemID {
+			t.Errorf("Expected item %s in %s, got %q", stopped.ItemID, event.Type, ref.ItemID)
+		}
+	}
+}
+
+func TestIntervalCommit(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendRaw([]byte(`{"type":"transcription_session.update","session":{"turn_detection":{"type":"interval","interval_ms":1000}}}`)); err != nil {
+		t.Fatalf("SendRaw failed: %v", err)
+	}
+	if _, err := client.Expect(domain.EventTranscriptionSessionUpdated); err != nil {
+		t.Fatal(err)
+	}
+
+	// Speech that never pauses is committed once a second of it is buffered
+	for _, chunk := range [][]byte{tone(600, 8000), tone(600, 8000), tone(300, 8000)} {
+		if err := client.AppendAudio(chunk); err != nil {
+			t.Fatalf("AppendAudio failed: %v", err)
+		}
+	}
+	committed, err := client.Expect(domain.EventInputAudioBufferCommitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event domain.InputAudioBufferCommittedEvent
+	if err := committed.Decode(&event); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if event.AudioDurationMs != 1200 {
+		t.Errorf("Expected 1200ms committed, got %d", event.AudioDurationMs)
+	}
+	if _, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rest can still be committed by hand
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if committed, err = client.Expect(domain.EventInputAudioBufferCommitted); err != nil {
+		t.Fatal(err)
+	}
+	if err := committed.Decode(&event); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if event.AudioDurationMs != 300 {
+		t.Errorf("Expected the remaining 300ms committed, got %d", event.AudioDurationMs)
+	}
+}
+
+func TestChunkedCommits(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Audio.MaxBufferSize = 2500 * 48 // 2.5 seconds of 24kHz PCM16
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetScenario(&mock.Scenario{Steps: []mock.Step{
+		{Partials: []string{"the quick brown fox"}},
+		{Partials: []string{"brown fox jumps over"}},
+		{Partials: []string{"Over the lazy dog."}},
+	}})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendRaw([]byte(`{"type":"transcription_session.update","session":{"turn_detection":null,"chunked_commits":true}}`)); err != nil {
+		t.Fatalf("SendRaw failed: %v", err)
+	}
+	if _, err := client.Expect(domain.EventTranscriptionSessionUpdated); err != nil {
+		t.Fatal(err)
+	}
+
+	// Four seconds overflow the buffer twice; each chunk repeats the last
+	// second of the one before
+	for i := 0; i < 4; i++ {
+		if err := client.AppendAudio(tone(1000, 8000)); err != nil {
+			t.Fatalf("AppendAudio failed: %v", err)
+		}
+	}
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	// Transcriptions may complete between the commits, so both are read as
+	// they come
+	var durations []int
+	var transcripts []string
+	for len(durations) < 3 || len(transcripts) < 3 {
+		event, err := client.Next()
+		if err != nil {
+			t.Fatalf("Expected 3 commits and 3 transcripts, got %v and %q: %v", durations, transcripts, err)
+		}
+		switch event.Type {
+		case domain.EventInputAudioBufferCommitted:
+			var committed domain.InputAudioBufferCommittedEvent
+			if err := event.Decode(&committed); err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			durations = append(durations, committed.AudioDurationMs)
+		case domain.EventConversationItemInputAudioTranscriptionCompleted:
+			var completed domain.ConversationItemInputAudioTranscriptionCompletedEvent
+			if err := event.Decode(&completed); err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			transcripts = append(transcripts, completed.Transcript)
+		case domain.EventError:
+			t.Fatalf("Unexpected error event %s", event.Raw)
+		}
+	}
+	for _, duration := range durations {
+		if duration != 2000 {
+			t.Errorf("Expected chunks of 2000ms, got %v", durations)
+			break
+		}
+	}
+	if got := strings.Join(transcripts, " | "); got != "the quick brown fox | jumps over | the lazy dog." {
+		t.Errorf("Expected the transcripts stitched at the overlaps, got %q", got)
+	}
+}
+
+// tone returns ms of 24kHz PCM16 audio alternating between ±amplitude
+func tone(ms int, amplitude int16) []byte {
+	pcm := make([]byte, ms*24*2)
+	for i := 0; i < len(pcm); i += 2 {
+		sample := amplitude
+		if i/2%2 == 1 {
+			sample = -amplitude
+		}
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(sample))
+	}
+	return pcm
+}
+
+func TestSessionResume(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Server.ResumeWindow = time.Minute
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	created, err := client.Expect(domain.EventTranscriptionSessionCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session domain.TranscriptionSessionCreatedEvent
+	if err := created.Decode(&session); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	updated, err := client.ConfigureTranscription(realtimetest.MockModel, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Sequence != 1 || updated.Sequence != 2 {
+		t.Errorf("Expected sequences 1 and 2, got %d and %d", created.Sequence, updated.Sequence)
+	}
+
+	// The transcription completes while the client is away, and the client
+	// asks for everything after session.updated
+	if err := client.AppendAudio(make([]byte, 3200)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, err := client.Expect(domain.EventInputAudioBufferCommitted); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	resumed, err := srv.Dial(url.Values{"session_id": {session.Session.ID}},
+		http.Header{"Last-Event-ID": {"2"}})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer resumed.Close()
+
+	// Events missed while away are replayed, in order and without gaps,
+	// before or along with the session's current configuration
+	seen := make(map[domain.EventType]bool)
+	for want := uint64(3); !seen[domain.EventTranscriptionSessionUpdated] ||
+		!seen[domain.EventConversationItemInputAudioTranscriptionCompleted]; want++ {
+		event, err := resumed.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Sequence != want {
+			t.Fatalf("Expected %s to have sequence %d, got %d", event.Type, want, event.Sequence)
+		}
+		seen[event.Type] = true
+	}
+	if !seen[domain.EventInputAudioBufferCommitted] {
+		t.Error("Expected input_audio_buffer.committed to be replayed")
+	}
+
+	// Sessions that are not live cannot be resumed
+	other, err := srv.Dial(url.Values{"session_id": {"sess_unknown"}}, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer other.Close()
+	errEvent, err := other.Expect(domain.EventError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failure domain.ErrorServerEvent
+	if err := errEvent.Decode(&failure); err != nil || failure.Error.Code != "session_not_found" {
+		t.Errorf("Expected session_not_found, got %s", errEvent.Raw)
+	}
+}
+
+func TestGAProtocol(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Server.Protocol = "ga"
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	// Transcription sessions use session.* events with the GA session object
+	created, err := client.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session domain.SessionCreatedEvent
+	if err := created.Decode(&session); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if created.Type != domain.EventSessionCreated || session.Session.Type != "transcription" {
+		t.Fatalf("Expected session.created of a transcription session, got %s", created.Raw)
+	}
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.AppendAudio(make([]byte, 3200)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	events, err := client.ExpectSequence(
+		domain.EventInputAudioBufferCommitted,
+		domain.EventConversationItemAdded,
+		domain.EventConversationItemInputAudioTranscriptionCompleted,
+		domain.EventConversationItemDone,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var added domain.ConversationItemAddedEvent
+	if err := events[1].Decode(&added); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if part := added.Item.Content[0]; part.Type != "input_audio" || part.Audio != "" {
+		t.Errorf("Expected an input_audio part without audio, got %+v", part)
+	}
+	var done domain.ConversationItemDoneEvent
+	if err := events[3].Decode(&done); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if part := done.Item.Content[0]; part.Transcript != "hello" || part.Audio != "" {
+		t.Errorf("Expected the finalized item with its transcript, got %+v", part)
+	}
+}
+
+func TestTranscriptionQueue(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.Audio.MaxTranscriptions = 1
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(300*time.Millisecond, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func() string {
+		t.Helper()
+		if err := client.AppendAudio(make([]byte, 3200)); err != nil {
+			t.Fatalf("AppendAudio failed: %v", err)
+		}
+		if err := client.Commit(); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		event, err := client.Expect(domain.EventInputAudioBufferCommitted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var committed domain.InputAudioBufferCommittedEvent
+		event.Decode(&committed)
+		return committed.ItemID
+	}
+
+	// The second item waits for the first to finish, and the client is told
+	first, second := commit(), commit()
+	event, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionQueued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queued domain.ConversationItemInputAudioTranscriptionQueuedEvent
+	event.Decode(&queued)
+	if queued.ItemID != second || queued.QueuePosition != 1 {
+		t.Errorf("Expected item %s queued at position 1, got %s", second, event.Raw)
+	}
+
+	// Deleting the queued item cancels its transcription
+	if err := client.Send(map[string]string{"type": "conversation.item.delete", "item_id": second}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Expect(domain.EventConversationItemDeleted); err != nil {
+		t.Fatal(err)
+	}
+	third := commit()
+	for _, want := range []string{first, third} {
+		event, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var completed domain.ConversationItemInputAudioTranscriptionCompletedEvent
+		event.Decode(&completed)
+		if completed.ItemID != want {
+			t.Fatalf("Expected item %s transcribed, got %s", want, completed.ItemID)
+		}
+	}
+}
+
+func TestSessionStats(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello"})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.ConfigureTranscription(realtimetest.MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	client.SendRaw([]byte(`{"type":"transcription_session.update","session":{"stats_interval_ms":10}}`))
+	if _, err := client.Expect(domain.EventError); err != nil {
+		t.Fatalf("Expected intervals below a second to be refused: %v", err)
+	}
+	client.SendRaw([]byte(`{"type":"transcription_session.update","session":{"stats_interval_ms":1000}}`))
+	if _, err := client.Expect(domain.EventTranscriptionSessionUpdated); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.AppendAudio(make([]byte, 4800)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AppendAudio(make([]byte, 960)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+
+	event, err := client.Expect(domain.EventSessionStats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats domain.SessionStatsEvent
+	event.Decode(&stats)
+	if stats.Items != 1 || stats.BufferedBytes != 960 || stats.AudioSeconds != 0.1 {
+		t.Errorf("Expected 1 item, 960 buffered bytes and 0.1s transcribed, got %s", event.Raw)
+	}
+}
+
+func TestTranscriptionPrompt(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+	err = client.Send(map[string]interface{}{
+		"type": domain.EventSessionUpdate,
+		"session": map[string]interface{}{
+			"audio": map[string]interface{}{
+				"input": map[string]interface{}{
+					"transcription": &domain.TranscriptionConfig{
+						Model: realtimetest.MockModel, Language: "en", Prompt: "Gribe, Sherpa", Temperature: 0.3,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if _, err := client.Expect(domain.EventSessionUpdated); err != nil {
+		t.Fatal(err)
+	}
+
+	transcribe := func(audioBytes int) domain.TranscriptionConfig {
+		t.Helper()
+		client.AppendAudio(make([]byte, audioBytes))
+		client.Commit()
+		if _, err := client.Expect(domain.EventConversationItemInputAudioTranscriptionCompleted); err != nil {
+			t.Fatal(err)
+		}
+		return srv.Provider.LastConfig()
+	}
+
+	// Providers without prompt support do not get one
+	if config := transcribe(3200); config.Prompt != "" || config.Temperature != 0 {
+		t.Errorf("Expected the prompt and temperature to be dropped, got %+v", config)
+	}
+
+	srv.Provider.SetPromptSupport(true)
+	if config := transcribe(4800); config.Prompt != "Gribe, Sherpa" || config.Temperature != 0.3 {
+		t.Errorf("Expected the prompt and temperature to reach the provider, got %+v", config)
+	}
+}
+
+func TestDeterministicIDs(t *testing.T) {
+	cfg := realtimetest.DefaultConfig()
+	cfg.IDs = config.IDConfig{Strategy: "sequential", Prefixes: map[string]string{"item": "utt-"}}
+	srv := realtimetest.NewServerWithConfig(cfg)
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	event, err := client.Expect(domain.EventTranscriptionSessionCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created domain.TranscriptionSessionCreatedEvent
+	if err := event.Decode(&created); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if created.Session.ID != "sess_000000000001" || event.EventID != "evt_000000000001" {
+		t.Errorf("Expected the first session and event IDs, got %s and %s", created.Session.ID, event.EventID)
+	}
+
+	client.ConfigureTranscription(realtimetest.MockModel, "en")
+	client.AppendAudio(make([]byte, 3200))
+	client.Commit()
+	event, err = client.Expect(domain.EventInputAudioBufferCommitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var committed domain.InputAudioBufferCommittedEvent
+	if err := event.Decode(&committed); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if committed.ItemID != "utt-000000000001" {
+		t.Errorf("Expected the first item ID with the configured prefix, got %s", committed.ItemID)
+	}
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// resourceBudget accounts a resource taken by loaded model instances, such
+// as the estimated memory of a GPU or the inference threads of the CPU, so
+// the registry refuses a model that does not fit instead of letting CUDA run
+// out of memory or decoding starve the rest of the server
+type resourceBudget struct {
+	mu       sync.Mutex
+	resource string                    // What is accounted, e.g. "GPU memory"
+	unit     string                    // Unit of the amounts, e.g. "MB"
+	limit    int                       // Per pool, 0 disables the accounting
+	used     map[string]map[string]int // pool -> model -> amount held by its instances
+}
+
+func newResourceBudget(resource, unit string, limit int) *resourceBudget {
+	return &resourceBudget{resource: resource, unit: unit, limit: limit, used: make(map[string]map[string]int)}
+}
+
+// reserve takes amount of pool for an instance of model, returning the func
+// that gives it back once the instance is closed
+func (b *resourceBudget) reserve(model, pool string, amount int) (func(), error) {
+	if b.limit <= 0 || amount <= 0 {
+		return func() {}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	models := b.used[pool]
+	total := 0
+	for _, held := range models {
+		total += held
+	}
+	if total+amount > b.limit {
+		return nil, fmt.Errorf("model '%s' needs %d %s of %s, but only %d %s of the %d %s budget of %s is free (held by %s)",
+			model, amount, b.unit, b.resource, b.limit-total, b.unit, b.limit, b.unit, pool, b.holders(models))
+	}
+	if models == nil {
+		models = make(map[string]int)
+		b.used[pool] = models
+	}
+	models[model] += amount
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if models[model] -= amount; models[model] <= 0 {
+				delete(models, model)
+			}
+		})
+	}, nil
+}
+
+// holders lists the models holding a pool, with how much
+func (b *resourceBudget) holders(models map[string]int) string {
+	if len(models) == 0 {
+		return "no model"
+	}
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := ""
+	for i, name := range names {
+		if i > 0 {
+			list += ", "
+		}
+		list += fmt.Sprintf("%s: %d %s", name, models[name], b.unit)
+	}
+	return list
+}
@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestResourceBudgets(t *testing.T) {
+	cuda := config.ModelConfig{Provider: string(ProviderMock), Languages: []string{"en"}, ExecutionProvider: "cuda", VRAMMB: 600}
+	registry := NewASRModelRegistry(&config.ASRConfig{
+		Provider:    "cpu",
+		NumThreads:  4,
+		GPUMemoryMB: 1000,
+		CPUBudget:   6,
+		Models: map[string]config.ModelConfig{
+			"large":  cuda,
+			"medium": cuda,
+			"small":  {Provider: string(ProviderMock), Languages: []string{"en"}, VRAMMB: 600},
+			"tiny":   {Provider: string(ProviderMock), Languages: []string{"en"}, NumThreads: 3},
+		},
+	})
+	registry.RegisterProviderType(ProviderMock, func(*config.ASRConfig, string, *config.ModelConfig) (domain.ASRProvider, error) {
+		return &MockASRProvider{}, nil
+	})
+
+	if _, err := registry.GetModel("large", "en"); err != nil {
+		t.Fatalf("GetModel failed: %v", err)
+	}
+	_, err := registry.GetModel("medium", "en")
+	if err == nil || !strings.Contains(err.Error(), "large: 600 MB") {
+		t.Fatalf("Expected a model beyond the GPU budget to be refused, got %v", err)
+	}
+	if registry.IsModelLoaded("medium") {
+		t.Error("Expected the refused model not to be loaded")
+	}
+	if _, err := registry.ReloadModel("large"); err == nil {
+		t.Error("Expected a reload without room for a second instance to be refused")
+	}
+
+	// Models on cpu take threads instead of GPU memory
+	if _, err := registry.GetModel("small", "en"); err != nil {
+		t.Fatalf("Expected a cpu model to load, got %v", err)
+	}
+	_, err = registry.GetModel("tiny", "en")
+	if err == nil || !strings.Contains(err.Error(), "small: 4 threads") {
+		t.Fatalf("Expected a model beyond the CPU budget to be refused, got %v", err)
+	}
+
+	// Closed models give their memory back
+	registry.Close()
+	if _, err := registry.GetModel("medium", "en"); err != nil {
+		t.Errorf("Expected the model to load once memory was freed, got %v", err)
+	}
+	if _, err := registry.GetModel("tiny", "en"); err != nil {
+		t.Errorf("Expected the model to load once threads were freed, got %v", err)
+	}
+
+	// fit_gomaxprocs leaves a CPU to the rest of the server
+	want := runtime.GOMAXPROCS(0) - 1
+	if want < 1 {
+		want = 1
+	}
+	if got := cpuBudget(&config.ASRConfig{CPUBudget: 1000, FitGOMAXPROCS: true}); got != want {
+		t.Errorf("Expected a budget of %d threads, got %d", want, got)
+	}
+}
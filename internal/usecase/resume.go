@@ -0,0 +1,267 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// replayBufferSize is how many recent server events a session keeps for
+// clients that resume it after a dropped connection
+const replayBufferSize = 512
+
+// errNotReadable is returned by ReadMessage of a session's sequencedConn,
+// whose client events are read from the connection currently serving it
+var errNotReadable = errors.New("session connection is not readable")
+
+// sequencedConn numbers the server events of a session with a "sequence"
+// field, names them as the session's protocol does, and keeps the most
+// recent for replay. It outlives the connections of
+// a resumable session: a client that resumes is attached in place of the
+// connection it lost, and events sent in between are held for replay.
+type sequencedConn struct {
+	mu       sync.Mutex
+	conn     Conn // Connection serving the session, nil while detached
+	protocol domain.Protocol
+	seq      uint64
+	replay   [replayBufferSize][]byte // Encoded events, indexed by sequence
+}
+
+// Delta is an encoded delta event, such as a transcript delta, as a session
+// writes it to its connection. The connection may hold deltas briefly to send
+// several in one frame.
+type Delta struct {
+	json.RawMessage
+}
+
+// isDelta reports whether a server event is a delta
+func isDelta(v interface{}) bool {
+	switch v.(type) {
+	case *domain.ResponseOutputTextDeltaEvent, *domain.ResponseOutputAudioTranscriptDeltaEvent,
+		*domain.ResponseOutputAudioDeltaEvent, *domain.ConversationItemInputAudioTranscriptionDeltaEvent:
+		return true
+	}
+	return false
+}
+
+func newSequencedConn(conn Conn, protocol domain.Protocol) *sequencedConn {
+	return &sequencedConn{conn: conn, protocol: protocol}
+}
+
+func (c *sequencedConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = withProtocolType(data, c.protocol)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	data = withSequence(data, c.seq)
+	c.replay[c.seq%replayBufferSize] = data
+	if c.conn == nil {
+		// Held for replay until the client resumes
+		return nil
+	}
+	if isDelta(v) {
+		return c.conn.WriteJSON(Delta{data})
+	}
+	return c.conn.WriteJSON(json.RawMessage(data))
+}
+
+func (c *sequencedConn) ReadMessage() (int, []byte, error) {
+	return 0, nil, errNotReadable
+}
+
+// Close closes the connection serving the session, if any
+func (c *sequencedConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// attach makes conn serve the session and replays to it the events after
+// lastSeq that are still buffered. It returns the connection it replaces, nil
+// if the session was detached, and how many events after lastSeq are lost.
+func (c *sequencedConn) attach(conn Conn, lastSeq uint64) (replaced Conn, lost uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	replaced, c.conn = c.conn, conn
+	first := uint64(1)
+	if c.seq > replayBufferSize {
+		first = c.seq - replayBufferSize + 1
+	}
+	if lastSeq+1 < first {
+		lost = first - lastSeq - 1
+		lastSeq = first - 1
+	}
+	for seq := lastSeq + 1; seq <= c.seq; seq++ {
+		if err := conn.WriteJSON(json.RawMessage(c.replay[seq%replayBufferSize])); err != nil {
+			break
+		}
+	}
+	return replaced, lost
+}
+
+// detach disconnects conn from the session and reports whether it was still
+// serving it, that is, whether it was not replaced by a resumed connection
+func (c *sequencedConn) detach(conn Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != conn {
+		return false
+	}
+	c.conn = nil
+	return true
+}
+
+func (c *sequencedConn) attached() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+// withProtocolType renames the encoded event as the protocol names it
+func withProtocolType(data []byte, protocol domain.Protocol) []byte {
+	if protocol != domain.Protocol2024 {
+		return data
+	}
+	var base domain.BaseEvent
+	if err := json.Unmarshal(data, &base); err != nil {
+		return data
+	}
+	renamed := protocol.ServerEventType(base.Type)
+	if renamed == base.Type {
+		return data
+	}
+	return bytes.Replace(data, []byte(`"type":"`+base.Type+`"`), []byte(`"type":"`+renamed+`"`), 1)
+}
+
+// withSequence adds a "sequence" field to an encoded JSON object
+func withSequence(data []byte, seq uint64) []byte {
+	if len(data) < 2 || data[len(data)-1] != '}' {
+		return data
+	}
+	out := make([]byte, 0, len(data)+32)
+	out = append(out, data[:len(data)-1]...)
+	if len(data) > 2 {
+		out = append(out, ',')
+	}
+	out = append(out, `"sequence":`...)
+	out = strconv.AppendUint(out, seq, 10)
+	return append(out, '}')
+}
+
+// liveSession ties a session to the connection serving it
+type liveSession struct {
+	state  *domain.SessionState
+	seq    *sequencedConn
+	conn   Conn // Passed to event handlers, writes through seq
+	cancel context.CancelFunc
+	drops  int // Times the session lost its connection, guarded by resumable.mu
+}
+
+// resumable tracks the live sessions a client may resume while the resume
+// window is enabled
+type resumable struct {
+	window   time.Duration // How long a disconnected session is kept
+	mu       sync.Mutex
+	sessions map[string]*liveSession
+}
+
+func newResumable(window time.Duration) *resumable {
+	return &resumable{window: window, sessions: make(map[string]*liveSession)}
+}
+
+func (r *resumable) add(s *liveSession) {
+	if r.window <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[s.state.ID] = s
+}
+
+func (r *resumable) remove(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, sessionID)
+}
+
+// resumeSession serves a reconnecting client with the session it lost. The
+// session must have been opened with the same credentials.
+func (u *SessionUsecase) resumeSession(conn Conn, opts ConnectOptions) {
+	u.resumable.mu.Lock()
+	s, ok := u.resumable.sessions[opts.ResumeSessionID]
+	if ok && (s.state.APIKey != opts.APIKey || s.state.Tenant != opts.Tenant) {
+		ok = false
+	}
+	var replaced Conn
+	var lost uint64
+	if ok {
+		replaced, lost = s.seq.attach(conn, opts.LastEventSequence)
+	}
+	u.resumable.mu.Unlock()
+
+	if !ok {
+		// Sessions of other credentials are reported as missing so IDs cannot be probed
+		u.sendError(conn, "", "invalid_request_error", domain.CodeSessionNotFound,
+			fmt.Sprintf("Session %s does not exist or can no longer be resumed", opts.ResumeSessionID), "session_id")
+		return
+	}
+	if replaced != nil {
+		// The client reconnected before the old connection was noticed as dropped
+		replaced.Close()
+	}
+	log.Printf("Session %s resumed after event %d", s.state.ID, opts.LastEventSequence)
+
+	if lost > 0 {
+		u.sendError(s.conn, "", "invalid_request_error", domain.CodeEventsLost,
+			fmt.Sprintf("%d events after sequence %d are no longer available", lost, opts.LastEventSequence), nil)
+	}
+	u.sendSessionEvent(s.conn, s.state, false)
+	u.serve(conn, s)
+}
+
+// release is called once conn stops serving a session. Sessions that can be
+// resumed are kept for the resume window, others end.
+func (u *SessionUsecase) release(conn Conn, s *liveSession) {
+	if u.resumable.window <= 0 {
+		u.endSession(s)
+		return
+	}
+
+	u.resumable.mu.Lock()
+	defer u.resumable.mu.Unlock()
+	if !s.seq.detach(conn) {
+		// Already served by a resumed connection
+		return
+	}
+	s.drops++
+	drop := s.drops
+	log.Printf("Session %s disconnected, resumable for %v", s.state.ID, u.resumable.window)
+	u.clock.AfterFunc(u.resumable.window, func() {
+		u.resumable.mu.Lock()
+		expired := s.drops == drop && !s.seq.attached() && u.resumable.sessions[s.state.ID] == s
+		if expired {
+			delete(u.resumable.sessions, s.state.ID)
+		}
+		u.resumable.mu.Unlock()
+		if expired {
+			u.endSession(s)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/clock"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestSequencedConn(t *testing.T) {
+	first := &recordingConn{}
+	c := newSequencedConn(first, domain.ProtocolDefault)
+	c.WriteJSON(map[string]string{"type": "session.created"})
+	c.WriteJSON(struct{}{})
+	if got := string(first.events[0].(json.RawMessage)); got != `{"type":"session.created","sequence":1}` {
+		t.Errorf("Unexpected first event %s", got)
+	}
+	if got := string(first.events[1].(json.RawMessage)); got != `{"sequence":2}` {
+		t.Errorf("Unexpected second event %s", got)
+	}
+
+	// Events sent while detached are held for replay, as far as the buffer reaches
+	if !c.detach(first) || c.detach(first) {
+		t.Fatal("Expected only the serving connection to detach")
+	}
+	for i := 0; i < replayBufferSize+100; i++ {
+		c.WriteJSON(struct{}{})
+	}
+	resumed := &recordingConn{}
+	replaced, lost := c.attach(resumed, 1)
+	if replaced != nil || lost != 101 {
+		t.Errorf("Expected no replaced connection and 101 lost events, got %v and %d", replaced, lost)
+	}
+	if len(resumed.events) != replayBufferSize {
+		t.Fatalf("Expected %d replayed events, got %d", replayBufferSize, len(resumed.events))
+	}
+	if got := string(resumed.events[0].(json.RawMessage)); got != `{"sequence":103}` {
+		t.Errorf("Expected replay to start after the lost events, got %s", got)
+	}
+
+	// Resuming while the old connection is still attached replaces it
+	if replaced, lost := c.attach(&recordingConn{}, c.seq); replaced != resumed || lost != 0 {
+		t.Errorf("Expected the resumed connection to be replaced, got %v and %d lost", replaced, lost)
+	}
+}
+
+func TestSequencedConnProtocol(t *testing.T) {
+	conn := &recordingConn{}
+	c := newSequencedConn(conn, domain.Protocol2024)
+	c.WriteJSON(&domain.ResponseOutputTextDeltaEvent{
+		BaseEvent: domain.BaseEvent{Type: domain.EventResponseOutputTextDelta},
+		Delta:     `"type":"response.output_text.delta"`,
+	})
+	c.WriteJSON(&domain.BaseEvent{Type: domain.EventSessionCreated})
+
+	// Deltas are tagged for the connection to batch
+	delta, ok := conn.events[0].(Delta)
+	if !ok {
+		t.Fatalf("Expected the delta written as a Delta, got %T", conn.events[0])
+	}
+	var first struct {
+		Type  domain.EventType
+		Delta string
+	}
+	if err := json.Unmarshal(delta.RawMessage, &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Type != "response.text.delta" || first.Delta != `"type":"response.output_text.delta"` {
+		t.Errorf("Expected only the event type renamed, got %+v", first)
+	}
+	if got := string(conn.events[1].(json.RawMessage)); got != `{"event_id":"","type":"session.created","sequence":2}` {
+		t.Errorf("Expected events the protocol does not rename unchanged, got %s", got)
+	}
+}
+
+func TestResumeWindowExpiry(t *testing.T) {
+	c := clock.NewFake(time.Unix(1735689600, 0))
+	uc := NewSessionUsecase()
+	uc.SetClock(c)
+	uc.resumable = newResumable(time.Minute)
+
+	// The connection drops at once, leaving the session resumable
+	uc.HandleNewConnectionWithOptions(&droppedConn{}, ConnectOptions{Intent: IntentTranscription})
+	sessions := uc.sessionManager.Sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("Expected the dropped session to be kept, got %d sessions", len(sessions))
+	}
+	if created := sessions[0].CreatedAt; !created.Equal(c.Now()) {
+		t.Errorf("Expected the session to be created at the clock's time, got %v", created)
+	}
+
+	c.Advance(time.Minute - time.Second)
+	if n := len(uc.sessionManager.Sessions()); n != 1 {
+		t.Fatalf("Expected the session to be resumable within the window, got %d sessions", n)
+	}
+	c.Advance(time.Second)
+	if n := len(uc.sessionManager.Sessions()); n != 0 {
+		t.Errorf("Expected the session to end with the window, got %d sessions", n)
+	}
+}
+
+// droppedConn is a connection whose client is gone
+type droppedConn struct {
+	recordingConn
+}
+
+func (c *droppedConn) ReadMessage() (int, []byte, error) { return 0, nil, io.EOF }
+
+func TestDuplicateClientEvents(t *testing.T) {
+	c := clock.NewFake(time.Unix(1735689600, 0))
+	uc := NewSessionUsecase()
+	uc.SetClock(c)
+	uc.dedupWindow = 30 * time.Second
+	state := uc.sessionManager.CreateSession("sess_1", "", "conv_1")
+	uc.stats.Store(state.ID, &sessionStats{})
+	conn := &recordingConn{}
+
+	create := []byte(`{"event_id":"evt_1","type":"conversation.item.create","item":{"type":"message","role":"user","content":[{"type":"input_text","text":"hello"}]}}`)
+	uc.ProcessMessage(conn, state, create)
+	var replies []string
+	for _, event := range conn.events {
+		data, _ := json.Marshal(event)
+		replies = append(replies, string(data))
+	}
+
+	// The retransmission is answered with the original events, not handled again
+	conn.events = nil
+	uc.ProcessMessage(conn, state, create)
+	if n := len(state.Conversation.Order); n != 1 {
+		t.Errorf("Expected the item created once, got %d items", n)
+	}
+	if len(conn.events) != len(replies) || len(replies) == 0 {
+		t.Fatalf("Expected the %d original replies again, got %d events", len(replies), len(conn.events))
+	}
+	for i, event := range conn.events {
+		if got := string(event.(json.RawMessage)); got != replies[i] {
+			t.Errorf("Expected reply %s again, got %s", replies[i], got)
+		}
+	}
+
+	// Other event IDs, events without one and events past the window are handled
+	uc.ProcessMessage(conn, state, []byte(`{"event_id":"evt_2","type":"conversation.item.create","item":{"type":"message","role":"user","content":[{"type":"input_text","text":"again"}]}}`))
+	uc.ProcessMessage(conn, state, []byte(`{"type":"conversation.item.create","item":{"type":"message","role":"user","content":[{"type":"input_text","text":"again"}]}}`))
+	c.Advance(30 * time.Second)
+	uc.ProcessMessage(conn, state, create)
+	if n := len(state.Conversation.Order); n != 4 {
+		t.Errorf("Expected 4 items, got %d", n)
+	}
+
+	// Audio appends reusing an event ID are all appended
+	for i := 0; i < 2; i++ {
+		uc.ProcessMessage(conn, state, []byte(`{"event_id":"evt_audio","type":"input_audio_buffer.append","audio":"AAAAAA=="}`))
+	}
+	if n := len(state.AudioBuffer.GetData()); n != 8 {
+		t.Errorf("Expected both appends buffered, got %d bytes", n)
+	}
+}
+
+func TestRejectedMessageSequenced(t *testing.T) {
+	uc := NewSessionUsecase()
+	conn := &rejectingConn{}
+	uc.HandleNewConnectionWithOptions(conn, ConnectOptions{Intent: IntentTranscription})
+
+	// The rejection is answered like any other event: numbered and kept for replay
+	var last struct {
+		Type     domain.EventType
+		Sequence uint64
+		Error    domain.ErrorDetail
+	}
+	if err := json.Unmarshal(conn.events[len(conn.events)-1].(json.RawMessage), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.Type != domain.EventError || last.Error.Code != domain.CodeRateLimitExceeded || last.Error.EventID != "evt_1" {
+		t.Errorf("Expected a rate limit error for evt_1, got %+v", last)
+	}
+	if last.Sequence != uint64(len(conn.events)) {
+		t.Errorf("Expected the error to carry sequence %d, got %d", len(conn.events), last.Sequence)
+	}
+}
+
+// rejectingConn rejects one client message, then drops
+type rejectingConn struct {
+	recordingConn
+	reads int
+}
+
+func (c *rejectingConn) ReadMessage() (int, []byte, error) {
+	c.reads++
+	if c.reads == 1 {
+		return 0, nil, &RejectedMessage{EventID: "evt_1", Type: "rate_limit_error",
+			Code: domain.CodeRateLimitExceeded, Message: "Event dropped", Param: "messages_per_second"}
+	}
+	return 0, nil, io.EOF
+}
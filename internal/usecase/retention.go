@@ -0,0 +1,294 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	recordings "github.com/aira-id/gribe/internal/pkg/recording"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Retention deletes stored conversations and session recordings once they
+// are older than their retention period. Both are kept for the
+// retention_days of the auth.keys entry that created them, else of their
+// tenant, else retention.days. Recordings made before recordings named their
+// owner are kept for the retention_days of the tenant recording to their
+// directory, else retention.days.
+type Retention struct {
+	conversations domain.ConversationStore // nil when conversations are not kept
+	eraser        *Eraser
+	days          int            // Default retention, 0 keeps data
+	tenantDays    map[string]int // Tenant ID -> days
+	keyDays       map[string]int // OwnerHash of an API key -> days
+	recordDirs    map[string]int // Recording directory -> days of recordings without an owner
+	interval      time.Duration
+	dryRun        bool
+	now           func() time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// RetentionReport lists the stored data a sweep deleted, or would delete
+type RetentionReport struct {
+	DryRun  bool                    `json:"dry_run"`
+	Deleted []domain.DeletionRecord `json:"deleted"`
+	Errors  []string                `json:"errors,omitempty"`
+}
+
+// expiredData is stored data past its retention period
+type expiredData struct {
+	record domain.DeletionRecord
+	remove func() error
+}
+
+// NewRetention creates the retention policy of cfg over the conversations in
+// store, which may be nil, and the recordings of record.dir and tenants'
+// record_dir. Deletions go through eraser.
+func NewRetention(cfg *config.Config, store domain.ConversationStore, eraser *Eraser) *Retention {
+	r := &Retention{
+		conversations: store,
+		eraser:        eraser,
+		days:          cfg.Retention.Days,
+		tenantDays:    make(map[string]int),
+		keyDays:       make(map[string]int),
+		recordDirs:    make(map[string]int),
+		interval:      cfg.Retention.Interval,
+		dryRun:        cfg.Retention.DryRun,
+		now:           time.Now,
+		stop:          make(chan struct{}),
+	}
+	for id, tenant := range cfg.Tenants {
+		if tenant.RetentionDays > 0 {
+			r.tenantDays[id] = tenant.RetentionDays
+		}
+	}
+	for _, key := range cfg.Auth.Keys {
+		if key.RetentionDays > 0 {
+			r.keyDays[domain.OwnerHash(key.Key)] = key.RetentionDays
+		}
+	}
+
+	// Without an owner, the recordings of tenants sharing a directory cannot
+	// be told apart, so the longest period wins and none go before their time
+	addDir := func(dir string, days int) {
+		if dir == "" {
+			return
+		}
+		dir = filepath.Clean(dir)
+		if kept, ok := r.recordDirs[dir]; !ok || kept > 0 && (days == 0 || days > kept) {
+			r.recordDirs[dir] = days
+		}
+	}
+	addDir(cfg.Record.Dir, r.days)
+	for id, tenant := range cfg.Tenants {
+		dir := tenant.RecordDir
+		if dir == "" {
+			dir = cfg.Record.Dir
+		}
+		addDir(dir, r.tenantRetention(id))
+	}
+	return r
+}
+
+// SetRetention enables the retention policy of stored data
+func (u *SessionUsecase) SetRetention(retention *Retention) {
+	u.retention = retention
+}
+
+// Retention returns the retention policy, nil when stored data is kept for ever
+func (u *SessionUsecase) Retention() *Retention {
+	return u.retention
+}
+
+// Start sweeps expired data every retention.interval until Close
+func (r *Retention) Start() {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.sweep()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// sweep runs a scheduled sweep and logs its outcome
+func (r *Retention) sweep() {
+	defer panics.Recover("retention sweep", nil)
+	report := r.Sweep(r.dryRun)
+	for _, err := range report.Errors {
+		log.Printf("[ERROR] Retention sweep: %s", err)
+	}
+	if len(report.Deleted) > 0 {
+		log.Printf("Retention sweep expired %d stored items (dry run: %v)", len(report.Deleted), report.DryRun)
+	}
+}
+
+// Close stops sweeping
+func (r *Retention) Close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// DryRun reports whether sweeps only report and audit what they would delete
+func (r *Retention) DryRun() bool {
+	return r.dryRun
+}
+
+// Expired lists the stored data past its retention period without deleting
+// or auditing anything
+func (r *Retention) Expired() RetentionReport {
+	expired, errs := r.expired()
+	report := RetentionReport{DryRun: true, Deleted: []domain.DeletionRecord{}, Errors: errs}
+	for _, data := range expired {
+		data.record.DryRun = true
+		report.Deleted = append(report.Deleted, data.record)
+	}
+	return report
+}
+
+// Sweep deletes the stored data past its retention period, auditing every
+// deletion. With dryRun nothing is deleted but the audit records are still
+// written, marked dry_run.
+func (r *Retention) Sweep(dryRun bool) RetentionReport {
+	expired, errs := r.expired()
+	report := RetentionReport{DryRun: dryRun, Deleted: []domain.DeletionRecord{}, Errors: errs}
+	for _, data := range expired {
+		data.record.DryRun = dryRun
+		if err := r.eraser.erase(&data.record, data.remove); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s %s: %v", data.record.Kind, data.record.ID, err))
+			continue
+		}
+		report.Deleted = append(report.Deleted, data.record)
+	}
+	return report
+}
+
+// expired collects the conversations and recordings past their retention
+// period, and the errors of listing them
+func (r *Retention) expired() ([]expiredData, []string) {
+	var expired []expiredData
+	var errs []string
+	now := r.now()
+
+	if r.conversations != nil {
+		conversations, err := r.conversations.List()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("listing conversations: %v", err))
+		}
+		for _, conversation := range conversations {
+			days := r.conversationRetention(conversation)
+			if !isExpired(time.Unix(conversation.EndedAt, 0), days, now) {
+				continue
+			}
+			id := conversation.ID
+			expired = append(expired, expiredData{
+				record: domain.DeletionRecord{
+					Kind:      domain.StoredConversationKind,
+					ID:        id,
+					SessionID: conversation.SessionID,
+					Owner:     conversation.Owner,
+					TenantID:  conversation.TenantID,
+					CreatedAt: conversation.CreatedAt,
+					Reason:    domain.DeletionReasonRetention,
+				},
+				remove: func() error { return r.conversations.Delete(id) },
+			})
+		}
+	}
+
+	shortest := r.shortestRetention()
+	for dir, dirDays := range r.recordDirs {
+		if shortest == 0 {
+			break
+		}
+		files, err := recordings.Files(dir)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("listing recordings in %s: %v", dir, err))
+			continue
+		}
+		for _, file := range files {
+			if !isExpired(file.ModTime, shortest, now) {
+				continue // Not expired under any period, no need to read it
+			}
+			recorded, err := recordings.ReadSession(file.Path)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("reading recording %s: %v", file.Path, err))
+				continue
+			}
+			days := dirDays
+			if recorded.Owner != "" {
+				days = r.retention(recorded.Owner, recorded.TenantID)
+			}
+			if !isExpired(file.ModTime, days, now) {
+				continue
+			}
+			path := file.Path
+			expired = append(expired, expiredData{
+				record: domain.DeletionRecord{
+					Kind:      domain.StoredRecordingKind,
+					ID:        filepath.Base(path),
+					SessionID: recorded.ID,
+					Owner:     recorded.Owner,
+					TenantID:  recorded.TenantID,
+					CreatedAt: file.ModTime.Unix(),
+					Reason:    domain.DeletionReasonRetention,
+				},
+				remove: func() error { return os.Remove(path) },
+			})
+		}
+	}
+	return expired, errs
+}
+
+// shortestRetention returns the shortest retention period of any data, 0
+// when all data is kept for ever
+func (r *Retention) shortestRetention() int {
+	shortest := r.days
+	for _, periods := range []map[string]int{r.tenantDays, r.keyDays, r.recordDirs} {
+		for _, days := range periods {
+			if days > 0 && (shortest == 0 || days < shortest) {
+				shortest = days
+			}
+		}
+	}
+	return shortest
+}
+
+// conversationRetention returns the days a conversation is kept, 0 for ever
+func (r *Retention) conversationRetention(conversation *domain.StoredConversation) int {
+	return r.retention(conversation.Owner, conversation.TenantID)
+}
+
+// retention returns the days the data of an owner, a domain.OwnerHash, and
+// tenant is kept, 0 for ever
+func (r *Retention) retention(owner, tenantID string) int {
+	if days, ok := r.keyDays[owner]; ok {
+		return days
+	}
+	return r.tenantRetention(tenantID)
+}
+
+// tenantRetention returns the days a tenant's data is kept, 0 for ever
+func (r *Retention) tenantRetention(tenantID string) int {
+	if days, ok := r.tenantDays[tenantID]; ok {
+		return days
+	}
+	return r.days
+}
+
+// isExpired reports whether data last written at t is past a retention
+// period of days as of now. A period of 0 days never expires.
+func isExpired(t time.Time, days int, now time.Time) bool {
+	return days > 0 && now.Sub(t) >= time.Duration(days)*24*time.Hour
+}
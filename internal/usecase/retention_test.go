@@ -0,0 +1,167 @@
+package usecase
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/convstore"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestRetentionSweep(t *testing.T) {
+	now := time.Now()
+	daysAgo := func(days int) time.Time { return now.Add(-time.Duration(days)*24*time.Hour - time.Minute) }
+
+	cfg := &config.Config{
+		Retention: config.RetentionConfig{Days: 30, Interval: time.Hour},
+		Auth:      config.AuthConfig{Keys: []config.APIKeyConfig{{Key: "short-key", RetentionDays: 1}}},
+		Tenants:   map[string]config.TenantConfig{"acme": {RetentionDays: 7, RecordDir: t.TempDir()}},
+		Record:    config.RecordConfig{Dir: t.TempDir()},
+	}
+
+	store := convstore.NewMemory(10)
+	for _, conversation := range []*domain.StoredConversation{
+		{ID: "conv_default_old", SessionID: "sess_1", EndedAt: daysAgo(31).Unix()},
+		{ID: "conv_default_new", SessionID: "sess_2", EndedAt: daysAgo(8).Unix()},
+		{ID: "conv_tenant_old", SessionID: "sess_3", EndedAt: daysAgo(8).Unix(), TenantID: "acme"},
+		{ID: "conv_key_old", SessionID: "sess_4", EndedAt: daysAgo(2).Unix(), TenantID: "acme", Owner: domain.OwnerHash("short-key")},
+		{ID: "conv_key_new", SessionID: "sess_5", EndedAt: now.Unix(), Owner: domain.OwnerHash("short-key")},
+	} {
+		if err := store.Save(conversation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeRecording := func(dir, name string, modTime time.Time, header ...string) string {
+		path := filepath.Join(dir, name)
+		content := "{}\n"
+		if len(header) > 0 {
+			content = `{"direction":"header","event":` + header[0] + "}\n"
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	defaultOld := writeRecording(cfg.Record.Dir, "session-old.jsonl", daysAgo(31))
+	defaultNew := writeRecording(cfg.Record.Dir, "session-new.jsonl", daysAgo(8))
+	tenantOld := writeRecording(cfg.Tenants["acme"].RecordDir, "session-old.jsonl", daysAgo(8))
+	unrelated := writeRecording(cfg.Tenants["acme"].RecordDir, "notes.txt", daysAgo(100))
+
+	// Recordings naming their owner are kept for the owner's period, wherever they are
+	keyOld := writeRecording(cfg.Record.Dir, "session-key.jsonl", daysAgo(2), `{"owner":"`+domain.OwnerHash("short-key")+`"}`)
+	tenantInDefault := writeRecording(cfg.Record.Dir, "session-tenant.jsonl", daysAgo(8), `{"owner":"x","tenant_id":"acme"}`)
+	keyNew := writeRecording(cfg.Record.Dir, "session-key-new.jsonl", now, `{"owner":"`+domain.OwnerHash("short-key")+`"}`)
+
+	auditFile := filepath.Join(t.TempDir(), "audit.jsonl")
+	cfg.Retention.AuditFile = auditFile
+	eraser, err := NewEraserWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eraser.Close()
+	retention := NewRetention(cfg, store, eraser)
+
+	expected := []string{"conv_default_old", "conv_key_old", "conv_tenant_old",
+		"session-key.jsonl", "session-old.jsonl", "session-old.jsonl", "session-tenant.jsonl"}
+	ids := func(records []domain.DeletionRecord) []string {
+		var ids []string
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	// A report and a dry run delete nothing; only the dry run is audited
+	report := retention.Expired()
+	if got := ids(report.Deleted); !equalStrings(got, expected) {
+		t.Errorf("Expected %v to be expired, got %v", expected, got)
+	}
+	report = retention.Sweep(true)
+	if !report.DryRun || !equalStrings(ids(report.Deleted), expected) {
+		t.Errorf("Expected a dry run of %v, got %+v", expected, report)
+	}
+	if conversations, _ := store.List(); len(conversations) != 5 {
+		t.Errorf("Expected a dry run to keep all 5 conversations, got %d", len(conversations))
+	}
+	for _, path := range []string{defaultOld, tenantOld} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected a dry run to keep %s: %v", path, err)
+		}
+	}
+
+	report = retention.Sweep(false)
+	if report.DryRun || len(report.Errors) > 0 || !equalStrings(ids(report.Deleted), expected) {
+		t.Errorf("Expected %v to be deleted, got %+v", expected, report)
+	}
+	for _, id := range []string{"conv_default_old", "conv_tenant_old", "conv_key_old", "sess_1"} {
+		if _, err := store.Get(id); err != domain.ErrConversationNotFound {
+			t.Errorf("Expected %s to be deleted, got %v", id, err)
+		}
+	}
+	for _, id := range []string{"conv_default_new", "conv_key_new"} {
+		if _, err := store.Get(id); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", id, err)
+		}
+	}
+	for path, kept := range map[string]bool{
+		defaultOld: false, tenantOld: false, keyOld: false, tenantInDefault: false,
+		defaultNew: true, keyNew: true, unrelated: true,
+	} {
+		if _, err := os.Stat(path); (err == nil) != kept {
+			t.Errorf("Expected %s kept=%v, got %v", path, kept, err)
+		}
+	}
+	if report := retention.Sweep(false); len(report.Deleted) != 0 {
+		t.Errorf("Expected nothing left to delete, got %+v", report.Deleted)
+	}
+
+	// Every deletion is audited, dry runs marked as such
+	file, err := os.Open(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var dryRuns, deletions int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record domain.DeletionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid audit record %q: %v", scanner.Text(), err)
+		}
+		if record.Reason != domain.DeletionReasonRetention || record.Time == 0 {
+			t.Errorf("Unexpected audit record %+v", record)
+		}
+		if record.DryRun {
+			dryRuns++
+		} else {
+			deletions++
+		}
+	}
+	if dryRuns != len(expected) || deletions != len(expected) {
+		t.Errorf("Expected %d dry-run and %d deletion audit records, got %d and %d",
+			len(expected), len(expected), dryRuns, deletions)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
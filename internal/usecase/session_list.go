@@ -0,0 +1,131 @@
+package usecase
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// SessionInfo describes a live session to operators
+type SessionInfo struct {
+	ID              string  `json:"id"`
+	Type            string  `json:"type"`               // "realtime" or "transcription"
+	Model           string  `json:"model,omitempty"`    // Transcription model
+	Provider        string  `json:"provider,omitempty"` // Provider the model runs on, e.g. "sherpa-onnx"
+	APIKey          string  `json:"api_key"`
+	TenantID        string  `json:"tenant_id,omitempty"`
+	ClientIP        string  `json:"client_ip,omitempty"`
+	CreatedAt       int64   `json:"created_at"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	IdleSeconds     float64 `json:"idle_seconds"` // Since the client's latest event
+	Items           int     `json:"items"`
+	AudioSeconds    float64 `json:"audio_seconds"` // Transcribed so far
+	BufferedBytes   int     `json:"buffered_bytes"`
+
+	Metadata map[string]string `json:"metadata,omitempty"` // Set by the client
+}
+
+// SessionFilter selects and orders live sessions; zero fields match all
+type SessionFilter struct {
+	APIKey      string
+	Model       string
+	ClientIP    string
+	MinDuration time.Duration
+	MinIdle     time.Duration
+	Sort        string // One of SessionSortFields, "created_at" when empty
+	Ascending   bool   // Sort smallest first instead of largest
+}
+
+// SessionSortFields are the fields sessions can be sorted by
+var SessionSortFields = map[string]func(a, b *SessionInfo) bool{
+	"created_at":    func(a, b *SessionInfo) bool { return a.CreatedAt < b.CreatedAt },
+	"duration":      func(a, b *SessionInfo) bool { return a.DurationSeconds < b.DurationSeconds },
+	"idle":          func(a, b *SessionInfo) bool { return a.IdleSeconds < b.IdleSeconds },
+	"items":         func(a, b *SessionInfo) bool { return a.Items < b.Items },
+	"audio_seconds": func(a, b *SessionInfo) bool { return a.AudioSeconds < b.AudioSeconds },
+}
+
+// SessionSummary counts the live sessions
+type SessionSummary struct {
+	Total      int            `json:"total"`
+	ByModel    map[string]int `json:"by_model"`    // "" counts sessions without a model yet
+	ByProvider map[string]int `json:"by_provider"` // "" counts sessions without a model yet
+}
+
+// Sessions lists the live sessions matching filter, in its order
+func (u *SessionUsecase) Sessions(filter SessionFilter) ([]SessionInfo, error) {
+	less, ok := SessionSortFields[filter.Sort]
+	if filter.Sort == "" {
+		less, ok = SessionSortFields["created_at"], true
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown sort field %q", filter.Sort)
+	}
+
+	now := u.clock.Now()
+	sessions := []SessionInfo{}
+	for _, state := range u.sessionManager.Sessions() {
+		info := u.sessionInfo(state, now)
+		if (filter.APIKey != "" && info.APIKey != filter.APIKey) ||
+			(filter.Model != "" && info.Model != filter.Model) ||
+			(filter.ClientIP != "" && info.ClientIP != filter.ClientIP) ||
+			info.DurationSeconds < filter.MinDuration.Seconds() ||
+			info.IdleSeconds < filter.MinIdle.Seconds() {
+			continue
+		}
+		sessions = append(sessions, info)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if filter.Ascending {
+			return less(&sessions[i], &sessions[j])
+		}
+		return less(&sessions[j], &sessions[i])
+	})
+	return sessions, nil
+}
+
+// Summary counts the live sessions by model and provider
+func (u *SessionUsecase) Summary() SessionSummary {
+	summary := SessionSummary{ByModel: make(map[string]int), ByProvider: make(map[string]int)}
+	now := u.clock.Now()
+	for _, state := range u.sessionManager.Sessions() {
+		info := u.sessionInfo(state, now)
+		summary.Total++
+		summary.ByModel[info.Model]++
+		summary.ByProvider[info.Provider]++
+	}
+	return summary
+}
+
+// sessionInfo describes a live session at now
+func (u *SessionUsecase) sessionInfo(state *domain.SessionState, now time.Time) SessionInfo {
+	info := SessionInfo{
+		ID:              state.ID,
+		Type:            state.Config.Type,
+		APIKey:          state.APIKey,
+		ClientIP:        state.ClientIP,
+		CreatedAt:       state.CreatedAt.Unix(),
+		DurationSeconds: now.Sub(state.CreatedAt).Seconds(),
+		IdleSeconds:     state.Idle(now).Seconds(),
+		Items:           state.Conversation.Len(),
+		BufferedBytes:   state.AudioBuffer.GetSize(),
+		Metadata:        state.Config.Metadata,
+	}
+	if state.Tenant != nil {
+		info.TenantID = state.Tenant.ID
+	}
+	if audio := state.Config.Audio; audio != nil && audio.Input != nil && audio.Input.Transcription != nil {
+		info.Model = audio.Input.Transcription.Model
+	}
+	if info.Model != "" && u.asrRegistry != nil {
+		info.Provider = u.asrRegistry.GetModelProvider(info.Model)
+	}
+	if stats := u.sessionStats(state.ID); stats != nil {
+		stats.mu.Lock()
+		info.AudioSeconds = stats.usage.AudioTranscribedSeconds
+		stats.mu.Unlock()
+	}
+	return info
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestSessions(t *testing.T) {
+	uc := NewSessionUsecase()
+	now := time.Now()
+	for _, s := range []struct {
+		id, key, ip, model string
+		age, idle          time.Duration
+	}{
+		{"sess_new", "key-a", "10.0.0.1", "whisper", time.Minute, 0},
+		{"sess_old", "key-a", "10.0.0.2", "zipformer", time.Hour, 0},
+		{"sess_idle", "key-b", "10.0.0.1", "zipformer", 10 * time.Minute, 5 * time.Minute},
+	} {
+		state := uc.sessionManager.CreateTranscriptionSession(s.id, "model", "conv_"+s.id, "en")
+		state.APIKey, state.ClientIP, state.CreatedAt = s.key, s.ip, now.Add(-s.age)
+		state.Config.Audio = &domain.AudioConfig{Input: &domain.AudioInput{Transcription: &domain.TranscriptionConfig{Model: s.model}}}
+		if s.idle == 0 {
+			state.Touch(now)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		filter SessionFilter
+		want   []string
+	}{
+		{"newest first", SessionFilter{}, []string{"sess_new", "sess_idle", "sess_old"}},
+		{"by key, oldest first", SessionFilter{APIKey: "key-a", Ascending: true}, []string{"sess_old", "sess_new"}},
+		{"by model and ip", SessionFilter{Model: "zipformer", ClientIP: "10.0.0.1"}, []string{"sess_idle"}},
+		{"long running", SessionFilter{MinDuration: 5 * time.Minute, Sort: "duration"}, []string{"sess_old", "sess_idle"}},
+		{"idle", SessionFilter{MinIdle: time.Minute}, []string{"sess_idle"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sessions, err := uc.Sessions(tc.filter)
+			if err != nil {
+				t.Fatalf("Sessions failed: %v", err)
+			}
+			var got []string
+			for _, info := range sessions {
+				got = append(got, info.ID)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("Expected %v, got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("Expected %v, got %v", tc.want, got)
+				}
+			}
+		})
+	}
+
+	if _, err := uc.Sessions(SessionFilter{Sort: "color"}); err == nil {
+		t.Error("Expected an unknown sort field to be refused")
+	}
+	summary := uc.Summary()
+	if summary.Total != 3 || summary.ByModel["zipformer"] != 2 || summary.ByModel["whisper"] != 1 {
+		t.Errorf("Expected 3 sessions, 2 on zipformer, got %+v", summary)
+	}
+}
@@ -3,22 +3,25 @@ package usecase
 import (
 	"fmt"
 	"sync"
-	"time"
 
-	"github.com/aira-id/gribe/internal/domain"
-	"github.com/google/uuid"
+	"github.com/aira-id/gribe/internal/pkg/clock"
+	"github.com/aira-id/gribe/internal/pkg/ids"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
 // SessionManager handles session lifecycle and state
 type SessionManager struct {
 	sessions map[string]*domain.SessionState
 	mu       sync.RWMutex
+	clock    clock.Clock // Stamps creation and activity
 }
 
 // NewSessionManager creates a new session manager
 func NewSessionManager() *SessionManager {
 	return &SessionManager{
 		sessions: make(map[string]*domain.SessionState),
+		clock:    clock.Real{},
 	}
 }
 
@@ -27,14 +30,15 @@ func (sm *SessionManager) CreateSession(sessionID, model, conversationID string)
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	now := sm.clock.Now()
 	state := &domain.SessionState{
 		ID:              sessionID,
 		Config:          domain.NewSession(sessionID, model),
 		Conversation:    domain.NewConversationState(conversationID),
 		AudioBuffer:     NewAudioBuffer(),
 		CurrentResponse: nil,
-		CreatedAt:       time.Now(),
-		LastActivity:    time.Now(),
+		CreatedAt:       now,
+		LastActivity:    now,
 	}
 
 	sm.sessions[sessionID] = state
@@ -46,14 +50,15 @@ func (sm *SessionManager) CreateTranscriptionSession(sessionID, model, conversat
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	now := sm.clock.Now()
 	state := &domain.SessionState{
 		ID:              sessionID,
 		Config:          domain.NewTranscriptionSession(sessionID, model, language),
 		Conversation:    domain.NewConversationState(conversationID),
 		AudioBuffer:     NewAudioBuffer(),
 		CurrentResponse: nil,
-		CreatedAt:       time.Now(),
-		LastActivity:    time.Now(),
+		CreatedAt:       now,
+		LastActivity:    now,
 	}
 
 	sm.sessions[sessionID] = state
@@ -71,12 +76,16 @@ func (sm *SessionManager) GetSession(sessionID string) (*domain.SessionState, er
 	}
 
 	// Update last activity
-	state.LastActivity = time.Now()
+	state.LastActivity = sm.clock.Now()
 	return state, nil
 }
 
-// UpdateSession updates session configuration
-func (sm *SessionManager) UpdateSession(sessionID string, updates *domain.Session) (*domain.SessionState, error) {
+// UpdateSession updates session configuration. Non-empty fields override;
+// fields listed in sent are applied even when null or empty, so clients can
+// clear instructions or disable turn detection. A nil sent keeps the plain
+// non-empty merge. Turn detection settings left out of an update take their
+// defaults, and the merged session is normalized.
+func (sm *SessionManager) UpdateSession(sessionID string, updates *domain.Session, sent domain.SessionFields) (*domain.SessionState, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
@@ -85,25 +94,40 @@ func (sm *SessionManager) UpdateSession(sessionID string, updates *domain.Sessio
 		return nil, fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	// Merge updates (non-empty fields override)
+	if updates.Audio != nil && updates.Audio.Input != nil && updates.Audio.Input.TurnDetection != nil {
+		updates.Audio.Input.TurnDetection.FillDefaults(domain.DefaultTurnDetection(state.Config.Type), sent,
+			"audio.input.turn_detection")
+	}
+
+	// Merge updates (non-empty or explicitly sent fields override)
 	if updates.Type != "" {
 		state.Config.Type = updates.Type
 	}
-	if updates.Instructions != "" {
+	if updates.Instructions != "" || sent.Sent("instructions") {
 		state.Config.Instructions = updates.Instructions
 	}
 	if updates.Tools != nil {
 		state.Config.Tools = updates.Tools
+	} else if sent.Null("tools") {
+		state.Config.Tools = []domain.Tool{}
 	}
 	if updates.ToolChoice != "" {
 		state.Config.ToolChoice = updates.ToolChoice
 	}
 	if updates.MaxOutputTokens != nil {
 		state.Config.MaxOutputTokens = updates.MaxOutputTokens
+	} else if sent.Null("max_output_tokens") {
+		state.Config.MaxOutputTokens = "inf"
 	}
 	if updates.Temperature > 0 {
 		state.Config.Temperature = updates.Temperature
 	}
+	if updates.Tracing != nil || sent.Sent("tracing") {
+		state.Config.Tracing = updates.Tracing
+	}
+	if updates.Prompt != nil || sent.Sent("prompt") {
+		state.Config.Prompt = updates.Prompt
+	}
 	if updates.Audio != nil {
 		// Deep merge audio config
 		if state.Config.Audio == nil {
@@ -117,18 +141,21 @@ func (sm *SessionManager) UpdateSession(sessionID string, updates *domain.Sessio
 					if updates.Audio.Input.Format != nil {
 						state.Config.Audio.Input.Format = updates.Audio.Input.Format
 					}
-					if updates.Audio.Input.Transcription != nil {
+					if updates.Audio.Input.Transcription != nil || sent.Null("audio.input.transcription") {
 						state.Config.Audio.Input.Transcription = updates.Audio.Input.Transcription
 					}
-					if updates.Audio.Input.NoiseReduction != nil {
+					if updates.Audio.Input.NoiseReduction != nil || sent.Null("audio.input.noise_reduction") {
 						state.Config.Audio.Input.NoiseReduction = updates.Audio.Input.NoiseReduction
 					}
-					if updates.Audio.Input.TurnDetection != nil {
+					if updates.Audio.Input.EchoCancellation != nil || sent.Null("audio.input.echo_cancellation") {
+						state.Config.Audio.Input.EchoCancellation = updates.Audio.Input.EchoCancellation
+					}
+					if updates.Audio.Input.TurnDetection != nil || sent.Null("audio.input.turn_detection") {
 						state.Config.Audio.Input.TurnDetection = updates.Audio.Input.TurnDetection
 					}
 				}
 			}
-			if updates.Audio.Output != nil {
+			if updates.Audio.Output != nil || sent.Null("audio.output") {
 				state.Config.Audio.Output = updates.Audio.Output
 			}
 		}
@@ -136,14 +163,48 @@ func (sm *SessionManager) UpdateSession(sessionID string, updates *domain.Sessio
 	if len(updates.OutputModalities) > 0 {
 		state.Config.OutputModalities = updates.OutputModalities
 	}
-	if len(updates.Include) > 0 {
+	if len(updates.Include) > 0 || sent.Sent("include") {
 		state.Config.Include = updates.Include
 	}
+	if updates.TranscriptionDeltas != nil || sent.Sent("transcription_deltas") {
+		state.Config.TranscriptionDeltas = updates.TranscriptionDeltas
+	}
+	if updates.BufferWindowMs > 0 || sent.Sent("buffer_window_ms") {
+		state.Config.BufferWindowMs = updates.BufferWindowMs
+	}
+	if updates.StatsIntervalMs > 0 || sent.Sent("stats_interval_ms") {
+		state.Config.StatsIntervalMs = updates.StatsIntervalMs
+	}
+	if updates.Diarization || sent.Sent("diarization") {
+		state.Config.Diarization = updates.Diarization
+	}
+	if updates.AudioQuality || sent.Sent("audio_quality") {
+		state.Config.AudioQuality = updates.AudioQuality
+	}
+	if updates.ChunkedCommits || sent.Sent("chunked_commits") {
+		state.Config.ChunkedCommits = updates.ChunkedCommits
+	}
+	if updates.Metadata != nil || sent.Sent("metadata") {
+		state.Config.Metadata = updates.Metadata
+	}
+	state.Config.Normalize()
 
-	state.LastActivity = time.Now()
+	state.LastActivity = sm.clock.Now()
 	return state, nil
 }
 
+// Sessions returns the live sessions
+func (sm *SessionManager) Sessions() []*domain.SessionState {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	sessions := make([]*domain.SessionState, 0, len(sm.sessions))
+	for _, state := range sm.sessions {
+		sessions = append(sessions, state)
+	}
+	return sessions
+}
+
 // DeleteSession removes a session
 func (sm *SessionManager) DeleteSession(sessionID string) {
 	sm.mu.Lock()
@@ -178,41 +239,84 @@ func NewAudioBufferWithMaxSize(maxSize int) *domain.AudioBuffer {
 // ID GENERATORS
 // ============================================================================
 
-// IDGenerator generates unique IDs using UUIDs
-// This ensures globally unique IDs even across server restarts and distributed systems
-type IDGenerator struct{}
+// defaultIDPrefixes name the kind of object each ID is of
+var defaultIDPrefixes = map[string]string{
+	"session":      "sess_",
+	"conversation": "conv_",
+	"item":         "item_",
+	"response":     "resp_",
+	"event":        "evt_",
+	"speaker":      "spk_",
+	"deletion":     "del_",
+}
+
+// IDGenerator generates unique IDs: a prefix naming the kind of object
+// followed by a unique part from the configured strategy, by default
+// shortened random UUIDs, which stay unique across server restarts and
+// distributed systems
+type IDGenerator struct {
+	gen      ids.Generator
+	prefixes map[string]string
+}
 
-// NewIDGenerator creates a new ID generator
+// NewIDGenerator creates an ID generator with the default strategy and prefixes
 func NewIDGenerator() *IDGenerator {
-	return &IDGenerator{}
+	return &IDGenerator{gen: ids.ShortUUID{}, prefixes: defaultIDPrefixes}
 }
 
-// generateShortUUID generates a shortened UUID (first 12 chars)
-func generateShortUUID() string {
-	return uuid.New().String()[:12]
+// NewIDGeneratorWithConfig creates an ID generator of cfg's strategy, with
+// its prefixes replacing the defaults
+func NewIDGeneratorWithConfig(cfg *config.IDConfig) (*IDGenerator, error) {
+	gen, err := ids.New(cfg.Strategy, cfg.Node)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := make(map[string]string, len(defaultIDPrefixes))
+	for kind, prefix := range defaultIDPrefixes {
+		prefixes[kind] = prefix
+	}
+	for kind, prefix := range cfg.Prefixes {
+		prefixes[kind] = prefix
+	}
+	return &IDGenerator{gen: gen, prefixes: prefixes}, nil
+}
+
+// generate returns a new ID of an object of kind
+func (gen *IDGenerator) generate(kind string) string {
+	return gen.prefixes[kind] + gen.gen.New(kind)
 }
 
 // GenerateSessionID generates a unique session ID
 func (gen *IDGenerator) GenerateSessionID() string {
-	return "sess_" + generateShortUUID()
+	return gen.generate("session")
 }
 
 // GenerateConversationID generates a unique conversation ID
 func (gen *IDGenerator) GenerateConversationID() string {
-	return "conv_" + generateShortUUID()
+	return gen.generate("conversation")
 }
 
 // GenerateItemID generates a unique item ID
 func (gen *IDGenerator) GenerateItemID() string {
-	return "item_" + generateShortUUID()
+	return gen.generate("item")
 }
 
 // GenerateResponseID generates a unique response ID
 func (gen *IDGenerator) GenerateResponseID() string {
-	return "resp_" + generateShortUUID()
+	return gen.generate("response")
 }
 
 // GenerateEventID generates a unique event ID
 func (gen *IDGenerator) GenerateEventID() string {
-	return "evt_" + generateShortUUID()
+	return gen.generate("event")
+}
+
+// GenerateSpeakerID generates a unique speaker profile ID
+func (gen *IDGenerator) GenerateSpeakerID() string {
+	return gen.generate("speaker")
+}
+
+// GenerateDeletionID generates a unique deletion receipt ID
+func (gen *IDGenerator) GenerateDeletionID() string {
+	return gen.generate("deletion")
 }
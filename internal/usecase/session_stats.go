@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aira-id/gribe/internal/pkg/clock"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// sessionStats accumulates a session's transcription statistics and usage,
+// and schedules its session.stats events
+type sessionStats struct {
+	mu             sync.Mutex
+	usage          domain.SessionUsage
+	realTimeFactor float64     // Of the latest transcription
+	timer          clock.Timer // Sends the next session.stats, nil when not requested
+}
+
+// sessionStats returns the statistics of a live session, nil once it ended
+func (u *SessionUsecase) sessionStats(sessionID string) *sessionStats {
+	stats, ok := u.stats.Load(sessionID)
+	if !ok {
+		return nil
+	}
+	return stats.(*sessionStats)
+}
+
+// recordTranscription accounts the transcription of audioSeconds of audio
+// into transcript, returning its usage. took is how long the provider
+// needed, 0 for transcripts from the cache.
+func (u *SessionUsecase) recordTranscription(state *domain.SessionState, audioSeconds float64, transcript string, took time.Duration) *domain.Usage {
+	usage := transcriptionUsage(audioSeconds, transcript)
+	stats := u.sessionStats(state.ID)
+	if stats == nil {
+		return usage
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.usage.AudioTranscribedSeconds += audioSeconds
+	stats.usage.Transcriptions++
+	stats.usage.TranscriptCharacters += utf8.RuneCountInString(transcript)
+	stats.addTokens(usage)
+	if took > 0 && audioSeconds > 0 {
+		stats.realTimeFactor = took.Seconds() / audioSeconds
+	}
+	return usage
+}
+
+// recordResponse accounts a response of the session
+func (u *SessionUsecase) recordResponse(state *domain.SessionState, usage *domain.Usage) {
+	stats := u.sessionStats(state.ID)
+	if stats == nil {
+		return
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.usage.Responses++
+	stats.addTokens(usage)
+}
+
+// recordInputAudio accounts seconds of audio appended by the client
+func (u *SessionUsecase) recordInputAudio(state *domain.SessionState, seconds float64) {
+	if stats := u.sessionStats(state.ID); stats != nil {
+		stats.mu.Lock()
+		stats.usage.AudioInputSeconds += seconds
+		stats.mu.Unlock()
+	}
+}
+
+// countEvent counts an event received from (client) or sent to the client.
+// It is safe to call on nil stats.
+func (s *sessionStats) countEvent(client bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if client {
+		s.usage.ClientEvents++
+	} else {
+		s.usage.ServerEvents++
+	}
+}
+
+// addTokens adds usage's tokens to the session's. The caller holds s.mu.
+func (s *sessionStats) addTokens(usage *domain.Usage) {
+	s.usage.InputTokens += usage.InputTokens
+	s.usage.OutputTokens += usage.OutputTokens
+	s.usage.TotalTokens += usage.TotalTokens
+}
+
+// Usage returns the usage of a live session so far
+func (u *SessionUsecase) Usage(sessionID string) (domain.SessionUsage, bool) {
+	stats := u.sessionStats(sessionID)
+	if stats == nil {
+		return domain.SessionUsage{}, false
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	return stats.usage, true
+}
+
+// scheduleStats (re)starts the session's session.stats events at its
+// stats_interval_ms, or stops them when it is 0
+func (u *SessionUsecase) scheduleStats(conn Conn, state *domain.SessionState) {
+	stats := u.sessionStats(state.ID)
+	if stats == nil {
+		return
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	if stats.timer != nil {
+		stats.timer.Stop()
+		stats.timer = nil
+	}
+	interval := time.Duration(state.Config.StatsIntervalMs) * time.Millisecond
+	if interval <= 0 {
+		return
+	}
+
+	var timer clock.Timer
+	timer = u.clock.AfterFunc(interval, func() {
+		stats.mu.Lock()
+		if stats.timer != timer || state.Context().Err() != nil {
+			// Rescheduled or ended meanwhile
+			stats.mu.Unlock()
+			return
+		}
+		event := &domain.SessionStatsEvent{
+			BaseEvent: domain.BaseEvent{
+				EventID: u.idGen.GenerateEventID(),
+				Type:    domain.EventSessionStats,
+			},
+			BufferedBytes:  state.AudioBuffer.GetSize(),
+			AudioSeconds:   stats.usage.AudioTranscribedSeconds,
+			Items:          state.Conversation.Len(),
+			RealTimeFactor: stats.realTimeFactor,
+		}
+		timer.Reset(interval)
+		stats.mu.Unlock()
+		conn.WriteJSON(event)
+	})
+	stats.timer = timer
+}
+
+// reportUsage passes session.closed with the usage of an ending session to
+// the observers and the session's subscribers, and exports it to the usage
+// sink. Sessions end once their client is gone, so it is not written to the
+// connection.
+func (u *SessionUsecase) reportUsage(s *liveSession) {
+	usage, ok := u.Usage(s.state.ID)
+	if !ok {
+		return
+	}
+	now := u.clock.Now()
+	event := &domain.SessionClosedEvent{
+		BaseEvent: domain.BaseEvent{
+			EventID: u.idGen.GenerateEventID(),
+			Type:    domain.EventSessionClosed,
+		},
+		DurationMs: now.Sub(s.state.CreatedAt).Milliseconds(),
+		Usage:      usage,
+		Metadata:   s.state.Config.Metadata,
+	}
+	for _, observe := range u.observers {
+		observe(s.state.ID, event)
+	}
+	u.events.publish(s.state.ID, event)
+	u.exportUsage(s.state, usage, now)
+}
+
+// stopStats stops the session.stats events of an ended session and drops its
+// statistics
+func (u *SessionUsecase) stopStats(sessionID string) {
+	stats, ok := u.stats.LoadAndDelete(sessionID)
+	if !ok {
+		return
+	}
+	s := stats.(*sessionStats)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
@@ -0,0 +1,17 @@
+package usecase
+
+import "github.com/aira-id/gribe/pkg/domain"
+
+// Transcript returns the transcript of a live session with its items and
+// words placed on the session's input audio. Sessions are visible to the
+// same credentials as their events, see Subscribe.
+func (u *SessionUsecase) Transcript(sessionID string, opts SubscribeOptions) (*domain.SessionTranscript, error) {
+	state, err := u.sessionManager.GetSession(sessionID)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+	if !opts.allow(state.APIKey, state.Tenant) {
+		return nil, ErrSessionForbidden
+	}
+	return state.Transcript(), nil
+}
@@ -7,11 +7,17 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/aira-id/gribe/internal/config"
-	"github.com/aira-id/gribe/internal/domain"
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+	"github.com/aira-id/gribe/internal/pkg/clock"
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
 // Conn defines the interface for WebSocket connections
@@ -21,16 +27,67 @@ type Conn interface {
 	Close() error
 }
 
+// RejectedMessage is returned by the ReadMessage of a Conn that dropped a
+// client message, such as one over a rate limit. The session answers it with
+// an error event, sent like all its events, and reads on.
+type RejectedMessage struct {
+	EventID string      // Event ID of the dropped client event
+	Type    string      // Error type, e.g. "rate_limit_error"
+	Code    string      // Error code
+	Message string      // Human-readable reason
+	Param   interface{} // Error param, e.g. the limit exceeded
+}
+
+func (e *RejectedMessage) Error() string {
+	return e.Message
+}
+
 // SessionUsecase handles session business logic
 type SessionUsecase struct {
 	sessionManager       *SessionManager
 	idGen                *IDGenerator
-	asrRegistry          *ASRModelRegistry            // Registry for lazy model loading
-	asrProvider          domain.ASRProvider           // Current ASR provider (nil until session.update)
-	vadProviders         map[string]*SimpleVADProvider // sessionID -> VAD
+	asrRegistry          *ASRModelRegistry     // Registry for lazy model loading
+	asrProvider          domain.ASRProvider    // Provider of sessions that selected no model, nil unless created with one
+	vadWorkers           map[string]*vadWorker // sessionID -> VAD worker
 	vadMu                sync.RWMutex
 	maxAudioBufferSize   int
+	spillThreshold       int                              // Buffered bytes past which audio moves to disk, 0 disables
+	openSpill            func() (domain.SpillFile, error) // Creates spill files in the configured directory
 	transcriptionTimeout time.Duration
+	quota                *QuotaTracker
+	tenants              map[string]*domain.Tenant // API key -> tenant
+	tenantsByClaim       map[string]*domain.Tenant // JWT tenant claim value -> tenant
+	defaultModel         string                    // Model preselected for new sessions, empty requires session.update
+	defaultLanguage      string
+	languageRoutes       map[string]string   // Model selected per language when a session names none
+	deltaInterval        time.Duration       // Minimum time between transcription deltas
+	deltaMinChars        int                 // Minimum characters per transcription delta
+	stabilityWindow      int                 // Trailing hypothesis words held back as unstable
+	retry                retryPolicy         // Retries of transient provider failures
+	cache                *transcriptCache    // Transcripts of recurring audio, nil when disabled
+	memory               *memoryBudget       // Ceiling on session audio in memory, nil when unlimited
+	transcriptions       *transcriptionQueue // Bound on transcriptions running at once, nil when unlimited
+	queuedItems          sync.Map            // itemID -> context.CancelFunc of transcriptions waiting for a slot
+	transcribing         atomic.Int64        // Transcriptions in flight
+	stats                sync.Map            // sessionID -> *sessionStats of live sessions
+	resumable            *resumable          // Sessions clients may resume after a dropped connection
+	protocol             domain.Protocol     // Dialect of server events for connections that do not choose one
+	speakers             *SpeakerIdentifier  // Identifies enrolled speakers, nil when disabled
+	diarizers            sync.Map            // sessionID -> *diarizer of diarized sessions
+	echoCancellers       sync.Map            // sessionID -> *aec.Canceller of sessions cancelling echo
+	utterances           sync.Map            // sessionID -> *utteranceChunk the buffer's audio continues
+	utteranceChunks      sync.Map            // itemID -> *utteranceChunk of items not yet transcribing
+	dedupWindow          time.Duration       // How long client event IDs are remembered, 0 disables deduplication
+	clientEventLogs      sync.Map            // sessionID -> *clientEventLog of handled client events
+	observers            []EventObserver
+	events               *eventHub                // Subscribers to live sessions' events
+	conversations        domain.ConversationStore // Conversations of ended sessions, nil when not kept
+	usageSink            domain.UsageSink         // Receives the usage of ended sessions, nil when not exported
+	shadow               *ShadowTranscriber       // Transcribes a sample of segments again, nil when disabled
+	retention            *Retention               // Deletes expired stored data, nil when kept for ever
+	eraser               *Eraser                  // Deletes stored data on request, auditing it
+	clock                clock.Clock              // Times activity, stats events and the resume window
+	newVAD               VADFactory               // Creates sessions' VADs, the SimpleVADProvider when nil
 }
 
 // NewSessionUsecase creates a new session usecase (for testing, no config)
@@ -40,9 +97,13 @@ func NewSessionUsecase() *SessionUsecase {
 		idGen:                NewIDGenerator(),
 		asrRegistry:          nil, // No registry without config
 		asrProvider:          nil, // No provider until session.update
-		vadProviders:         make(map[string]*SimpleVADProvider),
+		vadWorkers:           make(map[string]*vadWorker),
+		events:               newEventHub(),
+		resumable:            newResumable(0),
 		maxAudioBufferSize:   15 * 1024 * 1024, // 15MB default
 		transcriptionTimeout: 30 * time.Second,
+		quota:                NewQuotaTracker(&config.QuotaConfig{}), // unlimited
+		clock:                clock.Real{},
 	}
 }
 
@@ -58,14 +119,75 @@ func NewSessionUsecaseWithConfig(cfg *config.Config) *SessionUsecase {
 		log.Printf("[INFO]   - %s", modelName)
 	}
 
+	quota := NewQuotaTracker(&cfg.Quota)
+	tenants := make(map[string]*domain.Tenant)
+	tenantsByClaim := make(map[string]*domain.Tenant)
+	for id, tc := range cfg.Tenants {
+		tenant := &domain.Tenant{
+			ID:               id,
+			AllowedModels:    tc.AllowedModels,
+			AllowedLanguages: tc.AllowedLanguages,
+			RecordDir:        tc.RecordDir,
+		}
+		for _, key := range tc.APIKeys {
+			tenants[key] = tenant
+		}
+		for _, value := range tc.JWTClaimValues {
+			tenantsByClaim[value] = tenant
+		}
+		if tc.Quota != nil {
+			// One budget shared by all the tenant's keys and JWT subjects
+			tenant.SharedQuota = true
+			quota.SetKeyLimits(TenantQuotaKey(id), tc.Quota)
+		}
+	}
+	if len(cfg.Tenants) > 0 {
+		log.Printf("[INFO] %d tenant(s) configured", len(cfg.Tenants))
+	}
+
+	defaultModel, defaultLanguage := cfg.Audio.DefaultModel, cfg.Audio.DefaultLanguage
+	if model, exists := cfg.ASR.Models[defaultModel]; exists && defaultLanguage == "" && len(model.Languages) > 0 {
+		defaultLanguage = model.Languages[0]
+	}
+	if defaultModel != "" {
+		log.Printf("[INFO] Default transcription model: %s (%s)", defaultModel, defaultLanguage)
+	}
+
+	idGen, err := NewIDGeneratorWithConfig(&cfg.IDs)
+	if err != nil {
+		log.Printf("[WARN] %v, generating short UUID IDs", err)
+		idGen = NewIDGenerator()
+	}
+
+	sessionManager := NewSessionManager()
 	return &SessionUsecase{
-		sessionManager:       NewSessionManager(),
-		idGen:                NewIDGenerator(),
+		sessionManager:       sessionManager,
+		idGen:                idGen,
 		asrRegistry:          registry,
 		asrProvider:          nil, // No provider until session.update
-		vadProviders:         make(map[string]*SimpleVADProvider),
+		vadWorkers:           make(map[string]*vadWorker),
+		events:               newEventHub(),
 		maxAudioBufferSize:   cfg.Audio.MaxBufferSize,
+		spillThreshold:       cfg.Audio.SpillThreshold,
+		openSpill:            spillOpener(cfg.Audio.SpillDir),
 		transcriptionTimeout: cfg.Audio.TranscriptionTimeout,
+		quota:                quota,
+		tenants:              tenants,
+		tenantsByClaim:       tenantsByClaim,
+		defaultModel:         defaultModel,
+		defaultLanguage:      defaultLanguage,
+		languageRoutes:       cfg.ASR.LanguageRoutes,
+		deltaInterval:        cfg.Audio.MinDeltaInterval,
+		deltaMinChars:        cfg.Audio.MinDeltaChars,
+		stabilityWindow:      cfg.Audio.StabilityWindow,
+		retry:                retryPolicy{retries: cfg.Audio.TranscriptionRetries, backoff: cfg.Audio.RetryBackoff},
+		cache:                newTranscriptCache(cfg.Cache.MaxEntries, cfg.Cache.TTL),
+		memory:               newMemoryBudget(cfg.Server.MemoryBudget, sessionManager),
+		transcriptions:       newTranscriptionQueue(cfg.Audio.MaxTranscriptions),
+		resumable:            newResumable(cfg.Server.ResumeWindow),
+		dedupWindow:          cfg.Server.EventDedupWindow,
+		protocol:             domain.Protocol(cfg.Server.Protocol),
+		clock:                clock.Real{},
 	}
 }
 
@@ -76,19 +198,114 @@ func NewSessionUsecaseWithASR(asr domain.ASRProvider) *SessionUsecase {
 		idGen:                NewIDGenerator(),
 		asrRegistry:          nil,
 		asrProvider:          asr,
-		vadProviders:         make(map[string]*SimpleVADProvider),
+		vadWorkers:           make(map[string]*vadWorker),
+		events:               newEventHub(),
+		resumable:            newResumable(0),
 		maxAudioBufferSize:   15 * 1024 * 1024, // 15MB default
 		transcriptionTimeout: 30 * time.Second,
+		quota:                NewQuotaTracker(&config.QuotaConfig{}), // unlimited
+		clock:                clock.Real{},
 	}
 }
 
-// getOrCreateVAD gets or creates a VAD provider for a session
-func (u *SessionUsecase) getOrCreateVAD(state *domain.SessionState) *SimpleVADProvider {
+// ASRRegistry returns the model registry used by this usecase (nil when created without config)
+func (u *SessionUsecase) ASRRegistry() *ASRModelRegistry {
+	return u.asrRegistry
+}
+
+// ReloadModel reloads a model from its replaced files without dropping the
+// sessions using it, and forgets its cached transcripts. It reports false if
+// the model was not loaded.
+func (u *SessionUsecase) ReloadModel(modelName string) (bool, error) {
+	if u.asrRegistry == nil {
+		return false, fmt.Errorf("ASR configuration not available")
+	}
+	reloaded, err := u.asrRegistry.ReloadModel(modelName)
+	if err != nil {
+		return false, err
+	}
+	u.cache.dropModel(modelName)
+	return reloaded, nil
+}
+
+// SetSpeakerIdentifier enables speaker identification for sessions that
+// include item.input_audio_transcription.speaker
+func (u *SessionUsecase) SetSpeakerIdentifier(speakers *SpeakerIdentifier) {
+	if speakers != nil {
+		speakers.idGen = u.idGen
+	}
+	u.speakers = speakers
+}
+
+// IDGenerator returns the generator of the IDs of sessions, items and events
+func (u *SessionUsecase) IDGenerator() *IDGenerator {
+	return u.idGen
+}
+
+// SetClock times the sessions created from now on, their activity, their
+// session.stats events and the resume window with c instead of the system
+// clock, so tests can advance time
+func (u *SessionUsecase) SetClock(c clock.Clock) {
+	u.clock = c
+	u.sessionManager.clock = c
+}
+
+// Speakers returns the speaker identifier, nil when identification is disabled
+func (u *SessionUsecase) Speakers() *SpeakerIdentifier {
+	return u.speakers
+}
+
+// Quota returns the per-API-key audio quota tracker
+func (u *SessionUsecase) Quota() *QuotaTracker {
+	return u.quota
+}
+
+// AdmitSession reports whether a new session fits in the memory budget,
+// pruning conversation audio first when memory use is near it
+func (u *SessionUsecase) AdmitSession() bool {
+	return u.memory.admit()
+}
+
+// DefaultTranscription returns the model and language new sessions start
+// with, empty when no default model is configured
+func (u *SessionUsecase) DefaultTranscription() (model, language string) {
+	return u.defaultModel, u.defaultLanguage
+}
+
+// ResolveTenant returns the tenant owning apiKey or, for JWT callers, the
+// tenant matching the token's tenant claim. Nil means no tenant matched.
+func (u *SessionUsecase) ResolveTenant(apiKey, tenantClaim string) *domain.Tenant {
+	if tenant := u.tenants[apiKey]; apiKey != "" && tenant != nil {
+		return tenant
+	}
+	if tenantClaim != "" {
+		return u.tenantsByClaim[tenantClaim]
+	}
+	return nil
+}
+
+// VADFactory creates the voice activity detector of a session's server VAD.
+// The detector must attach the speech segment's audio to the
+// VADEventSpeechStopped events it sends, which is what gets transcribed.
+type VADFactory func(config *domain.VADConfig) domain.VADProvider
+
+// SetVADFactory makes server VAD detect speech with detectors newVAD
+// creates instead of the SimpleVADProvider, in sessions whose VAD starts
+// from now on
+func (u *SessionUsecase) SetVADFactory(newVAD VADFactory) {
+	u.vadMu.Lock()
+	defer u.vadMu.Unlock()
+	u.newVAD = newVAD
+}
+
+// getOrCreateVAD gets or starts the VAD worker for a session, which a new
+// worker starts at startMs of the session's input audio
+func (u *SessionUsecase) getOrCreateVAD(conn Conn, state *domain.SessionState, startMs int) *vadWorker {
 	u.vadMu.Lock()
 	defer u.vadMu.Unlock()
 
-	if vad, exists := u.vadProviders[state.ID]; exists {
-		return vad
+	if worker, exists := u.vadWorkers[state.ID]; exists {
+		return worker
 	}
 
 	// Create VAD config from session config
@@ -98,20 +315,29 @@ func (u *SessionUsecase) getOrCreateVAD(state *domain.SessionState) *SimpleVADPr
 	} else {
 		vadConfig = domain.NewDefaultVADConfig()
 	}
+	if state.Config.Audio != nil && state.Config.Audio.Input != nil {
+		vadConfig.ApplyFormat(state.Config.Audio.Input.Format)
+	}
 
-	vad := NewSimpleVADProvider(vadConfig)
-	u.vadProviders[state.ID] = vad
-	return vad
+	newVAD := u.newVAD
+	if newVAD == nil {
+		newVAD = func(config *domain.VADConfig) domain.VADProvider { return NewSimpleVADProvider(config) }
+	}
+	worker := u.startVADWorker(conn, state, newVAD(vadConfig))
+	worker.offsetMs = startMs
+	u.vadWorkers[state.ID] = worker
+	return worker
 }
 
-// removeVAD removes the VAD provider for a session
+// removeVAD stops the VAD worker for a session once its queued audio is processed
 func (u *SessionUsecase) removeVAD(sessionID string) {
 	u.vadMu.Lock()
-	defer u.vadMu.Unlock()
+	worker, exists := u.vadWorkers[sessionID]
+	delete(u.vadWorkers, sessionID)
+	u.vadMu.Unlock()
 
-	if vad, exists := u.vadProviders[sessionID]; exists {
-		vad.Close()
-		delete(u.vadProviders, sessionID)
+	if exists {
+		worker.Stop()
 	}
 }
 
@@ -132,15 +358,58 @@ func (u *SessionUsecase) HandleNewConnection(conn interface{}) {
 // HandleNewConnectionWithIntent handles a new WebSocket connection with specified intent
 // intent can be "realtime" (default) or "transcription"
 func (u *SessionUsecase) HandleNewConnectionWithIntent(conn interface{}, intent SessionIntent) {
+	u.HandleNewConnectionWithOptions(conn, ConnectOptions{Intent: intent})
+}
+
+// ConnectOptions describes how a connection was established
+type ConnectOptions struct {
+	Intent   SessionIntent
+	APIKey   string         // API key (or "jwt:<sub>") the client authenticated with, used for quota accounting
+	Tenant   *domain.Tenant // Tenant resolved from the credentials, see ResolveTenant
+	ClientIP string         // Address the client connected from, for operators
+
+	// Protocol is the dialect of server events to send, the configured
+	// default when empty
+	Protocol domain.Protocol
+
+	// ResumeSessionID resumes a session that lost its connection instead of
+	// creating one, replaying the events after LastEventSequence
+	ResumeSessionID   string
+	LastEventSequence uint64
+
+	// Metadata the client attached to the session as it opened it, see
+	// domain.Session.Metadata
+	Metadata map[string]string
+}
+
+// HandleNewConnectionWithOptions handles a new WebSocket connection
+func (u *SessionUsecase) HandleNewConnectionWithOptions(conn interface{}, opts ConnectOptions) {
+	intent := opts.Intent
 	wsConn, ok := conn.(Conn)
 	if !ok {
 		log.Println("Invalid connection type")
 		return
 	}
 
+	if opts.ResumeSessionID != "" {
+		u.resumeSession(wsConn, opts)
+		return
+	}
+
+	// Refuse sessions that would push memory use past the budget
+	if !u.memory.admit() {
+		u.sendError(wsConn, "", "server_error", domain.CodeServerOverloaded, "Server memory is near capacity, retry later", nil)
+		return
+	}
+
 	// Create session and conversation
 	sessionID := u.idGen.GenerateSessionID()
 	conversationID := u.idGen.GenerateConversationID()
+	protocol := opts.Protocol
+	if protocol == domain.ProtocolDefault {
+		protocol = u.protocol
+	}
+	seqConn := newSequencedConn(wsConn, protocol)
 
 	var state *domain.SessionState
 	if intent == IntentTranscription {
@@ -151,71 +420,124 @@ func (u *SessionUsecase) HandleNewConnectionWithIntent(conn interface{}, intent
 		state = u.sessionManager.CreateSession(sessionID, "gpt-realtime-2025-08-28", conversationID)
 	}
 
+	state.APIKey = opts.APIKey
+	state.Tenant = opts.Tenant
+	state.ClientIP = opts.ClientIP
+	state.Protocol = protocol
+	state.Config.Metadata = opts.Metadata
+	ctx, cancel := context.WithCancel(context.Background())
+	state.Ctx = ctx
+	u.events.open(state)
+	stats := &sessionStats{}
+	u.stats.Store(sessionID, stats)
+	session := &liveSession{
+		state:  state,
+		seq:    seqConn,
+		conn:   &observedConn{Conn: seqConn, sessionID: sessionID, observers: u.observers, events: u.events, stats: stats},
+		cancel: cancel,
+	}
+	u.resumable.add(session)
+
+	// Preselect the default model so clients can stream without a session.update
+	u.selectDefaultModel(state)
+
 	// Set audio buffer size limit
 	if u.maxAudioBufferSize > 0 {
 		state.AudioBuffer.SetMaxSize(u.maxAudioBufferSize)
 	}
+	if u.spillThreshold > 0 {
+		state.AudioBuffer.SetSpill(u.spillThreshold, u.openSpill)
+	}
 
-	// Send appropriate session.created event based on intent
-	if intent == IntentTranscription {
-		// Send transcription_session.created event with flattened format
-		transcriptionSessionCreatedEvent := &domain.TranscriptionSessionCreatedEvent{
-			BaseEvent: domain.BaseEvent{
-				EventID: u.idGen.GenerateEventID(),
-				Type:    domain.EventTranscriptionSessionCreated,
-			},
-			Session: domain.NewTranscriptionSessionConfig(state.Config),
-		}
-
-		if err := wsConn.WriteJSON(transcriptionSessionCreatedEvent); err != nil {
-			log.Println("Error sending transcription_session.created:", err)
-			return
-		}
-	} else {
-		// Send session.created event
-		sessionCreatedEvent := &domain.SessionCreatedEvent{
-			BaseEvent: domain.BaseEvent{
-				EventID: u.idGen.GenerateEventID(),
-				Type:    domain.EventSessionCreated,
-			},
-			Session: state.Config,
-		}
+	// Send session.created, or transcription_session.created for transcription sessions
+	if err := u.sendSessionEvent(session.conn, state, true); err != nil {
+		log.Println("Error sending session.created:", err)
+		u.endSession(session)
+		return
+	}
 
-		if err := wsConn.WriteJSON(sessionCreatedEvent); err != nil {
-			log.Println("Error sending session.created:", err)
-			return
-		}
+	// Report remaining audio quota up front
+	if u.quota.Enabled(quotaKey(state)) {
+		u.sendRateLimits(session.conn, state)
 	}
 
-	// Message reading loop
+	u.serve(wsConn, session)
+}
+
+// serve processes the client events read from conn until it fails
+func (u *SessionUsecase) serve(conn Conn, s *liveSession) {
 	for {
-		_, message, err := wsConn.ReadMessage()
+		_, message, err := conn.ReadMessage()
+		var rejected *RejectedMessage
+		if errors.As(err, &rejected) {
+			u.sendError(s.conn, rejected.EventID, rejected.Type, rejected.Code, rejected.Message, rejected.Param)
+			continue
+		}
 		if err != nil {
 			log.Println("Read error:", err)
 			break
 		}
 
-		u.ProcessMessage(wsConn, state, message)
+		u.ProcessMessage(s.conn, s.state, message)
 	}
+	u.release(conn, s)
+}
 
-	// Cleanup
-	u.removeVAD(sessionID)
-	u.sessionManager.DeleteSession(sessionID)
+// endSession sends the session's usage summary, stops in-flight
+// transcriptions, whose events could no longer be delivered, and discards
+// the session
+func (u *SessionUsecase) endSession(s *liveSession) {
+	u.reportUsage(s)
+	s.cancel()
+	u.saveConversation(s.state)
+	u.removeVAD(s.state.ID)
+	s.state.AudioBuffer.Clear() // Removes any spill file
+	u.sessionManager.DeleteSession(s.state.ID)
+	u.resumable.remove(s.state.ID)
+	u.events.close(s.state.ID)
+	u.stopStats(s.state.ID)
+	u.diarizers.Delete(s.state.ID)
+	u.echoCancellers.Delete(s.state.ID)
+	u.utterances.Delete(s.state.ID)
+	u.clientEventLogs.Delete(s.state.ID)
 }
 
 // ProcessMessage processes incoming client events
 func (u *SessionUsecase) ProcessMessage(conn Conn, state *domain.SessionState, message []byte) {
+	state.Touch(u.clock.Now())
+	u.sessionStats(state.ID).countEvent(true)
 	var baseEvent domain.BaseEvent
 	if err := json.Unmarshal(message, &baseEvent); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_json", "Failed to parse message", nil)
+		u.sendError(conn, "", "invalid_request_error", domain.CodeInvalidJSON, "Failed to parse message", nil)
 		return
 	}
 
 	log.Printf("Received event: %s", baseEvent.Type)
 
+	// A retransmitted event is answered as it was the first time. Audio
+	// appends are not: clients commonly reuse their event IDs, and dropping
+	// one loses audio.
+	if events := u.clientEvents(state.ID); events != nil && baseEvent.EventID != "" && !isAudioAppend(baseEvent.Type) {
+		if u.answerDuplicate(conn, events, state.ID, baseEvent.EventID) {
+			return
+		}
+		recorder := &replyRecorder{Conn: conn}
+		conn = recorder
+		defer func() { events.add(baseEvent.EventID, u.clock.Now(), recorder.stop()) }()
+	}
+
+	// A bug in a handler fails the event, not the server
+	defer panics.Recover("handler of "+string(baseEvent.Type), func(error) {
+		u.sendError(conn, baseEvent.EventID, "server_error", domain.CodeServerError, "The server failed to handle the event", nil)
+	})
+
 	switch baseEvent.Type {
 	case domain.EventSessionUpdate:
-		u.handleSessionUpdate(conn, state, message)
+		if state.Protocol == domain.Protocol2024 {
+			u.handleBetaSessionUpdate(conn, state, message)
+		} else {
+			u.handleSessionUpdate(conn, state, message)
+		}
 
 	case domain.EventInputAudioBufferAppend:
 		u.handleInputAudioBufferAppend(conn, state, message)
@@ -244,8 +566,11 @@ func (u *SessionUsecase) ProcessMessage(conn Conn, state *domain.SessionState, m
 	case domain.EventTranscriptionSessionUpdate:
 		u.handleTranscriptionSessionUpdate(conn, state, message)
 
+	case domain.EventOutputAudioBufferAppend:
+		u.handleOutputAudioBufferAppend(conn, state, message)
+
 	default:
-		u.sendError(conn, baseEvent.EventID, "invalid_request_error", "unknown_event_type",
+		u.sendError(conn, baseEvent.EventID, "invalid_request_error", domain.CodeUnknownEventType,
 			fmt.Sprintf("Unknown event type: %s", baseEvent.Type), nil)
 	}
 }
@@ -256,31 +581,46 @@ func (u *SessionUsecase) ProcessMessage(conn Conn, state *domain.SessionState, m
 
 func (u *SessionUsecase) handleSessionUpdate(conn Conn, state *domain.SessionState, message []byte) {
 	var event domain.SessionUpdateClientEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_event", "Failed to parse session.update", nil)
-		return
-	}
-
-	if event.Session == nil {
-		u.sendError(conn, event.EventID, "invalid_request_error", "missing_field", "session field is required", "session")
+	if !u.decodeClientEvent(conn, message, &event) {
 		return
 	}
 
 	// Check if transcription config is being updated (model/language change)
 	if event.Session.Audio != nil && event.Session.Audio.Input != nil && event.Session.Audio.Input.Transcription != nil {
 		transcription := event.Session.Audio.Input.Transcription
-		if err := u.reconfigureASRProvider(conn, event.EventID, transcription.Model, transcription.Language); err != nil {
+		transcription.Model = u.routeModel(state, transcription.Model, transcription.Language)
+		defaults := u.modelDefaults(state, transcription.Model)
+		if err := u.reconfigureASRProvider(conn, state, event.EventID, transcription.Model, transcription.Language); err != nil {
 			// Error already sent to client
 			return
 		}
+		if !u.checkTranslation(conn, state, event.EventID, transcription.Model, transcription.Translate,
+			"audio.input.transcription.translate") {
+			return
+		}
+		if defaults != nil {
+			// Turn detection in this update, if any, replaces the defaults below
+			u.applyModelDefaults(state, defaults)
+			if transcription.Prompt == "" {
+				transcription.Prompt = defaults.Prompt
+			}
+		}
 	}
 
 	// Update session configuration
-	updatedState, err := u.sessionManager.UpdateSession(state.ID, event.Session)
+	sent := domain.ParseSessionFields(message)
+	updatedState, err := u.sessionManager.UpdateSession(state.ID, event.Session, sent)
 	if err != nil {
-		u.sendError(conn, event.EventID, "server_error", "session_update_failed", err.Error(), nil)
+		u.sendError(conn, event.EventID, "server_error", domain.CodeSessionUpdateFailed, err.Error(), nil)
 		return
 	}
+	if sent.Sent("audio.input.turn_detection") || sent.Sent("audio.input.format") {
+		// Recreate the VAD with the new settings on the next append
+		u.removeVAD(state.ID)
+	}
+	applyBufferWindow(state)
+	u.scheduleStats(conn, state)
+	u.logIgnoredOptions(state)
 
 	// Send session.updated event
 	sessionUpdatedEvent := &domain.SessionUpdatedEvent{
@@ -298,92 +638,258 @@ func (u *SessionUsecase) handleSessionUpdate(conn Conn, state *domain.SessionSta
 // This uses the flattened OpenAI Realtime Transcription API format
 func (u *SessionUsecase) handleTranscriptionSessionUpdate(conn Conn, state *domain.SessionState, message []byte) {
 	var event domain.TranscriptionSessionUpdateClientEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_event", "Failed to parse transcription_session.update", nil)
+	if !u.decodeClientEvent(conn, message, &event) {
 		return
 	}
+	u.applyFlatSessionUpdate(conn, state, event.EventID, event.Session, event.Session, message)
+}
 
-	if event.Session == nil {
-		u.sendError(conn, event.EventID, "invalid_request_error", "missing_field", "session field is required", "session")
-		return
-	}
-
-	// Apply the flattened config to the internal session structure
-	event.Session.ApplyToSession(state.Config)
-
+// applyFlatSessionUpdate applies a session update in the flat format of
+// transcription sessions or the beta protocol, whose input audio settings
+// are in input
+func (u *SessionUsecase) applyFlatSessionUpdate(conn Conn, state *domain.SessionState, eventID string,
+	input *domain.TranscriptionSessionConfig, update interface {
+		ApplyToSession(*domain.Session, domain.SessionFields)
+	}, message []byte) {
 	// Check if transcription config is being updated (model/language change)
-	if event.Session.InputAudioTranscription != nil {
-		model := event.Session.InputAudioTranscription.Model
-		language := event.Session.InputAudioTranscription.Language
+	if input.InputAudioTranscription != nil {
+		language := input.InputAudioTranscription.Language
+		model := u.routeModel(state, input.InputAudioTranscription.Model, language)
+		input.InputAudioTranscription.Model = model
 		if model != "" && language != "" {
-			if err := u.reconfigureASRProvider(conn, event.EventID, model, language); err != nil {
+			defaults := u.modelDefaults(state, model)
+			if err := u.reconfigureASRProvider(conn, state, eventID, model, language); err != nil {
 				// Error already sent to client
 				return
 			}
+			if defaults != nil {
+				u.applyModelDefaults(state, defaults)
+			}
+		}
+		if !u.checkTranslation(conn, state, eventID, model, input.InputAudioTranscription.Translate,
+			"input_audio_transcription.translate") {
+			return
 		}
 	}
 
-	// Send transcription_session.updated event with flattened format
-	transcriptionSessionUpdatedEvent := &domain.TranscriptionSessionUpdatedEvent{
-		BaseEvent: domain.BaseEvent{
-			EventID: u.idGen.GenerateEventID(),
-			Type:    domain.EventTranscriptionSessionUpdated,
-		},
-		Session: domain.NewTranscriptionSessionConfig(state.Config),
+	// Apply the flattened config to the internal session structure,
+	// overriding any model defaults with the fields the client sent
+	sent := domain.ParseSessionFields(message)
+	update.ApplyToSession(state.Config, sent)
+	state.Config.Normalize()
+	if sent.Sent("turn_detection") || sent.Sent("input_audio_format") {
+		u.removeVAD(state.ID)
 	}
+	applyBufferWindow(state)
+	u.scheduleStats(conn, state)
+	u.logIgnoredOptions(state)
 
-	conn.WriteJSON(transcriptionSessionUpdatedEvent)
+	// Send transcription_session.updated, or session.updated, with flattened format
+	u.sendSessionEvent(conn, state, false)
+}
+
+// checkTranslation rejects an update asking for translation when the
+// session's provider cannot translate, rather than transcribing untranslated
+func (u *SessionUsecase) checkTranslation(conn Conn, state *domain.SessionState, eventID, model string, translate bool, param string) bool {
+	if !translate || domain.SupportsTranslation(u.providerFor(state)) {
+		return true
+	}
+	u.sendError(conn, eventID, "invalid_request_error", domain.CodeTranslationNotSupported,
+		fmt.Sprintf("Model %s cannot translate to English", model), param)
+	return false
+}
+
+// logIgnoredOptions logs the transcription options set on the session that
+// its provider cannot use, which are dropped from its transcriptions
+func (u *SessionUsecase) logIgnoredOptions(state *domain.SessionState) {
+	provider := u.providerFor(state)
+	if provider == nil || state.Config.Audio == nil || state.Config.Audio.Input == nil ||
+		state.Config.Audio.Input.Transcription == nil {
+		return
+	}
+	transcription := state.Config.Audio.Input.Transcription
+	if _, dropped := domain.SupportedOptions(provider, transcription); dropped != nil {
+		log.Printf("[INFO] Session %s: model %s ignores the transcription %s",
+			state.ID, transcription.Model, strings.Join(dropped, " and "))
+	}
 }
 
 // reconfigureASRProvider loads/gets the ASR provider for the requested model and language
 // Uses the registry for singleton pattern - models are loaded once and reused
-func (u *SessionUsecase) reconfigureASRProvider(conn Conn, eventID, modelName, language string) error {
+func (u *SessionUsecase) reconfigureASRProvider(conn Conn, state *domain.SessionState, eventID, modelName, language string) error {
 	// Check if registry is available
 	if u.asrRegistry == nil {
-		u.sendError(conn, eventID, "server_error", "configuration_unavailable",
+		u.sendError(conn, eventID, "server_error", domain.CodeConfigUnavailable,
 			"ASR configuration not available. Server was not initialized with YAML config.", nil)
 		return fmt.Errorf("ASR configuration not available")
 	}
 
 	// Validate model_name is provided
 	if modelName == "" {
-		u.sendError(conn, eventID, "invalid_request_error", "missing_field",
+		u.sendError(conn, eventID, "invalid_request_error", domain.CodeMissingField,
 			"transcription.model is required", "audio.input.transcription.model")
 		return fmt.Errorf("model is required")
 	}
 
 	// Validate language is provided
 	if language == "" {
-		u.sendError(conn, eventID, "invalid_request_error", "missing_field",
+		u.sendError(conn, eventID, "invalid_request_error", domain.CodeMissingField,
 			"transcription.language is required", "audio.input.transcription.language")
 		return fmt.Errorf("language is required")
 	}
 
+	// Enforce tenant restrictions
+	if state.Tenant != nil {
+		if !state.Tenant.AllowsModel(modelName) {
+			u.sendError(conn, eventID, "invalid_request_error", domain.CodeModelNotAllowed,
+				fmt.Sprintf("Model %s is not available for this API key", modelName), "audio.input.transcription.model")
+			return fmt.Errorf("model %s not allowed for tenant %s", modelName, state.Tenant.ID)
+		}
+		if !state.Tenant.AllowsLanguage(language) {
+			u.sendError(conn, eventID, "invalid_request_error", domain.CodeLanguageNotAllowed,
+				fmt.Sprintf("Language %s is not available for this API key", language), "audio.input.transcription.language")
+			return fmt.Errorf("language %s not allowed for tenant %s", language, state.Tenant.ID)
+		}
+	}
+
 	// Get model from registry (lazy loading with singleton pattern)
 	provider, err := u.asrRegistry.GetModel(modelName, language)
 	if err != nil {
 		// Determine error type based on error message
 		errMsg := err.Error()
 		if contains(errMsg, "not found") {
-			u.sendError(conn, eventID, "invalid_request_error", "invalid_model",
+			u.sendError(conn, eventID, "invalid_request_error", domain.CodeInvalidModel,
 				err.Error(), "audio.input.transcription.model")
 		} else if contains(errMsg, "not supported") {
-			u.sendError(conn, eventID, "invalid_request_error", "unsupported_language",
+			u.sendError(conn, eventID, "invalid_request_error", domain.CodeUnsupportedLanguage,
 				err.Error(), "audio.input.transcription.language")
 		} else {
-			u.sendError(conn, eventID, "server_error", "provider_initialization_failed",
+			u.sendError(conn, eventID, "server_error", domain.CodeProviderInitFailed,
 				err.Error(), nil)
 		}
 		return err
 	}
 
 	// Update the ASR provider for this session
-	u.asrProvider = provider
+	state.SetASRProvider(provider)
 
-	log.Printf("[INFO] ASR provider set to model: %s, language: %s", modelName, language)
+	log.Printf("[INFO] Session %s: ASR provider set to model: %s, language: %s", state.ID, modelName, language)
 	return nil
 }
 
+// providerFor returns the provider transcribing a session's audio: that of
+// the model the session selected, else the one the usecase was created with
+func (u *SessionUsecase) providerFor(state *domain.SessionState) domain.ASRProvider {
+	if provider := state.ASRProvider(); provider != nil {
+		return provider
+	}
+	return u.asrProvider
+}
+
+// selectDefaultModel loads the configured default model and language for a
+// new session. Failures are logged and leave the session waiting for a
+// session.update, as when no default is configured.
+func (u *SessionUsecase) selectDefaultModel(state *domain.SessionState) {
+	if u.asrRegistry == nil || u.defaultModel == "" {
+		return
+	}
+	model, language := u.defaultModel, u.defaultLanguage
+
+	if state.Tenant != nil && (!state.Tenant.AllowsModel(model) || !state.Tenant.AllowsLanguage(language)) {
+		log.Printf("[INFO] Default model %s (%s) not available to tenant %s, waiting for session.update",
+			model, language, state.Tenant.ID)
+		return
+	}
+
+	provider, err := u.asrRegistry.GetModel(model, language)
+	if err != nil {
+		log.Printf("[WARN] Could not load default model %s (%s): %v", model, language, err)
+		return
+	}
+	state.SetASRProvider(provider)
+
+	if state.Config.Audio == nil {
+		state.Config.Audio = &domain.AudioConfig{}
+	}
+	if state.Config.Audio.Input == nil {
+		state.Config.Audio.Input = &domain.AudioInput{}
+	}
+	state.Config.Audio.Input.Transcription = &domain.TranscriptionConfig{
+		Model:    model,
+		Language: language,
+	}
+	if state.Config.Type == "transcription" {
+		state.Config.Model = model
+	}
+	if defaults := u.asrRegistry.GetModelDefaults(model); defaults != nil {
+		u.applyModelDefaults(state, defaults)
+	}
+}
+
+// routeModel returns the model of a transcription update. A model the client
+// names is used as is. Without one, the language's route picks the model, so
+// clients switch languages without knowing model names; unrouted languages
+// keep the session's model.
+func (u *SessionUsecase) routeModel(state *domain.SessionState, model, language string) string {
+	if model != "" || language == "" {
+		return model
+	}
+	if routed, ok := u.languageRoutes[language]; ok {
+		return routed
+	}
+	if audio := state.Config.Audio; audio != nil && audio.Input != nil && audio.Input.Transcription != nil {
+		return audio.Input.Transcription.Model
+	}
+	return ""
+}
+
+// modelDefaults returns the defaults to apply when modelName replaces the
+// session's current model, or nil if the model is unchanged or declares none
+func (u *SessionUsecase) modelDefaults(state *domain.SessionState, modelName string) *config.ModelDefaults {
+	if u.asrRegistry == nil {
+		return nil
+	}
+	if audio := state.Config.Audio; audio != nil && audio.Input != nil &&
+		audio.Input.Transcription != nil && audio.Input.Transcription.Model == modelName {
+		return nil
+	}
+	return u.asrRegistry.GetModelDefaults(modelName)
+}
+
+// applyModelDefaults tunes the session's turn detection and sets its
+// transcription prompt from model defaults. Turn detection is never enabled
+// here, so a session with VAD turned off stays that way.
+func (u *SessionUsecase) applyModelDefaults(state *domain.SessionState, defaults *config.ModelDefaults) {
+	if state.Config.Audio == nil {
+		state.Config.Audio = &domain.AudioConfig{}
+	}
+	if state.Config.Audio.Input == nil {
+		state.Config.Audio.Input = &domain.AudioInput{}
+	}
+	input := state.Config.Audio.Input
+
+	if td := input.TurnDetection; td != nil {
+		if defaults.TurnDetection.Threshold > 0 {
+			td.Threshold = defaults.TurnDetection.Threshold
+		}
+		if defaults.TurnDetection.PrefixPaddingMs > 0 {
+			td.PrefixPaddingMs = defaults.TurnDetection.PrefixPaddingMs
+		}
+		if defaults.TurnDetection.SilenceDurationMs > 0 {
+			td.SilenceDurationMs = defaults.TurnDetection.SilenceDurationMs
+		}
+		// Recreate the VAD with the new settings on the next append
+		u.removeVAD(state.ID)
+	}
+
+	if defaults.Prompt != "" {
+		if input.Transcription == nil {
+			input.Transcription = &domain.TranscriptionConfig{}
+		}
+		input.Transcription.Prompt = defaults.Prompt
+	}
+}
+
 // contains checks if s contains substr (simple helper to avoid importing strings)
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsHelper(s, substr))
@@ -403,58 +909,71 @@ func containsHelper(s, substr string) bool {
 // ============================================================================
 
 func (u *SessionUsecase) handleInputAudioBufferAppend(conn Conn, state *domain.SessionState, message []byte) {
-	var event domain.InputAudioBufferAppendEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_event", "Failed to parse input_audio_buffer.append", nil)
+	var event appendEvent
+	if !u.decodeClientEvent(conn, message, &event) {
 		return
 	}
+	defer bufpool.PutBytes(event.Audio)
 
-	if event.Audio == "" {
-		u.sendError(conn, event.EventID, "invalid_request_error", "missing_field", "audio field is required", "audio")
+	// Audio is charged to the API key's quota as it arrives, so once the
+	// quota is used up no more is taken
+	if u.quota.Enabled(quotaKey(state)) && u.quota.Exceeded(quotaKey(state)) && !u.quota.SoftLimit(quotaKey(state)) {
+		u.sendError(conn, event.EventID, "rate_limit_error", domain.CodeInsufficientQuota,
+			"Audio transcription quota exceeded for this API key", "audio")
 		return
 	}
 
-	// Decode base64 audio
-	audioBytes, err := base64.StdEncoding.DecodeString(event.Audio)
-	if err != nil {
-		u.sendError(conn, event.EventID, "invalid_request_error", "invalid_audio", "Invalid base64 audio data", "audio")
-		return
+	// A long utterance is committed in chunks rather than overflow the buffer
+	if state.Config.ChunkedCommits {
+		u.commitFullBuffer(conn, state, event.EventID, base64.StdEncoding.DecodedLen(len(event.Audio)))
 	}
 
-	// Append to buffer (with size limit check)
-	if err := state.AudioBuffer.Append(audioBytes); err != nil {
+	// Decode the base64 audio straight into the buffer (with size limit check)
+	chunk, err := u.appendInputAudio(state, event.Audio)
+	if err != nil {
 		if errors.Is(err, domain.ErrBufferFull) {
-			u.sendError(conn, event.EventID, "invalid_request_error", "buffer_full",
+			u.sendError(conn, event.EventID, "invalid_request_error", domain.CodeBufferFull,
 				fmt.Sprintf("Audio buffer size limit exceeded (max %d bytes)", state.AudioBuffer.GetMaxSize()), "audio")
 			return
 		}
-		u.sendError(conn, event.EventID, "server_error", "buffer_error", err.Error(), "audio")
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) {
+			u.sendError(conn, event.EventID, "invalid_request_error", domain.CodeInvalidAudio, "Invalid base64 audio data", "audio")
+			return
+		}
+		u.sendError(conn, event.EventID, "server_error", domain.CodeBufferError, err.Error(), "audio")
 		return
 	}
 	log.Printf("Appended audio to buffer, total size: %d bytes", state.AudioBuffer.GetSize())
+	seconds := audioSeconds(state, len(chunk))
+	u.recordInputAudio(state, seconds)
+	u.chargeQuota(state, seconds)
+	startMs := state.AdvanceInput(time.Duration(seconds * float64(time.Second)))
 
-	// Process through VAD if enabled
-	if state.Config.Audio != nil && state.Config.Audio.Input != nil &&
-		state.Config.Audio.Input.TurnDetection != nil &&
-		state.Config.Audio.Input.TurnDetection.Type != "" {
-
-		vad := u.getOrCreateVAD(state)
-		if err := vad.ProcessAudio(context.Background(), audioBytes); err != nil {
-			log.Printf("VAD processing error: %v", err)
-		}
+	var turnDetection *domain.TurnDetection
+	if state.Config.Audio != nil && state.Config.Audio.Input != nil {
+		turnDetection = state.Config.Audio.Input.TurnDetection
+	}
+	switch {
+	case turnDetection.DetectsSpeech():
+		// Events are sent from the session's VAD worker as they occur. The
+		// chunk aliases the buffer, so the worker gets a pooled copy it
+		// recycles once processed.
+		u.getOrCreateVAD(conn, state, startMs).Process(append(bufpool.Bytes(len(chunk))[:0], chunk...))
 
-		// Check for VAD events
-		u.processVADEvents(conn, state, vad)
+	case turnDetection != nil && turnDetection.Type == domain.TurnDetectionInterval:
+		u.commitInterval(conn, state, event.EventID, turnDetection.CommitInterval())
 	}
 
 	// Note: client doesn't expect a response for append events
 }
 
-// processVADEvents handles VAD events and sends appropriate server events
-func (u *SessionUsecase) processVADEvents(conn Conn, state *domain.SessionState, vad *SimpleVADProvider) {
+// processVADEvents handles VAD events and sends appropriate server events.
+// A speech segment keeps one item ID from speech_started to its transcript.
+func (u *SessionUsecase) processVADEvents(conn Conn, state *domain.SessionState, w *vadWorker) {
 	for {
 		select {
-		case event, ok := <-vad.GetEvents():
+		case event, ok := <-w.vad.GetEvents():
 			if !ok {
 				return
 			}
@@ -463,6 +982,7 @@ func (u *SessionUsecase) processVADEvents(conn Conn, state *domain.SessionState,
 			case domain.VADEventSpeechStarted:
 				// Generate item ID for this speech segment
 				itemID := u.idGen.GenerateItemID()
+				w.itemID = itemID
 
 				speechStartedEvent := &domain.InputAudioBufferSpeechStartedEvent{
 					BaseEvent: domain.BaseEvent{
@@ -476,7 +996,11 @@ func (u *SessionUsecase) processVADEvents(conn Conn, state *domain.SessionState,
 				log.Printf("Speech started at %d ms, item_id: %s", event.StartMs, itemID)
 
 			case domain.VADEventSpeechStopped:
-				itemID := u.idGen.GenerateItemID()
+				itemID := w.itemID
+				if itemID == "" {
+					itemID = u.idGen.GenerateItemID()
+				}
+				w.itemID = ""
 
 				speechStoppedEvent := &domain.InputAudioBufferSpeechStoppedEvent{
 					BaseEvent: domain.BaseEvent{
@@ -491,7 +1015,7 @@ func (u *SessionUsecase) processVADEvents(conn Conn, state *domain.SessionState,
 
 				// Auto-commit if VAD detected speech end
 				if len(event.AudioData) > 0 {
-					u.commitAndTranscribe(conn, state, itemID, event.AudioData)
+					u.commitAndTranscribe(conn, state, itemID, event.AudioData, w.offsetMs+event.EndMs)
 				}
 
 			case domain.VADEventTimeout:
@@ -517,32 +1041,77 @@ func (u *SessionUsecase) processVADEvents(conn Conn, state *domain.SessionState,
 
 func (u *SessionUsecase) handleInputAudioBufferCommit(conn Conn, state *domain.SessionState, message []byte) {
 	var event domain.InputAudioBufferCommitEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_event", "Failed to parse input_audio_buffer.commit", nil)
+	if !u.decodeClientEvent(conn, message, &event) {
 		return
 	}
 
 	if state.AudioBuffer.IsEmpty() {
-		u.sendError(conn, event.EventID, "invalid_request_error", "empty_buffer", "Audio buffer is empty", nil)
+		u.sendError(conn, event.EventID, "invalid_request_error", domain.CodeEmptyBuffer, "Audio buffer is empty", nil)
 		return
 	}
 
-	// Get audio data and commit
-	audioData := state.AudioBuffer.Commit()
+	// Take the buffered audio; transcription recycles it when done
+	audioData, err := state.AudioBuffer.Commit()
+	if err != nil {
+		u.sendError(conn, event.EventID, "server_error", domain.CodeBufferError, err.Error(), nil)
+		return
+	}
 	itemID := u.idGen.GenerateItemID()
+	u.linkUtteranceChunk(state.ID, itemID, false)
 
 	// Commit and transcribe
-	u.commitAndTranscribe(conn, state, itemID, audioData)
+	u.commitAndTranscribe(conn, state, itemID, audioData, state.InputMs())
 
 	// Clear audio buffer after commit
 	state.AudioBuffer.Clear()
 }
 
+// commitInterval commits the buffer once it holds intervalMs of audio, for
+// interval turn detection
+func (u *SessionUsecase) commitInterval(conn Conn, state *domain.SessionState, eventID string, intervalMs int) {
+	format := state.Config.Audio.Input.Format
+	if state.AudioBuffer.GetSize() < intervalMs*format.SampleRate()/1000*format.BytesPerSample() {
+		return
+	}
+	audioData, err := state.AudioBuffer.Commit()
+	if err != nil {
+		u.sendError(conn, eventID, "server_error", domain.CodeBufferError, err.Error(), nil)
+		return
+	}
+	itemID := u.idGen.GenerateItemID()
+	u.linkUtteranceChunk(state.ID, itemID, false)
+	u.commitAndTranscribe(conn, state, itemID, audioData, state.InputMs())
+	state.AudioBuffer.Clear()
+}
+
 // commitAndTranscribe handles the commit flow and triggers transcription
-func (u *SessionUsecase) commitAndTranscribe(conn Conn, state *domain.SessionState, itemID string, audioData []byte) {
+// of audio that ends at endMs of the session's input audio. A turn of a
+// diarized session becomes an item per speaker, the first keeping itemID.
+func (u *SessionUsecase) commitAndTranscribe(conn Conn, state *domain.SessionState, itemID string, audioData []byte, endMs int) {
+	startMs := endMs - audioDurationMs(state, len(audioData))
+	if !state.Config.Diarization || u.speakers == nil {
+		u.commitItem(conn, state, itemID, audioData, startMs, nil)
+		return
+	}
+	for i, turn := range u.diarize(state, audioData) {
+		if i > 0 {
+			itemID = u.idGen.GenerateItemID()
+		}
+		u.commitItem(conn, state, itemID, turn.audio, startMs, turn.speaker)
+		startMs += audioDurationMs(state, len(turn.audio))
+	}
+}
+
+// commitItem adds a user message item of audio starting at startMs of the
+// session's input audio, spoken by speaker (nil if unknown), and
+// transcribes it
+func (u *SessionUsecase) commitItem(conn Conn, state *domain.SessionState, itemID string, audioData []byte, startMs int, speaker *domain.Speaker) {
 	// Create user message item from audio buffer
 	item := domain.NewItem(itemID, "message", "user")
 	item.Status = "completed"
+	item.Speaker = speaker
+	item.AudioStartMs = startMs
+	item.AudioEndMs = startMs + audioDurationMs(state, len(audioData))
 	item.Content = []domain.ContentPart{
 		{
 			Type:   "input_audio",
@@ -551,14 +1120,8 @@ func (u *SessionUsecase) commitAndTranscribe(conn Conn, state *domain.SessionSta
 		},
 	}
 
-	// Get previous item ID before adding new item
-	var previousItemID *string
-	if len(state.Conversation.Order) > 0 {
-		prevID := state.Conversation.Order[len(state.Conversation.Order)-1]
-		previousItemID = &prevID
-	}
-
-	state.Conversation.AddItem(item)
+	previousItemID := state.Conversation.AddItem(item)
+	u.memory.relieve()
 
 	// Send input_audio_buffer.committed event
 	committedEvent := &domain.InputAudioBufferCommittedEvent{
@@ -566,40 +1129,58 @@ func (u *SessionUsecase) commitAndTranscribe(conn Conn, state *domain.SessionSta
 			EventID: u.idGen.GenerateEventID(),
 			Type:    domain.EventInputAudioBufferCommitted,
 		},
-		ItemID:         itemID,
-		PreviousItemID: previousItemID,
+		ItemID:          itemID,
+		PreviousItemID:  previousItemID,
+		AudioDurationMs: audioDurationMs(state, len(audioData)),
 	}
 	conn.WriteJSON(committedEvent)
-
-	// Send conversation.item.created event
-	itemCreatedEvent := &domain.ConversationItemAddedEvent{
-		BaseEvent: domain.BaseEvent{
-			EventID: u.idGen.GenerateEventID(),
-			Type:    domain.EventConversationItemCreated,
-		},
-		Item:           item,
-		PreviousItemID: previousItemID,
+	if state.Config.AudioQuality {
+		u.sendAudioQuality(conn, state, itemID, audioData)
 	}
-	conn.WriteJSON(itemCreatedEvent)
+
+	// Send conversation.item.created, or conversation.item.added for GA
+	u.sendItemAdded(conn, state, item, previousItemID)
 
 	// Trigger transcription asynchronously
-	go u.transcribeAudio(conn, state, itemID, audioData)
+	go u.transcribeAudio(conn, state, itemID, previousItemID, audioData)
 }
 
 // transcribeAudio performs speech-to-text transcription and sends events
-func (u *SessionUsecase) transcribeAudio(conn Conn, state *domain.SessionState, itemID string, audioData []byte) {
-	// Check if ASR provider is configured
-	if u.asrProvider == nil {
-		failedEvent := &domain.ErrorServerEvent{
+func (u *SessionUsecase) transcribeAudio(conn Conn, state *domain.SessionState, itemID string, previousItemID *string, audioData []byte) {
+	u.transcribing.Add(1)
+	defer u.transcribing.Add(-1)
+	defer panics.Recover("transcription of "+itemID, func(error) {
+		conn.WriteJSON(&domain.ConversationItemInputAudioTranscriptionFailedEvent{
 			BaseEvent: domain.BaseEvent{
 				EventID: u.idGen.GenerateEventID(),
 				Type:    domain.EventConversationItemInputAudioTranscriptionFailed,
 			},
-			Error: &domain.ErrorDetail{
-				Type:    "transcription_error",
-				Code:    "provider_not_configured",
-				Message: "ASR provider not configured. Send session.update with audio.input.transcription.model and audio.input.transcription.language first.",
+			ItemID: itemID,
+			Error:  domain.NewErrorDetail("server_error", domain.CodeServerError, "The server failed to transcribe the audio", nil),
+		})
+	})
+
+	// A chunk of a long utterance is transcribed after the chunk before,
+	// whose transcript its own is stitched onto
+	utterance := u.takeUtteranceChunk(itemID)
+	var previousTranscript string
+	if utterance != nil {
+		defer close(utterance.done)
+		previousTranscript = utterance.previous.wait(state.Context())
+		utterance.previous = nil
+	}
+
+	// Check if ASR provider is configured
+	provider := u.providerFor(state)
+	if provider == nil {
+		failedEvent := &domain.ConversationItemInputAudioTranscriptionFailedEvent{
+			BaseEvent: domain.BaseEvent{
+				EventID: u.idGen.GenerateEventID(),
+				Type:    domain.EventConversationItemInputAudioTranscriptionFailed,
 			},
+			ItemID: itemID,
+			Error: domain.NewErrorDetail("transcription_error", domain.CodeProviderNotConfigured,
+				"ASR provider not configured. Send session.update with audio.input.transcription.model and audio.input.transcription.language first.", nil),
 		}
 		conn.WriteJSON(failedEvent)
 		return
@@ -619,24 +1200,51 @@ func (u *SessionUsecase) transcribeAudio(conn Conn, state *domain.SessionState,
 		}
 	}
 
+	// Options the provider cannot use do not reach it, nor split the cache
+	transcriptionConfig, _ = domain.SupportedOptions(provider, transcriptionConfig)
+
+	// Recurring audio is answered from the cache as a single chunk, others
+	// wait for a transcription slot
+	key := cacheKey(audioData, transcriptionConfig)
+	cached, hit := u.cache.get(key)
+	var started time.Time
+	var shadow *shadowRun
+	if !hit {
+		if !u.awaitTranscriptionSlot(conn, state, itemID) {
+			return
+		}
+		started = time.Now()
+		defer func() { u.transcriptions.release(time.Since(started)) }()
+		shadow = u.shadow.start(state, itemID, audioData, transcriptionConfig)
+	}
+
 	// Create context with timeout for transcription
-	ctx, cancel := context.WithTimeout(context.Background(), u.transcriptionTimeout)
+	ctx, cancel := context.WithTimeout(state.Context(), u.transcriptionTimeout)
 	defer cancel()
 
-	// Call ASR provider
-	resultChan, err := u.asrProvider.Transcribe(ctx, audioData, transcriptionConfig)
+	var resultChan <-chan domain.TranscriptionChunk
+	var err error
+	if hit {
+		replay := make(chan domain.TranscriptionChunk, 1)
+		replay <- domain.TranscriptionChunk{Text: cached, IsFinal: true}
+		close(replay)
+		resultChan = replay
+	} else {
+		// Call ASR provider
+		resultChan, err = u.retry.transcribe(ctx, provider, audioData, transcriptionConfig)
+	}
+	if err != nil && state.Context().Err() != nil {
+		return
+	}
 	if err != nil {
 		// Send transcription failed event
-		failedEvent := &domain.ErrorServerEvent{
+		failedEvent := &domain.ConversationItemInputAudioTranscriptionFailedEvent{
 			BaseEvent: domain.BaseEvent{
 				EventID: u.idGen.GenerateEventID(),
 				Type:    domain.EventConversationItemInputAudioTranscriptionFailed,
 			},
-			Error: &domain.ErrorDetail{
-				Type:    "transcription_error",
-				Code:    "transcription_failed",
-				Message: err.Error(),
-			},
+			ItemID: itemID,
+			Error:  domain.NewErrorDetail("transcription_error", domain.CodeTranscriptionFailed, err.Error(), nil),
 		}
 		conn.WriteJSON(failedEvent)
 		return
@@ -644,23 +1252,29 @@ func (u *SessionUsecase) transcribeAudio(conn Conn, state *domain.SessionState,
 
 	// Stream transcription results
 	var fullTranscript string
+	var logprobs []domain.Logprob
+	var words []domain.WordTiming
+	var noSpeechProb float64
 	contentIndex := 0
+	throttle := newDeltaThrottle(u.deltaInterval, u.deltaMinChars)
+	stabilizer := newHypothesisStabilizer(u.stabilityWindow)
 
 	for {
 		select {
 		case <-ctx.Done():
-			// Timeout or cancellation
+			if state.Context().Err() != nil {
+				// The client is gone, so there is nobody to tell
+				log.Printf("Transcription of item %s cancelled: client disconnected", itemID)
+				return
+			}
 			log.Printf("Transcription timeout for item %s", itemID)
-			failedEvent := &domain.ErrorServerEvent{
+			failedEvent := &domain.ConversationItemInputAudioTranscriptionFailedEvent{
 				BaseEvent: domain.BaseEvent{
 					EventID: u.idGen.GenerateEventID(),
 					Type:    domain.EventConversationItemInputAudioTranscriptionFailed,
 				},
-				Error: &domain.ErrorDetail{
-					Type:    "transcription_error",
-					Code:    "transcription_timeout",
-					Message: "Transcription timed out",
-				},
+				ItemID: itemID,
+				Error:  domain.NewErrorDetail("transcription_error", domain.CodeTranscriptionTimeout, "Transcription timed out", nil),
 			}
 			conn.WriteJSON(failedEvent)
 			return
@@ -671,51 +1285,238 @@ func (u *SessionUsecase) transcribeAudio(conn Conn, state *domain.SessionState,
 				goto done
 			}
 
-			fullTranscript += chunk.Text
+			if chunk.Err != nil && state.Context().Err() != nil {
+				return
+			}
+			if chunk.Err != nil {
+				// Provider failed mid-stream
+				log.Printf("Transcription error for item %s: %v", itemID, chunk.Err)
+				failedEvent := &domain.ConversationItemInputAudioTranscriptionFailedEvent{
+					BaseEvent: domain.BaseEvent{
+						EventID: u.idGen.GenerateEventID(),
+						Type:    domain.EventConversationItemInputAudioTranscriptionFailed,
+					},
+					ItemID: itemID,
+					Error:  domain.NewErrorDetail("transcription_error", domain.CodeTranscriptionFailed, chunk.Err.Error(), nil),
+				}
+				conn.WriteJSON(failedEvent)
+				return
+			}
 
-			// Send delta event for each chunk
-			deltaEvent := &domain.ConversationItemInputAudioTranscriptionDeltaEvent{
-				BaseEvent: domain.BaseEvent{
-					EventID: u.idGen.GenerateEventID(),
-					Type:    domain.EventConversationItemInputAudioTranscriptionDelta,
-				},
-				ItemID:       itemID,
-				ContentIndex: contentIndex,
-				Delta:        chunk.Text,
+			// Revisable hypotheses only release their stable prefix
+			text := chunk.Text
+			if chunk.Hypothesis != "" {
+				text = stabilizer.Update(chunk.Hypothesis)
+			}
+			fullTranscript += text
+			logprobs = append(logprobs, chunk.Logprobs...)
+			words = append(words, chunk.Words...)
+			if chunk.NoSpeechProb > 0 {
+				noSpeechProb = chunk.NoSpeechProb
+			}
+
+			// Final-only sessions get just the completed transcript
+			if !state.Config.DeltasEnabled() {
+				continue
+			}
+
+			// Send a delta event unless the throttle holds the text back
+			if delta := throttle.Add(text); delta != "" {
+				u.sendTranscriptionDelta(conn, itemID, contentIndex, delta)
 			}
-			conn.WriteJSON(deltaEvent)
-			log.Printf("Transcription delta: %s", chunk.Text)
 		}
 	}
 
 done:
+	// Providers close their results when the client disconnects mid-turn
+	if state.Context().Err() != nil {
+		log.Printf("Transcription of item %s cancelled: client disconnected", itemID)
+		return
+	}
+
+	// Release words held back as unstable, then anything the throttle holds,
+	// so deltas always add up to the completed transcript
+	rest := stabilizer.Flush()
+	fullTranscript += rest
+	if delta := throttle.Add(rest) + throttle.Flush(); delta != "" && state.Config.DeltasEnabled() {
+		u.sendTranscriptionDelta(conn, itemID, contentIndex, delta)
+	}
+	if final := stabilizer.Transcript(); final != "" {
+		// The recognizer's final hypothesis is authoritative
+		fullTranscript = final
+	}
+	var took time.Duration
+	if !hit {
+		u.cache.put(key, fullTranscript)
+		took = time.Since(started)
+		shadow.compare(fullTranscript, took)
+	}
+	if utterance != nil {
+		utterance.transcript = fullTranscript
+		var repeated int
+		fullTranscript, repeated = stitchTranscripts(previousTranscript, fullTranscript)
+		words = words[min(repeated, len(words)):]
+	}
+	usage := u.recordTranscription(state, audioSeconds(state, len(audioData)), fullTranscript, took)
+
 	// Send completed event
 	completedEvent := &domain.ConversationItemInputAudioTranscriptionCompletedEvent{
 		BaseEvent: domain.BaseEvent{
 			EventID: u.idGen.GenerateEventID(),
 			Type:    domain.EventConversationItemInputAudioTranscriptionCompleted,
 		},
-		ItemID:       itemID,
-		ContentIndex: contentIndex,
-		Transcript:   fullTranscript,
+		ItemID:          itemID,
+		ContentIndex:    contentIndex,
+		Transcript:      fullTranscript,
+		Usage:           usage,
+		AudioDurationMs: audioDurationMs(state, len(audioData)),
+	}
+	if state.Config.Includes(domain.IncludeLogprobs) {
+		completedEvent.Logprobs = logprobs
+	}
+	if state.Config.Includes(domain.IncludeWords) {
+		completedEvent.Words = words
+		completedEvent.NoSpeechProb = noSpeechProb
+	}
+	if item := state.Conversation.GetItem(itemID); item != nil && item.Speaker != nil {
+		completedEvent.Speaker = item.Speaker
+	} else if state.Config.Includes(domain.IncludeSpeaker) {
+		completedEvent.Speaker = u.identifySpeaker(state, itemID, audioData)
 	}
 	conn.WriteJSON(completedEvent)
 	log.Printf("Transcription completed: %s", fullTranscript)
 
-	// Update item with transcript
-	if item := state.Conversation.GetItem(itemID); item != nil && len(item.Content) > 0 {
-		item.Content[0].Transcript = fullTranscript
+	// Update item with transcript, which finalizes it
+	if item := state.Conversation.SetTranscript(itemID, fullTranscript, words); item != nil {
+		u.sendItemDone(conn, state, item, previousItemID)
+	}
+
+	// Report the API key's remaining quota, charged as the audio arrived
+	if u.quota.Enabled(quotaKey(state)) {
+		u.sendRateLimits(conn, state)
+	}
+
+	// The provider closed its results, so it is done with the audio
+	bufpool.PutBytes(audioData)
+}
+
+// identifySpeaker returns the enrolled speaker of a turn's audio, nil if
+// identification is disabled or no profile matches
+func (u *SessionUsecase) identifySpeaker(state *domain.SessionState, itemID string, audio []byte) *domain.Speaker {
+	if u.speakers == nil {
+		return nil
+	}
+	var format *domain.AudioFormat
+	if state.Config.Audio != nil && state.Config.Audio.Input != nil {
+		format = state.Config.Audio.Input.Format
+	}
+	samples := inputSamples(audio, format)
+	defer bufpool.PutFloat32s(samples)
+	speaker, err := u.speakers.Identify(samples, format.SampleRate())
+	if err != nil {
+		log.Printf("[WARN] Speaker identification of item %s failed: %v", itemID, err)
+	}
+	return speaker
+}
+
+// awaitTranscriptionSlot waits until the transcription queue admits the item,
+// telling the client while it waits. It returns false if the session ended or
+// the client deleted the item meanwhile.
+func (u *SessionUsecase) awaitTranscriptionSlot(conn Conn, state *domain.SessionState, itemID string) bool {
+	ctx, cancel := context.WithCancel(state.Context())
+	defer cancel()
+	u.queuedItems.Store(itemID, cancel)
+	defer u.queuedItems.Delete(itemID)
+	if state.Conversation.GetItem(itemID) == nil {
+		// Deleted before it could be queued
+		return false
+	}
+
+	return u.transcriptions.acquire(ctx, func(position int, wait time.Duration) {
+		log.Printf("Transcription of item %s queued at position %d", itemID, position)
+		conn.WriteJSON(&domain.ConversationItemInputAudioTranscriptionQueuedEvent{
+			BaseEvent: domain.BaseEvent{
+				EventID: u.idGen.GenerateEventID(),
+				Type:    domain.EventConversationItemInputAudioTranscriptionQueued,
+			},
+			ItemID:          itemID,
+			QueuePosition:   position,
+			EstimatedWaitMs: int(wait / time.Millisecond),
+		})
+	})
+}
+
+// sendTranscriptionDelta sends a conversation.item.input_audio_transcription.delta event
+func (u *SessionUsecase) sendTranscriptionDelta(conn Conn, itemID string, contentIndex int, delta string) {
+	conn.WriteJSON(&domain.ConversationItemInputAudioTranscriptionDeltaEvent{
+		BaseEvent: domain.BaseEvent{
+			EventID: u.idGen.GenerateEventID(),
+			Type:    domain.EventConversationItemInputAudioTranscriptionDelta,
+		},
+		ItemID:       itemID,
+		ContentIndex: contentIndex,
+		Delta:        delta,
+	})
+	log.Printf("Transcription delta: %s", delta)
+}
+
+// sendRateLimits sends the session's remaining audio quota as rate_limits.updated
+func (u *SessionUsecase) sendRateLimits(conn Conn, state *domain.SessionState) {
+	conn.WriteJSON(&domain.RateLimitsUpdatedEvent{
+		BaseEvent: domain.BaseEvent{
+			EventID: u.idGen.GenerateEventID(),
+			Type:    domain.EventRateLimitsUpdated,
+		},
+		RateLimits: u.quota.RateLimits(quotaKey(state)),
+	})
+}
+
+// chargeQuota accounts seconds of appended audio against the session's API
+// key, warning when it goes over a soft limit
+func (u *SessionUsecase) chargeQuota(state *domain.SessionState, seconds float64) {
+	if !u.quota.Enabled(quotaKey(state)) {
+		return
+	}
+	exceeded := u.quota.Exceeded(quotaKey(state))
+	u.quota.Record(quotaKey(state), seconds)
+	if !exceeded && u.quota.Exceeded(quotaKey(state)) && u.quota.SoftLimit(quotaKey(state)) {
+		log.Printf("[WARN] Session %s is over its audio quota (soft limit)", state.ID)
+	}
+}
+
+// audioSeconds returns the duration of n bytes of mono audio in the session's input format
+func audioSeconds(state *domain.SessionState, n int) float64 {
+	var format *domain.AudioFormat
+	if audio := state.Config.Audio; audio != nil && audio.Input != nil {
+		format = audio.Input.Format
+	}
+	return float64(n) / float64(format.BytesPerSample()) / float64(format.SampleRate())
+}
+
+// audioDurationMs returns the duration of n bytes of the session's input
+// audio in milliseconds
+func audioDurationMs(state *domain.SessionState, n int) int {
+	return int(math.Round(audioSeconds(state, n) * 1000))
+}
+
+// applyBufferWindow sizes the audio buffer's rolling window from the
+// session's buffer_window_ms in its input format
+func applyBufferWindow(state *domain.SessionState) {
+	var format *domain.AudioFormat
+	if audio := state.Config.Audio; audio != nil && audio.Input != nil {
+		format = audio.Input.Format
 	}
+	state.AudioBuffer.SetWindow(state.Config.BufferWindowMs * format.SampleRate() / 1000 * format.BytesPerSample())
 }
 
 func (u *SessionUsecase) handleInputAudioBufferClear(conn Conn, state *domain.SessionState, message []byte) {
 	var event domain.InputAudioBufferClearEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_event", "Failed to parse input_audio_buffer.clear", nil)
+	if !u.decodeClientEvent(conn, message, &event) {
 		return
 	}
 
 	state.AudioBuffer.Clear()
+	u.utterances.Delete(state.ID)
 
 	// Send input_audio_buffer.cleared event
 	clearedEvent := &domain.InputAudioBufferClearedEvent{
@@ -734,13 +1535,7 @@ func (u *SessionUsecase) handleInputAudioBufferClear(conn Conn, state *domain.Se
 
 func (u *SessionUsecase) handleConversationItemCreate(conn Conn, state *domain.SessionState, message []byte) {
 	var event domain.ConversationItemCreateClientEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_event", "Failed to parse conversation.item.create", nil)
-		return
-	}
-
-	if event.Item == nil {
-		u.sendError(conn, event.EventID, "invalid_request_error", "missing_field", "item field is required", "item")
+	if !u.decodeClientEvent(conn, message, &event) {
 		return
 	}
 
@@ -759,36 +1554,26 @@ func (u *SessionUsecase) handleConversationItemCreate(conn Conn, state *domain.S
 
 	state.Conversation.AddItem(event.Item)
 
-	// Send conversation.item.created event
-	createdEvent := &domain.ConversationItemAddedEvent{
-		BaseEvent: domain.BaseEvent{
-			EventID: u.idGen.GenerateEventID(),
-			Type:    domain.EventConversationItemCreated,
-		},
-		Item:           event.Item,
-		PreviousItemID: event.PreviousItemID,
-	}
-
-	conn.WriteJSON(createdEvent)
+	// Client items are final as created
+	u.sendItemAdded(conn, state, event.Item, event.PreviousItemID)
+	u.sendItemDone(conn, state, event.Item, event.PreviousItemID)
 }
 
 func (u *SessionUsecase) handleConversationItemDelete(conn Conn, state *domain.SessionState, message []byte) {
 	var event domain.ConversationItemDeleteEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_event", "Failed to parse conversation.item.delete", nil)
-		return
-	}
-
-	if event.ItemID == "" {
-		u.sendError(conn, event.EventID, "invalid_request_error", "missing_field", "item_id field is required", "item_id")
+	if !u.decodeClientEvent(conn, message, &event) {
 		return
 	}
 
 	if !state.Conversation.DeleteItem(event.ItemID) {
-		u.sendError(conn, event.EventID, "invalid_request_error", "item_not_found",
+		u.sendError(conn, event.EventID, "invalid_request_error", domain.CodeItemNotFound,
 			fmt.Sprintf("Item not found: %s", event.ItemID), nil)
 		return
 	}
+	// Deleting an item waiting for a transcription slot cancels its transcription
+	if cancel, ok := u.queuedItems.Load(event.ItemID); ok {
+		cancel.(context.CancelFunc)()
+	}
 
 	// Send conversation.item.deleted event
 	deletedEvent := &domain.ConversationItemDeletedEvent{
@@ -804,19 +1589,13 @@ func (u *SessionUsecase) handleConversationItemDelete(conn Conn, state *domain.S
 
 func (u *SessionUsecase) handleConversationItemTruncate(conn Conn, state *domain.SessionState, message []byte) {
 	var event domain.ConversationItemTruncateEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_event", "Failed to parse conversation.item.truncate", nil)
-		return
-	}
-
-	if event.ItemID == "" {
-		u.sendError(conn, event.EventID, "invalid_request_error", "missing_field", "item_id field is required", "item_id")
+	if !u.decodeClientEvent(conn, message, &event) {
 		return
 	}
 
 	item := state.Conversation.GetItem(event.ItemID)
 	if item == nil {
-		u.sendError(conn, event.EventID, "invalid_request_error", "item_not_found",
+		u.sendError(conn, event.EventID, "invalid_request_error", domain.CodeItemNotFound,
 			fmt.Sprintf("Item not found: %s", event.ItemID), nil)
 		return
 	}
@@ -841,8 +1620,7 @@ func (u *SessionUsecase) handleConversationItemTruncate(conn Conn, state *domain
 
 func (u *SessionUsecase) handleResponseCreate(conn Conn, state *domain.SessionState, message []byte) {
 	var event domain.ResponseCreateClientEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_event", "Failed to parse response.create", nil)
+	if !u.decodeClientEvent(conn, message, &event) {
 		return
 	}
 
@@ -965,18 +1743,8 @@ func (u *SessionUsecase) handleResponseCreate(conn Conn, state *domain.SessionSt
 	// Mark response as completed
 	response.Status = "completed"
 	response.Output = []domain.Item{*assistantItem}
-	response.Usage = &domain.Usage{
-		TotalTokens:  50,
-		InputTokens:  20,
-		OutputTokens: 30,
-		InputTokenDetails: &domain.TokenDetails{
-			TextTokens:  10,
-			AudioTokens: 10,
-		},
-		OutputTokenDetails: &domain.TokenDetails{
-			TextTokens: 30,
-		},
-	}
+	response.Usage = responseUsage(state.Conversation.List(), textDoneEvent.Text)
+	u.recordResponse(state, response.Usage)
 
 	// Send response.done
 	doneEvent := &domain.ResponseDoneEvent{
@@ -995,13 +1763,12 @@ func (u *SessionUsecase) handleResponseCreate(conn Conn, state *domain.SessionSt
 
 func (u *SessionUsecase) handleResponseCancel(conn Conn, state *domain.SessionState, message []byte) {
 	var event domain.ResponseCancelEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		u.sendError(conn, "", "invalid_request_error", "invalid_event", "Failed to parse response.cancel", nil)
+	if !u.decodeClientEvent(conn, message, &event) {
 		return
 	}
 
 	if state.CurrentResponse == nil {
-		u.sendError(conn, event.EventID, "invalid_request_error", "no_active_response", "No active response to cancel", nil)
+		u.sendError(conn, event.EventID, "invalid_request_error", domain.CodeNoActiveResponse, "No active response to cancel", nil)
 		return
 	}
 
@@ -1031,14 +1798,9 @@ func (u *SessionUsecase) sendError(conn Conn, clientEventID, errType, code, mess
 			EventID: u.idGen.GenerateEventID(),
 			Type:    domain.EventError,
 		},
-		Error: &domain.ErrorDetail{
-			Type:    errType,
-			Code:    code,
-			Message: message,
-			Param:   param,
-			EventID: clientEventID,
-		},
+		Error: domain.NewErrorDetail(errType, code, message, param),
 	}
+	errorEvent.Error.EventID = clientEventID
 
 	if err := conn.WriteJSON(errorEvent); err != nil {
 		log.Printf("Failed to send error event: %v", err)
@@ -1,11 +1,19 @@
 package usecase
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/aira-id/gribe/internal/domain"
+	"github.com/aira-id/gribe/internal/pkg/audiogen"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
 // TestSessionManager tests
@@ -76,6 +84,122 @@ func TestAudioBufferSizeLimit(t *testing.T) {
 	}
 }
 
+func TestAudioBufferAppendBase64(t *testing.T) {
+	ab := NewAudioBufferWithMaxSize(6)
+
+	chunk, err := ab.AppendBase64([]byte("AQID"))
+	if err != nil || !bytes.Equal(chunk, []byte{1, 2, 3}) {
+		t.Fatalf("Expected chunk [1 2 3], got %v %v", chunk, err)
+	}
+	if _, err := ab.AppendBase64([]byte("not base64!")); err == nil {
+		t.Error("Expected invalid base64 to fail")
+	}
+	if _, err := ab.AppendBase64([]byte("BAUGBw==")); err != domain.ErrBufferFull {
+		t.Errorf("Expected ErrBufferFull, got %v", err)
+	}
+	if chunk, err := ab.AppendBase64([]byte("BAUG")); err != nil || !bytes.Equal(chunk, []byte{4, 5, 6}) {
+		t.Fatalf("Expected chunk [4 5 6], got %v %v", chunk, err)
+	}
+	if data := ab.GetData(); !bytes.Equal(data, []byte{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("Expected failed appends to leave the buffer unchanged, got %v", data)
+	}
+}
+
+func TestAudioBufferSpill(t *testing.T) {
+	dir := t.TempDir()
+	ab := NewAudioBufferWithMaxSize(10)
+	ab.SetSpill(4, spillOpener(dir))
+
+	ab.Append([]byte{1, 2, 3})
+	if ab.IsSpilled() {
+		t.Fatal("Expected the buffer to stay in memory below the threshold")
+	}
+	if chunk, err := ab.AppendBase64([]byte("BAUG")); err != nil || !bytes.Equal(chunk, []byte{4, 5, 6}) {
+		t.Fatalf("Expected chunk [4 5 6], got %v %v", chunk, err)
+	}
+	if !ab.IsSpilled() || len(ab.Data) != 0 {
+		t.Fatalf("Expected the buffer to spill past the threshold, %d bytes in memory", len(ab.Data))
+	}
+	ab.Append([]byte{7, 8})
+	if err := ab.Append([]byte{9, 10, 11}); err != domain.ErrBufferFull {
+		t.Errorf("Expected ErrBufferFull counting spilled bytes, got %v", err)
+	}
+	if ab.GetSize() != 8 {
+		t.Errorf("Expected 8 bytes, got %d", ab.GetSize())
+	}
+
+	data, err := ab.Commit()
+	if err != nil || !bytes.Equal(data, []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Fatalf("Expected the spilled audio back, got %v %v", data, err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected the spill file to be removed, found %d", len(files))
+	}
+
+	ab.Clear()
+	ab.Append([]byte{1, 2, 3, 4, 5})
+	ab.Clear()
+	if files, _ := os.ReadDir(dir); len(files) != 0 || !ab.IsEmpty() {
+		t.Errorf("Expected Clear to remove the spill file, found %d", len(files))
+	}
+}
+
+func TestAudioBufferWindow(t *testing.T) {
+	uc := NewSessionUsecase()
+	state := uc.sessionManager.CreateSession("sess_1", "model", "conv_1")
+	state.AudioBuffer.SetMaxSize(4000)
+
+	// 100ms of 16kHz PCM16 is 3200 bytes
+	conn := &recordingConn{}
+	uc.ProcessMessage(conn, state, []byte(`{"type":"session.update","session":{"buffer_window_ms":100,`+
+		`"audio":{"input":{"format":{"type":"audio/pcm","rate":16000},"turn_detection":null}}}}`))
+
+	chunk := make([]byte, 1000)
+	for i := 0; i < 5; i++ {
+		chunk[0] = byte(i)
+		message, _ := json.Marshal(&domain.InputAudioBufferAppendEvent{
+			BaseEvent: domain.BaseEvent{Type: domain.EventInputAudioBufferAppend},
+			Audio:     base64.StdEncoding.EncodeToString(chunk),
+		})
+		uc.ProcessMessage(conn, state, message)
+	}
+	for _, event := range conn.events {
+		if _, ok := event.(*domain.ErrorServerEvent); ok {
+			t.Fatalf("Expected appends past the max size to roll, got %+v", event)
+		}
+	}
+	data := state.AudioBuffer.GetData()
+	if len(data) != 3200 {
+		t.Fatalf("Expected the last 3200 bytes, got %d", len(data))
+	}
+	if data[2200] != 4 || data[1200] != 3 {
+		t.Errorf("Expected the window to end with the newest chunk")
+	}
+
+	// Disabling the window restores the buffer_full limit
+	uc.ProcessMessage(conn, state, []byte(`{"type":"session.update","session":{"buffer_window_ms":0}}`))
+	uc.ProcessMessage(conn, state, []byte(`{"type":"input_audio_buffer.append","audio":"`+
+		base64.StdEncoding.EncodeToString(chunk)+`"}`))
+	if detail := conn.lastError(t); detail.Code != "buffer_full" {
+		t.Errorf("Expected buffer_full, got %s", detail.Code)
+	}
+}
+
+func TestAppendEscapedAudio(t *testing.T) {
+	uc := NewSessionUsecase()
+	state := uc.sessionManager.CreateSession("sess_1", "model", "conv_1")
+
+	// "//8=" with the slashes escaped, as some JSON encoders do
+	conn := &recordingConn{}
+	uc.ProcessMessage(conn, state, []byte(`{"type":"input_audio_buffer.append","audio":"\/\/8="}`))
+	if len(conn.events) != 0 {
+		t.Fatalf("Expected no response, got %+v", conn.events)
+	}
+	if data := state.AudioBuffer.GetData(); !bytes.Equal(data, []byte{0xff, 0xff}) {
+		t.Errorf("Expected [255 255], got %v", data)
+	}
+}
+
 func TestNewSessionConfiguration(t *testing.T) {
 	session := domain.NewSession("sess_1", "model")
 
@@ -320,6 +444,149 @@ func TestSimpleVADProvider(t *testing.T) {
 	}
 }
 
+// pcmTone returns ms of 24kHz 16-bit PCM at a constant amplitude
+func pcmTone(ms int, amplitude int16) []byte {
+	pcm := make([]byte, ms*24*2)
+	for i := 0; i < len(pcm); i += 2 {
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(amplitude))
+	}
+	return pcm
+}
+
+func TestVADPrefixPadding(t *testing.T) {
+	config := domain.NewDefaultVADConfig() // 24kHz, 300ms prefix, 500ms silence
+	vad := NewSimpleVADProvider(config)
+	defer vad.Close()
+
+	ctx := context.Background()
+	vad.ProcessAudio(ctx, pcmTone(200, 1))
+	vad.ProcessAudio(ctx, pcmTone(200, 2)) // Only the last 300ms of pre-speech audio is kept
+	vad.ProcessAudio(ctx, pcmTone(100, 10000))
+	vad.ProcessAudio(ctx, pcmTone(600, 0))
+
+	started := <-vad.GetEvents()
+	if started.Type != domain.VADEventSpeechStarted || started.StartMs != 100 {
+		t.Fatalf("Expected speech_started at 100ms, got %+v", started)
+	}
+
+	stopped := <-vad.GetEvents()
+	if stopped.Type != domain.VADEventSpeechStopped {
+		t.Fatalf("Expected speech_stopped, got %+v", stopped)
+	}
+	if want := len(pcmTone(300+100+600, 0)); len(stopped.AudioData) != want {
+		t.Fatalf("Expected %d bytes including prefix padding, got %d", want, len(stopped.AudioData))
+	}
+	// 100ms of the first chunk, then the second chunk, then speech
+	if got := int16(binary.LittleEndian.Uint16(stopped.AudioData)); got != 1 {
+		t.Errorf("Expected segment to start with pre-speech audio, got sample %d", got)
+	}
+	if got := int16(binary.LittleEndian.Uint16(stopped.AudioData[len(pcmTone(300, 0)):])); got != 10000 {
+		t.Errorf("Expected speech after 300ms of padding, got sample %d", got)
+	}
+}
+
+func TestVADG711Format(t *testing.T) {
+	config := domain.NewDefaultVADConfig()
+	config.ApplyFormat(&domain.AudioFormat{Type: "audio/pcmu", Rate: 24000})
+	if config.SampleRate != 8000 || config.Encoding != domain.EncodingG711Ulaw {
+		t.Fatalf("Expected 8kHz μ-law VAD config, got %+v", config)
+	}
+
+	vad := NewSimpleVADProvider(config)
+	defer vad.Close()
+
+	ulaw := func(ms int, sample byte) []byte {
+		return bytes.Repeat([]byte{sample}, ms*8)
+	}
+	ctx := context.Background()
+	vad.ProcessAudio(ctx, ulaw(400, 0xFF)) // Silence
+	vad.ProcessAudio(ctx, ulaw(100, 0x80)) // Loud
+	vad.ProcessAudio(ctx, ulaw(600, 0xFF))
+
+	started := <-vad.GetEvents()
+	if started.Type != domain.VADEventSpeechStarted || started.StartMs != 100 {
+		t.Fatalf("Expected speech_started at 100ms, got %+v", started)
+	}
+	stopped := <-vad.GetEvents()
+	if stopped.Type != domain.VADEventSpeechStopped || stopped.EndMs != 500 {
+		t.Fatalf("Expected speech_stopped at 500ms, got %+v", stopped)
+	}
+	if want := len(ulaw(300+100+600, 0)); len(stopped.AudioData) != want {
+		t.Errorf("Expected %d bytes of μ-law audio, got %d", want, len(stopped.AudioData))
+	}
+}
+
+func TestVADAdaptiveNoiseFloor(t *testing.T) {
+	config := domain.NewDefaultVADConfig()
+	config.PrefixPaddingMs = 0
+	vad := NewSimpleVADProvider(config)
+	defer vad.Close()
+
+	// Steady ambient noise well above a fixed threshold is not speech
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		vad.ProcessAudio(ctx, pcmTone(100, 1500))
+	}
+	if vad.IsSpeaking() {
+		t.Fatal("Expected ambient noise to be treated as silence")
+	}
+	if vad.noiseFloor < 1400 || vad.noiseFloor > 1600 {
+		t.Errorf("Expected noise floor near 1500, got %.0f", vad.noiseFloor)
+	}
+
+	// Speech well above the ambient level is detected
+	vad.ProcessAudio(ctx, pcmTone(100, 9000))
+	if !vad.IsSpeaking() {
+		t.Fatal("Expected speech above the noise floor to be detected")
+	}
+
+	// Hysteresis: a dip below the start level but above the stop level continues speech
+	start, stop := vad.energyLevels()
+	vad.ProcessAudio(ctx, pcmTone(600, int16((start+stop)/2)))
+	if !vad.IsSpeaking() {
+		t.Error("Expected speech to continue between the stop and start levels")
+	}
+	vad.ProcessAudio(ctx, pcmTone(600, 1500))
+	if vad.IsSpeaking() {
+		t.Error("Expected speech to stop at the ambient level")
+	}
+}
+
+func TestVADSyntheticSpeech(t *testing.T) {
+	config := domain.NewDefaultVADConfig()
+	config.PrefixPaddingMs = 0
+	vad := NewSimpleVADProvider(config)
+	defer vad.Close()
+
+	// Turns of speech over street noise, streamed in 20ms chunks
+	spec := audiogen.Spec{
+		SampleRate: config.SampleRate,
+		Segments:   audiogen.Turns(3, 1200*time.Millisecond, 800*time.Millisecond),
+		NoiseLevel: 0.02,
+	}
+	ctx := context.Background()
+	for _, chunk := range spec.Chunks(20 * time.Millisecond) {
+		vad.ProcessAudio(ctx, chunk)
+	}
+
+	for i, span := range spec.Speech() {
+		started, stopped := <-vad.GetEvents(), <-vad.GetEvents()
+		if started.Type != domain.VADEventSpeechStarted || stopped.Type != domain.VADEventSpeechStopped {
+			t.Fatalf("Turn %d: expected speech_started and speech_stopped, got %s and %s", i, started.Type, stopped.Type)
+		}
+		// Onsets fade in and syllables fade out, so boundaries may be a little late or early
+		start, end := int(span.Start.Milliseconds()), int(span.End.Milliseconds())+config.SilenceDurationMs
+		if started.StartMs < start || started.StartMs > start+100 || stopped.EndMs < end-300 || stopped.EndMs > end+40 {
+			t.Errorf("Turn %d: expected speech at %d-%dms, detected %d-%dms", i, start, end, started.StartMs, stopped.EndMs)
+		}
+	}
+	select {
+	case event := <-vad.GetEvents():
+		t.Errorf("Expected noise between turns not to be speech, got %+v", event)
+	default:
+	}
+}
+
 func TestTranscriptionEventSerialization(t *testing.T) {
 	deltaEvent := &domain.ConversationItemInputAudioTranscriptionDeltaEvent{
 		BaseEvent: domain.BaseEvent{
@@ -419,3 +686,322 @@ func TestIDGeneratorUUID(t *testing.T) {
 		ids[id] = true
 	}
 }
+
+// recordingConn captures server events written by the usecase
+type recordingConn struct {
+	events []interface{}
+}
+
+func (c *recordingConn) WriteJSON(v interface{}) error {
+	c.events = append(c.events, v)
+	return nil
+}
+
+func (c *recordingConn) ReadMessage() (int, []byte, error) { return 0, nil, nil }
+
+func (c *recordingConn) Close() error { return nil }
+
+func (c *recordingConn) lastError(t *testing.T) *domain.ErrorDetail {
+	t.Helper()
+	if len(c.events) == 0 {
+		t.Fatal("Expected an error event, got none")
+	}
+	event, ok := c.events[len(c.events)-1].(*domain.ErrorServerEvent)
+	if !ok {
+		t.Fatalf("Expected *domain.ErrorServerEvent, got %T", c.events[len(c.events)-1])
+	}
+	return event.Error
+}
+
+func TestClientEventValidation(t *testing.T) {
+	uc := NewSessionUsecase()
+	state := uc.sessionManager.CreateSession("sess_1", "model", "conv_1")
+
+	tests := []struct {
+		name    string
+		message string
+		code    string
+		param   interface{}
+	}{
+		{"unknown field", `{"type":"input_audio_buffer.append","audio":"AAAA","bogus":1}`, "unknown_field", "bogus"},
+		{"wrong type", `{"type":"session.update","session":{"audio":{"input":{"format":{"rate":"fast"}}}}}`, "invalid_type", "session.audio.input.format.rate"},
+		{"audio wrong type", `{"type":"input_audio_buffer.append","audio":123}`, "invalid_type", "audio"},
+		{"audio missing", `{"type":"input_audio_buffer.append"}`, "missing_field", "audio"},
+		{"audio not base64", `{"type":"input_audio_buffer.append","audio":"not base64!"}`, "invalid_audio", "audio"},
+		{"missing field", `{"type":"conversation.item.delete","event_id":"evt_c1"}`, "missing_field", "item_id"},
+		{"invalid value", `{"type":"session.update","session":{"audio":{"input":{"turn_detection":{"threshold":2}}}}}`, "invalid_value", "session.audio.input.turn_detection.threshold"},
+		{"unsupported voice", `{"type":"session.update","session":{"audio":{"output":{"voice":"robot"}}}}`, "invalid_value", "session.audio.output.voice"},
+		{"transcription temperature", `{"type":"session.update","session":{"audio":{"input":{"transcription":{"temperature":2}}}}}`, "invalid_value", "session.audio.input.transcription.temperature"},
+		{"echo tail", `{"type":"session.update","session":{"audio":{"input":{"echo_cancellation":{"tail_ms":1000}}}}}`, "invalid_value", "session.audio.input.echo_cancellation.tail_ms"},
+		{"reference without echo cancellation", `{"type":"output_audio_buffer.append","audio":"AAAA"}`, "echo_cancellation_disabled", nil},
+		{"G.711 rate", `{"type":"session.update","session":{"audio":{"input":{"format":{"type":"audio/pcmu","rate":16000}}}}}`, "invalid_value", "session.audio.input.format.rate"},
+		{"unsupported modality", `{"type":"session.update","session":{"output_modalities":["video"]}}`, "invalid_value", "session.output_modalities"},
+		{"invalid json", `{"type":`, "invalid_json", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &recordingConn{}
+			uc.ProcessMessage(conn, state, []byte(tt.message))
+
+			detail := conn.lastError(t)
+			if detail.Code != tt.code {
+				t.Errorf("Expected code %s, got %s (%s)", tt.code, detail.Code, detail.Message)
+			}
+			if detail.Param != tt.param {
+				t.Errorf("Expected param %v, got %v", tt.param, detail.Param)
+			}
+		})
+	}
+
+	// The client event_id is echoed back
+	conn := &recordingConn{}
+	uc.ProcessMessage(conn, state, []byte(`{"type":"conversation.item.delete","event_id":"evt_c1"}`))
+	if detail := conn.lastError(t); detail.EventID != "evt_c1" {
+		t.Errorf("Expected event_id evt_c1, got %s", detail.EventID)
+	}
+}
+
+func TestHandlerPanicRecovered(t *testing.T) {
+	uc := NewSessionUsecase()
+	state := uc.sessionManager.CreateSession("sess_1", "model", "conv_1")
+	conn := &recordingConn{}
+
+	// A session without a buffer makes the append handler panic
+	buffer := state.AudioBuffer
+	state.AudioBuffer = nil
+	uc.ProcessMessage(conn, state, []byte(`{"type":"input_audio_buffer.append","event_id":"evt_p1","audio":"AAAA"}`))
+	detail := conn.lastError(t)
+	if detail.Code != domain.CodeServerError || detail.EventID != "evt_p1" || !detail.Retryable {
+		t.Fatalf("Expected a retryable server_error for evt_p1, got %+v", detail)
+	}
+
+	// The session keeps handling events
+	state.AudioBuffer = buffer
+	conn.events = nil
+	uc.ProcessMessage(conn, state, []byte(`{"type":"input_audio_buffer.append","audio":"AAAA"}`))
+	if len(conn.events) != 0 || state.AudioBuffer.GetSize() != 3 {
+		t.Fatalf("Expected the append to succeed after the panic, got %v and %d bytes", conn.events, state.AudioBuffer.GetSize())
+	}
+}
+
+func TestEchoCancellation(t *testing.T) {
+	uc := NewSessionUsecase()
+	state := uc.sessionManager.CreateSession("sess_1", "model", "conv_1")
+	state.Config.Audio.Input.EchoCancellation = &domain.EchoCancellation{TailMs: 20}
+
+	// The client plays noise, which its microphone picks up 5ms later
+	conn := &recordingConn{}
+	rate := state.Config.Audio.Input.Format.SampleRate()
+	chunk := rate / 50
+	played := make([]byte, 2*chunk)
+	var previous []int16
+	seed := uint32(1)
+	for i := 0; i < 100; i++ {
+		heard := make([]byte, 2*chunk)
+		samples := make([]int16, chunk)
+		for j := range samples {
+			seed = seed*1664525 + 1013904223
+			samples[j] = int16(seed>>20) - 2048
+			binary.LittleEndian.PutUint16(played[2*j:], uint16(samples[j]))
+			if k := j - rate/200; k >= 0 {
+				binary.LittleEndian.PutUint16(heard[2*j:], uint16(samples[k]/2))
+			} else if previous != nil {
+				binary.LittleEndian.PutUint16(heard[2*j:], uint16(previous[chunk+k]/2))
+			}
+		}
+		previous = samples
+		state.AudioBuffer.Clear()
+		uc.ProcessMessage(conn, state, []byte(`{"type":"output_audio_buffer.append","audio":"`+base64.StdEncoding.EncodeToString(played)+`"}`))
+		uc.ProcessMessage(conn, state, []byte(`{"type":"input_audio_buffer.append","audio":"`+base64.StdEncoding.EncodeToString(heard)+`"}`))
+	}
+	if len(conn.events) != 0 {
+		t.Fatalf("Expected no events, got %+v", conn.events)
+	}
+
+	// After two seconds the echo is gone from the buffer
+	var peak int16
+	for i := 0; i+1 < len(state.AudioBuffer.GetData()); i += 2 {
+		peak = max(peak, int16(binary.LittleEndian.Uint16(state.AudioBuffer.GetData()[i:])))
+	}
+	if peak > 100 {
+		t.Errorf("Expected the echo (peak 1024) cancelled, got a peak of %d", peak)
+	}
+}
+
+func TestSessionUpdateExplicitNull(t *testing.T) {
+	sm := NewSessionManager()
+	sm.CreateSession("sess", "gpt-realtime-2025-08-28", "conv")
+
+	update := func(message string) *domain.SessionState {
+		var event domain.SessionUpdateClientEvent
+		if err := json.Unmarshal([]byte(message), &event); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		state, err := sm.UpdateSession("sess", event.Session, domain.ParseSessionFields([]byte(message)))
+		if err != nil {
+			t.Fatalf("UpdateSession failed: %v", err)
+		}
+		return state
+	}
+
+	// Absent fields are left alone
+	state := update(`{"type":"session.update","session":{"audio":{"input":{"format":{"type":"audio/pcm","rate":16000}}}}}`)
+	if state.Config.Audio.Input.TurnDetection == nil || state.Config.Instructions == "" {
+		t.Fatal("Expected absent fields to keep their values")
+	}
+
+	// Explicit null and empty values clear them
+	state = update(`{"type":"session.update","session":{"instructions":"","audio":{"input":{"turn_detection":null}}}}`)
+	if state.Config.Audio.Input.TurnDetection != nil {
+		t.Errorf("Expected turn_detection to be disabled, got %+v", state.Config.Audio.Input.TurnDetection)
+	}
+	if state.Config.Instructions != "" {
+		t.Errorf("Expected instructions to be cleared, got %q", state.Config.Instructions)
+	}
+	if state.Config.Audio.Input.Format.Rate != 16000 {
+		t.Errorf("Expected format to be kept, got %+v", state.Config.Audio.Input.Format)
+	}
+
+	// Turn detection can be enabled again
+	state = update(`{"type":"session.update","session":{"audio":{"input":{"turn_detection":{"type":"server_vad"}}}}}`)
+	if state.Config.Audio.Input.TurnDetection == nil || state.Config.Audio.Input.TurnDetection.Type != "server_vad" {
+		t.Errorf("Expected turn_detection to be re-enabled, got %+v", state.Config.Audio.Input.TurnDetection)
+	}
+}
+
+func TestSessionUpdateNormalized(t *testing.T) {
+	uc := NewSessionUsecase()
+	state := uc.sessionManager.CreateSession("sess", "gpt-realtime-2025-08-28", "conv")
+
+	conn := &recordingConn{}
+	uc.ProcessMessage(conn, state, []byte(`{"type":"session.update","session":{"audio":{`+
+		`"input":{"format":{"type":"audio/pcmu"},"turn_detection":{"type":"server_vad","prefix_padding_ms":0}},`+
+		`"output":{"format":{"type":"audio/pcma"}}}}}`))
+	if len(conn.events) == 0 {
+		t.Fatal("Expected session.updated")
+	}
+	event, ok := conn.events[len(conn.events)-1].(*domain.SessionUpdatedEvent)
+	if !ok {
+		t.Fatalf("Expected *domain.SessionUpdatedEvent, got %T", conn.events[len(conn.events)-1])
+	}
+
+	// Formats carry the rate they are used at, G.711 always 8kHz
+	audio := event.Session.Audio
+	if *audio.Input.Format != (domain.AudioFormat{Type: "audio/pcmu", Rate: 8000}) {
+		t.Errorf("Expected input format audio/pcmu at 8000, got %+v", audio.Input.Format)
+	}
+	if *audio.Output.Format != (domain.AudioFormat{Type: "audio/pcma", Rate: 8000}) {
+		t.Errorf("Expected output format audio/pcma at 8000, got %+v", audio.Output.Format)
+	}
+	if audio.Output.Voice != domain.DefaultVoice || audio.Output.Speed != 1.0 {
+		t.Errorf("Expected the default voice and speed, got %+v", audio.Output)
+	}
+
+	// Turn detection settings left out take their defaults, explicit zeros stay
+	td := audio.Input.TurnDetection
+	if td.Threshold != 0.5 || td.SilenceDurationMs != 200 || !td.CreateResponse {
+		t.Errorf("Expected default turn detection settings, got %+v", td)
+	}
+	if td.PrefixPaddingMs != 0 {
+		t.Errorf("Expected the sent prefix_padding_ms of 0 to be kept, got %d", td.PrefixPaddingMs)
+	}
+}
+
+func TestTranscriptionSessionUpdateExplicitNull(t *testing.T) {
+	session := domain.NewTranscriptionSession("sess", "model", "en")
+	message := []byte(`{"type":"transcription_session.update","session":{"turn_detection":null}}`)
+
+	var event domain.TranscriptionSessionUpdateClientEvent
+	if err := json.Unmarshal(message, &event); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	event.Session.ApplyToSession(session, domain.ParseSessionFields(message))
+
+	if session.Audio.Input.TurnDetection != nil {
+		t.Errorf("Expected turn_detection to be disabled, got %+v", session.Audio.Input.TurnDetection)
+	}
+	if session.Audio.Input.Transcription == nil || session.Audio.Input.Transcription.Model != "model" {
+		t.Errorf("Expected transcription to be kept, got %+v", session.Audio.Input.Transcription)
+	}
+}
+
+// newTwoModelUsecase returns a usecase serving mock models "default", its
+// default, and "other"
+func newTwoModelUsecase(t *testing.T) *SessionUsecase {
+	t.Helper()
+	registry := NewASRModelRegistry(&config.ASRConfig{Models: map[string]config.ModelConfig{
+		"default": {Provider: string(ProviderMock), Languages: []string{"en", "ja"}},
+		"other":   {Provider: string(ProviderMock), Languages: []string{"en", "ja"}},
+	}})
+	t.Cleanup(func() { registry.Close() })
+	registry.RegisterProviderType(ProviderMock, func(*config.ASRConfig, string, *config.ModelConfig) (domain.ASRProvider, error) {
+		return NewMockASRProvider(), nil
+	})
+	uc := NewSessionUsecase()
+	uc.asrRegistry = registry
+	uc.defaultModel, uc.defaultLanguage = "default", "en"
+	return uc
+}
+
+func TestProviderPerSession(t *testing.T) {
+	uc := newTwoModelUsecase(t)
+	first := uc.sessionManager.CreateTranscriptionSession("sess_1", "", "conv_1", "")
+	uc.selectDefaultModel(first)
+	if err := uc.reconfigureASRProvider(&recordingConn{}, first, "", "other", "en"); err != nil {
+		t.Fatalf("reconfigureASRProvider failed: %v", err)
+	}
+	other := first.ASRProvider()
+
+	// A new session selects the default model for itself only
+	second := uc.sessionManager.CreateTranscriptionSession("sess_2", "", "conv_2", "")
+	uc.selectDefaultModel(second)
+	if first.ASRProvider() != other {
+		t.Error("Expected a new session to leave the model of others alone")
+	}
+	if second.ASRProvider() == nil || second.ASRProvider() == other {
+		t.Error("Expected the new session to get the default model")
+	}
+}
+
+func TestTenantProviderIsolated(t *testing.T) {
+	uc := newTwoModelUsecase(t)
+	restricted := uc.sessionManager.CreateTranscriptionSession("sess_1", "", "conv_1", "")
+	restricted.Tenant = &domain.Tenant{ID: "acme", AllowedModels: []string{"default"}}
+	uc.selectDefaultModel(restricted)
+	allowed := restricted.ASRProvider()
+
+	conn := &recordingConn{}
+	if err := uc.reconfigureASRProvider(conn, restricted, "", "other", "en"); err == nil {
+		t.Fatal("Expected the tenant to be refused the other model")
+	}
+
+	// Another tenant's session selecting the model does not hand it to acme
+	unrestricted := uc.sessionManager.CreateTranscriptionSession("sess_2", "", "conv_2", "")
+	if err := uc.reconfigureASRProvider(conn, unrestricted, "", "other", "en"); err != nil {
+		t.Fatalf("reconfigureASRProvider failed: %v", err)
+	}
+	if restricted.ASRProvider() != allowed || uc.providerFor(restricted) == unrestricted.ASRProvider() {
+		t.Error("Expected the restricted session to keep its allowed model")
+	}
+}
+
+func TestLanguageRoutePerSession(t *testing.T) {
+	uc := newTwoModelUsecase(t)
+	uc.languageRoutes = map[string]string{"ja": "other"}
+	uc.stats.Store("sess_1", &sessionStats{})
+	routed := uc.sessionManager.CreateTranscriptionSession("sess_1", "", "conv_1", "")
+	bystander := uc.sessionManager.CreateTranscriptionSession("sess_2", "", "conv_2", "")
+	uc.selectDefaultModel(routed)
+	uc.selectDefaultModel(bystander)
+	unrouted := bystander.ASRProvider()
+
+	// A language change routes the session that made it, and no other
+	uc.ProcessMessage(&recordingConn{}, routed, []byte(`{"type":"transcription_session.update","session":{"input_audio_transcription":{"language":"ja"}}}`))
+	if model := routed.Config.Audio.Input.Transcription.Model; model != "other" || routed.ASRProvider() == unrouted {
+		t.Errorf("Expected the session routed to the other model, got %s", model)
+	}
+	if bystander.ASRProvider() != unrouted || bystander.Config.Audio.Input.Transcription.Model != "default" {
+		t.Error("Expected other sessions to keep their model")
+	}
+}
@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/internal/pkg/auditlog"
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+var shadowTranscriptions = metrics.NewCounterVec("gribe_shadow_transcriptions_total",
+	"Segments transcribed again by the shadow model, by outcome", "result")
+
+// ShadowRecord compares the transcripts of a segment by the session's model
+// and the shadow model
+type ShadowRecord struct {
+	Time             time.Time `json:"time"`
+	SessionID        string    `json:"session_id"`
+	ItemID           string    `json:"item_id"`
+	Language         string    `json:"language"`
+	AudioMs          int       `json:"audio_ms"`
+	Model            string    `json:"model"`
+	Transcript       string    `json:"transcript"`
+	LatencyMs        int       `json:"latency_ms"`
+	ShadowModel      string    `json:"shadow_model"`
+	ShadowTranscript string    `json:"shadow_transcript"`
+	ShadowLatencyMs  int       `json:"shadow_latency_ms"`
+	ShadowError      string    `json:"shadow_error,omitempty"`
+	Match            bool      `json:"match"` // Same words, ignoring case and punctuation
+}
+
+// ShadowTranscriber transcribes a sample of committed segments again with
+// the shadow model, see config.ShadowConfig
+type ShadowTranscriber struct {
+	cfg      *config.ShadowConfig
+	registry *ASRModelRegistry
+	log      *auditlog.Log // Nil when comparisons are only logged
+	slots    chan struct{} // Shadow transcriptions running
+	timeout  time.Duration
+	sample   func() float64 // In [0, 1)
+}
+
+// NewShadowTranscriberWithConfig creates the shadow transcriber of cfg,
+// taking models from registry, or nil if shadowing is disabled
+func NewShadowTranscriberWithConfig(cfg *config.ASRConfig, registry *ASRModelRegistry) (*ShadowTranscriber, error) {
+	if !cfg.Shadow.Enabled() {
+		return nil, nil
+	}
+	s := &ShadowTranscriber{
+		cfg:      &cfg.Shadow,
+		registry: registry,
+		slots:    make(chan struct{}, max(cfg.Shadow.MaxConcurrent, 1)),
+		timeout:  30 * time.Second,
+		sample:   rand.Float64,
+	}
+	if cfg.Shadow.LogFile != "" {
+		file, err := auditlog.Open(cfg.Shadow.LogFile)
+		if err != nil {
+			return nil, err
+		}
+		s.log = file
+	}
+	return s, nil
+}
+
+// SetShadowTranscriber makes a sample of segments transcribed again by the
+// shadow model
+func (u *SessionUsecase) SetShadowTranscriber(shadow *ShadowTranscriber) {
+	if shadow != nil {
+		shadow.timeout = u.transcriptionTimeout
+	}
+	u.shadow = shadow
+}
+
+// Close closes the comparison log
+func (s *ShadowTranscriber) Close() error {
+	if s.log == nil {
+		return nil
+	}
+	return s.log.Close()
+}
+
+// shadowRun is a segment's shadow transcription in the background
+type shadowRun struct {
+	shadow *ShadowTranscriber
+	record ShadowRecord
+	done   chan struct{} // Closed once the shadow transcript is in record
+}
+
+// start shadows the transcription of a segment if it is sampled, copying its
+// audio. It returns nil for segments not shadowed: those not sampled, those
+// of sessions on the shadow model or in a language it lacks, and those
+// sampled while all shadow transcriptions are running.
+func (s *ShadowTranscriber) start(state *domain.SessionState, itemID string, audio []byte,
+	transcription *domain.TranscriptionConfig) *shadowRun {
+	if s == nil || transcription.Model == s.cfg.Model || s.sample()*100 >= s.cfg.Percent {
+		return nil
+	}
+	languages, _ := s.registry.GetModelLanguages(s.cfg.Model)
+	if !containsLanguage(languages, transcription.Language) {
+		return nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		shadowTranscriptions.With("skipped").Inc()
+		return nil
+	}
+
+	run := &shadowRun{
+		shadow: s,
+		record: ShadowRecord{
+			SessionID:   state.ID,
+			ItemID:      itemID,
+			Language:    transcription.Language,
+			AudioMs:     audioDurationMs(state, len(audio)),
+			Model:       transcription.Model,
+			ShadowModel: s.cfg.Model,
+		},
+		done: make(chan struct{}),
+	}
+	audio = append([]byte(nil), audio...) // The session's copy goes back to the pool
+	config := *transcription
+	config.Model = s.cfg.Model
+	go func() {
+		defer close(run.done)
+		defer func() { <-s.slots }()
+		defer panics.Recover("shadow transcription of "+itemID, nil)
+		started := time.Now()
+		transcript, err := s.transcribe(audio, &config)
+		run.record.ShadowLatencyMs = int(time.Since(started).Milliseconds())
+		run.record.ShadowTranscript = transcript
+		if err != nil {
+			run.record.ShadowError = err.Error()
+		}
+	}()
+	return run
+}
+
+// transcribe transcribes audio with the shadow model, not bound to the
+// session, which may end first
+func (s *ShadowTranscriber) transcribe(audio []byte, config *domain.TranscriptionConfig) (string, error) {
+	provider, err := s.registry.GetModel(config.Model, config.Language)
+	if err != nil {
+		return "", err
+	}
+	config, _ = domain.SupportedOptions(provider, config)
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	results, err := provider.Transcribe(ctx, audio, config)
+	if err != nil {
+		return "", err
+	}
+	// Revisable hypotheses are each the full transcript so far
+	var transcript strings.Builder
+	var hypothesis string
+	for chunk := range results {
+		if chunk.Err != nil {
+			return transcript.String(), chunk.Err
+		}
+		if chunk.Hypothesis != "" {
+			hypothesis = chunk.Hypothesis
+			continue
+		}
+		transcript.WriteString(chunk.Text)
+	}
+	if err := ctx.Err(); err != nil {
+		return transcript.String(), err
+	}
+	if hypothesis != "" {
+		return hypothesis, nil
+	}
+	return transcript.String(), nil
+}
+
+// compare records the session model's transcript of the segment against the
+// shadow model's once that is done. Runs whose segment failed to transcribe
+// are never compared.
+func (r *shadowRun) compare(transcript string, took time.Duration) {
+	if r == nil {
+		return
+	}
+	go func() {
+		<-r.done
+		record := r.record
+		record.Time = time.Now()
+		record.Transcript = transcript
+		record.LatencyMs = int(took.Milliseconds())
+		words, shadowWords := strings.Fields(transcript), strings.Fields(record.ShadowTranscript)
+		record.Match = record.ShadowError == "" && len(words) == len(shadowWords) && sameWords(words, shadowWords)
+		r.shadow.write(&record)
+	}()
+}
+
+// write logs and exports a comparison
+func (s *ShadowTranscriber) write(record *ShadowRecord) {
+	result := "match"
+	switch {
+	case record.ShadowError != "":
+		result = "failed"
+	case !record.Match:
+		result = "differ"
+	}
+	shadowTranscriptions.With(result).Inc()
+	log.Printf("[INFO] Shadow %s of item %s (%s): %s in %dms %q, %s in %dms %q", result, record.ItemID,
+		record.SessionID, record.Model, record.LatencyMs, record.Transcript,
+		record.ShadowModel, record.ShadowLatencyMs, record.ShadowTranscript)
+	if s.log != nil {
+		if err := s.log.Write(record); err != nil {
+			log.Printf("[WARN] Failed to write shadow comparison of item %s: %v", record.ItemID, err)
+		}
+	}
+}
+
+func containsLanguage(languages []string, language string) bool {
+	for _, l := range languages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestShadowTranscriber(t *testing.T) {
+	cfg := &config.ASRConfig{
+		Models: map[string]config.ModelConfig{
+			"current":   {Provider: string(ProviderMock), Languages: []string{"en"}},
+			"candidate": {Provider: "candidate", Languages: []string{"en"}},
+		},
+		Shadow: config.ShadowConfig{
+			Model:         "candidate",
+			Percent:       50,
+			MaxConcurrent: 1,
+			LogFile:       filepath.Join(t.TempDir(), "shadow.jsonl"),
+		},
+	}
+	registry := NewASRModelRegistry(cfg)
+	defer registry.Close()
+	candidate := NewMockASRProvider()
+	candidate.mockResults = []string{"Hello", " world!"}
+	candidate.delay, candidate.chunkDelay = 0, 0
+	registry.RegisterProviderType("candidate", func(*config.ASRConfig, string, *config.ModelConfig) (domain.ASRProvider, error) {
+		return candidate, nil
+	})
+
+	shadow, err := NewShadowTranscriberWithConfig(cfg, registry)
+	if err != nil {
+		t.Fatalf("NewShadowTranscriberWithConfig failed: %v", err)
+	}
+	defer shadow.Close()
+	draw := 0.2
+	shadow.sample = func() float64 { return draw }
+
+	state := NewSessionUsecase().sessionManager.CreateTranscriptionSession("sess_1", "", "conv_1", "")
+	current := &domain.TranscriptionConfig{Model: "current", Language: "en"}
+	audio := make([]byte, 3200)
+	if run := shadow.start(state, "item_1", audio, &domain.TranscriptionConfig{Model: "candidate", Language: "en"}); run != nil {
+		t.Error("Expected sessions on the shadow model not to be shadowed")
+	}
+	if run := shadow.start(state, "item_1", audio, &domain.TranscriptionConfig{Model: "current", Language: "fr"}); run != nil {
+		t.Error("Expected languages the shadow model lacks not to be shadowed")
+	}
+
+	run := shadow.start(state, "item_1", audio, current)
+	if run == nil {
+		t.Fatal("Expected a sampled segment to be shadowed")
+	}
+	if second := shadow.start(state, "item_2", audio, current); second != nil {
+		t.Error("Expected segments beyond max_concurrent to be skipped")
+	}
+	run.compare("hello world", 120*time.Millisecond)
+
+	var record ShadowRecord
+	for i := 0; i < 200; i++ {
+		if line, ok := firstLine(cfg.Shadow.LogFile); ok {
+			if err := json.Unmarshal(line, &record); err != nil {
+				t.Fatalf("Invalid record %s: %v", line, err)
+			}
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if record.ItemID != "item_1" || record.Model != "current" || record.ShadowModel != "candidate" ||
+		record.Transcript != "hello world" || record.ShadowTranscript != "Hello world!" ||
+		record.LatencyMs != 120 || !record.Match || record.AudioMs == 0 {
+		t.Errorf("Unexpected comparison %+v", record)
+	}
+
+	draw = 0.5
+	if run := shadow.start(state, "item_3", audio, current); run != nil {
+		t.Error("Expected segments drawn beyond the percentage not to be shadowed")
+	}
+}
+
+// firstLine returns the first complete line of a file
+func firstLine(path string) ([]byte, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	return line, err == nil
+}
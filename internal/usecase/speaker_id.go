@@ -0,0 +1,275 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/g711"
+	"github.com/aira-id/gribe/internal/pkg/pcm"
+	"github.com/aira-id/gribe/internal/pkg/sherpa"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// minSpeakerAudioMs is the shortest audio a voiceprint is computed from;
+// shorter turns carry too little of the voice to identify it
+const minSpeakerAudioMs = 500
+
+// SpeakerIdentifier identifies enrolled speakers by their voiceprints. The
+// profiles are kept in memory and, with a profiles file, persisted to it on
+// every change.
+type SpeakerIdentifier struct {
+	embedder  domain.SpeakerEmbedder
+	threshold float64
+	path      string // Profiles file, empty when profiles are not persisted
+	idGen     *IDGenerator
+	now       func() time.Time
+
+	mu       sync.Mutex
+	profiles map[string]*domain.SpeakerProfile
+}
+
+// NewSpeakerIdentifier creates an identifier matching voiceprints of
+// embedder with at least threshold cosine similarity, loading the profiles
+// enrolled in profilesFile if it exists
+func NewSpeakerIdentifier(embedder domain.SpeakerEmbedder, threshold float64, profilesFile string) (*SpeakerIdentifier, error) {
+	s := &SpeakerIdentifier{
+		embedder:  embedder,
+		threshold: threshold,
+		path:      profilesFile,
+		idGen:     NewIDGenerator(),
+		now:       time.Now,
+		profiles:  make(map[string]*domain.SpeakerProfile),
+	}
+	if profilesFile == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(profilesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var profiles []*domain.SpeakerProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("invalid speaker profiles file %s: %w", profilesFile, err)
+	}
+	for _, profile := range profiles {
+		s.profiles[profile.ID] = profile
+	}
+	log.Printf("[INFO] Loaded %d speaker profile(s) from %s", len(profiles), profilesFile)
+	return s, nil
+}
+
+// NewSpeakerIdentifierWithConfig loads the speaker embedding model of cfg
+// and the enrolled profiles
+func NewSpeakerIdentifierWithConfig(cfg *config.ASRConfig) (*SpeakerIdentifier, error) {
+	embedder, err := sherpa.NewSpeakerEmbedder(&sherpa.SpeakerConfig{
+		Model:      filepath.Join(cfg.ModelsDir, cfg.Speaker.Model),
+		Provider:   cfg.Provider,
+		NumThreads: cfg.Speaker.NumThreads,
+	})
+	if err != nil {
+		return nil, err
+	}
+	identifier, err := NewSpeakerIdentifier(embedder, cfg.Speaker.Threshold, cfg.Speaker.ProfilesFile)
+	if err != nil {
+		embedder.Close()
+		return nil, err
+	}
+	return identifier, nil
+}
+
+// Identify returns the enrolled speaker whose voiceprint is the closest to
+// that of samples, or nil if none is close enough
+func (s *SpeakerIdentifier) Identify(samples []float32, sampleRate int) (*domain.Speaker, error) {
+	if len(samples)*1000 < minSpeakerAudioMs*sampleRate {
+		return nil, nil
+	}
+	voiceprint, err := s.embedder.Embed(samples, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	return s.match(voiceprint), nil
+}
+
+// match returns the enrolled speaker closest to voiceprint, or nil if none
+// is close enough
+func (s *SpeakerIdentifier) match(voiceprint []float32) *domain.Speaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var best *domain.Speaker
+	for _, profile := range s.profiles {
+		similarity := cosineSimilarity(voiceprint, profile.Voiceprint)
+		if similarity >= s.threshold && (best == nil || similarity > best.Similarity) {
+			best = &domain.Speaker{ID: profile.ID, Name: profile.Name, Similarity: similarity}
+		}
+	}
+	return best
+}
+
+// Enroll adds a recording of a speaker. With an empty id it creates a new
+// profile named name; otherwise the recording is averaged into the
+// voiceprint of profile id, renaming it if name is set.
+func (s *SpeakerIdentifier) Enroll(id, name string, samples []float32, sampleRate int) (domain.SpeakerProfile, error) {
+	if len(samples)*1000 < minSpeakerAudioMs*sampleRate {
+		return domain.SpeakerProfile{}, fmt.Errorf("enrollment audio must be at least %dms long", minSpeakerAudioMs)
+	}
+	voiceprint, err := s.embedder.Embed(samples, sampleRate)
+	if err != nil {
+		return domain.SpeakerProfile{}, err
+	}
+	normalize(voiceprint)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile := s.profiles[id]
+	switch {
+	case id == "":
+		profile = &domain.SpeakerProfile{
+			ID:         s.idGen.GenerateSpeakerID(),
+			Name:       name,
+			CreatedAt:  s.now().Unix(),
+			Voiceprint: voiceprint,
+		}
+		s.profiles[profile.ID] = profile
+	case profile == nil:
+		return domain.SpeakerProfile{}, domain.ErrSpeakerNotFound
+	case len(profile.Voiceprint) != len(voiceprint):
+		return domain.SpeakerProfile{}, fmt.Errorf("voiceprint has %d dimensions, profile %s has %d",
+			len(voiceprint), id, len(profile.Voiceprint))
+	default:
+		addVoiceprint(profile.Voiceprint, profile.Samples, voiceprint)
+		if name != "" {
+			profile.Name = name
+		}
+	}
+	profile.Samples++
+	return *profile, s.save()
+}
+
+// Profiles returns the enrolled profiles, oldest first
+func (s *SpeakerIdentifier) Profiles() []domain.SpeakerProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sorted()
+}
+
+// Profile returns the enrolled profile id
+func (s *SpeakerIdentifier) Profile(id string) (domain.SpeakerProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile, ok := s.profiles[id]
+	if !ok {
+		return domain.SpeakerProfile{}, domain.ErrSpeakerNotFound
+	}
+	return *profile, nil
+}
+
+// Delete removes the enrolled profile id
+func (s *SpeakerIdentifier) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.profiles[id]; !ok {
+		return domain.ErrSpeakerNotFound
+	}
+	delete(s.profiles, id)
+	return s.save()
+}
+
+// Close releases the embedding model
+func (s *SpeakerIdentifier) Close() error {
+	return s.embedder.Close()
+}
+
+func (s *SpeakerIdentifier) sorted() []domain.SpeakerProfile {
+	profiles := make([]domain.SpeakerProfile, 0, len(s.profiles))
+	for _, profile := range s.profiles {
+		profiles = append(profiles, *profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].CreatedAt != profiles[j].CreatedAt {
+			return profiles[i].CreatedAt < profiles[j].CreatedAt
+		}
+		return profiles[i].ID < profiles[j].ID
+	})
+	return profiles
+}
+
+// save writes the profiles file, replacing it at once so a crash never
+// leaves it half written. The caller holds mu.
+func (s *SpeakerIdentifier) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// inputSamples decodes audio of the session's input format to samples
+func inputSamples(audio []byte, format *domain.AudioFormat) []float32 {
+	switch format.Encoding() {
+	case domain.EncodingG711Ulaw:
+		audio = g711.UlawToPCM16(audio)
+	case domain.EncodingG711Alaw:
+		audio = g711.AlawToPCM16(audio)
+	}
+	return pcm.ToFloat32(audio)
+}
+
+// cosineSimilarity of two voiceprints, 0 if their dimensions differ
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// addVoiceprint averages the normalized voiceprint of another recording into
+// mean, the running mean of n recordings
+func addVoiceprint(mean []float32, n int, voiceprint []float32) {
+	for i := range voiceprint {
+		mean[i] = (mean[i]*float32(n) + voiceprint[i]) / float32(n+1)
+	}
+}
+
+// normalize scales v to unit length, so recordings weigh equally in a profile
+func normalize(v []float32) {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range v {
+		v[i] *= scale
+	}
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// voiceEmbedder embeds a recording as the voice its first sample names
+type voiceEmbedder struct{}
+
+func (voiceEmbedder) Embed(samples []float32, sampleRate int) ([]float32, error) {
+	voices := map[float32][]float32{
+		0.1: {1, 0, 0},
+		0.2: {0.9, 0.1, 0}, // Close to 0.1, a second recording of the same voice
+		0.3: {0, 0, 1},
+		0.4: {0, 1, 0},
+	}
+	return append([]float32(nil), voices[samples[0]]...), nil
+}
+
+func (voiceEmbedder) Close() error { return nil }
+
+// recording returns a second of 16kHz audio of voice
+func recording(voice float32) []float32 {
+	samples := make([]float32, 16000)
+	samples[0] = voice
+	return samples
+}
+
+func TestSpeakerIdentifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "speakers.json")
+	speakers, err := NewSpeakerIdentifier(voiceEmbedder{}, 0.8, path)
+	if err != nil {
+		t.Fatalf("Failed to create identifier: %v", err)
+	}
+
+	alice, err := speakers.Enroll("", "Alice", recording(0.1), 16000)
+	if err != nil || alice.Samples != 1 {
+		t.Fatalf("Expected Alice enrolled, got %+v %v", alice, err)
+	}
+	if alice, err = speakers.Enroll(alice.ID, "", recording(0.2), 16000); err != nil || alice.Samples != 2 || alice.Name != "Alice" {
+		t.Fatalf("Expected a second recording of Alice, got %+v %v", alice, err)
+	}
+	if _, err := speakers.Enroll("spk_unknown", "", recording(0.1), 16000); !errors.Is(err, domain.ErrSpeakerNotFound) {
+		t.Errorf("Expected enrolling an unknown profile to fail, got %v", err)
+	}
+	if _, err := speakers.Enroll("", "Bob", recording(0.1)[:4000], 16000); err == nil {
+		t.Error("Expected too short a recording to be refused")
+	}
+
+	speaker, err := speakers.Identify(recording(0.1), 16000)
+	if err != nil || speaker == nil || speaker.ID != alice.ID || speaker.Similarity < 0.8 {
+		t.Fatalf("Expected Alice identified, got %+v %v", speaker, err)
+	}
+	if speaker, _ := speakers.Identify(recording(0.3), 16000); speaker != nil {
+		t.Errorf("Expected an unknown voice to match nobody, got %+v", speaker)
+	}
+
+	// Profiles survive a restart
+	restarted, err := NewSpeakerIdentifier(voiceEmbedder{}, 0.8, path)
+	if err != nil {
+		t.Fatalf("Failed to reload profiles: %v", err)
+	}
+	if profiles := restarted.Profiles(); len(profiles) != 1 || profiles[0].ID != alice.ID || profiles[0].Samples != 2 {
+		t.Fatalf("Expected Alice's profile reloaded, got %+v", profiles)
+	}
+	if err := restarted.Delete(alice.ID); err != nil {
+		t.Fatalf("Failed to delete profile: %v", err)
+	}
+	if _, err := restarted.Profile(alice.ID); !errors.Is(err, domain.ErrSpeakerNotFound) {
+		t.Errorf("Expected the deleted profile to be gone, got %v", err)
+	}
+	if speaker, _ := restarted.Identify(recording(0.1), 16000); speaker != nil {
+		t.Errorf("Expected no match after deletion, got %+v", speaker)
+	}
+}
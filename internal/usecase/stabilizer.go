@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// hypothesisStabilizer turns revisable recognizer hypotheses into append-only
+// deltas. The last window words of each hypothesis are held back as unstable,
+// so a streaming recognizer can revise them without a delta ever needing to be
+// retracted; the rest is released when the utterance ends.
+type hypothesisStabilizer struct {
+	window  int    // Trailing words held back as unstable
+	emitted string // Text already released as deltas
+	latest  string // Most recent hypothesis
+}
+
+// newHypothesisStabilizer creates a stabilizer holding back window trailing words
+func newHypothesisStabilizer(window int) *hypothesisStabilizer {
+	return &hypothesisStabilizer{window: window}
+}
+
+// Update takes the recognizer's full current hypothesis and returns the newly stable text
+func (s *hypothesisStabilizer) Update(hypothesis string) string {
+	s.latest = hypothesis
+	stable := hypothesis[:stablePrefixEnd(hypothesis, s.window)]
+
+	// Released text cannot be retracted, so only ever extend it
+	if len(stable) <= len(s.emitted) || !strings.HasPrefix(stable, s.emitted) {
+		return ""
+	}
+	delta := stable[len(s.emitted):]
+	s.emitted = stable
+	return delta
+}
+
+// Flush releases the rest of the latest hypothesis once the utterance is final
+func (s *hypothesisStabilizer) Flush() string {
+	if !strings.HasPrefix(s.latest, s.emitted) {
+		log.Printf("[WARN] Recognizer revised text already sent as deltas: %q -> %q", s.emitted, s.latest)
+		return ""
+	}
+	delta := s.latest[len(s.emitted):]
+	s.emitted = s.latest
+	return delta
+}
+
+// Transcript returns the latest hypothesis, "" if none was seen
+func (s *hypothesisStabilizer) Transcript() string {
+	return s.latest
+}
+
+// stablePrefixEnd returns the byte offset where the last window words of text
+// begin, keeping the whitespace before them in the stable part
+func stablePrefixEnd(text string, window int) int {
+	if window <= 0 {
+		return len(text)
+	}
+	end := len(text)
+	for words := 0; words < window; words++ {
+		// Skip trailing whitespace, then the word before it
+		for end > 0 {
+			r, size := utf8.DecodeLastRuneInString(text[:end])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			end -= size
+		}
+		for end > 0 {
+			r, size := utf8.DecodeLastRuneInString(text[:end])
+			if unicode.IsSpace(r) {
+				break
+			}
+			end -= size
+		}
+	}
+	return end
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import "testing"
+
+func TestHypothesisStabilizer(t *testing.T) {
+	s := newHypothesisStabilizer(1)
+
+	steps := []struct {
+		hypothesis string
+		want       string
+	}{
+		{"hello", ""},
+		{"hello wor", "hello "},
+		{"hello world", ""},
+		{"hello word how", "word "}, // revision inside the window, before release
+		{"hello world how are", ""}, // revision of released text is held back
+		{"hello word how are you", "how are "},
+	}
+	var deltas string
+	for _, step := range steps {
+		got := s.Update(step.hypothesis)
+		if got != step.want {
+			t.Errorf("Update(%q) = %q, want %q", step.hypothesis, got, step.want)
+		}
+		deltas += got
+	}
+
+	if rest := s.Flush(); rest != "you" {
+		t.Errorf("Expected flush to release the held word, got %q", rest)
+	}
+	if s.Transcript() != "hello word how are you" {
+		t.Errorf("Unexpected transcript %q", s.Transcript())
+	}
+	if deltas+"you" != s.Transcript() {
+		t.Errorf("Expected deltas to add up to the transcript, got %q", deltas+"you")
+	}
+}
+
+func TestStablePrefixEnd(t *testing.T) {
+	tests := []struct {
+		text   string
+		window int
+		want   string
+	}{
+		{"one two three", 0, "one two three"},
+		{"one two three", 1, "one two "},
+		{"one two three ", 1, "one two "},
+		{"one two three", 2, "one "},
+		{"one", 3, ""},
+		{"satu dua tiga", 1, "satu dua "},
+	}
+	for _, tt := range tests {
+		if got := tt.text[:stablePrefixEnd(tt.text, tt.window)]; got != tt.want {
+			t.Errorf("stablePrefixEnd(%q, %d) kept %q, want %q", tt.text, tt.window, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// events are dropped for it
+const subscriberBuffer = 256
+
+var (
+	// ErrSessionNotFound is returned for a session that is not live
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionForbidden is returned for a session of other credentials
+	ErrSessionForbidden = errors.New("session belongs to other credentials")
+)
+
+// SubscribeOptions describes the credentials of a subscriber
+type SubscribeOptions struct {
+	APIKey     string         // Credential the subscriber authenticated with
+	Tenant     *domain.Tenant // Tenant resolved from the subscriber's credentials
+	AnySession bool           // Allow sessions of any credentials, e.g. for admins
+}
+
+// Subscription is a read-only stream of a live session's server events
+type Subscription struct {
+	// Events carries each server event as JSON. It is closed when the session
+	// ends or the subscription is closed.
+	Events <-chan []byte
+
+	events    chan []byte
+	hub       *eventHub
+	sessionID string
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+// Subscribe streams the server events of a live session to a second consumer,
+// e.g. a dashboard, without affecting the session. Sessions of a tenant are
+// visible to all credentials of that tenant, other sessions only to the
+// credentials that opened them. A subscriber that falls behind loses events
+// rather than stalling the session.
+func (u *SessionUsecase) Subscribe(sessionID string, opts SubscribeOptions) (*Subscription, error) {
+	return u.events.subscribe(sessionID, opts)
+}
+
+// eventHub fans server events out to the subscribers of each live session
+type eventHub struct {
+	mu       sync.Mutex
+	sessions map[string]*hubSession
+}
+
+type hubSession struct {
+	apiKey      string
+	tenant      *domain.Tenant
+	subscribers map[*Subscription]bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{sessions: make(map[string]*hubSession)}
+}
+
+// open makes a session available to subscribers
+func (h *eventHub) open(state *domain.SessionState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sessions[state.ID] = &hubSession{
+		apiKey:      state.APIKey,
+		tenant:      state.Tenant,
+		subscribers: make(map[*Subscription]bool),
+	}
+}
+
+// close ends the subscriptions of a session
+func (h *eventHub) close(sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if session, ok := h.sessions[sessionID]; ok {
+		for sub := range session.subscribers {
+			close(sub.events)
+		}
+		delete(h.sessions, sessionID)
+	}
+}
+
+func (h *eventHub) subscribe(sessionID string, opts SubscribeOptions) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	session, ok := h.sessions[sessionID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if !session.allows(opts) {
+		return nil, ErrSessionForbidden
+	}
+
+	events := make(chan []byte, subscriberBuffer)
+	sub := &Subscription{Events: events, events: events, hub: h, sessionID: sessionID}
+	session.subscribers[sub] = true
+	return sub, nil
+}
+
+// allows reports whether the credentials may see the session
+func (s *hubSession) allows(opts SubscribeOptions) bool {
+	return opts.allow(s.apiKey, s.tenant)
+}
+
+// allow reports whether the credentials may see a session opened with
+// apiKey for tenant
+func (opts SubscribeOptions) allow(apiKey string, tenant *domain.Tenant) bool {
+	if opts.AnySession {
+		return true
+	}
+	if tenant != nil {
+		return opts.Tenant != nil && opts.Tenant.ID == tenant.ID
+	}
+	return opts.APIKey == apiKey
+}
+
+func (h *eventHub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if session, ok := h.sessions[sub.sessionID]; ok && session.subscribers[sub] {
+		delete(session.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// publish sends an event to the session's subscribers, if it has any
+func (h *eventHub) publish(sessionID string, event interface{}) {
+	h.mu.Lock()
+	session, ok := h.sessions[sessionID]
+	listening := ok && len(session.subscribers) > 0
+	h.mu.Unlock()
+	if !listening {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[WARN] Could not encode event for subscribers of session %s: %v", sessionID, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if session, ok := h.sessions[sessionID]; ok {
+		for sub := range session.subscribers {
+			select {
+			case sub.events <- data:
+			default:
+				// Subscriber is not keeping up
+			}
+		}
+	}
+}
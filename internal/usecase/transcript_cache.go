@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+var (
+	cacheHits = metrics.NewCounter("gribe_transcript_cache_hits_total",
+		"Turns answered from the transcript cache")
+	cacheMisses = metrics.NewCounter("gribe_transcript_cache_misses_total",
+		"Turns looked up in the transcript cache and sent to the provider")
+)
+
+// transcriptCache is an LRU cache of transcripts keyed by audio fingerprint,
+// model, language and prompt, for audio that recurs verbatim such as IVR
+// prompts and automated tests. A nil cache never hits.
+type transcriptCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration // 0 keeps entries until evicted
+	order      *list.List    // Most recently used first
+	entries    map[string]*list.Element
+	now        func() time.Time
+}
+
+type cacheEntry struct {
+	key        string
+	transcript string
+	stored     time.Time
+}
+
+// newTranscriptCache returns nil, disabling the cache, when maxEntries is 0
+func newTranscriptCache(maxEntries int, ttl time.Duration) *transcriptCache {
+	if maxEntries <= 0 {
+		return nil
+	}
+	return &transcriptCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// cacheKey fingerprints a turn's audio and transcription settings
+func cacheKey(audio []byte, config *domain.TranscriptionConfig) string {
+	sum := sha256.Sum256(audio)
+	key := hex.EncodeToString(sum[:]) + "\x00" + config.Model + "\x00" + config.Language + "\x00" + config.Prompt
+	if config.Translate {
+		key += "\x00translate"
+	}
+	return key
+}
+
+// get returns the cached transcript for key
+func (c *transcriptCache) get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if ok && c.ttl > 0 && c.now().Sub(element.Value.(*cacheEntry).stored) > c.ttl {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		cacheMisses.Inc()
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	cacheHits.Inc()
+	return element.Value.(*cacheEntry).transcript, true
+}
+
+// put stores a transcript, evicting the least recently used entry when full
+func (c *transcriptCache) put(key, transcript string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.transcript, entry.stored = transcript, c.now()
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, transcript: transcript, stored: c.now()})
+	if c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// dropModel removes the transcripts of a model, whose new version may
+// transcribe the same audio differently
+func (c *transcriptCache) dropModel(model string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.entries {
+		if strings.SplitN(key, "\x00", 3)[1] == model {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
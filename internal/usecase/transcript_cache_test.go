@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestTranscriptCache(t *testing.T) {
+	if newTranscriptCache(0, time.Hour) != nil {
+		t.Fatal("Expected a zero size to disable the cache")
+	}
+	var disabled *transcriptCache
+	disabled.put("key", "text")
+	if _, ok := disabled.get("key"); ok {
+		t.Error("Expected a disabled cache to miss")
+	}
+
+	now := time.Unix(1718000000, 0)
+	c := newTranscriptCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	en := &domain.TranscriptionConfig{Model: "zipformer", Language: "en"}
+	id := &domain.TranscriptionConfig{Model: "zipformer", Language: "id"}
+	a, b, d := cacheKey([]byte("a"), en), cacheKey([]byte("b"), en), cacheKey([]byte("d"), en)
+	if cacheKey([]byte("a"), id) == a {
+		t.Error("Expected the language to be part of the key")
+	}
+
+	c.put(a, "alpha")
+	c.put(b, "bravo")
+	if got, ok := c.get(a); !ok || got != "alpha" {
+		t.Fatalf("Expected a hit for a, got %q %v", got, ok)
+	}
+	// b is now the least recently used
+	c.put(d, "delta")
+	if _, ok := c.get(b); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if _, ok := c.get(a); !ok {
+		t.Error("Expected a to survive eviction")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.get(d); ok {
+		t.Error("Expected d to expire")
+	}
+	if len(c.entries) != 1 || c.order.Len() != 1 {
+		t.Errorf("Expected the expired entry to be dropped, have %d", len(c.entries))
+	}
+}
@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aira-id/gribe/internal/metrics"
+)
+
+// transcriptionTimeWeight is the weight of the latest transcription in the
+// moving average used to estimate queue waits
+const transcriptionTimeWeight = 0.2
+
+// Queue metrics add up the queues of the process
+var (
+	transcriptionsQueuedTotal = metrics.NewCounter("gribe_transcriptions_queued_total",
+		"Transcriptions that waited for a free transcription slot")
+	transcriptionsWaiting = metrics.NewGauge("gribe_transcriptions_waiting",
+		"Transcriptions waiting for a free transcription slot")
+)
+
+// transcriptionQueue bounds the transcriptions running at once server-wide.
+// Items beyond the limit wait for a slot in turn. A nil queue runs
+// everything at once.
+type transcriptionQueue struct {
+	slots   chan struct{}
+	mu      sync.Mutex
+	waiting int           // Transcriptions waiting for a slot
+	average time.Duration // Moving average of recent transcription times
+}
+
+// newTranscriptionQueue returns nil, disabling the queue, when limit is 0
+func newTranscriptionQueue(limit int) *transcriptionQueue {
+	if limit <= 0 {
+		return nil
+	}
+	return &transcriptionQueue{slots: make(chan struct{}, limit)}
+}
+
+// acquire takes a transcription slot, waiting for one if all are taken. It
+// calls queued with the item's position and estimated wait before it starts
+// waiting, and returns false if ctx ends first.
+func (q *transcriptionQueue) acquire(ctx context.Context, queued func(position int, wait time.Duration)) bool {
+	if q == nil {
+		return true
+	}
+	select {
+	case q.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	q.mu.Lock()
+	q.waiting++
+	position := q.waiting
+	// Each free slot takes one transcription of the ones ahead
+	wait := q.average * time.Duration((position+cap(q.slots)-1)/cap(q.slots))
+	q.mu.Unlock()
+	transcriptionsWaiting.Inc()
+	defer func() {
+		q.mu.Lock()
+		q.waiting--
+		q.mu.Unlock()
+		transcriptionsWaiting.Dec()
+	}()
+
+	transcriptionsQueuedTotal.Inc()
+	queued(position, wait)
+	select {
+	case q.slots <- struct{}{}:
+		if ctx.Err() != nil {
+			<-q.slots
+			return false
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees a slot taken by acquire from a transcription that ran for took
+func (q *transcriptionQueue) release(took time.Duration) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	if q.average == 0 {
+		q.average = took
+	} else {
+		q.average += time.Duration(transcriptionTimeWeight * float64(took-q.average))
+	}
+	q.mu.Unlock()
+	<-q.slots
+}
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTranscriptionQueue(t *testing.T) {
+	var unlimited *transcriptionQueue
+	if !unlimited.acquire(context.Background(), nil) {
+		t.Fatal("Expected a nil queue to admit every transcription")
+	}
+	unlimited.release(time.Second)
+
+	q := newTranscriptionQueue(1)
+	notQueued := func(int, time.Duration) { t.Error("Expected a free slot to be taken without queueing") }
+	if !q.acquire(context.Background(), notQueued) {
+		t.Fatal("Expected the first transcription to be admitted")
+	}
+	q.release(2 * time.Second)
+	if !q.acquire(context.Background(), notQueued) {
+		t.Fatal("Expected a released slot to be reused")
+	}
+
+	// A waiting transcription learns its position and the expected wait
+	positions := make(chan int, 1)
+	admitted := make(chan bool)
+	go func() {
+		admitted <- q.acquire(context.Background(), func(position int, wait time.Duration) {
+			if wait != 2*time.Second {
+				t.Errorf("Expected a wait of 2s, got %v", wait)
+			}
+			positions <- position
+		})
+	}()
+	if position := <-positions; position != 1 {
+		t.Errorf("Expected queue position 1, got %d", position)
+	}
+
+	// Cancelled waits give up without taking a slot
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan bool)
+	go func() {
+		cancelled <- q.acquire(ctx, func(int, time.Duration) { cancel() })
+	}()
+	if <-cancelled {
+		t.Error("Expected a cancelled transcription not to be admitted")
+	}
+
+	q.release(time.Second)
+	if !<-admitted {
+		t.Fatal("Expected the waiting transcription to be admitted once a slot was freed")
+	}
+	q.release(time.Second)
+	if len(q.slots) != 0 || q.waiting != 0 {
+		t.Errorf("Expected all slots free and none waiting, got %d and %d", len(q.slots), q.waiting)
+	}
+}
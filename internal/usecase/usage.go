@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/internal/pkg/fetch"
+	"github.com/aira-id/gribe/internal/pkg/usagesink"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+const (
+	// audioTokensPerSecond is the rate audio is counted at, one token per 100 ms
+	audioTokensPerSecond = 10
+	// charactersPerToken estimates text tokens without the model's tokenizer
+	charactersPerToken = 4
+)
+
+var usageRecordsDropped = metrics.NewCounter("gribe_usage_records_dropped_total",
+	"Usage records of ended sessions dropped because the usage sink fell behind")
+
+// audioTokens estimates the tokens of seconds of audio
+func audioTokens(seconds float64) int {
+	return int(math.Ceil(seconds * audioTokensPerSecond))
+}
+
+// textTokens estimates the tokens of text
+func textTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charactersPerToken - 1) / charactersPerToken
+}
+
+// transcriptionUsage is the usage of transcribing seconds of audio into transcript
+func transcriptionUsage(seconds float64, transcript string) *domain.Usage {
+	input, output := audioTokens(seconds), textTokens(transcript)
+	return &domain.Usage{
+		Type:              "tokens",
+		TotalTokens:       input + output,
+		InputTokens:       input,
+		OutputTokens:      output,
+		InputTokenDetails: &domain.TokenDetails{AudioTokens: input},
+	}
+}
+
+// responseUsage is the usage of a response reading the conversation's items
+// and producing text
+func responseUsage(items []domain.Item, text string) *domain.Usage {
+	input := &domain.TokenDetails{}
+	for _, item := range items {
+		if item.AudioEndMs > item.AudioStartMs {
+			input.AudioTokens += audioTokens(float64(item.AudioEndMs-item.AudioStartMs) / 1000)
+		}
+		for _, part := range item.Content {
+			input.TextTokens += textTokens(part.Text) + textTokens(part.Transcript)
+		}
+	}
+	output := textTokens(text)
+	return &domain.Usage{
+		TotalTokens:        input.AudioTokens + input.TextTokens + output,
+		InputTokens:        input.AudioTokens + input.TextTokens,
+		OutputTokens:       output,
+		InputTokenDetails:  input,
+		OutputTokenDetails: &domain.TokenDetails{TextTokens: output},
+	}
+}
+
+// NewUsageSinkWithConfig creates the configured usage sink behind a queue,
+// nil when usage is not exported
+func NewUsageSinkWithConfig(cfg *config.UsageConfig) (*usagesink.Queue, error) {
+	var sink domain.UsageSink
+	switch cfg.Sink {
+	case "":
+		return nil, nil
+	case "file":
+		file, err := usagesink.NewFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		sink = file
+	case "s3":
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid usage URL: %w", err)
+		}
+		sink = usagesink.NewS3(u.Host, u.Path, fetch.Credentials{
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+			Region:          cfg.S3.Region,
+			Endpoint:        cfg.S3.Endpoint,
+		})
+	case "webhook":
+		webhook, err := usagesink.NewWebhook(cfg.URL, cfg.Secret)
+		if err != nil {
+			return nil, err
+		}
+		sink = webhook
+	default:
+		return nil, fmt.Errorf("unknown usage sink %q", cfg.Sink)
+	}
+	return usagesink.NewQueue(sink, cfg.QueueSize, cfg.Attempts, cfg.Backoff), nil
+}
+
+// SetUsageSink exports the usage of ended sessions to sink
+func (u *SessionUsecase) SetUsageSink(sink domain.UsageSink) {
+	u.usageSink = sink
+}
+
+// exportUsage writes the usage record of a session ending at endedAt
+func (u *SessionUsecase) exportUsage(state *domain.SessionState, usage domain.SessionUsage, endedAt time.Time) {
+	if u.usageSink == nil {
+		return
+	}
+	info := u.sessionInfo(state, endedAt)
+	record := &domain.UsageRecord{
+		SessionID:  state.ID,
+		Type:       info.Type,
+		APIKeyHash: domain.OwnerHash(state.APIKey),
+		TenantID:   info.TenantID,
+		Model:      info.Model,
+		StartedAt:  state.CreatedAt.Unix(),
+		EndedAt:    endedAt.Unix(),
+		Usage:      usage,
+		Metadata:   state.Config.Metadata,
+	}
+	if err := u.usageSink.Write(record); err != nil {
+		usageRecordsDropped.Inc()
+		log.Printf("[WARN] Dropped usage record of session %s: %v", state.ID, err)
+	}
+}
@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/clock"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestSessionUsage(t *testing.T) {
+	uc := NewSessionUsecase()
+	var closed *domain.SessionClosedEvent
+	uc.AddEventObserver(func(sessionID string, event interface{}) {
+		if e, ok := event.(*domain.SessionClosedEvent); ok {
+			closed = e
+		}
+	})
+	sink := &usageRecorder{}
+	uc.SetUsageSink(sink)
+	state := uc.sessionManager.CreateSession("sess_1", "model", "conv_1")
+	state.APIKey = "key-a"
+	uc.stats.Store(state.ID, &sessionStats{})
+	conn := &recordingConn{}
+
+	// One second of 24 kHz PCM16 and a text item
+	audio := base64.StdEncoding.EncodeToString(make([]byte, 48000))
+	uc.ProcessMessage(conn, state, []byte(`{"type":"input_audio_buffer.append","audio":"`+audio+`"}`))
+	uc.ProcessMessage(conn, state, []byte(`{"type":"conversation.item.create","item":{"type":"message","role":"user","content":[{"type":"input_text","text":"hello there"}]}}`))
+	uc.ProcessMessage(conn, state, []byte(`{"type":"response.create"}`))
+
+	var done *domain.ResponseDoneEvent
+	for _, event := range conn.events {
+		if e, ok := event.(*domain.ResponseDoneEvent); ok {
+			done = e
+		}
+	}
+	if done == nil || done.Response.Usage == nil {
+		t.Fatal("Expected response.done with usage")
+	}
+	// "hello there" is 3 tokens, the 52 characters of the mock response 13
+	if usage := done.Response.Usage; usage.InputTokens != 3 || usage.OutputTokens != 13 || usage.TotalTokens != 16 {
+		t.Errorf("Expected 3 input and 13 output tokens, got %+v", usage)
+	}
+
+	transcription := uc.recordTranscription(state, 2, "hello world!", 0)
+	if transcription.Type != "tokens" || transcription.InputTokens != 20 || transcription.OutputTokens != 3 {
+		t.Errorf("Expected 20 audio and 3 text tokens, got %+v", transcription)
+	}
+
+	uc.reportUsage(&liveSession{state: state})
+	if closed == nil {
+		t.Fatal("Expected observers to get session.closed")
+	}
+	want := domain.SessionUsage{
+		AudioInputSeconds:       1,
+		AudioTranscribedSeconds: 2,
+		Transcriptions:          1,
+		TranscriptCharacters:    12,
+		Responses:               1,
+		InputTokens:             23,
+		OutputTokens:            16,
+		TotalTokens:             39,
+		ClientEvents:            3,
+	}
+	if closed.Usage != want {
+		t.Errorf("Expected usage %+v, got %+v", want, closed.Usage)
+	}
+	if len(sink.records) != 1 || sink.records[0].Usage != want || sink.records[0].APIKeyHash != domain.OwnerHash("key-a") {
+		t.Errorf("Expected a usage record of key-a, got %+v", sink.records)
+	}
+}
+
+// usageRecorder is a usage sink keeping the records
+type usageRecorder struct {
+	records []*domain.UsageRecord
+}
+
+func (r *usageRecorder) Write(record *domain.UsageRecord) error {
+	r.records = append(r.records, record)
+	return nil
+}
+
+func TestSessionStatsInterval(t *testing.T) {
+	c := clock.NewFake(time.Unix(1735689600, 0))
+	uc := NewSessionUsecase()
+	uc.SetClock(c)
+	state := uc.sessionManager.CreateTranscriptionSession("sess_1", "", "conv_1", "")
+	uc.stats.Store(state.ID, &sessionStats{})
+	conn := &recordingConn{}
+
+	statsEvents := func() int {
+		n := 0
+		for _, event := range conn.events {
+			if _, ok := event.(*domain.SessionStatsEvent); ok {
+				n++
+			}
+		}
+		return n
+	}
+	uc.ProcessMessage(conn, state, []byte(`{"type":"transcription_session.update","session":{"stats_interval_ms":5000}}`))
+	c.Advance(4999 * time.Millisecond)
+	if n := statsEvents(); n != 0 {
+		t.Fatalf("Expected no session.stats before the interval, got %d", n)
+	}
+	c.Advance(10*time.Second + time.Millisecond)
+	if n := statsEvents(); n != 3 {
+		t.Errorf("Expected session.stats at 5s, 10s and 15s, got %d", n)
+	}
+
+	uc.ProcessMessage(conn, state, []byte(`{"type":"transcription_session.update","session":{"stats_interval_ms":0}}`))
+	c.Advance(time.Minute)
+	if n := statsEvents(); n != 3 {
+		t.Errorf("Expected session.stats to stop, got %d", n)
+	}
+}
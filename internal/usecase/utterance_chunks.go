@@ -0,0 +1,118 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+const (
+	chunkOverlapMs = 1000 // Audio a chunk of an utterance repeats from the end of the chunk before
+	maxStitchWords = 20   // Most words looked for in the overlap of two chunks' transcripts
+)
+
+// utteranceChunk is the item of a chunk of an utterance too long for the
+// audio buffer. Its transcript is stitched onto that of the chunk before.
+type utteranceChunk struct {
+	previous   *utteranceChunk // nil for the first chunk
+	done       chan struct{}   // Closed once the chunk's transcription ended
+	transcript string          // As transcribed, before stitching; empty if it failed
+}
+
+// wait returns the chunk's transcript once transcribed, empty for no chunk
+// or if ctx ends first
+func (c *utteranceChunk) wait(ctx context.Context) string {
+	if c == nil {
+		return ""
+	}
+	select {
+	case <-c.done:
+		return c.transcript
+	case <-ctx.Done():
+		return ""
+	}
+}
+
+// commitFullBuffer commits the buffer as a chunk of an utterance when
+// appending size more bytes would overflow it, keeping its last
+// chunkOverlapMs as the start of the next chunk
+func (u *SessionUsecase) commitFullBuffer(conn Conn, state *domain.SessionState, eventID string, size int) {
+	maxSize := state.AudioBuffer.GetMaxSize()
+	if maxSize == 0 || state.AudioBuffer.IsEmpty() || state.AudioBuffer.GetSize()+size <= maxSize {
+		return
+	}
+	audioData, err := state.AudioBuffer.Commit()
+	if err != nil {
+		u.sendError(conn, eventID, "server_error", domain.CodeBufferError, err.Error(), nil)
+		return
+	}
+
+	// Transcription recycles the committed audio, so the overlap is copied
+	format := state.Config.Audio.Input.Format
+	bytesPerSample := format.BytesPerSample()
+	overlap := min(chunkOverlapMs*format.SampleRate()/1000, len(audioData)/bytesPerSample/2) * bytesPerSample
+	tail := append([]byte(nil), audioData[len(audioData)-overlap:]...)
+
+	itemID := u.idGen.GenerateItemID()
+	u.linkUtteranceChunk(state.ID, itemID, true)
+	u.commitAndTranscribe(conn, state, itemID, audioData, state.InputMs())
+	state.AudioBuffer.Clear()
+	if err := state.AudioBuffer.Append(tail); err != nil {
+		u.sendError(conn, eventID, "server_error", domain.CodeBufferError, err.Error(), nil)
+	}
+}
+
+// linkUtteranceChunk makes itemID the next chunk of the utterance whose
+// previous chunk the buffer still starts with, if any. A chunk that
+// continues, committed from a full buffer, is in turn continued by the
+// next commit.
+func (u *SessionUsecase) linkUtteranceChunk(sessionID, itemID string, continues bool) {
+	var previous *utteranceChunk
+	if value, ok := u.utterances.LoadAndDelete(sessionID); ok {
+		previous = value.(*utteranceChunk)
+	}
+	if previous == nil && !continues {
+		return
+	}
+	chunk := &utteranceChunk{previous: previous, done: make(chan struct{})}
+	u.utteranceChunks.Store(itemID, chunk)
+	if continues {
+		u.utterances.Store(sessionID, chunk)
+	}
+}
+
+// takeUtteranceChunk returns the utterance chunk of an item, nil if it is
+// not one
+func (u *SessionUsecase) takeUtteranceChunk(itemID string) *utteranceChunk {
+	value, ok := u.utteranceChunks.LoadAndDelete(itemID)
+	if !ok {
+		return nil
+	}
+	return value.(*utteranceChunk)
+}
+
+// stitchTranscripts drops the words transcript starts with that previous
+// ends with, heard twice in the overlap of their chunks, and returns how
+// many it dropped
+func stitchTranscripts(previous, transcript string) (string, int) {
+	previousWords := strings.Fields(previous)
+	words := strings.Fields(transcript)
+	for n := min(len(previousWords), len(words), maxStitchWords); n > 0; n-- {
+		if sameWords(previousWords[len(previousWords)-n:], words[:n]) {
+			return strings.Join(words[n:], " "), n
+		}
+	}
+	return strings.TrimSpace(transcript), 0
+}
+
+// sameWords compares words ignoring case and surrounding punctuation
+func sameWords(a, b []string) bool {
+	for i := range a {
+		if !strings.EqualFold(strings.TrimFunc(a[i], unicode.IsPunct), strings.TrimFunc(b[i], unicode.IsPunct)) {
+			return false
+		}
+	}
+	return true
+}
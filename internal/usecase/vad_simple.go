@@ -6,10 +6,21 @@ import (
 	"math"
 	"sync"
 
-	"github.com/aira-id/gribe/internal/domain"
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+	"github.com/aira-id/gribe/internal/pkg/g711"
+	"github.com/aira-id/gribe/pkg/domain"
 )
 
-// SimpleVADProvider implements a basic energy-based VAD
+// Noise floor tracking. The floor follows quieter audio quickly and louder
+// ambient noise slowly, so a burst of speech barely moves it.
+const (
+	minNoiseFloor = 100.0 // RMS on the 16-bit scale, keeps digital silence from making any sound look like speech
+	noiseFallMs   = 100   // Time constant when the energy is below the floor
+	noiseRiseMs   = 2000  // Time constant when the energy is above the floor
+)
+
+// SimpleVADProvider implements a basic energy-based VAD whose threshold is
+// relative to an adaptive estimate of the ambient noise level
 type SimpleVADProvider struct {
 	config        *domain.VADConfig
 	events        chan domain.VADEvent
@@ -17,6 +28,9 @@ type SimpleVADProvider struct {
 	isSpeaking    bool
 	silentSamples int
 	audioBuffer   []byte
+	noiseFloor    float64     // Estimated RMS energy of the ambient noise
+	floorSet      bool        // Whether the noise floor was calibrated from audio yet
+	prefix        *prefixRing // Recent pre-speech audio, prepended to each segment
 	startMs       int
 	currentMs     int
 	ctx           context.Context
@@ -37,6 +51,8 @@ func NewSimpleVADProvider(config *domain.VADConfig) *SimpleVADProvider {
 		config:      config,
 		events:      make(chan domain.VADEvent, 10),
 		audioBuffer: make([]byte, 0),
+		prefix:      newPrefixRing(prefixBytes(config)),
+		noiseFloor:  minNoiseFloor,
 		ctx:         ctx,
 		cancel:      cancel,
 	}
@@ -62,49 +78,56 @@ func (v *SimpleVADProvider) ProcessAudio(ctx context.Context, audio []byte) erro
 	// Calculate RMS energy of the audio
 	energy := v.calculateEnergy(audio)
 
-	// Convert threshold to energy level (threshold is 0-1, energy is typically 0-32768 for 16-bit audio)
-	energyThreshold := v.config.Threshold * 1000 // Simplified threshold mapping
+	// Calculate duration of this audio chunk in milliseconds (mono audio)
+	chunkDurationMs := v.durationMs(len(audio))
+
+	// Calibrate on the first chunk; clients normally start streaming before speaking
+	if !v.floorSet {
+		v.noiseFloor = math.Max(energy, minNoiseFloor)
+		v.floorSet = true
+	}
 
-	// Calculate duration of this audio chunk in milliseconds
-	// Assuming 16-bit PCM mono audio
-	bytesPerSample := 2
-	samplesInChunk := len(audio) / bytesPerSample
-	chunkDurationMs := (samplesInChunk * 1000) / v.config.SampleRate
+	// Speech starts above the start level and, with hysteresis, continues
+	// until energy falls below the lower stop level
+	startLevel, stopLevel := v.energyLevels()
+	isSpeech := energy > startLevel || (v.isSpeaking && energy > stopLevel)
+	if !v.isSpeaking && !isSpeech {
+		v.updateNoiseFloor(energy, chunkDurationMs)
+	}
 
 	wasSpeaking := v.isSpeaking
 
-	if energy > energyThreshold {
+	if isSpeech {
 		// Speech detected
 		v.silentSamples = 0
 
 		if !v.isSpeaking {
-			// Speech just started
+			// Speech just started, begin the segment with the buffered
+			// pre-speech audio so word onsets are not clipped
 			v.isSpeaking = true
-			v.startMs = v.currentMs
-
-			// Include prefix padding
-			prefixStart := v.startMs - v.config.PrefixPaddingMs
-			if prefixStart < 0 {
-				prefixStart = 0
-			}
+			v.audioBuffer = v.prefix.AppendTo(bufpool.Bytes(v.prefix.size + len(audio))[:0])
+			v.prefix.Reset()
+			v.startMs = v.currentMs - v.durationMs(len(v.audioBuffer))
 
 			event := domain.VADEvent{
 				Type:    domain.VADEventSpeechStarted,
-				StartMs: prefixStart,
+				StartMs: v.startMs,
 			}
 
 			v.sendEvent(event)
 		}
 
 		// Accumulate audio data during speech
-		v.audioBuffer = append(v.audioBuffer, audio...)
+		v.audioBuffer = bufpool.Append(v.audioBuffer, audio)
 	} else {
 		// Silence detected
-		if v.isSpeaking {
+		if !v.isSpeaking {
+			v.prefix.Write(audio)
+		} else {
 			v.silentSamples += chunkDurationMs
 
 			// Still accumulate audio during silence (might be pause in speech)
-			v.audioBuffer = append(v.audioBuffer, audio...)
+			v.audioBuffer = bufpool.Append(v.audioBuffer, audio)
 
 			if v.silentSamples >= v.config.SilenceDurationMs {
 				// Speech ended
@@ -119,8 +142,8 @@ func (v *SimpleVADProvider) ProcessAudio(ctx context.Context, audio []byte) erro
 
 				v.sendEvent(event)
 
-				// Clear buffer after speech segment
-				v.audioBuffer = make([]byte, 0)
+				// The segment now belongs to the event's receiver
+				v.audioBuffer = nil
 			}
 		}
 	}
@@ -143,18 +166,30 @@ func (v *SimpleVADProvider) ProcessAudio(ctx context.Context, audio []byte) erro
 	return nil
 }
 
-// calculateEnergy calculates RMS energy of 16-bit PCM audio
+// calculateEnergy calculates RMS energy of the audio on the 16-bit PCM scale,
+// decoding G.711 samples first
 func (v *SimpleVADProvider) calculateEnergy(audio []byte) float64 {
-	if len(audio) < 2 {
-		return 0
-	}
-
 	var sumSquares float64
-	sampleCount := len(audio) / 2
+	var sampleCount int
 
-	for i := 0; i < len(audio)-1; i += 2 {
-		sample := int16(binary.LittleEndian.Uint16(audio[i : i+2]))
-		sumSquares += float64(sample) * float64(sample)
+	switch v.config.Encoding {
+	case domain.EncodingG711Ulaw, domain.EncodingG711Alaw:
+		decode := g711.Ulaw
+		if v.config.Encoding == domain.EncodingG711Alaw {
+			decode = g711.Alaw
+		}
+		for _, b := range audio {
+			sample := decode(b)
+			sumSquares += float64(sample) * float64(sample)
+		}
+		sampleCount = len(audio)
+
+	default:
+		for i := 0; i < len(audio)-1; i += 2 {
+			sample := int16(binary.LittleEndian.Uint16(audio[i : i+2]))
+			sumSquares += float64(sample) * float64(sample)
+		}
+		sampleCount = len(audio) / 2
 	}
 
 	if sampleCount == 0 {
@@ -165,6 +200,31 @@ func (v *SimpleVADProvider) calculateEnergy(audio []byte) float64 {
 	return rms
 }
 
+// energyLevels returns the energy above which speech starts and the lower
+// level below which ongoing speech counts as silence. Threshold (0.0-1.0) is a
+// relative sensitivity: the start level is 1x to 5x the noise floor.
+func (v *SimpleVADProvider) energyLevels() (start, stop float64) {
+	floor := math.Max(v.noiseFloor, minNoiseFloor)
+	start = floor * (1 + 4*v.config.Threshold)
+	stop = floor + (start-floor)/2
+	return start, stop
+}
+
+// updateNoiseFloor moves the noise floor toward the energy of a non-speech chunk
+func (v *SimpleVADProvider) updateNoiseFloor(energy float64, chunkMs int) {
+	tau := noiseRiseMs
+	if energy < v.noiseFloor {
+		tau = noiseFallMs
+	}
+	alpha := float64(chunkMs) / float64(chunkMs+tau)
+	v.noiseFloor += alpha * (energy - v.noiseFloor)
+}
+
+// durationMs returns the duration of n bytes of audio in the configured format
+func (v *SimpleVADProvider) durationMs(n int) int {
+	return n / v.config.BytesPerSample() * 1000 / v.config.SampleRate
+}
+
 // GetEvents returns the channel for VAD events
 func (v *SimpleVADProvider) GetEvents() <-chan domain.VADEvent {
 	return v.events
@@ -193,6 +253,7 @@ func (v *SimpleVADProvider) Configure(config *domain.VADConfig) error {
 
 	if config != nil {
 		v.config = config
+		v.prefix = newPrefixRing(prefixBytes(config))
 	}
 	return nil
 }
@@ -204,7 +265,11 @@ func (v *SimpleVADProvider) Reset() {
 
 	v.isSpeaking = false
 	v.silentSamples = 0
-	v.audioBuffer = make([]byte, 0)
+	bufpool.PutBytes(v.audioBuffer)
+	v.audioBuffer = nil
+	v.prefix.Reset()
+	v.noiseFloor = minNoiseFloor
+	v.floorSet = false
 	v.startMs = 0
 	v.currentMs = 0
 }
@@ -263,9 +328,63 @@ func (v *SimpleVADProvider) ForceCommit() *domain.VADEvent {
 		AudioData: v.audioBuffer,
 	}
 
-	v.audioBuffer = make([]byte, 0)
+	v.audioBuffer = nil
 	v.isSpeaking = false
 	v.startMs = v.currentMs
 
 	return event
 }
+
+// prefixBytes returns the size of config.PrefixPaddingMs of audio
+func prefixBytes(config *domain.VADConfig) int {
+	return config.PrefixPaddingMs * config.SampleRate / 1000 * config.BytesPerSample()
+}
+
+// prefixRing keeps the most recent audio up to a fixed size, so the padding
+// before detected speech can be prepended to the segment
+type prefixRing struct {
+	buf   []byte
+	start int
+	size  int
+}
+
+func newPrefixRing(capacity int) *prefixRing {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &prefixRing{buf: make([]byte, capacity)}
+}
+
+// Write appends audio, overwriting the oldest bytes once full
+func (r *prefixRing) Write(p []byte) {
+	n := len(r.buf)
+	if n == 0 {
+		return
+	}
+	if len(p) >= n {
+		copy(r.buf, p[len(p)-n:])
+		r.start, r.size = 0, n
+		return
+	}
+
+	end := (r.start + r.size) % n
+	copied := copy(r.buf[end:], p)
+	copy(r.buf, p[copied:])
+	r.size += len(p)
+	if r.size > n {
+		r.start = (r.start + r.size - n) % n
+		r.size = n
+	}
+}
+
+// AppendTo appends the buffered audio to dst, oldest first
+func (r *prefixRing) AppendTo(dst []byte) []byte {
+	first := min(r.size, len(r.buf)-r.start)
+	dst = append(dst, r.buf[r.start:r.start+first]...)
+	return append(dst, r.buf[:r.size-first]...)
+}
+
+// Reset discards the buffered audio
+func (r *prefixRing) Reset() {
+	r.start, r.size = 0, 0
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+	"log"
+
+	"github.com/aira-id/gribe/internal/pkg/bufpool"
+	"github.com/aira-id/gribe/internal/pkg/panics"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// vadAudioQueue is the number of appended chunks a VAD worker can fall behind
+// before appends block
+const vadAudioQueue = 64
+
+// vadWorker runs a session's VAD on its own goroutine. Appended audio is
+// queued to it, and the events it produces are sent to the client as soon as
+// the audio is processed rather than on the next append.
+type vadWorker struct {
+	vad    domain.VADProvider
+	audio  chan []byte
+	done   chan struct{}
+	itemID string // Item of the speech segment in progress, used by the worker goroutine only
+
+	// offsetMs is where in the session's input audio the VAD started, to
+	// place its segments on the session's clock
+	offsetMs int
+}
+
+// startVADWorker starts a worker feeding vad and reporting its events on conn
+func (u *SessionUsecase) startVADWorker(conn Conn, state *domain.SessionState, vad domain.VADProvider) *vadWorker {
+	w := &vadWorker{
+		vad:   vad,
+		audio: make(chan []byte, vadAudioQueue),
+		done:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.done)
+		for audio := range w.audio {
+			u.detectSpeech(conn, state, w, audio)
+		}
+	}()
+
+	return w
+}
+
+// Process queues audio for the worker, which takes ownership of the slice
+// and recycles it once processed
+func (w *vadWorker) Process(audio []byte) {
+	w.audio <- audio
+}
+
+// Stop processes the queued audio, then stops the worker and closes its VAD
+func (w *vadWorker) Stop() {
+	close(w.audio)
+	<-w.done
+	w.vad.Close()
+}
+
+// detectSpeech runs the VAD on audio and acts on its events. A panic drops
+// the audio, keeping the worker alive for the audio after it.
+func (u *SessionUsecase) detectSpeech(conn Conn, state *domain.SessionState, w *vadWorker, audio []byte) {
+	defer panics.Recover("voice activity detection of session "+state.ID, func(error) {
+		u.sendError(conn, "", "server_error", domain.CodeServerError, "The server failed to detect speech in the audio", nil)
+	})
+	if err := w.vad.ProcessAudio(context.Background(), audio); err != nil {
+		log.Printf("VAD processing error: %v", err)
+	}
+	bufpool.PutBytes(audio)
+	u.processVADEvents(conn, state, w)
+}
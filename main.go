@@ -2,27 +2,78 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/aira-id/gribe/internal/config"
-	"github.com/aira-id/gribe/internal/delivery/websocket"
+	"github.com/aira-id/gribe/internal/pkg/cgroup"
+	"github.com/aira-id/gribe/internal/pkg/modelfetch"
+	"github.com/aira-id/gribe/internal/pkg/sdactivate"
 	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/gribe"
 )
 
 func main() {
-	// Load configuration from environment and YAML
-	cfg := config.LoadWithYAML("config.yaml")
+	if len(os.Args) > 1 && os.Args[1] == "eval" {
+		os.Exit(runEval(os.Args[2:]))
+	}
+
+	configPath := flag.String("config", "config.yaml", "Path to the config file (.yaml, .json or .toml)")
+	force := flag.Bool("force", false, "Start even if the configuration fails validation")
+	flag.Parse()
+
+	// Load configuration from environment and config file
+	cfg := config.LoadWithYAML(*configPath)
+
+	// Download missing model files before their presence is validated
+	if err := usecase.FetchModels(context.Background(), &cfg.ASR, modelfetch.NewFetcher()); err != nil {
+		if !*force {
+			log.Fatalf("Fetching models failed (use -force to start anyway): %v", err)
+		}
+		log.Printf("[WARN] Starting without all models (-force): %v", err)
+	}
+
+	// Refuse to start with a broken configuration unless forced
+	if err := cfg.Validate(); err != nil {
+		if !*force {
+			log.Fatalf("Invalid configuration (use -force to start anyway): %v", err)
+		}
+		log.Printf("[WARN] Starting with invalid configuration (-force): %v", err)
+	}
+
+	// Fit the Go runtime to the container before anything sizes itself by
+	// GOMAXPROCS, such as the inference thread budget
+	if cfg.Runtime.FitCgroup {
+		limits, err := cgroup.Read()
+		if err != nil {
+			log.Printf("[WARN] Reading cgroup limits failed: %v", err)
+		}
+		if procs := cgroup.FitGOMAXPROCS(limits); procs > 0 {
+			log.Printf("GOMAXPROCS set to %d for a CPU quota of %.2f", procs, limits.CPU)
+		}
+		if limit := cgroup.FitMemoryLimit(limits, cfg.Runtime.MemoryLimitRatio); limit > 0 {
+			log.Printf("Go memory limit set to %d bytes of the %d byte cgroup limit", limit, limits.Memory)
+		}
+	}
 
 	// Log configuration (without sensitive data)
 	log.Printf("Starting Gribe STT Server")
 	log.Printf("Port: %s", cfg.Server.Port)
 	log.Printf("Max audio buffer size: %d bytes", cfg.Audio.MaxBufferSize)
 	log.Printf("Max connections per IP: %d", cfg.Rate.MaxConnectionsPerIP)
+	if cfg.Server.MaxSessions > 0 {
+		log.Printf("Max concurrent sessions: %d", cfg.Server.MaxSessions)
+	}
+	if cfg.Quota.DailyAudioSeconds > 0 || cfg.Quota.MonthlyAudioSeconds > 0 {
+		log.Printf("Audio quota per API key: daily=%ds monthly=%ds soft=%v",
+			cfg.Quota.DailyAudioSeconds, cfg.Quota.MonthlyAudioSeconds, cfg.Quota.SoftLimit)
+	}
 
 	if len(cfg.Server.AllowedOrigins) == 0 {
 		log.Println("Allowed origins: * (all)")
@@ -30,32 +81,75 @@ func main() {
 		log.Printf("Allowed origins: %v", cfg.Server.AllowedOrigins)
 	}
 
-	if len(cfg.Auth.APIKeys) == 0 {
+	if cfg.Auth.JWT.Enabled() {
+		log.Printf("JWT authentication: enabled (JWKS: %s)", cfg.Auth.JWT.JWKSURL)
+	}
+	if len(cfg.APIKeys()) == 0 && len(cfg.Auth.Keys) == 0 && cfg.Auth.APIKeysFile == "" {
 		log.Println("Authentication: disabled (no API keys configured)")
 	} else {
-		log.Printf("Authentication: enabled (%d API key(s) configured)", len(cfg.Auth.APIKeys))
+		log.Printf("Authentication: enabled (%d API key(s) configured)", len(cfg.APIKeys())+len(cfg.Auth.Keys))
 	}
 
-	// Initialize Usecase with configuration
-	sessionUsecase := usecase.NewSessionUsecaseWithConfig(cfg)
+	// Pick up changes to the API keys file without a restart
+	stopKeyWatch := make(chan struct{})
+	cfg.WatchAPIKeysFile(10*time.Second, stopKeyWatch)
 
-	// Initialize Delivery Handler
-	wsHandler := websocket.NewHandler(sessionUsecase, cfg)
-
-	// Set up routes
-	http.Handle("/v1/realtime", wsHandler)
+	// Under systemd socket activation the listening sockets are inherited:
+	// the one named "admin" (FileDescriptorName=admin) is the admin listener
+	// and the first other one serves the API
+	activated, err := sdactivate.Listeners()
+	if err != nil {
+		log.Fatalf("Socket activation error: %v", err)
+	}
+	var publicListener, adminListener net.Listener
+	for _, l := range activated {
+		switch {
+		case l.Name == "admin" && adminListener == nil:
+			adminListener = l
+		case publicListener == nil:
+			publicListener = l
+		default:
+			log.Printf("Ignoring extra activated socket %s (%s)", l.Name, l.Addr())
+			l.Close()
+		}
+	}
 
-	// Health check endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	// Operational endpoints go on the admin listener when one is configured,
+	// which also serves pprof, and on the public port otherwise
+	srv, err := gribe.New(cfg, gribe.Options{SeparateAdmin: cfg.Admin.Listen != "" || adminListener != nil})
+	if err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
 
 	// Start server in a goroutine
 	addr := ":" + cfg.Server.Port
 	server := &http.Server{
 		Addr:    addr,
-		Handler: nil, // Uses http.DefaultServeMux
+		Handler: srv,
+	}
+
+	var adminServer *http.Server
+	if adminListener == nil && cfg.Admin.Listen != "" {
+		network, address := cfg.Admin.ListenAddress()
+		if network == "unix" {
+			// A socket left behind by an unclean exit would fail the listen
+			if info, err := os.Lstat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+				os.Remove(address)
+			}
+		}
+		adminListener, err = net.Listen(network, address)
+		if err != nil {
+			log.Fatalf("Admin listener error: %v", err)
+		}
+	}
+	if adminListener != nil {
+		adminServer = &http.Server{Handler: srv.AdminHandler()}
+		go func() {
+			log.Printf("Admin endpoints listening on %s %s", adminListener.Addr().Network(), adminListener.Addr())
+			if err := adminServer.Serve(adminListener); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Admin server error: %v", err)
+			}
+		}()
 	}
 
 	// Graceful shutdown handling
@@ -63,8 +157,15 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		log.Printf("Server listening on %s", addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if publicListener != nil {
+			log.Printf("Server listening on activated socket %s%s", publicListener.Addr(), cfg.Server.BasePath)
+			err = server.Serve(publicListener)
+		} else {
+			log.Printf("Server listening on %s%s", addr, cfg.Server.BasePath)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
 		}
 	}()
@@ -81,7 +182,13 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Printf("Server force shutdown: %v", err)
 	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			log.Printf("Admin server force shutdown: %v", err)
+		}
+	}
 
-	wsHandler.Close()
+	srv.Close()
+	close(stopKeyWatch)
 	log.Println("Server stopped")
 }
@@ -0,0 +1,1515 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds all configuration for the application
+type Config struct {
+	Server        ServerConfig
+	Auth          AuthConfig
+	Audio         AudioConfig
+	Rate          RateLimitConfig
+	ASR           ASRConfig
+	Record        RecordConfig
+	Cache         CacheConfig
+	Conversations ConversationsConfig
+	Usage         UsageConfig
+	Retention     RetentionConfig
+	Quota         QuotaConfig
+	Admin         AdminConfig
+	SIP           SIPConfig
+	AudioSocket   AudioSocketConfig
+	MQTT          MQTTConfig
+	WebRTC        WebRTCConfig
+	Watch         WatchConfig
+	Batch         BatchConfig
+	Runtime       RuntimeConfig
+	IDs           IDConfig
+	Tenants       map[string]TenantConfig
+
+	apiKeysFile *KeyFile // Loaded from Auth.APIKeysFile, reloaded by WatchAPIKeysFile
+	fileErr     error    // Why the config file could not be loaded, reported by Validate
+}
+
+// ServerConfig holds server-related configuration
+type ServerConfig struct {
+	Port           string   `yaml:"port"`
+	BasePath       string   `yaml:"base_path"`       // Prefix all routes are served under, e.g. "/stt"; empty serves them at the root
+	AllowedOrigins []string `yaml:"allowed_origins"` // Empty means allow all (wildcard)
+	MaxSessions    int      `yaml:"max_sessions"`    // Server-wide concurrent session cap, 0 means unlimited
+	MemoryBudget   int      `yaml:"memory_budget"`   // Bytes of session audio held in memory before shedding load, 0 means unlimited
+
+	// ResumeWindow is how long a session that lost its connection is kept for
+	// the client to resume, 0 disables resuming
+	ResumeWindow time.Duration `yaml:"resume_window"`
+
+	// EventDedupWindow is how long the event_id of a client event is
+	// remembered, answering a retransmission with the original replies
+	// instead of handling it again; 0 disables deduplication
+	EventDedupWindow time.Duration `yaml:"event_dedup_window"`
+
+	// EventBatchInterval is how long delta events may be held to send those
+	// of the interval in one frame, as a JSON array, to clients connecting
+	// with ?batch=true; 0 disables batching
+	EventBatchInterval time.Duration `yaml:"event_batch_interval"`
+
+	// Protocol is the dialect of server events: empty for the default, "ga"
+	// for strict GA Realtime API compatibility
+	Protocol string `yaml:"protocol"`
+
+	// CORS answers browsers calling the REST endpoints from AllowedOrigins
+	CORS CORSConfig `yaml:"cors"`
+
+	// AccessLog logs every HTTP request once answered
+	AccessLog bool `yaml:"access_log"`
+}
+
+// CORSConfig holds what cross-origin requests to the REST endpoints may do
+type CORSConfig struct {
+	AllowedMethods []string      `yaml:"allowed_methods"` // Methods preflights allow
+	AllowedHeaders []string      `yaml:"allowed_headers"` // Request headers preflights allow
+	MaxAge         time.Duration `yaml:"max_age"`         // How long browsers may cache a preflight, 0 leaves it to the browser
+}
+
+// AuthConfig holds authentication configuration
+type AuthConfig struct {
+	APIKeys     []string       `yaml:"api_keys"`      // List of valid API keys, empty means no auth required
+	APIKeysFile string         `yaml:"api_keys_file"` // File with one API key per line, reloaded on change
+	Keys        []APIKeyConfig `yaml:"keys"`          // Keys with explicit scopes
+	JWT         JWTConfig      `yaml:"jwt"`
+}
+
+// JWTConfig holds JWT bearer token authentication configuration
+type JWTConfig struct {
+	JWKSURL         string        `yaml:"jwks_url"`         // Signing keys endpoint, empty disables JWT auth
+	Issuer          string        `yaml:"issuer"`           // Required iss claim, empty skips the check
+	Audience        string        `yaml:"audience"`         // Required aud claim, empty skips the check
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // How often signing keys are refetched (default 1h)
+	ScopeClaim      string        `yaml:"scope_claim"`      // Claim holding granted scopes (default "scope")
+	TenantClaim     string        `yaml:"tenant_claim"`     // Claim matched against tenants' jwt_claim_values
+}
+
+// Enabled reports whether JWT auth is configured
+func (j *JWTConfig) Enabled() bool {
+	return j.JWKSURL != ""
+}
+
+// APIKeyConfig is an API key restricted to a set of scopes
+type APIKeyConfig struct {
+	Key           string   `yaml:"key"`
+	Scopes        []string `yaml:"scopes"`         // e.g. realtime:transcribe, admin:read, admin:write
+	RetentionDays int      `yaml:"retention_days"` // Overrides retention.days for data of this key, 0 inherits it
+}
+
+// AudioConfig holds audio processing limits
+type AudioConfig struct {
+	MaxBufferSize        int           `yaml:"max_audio_buffer_size"` // Maximum audio buffer size in bytes (default 15MB)
+	TranscriptionTimeout time.Duration `yaml:"transcription_timeout"` // Timeout for transcription calls (default 30s)
+	DefaultModel         string        `yaml:"default_model"`         // Model selected for new sessions (defaults to asr.default_model)
+	DefaultLanguage      string        `yaml:"default_language"`      // Language selected for new sessions (defaults to the model's first language)
+	MinDeltaInterval     time.Duration `yaml:"min_delta_interval"`    // Minimum time between transcription deltas (0 sends every delta)
+	MinDeltaChars        int           `yaml:"min_delta_chars"`       // Minimum characters per transcription delta (0 sends every delta)
+	StabilityWindow      int           `yaml:"stability_window"`      // Trailing words of revisable hypotheses held back until final (default 2)
+	TranscriptionRetries int           `yaml:"transcription_retries"` // Retries of transient provider failures before a turn fails (default 2, 0 disables)
+	RetryBackoff         time.Duration `yaml:"retry_backoff"`         // Delay before the first retry, doubled for each further one (default 200ms)
+	SpillThreshold       int           `yaml:"spill_threshold"`       // Buffered bytes past which a session's audio moves to a temp file (0 keeps it in memory)
+	SpillDir             string        `yaml:"spill_dir"`             // Directory for spilled audio (default: system temp directory)
+	MaxTranscriptions    int           `yaml:"max_transcriptions"`    // Transcriptions run at once server-wide, others queue (0 is unlimited)
+}
+
+// Policies at the per-IP connection limit
+const (
+	ConnectionLimitReject    = "reject"
+	ConnectionLimitEvictIdle = "evict_idle"
+)
+
+// RateLimitConfig holds rate limiting configuration
+type RateLimitConfig struct {
+	MaxConnectionsPerIP int           `yaml:"max_connections_per_ip"`
+	RequestsPerSecond   int           `yaml:"requests_per_second"`
+	BurstSize           int           `yaml:"burst_size"`
+	CleanupInterval     time.Duration `yaml:"cleanup_interval"`
+
+	// ConnectionLimitPolicy decides what happens to a connection past
+	// MaxConnectionsPerIP: ConnectionLimitReject refuses it, and
+	// ConnectionLimitEvictIdle closes the IP's connection that has been idle
+	// longest, at least EvictIdleAfter, in its favor
+	ConnectionLimitPolicy string        `yaml:"connection_limit_policy"`
+	EvictIdleAfter        time.Duration `yaml:"evict_idle_after"`
+
+	// Abuse bans: an IP reaching BanAfterAuthFailures rejected credentials or
+	// BanAfterRateViolations rate-limited requests within BanWindow is refused
+	// for BanDuration, doubled for every repeat ban up to MaxBanDuration
+	// (0 thresholds disable the bans)
+	BanAfterAuthFailures   int           `yaml:"ban_after_auth_failures"`
+	BanAfterRateViolations int           `yaml:"ban_after_rate_violations"`
+	BanWindow              time.Duration `yaml:"ban_window"`
+	BanDuration            time.Duration `yaml:"ban_duration"`
+	MaxBanDuration         time.Duration `yaml:"max_ban_duration"`
+
+	// Per-connection message limits (0 disables the limit)
+	MaxEventsPerSecond  int `yaml:"max_events_per_second"`  // All client events
+	MaxAppendsPerSecond int `yaml:"max_appends_per_second"` // input_audio_buffer.append events
+	MaxBytesPerSecond   int `yaml:"max_bytes_per_second"`   // Raw message bytes
+	MaxViolations       int `yaml:"max_violations"`         // Rejected messages within a minute before disconnecting
+}
+
+// RecordConfig holds session recording configuration
+type RecordConfig struct {
+	Dir string `yaml:"dir"` // Directory for session recordings, empty disables recording
+}
+
+// CacheConfig holds the transcript cache, which answers turns whose audio,
+// model, language and prompt match an earlier turn without calling the provider
+type CacheConfig struct {
+	MaxEntries int           `yaml:"max_entries"` // Transcripts kept, least recently used evicted first (0 disables the cache)
+	TTL        time.Duration `yaml:"ttl"`         // How long a transcript stays valid (default 1h, 0 = until evicted)
+}
+
+// ConversationsConfig holds the conversation store, which keeps the
+// conversations of ended sessions for retrieval at /v1/conversations/
+type ConversationsConfig struct {
+	Dir        string `yaml:"dir"`         // Directory conversations are saved in, empty keeps them in memory
+	MaxEntries int    `yaml:"max_entries"` // Conversations kept in memory without a dir, oldest evicted first (0 disables the store)
+}
+
+// RetentionEnabled reports whether any stored data has a retention period
+func (c *Config) RetentionEnabled() bool {
+	if c.Retention.Days > 0 {
+		return true
+	}
+	for _, tenant := range c.Tenants {
+		if tenant.RetentionDays > 0 {
+			return true
+		}
+	}
+	for _, key := range c.Auth.Keys {
+		if key.RetentionDays > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled reports whether conversations of ended sessions are kept
+func (c *ConversationsConfig) Enabled() bool {
+	return c.Dir != "" || c.MaxEntries > 0
+}
+
+// UsageConfig exports a usage record of each ended session to a sink, so
+// billing can be computed without scraping logs
+type UsageConfig struct {
+	Sink      string            `yaml:"sink"`       // "file", "s3" or "webhook"; empty disables the export
+	File      string            `yaml:"file"`       // JSONL file records are appended to, for the file sink
+	URL       string            `yaml:"url"`        // Webhook URL, or s3://bucket/prefix records are written under
+	Secret    string            `yaml:"secret"`     // HMAC key signing webhook payloads
+	S3        ObjectStoreConfig `yaml:"s3"`         // Credentials for the s3 sink
+	Attempts  int               `yaml:"attempts"`   // Deliveries tried per record (default 5)
+	Backoff   time.Duration     `yaml:"backoff"`    // Delay before the first retry, doubled for each further one (default 2s)
+	QueueSize int               `yaml:"queue_size"` // Records waiting for the sink before new ones are dropped (default 1000)
+}
+
+// RetentionConfig deletes stored conversations and session recordings once
+// they are older than their retention period: Days, or the retention_days of
+// their tenant or auth.keys entry
+type RetentionConfig struct {
+	Days      int           `yaml:"days"`       // Days stored data is kept, 0 keeps it until deleted otherwise
+	Interval  time.Duration `yaml:"interval"`   // How often expired data is swept (default 1h)
+	DryRun    bool          `yaml:"dry_run"`    // Only report and audit what would be deleted
+	AuditFile string        `yaml:"audit_file"` // JSONL file deletions are recorded in, empty logs them only
+}
+
+// RuntimeConfig fits the Go runtime to the limits of the container gribe runs
+// in. Without it GOMAXPROCS is the host's core count and the garbage
+// collector does not know the memory limit the container is OOM-killed at.
+type RuntimeConfig struct {
+	FitCgroup        bool    `yaml:"fit_cgroup"`         // Set GOMAXPROCS to the cgroup CPU quota and the Go memory limit from its memory limit
+	MemoryLimitRatio float64 `yaml:"memory_limit_ratio"` // Share of the cgroup memory limit the Go memory limit is set to (default 0.9)
+}
+
+// IDConfig holds how the IDs of sessions, conversations, items, events and
+// other objects are generated: a prefix naming the kind of object, such as
+// "sess_", followed by a unique part made by the strategy
+type IDConfig struct {
+	Strategy string            `yaml:"strategy"` // short-uuid (default), uuid, ulid, snowflake or sequential (deterministic, for tests)
+	Node     int               `yaml:"node"`     // Snowflake node ID of this server, 0-1023, unique within the deployment
+	Prefixes map[string]string `yaml:"prefixes"` // Prefix per kind of object, replacing the default, e.g. session: "s-"
+}
+
+// QuotaConfig holds per-API-key audio quota configuration
+type QuotaConfig struct {
+	DailyAudioSeconds   int  `yaml:"daily_audio_seconds"`   // Transcribed audio per key per UTC day, 0 means unlimited
+	MonthlyAudioSeconds int  `yaml:"monthly_audio_seconds"` // Transcribed audio per key per UTC month, 0 means unlimited
+	SoftLimit           bool `yaml:"soft_limit"`            // Keep transcribing past the quota, only reporting it
+}
+
+// AdminConfig holds admin endpoint configuration
+type AdminConfig struct {
+	APIKeys []string `yaml:"api_keys"` // Keys allowed to call admin endpoints, empty disables them
+
+	// Listen moves the admin and metrics endpoints off the public port onto
+	// their own listener, which also serves pprof: a TCP address such as
+	// "127.0.0.1:9090", or "unix:" followed by a socket path. Empty keeps
+	// them on the public port, without pprof.
+	Listen string `yaml:"listen"`
+}
+
+// ListenAddress returns the network and address of the admin listener
+func (a AdminConfig) ListenAddress() (network, address string) {
+	if path, ok := strings.CutPrefix(a.Listen, "unix:"); ok {
+		return "unix", path
+	}
+	return "tcp", a.Listen
+}
+
+// SIPConfig holds the SIP/RTP ingestion gateway configuration. Each audio
+// stream of an accepted call is transcribed in its own transcription session.
+type SIPConfig struct {
+	Listen        string        `yaml:"listen"`         // UDP address for SIP, e.g. ":5060"; empty disables the gateway
+	PublicAddress string        `yaml:"public_address"` // IP advertised for RTP in SDP answers (defaults to the local address facing the caller)
+	RTPPortMin    int           `yaml:"rtp_port_min"`   // First UDP port for RTP (default 10000)
+	RTPPortMax    int           `yaml:"rtp_port_max"`   // Last UDP port for RTP (default 10999)
+	MediaTimeout  time.Duration `yaml:"media_timeout"`  // Hang up calls without RTP for this long (default 30s)
+	Model         string        `yaml:"model"`          // Transcription model (defaults to audio.default_model)
+	Language      string        `yaml:"language"`       // Transcription language (defaults to the model's default)
+	APIKey        string        `yaml:"api_key"`        // Key call sessions are accounted to for quotas and tenants
+	AllowedPeers  []string      `yaml:"allowed_peers"`  // IPs or CIDRs allowed to place calls, empty allows all
+}
+
+// Enabled reports whether the SIP gateway is configured
+func (s *SIPConfig) Enabled() bool {
+	return s.Listen != ""
+}
+
+// AudioSocketConfig holds the listener for Asterisk's AudioSocket protocol.
+// Each connection is transcribed in its own transcription session.
+type AudioSocketConfig struct {
+	Listen       string        `yaml:"listen"`        // TCP address, e.g. ":9092"; empty disables the listener
+	MediaTimeout time.Duration `yaml:"media_timeout"` // Hang up connections without audio for this long (default 30s)
+	Model        string        `yaml:"model"`         // Transcription model (defaults to audio.default_model)
+	Language     string        `yaml:"language"`      // Transcription language (defaults to the model's default)
+	APIKey       string        `yaml:"api_key"`       // Key call sessions are accounted to for quotas and tenants
+	AllowedPeers []string      `yaml:"allowed_peers"` // IPs or CIDRs allowed to connect, empty allows all
+}
+
+// Enabled reports whether the AudioSocket listener is configured
+func (a *AudioSocketConfig) Enabled() bool {
+	return a.Listen != ""
+}
+
+// MQTTConfig holds the MQTT client that publishes transcripts. Topics may
+// contain {session_id}, which is replaced with the session's ID.
+type MQTTConfig struct {
+	Broker          string `yaml:"broker"`    // Broker URL, e.g. "tcp://localhost:1883" or "ssl://host:8883"; empty disables publishing
+	ClientID        string `yaml:"client_id"` // MQTT client ID (default "gribe")
+	Username        string `yaml:"username"`  // Optional broker credentials
+	Password        string `yaml:"password"`
+	TranscriptTopic string `yaml:"transcript_topic"` // Topic for final transcripts (default "gribe/sessions/{session_id}/transcript")
+	DeltaTopic      string `yaml:"delta_topic"`      // Topic for transcription deltas, empty publishes none
+	QoS             int    `yaml:"qos"`              // Publish QoS, 0-2 (default 0)
+}
+
+// Enabled reports whether transcripts are published over MQTT
+func (m *MQTTConfig) Enabled() bool {
+	return m.Broker != ""
+}
+
+// WebRTCConfig holds the WebRTC transport, which accepts realtime sessions
+// whose audio arrives as a media track and whose events use a data channel
+type WebRTCConfig struct {
+	Enabled    bool     `yaml:"enabled"`      // Serve POST /v1/realtime/calls
+	ICEServers []string `yaml:"ice_servers"`  // STUN/TURN URLs used to gather candidates, e.g. "stun:stun.l.google.com:19302"
+	PublicIPs  []string `yaml:"public_ips"`   // IPs advertised in host candidates when behind 1:1 NAT
+	UDPPortMin int      `yaml:"udp_port_min"` // First UDP port for media, 0 lets the OS choose
+	UDPPortMax int      `yaml:"udp_port_max"` // Last UDP port for media
+}
+
+// WatchConfig holds the directory watcher batch mode, which transcribes WAV
+// files dropped into a directory and writes transcript files next to them
+type WatchConfig struct {
+	Dir          string        `yaml:"dir"`           // Directory watched for audio files; empty disables the watcher
+	OutputDir    string        `yaml:"output_dir"`    // Directory for the transcript files (defaults to dir)
+	Formats      []string      `yaml:"formats"`       // Transcript files written per audio file: txt, json, srt (default txt, json)
+	PollInterval time.Duration `yaml:"poll_interval"` // How often dir is scanned (default 5s)
+	Model        string        `yaml:"model"`         // Transcription model (defaults to audio.default_model)
+	Language     string        `yaml:"language"`      // Transcription language (defaults to the model's default)
+	APIKey       string        `yaml:"api_key"`       // Key the sessions are accounted to for quotas and tenants
+}
+
+// Enabled reports whether the directory watcher is configured
+func (w *WatchConfig) Enabled() bool {
+	return w.Dir != ""
+}
+
+// BatchConfig holds the batch transcription endpoint, which accepts an
+// uploaded file or fetches one from a source URL
+type BatchConfig struct {
+	MaxBytes     int               `yaml:"max_bytes"`     // Largest audio file accepted, uploaded or fetched (default 200MB)
+	FetchTimeout time.Duration     `yaml:"fetch_timeout"` // Time allowed to download a source URL (default 2m)
+	AllowedHosts []string          `yaml:"allowed_hosts"` // Hosts source URLs may use ("*.example.com" allowed) and "s3://bucket" / "gs://bucket"; empty disables URL input
+	S3           ObjectStoreConfig `yaml:"s3"`            // Credentials for s3:// sources
+	GCS          ObjectStoreConfig `yaml:"gcs"`           // HMAC credentials for gs:// sources
+	Workers      int               `yaml:"workers"`       // Transcription jobs run concurrently (default 2)
+	QueueSize    int               `yaml:"queue_size"`    // Jobs waiting for a worker before new ones are refused (default 100)
+	JobRetention time.Duration     `yaml:"job_retention"` // How long finished jobs can be fetched (default 24h)
+
+	CallbackHosts    []string      `yaml:"callback_hosts"`    // Hosts job callback_url may target ("*.example.com" allowed); empty disables callbacks
+	CallbackSecret   string        `yaml:"callback_secret"`   // HMAC key signing callback payloads
+	CallbackAttempts int           `yaml:"callback_attempts"` // Deliveries tried per callback (default 5)
+	CallbackBackoff  time.Duration `yaml:"callback_backoff"`  // Delay before the first retry, doubled for each further one (default 2s)
+}
+
+// ObjectStoreConfig holds the credentials for an object store. Without an
+// access key, objects are fetched anonymously.
+type ObjectStoreConfig struct {
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	Region          string `yaml:"region"`   // Signing region (default us-east-1 for S3, auto for GCS)
+	Endpoint        string `yaml:"endpoint"` // Replaces the provider's URL, e.g. "https://minio:9000" for S3-compatible stores
+}
+
+// TenantConfig holds per-tenant restrictions and limits. A connection belongs
+// to the tenant whose api_keys contain the key it authenticated with, or whose
+// jwt_claim_values contain its token's tenant claim.
+type TenantConfig struct {
+	APIKeys          []string     `yaml:"api_keys"`          // Keys identifying this tenant, also accepted for auth
+	JWTClaimValues   []string     `yaml:"jwt_claim_values"`  // Values of auth.jwt.tenant_claim identifying this tenant
+	AllowedModels    []string     `yaml:"allowed_models"`    // Empty means all configured models
+	AllowedLanguages []string     `yaml:"allowed_languages"` // Empty means all languages supported by the model
+	Quota            *QuotaConfig `yaml:"quota"`             // Overrides the global quota for this tenant's keys
+	RecordDir        string       `yaml:"record_dir"`        // Overrides record.dir for this tenant's sessions
+	RetentionDays    int          `yaml:"retention_days"`    // Overrides retention.days for this tenant's data, 0 inherits it
+}
+
+// ASRConfig holds ASR provider configuration loaded from YAML
+type ASRConfig struct {
+	Provider     string                 `yaml:"provider"`      // Default execution provider of models: cpu or cuda
+	NumThreads   int                    `yaml:"num_threads"`   // Default number of threads for inference of a model
+	ModelsDir    string                 `yaml:"models_dir"`    // Base directory for models
+	DefaultModel string                 `yaml:"default_model"` // Default model to use
+	Models       map[string]ModelConfig `yaml:"models"`        // Model configurations
+
+	// LanguageRoutes maps a language to the model selected when a session
+	// switches to it without naming a model
+	LanguageRoutes map[string]string `yaml:"language_routes"`
+
+	// GPUMemoryMB is the memory of each GPU that models may take, in MB. A
+	// model whose vram_mb does not fit in what is left of its device's
+	// budget is refused instead of loaded. 0 disables the accounting.
+	GPUMemoryMB int `yaml:"gpu_memory_mb"`
+
+	// CPUBudget is the inference threads of all loaded cpu models together;
+	// a model whose num_threads do not fit is refused. 0 disables the limit.
+	// FitGOMAXPROCS caps it at GOMAXPROCS-1, leaving a CPU to the event loops.
+	CPUBudget     int  `yaml:"cpu_budget"`
+	FitGOMAXPROCS bool `yaml:"fit_gomaxprocs"`
+
+	// Speaker configures the optional speaker identification stage
+	Speaker SpeakerConfig `yaml:"speaker"`
+
+	// SelfTest transcribes a known sample with each model at startup
+	SelfTest SelfTestConfig `yaml:"self_test"`
+
+	// Shadow transcribes a sample of segments again with another model
+	Shadow ShadowConfig `yaml:"shadow"`
+}
+
+// ShadowConfig holds shadow transcription: a share of the committed segments
+// is transcribed again by a secondary model in the background, and both
+// transcripts are logged with their latencies to evaluate the model before
+// sessions are moved to it. Clients only ever get the session model's.
+type ShadowConfig struct {
+	Model         string  `yaml:"model"`          // Model in asr.models transcribing the sample, empty disables shadowing
+	Percent       float64 `yaml:"percent"`        // Share of segments shadowed, above 0 and at most 100
+	MaxConcurrent int     `yaml:"max_concurrent"` // Shadow transcriptions at once (default 1); sampled segments beyond are skipped
+	LogFile       string  `yaml:"log_file"`       // JSON Lines file comparisons are appended to, empty only logs them
+}
+
+// Enabled reports whether shadow transcription is configured
+func (s *ShadowConfig) Enabled() bool {
+	return s.Model != ""
+}
+
+// SelfTestConfig holds the startup self-test, which loads every model and
+// transcribes a short WAV with it through the session pipeline, to catch
+// broken models or libraries before traffic arrives
+type SelfTestConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Sample is the 16-bit PCM WAV transcribed by models without a
+	// test_wavs/0.wav of their own in their directory, as sherpa-onnx models
+	// ship. Models with neither are not tested.
+	Sample string `yaml:"sample"`
+
+	Timeout time.Duration `yaml:"timeout"` // Time each model has for a non-empty transcript (default 30s)
+	Strict  bool          `yaml:"strict"`  // Refuse to start when a model fails, instead of reporting it degraded
+}
+
+// SpeakerConfig holds speaker identification, which computes a voiceprint of
+// each transcribed turn with a sherpa-onnx speaker embedding model and
+// matches it against the profiles enrolled through /admin/speakers
+type SpeakerConfig struct {
+	Model        string  `yaml:"model"`         // Embedding model file in models_dir, empty disables the stage
+	NumThreads   int     `yaml:"num_threads"`   // Inference threads of the model (default 1)
+	Threshold    float64 `yaml:"threshold"`     // Minimum cosine similarity to identify a speaker (default 0.5)
+	ProfilesFile string  `yaml:"profiles_file"` // JSON file enrolled profiles are kept in, empty keeps them in memory
+}
+
+// Enabled reports whether speaker identification is configured
+func (s *SpeakerConfig) Enabled() bool {
+	return s.Model != ""
+}
+
+// ModelConfig holds configuration for a specific ASR model
+type ModelConfig struct {
+	Provider  string   `yaml:"provider"`  // Provider type (e.g., "sherpa-onnx", "whisper-cpp")
+	Encoder   string   `yaml:"encoder"`   // Path to encoder model file
+	Decoder   string   `yaml:"decoder"`   // Path to decoder model file
+	Joiner    string   `yaml:"joiner"`    // Path to joiner model file
+	Tokens    string   `yaml:"tokens"`    // Path to tokens file
+	Languages []string `yaml:"languages"` // Supported languages
+
+	// Precision selects the model variant: fp32 (default) or int8, which
+	// loads the Int8 files instead, trading accuracy for CPU throughput
+	Precision string          `yaml:"precision"`
+	Int8      *QuantizedFiles `yaml:"int8"`
+
+	// ExecutionProvider runs the model on cpu or cuda, empty for asr.provider.
+	// CUDA models are placed on DeviceID and take an estimated VRAMMB of its
+	// memory, accounted against asr.gpu_memory_mb.
+	ExecutionProvider string `yaml:"execution_provider"`
+	DeviceID          int    `yaml:"device_id"`
+	VRAMMB            int    `yaml:"vram_mb"`
+
+	NumThreads int `yaml:"num_threads"` // Inference threads, 0 for asr.num_threads
+
+	// URL, when set, is where files missing from the model's directory are
+	// downloaded from at startup: the file itself for a single-file model,
+	// else the base URL the file names are appended to
+	URL string `yaml:"url"`
+
+	// Checksums holds the sha256 of model files, keyed by path relative to
+	// the model's directory ("." for a single-file model), verified before
+	// the model is loaded
+	Checksums map[string]string `yaml:"checksums"`
+
+	Defaults *ModelDefaults `yaml:"defaults"` // Session defaults applied when the model is selected
+}
+
+// QuantizedFiles names the int8-quantized files of a sherpa-onnx model. An
+// unset joiner keeps the full-precision one.
+type QuantizedFiles struct {
+	Encoder string `yaml:"encoder"`
+	Decoder string `yaml:"decoder"`
+	Joiner  string `yaml:"joiner"`
+}
+
+// ModelDefaults holds session settings applied when a model is selected.
+// Zero values keep the current session setting; anything the client sends in
+// the same update takes precedence.
+type ModelDefaults struct {
+	TurnDetection TurnDetectionDefaults `yaml:"turn_detection"`
+	Prompt        string                `yaml:"prompt"` // Transcription prompt
+}
+
+// TurnDetectionDefaults holds per-model server VAD tuning
+type TurnDetectionDefaults struct {
+	Threshold         float64 `yaml:"threshold"`           // 0.0-1.0
+	PrefixPaddingMs   int     `yaml:"prefix_padding_ms"`   // Audio kept before detected speech
+	SilenceDurationMs int     `yaml:"silence_duration_ms"` // Silence that ends a turn
+}
+
+// YAMLConfig holds configuration loaded from YAML file
+type YAMLConfig struct {
+	Server        ServerConfig            `yaml:"server"`
+	Auth          AuthConfig              `yaml:"auth"`
+	Audio         AudioConfig             `yaml:"audio"`
+	Rate          RateLimitConfig         `yaml:"rate"`
+	ASR           ASRConfig               `yaml:"asr"`
+	Record        RecordConfig            `yaml:"record"`
+	Cache         CacheConfig             `yaml:"cache"`
+	Conversations ConversationsConfig     `yaml:"conversations"`
+	Usage         UsageConfig             `yaml:"usage"`
+	Retention     RetentionConfig         `yaml:"retention"`
+	Runtime       RuntimeConfig           `yaml:"runtime"`
+	IDs           IDConfig                `yaml:"ids"`
+	Quota         QuotaConfig             `yaml:"quota"`
+	Admin         AdminConfig             `yaml:"admin"`
+	SIP           SIPConfig               `yaml:"sip"`
+	AudioSocket   AudioSocketConfig       `yaml:"audiosocket"`
+	MQTT          MQTTConfig              `yaml:"mqtt"`
+	WebRTC        WebRTCConfig            `yaml:"webrtc"`
+	Watch         WatchConfig             `yaml:"watch"`
+	Batch         BatchConfig             `yaml:"batch"`
+	Tenants       map[string]TenantConfig `yaml:"tenants"`
+
+	keys map[string]bool // Dotted paths of the keys the file sets, e.g. "server.max_sessions"
+}
+
+// set reports whether the file sets key, so its value applies even when it
+// is 0, false or out of range, for Validate to refuse
+func (c *YAMLConfig) set(key string) bool {
+	return c.keys[key]
+}
+
+// Load loads configuration from environment variables
+func Load() *Config {
+	cfg := &Config{
+		Server: ServerConfig{
+			Port:               getEnv("GRIBE_PORT", "8080"),
+			BasePath:           getEnv("GRIBE_BASE_PATH", ""),
+			AllowedOrigins:     getEnvSlice("GRIBE_ALLOWED_ORIGINS", nil), // nil = wildcard
+			MaxSessions:        getEnvInt("GRIBE_MAX_SESSIONS", 0),        // 0 = unlimited
+			MemoryBudget:       getEnvInt("GRIBE_MEMORY_BUDGET", 0),       // 0 = unlimited
+			ResumeWindow:       time.Duration(getEnvInt("GRIBE_SESSION_RESUME_WINDOW_SECONDS", 0)) * time.Second,
+			EventDedupWindow:   time.Duration(getEnvInt("GRIBE_EVENT_DEDUP_WINDOW_SECONDS", 0)) * time.Second,
+			EventBatchInterval: time.Duration(getEnvInt("GRIBE_EVENT_BATCH_INTERVAL_MS", 0)) * time.Millisecond,
+			Protocol:           getEnv("GRIBE_PROTOCOL", ""),
+			CORS: CORSConfig{
+				AllowedMethods: getEnvSlice("GRIBE_CORS_ALLOWED_METHODS", []string{"GET", "POST", "DELETE"}),
+				AllowedHeaders: getEnvSlice("GRIBE_CORS_ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "Last-Event-ID"}),
+				MaxAge:         time.Duration(getEnvInt("GRIBE_CORS_MAX_AGE_SECONDS", 600)) * time.Second,
+			},
+			AccessLog: getEnvBool("GRIBE_ACCESS_LOG", false),
+		},
+		Auth: AuthConfig{
+			APIKeys:     getEnvSlice("GRIBE_API_KEYS", nil), // nil = no auth required
+			APIKeysFile: getEnv("GRIBE_API_KEYS_FILE", ""),
+			JWT: JWTConfig{
+				JWKSURL:         getEnv("GRIBE_JWT_JWKS_URL", ""), // empty = JWT auth disabled
+				Issuer:          getEnv("GRIBE_JWT_ISSUER", ""),
+				Audience:        getEnv("GRIBE_JWT_AUDIENCE", ""),
+				RefreshInterval: time.Hour,
+				ScopeClaim:      "scope",
+			},
+		},
+		Audio: AudioConfig{
+			MaxBufferSize:        getEnvInt("GRIBE_MAX_AUDIO_BUFFER_SIZE", 15*1024*1024), // 15MB default
+			TranscriptionTimeout: time.Duration(getEnvInt("GRIBE_TRANSCRIPTION_TIMEOUT_SECONDS", 30)) * time.Second,
+			DefaultModel:         getEnv("GRIBE_DEFAULT_MODEL", ""), // empty = asr.default_model
+			DefaultLanguage:      getEnv("GRIBE_DEFAULT_LANGUAGE", ""),
+			MinDeltaInterval:     time.Duration(getEnvInt("GRIBE_MIN_DELTA_INTERVAL_MS", 0)) * time.Millisecond,
+			MinDeltaChars:        getEnvInt("GRIBE_MIN_DELTA_CHARS", 0),
+			StabilityWindow:      getEnvInt("GRIBE_STABILITY_WINDOW", 2),
+			TranscriptionRetries: getEnvInt("GRIBE_TRANSCRIPTION_RETRIES", 2),
+			RetryBackoff:         time.Duration(getEnvInt("GRIBE_TRANSCRIPTION_RETRY_BACKOFF_MS", 200)) * time.Millisecond,
+			SpillThreshold:       getEnvInt("GRIBE_AUDIO_SPILL_THRESHOLD", 0), // 0 = in memory
+			SpillDir:             getEnv("GRIBE_AUDIO_SPILL_DIR", ""),
+			MaxTranscriptions:    getEnvInt("GRIBE_MAX_TRANSCRIPTIONS", 0), // 0 = unlimited
+		},
+		Rate: RateLimitConfig{
+			MaxConnectionsPerIP:    getEnvInt("GRIBE_MAX_CONNECTIONS_PER_IP", 10),
+			RequestsPerSecond:      getEnvInt("GRIBE_REQUESTS_PER_SECOND", 100),
+			BurstSize:              getEnvInt("GRIBE_RATE_BURST_SIZE", 50),
+			CleanupInterval:        time.Duration(getEnvInt("GRIBE_RATE_CLEANUP_SECONDS", 60)) * time.Second,
+			MaxEventsPerSecond:     getEnvInt("GRIBE_MAX_EVENTS_PER_SECOND", 200),
+			MaxAppendsPerSecond:    getEnvInt("GRIBE_MAX_APPENDS_PER_SECOND", 100),
+			MaxBytesPerSecond:      getEnvInt("GRIBE_MAX_BYTES_PER_SECOND", 1024*1024), // 1MB/s
+			MaxViolations:          getEnvInt("GRIBE_MAX_VIOLATIONS", 50),
+			ConnectionLimitPolicy:  getEnv("GRIBE_CONNECTION_LIMIT_POLICY", ConnectionLimitReject),
+			EvictIdleAfter:         time.Duration(getEnvInt("GRIBE_EVICT_IDLE_AFTER_SECONDS", 30)) * time.Second,
+			BanAfterAuthFailures:   getEnvInt("GRIBE_BAN_AFTER_AUTH_FAILURES", 0), // 0 = no bans
+			BanAfterRateViolations: getEnvInt("GRIBE_BAN_AFTER_RATE_VIOLATIONS", 0),
+			BanWindow:              time.Duration(getEnvInt("GRIBE_BAN_WINDOW_SECONDS", 60)) * time.Second,
+			BanDuration:            time.Duration(getEnvInt("GRIBE_BAN_DURATION_SECONDS", 60)) * time.Second,
+			MaxBanDuration:         time.Duration(getEnvInt("GRIBE_MAX_BAN_DURATION_SECONDS", 3600)) * time.Second,
+		},
+		Record: RecordConfig{
+			Dir: getEnv("GRIBE_RECORD_DIR", ""), // empty = recording disabled
+		},
+		Cache: CacheConfig{
+			MaxEntries: getEnvInt("GRIBE_CACHE_MAX_ENTRIES", 0), // 0 = cache disabled
+			TTL:        time.Duration(getEnvInt("GRIBE_CACHE_TTL_SECONDS", 3600)) * time.Second,
+		},
+		Conversations: ConversationsConfig{
+			Dir:        getEnv("GRIBE_CONVERSATIONS_DIR", ""),           // empty = kept in memory
+			MaxEntries: getEnvInt("GRIBE_CONVERSATIONS_MAX_ENTRIES", 0), // 0 = store disabled
+		},
+		Usage: UsageConfig{
+			Sink:   getEnv("GRIBE_USAGE_SINK", ""), // empty = export disabled
+			File:   getEnv("GRIBE_USAGE_FILE", ""),
+			URL:    getEnv("GRIBE_USAGE_URL", ""),
+			Secret: getEnv("GRIBE_USAGE_SECRET", ""),
+			S3: ObjectStoreConfig{
+				AccessKeyID:     getEnv("GRIBE_USAGE_S3_ACCESS_KEY_ID", ""),
+				SecretAccessKey: getEnv("GRIBE_USAGE_S3_SECRET_ACCESS_KEY", ""),
+				Region:          getEnv("GRIBE_USAGE_S3_REGION", ""),
+				Endpoint:        getEnv("GRIBE_USAGE_S3_ENDPOINT", ""),
+			},
+			Attempts:  getEnvInt("GRIBE_USAGE_ATTEMPTS", 5),
+			Backoff:   time.Duration(getEnvInt("GRIBE_USAGE_BACKOFF_SECONDS", 2)) * time.Second,
+			QueueSize: getEnvInt("GRIBE_USAGE_QUEUE_SIZE", 1000),
+		},
+		Retention: RetentionConfig{
+			Days:      getEnvInt("GRIBE_RETENTION_DAYS", 0), // 0 = kept until deleted otherwise
+			Interval:  time.Duration(getEnvInt("GRIBE_RETENTION_INTERVAL_SECONDS", 3600)) * time.Second,
+			DryRun:    getEnvBool("GRIBE_RETENTION_DRY_RUN", false),
+			AuditFile: getEnv("GRIBE_RETENTION_AUDIT_FILE", ""),
+		},
+		Runtime: RuntimeConfig{
+			FitCgroup:        getEnvBool("GRIBE_FIT_CGROUP", false),
+			MemoryLimitRatio: getEnvFloat("GRIBE_MEMORY_LIMIT_RATIO", 0.9),
+		},
+		IDs: IDConfig{
+			Strategy: getEnv("GRIBE_ID_STRATEGY", "short-uuid"),
+			Node:     getEnvInt("GRIBE_ID_NODE", 0),
+		},
+		Quota: QuotaConfig{
+			DailyAudioSeconds:   getEnvInt("GRIBE_QUOTA_DAILY_AUDIO_SECONDS", 0),   // 0 = unlimited
+			MonthlyAudioSeconds: getEnvInt("GRIBE_QUOTA_MONTHLY_AUDIO_SECONDS", 0), // 0 = unlimited
+			SoftLimit:           getEnvBool("GRIBE_QUOTA_SOFT_LIMIT", false),
+		},
+		Admin: AdminConfig{
+			APIKeys: getEnvSlice("GRIBE_ADMIN_API_KEYS", nil), // nil = admin endpoints disabled
+			Listen:  getEnv("GRIBE_ADMIN_LISTEN", ""),         // empty = public port
+		},
+		SIP: SIPConfig{
+			Listen:        getEnv("GRIBE_SIP_LISTEN", ""), // empty = SIP gateway disabled
+			PublicAddress: getEnv("GRIBE_SIP_PUBLIC_ADDRESS", ""),
+			RTPPortMin:    getEnvInt("GRIBE_SIP_RTP_PORT_MIN", 10000),
+			RTPPortMax:    getEnvInt("GRIBE_SIP_RTP_PORT_MAX", 10999),
+			MediaTimeout:  time.Duration(getEnvInt("GRIBE_SIP_MEDIA_TIMEOUT_SECONDS", 30)) * time.Second,
+			Model:         getEnv("GRIBE_SIP_MODEL", ""),
+			Language:      getEnv("GRIBE_SIP_LANGUAGE", ""),
+			APIKey:        getEnv("GRIBE_SIP_API_KEY", ""),
+			AllowedPeers:  getEnvSlice("GRIBE_SIP_ALLOWED_PEERS", nil), // nil = any peer
+		},
+		AudioSocket: AudioSocketConfig{
+			Listen:       getEnv("GRIBE_AUDIOSOCKET_LISTEN", ""), // empty = AudioSocket disabled
+			MediaTimeout: time.Duration(getEnvInt("GRIBE_AUDIOSOCKET_MEDIA_TIMEOUT_SECONDS", 30)) * time.Second,
+			Model:        getEnv("GRIBE_AUDIOSOCKET_MODEL", ""),
+			Language:     getEnv("GRIBE_AUDIOSOCKET_LANGUAGE", ""),
+			APIKey:       getEnv("GRIBE_AUDIOSOCKET_API_KEY", ""),
+			AllowedPeers: getEnvSlice("GRIBE_AUDIOSOCKET_ALLOWED_PEERS", nil), // nil = any peer
+		},
+		MQTT: MQTTConfig{
+			Broker:          getEnv("GRIBE_MQTT_BROKER", ""), // empty = MQTT publishing disabled
+			ClientID:        getEnv("GRIBE_MQTT_CLIENT_ID", "gribe"),
+			Username:        getEnv("GRIBE_MQTT_USERNAME", ""),
+			Password:        getEnv("GRIBE_MQTT_PASSWORD", ""),
+			TranscriptTopic: getEnv("GRIBE_MQTT_TRANSCRIPT_TOPIC", "gribe/sessions/{session_id}/transcript"),
+			DeltaTopic:      getEnv("GRIBE_MQTT_DELTA_TOPIC", ""), // empty = deltas not published
+			QoS:             getEnvInt("GRIBE_MQTT_QOS", 0),
+		},
+		WebRTC: WebRTCConfig{
+			Enabled:    getEnvBool("GRIBE_WEBRTC_ENABLED", false),
+			ICEServers: getEnvSlice("GRIBE_WEBRTC_ICE_SERVERS", nil),
+			PublicIPs:  getEnvSlice("GRIBE_WEBRTC_PUBLIC_IPS", nil),
+			UDPPortMin: getEnvInt("GRIBE_WEBRTC_UDP_PORT_MIN", 0),
+			UDPPortMax: getEnvInt("GRIBE_WEBRTC_UDP_PORT_MAX", 0),
+		},
+		Watch: WatchConfig{
+			Dir:          getEnv("GRIBE_WATCH_DIR", ""), // empty = watcher disabled
+			OutputDir:    getEnv("GRIBE_WATCH_OUTPUT_DIR", ""),
+			Formats:      getEnvSlice("GRIBE_WATCH_FORMATS", []string{"txt", "json"}),
+			PollInterval: time.Duration(getEnvInt("GRIBE_WATCH_POLL_INTERVAL_SECONDS", 5)) * time.Second,
+			Model:        getEnv("GRIBE_WATCH_MODEL", ""),
+			Language:     getEnv("GRIBE_WATCH_LANGUAGE", ""),
+			APIKey:       getEnv("GRIBE_WATCH_API_KEY", ""),
+		},
+		Batch: BatchConfig{
+			MaxBytes:     getEnvInt("GRIBE_BATCH_MAX_BYTES", 200*1024*1024), // 200MB
+			FetchTimeout: time.Duration(getEnvInt("GRIBE_BATCH_FETCH_TIMEOUT_SECONDS", 120)) * time.Second,
+			AllowedHosts: getEnvSlice("GRIBE_BATCH_ALLOWED_HOSTS", nil), // nil = URL input disabled
+			S3: ObjectStoreConfig{
+				AccessKeyID:     getEnv("GRIBE_BATCH_S3_ACCESS_KEY_ID", ""),
+				SecretAccessKey: getEnv("GRIBE_BATCH_S3_SECRET_ACCESS_KEY", ""),
+				Region:          getEnv("GRIBE_BATCH_S3_REGION", ""),
+				Endpoint:        getEnv("GRIBE_BATCH_S3_ENDPOINT", ""),
+			},
+			GCS: ObjectStoreConfig{
+				AccessKeyID:     getEnv("GRIBE_BATCH_GCS_ACCESS_KEY_ID", ""),
+				SecretAccessKey: getEnv("GRIBE_BATCH_GCS_SECRET_ACCESS_KEY", ""),
+				Endpoint:        getEnv("GRIBE_BATCH_GCS_ENDPOINT", ""),
+			},
+			Workers:      getEnvInt("GRIBE_BATCH_WORKERS", 2),
+			QueueSize:    getEnvInt("GRIBE_BATCH_QUEUE_SIZE", 100),
+			JobRetention: time.Duration(getEnvInt("GRIBE_BATCH_JOB_RETENTION_SECONDS", 86400)) * time.Second,
+
+			CallbackHosts:    getEnvSlice("GRIBE_BATCH_CALLBACK_HOSTS", nil), // nil = callbacks disabled
+			CallbackSecret:   getEnv("GRIBE_BATCH_CALLBACK_SECRET", ""),
+			CallbackAttempts: getEnvInt("GRIBE_BATCH_CALLBACK_ATTEMPTS", 5),
+			CallbackBackoff:  time.Duration(getEnvInt("GRIBE_BATCH_CALLBACK_BACKOFF_SECONDS", 2)) * time.Second,
+		},
+	}
+
+	cfg.resolveConfigSecrets()
+	cfg.loadAPIKeysFile()
+	return cfg
+}
+
+// IsOriginAllowed checks if the given origin is allowed
+func (c *Config) IsOriginAllowed(origin string) bool {
+	// If no origins configured, allow all (wildcard)
+	if len(c.Server.AllowedOrigins) == 0 {
+		return true
+	}
+
+	// Check if origin matches any allowed origin
+	for _, allowed := range c.Server.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+		if allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// API key scopes
+const (
+	ScopeRealtimeTranscribe = "realtime:transcribe" // Open transcription sessions
+	ScopeAdminRead          = "admin:read"          // Read admin endpoints
+	ScopeAdminWrite         = "admin:write"         // Modify state through admin endpoints
+	ScopeAll                = "*"                   // Every scope
+)
+
+// IsAPIKeyValid checks if the given API key may open transcription sessions
+func (c *Config) IsAPIKeyValid(apiKey string) bool {
+	return c.HasScope(apiKey, ScopeRealtimeTranscribe)
+}
+
+// HasScope checks if apiKey was granted scope. Keys in auth.api_keys and
+// tenants get realtime:transcribe, keys in admin.api_keys get admin:read and
+// admin:write, and auth.keys entries get the scopes they list.
+// When no key grants realtime:transcribe, transcription requires no auth.
+func (c *Config) HasScope(apiKey, scope string) bool {
+	if scope == ScopeRealtimeTranscribe && !c.realtimeAuthEnabled() {
+		return true
+	}
+	if apiKey == "" {
+		return false
+	}
+	for _, granted := range c.ScopesForAPIKey(apiKey) {
+		if granted == scope || granted == ScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopesForAPIKey returns all scopes granted to apiKey
+func (c *Config) ScopesForAPIKey(apiKey string) []string {
+	var scopes []string
+	if containsString(c.APIKeys(), apiKey) {
+		scopes = append(scopes, ScopeRealtimeTranscribe)
+	}
+	if id, _ := c.TenantForAPIKey(apiKey); id != "" {
+		scopes = append(scopes, ScopeRealtimeTranscribe)
+	}
+	if containsString(c.Admin.APIKeys, apiKey) {
+		scopes = append(scopes, ScopeAdminRead, ScopeAdminWrite)
+	}
+	for _, key := range c.Auth.Keys {
+		if key.Key == apiKey {
+			scopes = append(scopes, key.Scopes...)
+		}
+	}
+	return scopes
+}
+
+// realtimeAuthEnabled reports whether any configured key grants realtime:transcribe
+// or a keys file or JWT auth is configured
+func (c *Config) realtimeAuthEnabled() bool {
+	if len(c.Auth.APIKeys) > 0 || c.Auth.APIKeysFile != "" || c.hasTenantKeys() || c.Auth.JWT.Enabled() {
+		return true
+	}
+	for _, key := range c.Auth.Keys {
+		if containsString(key.Scopes, ScopeRealtimeTranscribe) || containsString(key.Scopes, ScopeAll) {
+			return true
+		}
+	}
+	return false
+}
+
+// TenantForAPIKey returns the tenant that owns apiKey, or "" and nil if none does
+func (c *Config) TenantForAPIKey(apiKey string) (string, *TenantConfig) {
+	if apiKey == "" {
+		return "", nil
+	}
+	for id, tenant := range c.Tenants {
+		for _, key := range tenant.APIKeys {
+			if key == apiKey {
+				tenant := tenant
+				return id, &tenant
+			}
+		}
+	}
+	return "", nil
+}
+
+func (c *Config) hasTenantKeys() bool {
+	for _, tenant := range c.Tenants {
+		if len(tenant.APIKeys) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper functions
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvSlice(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	// Split by comma and trim whitespace
+	parts := strings.Split(value, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+
+	if len(result) == 0 {
+		return defaultValue
+	}
+	return result
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	intVal, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return intVal
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	floatVal, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return floatVal
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	boolVal, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return boolVal
+}
+
+// envPlaceholder matches ${NAME} and ${NAME:-default}. Secret references such
+// as ${env:NAME} don't match and are resolved later by ResolveSecret.
+var envPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces ${NAME} placeholders in the scalars of a parsed document
+// with environment variable values, which therefore cannot add structure to
+// the document. Unset variables expand to their default; one without a
+// default is an error.
+func expandEnv(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		for _, child := range node.Content {
+			if err := expandEnv(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var unset string
+	value := envPlaceholder.ReplaceAllStringFunc(node.Value, func(match string) string {
+		groups := envPlaceholder.FindStringSubmatch(match)
+		if value, ok := os.LookupEnv(groups[1]); ok {
+			return value
+		}
+		if groups[2] == "" && unset == "" {
+			unset = groups[1]
+		}
+		return groups[3]
+	})
+	if unset != "" {
+		return fmt.Errorf("config references unset environment variable %s", unset)
+	}
+	if value != node.Value {
+		node.Value = value
+		if node.Style == 0 {
+			node.Tag = "" // A plain scalar takes the type of what it expands to
+		}
+	}
+	return nil
+}
+
+// LoadYAML loads the configuration from a YAML file
+func LoadYAML(path string) (*YAMLConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeYAML(data)
+}
+
+// decodeYAML decodes a YAML document into the configuration, expanding
+// environment variables and recording the keys it sets
+func decodeYAML(data []byte) (*YAMLConfig, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	cfg := YAMLConfig{keys: make(map[string]bool)}
+	if len(doc.Content) == 0 {
+		return &cfg, nil // Empty file
+	}
+	if err := expandEnv(&doc); err != nil {
+		return nil, err
+	}
+	if err := doc.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	collectKeys(doc.Content[0], "", cfg.keys)
+	return &cfg, nil
+}
+
+// collectKeys adds the dotted paths of the keys of a mapping node and its
+// nested mappings to keys
+func collectKeys(node *yaml.Node, prefix string, keys map[string]bool) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := prefix + node.Content[i].Value
+		keys[key] = true
+		collectKeys(node.Content[i+1], key+".", keys)
+	}
+}
+
+// LoadFile loads the configuration file at path in the format given by its
+// extension: .json, .toml, or YAML for anything else. All formats share the
+// YAML schema.
+func LoadFile(path string) (*YAMLConfig, error) {
+	var unmarshal func([]byte, interface{}) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		unmarshal = json.Unmarshal
+	case ".toml":
+		unmarshal = toml.Unmarshal
+	default:
+		return LoadYAML(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode generically, then reuse the YAML field names and duration parsing
+	var raw map[string]interface{}
+	if err := unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	normalized, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeYAML(normalized)
+}
+
+// LoadWithYAML loads configuration from environment variables and a config
+// file, which despite the name may also be JSON or TOML (see LoadFile)
+func LoadWithYAML(yamlPath string) *Config {
+	// 1. Start with environment variables (and defaults)
+	cfg := Load()
+
+	// 2. Try to load config file (YAML, JSON or TOML)
+	yamlCfg, err := LoadFile(yamlPath)
+	if err != nil {
+		log.Printf("Warning: Could not load config from %s: %v", yamlPath, err)
+		if !errors.Is(err, os.ErrNotExist) {
+			cfg.fileErr = err
+		}
+		// Set defaults for ASR config if YAML fails
+		cfg.ASR = ASRConfig{
+			Provider:   "cpu",
+			NumThreads: 4,
+			ModelsDir:  "./models",
+			Models:     make(map[string]ModelConfig),
+		}
+		return cfg
+	}
+
+	// 3. Override with YAML values if present
+	if yamlCfg.Server.Port != "" {
+		cfg.Server.Port = yamlCfg.Server.Port
+	}
+	if yamlCfg.Server.BasePath != "" {
+		cfg.Server.BasePath = yamlCfg.Server.BasePath
+	}
+	if len(yamlCfg.Server.AllowedOrigins) > 0 {
+		cfg.Server.AllowedOrigins = yamlCfg.Server.AllowedOrigins
+	}
+	if yamlCfg.set("server.max_sessions") {
+		cfg.Server.MaxSessions = yamlCfg.Server.MaxSessions
+	}
+	if yamlCfg.set("server.memory_budget") {
+		cfg.Server.MemoryBudget = yamlCfg.Server.MemoryBudget
+	}
+	if yamlCfg.set("server.resume_window") {
+		cfg.Server.ResumeWindow = yamlCfg.Server.ResumeWindow
+	}
+	if yamlCfg.set("server.event_dedup_window") {
+		cfg.Server.EventDedupWindow = yamlCfg.Server.EventDedupWindow
+	}
+	if yamlCfg.set("server.event_batch_interval") {
+		cfg.Server.EventBatchInterval = yamlCfg.Server.EventBatchInterval
+	}
+	if yamlCfg.Server.Protocol != "" {
+		cfg.Server.Protocol = yamlCfg.Server.Protocol
+	}
+	if yamlCfg.set("server.access_log") {
+		cfg.Server.AccessLog = yamlCfg.Server.AccessLog
+	}
+	if len(yamlCfg.Server.CORS.AllowedMethods) > 0 {
+		cfg.Server.CORS.AllowedMethods = yamlCfg.Server.CORS.AllowedMethods
+	}
+	if len(yamlCfg.Server.CORS.AllowedHeaders) > 0 {
+		cfg.Server.CORS.AllowedHeaders = yamlCfg.Server.CORS.AllowedHeaders
+	}
+	if yamlCfg.set("server.cors.max_age") {
+		cfg.Server.CORS.MaxAge = yamlCfg.Server.CORS.MaxAge
+	}
+
+	if len(yamlCfg.Auth.APIKeys) > 0 {
+		cfg.Auth.APIKeys = yamlCfg.Auth.APIKeys
+	}
+	if yamlCfg.Auth.APIKeysFile != "" {
+		cfg.Auth.APIKeysFile = yamlCfg.Auth.APIKeysFile
+	}
+	if len(yamlCfg.Auth.Keys) > 0 {
+		cfg.Auth.Keys = yamlCfg.Auth.Keys
+	}
+	if yamlCfg.Auth.JWT.JWKSURL != "" {
+		cfg.Auth.JWT.JWKSURL = yamlCfg.Auth.JWT.JWKSURL
+	}
+	if yamlCfg.Auth.JWT.Issuer != "" {
+		cfg.Auth.JWT.Issuer = yamlCfg.Auth.JWT.Issuer
+	}
+	if yamlCfg.Auth.JWT.Audience != "" {
+		cfg.Auth.JWT.Audience = yamlCfg.Auth.JWT.Audience
+	}
+	if yamlCfg.set("auth.jwt.refresh_interval") {
+		cfg.Auth.JWT.RefreshInterval = yamlCfg.Auth.JWT.RefreshInterval
+	}
+	if yamlCfg.Auth.JWT.ScopeClaim != "" {
+		cfg.Auth.JWT.ScopeClaim = yamlCfg.Auth.JWT.ScopeClaim
+	}
+	if yamlCfg.Auth.JWT.TenantClaim != "" {
+		cfg.Auth.JWT.TenantClaim = yamlCfg.Auth.JWT.TenantClaim
+	}
+
+	if yamlCfg.set("audio.max_audio_buffer_size") {
+		cfg.Audio.MaxBufferSize = yamlCfg.Audio.MaxBufferSize
+	}
+	if yamlCfg.set("audio.transcription_timeout") {
+		cfg.Audio.TranscriptionTimeout = yamlCfg.Audio.TranscriptionTimeout
+	}
+	if yamlCfg.Audio.DefaultModel != "" {
+		cfg.Audio.DefaultModel = yamlCfg.Audio.DefaultModel
+	}
+	if yamlCfg.Audio.DefaultLanguage != "" {
+		cfg.Audio.DefaultLanguage = yamlCfg.Audio.DefaultLanguage
+	}
+	if yamlCfg.set("audio.min_delta_interval") {
+		cfg.Audio.MinDeltaInterval = yamlCfg.Audio.MinDeltaInterval
+	}
+	if yamlCfg.set("audio.min_delta_chars") {
+		cfg.Audio.MinDeltaChars = yamlCfg.Audio.MinDeltaChars
+	}
+	if yamlCfg.set("audio.stability_window") {
+		cfg.Audio.StabilityWindow = yamlCfg.Audio.StabilityWindow
+	}
+	if yamlCfg.set("audio.transcription_retries") {
+		cfg.Audio.TranscriptionRetries = yamlCfg.Audio.TranscriptionRetries
+	}
+	if yamlCfg.set("audio.retry_backoff") {
+		cfg.Audio.RetryBackoff = yamlCfg.Audio.RetryBackoff
+	}
+	if yamlCfg.set("audio.spill_threshold") {
+		cfg.Audio.SpillThreshold = yamlCfg.Audio.SpillThreshold
+	}
+	if yamlCfg.Audio.SpillDir != "" {
+		cfg.Audio.SpillDir = yamlCfg.Audio.SpillDir
+	}
+	if yamlCfg.set("audio.max_transcriptions") {
+		cfg.Audio.MaxTranscriptions = yamlCfg.Audio.MaxTranscriptions
+	}
+
+	if yamlCfg.set("rate.max_connections_per_ip") {
+		cfg.Rate.MaxConnectionsPerIP = yamlCfg.Rate.MaxConnectionsPerIP
+	}
+	if yamlCfg.set("rate.requests_per_second") {
+		cfg.Rate.RequestsPerSecond = yamlCfg.Rate.RequestsPerSecond
+	}
+	if yamlCfg.set("rate.burst_size") {
+		cfg.Rate.BurstSize = yamlCfg.Rate.BurstSize
+	}
+	if yamlCfg.set("rate.cleanup_interval") {
+		cfg.Rate.CleanupInterval = yamlCfg.Rate.CleanupInterval
+	}
+	if yamlCfg.set("rate.max_events_per_second") {
+		cfg.Rate.MaxEventsPerSecond = yamlCfg.Rate.MaxEventsPerSecond
+	}
+	if yamlCfg.set("rate.max_appends_per_second") {
+		cfg.Rate.MaxAppendsPerSecond = yamlCfg.Rate.MaxAppendsPerSecond
+	}
+	if yamlCfg.set("rate.max_bytes_per_second") {
+		cfg.Rate.MaxBytesPerSecond = yamlCfg.Rate.MaxBytesPerSecond
+	}
+	if yamlCfg.set("rate.max_violations") {
+		cfg.Rate.MaxViolations = yamlCfg.Rate.MaxViolations
+	}
+	if yamlCfg.Rate.ConnectionLimitPolicy != "" {
+		cfg.Rate.ConnectionLimitPolicy = yamlCfg.Rate.ConnectionLimitPolicy
+	}
+	if yamlCfg.set("rate.evict_idle_after") {
+		cfg.Rate.EvictIdleAfter = yamlCfg.Rate.EvictIdleAfter
+	}
+	if yamlCfg.set("rate.ban_after_auth_failures") {
+		cfg.Rate.BanAfterAuthFailures = yamlCfg.Rate.BanAfterAuthFailures
+	}
+	if yamlCfg.set("rate.ban_after_rate_violations") {
+		cfg.Rate.BanAfterRateViolations = yamlCfg.Rate.BanAfterRateViolations
+	}
+	if yamlCfg.set("rate.ban_window") {
+		cfg.Rate.BanWindow = yamlCfg.Rate.BanWindow
+	}
+	if yamlCfg.set("rate.ban_duration") {
+		cfg.Rate.BanDuration = yamlCfg.Rate.BanDuration
+	}
+	if yamlCfg.set("rate.max_ban_duration") {
+		cfg.Rate.MaxBanDuration = yamlCfg.Rate.MaxBanDuration
+	}
+
+	if yamlCfg.Record.Dir != "" {
+		cfg.Record.Dir = yamlCfg.Record.Dir
+	}
+
+	if yamlCfg.set("cache.max_entries") {
+		cfg.Cache.MaxEntries = yamlCfg.Cache.MaxEntries
+	}
+	if yamlCfg.set("cache.ttl") {
+		cfg.Cache.TTL = yamlCfg.Cache.TTL
+	}
+
+	if yamlCfg.set("runtime.fit_cgroup") {
+		cfg.Runtime.FitCgroup = yamlCfg.Runtime.FitCgroup
+	}
+	if yamlCfg.set("runtime.memory_limit_ratio") {
+		cfg.Runtime.MemoryLimitRatio = yamlCfg.Runtime.MemoryLimitRatio
+	}
+
+	if yamlCfg.IDs.Strategy != "" {
+		cfg.IDs.Strategy = yamlCfg.IDs.Strategy
+	}
+	if yamlCfg.set("ids.node") {
+		cfg.IDs.Node = yamlCfg.IDs.Node
+	}
+	if len(yamlCfg.IDs.Prefixes) > 0 {
+		cfg.IDs.Prefixes = yamlCfg.IDs.Prefixes
+	}
+	if yamlCfg.Conversations.Dir != "" {
+		cfg.Conversations.Dir = yamlCfg.Conversations.Dir
+	}
+	if yamlCfg.set("conversations.max_entries") {
+		cfg.Conversations.MaxEntries = yamlCfg.Conversations.MaxEntries
+	}
+
+	if yamlCfg.Usage.Sink != "" {
+		cfg.Usage.Sink = yamlCfg.Usage.Sink
+	}
+	if yamlCfg.Usage.File != "" {
+		cfg.Usage.File = yamlCfg.Usage.File
+	}
+	if yamlCfg.Usage.URL != "" {
+		cfg.Usage.URL = yamlCfg.Usage.URL
+	}
+	if yamlCfg.Usage.Secret != "" {
+		cfg.Usage.Secret = yamlCfg.Usage.Secret
+	}
+	mergeObjectStore(&cfg.Usage.S3, yamlCfg.Usage.S3)
+	if yamlCfg.set("usage.attempts") {
+		cfg.Usage.Attempts = yamlCfg.Usage.Attempts
+	}
+	if yamlCfg.set("usage.backoff") {
+		cfg.Usage.Backoff = yamlCfg.Usage.Backoff
+	}
+	if yamlCfg.set("usage.queue_size") {
+		cfg.Usage.QueueSize = yamlCfg.Usage.QueueSize
+	}
+
+	if yamlCfg.set("retention.days") {
+		cfg.Retention.Days = yamlCfg.Retention.Days
+	}
+	if yamlCfg.set("retention.interval") {
+		cfg.Retention.Interval = yamlCfg.Retention.Interval
+	}
+	if yamlCfg.set("retention.dry_run") {
+		cfg.Retention.DryRun = yamlCfg.Retention.DryRun
+	}
+	if yamlCfg.Retention.AuditFile != "" {
+		cfg.Retention.AuditFile = yamlCfg.Retention.AuditFile
+	}
+
+	if yamlCfg.set("quota.daily_audio_seconds") {
+		cfg.Quota.DailyAudioSeconds = yamlCfg.Quota.DailyAudioSeconds
+	}
+	if yamlCfg.set("quota.monthly_audio_seconds") {
+		cfg.Quota.MonthlyAudioSeconds = yamlCfg.Quota.MonthlyAudioSeconds
+	}
+	if yamlCfg.set("quota.soft_limit") {
+		cfg.Quota.SoftLimit = yamlCfg.Quota.SoftLimit
+	}
+
+	if len(yamlCfg.Admin.APIKeys) > 0 {
+		cfg.Admin.APIKeys = yamlCfg.Admin.APIKeys
+	}
+	if yamlCfg.Admin.Listen != "" {
+		cfg.Admin.Listen = yamlCfg.Admin.Listen
+	}
+
+	if yamlCfg.SIP.Listen != "" {
+		cfg.SIP.Listen = yamlCfg.SIP.Listen
+	}
+	if yamlCfg.SIP.PublicAddress != "" {
+		cfg.SIP.PublicAddress = yamlCfg.SIP.PublicAddress
+	}
+	if yamlCfg.set("sip.rtp_port_min") {
+		cfg.SIP.RTPPortMin = yamlCfg.SIP.RTPPortMin
+	}
+	if yamlCfg.set("sip.rtp_port_max") {
+		cfg.SIP.RTPPortMax = yamlCfg.SIP.RTPPortMax
+	}
+	if yamlCfg.set("sip.media_timeout") {
+		cfg.SIP.MediaTimeout = yamlCfg.SIP.MediaTimeout
+	}
+	if yamlCfg.SIP.Model != "" {
+		cfg.SIP.Model = yamlCfg.SIP.Model
+	}
+	if yamlCfg.SIP.Language != "" {
+		cfg.SIP.Language = yamlCfg.SIP.Language
+	}
+	if yamlCfg.SIP.APIKey != "" {
+		cfg.SIP.APIKey = yamlCfg.SIP.APIKey
+	}
+	if len(yamlCfg.SIP.AllowedPeers) > 0 {
+		cfg.SIP.AllowedPeers = yamlCfg.SIP.AllowedPeers
+	}
+
+	if yamlCfg.AudioSocket.Listen != "" {
+		cfg.AudioSocket.Listen = yamlCfg.AudioSocket.Listen
+	}
+	if yamlCfg.set("audiosocket.media_timeout") {
+		cfg.AudioSocket.MediaTimeout = yamlCfg.AudioSocket.MediaTimeout
+	}
+	if yamlCfg.AudioSocket.Model != "" {
+		cfg.AudioSocket.Model = yamlCfg.AudioSocket.Model
+	}
+	if yamlCfg.AudioSocket.Language != "" {
+		cfg.AudioSocket.Language = yamlCfg.AudioSocket.Language
+	}
+	if yamlCfg.AudioSocket.APIKey != "" {
+		cfg.AudioSocket.APIKey = yamlCfg.AudioSocket.APIKey
+	}
+	if len(yamlCfg.AudioSocket.AllowedPeers) > 0 {
+		cfg.AudioSocket.AllowedPeers = yamlCfg.AudioSocket.AllowedPeers
+	}
+
+	if yamlCfg.MQTT.Broker != "" {
+		cfg.MQTT.Broker = yamlCfg.MQTT.Broker
+	}
+	if yamlCfg.MQTT.ClientID != "" {
+		cfg.MQTT.ClientID = yamlCfg.MQTT.ClientID
+	}
+	if yamlCfg.MQTT.Username != "" {
+		cfg.MQTT.Username = yamlCfg.MQTT.Username
+	}
+	if yamlCfg.MQTT.Password != "" {
+		cfg.MQTT.Password = yamlCfg.MQTT.Password
+	}
+	if yamlCfg.MQTT.TranscriptTopic != "" {
+		cfg.MQTT.TranscriptTopic = yamlCfg.MQTT.TranscriptTopic
+	}
+	if yamlCfg.MQTT.DeltaTopic != "" {
+		cfg.MQTT.DeltaTopic = yamlCfg.MQTT.DeltaTopic
+	}
+	if yamlCfg.set("mqtt.qos") {
+		cfg.MQTT.QoS = yamlCfg.MQTT.QoS
+	}
+
+	if yamlCfg.set("webrtc.enabled") {
+		cfg.WebRTC.Enabled = yamlCfg.WebRTC.Enabled
+	}
+	if len(yamlCfg.WebRTC.ICEServers) > 0 {
+		cfg.WebRTC.ICEServers = yamlCfg.WebRTC.ICEServers
+	}
+	if len(yamlCfg.WebRTC.PublicIPs) > 0 {
+		cfg.WebRTC.PublicIPs = yamlCfg.WebRTC.PublicIPs
+	}
+	if yamlCfg.set("webrtc.udp_port_min") {
+		cfg.WebRTC.UDPPortMin = yamlCfg.WebRTC.UDPPortMin
+	}
+	if yamlCfg.set("webrtc.udp_port_max") {
+		cfg.WebRTC.UDPPortMax = yamlCfg.WebRTC.UDPPortMax
+	}
+
+	if yamlCfg.Watch.Dir != "" {
+		cfg.Watch.Dir = yamlCfg.Watch.Dir
+	}
+	if yamlCfg.Watch.OutputDir != "" {
+		cfg.Watch.OutputDir = yamlCfg.Watch.OutputDir
+	}
+	if len(yamlCfg.Watch.Formats) > 0 {
+		cfg.Watch.Formats = yamlCfg.Watch.Formats
+	}
+	if yamlCfg.set("watch.poll_interval") {
+		cfg.Watch.PollInterval = yamlCfg.Watch.PollInterval
+	}
+	if yamlCfg.Watch.Model != "" {
+		cfg.Watch.Model = yamlCfg.Watch.Model
+	}
+	if yamlCfg.Watch.Language != "" {
+		cfg.Watch.Language = yamlCfg.Watch.Language
+	}
+	if yamlCfg.Watch.APIKey != "" {
+		cfg.Watch.APIKey = yamlCfg.Watch.APIKey
+	}
+
+	if yamlCfg.set("batch.max_bytes") {
+		cfg.Batch.MaxBytes = yamlCfg.Batch.MaxBytes
+	}
+	if yamlCfg.set("batch.fetch_timeout") {
+		cfg.Batch.FetchTimeout = yamlCfg.Batch.FetchTimeout
+	}
+	if len(yamlCfg.Batch.AllowedHosts) > 0 {
+		cfg.Batch.AllowedHosts = yamlCfg.Batch.AllowedHosts
+	}
+	mergeObjectStore(&cfg.Batch.S3, yamlCfg.Batch.S3)
+	if yamlCfg.set("batch.workers") {
+		cfg.Batch.Workers = yamlCfg.Batch.Workers
+	}
+	if yamlCfg.set("batch.queue_size") {
+		cfg.Batch.QueueSize = yamlCfg.Batch.QueueSize
+	}
+	if yamlCfg.set("batch.job_retention") {
+		cfg.Batch.JobRetention = yamlCfg.Batch.JobRetention
+	}
+	if len(yamlCfg.Batch.CallbackHosts) > 0 {
+		cfg.Batch.CallbackHosts = yamlCfg.Batch.CallbackHosts
+	}
+	if yamlCfg.Batch.CallbackSecret != "" {
+		cfg.Batch.CallbackSecret = yamlCfg.Batch.CallbackSecret
+	}
+	if yamlCfg.set("batch.callback_attempts") {
+		cfg.Batch.CallbackAttempts = yamlCfg.Batch.CallbackAttempts
+	}
+	if yamlCfg.set("batch.callback_backoff") {
+		cfg.Batch.CallbackBackoff = yamlCfg.Batch.CallbackBackoff
+	}
+	mergeObjectStore(&cfg.Batch.GCS, yamlCfg.Batch.GCS)
+
+	// Tenants are YAML-only
+	cfg.Tenants = yamlCfg.Tenants
+
+	// ASR section is mostly YAML-only anyway
+	cfg.ASR = yamlCfg.ASR
+
+	// Set ASR defaults if missing in YAML
+	if cfg.ASR.Provider == "" {
+		cfg.ASR.Provider = "cpu"
+	}
+	if cfg.ASR.NumThreads == 0 {
+		cfg.ASR.NumThreads = 4
+	}
+	if cfg.ASR.ModelsDir == "" {
+		cfg.ASR.ModelsDir = "./models"
+	}
+	if cfg.ASR.Speaker.NumThreads == 0 {
+		cfg.ASR.Speaker.NumThreads = 1
+	}
+	if cfg.ASR.Speaker.Threshold == 0 {
+		cfg.ASR.Speaker.Threshold = 0.5
+	}
+	if cfg.ASR.SelfTest.Timeout == 0 {
+		cfg.ASR.SelfTest.Timeout = 30 * time.Second
+	}
+	if cfg.ASR.Shadow.MaxConcurrent == 0 {
+		cfg.ASR.Shadow.MaxConcurrent = 1
+	}
+	if cfg.Audio.DefaultModel == "" {
+		cfg.Audio.DefaultModel = cfg.ASR.DefaultModel
+	}
+
+	// Resolve ${scheme:ref} secrets and (re)load the keys file with YAML values applied
+	cfg.resolveConfigSecrets()
+	cfg.loadAPIKeysFile()
+
+	return cfg
+}
+
+// mergeObjectStore overrides the object store settings set in YAML
+func mergeObjectStore(dst *ObjectStoreConfig, src ObjectStoreConfig) {
+	if src.AccessKeyID != "" {
+		dst.AccessKeyID = src.AccessKeyID
+	}
+	if src.SecretAccessKey != "" {
+		dst.SecretAccessKey = src.SecretAccessKey
+	}
+	if src.Region != "" {
+		dst.Region = src.Region
+	}
+	if src.Endpoint != "" {
+		dst.Endpoint = src.Endpoint
+	}
+}
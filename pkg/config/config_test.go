@@ -0,0 +1,725 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadWithYAML(t *testing.T) {
+	// Create a temporary YAML file
+	yamlContent := `
+server:
+  port: "9090"
+  allowed_origins:
+    - "http://localhost:3000"
+auth:
+  api_keys:
+    - "test-key-1"
+audio:
+  max_audio_buffer_size: 1000000
+  transcription_timeout: "15s"
+rate:
+  max_connections_per_ip: 5
+  requests_per_second: 50
+  burst_size: 25
+  cleanup_interval: "30s"
+  max_bytes_per_second: 0
+asr:
+  provider: "gpu"
+  num_threads: 8
+ids:
+  strategy: "snowflake"
+  node: 3
+  prefixes:
+    session: "s-"
+`
+	tmpFile, err := os.CreateTemp("", "config*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write([]byte(yamlContent)); err != nil {
+		t.Fatalf("Failed to write to temp file: %v", err)
+	}
+	tmpFile.Close()
+
+	// Set some environment variables to test precedence (YAML should win for specific fields)
+	os.Setenv("GRIBE_PORT", "8888")
+	defer os.Unsetenv("GRIBE_PORT")
+
+	cfg := LoadWithYAML(tmpFile.Name())
+
+	// Check if values are correctly loaded and overridden
+	if cfg.Server.Port != "9090" {
+		t.Errorf("Expected Port 9090 (from YAML), got %s", cfg.Server.Port)
+	}
+
+	if len(cfg.Server.AllowedOrigins) != 1 || cfg.Server.AllowedOrigins[0] != "http://localhost:3000" {
+		t.Errorf("Expected AllowedOrigins [http://localhost:3000], got %v", cfg.Server.AllowedOrigins)
+	}
+
+	if len(cfg.Auth.APIKeys) != 1 || cfg.Auth.APIKeys[0] != "test-key-1" {
+		t.Errorf("Expected APIKeys [test-key-1], got %v", cfg.Auth.APIKeys)
+	}
+
+	if cfg.Audio.MaxBufferSize != 1000000 {
+		t.Errorf("Expected MaxBufferSize 1000000, got %d", cfg.Audio.MaxBufferSize)
+	}
+
+	if cfg.Audio.TranscriptionTimeout != 15*time.Second {
+		t.Errorf("Expected TranscriptionTimeout 15s, got %v", cfg.Audio.TranscriptionTimeout)
+	}
+
+	if cfg.Rate.MaxConnectionsPerIP != 5 {
+		t.Errorf("Expected MaxConnectionsPerIP 5, got %d", cfg.Rate.MaxConnectionsPerIP)
+	}
+
+	if cfg.Rate.MaxBytesPerSecond != 0 || cfg.Rate.MaxEventsPerSecond != 200 {
+		t.Errorf("Expected the byte limit disabled and the event limit kept, got %d and %d",
+			cfg.Rate.MaxBytesPerSecond, cfg.Rate.MaxEventsPerSecond)
+	}
+
+	if cfg.Rate.CleanupInterval != 30*time.Second {
+		t.Errorf("Expected CleanupInterval 30s, got %v", cfg.Rate.CleanupInterval)
+	}
+
+	if cfg.ASR.Provider != "gpu" {
+		t.Errorf("Expected ASR Provider gpu, got %s", cfg.ASR.Provider)
+	}
+
+	if cfg.ASR.NumThreads != 8 {
+		t.Errorf("Expected ASR NumThreads 8, got %d", cfg.ASR.NumThreads)
+	}
+
+	if cfg.IDs.Strategy != "snowflake" || cfg.IDs.Node != 3 || cfg.IDs.Prefixes["session"] != "s-" {
+		t.Errorf("Expected snowflake IDs of node 3 with session prefix s-, got %+v", cfg.IDs)
+	}
+}
+
+func TestNegativeFileValuesRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+server:
+  max_sessions: -3
+audio:
+  max_audio_buffer_size: -100
+  transcription_timeout: -5s
+rate:
+  max_connections_per_ip: -1
+`), 0600)
+
+	// Values the file sets apply as written, for Validate to refuse
+	err := LoadWithYAML(path).Validate()
+	for _, want := range []string{
+		"server.max_sessions",
+		"audio.max_audio_buffer_size",
+		"audio.transcription_timeout",
+		"rate.max_connections_per_ip",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error for %s, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	// Test loading when YAML is missing
+	os.Unsetenv("GRIBE_PORT")
+	cfg := LoadWithYAML("non_existent.yaml")
+
+	if cfg.Server.Port != "8080" {
+		t.Errorf("Expected default Port 8080, got %s", cfg.Server.Port)
+	}
+
+	if cfg.ASR.Provider != "cpu" {
+		t.Errorf("Expected default ASR Provider cpu, got %s", cfg.ASR.Provider)
+	}
+}
+
+func TestTenantAPIKeys(t *testing.T) {
+	yamlContent := `
+auth:
+  api_keys: ["global-key"]
+tenants:
+  acme:
+    api_keys: ["acme-key"]
+    allowed_models: ["zipformer"]
+    quota:
+      daily_audio_seconds: 60
+`
+	tmpFile, err := os.CreateTemp("", "config*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Write([]byte(yamlContent))
+	tmpFile.Close()
+
+	cfg := LoadWithYAML(tmpFile.Name())
+
+	id, tenant := cfg.TenantForAPIKey("acme-key")
+	if id != "acme" || tenant == nil {
+		t.Fatalf("Expected tenant acme, got %q", id)
+	}
+	if tenant.Quota == nil || tenant.Quota.DailyAudioSeconds != 60 {
+		t.Errorf("Expected tenant quota of 60s, got %+v", tenant.Quota)
+	}
+	if id, _ := cfg.TenantForAPIKey("global-key"); id != "" {
+		t.Errorf("Expected no tenant for global key, got %q", id)
+	}
+
+	for key, valid := range map[string]bool{"global-key": true, "acme-key": true, "other": false, "": false} {
+		if cfg.IsAPIKeyValid(key) != valid {
+			t.Errorf("IsAPIKeyValid(%q) = %v, want %v", key, !valid, valid)
+		}
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	cfg := &Config{
+		Auth: AuthConfig{
+			APIKeys: []string{"plain"},
+			Keys: []APIKeyConfig{
+				{Key: "reader", Scopes: []string{ScopeAdminRead}},
+				{Key: "root", Scopes: []string{ScopeAll}},
+			},
+		},
+		Admin: AdminConfig{APIKeys: []string{"admin"}},
+	}
+
+	tests := []struct {
+		key   string
+		scope string
+		want  bool
+	}{
+		{"plain", ScopeRealtimeTranscribe, true},
+		{"plain", ScopeAdminRead, false},
+		{"reader", ScopeAdminRead, true},
+		{"reader", ScopeAdminWrite, false},
+		{"reader", ScopeRealtimeTranscribe, false},
+		{"admin", ScopeAdminWrite, true},
+		{"admin", ScopeRealtimeTranscribe, false},
+		{"root", ScopeAdminWrite, true},
+		{"root", ScopeRealtimeTranscribe, true},
+		{"", ScopeRealtimeTranscribe, false},
+	}
+	for _, tt := range tests {
+		if got := cfg.HasScope(tt.key, tt.scope); got != tt.want {
+			t.Errorf("HasScope(%q, %q) = %v, want %v", tt.key, tt.scope, got, tt.want)
+		}
+	}
+
+	// Admin-only keys don't turn on auth for transcription
+	adminOnly := &Config{Auth: AuthConfig{Keys: []APIKeyConfig{{Key: "reader", Scopes: []string{ScopeAdminRead}}}}}
+	if !adminOnly.IsAPIKeyValid("") {
+		t.Error("Expected transcription to stay open when no key grants realtime:transcribe")
+	}
+	if adminOnly.HasScope("", ScopeAdminRead) {
+		t.Error("Expected admin scope to always require a key")
+	}
+}
+
+func TestSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := dir + "/admin-key"
+	os.WriteFile(secretPath, []byte("admin-secret\n"), 0600)
+	os.Setenv("GRIBE_TEST_SECRET_KEY", "env-secret")
+	defer os.Unsetenv("GRIBE_TEST_SECRET_KEY")
+
+	keysPath := dir + "/keys.txt"
+	os.WriteFile(keysPath, []byte("# rotated weekly\nfile-key-1\n\nfile-key-2\n"), 0600)
+
+	yamlPath := dir + "/config.yaml"
+	os.WriteFile(yamlPath, []byte(`
+auth:
+  api_keys: ["${env:GRIBE_TEST_SECRET_KEY}", "${env:GRIBE_TEST_MISSING}", "${vault:secret/key}"]
+  api_keys_file: "`+keysPath+`"
+admin:
+  api_keys: ["${file:`+secretPath+`}"]
+`), 0600)
+
+	cfg := LoadWithYAML(yamlPath)
+
+	if len(cfg.Auth.APIKeys) != 1 || cfg.Auth.APIKeys[0] != "env-secret" {
+		t.Errorf("Expected only the resolvable env secret, got %v", cfg.Auth.APIKeys)
+	}
+	if !cfg.HasScope("admin-secret", ScopeAdminRead) {
+		t.Error("Expected file secret to be used as admin key")
+	}
+	for _, key := range []string{"env-secret", "file-key-1", "file-key-2"} {
+		if !cfg.IsAPIKeyValid(key) {
+			t.Errorf("Expected %s to be valid", key)
+		}
+	}
+	if cfg.IsAPIKeyValid("${env:GRIBE_TEST_MISSING}") {
+		t.Error("Unresolved references must not be accepted literally")
+	}
+
+	// Rewriting the keys file replaces the file keys on reload
+	later := time.Now().Add(time.Second)
+	os.WriteFile(keysPath, []byte("file-key-3\n"), 0600)
+	os.Chtimes(keysPath, later, later)
+	if changed, err := cfg.apiKeysFile.Reload(); err != nil || !changed {
+		t.Fatalf("Expected keys file to reload, changed=%v err=%v", changed, err)
+	}
+	if cfg.IsAPIKeyValid("file-key-1") || !cfg.IsAPIKeyValid("file-key-3") {
+		t.Errorf("Unexpected keys after reload: %v", cfg.APIKeys())
+	}
+
+	RegisterSecretProvider("vault", func(ref string) (string, error) { return "vault-" + ref, nil })
+	defer func() {
+		secretProvidersMu.Lock()
+		delete(secretProviders, "vault")
+		secretProvidersMu.Unlock()
+	}()
+	if secret, err := ResolveSecret("${vault:secret/key}"); err != nil || secret != "vault-secret/key" {
+		t.Errorf("Expected registered provider to resolve, got %q, %v", secret, err)
+	}
+}
+
+func TestEnvInterpolation(t *testing.T) {
+	os.Setenv("GRIBE_TEST_PORT", "7070")
+	os.Setenv("GRIBE_TEST_MODELS", "/srv/models")
+	defer os.Unsetenv("GRIBE_TEST_PORT")
+	defer os.Unsetenv("GRIBE_TEST_MODELS")
+
+	yamlPath := t.TempDir() + "/config.yaml"
+	os.WriteFile(yamlPath, []byte(`
+server:
+  port: "${GRIBE_TEST_PORT}"
+auth:
+  api_keys: ["${GRIBE_TEST_UNSET_KEY:-fallback-key}", "${env:GRIBE_TEST_PORT}"]
+asr:
+  models_dir: "${GRIBE_TEST_MODELS}/asr"
+`), 0600)
+
+	cfg := LoadWithYAML(yamlPath)
+
+	if cfg.Server.Port != "7070" {
+		t.Errorf("Expected port 7070, got %s", cfg.Server.Port)
+	}
+	if cfg.ASR.ModelsDir != "/srv/models/asr" {
+		t.Errorf("Expected models dir /srv/models/asr, got %s", cfg.ASR.ModelsDir)
+	}
+	if len(cfg.Auth.APIKeys) != 2 || cfg.Auth.APIKeys[0] != "fallback-key" || cfg.Auth.APIKeys[1] != "7070" {
+		t.Errorf("Unexpected API keys: %v", cfg.Auth.APIKeys)
+	}
+
+	// A value is expanded as a scalar, never as YAML
+	os.Setenv("GRIBE_TEST_KEY", "key\nserver:\n  port: \"1\"")
+	defer os.Unsetenv("GRIBE_TEST_KEY")
+	os.WriteFile(yamlPath, []byte(`
+server:
+  max_sessions: ${GRIBE_TEST_PORT}
+auth:
+  api_keys:
+    - ${GRIBE_TEST_KEY}
+`), 0600)
+	cfg = LoadWithYAML(yamlPath)
+	if cfg.Server.MaxSessions != 7070 || cfg.Server.Port == "1" {
+		t.Errorf("Expected 7070 max sessions and the default port, got %d and %s", cfg.Server.MaxSessions, cfg.Server.Port)
+	}
+	if len(cfg.Auth.APIKeys) != 1 || cfg.Auth.APIKeys[0] != os.Getenv("GRIBE_TEST_KEY") {
+		t.Errorf("Expected the variable's value as the key, got %q", cfg.Auth.APIKeys)
+	}
+
+	// An unset variable without a default fails the load
+	os.WriteFile(yamlPath, []byte(`auth: {api_keys: ["${GRIBE_TEST_UNSET_KEY}"]}`), 0600)
+	if _, err := LoadFile(yamlPath); err == nil || !strings.Contains(err.Error(), "GRIBE_TEST_UNSET_KEY") {
+		t.Errorf("Expected an unset variable error, got %v", err)
+	}
+	if err := LoadWithYAML(yamlPath).Validate(); err == nil || !strings.Contains(err.Error(), "config file:") {
+		t.Errorf("Expected Validate to report the unloadable file, got %v", err)
+	}
+}
+
+func TestLoadJSONAndTOML(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{
+	"server": {"port": "9191", "allowed_origins": ["https://app.example"]},
+	"audio": {"transcription_timeout": "12s"},
+	"asr": {"models": {"zipformer": {"provider": "sherpa-onnx", "languages": ["id"]}}}
+}`,
+		"config.toml": `
+[server]
+port = "9191"
+allowed_origins = ["https://app.example"]
+
+[audio]
+transcription_timeout = "12s"
+
+[asr.models.zipformer]
+provider = "sherpa-onnx"
+languages = ["id"]
+`,
+	}
+
+	for name, content := range files {
+		path := dir + "/" + name
+		os.WriteFile(path, []byte(content), 0600)
+
+		cfg := LoadWithYAML(path)
+		if cfg.Server.Port != "9191" || len(cfg.Server.AllowedOrigins) != 1 {
+			t.Errorf("%s: unexpected server config %+v", name, cfg.Server)
+		}
+		if cfg.Audio.TranscriptionTimeout != 12*time.Second {
+			t.Errorf("%s: expected 12s timeout, got %v", name, cfg.Audio.TranscriptionTimeout)
+		}
+		if model, ok := cfg.ASR.Models["zipformer"]; !ok || model.Provider != "sherpa-onnx" {
+			t.Errorf("%s: unexpected models %+v", name, cfg.ASR.Models)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	modelsDir := t.TempDir()
+	os.MkdirAll(modelsDir+"/zipformer", 0755)
+	for _, name := range []string{"encoder.onnx", "decoder.onnx", "joiner.onnx", "tokens.txt"} {
+		os.WriteFile(modelsDir+"/zipformer/"+name, nil, 0600)
+	}
+
+	valid := func() *Config {
+		cfg := Load()
+		cfg.Auth.APIKeys = []string{"key-1"}
+		cfg.ASR = ASRConfig{
+			Provider:     "cpu",
+			NumThreads:   4,
+			ModelsDir:    modelsDir,
+			DefaultModel: "zipformer",
+			Models: map[string]ModelConfig{
+				"zipformer": {
+					Provider: "sherpa-onnx", Languages: []string{"id"},
+					Encoder: "encoder.onnx", Decoder: "decoder.onnx", Joiner: "joiner.onnx", Tokens: "tokens.txt",
+				},
+			},
+		}
+		return cfg
+	}
+
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	cfg := valid()
+	cfg.Audio.MaxBufferSize = -1
+	cfg.Audio.TranscriptionTimeout = 0
+	cfg.Admin.APIKeys = []string{"key-1"}
+	cfg.ASR.DefaultModel = "missing"
+	model := cfg.ASR.Models["zipformer"]
+	model.Joiner = "gone.onnx"
+	cfg.ASR.Models["zipformer"] = model
+	cfg.Tenants = map[string]TenantConfig{"acme": {AllowedModels: []string{"whisper"}}}
+	cfg.Auth.JWT.JWKSURL = "https://idp.example/jwks"
+
+	err := cfg.Validate()
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	for _, want := range []string{
+		"audio.max_audio_buffer_size",
+		"audio.transcription_timeout",
+		"admin.api_keys[0]: duplicate key",
+		"auth.jwt: set issuer or audience",
+		"asr.default_model",
+		"asr.models.zipformer.joiner",
+		"tenants.acme: no api_keys",
+		"tenants.acme.allowed_models",
+	} {
+		found := false
+		for _, e := range errs {
+			if strings.HasPrefix(e, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected an error for %s in:\n%v", want, err)
+		}
+	}
+	if len(errs) != 8 {
+		t.Errorf("Expected 8 errors, got %d:\n%v", len(errs), err)
+	}
+
+	cfg = valid()
+	cfg.SIP = SIPConfig{
+		Listen:       "5060",
+		RTPPortMin:   20000,
+		RTPPortMax:   10000,
+		MediaTimeout: time.Second,
+		Model:        "zipformer",
+		Language:     "en",
+		APIKey:       "unknown-key",
+		AllowedPeers: []string{"pbx.example.com"},
+	}
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 5 {
+		t.Fatalf("Expected 5 SIP errors, got:\n%v", err)
+	}
+	for i, want := range []string{"sip.listen", "sip: invalid RTP port range", "sip.language", "sip.api_key", "sip.allowed_peers[0]"} {
+		if !strings.HasPrefix(errs[i], want) {
+			t.Errorf("Expected %s error, got %q", want, errs[i])
+		}
+	}
+
+	cfg = valid()
+	cfg.IDs = IDConfig{Strategy: "random", Node: 1024, Prefixes: map[string]string{"session": "../", "call": "c_"}}
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 4 {
+		t.Fatalf("Expected 4 ID errors, got:\n%v", err)
+	}
+	for i, want := range []string{"ids.strategy", "ids.node", "ids.prefixes: unknown kind \"call\"", "ids.prefixes.session"} {
+		if !strings.HasPrefix(errs[i], want) {
+			t.Errorf("Expected %s error, got %q", want, errs[i])
+		}
+	}
+
+	cfg = valid()
+	cfg.AudioSocket = AudioSocketConfig{
+		Listen:       "9092",
+		Model:        "missing",
+		AllowedPeers: []string{"10.0.0.0/8", "pbx"},
+	}
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 4 {
+		t.Fatalf("Expected 4 AudioSocket errors, got:\n%v", err)
+	}
+	for i, want := range []string{"audiosocket.listen", "audiosocket.media_timeout", "audiosocket.model", "audiosocket.allowed_peers[1]"} {
+		if !strings.HasPrefix(errs[i], want) {
+			t.Errorf("Expected %s error, got %q", want, errs[i])
+		}
+	}
+
+	cfg = valid()
+	cfg.MQTT = MQTTConfig{
+		Broker:     "http://broker:1883",
+		DeltaTopic: "gribe/+/delta",
+		QoS:        3,
+	}
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 4 {
+		t.Fatalf("Expected 4 MQTT errors, got:\n%v", err)
+	}
+	for i, want := range []string{"mqtt.broker", "mqtt.transcript_topic", "mqtt.delta_topic", "mqtt.qos"} {
+		if !strings.HasPrefix(errs[i], want) {
+			t.Errorf("Expected %s error, got %q", want, errs[i])
+		}
+	}
+
+	cfg = valid()
+	cfg.WebRTC = WebRTCConfig{
+		Enabled:    true,
+		ICEServers: []string{"stun:stun.example.com:3478", "https://turn.example.com"},
+		PublicIPs:  []string{"203.0.113.7", "gribe.example.com"},
+		UDPPortMin: 20000,
+	}
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 3 {
+		t.Fatalf("Expected 3 WebRTC errors, got:\n%v", err)
+	}
+	for i, want := range []string{"webrtc.ice_servers[1]", "webrtc.public_ips[1]", "webrtc: invalid UDP port range"} {
+		if !strings.HasPrefix(errs[i], want) {
+			t.Errorf("Expected %s error, got %q", want, errs[i])
+		}
+	}
+
+	cfg = valid()
+	cfg.Watch = WatchConfig{
+		Dir:     filepath.Join(t.TempDir(), "missing"),
+		Formats: []string{"txt", "docx"},
+		Model:   "missing",
+	}
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 4 {
+		t.Fatalf("Expected 4 watch errors, got:\n%v", err)
+	}
+	for i, want := range []string{"watch.dir", "watch.formats[1]", "watch.poll_interval", "watch.model"} {
+		if !strings.HasPrefix(errs[i], want) {
+			t.Errorf("Expected %s error, got %q", want, errs[i])
+		}
+	}
+
+	cfg = valid()
+	cfg.Batch.MaxBytes = 0
+	cfg.Batch.Workers = 0
+	cfg.Batch.AllowedHosts = []string{"audio.example.com", "*.cdn.example.net", "s3://recordings", "https://evil.com"}
+	cfg.Batch.S3 = ObjectStoreConfig{AccessKeyID: "AKID"}
+	cfg.Batch.GCS = ObjectStoreConfig{Endpoint: "storage.example.com"}
+	cfg.Batch.CallbackHosts = []string{"hooks.example.com", "https://hooks.example.com/done"}
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 7 {
+		t.Fatalf("Expected 7 batch errors, got:\n%v", err)
+	}
+	for i, want := range []string{"batch.max_bytes", "batch.workers", "batch.allowed_hosts[3]", "batch.s3:", "batch.gcs.endpoint",
+		"batch.callback_hosts[1]", "batch.callback_secret"} {
+		if !strings.HasPrefix(errs[i], want) {
+			t.Errorf("Expected %s error, got %q", want, errs[i])
+		}
+	}
+
+	cfg = valid()
+	cfg.Usage = UsageConfig{Sink: "webhook", URL: "s3://billing/usage", Attempts: 5, Backoff: time.Second}
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 3 {
+		t.Fatalf("Expected 3 usage errors, got:\n%v", err)
+	}
+	for i, want := range []string{"usage.url", "usage.secret", "usage.queue_size"} {
+		if !strings.HasPrefix(errs[i], want) {
+			t.Errorf("Expected %s error, got %q", want, errs[i])
+		}
+	}
+
+	cfg = valid()
+	cfg.Audio.SpillThreshold = cfg.Audio.MaxBufferSize
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.HasPrefix(errs[0], "audio.spill_threshold") {
+		t.Errorf("Expected an audio.spill_threshold error, got:\n%v", err)
+	}
+
+	cfg = valid()
+	cfg.Rate.ConnectionLimitPolicy = "evict_oldest"
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.HasPrefix(errs[0], "rate.connection_limit_policy") {
+		t.Errorf("Expected a rate.connection_limit_policy error, got:\n%v", err)
+	}
+
+	cfg = valid()
+	cfg.Admin.Listen = ":" + cfg.Server.Port
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.HasPrefix(errs[0], "admin.listen") {
+		t.Errorf("Expected an admin.listen error, got:\n%v", err)
+	}
+	cfg.Admin.Listen = "unix:/run/gribe/admin.sock"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected a unix admin socket to be valid, got:\n%v", err)
+	}
+
+	cfg = valid()
+	cfg.Rate.BanAfterAuthFailures = 5
+	cfg.Rate.BanWindow = time.Minute
+	cfg.Rate.BanDuration = time.Hour
+	cfg.Rate.MaxBanDuration = time.Minute
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.HasPrefix(errs[0], "rate.max_ban_duration") {
+		t.Errorf("Expected a rate.max_ban_duration error, got:\n%v", err)
+	}
+
+	cfg = valid()
+	os.WriteFile(modelsDir+"/zipformer/tokens.txt", []byte("a\nb\n"), 0600)
+	sum := sha256.Sum256([]byte("a\nb\n"))
+	model = cfg.ASR.Models["zipformer"]
+	model.Checksums = map[string]string{"tokens.txt": hex.EncodeToString(sum[:])}
+	cfg.ASR.Models["zipformer"] = model
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected matching checksums to pass, got %v", err)
+	}
+	model.Checksums = map[string]string{"encoder.onnx": hex.EncodeToString(sum[:]), "joiner.onnx": "abc"}
+	cfg.ASR.Models["zipformer"] = model
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.HasPrefix(errs[0], "asr.models.zipformer.checksums.joiner.onnx") {
+		t.Errorf("Expected a malformed checksum error, got:\n%v", err)
+	}
+	delete(model.Checksums, "joiner.onnx")
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.Contains(errs[0], "encoder.onnx: sha256 is") {
+		t.Errorf("Expected a checksum mismatch error, got:\n%v", err)
+	}
+
+	cfg = valid()
+	model = cfg.ASR.Models["zipformer"]
+	model.URL = "ftp://models.example.com/zipformer"
+	cfg.ASR.Models["zipformer"] = model
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.HasPrefix(errs[0], "asr.models.zipformer.url") {
+		t.Errorf("Expected an asr.models.zipformer.url error, got:\n%v", err)
+	}
+
+	cfg = valid()
+	os.WriteFile(modelsDir+"/zipformer/encoder.int8.onnx", nil, 0600)
+	model = cfg.ASR.Models["zipformer"]
+	model.Precision = PrecisionInt8
+	model.Int8 = &QuantizedFiles{Encoder: "encoder.int8.onnx", Decoder: "decoder.int8.onnx"}
+	cfg.ASR.Models["zipformer"] = model
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.HasPrefix(errs[0], "asr.models.zipformer.int8.decoder") {
+		t.Errorf("Expected the missing int8 decoder to be reported, got:\n%v", err)
+	}
+	if encoder, _, joiner := model.TransducerFiles(); encoder != "encoder.int8.onnx" || joiner != "joiner.onnx" {
+		t.Errorf("Expected the int8 encoder and full-precision joiner, got %s and %s", encoder, joiner)
+	}
+	model.Precision = "fp16"
+	cfg.ASR.Models["zipformer"] = model
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.HasPrefix(errs[0], "asr.models.zipformer.precision") {
+		t.Errorf("Expected an asr.models.zipformer.precision error, got:\n%v", err)
+	}
+
+	cfg = valid()
+	os.Symlink(modelsDir+"/zipformer", modelsDir+"/zipformer-cuda")
+	cfg.ASR.GPUMemoryMB = 1000
+	model = cfg.ASR.Models["zipformer"]
+	model.ExecutionProvider = ExecutionCUDA
+	cfg.ASR.Models["zipformer-cuda"] = model
+	model.DeviceID = 1
+	model.VRAMMB = 1200
+	cfg.ASR.Models["zipformer"] = model
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 2 ||
+		!strings.HasPrefix(errs[0], "asr.models.zipformer.vram_mb") ||
+		!strings.HasPrefix(errs[1], "asr.models.zipformer-cuda.device_id") {
+		t.Errorf("Expected vram_mb and device_id errors, got:\n%v", err)
+	}
+	model.ExecutionProvider = "gpu"
+	model.VRAMMB = 0
+	cfg.ASR.Models["zipformer"] = model
+	delete(cfg.ASR.Models, "zipformer-cuda")
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.HasPrefix(errs[0], "asr.models.zipformer.execution_provider") {
+		t.Errorf("Expected an asr.models.zipformer.execution_provider error, got:\n%v", err)
+	}
+
+	cfg = valid()
+	cfg.ASR.CPUBudget = 2
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 1 || !strings.HasPrefix(errs[0], "asr.models.zipformer.num_threads") {
+		t.Errorf("Expected the default num_threads beyond the CPU budget to be reported, got:\n%v", err)
+	}
+	model = cfg.ASR.Models["zipformer"]
+	model.NumThreads = 2
+	cfg.ASR.Models["zipformer"] = model
+	if err = cfg.Validate(); err != nil {
+		t.Errorf("Expected a model within the CPU budget to be valid, got %v", err)
+	}
+
+	cfg = valid()
+	cfg.ASR.Speaker = SpeakerConfig{Model: "missing-speaker.onnx", NumThreads: 1, Threshold: 1.5}
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 2 ||
+		!strings.HasPrefix(errs[0], "asr.speaker.model") || !strings.HasPrefix(errs[1], "asr.speaker.threshold") {
+		t.Errorf("Expected asr.speaker.model and threshold errors, got:\n%v", err)
+	}
+	cfg.ASR.Speaker.Model = "zipformer/encoder.onnx"
+	cfg.ASR.Speaker.Threshold = 0.6
+	if err = cfg.Validate(); err != nil {
+		t.Errorf("Expected a valid speaker model, got %v", err)
+	}
+
+	cfg = valid()
+	cfg.ASR.LanguageRoutes = map[string]string{"en": "zipformer", "id": "whisper"}
+	err = cfg.Validate()
+	if errs, _ = err.(ValidationErrors); len(errs) != 2 {
+		t.Fatalf("Expected 2 language route errors, got:\n%v", err)
+	}
+	for i, want := range []string{"asr.language_routes.en: \"en\" is not supported", "asr.language_routes.id: \"whisper\" is not defined"} {
+		if !strings.HasPrefix(errs[i], want) {
+			t.Errorf("Expected %s error, got %q", want, errs[i])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VerifyChecksums checks the model's files under modelDir against their
+// configured sha256 checksums, so a file truncated or corrupted by a bad
+// deploy fails with a clear error before a provider tries to load it
+func (m *ModelConfig) VerifyChecksums(modelDir string) error {
+	for _, file := range sortedKeys(m.Checksums) {
+		want := strings.ToLower(m.Checksums[file])
+		got, size, err := fileSHA256(filepath.Join(modelDir, file))
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if got != want {
+			return fmt.Errorf("%s: sha256 is %s, expected %s; the file (%d bytes) is truncated or corrupted",
+				file, got, want, size)
+		}
+	}
+	return nil
+}
+
+// Model precisions
+const (
+	PrecisionFP32 = "fp32"
+	PrecisionInt8 = "int8"
+)
+
+// Execution providers
+const (
+	ExecutionCPU  = "cpu"
+	ExecutionCUDA = "cuda"
+)
+
+// ExecutionProvider returns the execution provider model runs on
+func (c *ASRConfig) ExecutionProvider(model *ModelConfig) string {
+	if model.ExecutionProvider != "" {
+		return model.ExecutionProvider
+	}
+	return c.Provider
+}
+
+// Threads returns the inference threads of model
+func (c *ASRConfig) Threads(model *ModelConfig) int {
+	if model.NumThreads > 0 {
+		return model.NumThreads
+	}
+	return c.NumThreads
+}
+
+// TransducerFiles returns the encoder, decoder and joiner files of a
+// sherpa-onnx model at its precision
+func (m *ModelConfig) TransducerFiles() (encoder, decoder, joiner string) {
+	encoder, decoder, joiner = m.Encoder, m.Decoder, m.Joiner
+	if m.Precision == PrecisionInt8 && m.Int8 != nil {
+		encoder, decoder = m.Int8.Encoder, m.Int8.Decoder
+		if m.Int8.Joiner != "" {
+			joiner = m.Int8.Joiner
+		}
+	}
+	return encoder, decoder, joiner
+}
+
+// Files returns the paths of the model's files relative to its directory,
+// "." for a single-file model
+func (m *ModelConfig) Files() []string {
+	var files []string
+	switch m.Provider {
+	case "sherpa-onnx":
+		encoder, decoder, joiner := m.TransducerFiles()
+		files = []string{encoder, decoder, joiner, m.Tokens}
+	case "whisper-cpp":
+		files = []string{"."}
+	}
+	for _, file := range sortedKeys(m.Checksums) {
+		files = append(files, file)
+	}
+
+	// Unset names are reported by validation
+	var unique []string
+	for _, file := range files {
+		if file != "" && !containsString(unique, file) {
+			unique = append(unique, file)
+		}
+	}
+	return unique
+}
+
+// fileSHA256 returns the hex sha256 and the size of a file
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// validChecksum reports whether sum is a hex sha256
+func validChecksum(sum string) bool {
+	if len(sum) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(sum)
+	return err == nil
+}
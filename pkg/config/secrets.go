@@ -0,0 +1,254 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider resolves the reference part of a ${scheme:reference} value
+type SecretProvider func(ref string) (string, error)
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{
+		"env":  envSecret,
+		"file": fileSecret,
+	}
+)
+
+// RegisterSecretProvider makes ${scheme:reference} values resolvable through
+// provider, e.g. a secret manager client registered as "vault". The env and
+// file schemes are built in.
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[scheme] = provider
+}
+
+// ResolveSecret expands a value of the form ${scheme:reference}. Other values
+// are returned unchanged.
+func ResolveSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, "${") || !strings.HasSuffix(value, "}") {
+		return value, nil
+	}
+	inner := value[2 : len(value)-1]
+	scheme, ref, ok := strings.Cut(inner, ":")
+	if !ok {
+		// Plain ${NAME} placeholders are left for env interpolation
+		return value, nil
+	}
+
+	secretProvidersMu.RLock()
+	provider, exists := secretProviders[scheme]
+	secretProvidersMu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("unknown secret provider %q", scheme)
+	}
+
+	secret, err := provider(ref)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s secret: %w", scheme, err)
+	}
+	if secret == "" {
+		return "", fmt.Errorf("%s secret %q is empty", scheme, ref)
+	}
+	return secret, nil
+}
+
+func envSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+func fileSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// resolveSecrets resolves every value, dropping (and logging) those that fail
+// so an unresolved reference is never accepted literally as a key
+func resolveSecrets(field string, values []string) []string {
+	resolved := make([]string, 0, len(values))
+	for _, value := range values {
+		secret, err := ResolveSecret(value)
+		if err != nil {
+			log.Printf("[WARN] Ignoring %s entry: %v", field, err)
+			continue
+		}
+		resolved = append(resolved, secret)
+	}
+	return resolved
+}
+
+// resolveConfigSecrets resolves secret references in all key lists
+func (c *Config) resolveConfigSecrets() {
+	c.Auth.APIKeys = resolveSecrets("auth.api_keys", c.Auth.APIKeys)
+	c.Admin.APIKeys = resolveSecrets("admin.api_keys", c.Admin.APIKeys)
+
+	keys := c.Auth.Keys[:0]
+	for _, key := range c.Auth.Keys {
+		secret, err := ResolveSecret(key.Key)
+		if err != nil {
+			log.Printf("[WARN] Ignoring auth.keys entry: %v", err)
+			continue
+		}
+		key.Key = secret
+		keys = append(keys, key)
+	}
+	c.Auth.Keys = keys
+
+	for id, tenant := range c.Tenants {
+		tenant.APIKeys = resolveSecrets("tenants."+id+".api_keys", tenant.APIKeys)
+		c.Tenants[id] = tenant
+	}
+
+	c.SIP.APIKey = resolveSecret("sip.api_key", c.SIP.APIKey)
+	c.AudioSocket.APIKey = resolveSecret("audiosocket.api_key", c.AudioSocket.APIKey)
+	c.MQTT.Password = resolveSecret("mqtt.password", c.MQTT.Password)
+	c.Watch.APIKey = resolveSecret("watch.api_key", c.Watch.APIKey)
+	c.Batch.S3.SecretAccessKey = resolveSecret("batch.s3.secret_access_key", c.Batch.S3.SecretAccessKey)
+	c.Batch.GCS.SecretAccessKey = resolveSecret("batch.gcs.secret_access_key", c.Batch.GCS.SecretAccessKey)
+	c.Batch.CallbackSecret = resolveSecret("batch.callback_secret", c.Batch.CallbackSecret)
+	c.Usage.S3.SecretAccessKey = resolveSecret("usage.s3.secret_access_key", c.Usage.S3.SecretAccessKey)
+	c.Usage.Secret = resolveSecret("usage.secret", c.Usage.Secret)
+}
+
+// resolveSecret resolves a single optional key, dropping it with a warning on failure
+func resolveSecret(field, value string) string {
+	if value == "" {
+		return ""
+	}
+	secret, err := ResolveSecret(value)
+	if err != nil {
+		log.Printf("[WARN] Ignoring %s: %v", field, err)
+	}
+	return secret
+}
+
+// KeyFile holds API keys read from a file, one key per line. Blank lines and
+// lines starting with # are ignored.
+type KeyFile struct {
+	path    string
+	keys    []string
+	modTime time.Time
+	mu      sync.RWMutex
+}
+
+// NewKeyFile creates a key file and loads it
+func NewKeyFile(path string) (*KeyFile, error) {
+	f := &KeyFile{path: path}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Keys returns the currently loaded keys
+func (f *KeyFile) Keys() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.keys
+}
+
+// Reload rereads the file if it changed since the last load, reporting whether it did
+func (f *KeyFile) Reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.RLock()
+	unchanged := info.ModTime().Equal(f.modTime)
+	f.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, err
+	}
+
+	var keys []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+
+	f.mu.Lock()
+	f.keys = keys
+	f.modTime = info.ModTime()
+	f.mu.Unlock()
+	return true, nil
+}
+
+// Watch polls the file every interval and reloads it when it changes, until stop is closed
+func (f *KeyFile) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := f.Reload()
+			if err != nil {
+				log.Printf("[WARN] Could not reload API keys from %s, keeping %d cached key(s): %v",
+					f.path, len(f.Keys()), err)
+			} else if changed {
+				log.Printf("[INFO] Reloaded %d API key(s) from %s", len(f.Keys()), f.path)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// loadAPIKeysFile loads auth.api_keys_file if configured
+func (c *Config) loadAPIKeysFile() {
+	c.apiKeysFile = nil
+	if c.Auth.APIKeysFile == "" {
+		return
+	}
+	keyFile, err := NewKeyFile(c.Auth.APIKeysFile)
+	if err != nil {
+		log.Printf("[WARN] Could not load API keys file %s: %v", c.Auth.APIKeysFile, err)
+		return
+	}
+	c.apiKeysFile = keyFile
+}
+
+// WatchAPIKeysFile reloads auth.api_keys_file whenever it changes until stop
+// is closed. It returns immediately when no keys file is configured.
+func (c *Config) WatchAPIKeysFile(interval time.Duration, stop <-chan struct{}) {
+	if c.apiKeysFile == nil {
+		return
+	}
+	go c.apiKeysFile.Watch(interval, stop)
+}
+
+// APIKeys returns auth.api_keys plus the keys currently in auth.api_keys_file
+func (c *Config) APIKeys() []string {
+	if c.apiKeysFile == nil {
+		return c.Auth.APIKeys
+	}
+	fileKeys := c.apiKeysFile.Keys()
+	keys := make([]string, 0, len(c.Auth.APIKeys)+len(fileKeys))
+	keys = append(keys, c.Auth.APIKeys...)
+	return append(keys, fileKeys...)
+}
@@ -0,0 +1,724 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// knownModelProviders are the provider types the ASR registry can create
+var knownModelProviders = []string{"sherpa-onnx", "whisper-cpp"}
+
+// knownMQTTSchemes are the broker URL schemes the MQTT client can dial
+var knownMQTTSchemes = []string{"tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss"}
+
+// knownProtocols are the server event dialects besides the default
+var knownProtocols = []string{"ga", "2024-10"}
+
+// knownWatchFormats are the transcript files the directory watcher can write
+var knownWatchFormats = []string{"txt", "json", "srt"}
+
+// knownScopes are the scopes accepted in auth.keys
+var knownScopes = []string{ScopeRealtimeTranscribe, ScopeAdminRead, ScopeAdminWrite, ScopeAll}
+
+// knownIDStrategies are the ways ids.strategy can generate IDs
+var knownIDStrategies = []string{"short-uuid", "uuid", "ulid", "snowflake", "sequential"}
+
+// knownIDKinds are the kinds of objects ids.prefixes can name
+var knownIDKinds = []string{"session", "conversation", "item", "response", "event", "speaker", "deletion"}
+
+// ValidationErrors lists every problem found in a configuration
+type ValidationErrors []string
+
+func (e ValidationErrors) Error() string {
+	return fmt.Sprintf("%d configuration error(s):\n  - %s", len(e), strings.Join(e, "\n  - "))
+}
+
+func (e *ValidationErrors) add(format string, args ...interface{}) {
+	*e = append(*e, fmt.Sprintf(format, args...))
+}
+
+// Validate checks ranges, model files, duplicate keys and conflicting settings,
+// returning all problems at once as ValidationErrors, or nil if there are none
+func (c *Config) Validate() error {
+	var errs ValidationErrors
+
+	if c.fileErr != nil {
+		// The file's settings are missing, not just wrong
+		errs.add("config file: %v", c.fileErr)
+	}
+	c.validateServer(&errs)
+	c.validateLimits(&errs)
+	c.validateAuth(&errs)
+	c.validateASR(&errs)
+	c.validateTenants(&errs)
+	c.validateSIP(&errs)
+	c.validateAudioSocket(&errs)
+	c.validateMQTT(&errs)
+	c.validateWebRTC(&errs)
+	c.validateWatch(&errs)
+	c.validateBatch(&errs)
+	c.validateUsage(&errs)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (c *Config) validateServer(errs *ValidationErrors) {
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 0 || port > 65535 {
+		errs.add("server.port: %q is not a valid port", c.Server.Port)
+	}
+	if c.Server.MaxSessions < 0 {
+		errs.add("server.max_sessions: must not be negative, got %d", c.Server.MaxSessions)
+	}
+	if c.Server.MemoryBudget < 0 {
+		errs.add("server.memory_budget: must not be negative, got %d", c.Server.MemoryBudget)
+	}
+	if c.Server.ResumeWindow < 0 {
+		errs.add("server.resume_window: must not be negative, got %v", c.Server.ResumeWindow)
+	}
+	if c.Server.EventDedupWindow < 0 {
+		errs.add("server.event_dedup_window: must not be negative, got %v", c.Server.EventDedupWindow)
+	}
+	if c.Server.EventBatchInterval < 0 {
+		errs.add("server.event_batch_interval: must not be negative, got %v", c.Server.EventBatchInterval)
+	}
+	if c.Server.Protocol != "" && !containsString(knownProtocols, c.Server.Protocol) {
+		errs.add("server.protocol: unsupported protocol %q, must be empty or one of %v", c.Server.Protocol, knownProtocols)
+	}
+	if c.Admin.Listen != "" {
+		network, address := c.Admin.ListenAddress()
+		if network == "unix" {
+			if address == "" {
+				errs.add("admin.listen: unix socket path is empty")
+			}
+		} else if _, port, err := net.SplitHostPort(address); err != nil {
+			errs.add("admin.listen: %v", err)
+		} else if port == c.Server.Port {
+			errs.add("admin.listen: port %s is the public server.port", port)
+		}
+	}
+}
+
+func (c *Config) validateLimits(errs *ValidationErrors) {
+	if c.Audio.MaxBufferSize <= 0 {
+		errs.add("audio.max_audio_buffer_size: must be positive, got %d", c.Audio.MaxBufferSize)
+	}
+	if c.Audio.TranscriptionTimeout <= 0 {
+		errs.add("audio.transcription_timeout: must be positive, got %v", c.Audio.TranscriptionTimeout)
+	}
+
+	if c.Audio.MinDeltaInterval < 0 {
+		errs.add("audio.min_delta_interval: must not be negative, got %v", c.Audio.MinDeltaInterval)
+	}
+	if c.Cache.TTL < 0 {
+		errs.add("cache.ttl: must not be negative, got %v", c.Cache.TTL)
+	}
+	if c.Audio.SpillThreshold > 0 && c.Audio.SpillThreshold >= c.Audio.MaxBufferSize {
+		errs.add("audio.spill_threshold: must be below max_audio_buffer_size (%d), got %d",
+			c.Audio.MaxBufferSize, c.Audio.SpillThreshold)
+	}
+	if c.Audio.TranscriptionRetries > 0 && c.Audio.RetryBackoff <= 0 {
+		errs.add("audio.retry_backoff: must be positive when retries are enabled, got %v", c.Audio.RetryBackoff)
+	}
+
+	positive := map[string]int{
+		"rate.max_connections_per_ip": c.Rate.MaxConnectionsPerIP,
+		"rate.requests_per_second":    c.Rate.RequestsPerSecond,
+		"rate.burst_size":             c.Rate.BurstSize,
+	}
+	nonNegative := map[string]int{
+		"audio.min_delta_chars":          c.Audio.MinDeltaChars,
+		"audio.stability_window":         c.Audio.StabilityWindow,
+		"audio.transcription_retries":    c.Audio.TranscriptionRetries,
+		"audio.spill_threshold":          c.Audio.SpillThreshold,
+		"audio.max_transcriptions":       c.Audio.MaxTranscriptions,
+		"rate.max_events_per_second":     c.Rate.MaxEventsPerSecond,
+		"rate.max_appends_per_second":    c.Rate.MaxAppendsPerSecond,
+		"rate.max_bytes_per_second":      c.Rate.MaxBytesPerSecond,
+		"rate.max_violations":            c.Rate.MaxViolations,
+		"rate.ban_after_auth_failures":   c.Rate.BanAfterAuthFailures,
+		"rate.ban_after_rate_violations": c.Rate.BanAfterRateViolations,
+		"cache.max_entries":              c.Cache.MaxEntries,
+		"conversations.max_entries":      c.Conversations.MaxEntries,
+		"retention.days":                 c.Retention.Days,
+		"quota.daily_audio_seconds":      c.Quota.DailyAudioSeconds,
+		"quota.monthly_audio_seconds":    c.Quota.MonthlyAudioSeconds,
+	}
+	for _, field := range sortedKeys(positive) {
+		if positive[field] <= 0 {
+			errs.add("%s: must be positive, got %d", field, positive[field])
+		}
+	}
+	for _, field := range sortedKeys(nonNegative) {
+		if nonNegative[field] < 0 {
+			errs.add("%s: must not be negative, got %d", field, nonNegative[field])
+		}
+	}
+	if c.Server.BasePath != "" && (!strings.HasPrefix(c.Server.BasePath, "/") || strings.HasSuffix(c.Server.BasePath, "/")) {
+		errs.add("server.base_path: must start and not end with a slash, e.g. \"/stt\", got %q", c.Server.BasePath)
+	}
+	if c.Runtime.FitCgroup && (c.Runtime.MemoryLimitRatio <= 0 || c.Runtime.MemoryLimitRatio > 1) {
+		errs.add("runtime.memory_limit_ratio: must be in (0, 1], got %v", c.Runtime.MemoryLimitRatio)
+	}
+	if c.IDs.Strategy != "" && !containsString(knownIDStrategies, c.IDs.Strategy) {
+		errs.add("ids.strategy: unknown strategy %q, must be one of %v", c.IDs.Strategy, knownIDStrategies)
+	}
+	if c.IDs.Node < 0 || c.IDs.Node > 1023 {
+		errs.add("ids.node: must be 0-1023, got %d", c.IDs.Node)
+	}
+	for _, kind := range sortedKeys(c.IDs.Prefixes) {
+		if !containsString(knownIDKinds, kind) {
+			errs.add("ids.prefixes: unknown kind %q, must be one of %v", kind, knownIDKinds)
+		}
+		// IDs name files, such as stored conversations
+		if prefix := c.IDs.Prefixes[kind]; strings.Trim(prefix, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-") != "" {
+			errs.add("ids.prefixes.%s: may only hold letters, digits, _ and -, got %q", kind, prefix)
+		}
+	}
+	if c.Server.CORS.MaxAge < 0 {
+		errs.add("server.cors.max_age: must not be negative, got %v", c.Server.CORS.MaxAge)
+	}
+	if c.Retention.Interval <= 0 {
+		errs.add("retention.interval: must be positive, got %v", c.Retention.Interval)
+	}
+	if c.Rate.CleanupInterval <= 0 {
+		errs.add("rate.cleanup_interval: must be positive, got %v", c.Rate.CleanupInterval)
+	}
+	switch c.Rate.ConnectionLimitPolicy {
+	case "", ConnectionLimitReject, ConnectionLimitEvictIdle:
+	default:
+		errs.add("rate.connection_limit_policy: unsupported policy %q, must be %q or %q",
+			c.Rate.ConnectionLimitPolicy, ConnectionLimitReject, ConnectionLimitEvictIdle)
+	}
+	if c.Rate.EvictIdleAfter < 0 {
+		errs.add("rate.evict_idle_after: must not be negative, got %v", c.Rate.EvictIdleAfter)
+	}
+	if c.Rate.BanAfterAuthFailures > 0 || c.Rate.BanAfterRateViolations > 0 {
+		if c.Rate.BanWindow <= 0 {
+			errs.add("rate.ban_window: must be positive when bans are enabled, got %v", c.Rate.BanWindow)
+		}
+		if c.Rate.BanDuration <= 0 {
+			errs.add("rate.ban_duration: must be positive when bans are enabled, got %v", c.Rate.BanDuration)
+		}
+		if c.Rate.MaxBanDuration < c.Rate.BanDuration {
+			errs.add("rate.max_ban_duration: must be at least ban_duration (%v), got %v", c.Rate.BanDuration, c.Rate.MaxBanDuration)
+		}
+	}
+	if c.Quota.DailyAudioSeconds > 0 && c.Quota.MonthlyAudioSeconds > 0 &&
+		c.Quota.DailyAudioSeconds > c.Quota.MonthlyAudioSeconds {
+		errs.add("quota: daily_audio_seconds (%d) exceeds monthly_audio_seconds (%d)",
+			c.Quota.DailyAudioSeconds, c.Quota.MonthlyAudioSeconds)
+	}
+}
+
+func (c *Config) validateAuth(errs *ValidationErrors) {
+	// A key must identify exactly one caller
+	seen := make(map[string]string)
+	check := func(field, key string) {
+		if key == "" {
+			errs.add("%s: empty key", field)
+			return
+		}
+		if previous, exists := seen[key]; exists {
+			errs.add("%s: duplicate key (also in %s)", field, previous)
+			return
+		}
+		seen[key] = field
+	}
+
+	for i, key := range c.Auth.APIKeys {
+		check(fmt.Sprintf("auth.api_keys[%d]", i), key)
+	}
+	for i, key := range c.Auth.Keys {
+		field := fmt.Sprintf("auth.keys[%d]", i)
+		check(field, key.Key)
+		if len(key.Scopes) == 0 {
+			errs.add("%s: no scopes granted", field)
+		}
+		for _, scope := range key.Scopes {
+			if !containsString(knownScopes, scope) {
+				errs.add("%s: unknown scope %q", field, scope)
+			}
+		}
+		if key.RetentionDays < 0 {
+			errs.add("%s.retention_days: must not be negative, got %d", field, key.RetentionDays)
+		}
+	}
+	for i, key := range c.Admin.APIKeys {
+		check(fmt.Sprintf("admin.api_keys[%d]", i), key)
+	}
+	for _, id := range sortedKeys(c.Tenants) {
+		for i, key := range c.Tenants[id].APIKeys {
+			check(fmt.Sprintf("tenants.%s.api_keys[%d]", id, i), key)
+		}
+	}
+
+	if c.Auth.APIKeysFile != "" {
+		if _, err := os.Stat(c.Auth.APIKeysFile); err != nil {
+			errs.add("auth.api_keys_file: %v", err)
+		}
+	}
+	if c.Auth.JWT.TenantClaim != "" && !c.Auth.JWT.Enabled() {
+		errs.add("auth.jwt.tenant_claim: set without auth.jwt.jwks_url")
+	}
+	if c.Auth.JWT.Enabled() && c.Auth.JWT.Issuer == "" && c.Auth.JWT.Audience == "" {
+		// Otherwise any token the identity provider signs, for any application, is accepted
+		errs.add("auth.jwt: set issuer or audience, or both")
+	}
+}
+
+func (c *Config) validateASR(errs *ValidationErrors) {
+	if c.ASR.NumThreads <= 0 {
+		errs.add("asr.num_threads: must be positive, got %d", c.ASR.NumThreads)
+	}
+	if c.ASR.DefaultModel != "" {
+		if _, exists := c.ASR.Models[c.ASR.DefaultModel]; !exists {
+			errs.add("asr.default_model: %q is not defined in asr.models", c.ASR.DefaultModel)
+		}
+	}
+
+	if c.Audio.DefaultModel != "" {
+		if model, exists := c.ASR.Models[c.Audio.DefaultModel]; !exists {
+			errs.add("audio.default_model: %q is not defined in asr.models", c.Audio.DefaultModel)
+		} else if c.Audio.DefaultLanguage != "" && !containsString(model.Languages, c.Audio.DefaultLanguage) {
+			errs.add("audio.default_language: %q is not supported by model %s", c.Audio.DefaultLanguage, c.Audio.DefaultModel)
+		}
+	} else if c.Audio.DefaultLanguage != "" {
+		errs.add("audio.default_language: set without a default model")
+	}
+
+	for _, language := range sortedKeys(c.ASR.LanguageRoutes) {
+		name := c.ASR.LanguageRoutes[language]
+		if model, exists := c.ASR.Models[name]; !exists {
+			errs.add("asr.language_routes.%s: %q is not defined in asr.models", language, name)
+		} else if !containsString(model.Languages, language) {
+			errs.add("asr.language_routes.%s: %q is not supported by model %s", language, language, name)
+		}
+	}
+
+	if c.ASR.GPUMemoryMB < 0 {
+		errs.add("asr.gpu_memory_mb: must not be negative, got %d", c.ASR.GPUMemoryMB)
+	}
+	if c.ASR.CPUBudget < 0 {
+		errs.add("asr.cpu_budget: must not be negative, got %d", c.ASR.CPUBudget)
+	}
+
+	if speaker := c.ASR.Speaker; speaker.Enabled() {
+		if _, err := os.Stat(filepath.Join(c.ASR.ModelsDir, speaker.Model)); err != nil {
+			errs.add("asr.speaker.model: %v", err)
+		}
+		if speaker.NumThreads <= 0 {
+			errs.add("asr.speaker.num_threads: must be positive, got %d", speaker.NumThreads)
+		}
+		if speaker.Threshold <= 0 || speaker.Threshold > 1 {
+			errs.add("asr.speaker.threshold: must be above 0 and at most 1, got %v", speaker.Threshold)
+		}
+	}
+
+	if selfTest := c.ASR.SelfTest; selfTest.Enabled {
+		if selfTest.Sample != "" {
+			if _, err := os.Stat(selfTest.Sample); err != nil {
+				errs.add("asr.self_test.sample: %v", err)
+			}
+		}
+		if selfTest.Timeout <= 0 {
+			errs.add("asr.self_test.timeout: must be positive, got %v", selfTest.Timeout)
+		}
+	}
+
+	if shadow := c.ASR.Shadow; shadow.Enabled() {
+		if _, ok := c.ASR.Models[shadow.Model]; !ok {
+			errs.add("asr.shadow.model: %q is not defined in asr.models", shadow.Model)
+		}
+		if shadow.Percent <= 0 || shadow.Percent > 100 {
+			errs.add("asr.shadow.percent: must be above 0 and at most 100, got %v", shadow.Percent)
+		}
+		if shadow.MaxConcurrent <= 0 {
+			errs.add("asr.shadow.max_concurrent: must be positive, got %d", shadow.MaxConcurrent)
+		}
+	}
+
+	var cudaModel string // First model on cuda, whose device the others must share
+	for _, name := range sortedKeys(c.ASR.Models) {
+		model := c.ASR.Models[name]
+		field := "asr.models." + name
+		if !containsString(knownModelProviders, model.Provider) {
+			errs.add("%s.provider: unknown provider %q (expected one of %s)",
+				field, model.Provider, strings.Join(knownModelProviders, ", "))
+		}
+		if len(model.Languages) == 0 {
+			errs.add("%s.languages: at least one language is required", field)
+		}
+		if d := model.Defaults; d != nil {
+			if d.TurnDetection.Threshold < 0 || d.TurnDetection.Threshold > 1 {
+				errs.add("%s.defaults.turn_detection.threshold: must be between 0 and 1, got %v",
+					field, d.TurnDetection.Threshold)
+			}
+			if d.TurnDetection.PrefixPaddingMs < 0 || d.TurnDetection.SilenceDurationMs < 0 {
+				errs.add("%s.defaults.turn_detection: durations must not be negative", field)
+			}
+		}
+
+		switch model.Precision {
+		case "", PrecisionFP32:
+		case PrecisionInt8:
+			if model.Provider != "sherpa-onnx" {
+				errs.add("%s.precision: int8 is only supported for sherpa-onnx models", field)
+			} else if model.Int8 == nil {
+				errs.add("%s.int8: required for int8 precision", field)
+			}
+		default:
+			errs.add("%s.precision: must be %s or %s, got %q", field, PrecisionFP32, PrecisionInt8, model.Precision)
+		}
+		switch model.ExecutionProvider {
+		case "", ExecutionCPU:
+		case ExecutionCUDA:
+			if model.Provider != "sherpa-onnx" {
+				errs.add("%s.execution_provider: cuda is only supported for sherpa-onnx models", field)
+			}
+		default:
+			errs.add("%s.execution_provider: must be %s or %s, got %q", field, ExecutionCPU, ExecutionCUDA, model.ExecutionProvider)
+		}
+		if model.DeviceID < 0 {
+			errs.add("%s.device_id: must not be negative, got %d", field, model.DeviceID)
+		}
+		if model.VRAMMB < 0 {
+			errs.add("%s.vram_mb: must not be negative, got %d", field, model.VRAMMB)
+		} else if c.ASR.GPUMemoryMB > 0 && model.VRAMMB > c.ASR.GPUMemoryMB {
+			errs.add("%s.vram_mb: %d exceeds asr.gpu_memory_mb (%d), the model could never load",
+				field, model.VRAMMB, c.ASR.GPUMemoryMB)
+		}
+		if model.NumThreads < 0 {
+			errs.add("%s.num_threads: must not be negative, got %d", field, model.NumThreads)
+		} else if c.ASR.CPUBudget > 0 && c.ASR.ExecutionProvider(&model) != ExecutionCUDA &&
+			c.ASR.Threads(&model) > c.ASR.CPUBudget {
+			errs.add("%s.num_threads: %d exceeds asr.cpu_budget (%d), the model could never load",
+				field, c.ASR.Threads(&model), c.ASR.CPUBudget)
+		}
+		if c.ASR.ExecutionProvider(&model) == ExecutionCUDA {
+			// The sherpa-onnx bindings cannot place sessions on different
+			// devices, so the process is pinned to the one device
+			if cudaModel == "" {
+				cudaModel = name
+			} else if device := c.ASR.Models[cudaModel].DeviceID; model.DeviceID != device {
+				errs.add("%s.device_id: %d differs from device %d of model %s; all cuda models must share one device",
+					field, model.DeviceID, device, cudaModel)
+			}
+		}
+		if model.URL != "" {
+			if u, err := url.Parse(model.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs.add("%s.url: %q is not an http(s) URL", field, model.URL)
+			}
+		}
+
+		// Check the model files the providers will load
+		modelDir := filepath.Join(c.ASR.ModelsDir, name)
+		switch model.Provider {
+		case "sherpa-onnx":
+			files := map[string]string{
+				"encoder": model.Encoder,
+				"decoder": model.Decoder,
+				"joiner":  model.Joiner,
+				"tokens":  model.Tokens,
+			}
+			if model.Precision == PrecisionInt8 {
+				// The full-precision encoder and decoder are not loaded
+				delete(files, "encoder")
+				delete(files, "decoder")
+				if model.Int8 != nil {
+					files["int8.encoder"] = model.Int8.Encoder
+					files["int8.decoder"] = model.Int8.Decoder
+					if model.Int8.Joiner != "" {
+						files["int8.joiner"] = model.Int8.Joiner
+					}
+				}
+			}
+			for _, key := range sortedKeys(files) {
+				if files[key] == "" {
+					errs.add("%s.%s: required for sherpa-onnx models", field, key)
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(modelDir, files[key])); err != nil {
+					errs.add("%s.%s: %v", field, key, err)
+				}
+			}
+		case "whisper-cpp":
+			if _, err := os.Stat(modelDir); err != nil {
+				errs.add("%s: %v", field, err)
+			}
+		}
+
+		checksumsValid := true
+		for _, file := range sortedKeys(model.Checksums) {
+			if !validChecksum(model.Checksums[file]) {
+				errs.add("%s.checksums.%s: %q is not a hex sha256", field, file, model.Checksums[file])
+				checksumsValid = false
+			}
+		}
+		if checksumsValid {
+			if err := model.VerifyChecksums(modelDir); err != nil {
+				errs.add("%s.checksums.%v", field, err)
+			}
+		}
+	}
+}
+
+func (c *Config) validateTenants(errs *ValidationErrors) {
+	claimOwners := make(map[string]string)
+	for _, id := range sortedKeys(c.Tenants) {
+		tenant := c.Tenants[id]
+		field := "tenants." + id
+
+		if len(tenant.APIKeys) == 0 && len(tenant.JWTClaimValues) == 0 {
+			errs.add("%s: no api_keys or jwt_claim_values, the tenant can never match", field)
+		}
+		if len(tenant.JWTClaimValues) > 0 && c.Auth.JWT.TenantClaim == "" {
+			errs.add("%s.jwt_claim_values: set without auth.jwt.tenant_claim", field)
+		}
+		for _, value := range tenant.JWTClaimValues {
+			if owner, exists := claimOwners[value]; exists {
+				errs.add("%s.jwt_claim_values: %q is also used by tenant %s", field, value, owner)
+			}
+			claimOwners[value] = id
+		}
+		for _, model := range tenant.AllowedModels {
+			if _, exists := c.ASR.Models[model]; !exists {
+				errs.add("%s.allowed_models: %q is not defined in asr.models", field, model)
+			}
+		}
+		if tenant.Quota != nil && (tenant.Quota.DailyAudioSeconds < 0 || tenant.Quota.MonthlyAudioSeconds < 0) {
+			errs.add("%s.quota: limits must not be negative", field)
+		}
+		if tenant.RetentionDays < 0 {
+			errs.add("%s.retention_days: must not be negative, got %d", field, tenant.RetentionDays)
+		}
+	}
+}
+
+func (c *Config) validateSIP(errs *ValidationErrors) {
+	if !c.SIP.Enabled() {
+		return
+	}
+	if _, _, err := net.SplitHostPort(c.SIP.Listen); err != nil {
+		errs.add("sip.listen: %v", err)
+	}
+	if c.SIP.PublicAddress != "" && net.ParseIP(c.SIP.PublicAddress) == nil {
+		errs.add("sip.public_address: %q is not an IP address", c.SIP.PublicAddress)
+	}
+	if c.SIP.RTPPortMin <= 0 || c.SIP.RTPPortMax > 65535 || c.SIP.RTPPortMin > c.SIP.RTPPortMax {
+		errs.add("sip: invalid RTP port range %d-%d", c.SIP.RTPPortMin, c.SIP.RTPPortMax)
+	}
+	if c.SIP.MediaTimeout <= 0 {
+		errs.add("sip.media_timeout: must be positive, got %v", c.SIP.MediaTimeout)
+	}
+	c.validateGatewaySession(errs, "sip", c.SIP.Model, c.SIP.Language, c.SIP.APIKey, c.SIP.AllowedPeers)
+}
+
+func (c *Config) validateAudioSocket(errs *ValidationErrors) {
+	if !c.AudioSocket.Enabled() {
+		return
+	}
+	if _, _, err := net.SplitHostPort(c.AudioSocket.Listen); err != nil {
+		errs.add("audiosocket.listen: %v", err)
+	}
+	if c.AudioSocket.MediaTimeout <= 0 {
+		errs.add("audiosocket.media_timeout: must be positive, got %v", c.AudioSocket.MediaTimeout)
+	}
+	c.validateGatewaySession(errs, "audiosocket", c.AudioSocket.Model, c.AudioSocket.Language,
+		c.AudioSocket.APIKey, c.AudioSocket.AllowedPeers)
+}
+
+func (c *Config) validateMQTT(errs *ValidationErrors) {
+	if !c.MQTT.Enabled() {
+		return
+	}
+	if u, err := url.Parse(c.MQTT.Broker); err != nil || u.Host == "" {
+		errs.add("mqtt.broker: %q is not a broker URL", c.MQTT.Broker)
+	} else if !containsString(knownMQTTSchemes, u.Scheme) {
+		errs.add("mqtt.broker: unsupported scheme %q, must be one of %v", u.Scheme, knownMQTTSchemes)
+	}
+	if c.MQTT.TranscriptTopic == "" {
+		errs.add("mqtt.transcript_topic: must not be empty")
+	}
+	if strings.ContainsAny(c.MQTT.TranscriptTopic, "+#") {
+		errs.add("mqtt.transcript_topic: %q must not contain wildcards", c.MQTT.TranscriptTopic)
+	}
+	if strings.ContainsAny(c.MQTT.DeltaTopic, "+#") {
+		errs.add("mqtt.delta_topic: %q must not contain wildcards", c.MQTT.DeltaTopic)
+	}
+	if c.MQTT.QoS < 0 || c.MQTT.QoS > 2 {
+		errs.add("mqtt.qos: must be 0, 1 or 2, got %d", c.MQTT.QoS)
+	}
+}
+
+func (c *Config) validateWebRTC(errs *ValidationErrors) {
+	if !c.WebRTC.Enabled {
+		return
+	}
+	for i, server := range c.WebRTC.ICEServers {
+		scheme, _, _ := strings.Cut(server, ":")
+		if !containsString([]string{"stun", "stuns", "turn", "turns"}, scheme) {
+			errs.add("webrtc.ice_servers[%d]: %q is not a stun:, stuns:, turn: or turns: URL", i, server)
+		}
+	}
+	for i, ip := range c.WebRTC.PublicIPs {
+		if net.ParseIP(ip) == nil {
+			errs.add("webrtc.public_ips[%d]: %q is not an IP address", i, ip)
+		}
+	}
+	portsSet := c.WebRTC.UDPPortMin != 0 || c.WebRTC.UDPPortMax != 0
+	if portsSet && (c.WebRTC.UDPPortMin <= 0 || c.WebRTC.UDPPortMax > 65535 || c.WebRTC.UDPPortMin > c.WebRTC.UDPPortMax) {
+		errs.add("webrtc: invalid UDP port range %d-%d", c.WebRTC.UDPPortMin, c.WebRTC.UDPPortMax)
+	}
+}
+
+func (c *Config) validateWatch(errs *ValidationErrors) {
+	if !c.Watch.Enabled() {
+		return
+	}
+	if info, err := os.Stat(c.Watch.Dir); err != nil || !info.IsDir() {
+		errs.add("watch.dir: %q is not a directory", c.Watch.Dir)
+	}
+	if len(c.Watch.Formats) == 0 {
+		errs.add("watch.formats: must not be empty")
+	}
+	for i, format := range c.Watch.Formats {
+		if !containsString(knownWatchFormats, format) {
+			errs.add("watch.formats[%d]: unknown format %q, must be one of %v", i, format, knownWatchFormats)
+		}
+	}
+	if c.Watch.PollInterval <= 0 {
+		errs.add("watch.poll_interval: must be positive, got %v", c.Watch.PollInterval)
+	}
+	c.validateGatewaySession(errs, "watch", c.Watch.Model, c.Watch.Language, c.Watch.APIKey, nil)
+}
+
+func (c *Config) validateBatch(errs *ValidationErrors) {
+	if c.Batch.MaxBytes <= 0 {
+		errs.add("batch.max_bytes: must be positive, got %d", c.Batch.MaxBytes)
+	}
+	if c.Batch.FetchTimeout <= 0 {
+		errs.add("batch.fetch_timeout: must be positive, got %v", c.Batch.FetchTimeout)
+	}
+	if c.Batch.Workers <= 0 {
+		errs.add("batch.workers: must be positive, got %d", c.Batch.Workers)
+	}
+	if c.Batch.QueueSize < 0 {
+		errs.add("batch.queue_size: must not be negative, got %d", c.Batch.QueueSize)
+	}
+	if c.Batch.JobRetention <= 0 {
+		errs.add("batch.job_retention: must be positive, got %v", c.Batch.JobRetention)
+	}
+	for i, host := range c.Batch.AllowedHosts {
+		if bucket, ok := strings.CutPrefix(host, "s3://"); ok && bucket != "" && !strings.Contains(bucket, "/") {
+			continue
+		}
+		if bucket, ok := strings.CutPrefix(host, "gs://"); ok && bucket != "" && !strings.Contains(bucket, "/") {
+			continue
+		}
+		if host == "" || strings.ContainsAny(host, ":/") || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			errs.add("batch.allowed_hosts[%d]: %q must be a hostname, *.domain, s3://bucket or gs://bucket", i, host)
+		}
+	}
+	validateObjectStore(errs, "batch.s3", c.Batch.S3)
+	validateObjectStore(errs, "batch.gcs", c.Batch.GCS)
+	for i, host := range c.Batch.CallbackHosts {
+		if host == "" || strings.ContainsAny(host, ":/") || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			errs.add("batch.callback_hosts[%d]: %q must be a hostname or *.domain", i, host)
+		}
+	}
+	if len(c.Batch.CallbackHosts) > 0 && c.Batch.CallbackSecret == "" {
+		errs.add("batch.callback_secret: required to sign callbacks when batch.callback_hosts is set")
+	}
+	if c.Batch.CallbackAttempts <= 0 {
+		errs.add("batch.callback_attempts: must be positive, got %d", c.Batch.CallbackAttempts)
+	}
+	if c.Batch.CallbackBackoff <= 0 {
+		errs.add("batch.callback_backoff: must be positive, got %v", c.Batch.CallbackBackoff)
+	}
+}
+
+// validateObjectStore checks the credentials of an object store
+func validateObjectStore(errs *ValidationErrors, section string, store ObjectStoreConfig) {
+	if (store.AccessKeyID == "") != (store.SecretAccessKey == "") {
+		errs.add("%s: access_key_id and secret_access_key must be set together", section)
+	}
+	if store.Endpoint != "" {
+		if u, err := url.Parse(store.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add("%s.endpoint: %q is not an http(s) URL", section, store.Endpoint)
+		}
+	}
+}
+
+// validateUsage checks the usage export
+func (c *Config) validateUsage(errs *ValidationErrors) {
+	usage := c.Usage
+	switch usage.Sink {
+	case "":
+		return
+	case "file":
+		if usage.File == "" {
+			errs.add("usage.file: required for the file sink")
+		}
+	case "s3":
+		if u, err := url.Parse(usage.URL); err != nil || u.Scheme != "s3" || u.Host == "" {
+			errs.add("usage.url: %q must be s3://bucket/prefix for the s3 sink", usage.URL)
+		}
+		validateObjectStore(errs, "usage.s3", usage.S3)
+	case "webhook":
+		if u, err := url.Parse(usage.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add("usage.url: %q must be an http(s) URL for the webhook sink", usage.URL)
+		}
+		if usage.Secret == "" {
+			errs.add("usage.secret: required to sign webhook payloads")
+		}
+	default:
+		errs.add("usage.sink: %q must be file, s3 or webhook", usage.Sink)
+	}
+	if usage.Attempts <= 0 {
+		errs.add("usage.attempts: must be positive, got %d", usage.Attempts)
+	}
+	if usage.Backoff <= 0 {
+		errs.add("usage.backoff: must be positive, got %v", usage.Backoff)
+	}
+	if usage.QueueSize <= 0 {
+		errs.add("usage.queue_size: must be positive, got %d", usage.QueueSize)
+	}
+}
+
+// validateGatewaySession checks the session settings shared by the call transports
+func (c *Config) validateGatewaySession(errs *ValidationErrors, section, modelName, language, apiKey string, peers []string) {
+	if modelName != "" {
+		if model, exists := c.ASR.Models[modelName]; !exists {
+			errs.add("%s.model: %q is not defined in asr.models", section, modelName)
+		} else if language != "" && !containsString(model.Languages, language) {
+			errs.add("%s.language: %q is not supported by model %s", section, language, modelName)
+		}
+	}
+	if apiKey != "" && !c.HasScope(apiKey, ScopeRealtimeTranscribe) {
+		errs.add("%s.api_key: key lacks the %s scope", section, ScopeRealtimeTranscribe)
+	}
+	for i, peer := range peers {
+		if _, _, err := net.ParseCIDR(peer); err != nil && net.ParseIP(peer) == nil {
+			errs.add("%s.allowed_peers[%d]: %q is not an IP or CIDR", section, i, peer)
+		}
+	}
+}
+
+// sortedKeys returns map keys in order so reports are deterministic
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -9,6 +9,24 @@ type TranscriptionChunk struct {
 	StartMs   int     `json:"start_ms,omitempty"`
 	EndMs     int     `json:"end_ms,omitempty"`
 	Logprobs  []Logprob `json:"logprobs,omitempty"`
+	Err       error     `json:"-"` // Set when the provider fails mid-stream; no further chunks follow
+
+	// Words times the words of Text from the start of the transcribed audio,
+	// for providers that report them
+	Words []WordTiming `json:"words,omitempty"`
+	// NoSpeechProb is the provider's probability that the audio holds no speech
+	NoSpeechProb float64 `json:"no_speech_prob,omitempty"`
+
+	// Hypothesis is the full transcript so far from recognizers that may revise
+	// earlier words. When set, consumers stabilize it instead of appending Text.
+	Hypothesis string `json:"hypothesis,omitempty"`
+}
+
+// WordTiming is a transcribed word and the span of audio it was heard in
+type WordTiming struct {
+	Word    string `json:"word"`
+	StartMs int    `json:"start_ms"`
+	EndMs   int    `json:"end_ms"`
 }
 
 // Logprob represents log probability information for transcription
@@ -45,10 +63,53 @@ type ASRProvider interface {
 	// GetSupportedLanguages returns list of supported language codes
 	GetSupportedLanguages() []string
 
+	// HealthCheck reports whether the provider can transcribe, see ProviderHealth
+	HealthCheck() ProviderHealth
+
 	// Close releases any resources held by the provider
 	Close() error
 }
 
+// PromptCapable is implemented by providers that can condition a
+// transcription on TranscriptionConfig's Prompt or Temperature, such as a
+// Whisper initial prompt. Other providers get neither.
+type PromptCapable interface {
+	SupportsPrompt() bool
+	SupportsTemperature() bool
+}
+
+// SupportedOptions returns config without the prompt and temperature if
+// provider cannot use them, and the options it dropped
+func SupportedOptions(provider ASRProvider, config *TranscriptionConfig) (*TranscriptionConfig, []string) {
+	capable, _ := provider.(PromptCapable)
+	var dropped []string
+	supported := *config
+	if supported.Prompt != "" && (capable == nil || !capable.SupportsPrompt()) {
+		supported.Prompt = ""
+		dropped = append(dropped, "prompt")
+	}
+	if supported.Temperature != 0 && (capable == nil || !capable.SupportsTemperature()) {
+		supported.Temperature = 0
+		dropped = append(dropped, "temperature")
+	}
+	if dropped == nil {
+		return config, nil
+	}
+	return &supported, dropped
+}
+
+// TranslationCapable is implemented by providers that can translate speech
+// to English while transcribing it, such as Whisper's translate task
+type TranslationCapable interface {
+	SupportsTranslation() bool
+}
+
+// SupportsTranslation reports whether provider honors TranscriptionConfig.Translate
+func SupportsTranslation(provider ASRProvider) bool {
+	capable, ok := provider.(TranslationCapable)
+	return ok && capable.SupportsTranslation()
+}
+
 // ASRConfig holds configuration for ASR provider initialization
 type ASRConfig struct {
 	Provider    string            // "whisper", "google", "azure", "mock"
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// The ASRProvider interface itself is defined in asr.go
+
+// ProviderHealth is an ASRProvider's report of its state, for /health. A
+// provider fills in what it knows of itself; the model registry adds the
+// queue depth and errors of the transcriptions it serves.
+type ProviderHealth struct {
+	ModelLoaded bool `json:"model_loaded"` // Ready to transcribe
+	// Mock is set by providers answering placeholder transcripts instead of
+	// recognizing speech, so a server degraded to one can be told from a
+	// healthy one
+	Mock        bool       `json:"mock,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`    // Latest failed load or transcription
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"` // When LastError happened
+	Failing     bool       `json:"failing,omitempty"`       // The latest load or transcription failed
+	QueueDepth  int        `json:"queue_depth"`             // Transcriptions in flight on the provider
+}
+
+// ErrTransient marks provider failures that may succeed when retried, such as
+// an overloaded or briefly unreachable backend. Providers wrap it, e.g.
+// fmt.Errorf("%w: backend returned 503", domain.ErrTransient).
+var ErrTransient = errors.New("transient ASR failure")
+
+// IsTransient reports whether a provider error is worth retrying: it wraps
+// ErrTransient or is a network timeout
+func IsTransient(err error) bool {
+	if errors.Is(err, ErrTransient) {
+		return true
+	}
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
@@ -0,0 +1,536 @@
+package domain
+
+import (
+	"encoding/base64"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AudioConfig represents audio input/output configuration
+type AudioConfig struct {
+	Input  *AudioInput  `json:"input"`
+	Output *AudioOutput `json:"output"`
+}
+
+// AudioInput represents input audio configuration
+type AudioInput struct {
+	Format         *AudioFormat         `json:"format"`
+	Transcription  *TranscriptionConfig `json:"transcription"`   // null or settings
+	NoiseReduction *NoiseReduction      `json:"noise_reduction"` // null or settings
+	TurnDetection  *TurnDetection       `json:"turn_detection"`
+
+	// EchoCancellation removes the echo of the reference audio clients send
+	// with output_audio_buffer.append (an extension), null to disable
+	EchoCancellation *EchoCancellation `json:"echo_cancellation,omitempty"`
+}
+
+// TranscriptionConfig represents transcription settings for STT
+type TranscriptionConfig struct {
+	Model    string `json:"model"`              // "whisper-1", "gpt-4o-transcribe", "gpt-4o-mini-transcribe"
+	Language string `json:"language,omitempty"` // ISO-639-1 code like "en"
+	Prompt   string `json:"prompt,omitempty"`   // Optional prompt to guide transcription
+
+	// Temperature samples the transcription, 0-1, for providers that support
+	// it (an extension); 0 decodes greedily
+	Temperature float64 `json:"temperature,omitempty"`
+
+	// Translate transcribes the speech translated to English (an extension),
+	// for providers that support it
+	Translate bool `json:"translate,omitempty"`
+}
+
+// NoiseReduction represents noise reduction settings
+type NoiseReduction struct {
+	Type string `json:"type"` // "near_field", "far_field", or null to disable
+}
+
+// EchoCancellation represents acoustic echo cancellation settings of pcm16 input
+type EchoCancellation struct {
+	TailMs int `json:"tail_ms,omitempty"` // Longest echo delay cancelled, default DefaultEchoTailMs
+}
+
+// Bounds of the echo tail, the delay of the played audio's echo the
+// canceller covers. Longer tails cost proportionally more CPU.
+const (
+	DefaultEchoTailMs = 128
+	MaxEchoTailMs     = 500
+)
+
+// Tail returns the echo tail in milliseconds
+func (ec *EchoCancellation) Tail() int {
+	if ec.TailMs > 0 {
+		return ec.TailMs
+	}
+	return DefaultEchoTailMs
+}
+
+// AudioOutput represents output audio configuration
+type AudioOutput struct {
+	Format *AudioFormat `json:"format"`
+	Voice  string       `json:"voice"` // One of Voices, default "alloy"
+	Speed  float64      `json:"speed"` // MinOutputSpeed-MaxOutputSpeed, default 1.0
+}
+
+// Voices are the output voices a session may select
+var Voices = []string{"alloy", "ash", "ballad", "cedar", "coral", "echo", "fable", "marin", "nova", "onyx", "sage", "shimmer", "verse"}
+
+// DefaultVoice is the output voice of sessions that select none
+const DefaultVoice = "alloy"
+
+// Bounds of the output speed, 1.0 being the voice's natural pace
+const (
+	MinOutputSpeed = 0.25
+	MaxOutputSpeed = 1.5
+)
+
+// AudioFormat represents audio format specification
+type AudioFormat struct {
+	Type string `json:"type"` // "audio/pcm"
+	Rate int    `json:"rate"` // 24000, 16000, etc
+}
+
+// Input audio encodings, named as in input_audio_format
+const (
+	EncodingPCM16    = "pcm16"
+	EncodingG711Ulaw = "g711_ulaw"
+	EncodingG711Alaw = "g711_alaw"
+)
+
+// DefaultSampleRate is the PCM sample rate assumed when none is configured
+const DefaultSampleRate = 24000
+
+// Encoding returns the sample encoding of the format, PCM16 if unspecified
+func (f *AudioFormat) Encoding() string {
+	if f != nil {
+		switch f.Type {
+		case "audio/pcmu":
+			return EncodingG711Ulaw
+		case "audio/pcma":
+			return EncodingG711Alaw
+		}
+	}
+	return EncodingPCM16
+}
+
+// SampleRate returns the rate of the format. G.711 is always 8kHz.
+func (f *AudioFormat) SampleRate() int {
+	if f.Encoding() != EncodingPCM16 {
+		return 8000
+	}
+	if f != nil && f.Rate > 0 {
+		return f.Rate
+	}
+	return DefaultSampleRate
+}
+
+// Normalized returns the format as the server applies it, with its type and
+// sample rate filled in
+func (f *AudioFormat) Normalized() *AudioFormat {
+	formatType := "audio/pcm"
+	switch f.Encoding() {
+	case EncodingG711Ulaw:
+		formatType = "audio/pcmu"
+	case EncodingG711Alaw:
+		formatType = "audio/pcma"
+	}
+	return &AudioFormat{Type: formatType, Rate: f.SampleRate()}
+}
+
+// BytesPerSample returns the size of one mono sample: 1 for G.711, 2 for PCM16
+func (f *AudioFormat) BytesPerSample() int {
+	if f.Encoding() != EncodingPCM16 {
+		return 1
+	}
+	return 2
+}
+
+// Issues AudioQuality reports about a turn's audio
+const (
+	AudioIssueTooQuiet = "too_quiet"
+	AudioIssueClipping = "clipping"
+	AudioIssueNoisy    = "noisy"
+)
+
+// AudioQuality measures a turn's audio, so clients can tell a quiet or
+// clipping microphone from a recognition error
+type AudioQuality struct {
+	ClippingPercent float64  `json:"clipping_percent"` // Samples at full scale
+	RMSDBFS         float64  `json:"rms_dbfs"`         // Level of the turn, dB relative to full scale
+	SNRDB           float64  `json:"snr_db"`           // Estimated from the turn's loudest and quietest frames
+	SilenceRatio    float64  `json:"silence_ratio"`    // Share of the turn that is silent, 0-1
+	Issues          []string `json:"issues,omitempty"`
+}
+
+// TurnDetection represents VAD (Voice Activity Detection) settings
+type TurnDetection struct {
+	Type              string      `json:"type"`                // "server_vad", "semantic_vad", "interval", or null for manual commits
+	Threshold         float64     `json:"threshold"`           // 0.0-1.0
+	PrefixPaddingMs   int         `json:"prefix_padding_ms"`   // milliseconds
+	SilenceDurationMs int         `json:"silence_duration_ms"` // milliseconds
+	IdleTimeoutMs     interface{} `json:"idle_timeout_ms"`     // null or milliseconds
+	CreateResponse    bool        `json:"create_response"`     // auto-create response after speech
+	InterruptResponse bool        `json:"interrupt_response"`  // interrupt on new speech
+
+	// IntervalMs is how much buffered audio the interval type commits at a
+	// time (an extension), default DefaultCommitIntervalMs
+	IntervalMs int `json:"interval_ms,omitempty"`
+}
+
+// TurnDetectionInterval is the turn detection type that commits the buffer
+// every IntervalMs of audio instead of at pauses in speech, for dictation
+// that never pauses (an extension)
+const TurnDetectionInterval = "interval"
+
+// Bounds of the interval of interval turn detection
+const (
+	DefaultCommitIntervalMs = 5000
+	MinCommitIntervalMs     = 1000
+)
+
+// DetectsSpeech reports whether td ends turns at pauses detected by VAD
+func (td *TurnDetection) DetectsSpeech() bool {
+	return td != nil && td.Type != "" && td.Type != TurnDetectionInterval
+}
+
+// CommitInterval returns the audio the interval type commits at a time, in
+// milliseconds
+func (td *TurnDetection) CommitInterval() int {
+	if td.IntervalMs > 0 {
+		return td.IntervalMs
+	}
+	return DefaultCommitIntervalMs
+}
+
+// DefaultTurnDetection returns the turn detection a new session of
+// sessionType starts with. Transcription sessions wait longer for the end of
+// speech and never create responses.
+func DefaultTurnDetection(sessionType string) *TurnDetection {
+	if sessionType == "transcription" {
+		return &TurnDetection{Type: "server_vad", Threshold: 0.5, PrefixPaddingMs: 300, SilenceDurationMs: 500}
+	}
+	return &TurnDetection{
+		Type:              "server_vad",
+		Threshold:         0.5,
+		PrefixPaddingMs:   300,
+		SilenceDurationMs: 200,
+		CreateResponse:    true,
+		InterruptResponse: true,
+	}
+}
+
+// FillDefaults sets the settings an update left out of td, as recorded in
+// sent under path, to those of defaults. Semantic VAD has no threshold,
+// padding or silence duration to fill.
+func (td *TurnDetection) FillDefaults(defaults *TurnDetection, sent SessionFields, path string) {
+	unset := func(field string) bool { return !sent.Sent(path + "." + field) }
+	if td.Type == "" && unset("type") {
+		td.Type = defaults.Type
+	}
+	if !td.CreateResponse && unset("create_response") {
+		td.CreateResponse = defaults.CreateResponse
+	}
+	if !td.InterruptResponse && unset("interrupt_response") {
+		td.InterruptResponse = defaults.InterruptResponse
+	}
+	if td.Type == TurnDetectionInterval && td.IntervalMs == 0 && unset("interval_ms") {
+		td.IntervalMs = DefaultCommitIntervalMs
+	}
+	if td.Type != "server_vad" {
+		return
+	}
+	if td.Threshold == 0 && unset("threshold") {
+		td.Threshold = defaults.Threshold
+	}
+	if td.PrefixPaddingMs == 0 && unset("prefix_padding_ms") {
+		td.PrefixPaddingMs = defaults.PrefixPaddingMs
+	}
+	if td.SilenceDurationMs == 0 && unset("silence_duration_ms") {
+		td.SilenceDurationMs = defaults.SilenceDurationMs
+	}
+}
+
+// ErrBufferFull is returned when audio buffer exceeds max size
+var ErrBufferFull = &BufferFullError{}
+
+// BufferFullError indicates the audio buffer has reached its size limit
+type BufferFullError struct{}
+
+func (e *BufferFullError) Error() string {
+	return "audio buffer size limit exceeded"
+}
+
+// SpillFile holds an AudioBuffer's data once it outgrows its spill
+// threshold. Close discards the data.
+type SpillFile interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+}
+
+// AudioBuffer represents the input audio buffer
+type AudioBuffer struct {
+	Data          []byte
+	Lock          chan struct{} // simple lock mechanism
+	committed     bool
+	mu            sync.Mutex
+	startTime     time.Time
+	speechStartMs int
+	speechEndMs   int
+	maxSize       int // maximum buffer size in bytes, 0 means unlimited
+	window        int // bytes of most recent audio kept, 0 keeps everything up to maxSize
+
+	spillAt   int // size in bytes past which data moves to disk, 0 keeps it in memory
+	openSpill func() (SpillFile, error)
+	spill     SpillFile // nil until the buffer spills
+	spilled   int       // bytes held in spill
+}
+
+// Append adds audio data to the buffer
+// Returns error if buffer would exceed max size
+func (ab *AudioBuffer) Append(data []byte) error {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	// Check if adding data would exceed max size
+	if ab.window == 0 && ab.maxSize > 0 && ab.size()+len(data) > ab.maxSize {
+		return ErrBufferFull
+	}
+
+	size := len(ab.Data)
+	ab.Data = append(ab.Data, data...)
+	return ab.accept(size)
+}
+
+// AppendBase64 decodes standard base64 audio straight into the buffer's spare
+// capacity, without an intermediate decoded slice. It returns the decoded
+// chunk, which aliases the buffer and must be copied to be kept past the next
+// Append or Clear. On error the buffer is unchanged.
+func (ab *AudioBuffer) AppendBase64(src []byte) ([]byte, error) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	size := len(ab.Data)
+	ab.Data = slices.Grow(ab.Data, base64.StdEncoding.DecodedLen(len(src)))
+	n, err := base64.StdEncoding.Decode(ab.Data[size:cap(ab.Data)], src)
+	if err != nil {
+		return nil, err
+	}
+	if ab.window == 0 && ab.maxSize > 0 && ab.size()+n > ab.maxSize {
+		return nil, ErrBufferFull
+	}
+
+	ab.Data = ab.Data[:size+n]
+	chunk := ab.Data[size : size+n : size+n]
+	if err := ab.accept(size); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// accept keeps the bytes appended to Data past size, dropping the oldest
+// audio beyond the window or moving the data to the spill file once past the
+// threshold. On error the bytes are dropped.
+func (ab *AudioBuffer) accept(size int) error {
+	if window := ab.windowSize(); window > 0 && ab.spill == nil {
+		// Slicing off the front is cheap; the next reallocation copies
+		// only the window
+		if len(ab.Data) > window {
+			ab.Data = ab.Data[len(ab.Data)-window:]
+		}
+		if ab.startTime.IsZero() {
+			ab.startTime = time.Now()
+		}
+		return nil
+	}
+	if ab.spill == nil && (ab.spillAt == 0 || len(ab.Data) <= ab.spillAt) {
+		if ab.startTime.IsZero() {
+			ab.startTime = time.Now()
+		}
+		return nil
+	}
+
+	first := ab.spill == nil
+	if first {
+		spill, err := ab.openSpill()
+		if err != nil {
+			ab.Data = ab.Data[:size]
+			return err
+		}
+		ab.spill = spill
+	}
+	if _, err := ab.spill.WriteAt(ab.Data, int64(ab.spilled)); err != nil {
+		ab.Data = ab.Data[:size]
+		return err
+	}
+	ab.spilled += len(ab.Data)
+
+	// Release the in-memory data; later chunks only pass through Data
+	if first {
+		ab.Data = nil
+	} else {
+		ab.Data = ab.Data[:0]
+	}
+	if ab.startTime.IsZero() {
+		ab.startTime = time.Now()
+	}
+	return nil
+}
+
+// size returns the buffered bytes, in memory and spilled
+func (ab *AudioBuffer) size() int {
+	return ab.spilled + len(ab.Data)
+}
+
+// windowSize returns the rolling window in bytes, capped at the max size
+func (ab *AudioBuffer) windowSize() int {
+	if ab.maxSize > 0 && ab.window > ab.maxSize {
+		return ab.maxSize
+	}
+	return ab.window
+}
+
+// SetWindow keeps only the most recent size bytes of audio, dropping older
+// audio on append instead of failing with ErrBufferFull. 0 disables it.
+func (ab *AudioBuffer) SetWindow(size int) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	ab.window = size
+}
+
+// SetSpill moves the buffer's data to a file from open once it grows past
+// threshold bytes, bounding its memory use. A threshold of 0 disables it.
+func (ab *AudioBuffer) SetSpill(threshold int, open func() (SpillFile, error)) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	ab.spillAt = threshold
+	ab.openSpill = open
+}
+
+// IsSpilled returns whether the buffer's data is held in its spill file
+func (ab *AudioBuffer) IsSpilled() bool {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return ab.spill != nil
+}
+
+// readSpill reads the spilled data back into memory
+func (ab *AudioBuffer) readSpill() ([]byte, error) {
+	data := make([]byte, ab.spilled)
+	if _, err := ab.spill.ReadAt(data, 0); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// releaseSpill closes and forgets the spill file
+func (ab *AudioBuffer) releaseSpill() {
+	if ab.spill != nil {
+		ab.spill.Close()
+	}
+	ab.spill = nil
+	ab.spilled = 0
+}
+
+// SetMaxSize sets the maximum buffer size
+func (ab *AudioBuffer) SetMaxSize(size int) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	ab.maxSize = size
+}
+
+// GetMaxSize returns the maximum buffer size
+func (ab *AudioBuffer) GetMaxSize() int {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return ab.maxSize
+}
+
+// Commit hands the buffer data to the caller and marks the buffer as
+// committed. In-memory data is handed over without copying, so the buffer no
+// longer references the returned slice; spilled data is read back from disk.
+func (ab *AudioBuffer) Commit() ([]byte, error) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	data := ab.Data
+	if ab.spill != nil {
+		var err error
+		if data, err = ab.readSpill(); err != nil {
+			return nil, err
+		}
+		ab.releaseSpill()
+	}
+	ab.Data = nil
+	ab.committed = true
+	return data, nil
+}
+
+// Clear empties the buffer, keeping its capacity for the next appends
+func (ab *AudioBuffer) Clear() {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	ab.Data = ab.Data[:0]
+	ab.releaseSpill()
+	ab.committed = false
+	ab.startTime = time.Time{}
+	ab.speechStartMs = 0
+	ab.speechEndMs = 0
+}
+
+// MemorySize returns the bytes the buffer holds in memory, including spare
+// capacity but not spilled data
+func (ab *AudioBuffer) MemorySize() int {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return cap(ab.Data)
+}
+
+// GetSize returns the current buffer size
+func (ab *AudioBuffer) GetSize() int {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return ab.size()
+}
+
+// IsEmpty checks if buffer is empty
+func (ab *AudioBuffer) IsEmpty() bool {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return ab.size() == 0
+}
+
+// IsCommitted returns whether buffer has been committed
+func (ab *AudioBuffer) IsCommitted() bool {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return ab.committed
+}
+
+// GetData returns a copy of buffer data, nil if spilled data cannot be read
+func (ab *AudioBuffer) GetData() []byte {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	if ab.spill != nil {
+		data, _ := ab.readSpill()
+		return data
+	}
+	data := make([]byte, len(ab.Data))
+	copy(data, ab.Data)
+	return data
+}
+
+// SetSpeechTimings sets speech start and end times in milliseconds
+func (ab *AudioBuffer) SetSpeechTimings(startMs, endMs int) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	ab.speechStartMs = startMs
+	ab.speechEndMs = endMs
+}
+
+// GetSpeechTimings returns speech start and end times
+func (ab *AudioBuffer) GetSpeechTimings() (int, int) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return ab.speechStartMs, ab.speechEndMs
+}
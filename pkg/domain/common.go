@@ -10,11 +10,12 @@ type Tool struct {
 
 // ErrorDetail represents error information
 type ErrorDetail struct {
-	Type    string      `json:"type"`               // "invalid_request_error", "invalid_api_key_error", etc
-	Code    string      `json:"code"`               // Specific error code
-	Message string      `json:"message"`            // Human-readable message
-	Param   interface{} `json:"param"`              // Related parameter if applicable
-	EventID string      `json:"event_id,omitempty"` // Echo back client event_id
+	Type      string      `json:"type"`               // "invalid_request_error", "invalid_api_key_error", etc
+	Code      string      `json:"code"`               // Specific error code, one of the Code constants
+	Message   string      `json:"message"`            // Human-readable message
+	Param     interface{} `json:"param"`              // Related parameter if applicable
+	EventID   string      `json:"event_id,omitempty"` // Echo back client event_id
+	Retryable bool        `json:"retryable"`          // Whether the request may succeed when sent again, see IsRetryableCode
 }
 
 // RateLimit represents rate limit information
@@ -0,0 +1,176 @@
+package domain
+
+import "sync"
+
+// ConversationState tracks conversation history and state. It is safe for
+// concurrent use, since VAD commits add items off the message loop.
+type ConversationState struct {
+	ID    string
+	Items map[string]*Item // itemID -> Item
+	Order []string         // ordered item IDs
+	mu    sync.RWMutex
+}
+
+// Item represents a conversation item
+type Item struct {
+	ID        string        `json:"id"`
+	Object    string        `json:"object"`         // "realtime.item"
+	Type      string        `json:"type"`           // "message", "function_call"
+	Status    string        `json:"status"`         // "in_progress", "completed"
+	Role      string        `json:"role,omitempty"` // "user", "assistant"
+	Content   []ContentPart `json:"content"`
+	CreatedAt int64         `json:"created_at,omitempty"`
+	Speaker   *Speaker      `json:"speaker,omitempty"` // Speaker of the audio of diarized sessions, an extension
+
+	// Where the audio of an input audio item lies in the session's input
+	// audio, and the timings of its transcript's words within that audio
+	AudioStartMs int          `json:"-"`
+	AudioEndMs   int          `json:"-"`
+	Words        []WordTiming `json:"-"`
+}
+
+// ContentPart represents content within an item
+type ContentPart struct {
+	Type         string        `json:"type"` // "input_text", "input_audio", "text", "output_audio", "function_call"
+	Text         string        `json:"text,omitempty"`
+	Audio        string        `json:"audio,omitempty"` // base64-encoded audio
+	Transcript   string        `json:"transcript,omitempty"`
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+	Format       string        `json:"format,omitempty"` // "pcm16" for audio
+}
+
+// FunctionCall represents a function call in content
+type FunctionCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON string
+}
+
+// NewConversationState creates a new conversation state
+func NewConversationState(conversationID string) *ConversationState {
+	return &ConversationState{
+		ID:    conversationID,
+		Items: make(map[string]*Item),
+		Order: []string{},
+	}
+}
+
+// AddItem adds an item to the conversation and returns the ID of the item
+// before it, nil if it is the first
+func (cs *ConversationState) AddItem(item *Item) *string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var previousItemID *string
+	if len(cs.Order) > 0 {
+		prevID := cs.Order[len(cs.Order)-1]
+		previousItemID = &prevID
+	}
+	cs.Items[item.ID] = item
+	cs.Order = append(cs.Order, item.ID)
+	return previousItemID
+}
+
+// GetItem retrieves an item by ID
+func (cs *ConversationState) GetItem(itemID string) *Item {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.Items[itemID]
+}
+
+// SetTranscript finalizes an item's transcript and the timings of its words,
+// returning the item, or nil if it was deleted
+func (cs *ConversationState) SetTranscript(itemID, transcript string, words []WordTiming) *Item {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	item := cs.Items[itemID]
+	if item == nil || len(item.Content) == 0 {
+		return nil
+	}
+	item.Content[0].Transcript = transcript
+	item.Words = words
+	return item
+}
+
+// List returns copies of the items in order
+func (cs *ConversationState) List() []Item {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	items := make([]Item, 0, len(cs.Order))
+	for _, id := range cs.Order {
+		item := *cs.Items[id]
+		item.Content = append([]ContentPart(nil), item.Content...)
+		items = append(items, item)
+	}
+	return items
+}
+
+// DeleteItem removes an item from the conversation
+func (cs *ConversationState) DeleteItem(itemID string) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, exists := cs.Items[itemID]; !exists {
+		return false
+	}
+	delete(cs.Items, itemID)
+	for i, id := range cs.Order {
+		if id == itemID {
+			cs.Order = append(cs.Order[:i], cs.Order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Len returns the number of items in the conversation
+func (cs *ConversationState) Len() int {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return len(cs.Order)
+}
+
+// AudioBytes returns the size of the audio held in the conversation's items
+func (cs *ConversationState) AudioBytes() int {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	size := 0
+	for _, item := range cs.Items {
+		for _, part := range item.Content {
+			size += len(part.Audio)
+		}
+	}
+	return size
+}
+
+// PruneAudio drops the audio of all items, keeping their transcripts, and
+// returns the bytes released
+func (cs *ConversationState) PruneAudio() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	pruned := 0
+	for _, item := range cs.Items {
+		for i := range item.Content {
+			pruned += len(item.Content[i].Audio)
+			item.Content[i].Audio = ""
+		}
+	}
+	return pruned
+}
+
+// NewItem creates a new conversation item
+func NewItem(itemID, itemType, role string) *Item {
+	return &Item{
+		ID:        itemID,
+		Object:    "realtime.item",
+		Type:      itemType,
+		Status:    "in_progress",
+		Role:      role,
+		Content:   []ContentPart{},
+		CreatedAt: 0, // Will be set when needed
+	}
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// StoredConversation is the conversation of an ended session, kept so its
+// transcripts can be fetched after the connection is gone
+type StoredConversation struct {
+	ID        string            `json:"id"`
+	Object    string            `json:"object"` // "realtime.conversation"
+	SessionID string            `json:"session_id"`
+	CreatedAt int64             `json:"created_at"` // Unix seconds the session started
+	EndedAt   int64             `json:"ended_at"`   // Unix seconds the session ended
+	ItemCount int               `json:"item_count"`
+	Metadata  map[string]string `json:"metadata,omitempty"` // Session metadata set by the client
+
+	Items    []Item `json:"-"` // In order, served page by page
+	Owner    string `json:"-"` // OwnerHash of the credentials that opened the session
+	TenantID string `json:"-"` // Tenant of the session, empty when none matched
+}
+
+// ItemList is a page of a stored conversation's items
+type ItemList struct {
+	Object  string `json:"object"` // "list"
+	Data    []Item `json:"data"`
+	FirstID string `json:"first_id,omitempty"`
+	LastID  string `json:"last_id,omitempty"`
+	HasMore bool   `json:"has_more"`
+}
+
+// ConversationStore keeps the conversations of ended sessions
+type ConversationStore interface {
+	// Save stores a conversation, replacing one with the same ID
+	Save(conversation *StoredConversation) error
+
+	// Get returns a stored conversation by its ID or the ID of its session,
+	// which is all clients of the GA protocol learn, or ErrConversationNotFound
+	Get(id string) (*StoredConversation, error)
+
+	// List returns every stored conversation
+	List() ([]*StoredConversation, error)
+
+	// Delete removes a conversation by its ID or the ID of its session, or
+	// returns ErrConversationNotFound
+	Delete(id string) error
+}
+
+// ErrConversationNotFound is returned for conversations that are not stored
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// OwnerHash identifies the owner of stored data by a digest of their
+// credential, so stores never hold API keys
+func OwnerHash(credential string) string {
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:])
+}
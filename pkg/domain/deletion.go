@@ -0,0 +1,40 @@
+package domain
+
+// Reasons stored data is deleted for
+const (
+	DeletionReasonRetention = "retention" // Older than its retention period
+	DeletionReasonErasure   = "erasure"   // Erased on request
+)
+
+// Kinds of stored data
+const (
+	StoredConversationKind = "conversation"
+	StoredRecordingKind    = "recording"
+)
+
+// DeletionRecord audits the deletion of a piece of stored data
+type DeletionRecord struct {
+	Time      int64  `json:"time"` // Unix seconds of the deletion
+	Kind      string `json:"kind"` // StoredConversationKind or StoredRecordingKind
+	ID        string `json:"id"`   // Conversation ID or recording file name
+	SessionID string `json:"session_id,omitempty"`
+	Owner     string `json:"owner,omitempty"` // OwnerHash of the credentials that created the data, when known
+	TenantID  string `json:"tenant_id,omitempty"`
+	CreatedAt int64  `json:"created_at"` // Unix seconds the data was stored
+	Reason    string `json:"reason"`     // DeletionReasonRetention or DeletionReasonErasure
+	DryRun    bool   `json:"dry_run,omitempty"`
+	Receipt   string `json:"receipt,omitempty"` // ID of the DeletionReceipt of an erasure
+}
+
+// DeletionReceipt confirms an erasure request, listing the stored data it
+// deleted across all stores
+type DeletionReceipt struct {
+	ID        string           `json:"id"`
+	Object    string           `json:"object"`     // "deletion.receipt"
+	CreatedAt int64            `json:"created_at"` // Unix seconds of the erasure
+	SessionID string           `json:"session_id,omitempty"`
+	Owner     string           `json:"owner,omitempty"` // OwnerHash of the credentials whose data was erased
+	Since     int64            `json:"since,omitempty"` // Unix seconds bounding the sessions erased
+	Until     int64            `json:"until,omitempty"`
+	Deleted   []DeletionRecord `json:"deleted"`
+}
@@ -0,0 +1,84 @@
+package domain
+
+// Error codes sent in ErrorDetail.Code, by the realtime API and the HTTP
+// endpoints alike. Clients may rely on them; messages are for humans only.
+const (
+	// Requests the client must fix before sending them again
+	CodeInvalidJSON             = "invalid_json"                   // Event is not valid JSON
+	CodeInvalidEvent            = "invalid_event"                  // Event does not match its schema
+	CodeInvalidType             = "invalid_type"                   // Field has the wrong JSON type
+	CodeUnknownField            = "unknown_field"                  // Field is not part of the event
+	CodeMissingField            = "missing_field"                  // Required field is missing
+	CodeInvalidValue            = "invalid_value"                  // Field value is out of range or unsupported
+	CodeUnknownEventType        = "unknown_event_type"             // Client event type is not supported
+	CodeInvalidRequest          = "invalid_request"                // HTTP request is malformed
+	CodeMethodNotAllowed        = "method_not_allowed"             // HTTP method is not supported
+	CodeInvalidAudio            = "invalid_audio"                  // Audio is not valid base64
+	CodeInvalidAudioFormat      = "invalid_audio_format"           // Audio file is not in a supported format
+	CodeInvalidResponseFormat   = "invalid_response_format"        // response_format is not supported
+	CodeFileTooLarge            = "file_too_large"                 // Upload exceeds the size limit
+	CodeEmptyBuffer             = "empty_buffer"                   // Commit of an empty audio buffer
+	CodeBufferFull              = "buffer_full"                    // Audio buffer is at its limit until committed or cleared
+	CodeInvalidModel            = "invalid_model"                  // Transcription model is unknown
+	CodeUnsupportedLanguage     = "unsupported_language"           // Model does not support the language
+	CodeModelNotAllowed         = "model_not_allowed"              // Tenant may not use the model
+	CodeLanguageNotAllowed      = "language_not_allowed"           // Tenant may not use the language
+	CodeTranslationNotSupported = "translation_not_supported"      // Model cannot translate
+	CodeProviderNotConfigured   = "provider_not_configured"        // Session has no transcription model yet
+	CodeItemNotFound            = "item_not_found"                 // Conversation item does not exist
+	CodeNoActiveResponse        = "no_active_response"             // No response to cancel
+	CodeSessionNotFound         = "session_not_found"              // Session does not exist or can no longer be resumed
+	CodeSessionActive           = "session_active"                 // Session data cannot be erased until the session ends
+	CodeJobNotFound             = "job_not_found"                  // Transcription job does not exist
+	CodeConversationNotFound    = "conversation_not_found"         // Stored conversation does not exist
+	CodeSpeakerNotFound         = "speaker_not_found"              // Speaker profile does not exist
+	CodeEchoCancellationOff     = "echo_cancellation_disabled"     // Reference audio sent without echo cancellation
+	CodeURLNotAllowed           = "url_not_allowed"                // Source URL is refused by the server
+	CodeCallbackNotAllowed      = "callback_not_allowed"           // Callback URL is refused by the server
+	CodeInvalidAPIKey           = "invalid_api_key"                // Credentials are missing or invalid
+	CodeInsufficientScope       = "insufficient_scope"             // Credentials lack a required scope
+	CodeInsufficientQuota       = "insufficient_quota"             // API key has used up its audio quota
+	CodeEventsLost              = "events_lost"                    // Resumed session's events are no longer buffered
+	CodeSessionUpdateFailed     = "session_update_failed"          // Session could not apply the update
+	CodeConfigUnavailable       = "configuration_unavailable"      // Server has no model configuration
+	CodeProviderInitFailed      = "provider_initialization_failed" // Model failed to load
+	CodeBufferError             = "buffer_error"                   // Audio buffer failed
+	CodeTranscriptionFailed     = "transcription_failed"           // Provider failed to transcribe the audio
+
+	// Conditions that clear with time, so the same request may succeed later
+	CodeServerOverloaded     = "server_overloaded"     // Server memory is near capacity
+	CodeRateLimitExceeded    = "rate_limit_exceeded"   // Connection sends events too fast
+	CodeIPBanned             = "ip_banned"             // Client IP is temporarily banned for abuse
+	CodeQueueFull            = "queue_full"            // Too many transcription jobs are queued
+	CodeTranscriptionTimeout = "transcription_timeout" // Provider did not finish in time
+	CodeURLFetchFailed       = "url_fetch_failed"      // Source URL could not be fetched
+	CodeServerError          = "server_error"          // Server failed to handle the request
+)
+
+// retryableCodes are the error codes of conditions that clear with time
+var retryableCodes = map[string]bool{
+	CodeServerOverloaded:     true,
+	CodeRateLimitExceeded:    true,
+	CodeIPBanned:             true,
+	CodeQueueFull:            true,
+	CodeTranscriptionTimeout: true,
+	CodeURLFetchFailed:       true,
+	CodeServerError:          true,
+}
+
+// IsRetryableCode reports whether a request that failed with code may
+// succeed when sent again unchanged, after a backoff
+func IsRetryableCode(code string) bool {
+	return retryableCodes[code]
+}
+
+// NewErrorDetail creates error information, marked retryable by its code
+func NewErrorDetail(errType, code, message string, param interface{}) *ErrorDetail {
+	return &ErrorDetail{
+		Type:      errType,
+		Code:      code,
+		Message:   message,
+		Param:     param,
+		Retryable: IsRetryableCode(code),
+	}
+}
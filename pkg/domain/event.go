@@ -3,6 +3,22 @@ package domain
 // EventType represents the type of event for both client and server.
 type EventType string
 
+// Protocol selects the dialect of the server events sent to a client
+type Protocol string
+
+const (
+	// ProtocolDefault sends GA events, except that transcription sessions use
+	// the beta transcription_session.* events and new items are announced
+	// with conversation.item.created
+	ProtocolDefault Protocol = ""
+	// ProtocolGA sends exactly the events and payloads of the GA Realtime
+	// API, so that unmodified OpenAI client libraries work
+	ProtocolGA Protocol = "ga"
+	// Protocol2024 speaks the 2024-10 beta Realtime API: flat session
+	// objects and the beta names of response events, see BetaSessionConfig
+	Protocol2024 Protocol = "2024-10"
+)
+
 // BaseEvent is the base structure for all events
 type BaseEvent struct {
 	EventID string    `json:"event_id"`
@@ -21,6 +37,9 @@ const (
 	EventResponseCreate           EventType = "response.create"
 	EventResponseCancel           EventType = "response.cancel"
 
+	// Reference audio of echo cancellation, a Gribe extension
+	EventOutputAudioBufferAppend EventType = "output_audio_buffer.append"
+
 	// Server Events
 	EventSessionCreated                EventType = "session.created"
 	EventSessionUpdated                EventType = "session.updated"
@@ -30,6 +49,8 @@ const (
 	EventInputAudioBufferSpeechStarted EventType = "input_audio_buffer.speech_started"
 	EventInputAudioBufferSpeechStopped EventType = "input_audio_buffer.speech_stopped"
 	EventConversationItemCreated       EventType = "conversation.item.created"
+	EventConversationItemAdded         EventType = "conversation.item.added"
+	EventConversationItemDone          EventType = "conversation.item.done"
 	EventConversationItemDeleted       EventType = "conversation.item.deleted"
 	EventConversationItemTruncated     EventType = "conversation.item.truncated"
 	EventResponseCreated               EventType = "response.created"
@@ -49,10 +70,20 @@ const (
 	EventConversationItemInputAudioTranscriptionDelta     EventType = "conversation.item.input_audio_transcription.delta"
 	EventConversationItemInputAudioTranscriptionCompleted EventType = "conversation.item.input_audio_transcription.completed"
 	EventConversationItemInputAudioTranscriptionFailed    EventType = "conversation.item.input_audio_transcription.failed"
+	EventConversationItemInputAudioTranscriptionQueued    EventType = "conversation.item.input_audio_transcription.queued" // Gribe extension
 
 	// Rate Limits
 	EventRateLimitsUpdated EventType = "rate_limits.updated"
 
+	// Session statistics, a Gribe extension sent when stats_interval_ms is set
+	EventSessionStats EventType = "session.stats"
+
+	// Session usage summary, a Gribe extension sent when a session ends
+	EventSessionClosed EventType = "session.closed"
+
+	// Audio quality of committed turns, a Gribe extension sent when audio_quality is set
+	EventInputAudioBufferQuality EventType = "input_audio_buffer.quality"
+
 	// Transcription Session Events (for OpenAI Realtime Transcription API compatibility)
 	EventTranscriptionSessionUpdate  EventType = "transcription_session.update"  // Client event
 	EventTranscriptionSessionCreated EventType = "transcription_session.created" // Server event
@@ -142,8 +142,17 @@ type ConversationItemTruncatedEvent struct {
 // InputAudioBufferCommittedEvent represents input_audio_buffer.committed event
 type InputAudioBufferCommittedEvent struct {
 	BaseEvent
-	ItemID         string  `json:"item_id"`
-	PreviousItemID *string `json:"previous_item_id"`
+	ItemID          string  `json:"item_id"`
+	PreviousItemID  *string `json:"previous_item_id"`
+	AudioDurationMs int     `json:"audio_duration_ms"` // Duration of the committed audio
+}
+
+// InputAudioBufferQualityEvent represents input_audio_buffer.quality event,
+// sent after input_audio_buffer.committed when audio_quality is set
+type InputAudioBufferQualityEvent struct {
+	BaseEvent
+	ItemID string `json:"item_id"`
+	AudioQuality
 }
 
 // InputAudioBufferClearedEvent represents input_audio_buffer.cleared event
@@ -283,10 +292,21 @@ type ResponseOutputAudioDoneEvent struct {
 // ConversationItemInputAudioTranscriptionCompletedEvent represents conversation.item.input_audio_transcription.completed event
 type ConversationItemInputAudioTranscriptionCompletedEvent struct {
 	BaseEvent
-	ItemID       string `json:"item_id"`
-	ContentIndex int    `json:"content_index"`
-	Transcript   string `json:"transcript"`
-	Usage        *Usage `json:"usage"`
+	ItemID          string `json:"item_id"`
+	ContentIndex    int    `json:"content_index"`
+	Transcript      string `json:"transcript"`
+	Usage           *Usage `json:"usage"`
+	AudioDurationMs int    `json:"audio_duration_ms"` // Duration of the transcribed audio
+
+	// Logprobs of the transcript's tokens, with "item.input_audio_transcription.logprobs" in include
+	Logprobs []Logprob `json:"logprobs,omitempty"`
+	// Words and NoSpeechProb, with "item.input_audio_transcription.words" in
+	// include, as reported by the provider
+	Words        []WordTiming `json:"words,omitempty"`
+	NoSpeechProb float64      `json:"no_speech_prob,omitempty"`
+	// Speaker, with "item.input_audio_transcription.speaker" in include, is the
+	// enrolled speaker identified by voice, omitted when none matches
+	Speaker *Speaker `json:"speaker,omitempty"`
 }
 
 // ConversationItemInputAudioTranscriptionDeltaEvent represents conversation.item.input_audio_transcription.delta event
@@ -297,6 +317,40 @@ type ConversationItemInputAudioTranscriptionDeltaEvent struct {
 	Delta        string `json:"delta"`
 }
 
+// ConversationItemInputAudioTranscriptionFailedEvent represents conversation.item.input_audio_transcription.failed event
+type ConversationItemInputAudioTranscriptionFailedEvent struct {
+	BaseEvent
+	ItemID       string       `json:"item_id"`
+	ContentIndex int          `json:"content_index"`
+	Error        *ErrorDetail `json:"error"`
+}
+
+// ConversationItemInputAudioTranscriptionQueuedEvent represents conversation.item.input_audio_transcription.queued event,
+// sent when an item waits for a transcription slot
+type ConversationItemInputAudioTranscriptionQueuedEvent struct {
+	BaseEvent
+	ItemID          string `json:"item_id"`
+	QueuePosition   int    `json:"queue_position"`    // Transcriptions waiting before and including this one
+	EstimatedWaitMs int    `json:"estimated_wait_ms"` // Expected wait from recent transcription times, 0 if unknown
+}
+
+// SessionStatsEvent represents session.stats event
+type SessionStatsEvent struct {
+	BaseEvent
+	BufferedBytes  int     `json:"buffered_bytes"`   // Uncommitted input audio
+	AudioSeconds   float64 `json:"audio_seconds"`    // Audio transcribed so far
+	Items          int     `json:"items"`            // Items in the conversation
+	RealTimeFactor float64 `json:"real_time_factor"` // Transcription time per second of audio in the latest transcription, 0 before the first
+}
+
+// SessionClosedEvent represents session.closed event, the last of a session
+type SessionClosedEvent struct {
+	BaseEvent
+	DurationMs int64        `json:"duration_ms"` // Since the session was created
+	Usage      SessionUsage `json:"usage"`
+	Metadata   map[string]string `json:"metadata,omitempty"` // The session's metadata when it ended
+}
+
 // RateLimitsUpdatedEvent represents rate_limits.updated event
 type RateLimitsUpdatedEvent struct {
 	BaseEvent
@@ -336,7 +390,15 @@ type TranscriptionSessionConfig struct {
 	InputAudioTranscription   *InputAudioTranscriptionConfig   `json:"input_audio_transcription,omitempty"`    // Transcription settings
 	TurnDetection             *TurnDetectionConfig             `json:"turn_detection,omitempty"`               // VAD settings
 	InputAudioNoiseReduction  *InputAudioNoiseReductionConfig  `json:"input_audio_noise_reduction,omitempty"`  // Noise reduction settings
+	InputAudioEchoCancellation *EchoCancellation               `json:"input_audio_echo_cancellation,omitempty"` // Echo cancellation settings
 	Include                   []string                         `json:"include,omitempty"`                      // e.g., ["item.input_audio_transcription.logprobs"]
+	TranscriptionDeltas       *bool                            `json:"transcription_deltas,omitempty"`         // false sends only completed transcripts
+	BufferWindowMs            int                              `json:"buffer_window_ms,omitempty"`             // Keep only this much recent uncommitted audio
+	StatsIntervalMs           int                              `json:"stats_interval_ms,omitempty"`            // Send session.stats this often
+	Diarization               bool                             `json:"diarization,omitempty"`                  // Split turns into an item per speaker
+	AudioQuality              bool                             `json:"audio_quality,omitempty"`                // Send input_audio_buffer.quality per turn
+	ChunkedCommits            bool                             `json:"chunked_commits,omitempty"`              // Commit a full buffer as a chunk instead of buffer_full
+	Metadata                  map[string]string                `json:"metadata,omitempty"`                     // Set by the client, echoed unchanged
 	ExpiresAt                 int64                            `json:"expires_at,omitempty"`                   // Unix timestamp
 }
 
@@ -345,6 +407,8 @@ type InputAudioTranscriptionConfig struct {
 	Model    string `json:"model,omitempty"`    // "whisper-1", "gpt-4o-transcribe", etc.
 	Language string `json:"language,omitempty"` // ISO-639-1 code like "en"
 	Prompt   string `json:"prompt,omitempty"`   // Optional prompt to guide transcription
+	Temperature float64 `json:"temperature,omitempty"` // 0-1, for providers that support it
+	Translate   bool    `json:"translate,omitempty"`   // Translate to English, for providers that support it
 }
 
 // TurnDetectionConfig represents VAD settings in OpenAI format
@@ -353,6 +417,7 @@ type TurnDetectionConfig struct {
 	Threshold         float64 `json:"threshold,omitempty"`          // 0.0-1.0
 	PrefixPaddingMs   int     `json:"prefix_padding_ms,omitempty"`  // milliseconds
 	SilenceDurationMs int     `json:"silence_duration_ms,omitempty"`// milliseconds
+	IntervalMs        int     `json:"interval_ms,omitempty"`        // Audio committed at a time by the interval type
 }
 
 // InputAudioNoiseReductionConfig represents noise reduction settings in OpenAI format
@@ -369,6 +434,14 @@ func NewTranscriptionSessionConfig(session *Session) *TranscriptionSessionConfig
 		ID:        session.ID,
 		ExpiresAt: session.ExpiresAt,
 		Include:   session.Include,
+
+		TranscriptionDeltas: session.TranscriptionDeltas,
+		BufferWindowMs:      session.BufferWindowMs,
+		StatsIntervalMs:     session.StatsIntervalMs,
+		Diarization:         session.Diarization,
+		AudioQuality:        session.AudioQuality,
+		ChunkedCommits:      session.ChunkedCommits,
+		Metadata:            session.Metadata,
 	}
 
 	// Map audio input format
@@ -393,6 +466,8 @@ func NewTranscriptionSessionConfig(session *Session) *TranscriptionSessionConfig
 				Model:    session.Audio.Input.Transcription.Model,
 				Language: session.Audio.Input.Transcription.Language,
 				Prompt:   session.Audio.Input.Transcription.Prompt,
+				Temperature: session.Audio.Input.Transcription.Temperature,
+				Translate:   session.Audio.Input.Transcription.Translate,
 			}
 		}
 
@@ -403,6 +478,7 @@ func NewTranscriptionSessionConfig(session *Session) *TranscriptionSessionConfig
 				Threshold:         session.Audio.Input.TurnDetection.Threshold,
 				PrefixPaddingMs:   session.Audio.Input.TurnDetection.PrefixPaddingMs,
 				SilenceDurationMs: session.Audio.Input.TurnDetection.SilenceDurationMs,
+				IntervalMs:        session.Audio.Input.TurnDetection.IntervalMs,
 			}
 		}
 
@@ -412,14 +488,20 @@ func NewTranscriptionSessionConfig(session *Session) *TranscriptionSessionConfig
 				Type: session.Audio.Input.NoiseReduction.Type,
 			}
 		}
+		config.InputAudioEchoCancellation = session.Audio.Input.EchoCancellation
 	}
 
 	return config
 }
 
 // ApplyToSession applies TranscriptionSessionConfig updates to a Session
-// This converts from the flattened OpenAI format back to the nested structure
-func (tsc *TranscriptionSessionConfig) ApplyToSession(session *Session) {
+// This converts from the flattened OpenAI format back to the nested structure.
+// Fields sent as explicit null clear the corresponding session setting.
+func (tsc *TranscriptionSessionConfig) ApplyToSession(session *Session, sent SessionFields) {
+	// Turn detection enabled by this update starts from the defaults of the
+	// session as it was opened
+	turnDefaults := DefaultTurnDetection(session.Type)
+
 	// Ensure session type is transcription
 	session.Type = "transcription"
 
@@ -447,7 +529,9 @@ func (tsc *TranscriptionSessionConfig) ApplyToSession(session *Session) {
 	}
 
 	// Apply transcription config
-	if tsc.InputAudioTranscription != nil {
+	if sent.Null("input_audio_transcription") {
+		session.Audio.Input.Transcription = nil
+	} else if tsc.InputAudioTranscription != nil {
 		if session.Audio.Input.Transcription == nil {
 			session.Audio.Input.Transcription = &TranscriptionConfig{}
 		}
@@ -460,12 +544,20 @@ func (tsc *TranscriptionSessionConfig) ApplyToSession(session *Session) {
 		if tsc.InputAudioTranscription.Prompt != "" {
 			session.Audio.Input.Transcription.Prompt = tsc.InputAudioTranscription.Prompt
 		}
+		if tsc.InputAudioTranscription.Temperature != 0 {
+			session.Audio.Input.Transcription.Temperature = tsc.InputAudioTranscription.Temperature
+		}
+		if tsc.InputAudioTranscription.Translate {
+			session.Audio.Input.Transcription.Translate = true
+		}
 	}
 
-	// Apply turn detection (VAD)
-	if tsc.TurnDetection != nil {
+	// Apply turn detection (VAD), null disables it
+	if sent.Null("turn_detection") {
+		session.Audio.Input.TurnDetection = nil
+	} else if tsc.TurnDetection != nil {
 		if session.Audio.Input.TurnDetection == nil {
-			session.Audio.Input.TurnDetection = &TurnDetection{}
+			session.Audio.Input.TurnDetection = turnDefaults
 		}
 		if tsc.TurnDetection.Type != "" {
 			session.Audio.Input.TurnDetection.Type = tsc.TurnDetection.Type
@@ -479,10 +571,15 @@ func (tsc *TranscriptionSessionConfig) ApplyToSession(session *Session) {
 		if tsc.TurnDetection.SilenceDurationMs > 0 {
 			session.Audio.Input.TurnDetection.SilenceDurationMs = tsc.TurnDetection.SilenceDurationMs
 		}
+		if tsc.TurnDetection.IntervalMs > 0 {
+			session.Audio.Input.TurnDetection.IntervalMs = tsc.TurnDetection.IntervalMs
+		}
 	}
 
 	// Apply noise reduction
-	if tsc.InputAudioNoiseReduction != nil {
+	if sent.Null("input_audio_noise_reduction") {
+		session.Audio.Input.NoiseReduction = nil
+	} else if tsc.InputAudioNoiseReduction != nil {
 		if session.Audio.Input.NoiseReduction == nil {
 			session.Audio.Input.NoiseReduction = &NoiseReduction{}
 		}
@@ -491,8 +588,38 @@ func (tsc *TranscriptionSessionConfig) ApplyToSession(session *Session) {
 		}
 	}
 
+	// Apply echo cancellation
+	if tsc.InputAudioEchoCancellation != nil || sent.Null("input_audio_echo_cancellation") {
+		session.Audio.Input.EchoCancellation = tsc.InputAudioEchoCancellation
+	}
+
 	// Apply include
-	if len(tsc.Include) > 0 {
+	if len(tsc.Include) > 0 || sent.Sent("include") {
 		session.Include = tsc.Include
 	}
+
+	// Apply delta suppression
+	if tsc.TranscriptionDeltas != nil || sent.Sent("transcription_deltas") {
+		session.TranscriptionDeltas = tsc.TranscriptionDeltas
+	}
+
+	// Apply the rolling buffer window, 0 or null disables it
+	if tsc.BufferWindowMs > 0 || sent.Sent("buffer_window_ms") {
+		session.BufferWindowMs = tsc.BufferWindowMs
+	}
+	if tsc.StatsIntervalMs > 0 || sent.Sent("stats_interval_ms") {
+		session.StatsIntervalMs = tsc.StatsIntervalMs
+	}
+	if tsc.Diarization || sent.Sent("diarization") {
+		session.Diarization = tsc.Diarization
+	}
+	if tsc.AudioQuality || sent.Sent("audio_quality") {
+		session.AudioQuality = tsc.AudioQuality
+	}
+	if tsc.ChunkedCommits || sent.Sent("chunked_commits") {
+		session.ChunkedCommits = tsc.ChunkedCommits
+	}
+	if tsc.Metadata != nil || sent.Sent("metadata") {
+		session.Metadata = tsc.Metadata
+	}
 }
@@ -0,0 +1,25 @@
+package domain
+
+// Limits of session metadata, as for metadata in the OpenAI API
+const (
+	MaxMetadataKeys        = 16
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 512
+)
+
+// ValidateMetadata checks metadata against the limits, naming param as the
+// offending field
+func ValidateMetadata(param string, metadata map[string]string) *ValidationError {
+	if len(metadata) > MaxMetadataKeys {
+		return invalidValue(param, "must have at most %d keys, got %d", MaxMetadataKeys, len(metadata))
+	}
+	for key, value := range metadata {
+		if key == "" || len(key) > MaxMetadataKeyLength {
+			return invalidValue(param, "keys must be 1 to %d characters, got '%s'", MaxMetadataKeyLength, key)
+		}
+		if len(value) > MaxMetadataValueLength {
+			return invalidValue(param+"."+key, "must be at most %d characters, got %d", MaxMetadataValueLength, len(value))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package domain
+
+// betaEventTypes are the server events the GA release renamed, by GA name
+var betaEventTypes = map[EventType]EventType{
+	EventConversationItemAdded:        EventConversationItemCreated,
+	EventResponseOutputTextDelta:      "response.text.delta",
+	EventResponseOutputTextDone:       "response.text.done",
+	EventResponseAudioTranscriptDelta: "response.audio_transcript.delta",
+	EventResponseAudioTranscriptDone:  "response.audio_transcript.done",
+	EventResponseOutputAudioDelta:     "response.audio.delta",
+	EventResponseOutputAudioDone:      "response.audio.done",
+}
+
+// ParseProtocol returns the protocol with the given name, as accepted in
+// configuration and at connection time
+func ParseProtocol(name string) (Protocol, bool) {
+	switch p := Protocol(name); p {
+	case ProtocolDefault, ProtocolGA, Protocol2024:
+		return p, true
+	}
+	return ProtocolDefault, false
+}
+
+// ServerEventType returns the name the protocol gives a server event
+func (p Protocol) ServerEventType(t EventType) EventType {
+	if p == Protocol2024 {
+		if beta, ok := betaEventTypes[t]; ok {
+			return beta
+		}
+	}
+	return t
+}
+
+// BetaSessionConfig represents a realtime session in the flat format of the
+// 2024-10 beta Realtime API. The input audio settings are shared with
+// transcription sessions.
+type BetaSessionConfig struct {
+	TranscriptionSessionConfig
+	Model                   string      `json:"model,omitempty"`
+	Modalities              []string    `json:"modalities,omitempty"`
+	Instructions            string      `json:"instructions,omitempty"`
+	Voice                   string      `json:"voice,omitempty"`
+	OutputAudioFormat       string      `json:"output_audio_format,omitempty"` // "pcm16", "g711_ulaw", "g711_alaw"
+	Tools                   []Tool      `json:"tools,omitempty"`
+	ToolChoice              string      `json:"tool_choice,omitempty"`
+	Temperature             float64     `json:"temperature,omitempty"`
+	MaxResponseOutputTokens interface{} `json:"max_response_output_tokens,omitempty"` // "inf" or number
+}
+
+// BetaSessionUpdateClientEvent represents session.update in the 2024-10 beta protocol
+type BetaSessionUpdateClientEvent struct {
+	BaseEvent
+	Session *BetaSessionConfig `json:"session"`
+}
+
+// BetaSessionCreatedEvent represents session.created in the 2024-10 beta protocol
+type BetaSessionCreatedEvent struct {
+	BaseEvent
+	Session *BetaSessionConfig `json:"session"`
+}
+
+// BetaSessionUpdatedEvent represents session.updated in the 2024-10 beta protocol
+type BetaSessionUpdatedEvent struct {
+	BaseEvent
+	Session *BetaSessionConfig `json:"session"`
+}
+
+// NewBetaSessionConfig flattens a session into the 2024-10 beta format
+func NewBetaSessionConfig(session *Session) *BetaSessionConfig {
+	config := &BetaSessionConfig{
+		TranscriptionSessionConfig: *NewTranscriptionSessionConfig(session),
+		Model:                      session.Model,
+		Modalities:                 session.OutputModalities,
+		Instructions:               session.Instructions,
+		Tools:                      session.Tools,
+		ToolChoice:                 session.ToolChoice,
+		Temperature:                session.Temperature,
+		MaxResponseOutputTokens:    session.MaxOutputTokens,
+	}
+	config.Object = "realtime.session"
+	config.Type = ""
+	if session.Audio != nil && session.Audio.Output != nil {
+		config.Voice = session.Audio.Output.Voice
+		if session.Audio.Output.Format != nil {
+			config.OutputAudioFormat = betaAudioFormat(session.Audio.Output.Format.Type)
+		}
+	}
+	return config
+}
+
+// ApplyToSession applies a beta session.update to a session, keeping its type.
+// Fields sent as explicit null clear the corresponding session setting.
+func (c *BetaSessionConfig) ApplyToSession(session *Session, sent SessionFields) {
+	sessionType := session.Type
+	c.TranscriptionSessionConfig.ApplyToSession(session, sent)
+	session.Type = sessionType
+
+	if len(c.Modalities) > 0 {
+		session.OutputModalities = c.Modalities
+	}
+	if c.Instructions != "" || sent.Sent("instructions") {
+		session.Instructions = c.Instructions
+	}
+	if c.Voice != "" || c.OutputAudioFormat != "" {
+		if session.Audio.Output == nil {
+			session.Audio.Output = &AudioOutput{Speed: 1.0}
+		}
+		if c.Voice != "" {
+			session.Audio.Output.Voice = c.Voice
+		}
+		if c.OutputAudioFormat != "" {
+			session.Audio.Output.Format = &AudioFormat{Type: gaAudioFormat(c.OutputAudioFormat), Rate: 24000}
+		}
+	}
+	if c.Tools != nil {
+		session.Tools = c.Tools
+	}
+	if c.ToolChoice != "" {
+		session.ToolChoice = c.ToolChoice
+	}
+	if c.Temperature > 0 {
+		session.Temperature = c.Temperature
+	}
+	if c.MaxResponseOutputTokens != nil {
+		session.MaxOutputTokens = c.MaxResponseOutputTokens
+	}
+}
+
+// Validate checks beta session.update fields
+func (e *BetaSessionUpdateClientEvent) Validate() *ValidationError {
+	if e.Session == nil {
+		return missingField("session")
+	}
+	if err := (&TranscriptionSessionUpdateClientEvent{Session: &e.Session.TranscriptionSessionConfig}).Validate(); err != nil {
+		return err
+	}
+	if e.Session.OutputAudioFormat != "" && !oneOf(e.Session.OutputAudioFormat, "pcm16", "g711_ulaw", "g711_alaw") {
+		return invalidValue("session.output_audio_format",
+			"must be one of 'pcm16', 'g711_ulaw', 'g711_alaw', got '%s'", e.Session.OutputAudioFormat)
+	}
+	if err := validateVoice("session.voice", e.Session.Voice); err != nil {
+		return err
+	}
+	return validateModalities("session.modalities", e.Session.Modalities)
+}
+
+// betaAudioFormat converts a GA audio format type to its beta name
+func betaAudioFormat(formatType string) string {
+	switch formatType {
+	case "audio/pcmu":
+		return "g711_ulaw"
+	case "audio/pcma":
+		return "g711_alaw"
+	default:
+		return "pcm16"
+	}
+}
+
+// gaAudioFormat converts a beta audio format name to its GA type
+func gaAudioFormat(name string) string {
+	switch name {
+	case "g711_ulaw":
+		return "audio/pcmu"
+	case "g711_alaw":
+		return "audio/pcma"
+	default:
+		return "audio/pcm"
+	}
+}
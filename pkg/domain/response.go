@@ -23,8 +23,9 @@ type ResponseAudio struct {
 	Output *AudioOutput `json:"output"`
 }
 
-// Usage represents token usage
+// Usage represents token usage. Type is "tokens" on transcription usage.
 type Usage struct {
+	Type               string        `json:"type,omitempty"`
 	TotalTokens        int           `json:"total_tokens"`
 	InputTokens        int           `json:"input_tokens"`
 	OutputTokens       int           `json:"output_tokens"`
@@ -32,6 +33,21 @@ type Usage struct {
 	OutputTokenDetails *TokenDetails `json:"output_token_details,omitempty"`
 }
 
+// SessionUsage accounts what a session consumed and produced. Tokens are
+// estimated: audio at 10 tokens per second, text at 4 characters per token.
+type SessionUsage struct {
+	AudioInputSeconds       float64 `json:"audio_input_seconds"`       // Audio appended to the input buffer
+	AudioTranscribedSeconds float64 `json:"audio_transcribed_seconds"` // Audio of completed transcriptions
+	Transcriptions          int     `json:"transcriptions"`            // Completed transcriptions
+	TranscriptCharacters    int     `json:"transcript_characters"`
+	Responses               int     `json:"responses"`
+	InputTokens             int     `json:"input_tokens"`
+	OutputTokens            int     `json:"output_tokens"`
+	TotalTokens             int     `json:"total_tokens"`
+	ClientEvents            int     `json:"client_events"` // Received from the client
+	ServerEvents            int     `json:"server_events"` // Sent to the client
+}
+
 // TokenDetails represents detailed token information
 type TokenDetails struct {
 	TextTokens          int                 `json:"text_tokens"`
@@ -0,0 +1,259 @@
+package domain
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Session represents a WebSocket session configuration
+type Session struct {
+	Type              string         `json:"type"`                   // "realtime" or "transcription"
+	Object            string         `json:"object"`                 // "realtime.session"
+	ID                string         `json:"id"`                     // Session ID
+	Model             string         `json:"model"`                  // Model identifier
+	OutputModalities  []string       `json:"output_modalities"`      // ["audio", "text"]
+	Instructions      string         `json:"instructions,omitempty"` // System instructions
+	Tools             []Tool         `json:"tools"`                  // Available tools
+	ToolChoice        string         `json:"tool_choice"`            // "auto", "none", or tool name
+	MaxOutputTokens   interface{}    `json:"max_output_tokens"`      // "inf" or number
+	Temperature       float64        `json:"temperature,omitempty"`  // 0.6-1.2
+	Tracing           *string        `json:"tracing"`                // "none" or null
+	Prompt            *string        `json:"prompt"`                 // null
+	ExpiresAt         int64          `json:"expires_at"`             // Unix timestamp
+	Audio             *AudioConfig   `json:"audio"`                  // Audio configuration
+	Include           []string       `json:"include,omitempty"`      // e.g., ["item.input_audio_transcription.logprobs"]
+	VoiceSettings     *VoiceSettings `json:"voice_settings,omitempty"`
+
+	// TranscriptionDeltas set to false sends only completed transcripts,
+	// suppressing conversation.item.input_audio_transcription.delta events
+	TranscriptionDeltas *bool `json:"transcription_deltas,omitempty"`
+
+	// BufferWindowMs, when set, keeps only the most recent BufferWindowMs of
+	// uncommitted input audio, for always-listening clients, instead of
+	// failing appends with buffer_full once the buffer is full
+	BufferWindowMs int `json:"buffer_window_ms,omitempty"`
+
+	// StatsIntervalMs, when set, sends a session.stats event with the
+	// session's statistics every StatsIntervalMs
+	StatsIntervalMs int `json:"stats_interval_ms,omitempty"`
+
+	// Diarization, with speaker identification configured, splits each
+	// committed turn into a conversation item per speaker, labelled with
+	// the enrolled or anonymous speaker in the item's speaker
+	Diarization bool `json:"diarization,omitempty"`
+
+	// AudioQuality sends an input_audio_buffer.quality event with the level,
+	// clipping and noise of each committed turn's audio
+	AudioQuality bool `json:"audio_quality,omitempty"`
+
+	// ChunkedCommits commits a full audio buffer as a chunk of a longer
+	// utterance instead of refusing appends with buffer_full. The chunks
+	// overlap, and their transcripts are stitched at the overlap.
+	ChunkedCommits bool `json:"chunked_commits,omitempty"`
+
+	// Metadata is set by the client, e.g. a call or agent ID or consent
+	// flags, and carried unchanged to the session's server events, stored
+	// conversation, usage record and webhooks
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// DeltasEnabled reports whether partial transcription deltas are sent (the default)
+func (s *Session) DeltasEnabled() bool {
+	return s.TranscriptionDeltas == nil || *s.TranscriptionDeltas
+}
+
+// Values of include that add details to completed transcription events.
+// IncludeWords and IncludeSpeaker are extensions.
+const (
+	IncludeLogprobs = "item.input_audio_transcription.logprobs"
+	IncludeWords    = "item.input_audio_transcription.words"
+	IncludeSpeaker  = "item.input_audio_transcription.speaker"
+)
+
+// Includes reports whether include lists value
+func (s *Session) Includes(value string) bool {
+	for _, v := range s.Include {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize fills in the settings the server applies implicitly, so the
+// session reads as the configuration in effect: audio formats get their
+// type and sample rate, audio output its voice and speed.
+func (s *Session) Normalize() {
+	if s.Audio == nil {
+		s.Audio = &AudioConfig{}
+	}
+	if s.Audio.Input == nil {
+		s.Audio.Input = &AudioInput{}
+	}
+	s.Audio.Input.Format = s.Audio.Input.Format.Normalized()
+	if out := s.Audio.Output; out != nil {
+		out.Format = out.Format.Normalized()
+		if out.Voice == "" {
+			out.Voice = DefaultVoice
+		}
+		if out.Speed == 0 {
+			out.Speed = 1.0
+		}
+	}
+}
+
+// VoiceSettings represents voice customization
+type VoiceSettings struct {
+	Voice string  `json:"voice"`
+	Speed float64 `json:"speed,omitempty"`
+}
+
+// SessionState tracks the session runtime state
+type SessionState struct {
+	ID              string
+	Config          *Session
+	Conversation    *ConversationState
+	AudioBuffer     *AudioBuffer
+	CurrentResponse *Response
+	CreatedAt       time.Time
+	LastActivity    time.Time
+	APIKey          string  // Credential the session was opened with, used for quota accounting
+	Tenant          *Tenant // Tenant the session belongs to, nil when no tenant matched
+	Protocol        Protocol // Dialect of the server events sent to the client
+	ClientIP        string   // Address the client connected from, empty when unknown
+
+	// Ctx is cancelled when the client disconnects, ending in-flight work
+	Ctx context.Context
+
+	// inputAudio is the duration of the input audio appended so far, the
+	// clock the items of the session's transcript are placed on
+	inputAudio atomic.Int64
+
+	// activity is the Unix nanoseconds of the latest client event, 0 before the first
+	activity atomic.Int64
+
+	// asr transcribes the session's audio, nil until a model is selected.
+	// Transcriptions read it while session.update may replace it.
+	asr atomic.Pointer[providerRef]
+}
+
+// providerRef holds an ASRProvider for atomic replacement
+type providerRef struct {
+	provider ASRProvider
+}
+
+// ASRProvider returns the provider transcribing the session's audio, nil
+// until a model is selected
+func (s *SessionState) ASRProvider() ASRProvider {
+	if ref := s.asr.Load(); ref != nil {
+		return ref.provider
+	}
+	return nil
+}
+
+// SetASRProvider selects the provider transcribing the session's audio
+func (s *SessionState) SetASRProvider(provider ASRProvider) {
+	s.asr.Store(&providerRef{provider: provider})
+}
+
+// Touch records client activity on the session at now
+func (s *SessionState) Touch(now time.Time) {
+	s.activity.Store(now.UnixNano())
+}
+
+// Idle returns how long the client has been silent at now, since its latest
+// event or since the session was created
+func (s *SessionState) Idle(now time.Time) time.Duration {
+	if last := s.activity.Load(); last != 0 {
+		return now.Sub(time.Unix(0, last))
+	}
+	return now.Sub(s.CreatedAt)
+}
+
+// Context returns the session's context, or a background context for
+// sessions not bound to a connection
+func (s *SessionState) Context() context.Context {
+	if s.Ctx == nil {
+		return context.Background()
+	}
+	return s.Ctx
+}
+
+// NewSession creates a default session configuration
+func NewSession(sessionID, model string) *Session {
+	expiresAt := time.Now().Add(1 * time.Hour).Unix()
+
+	return &Session{
+		Type:             "realtime",
+		Object:           "realtime.session",
+		ID:               sessionID,
+		Model:            model,
+		OutputModalities: []string{"audio"},
+		Instructions:     "You are a helpful, witty, and friendly AI assistant.",
+		Tools:            []Tool{},
+		ToolChoice:       "auto",
+		MaxOutputTokens:  "inf",
+		Temperature:      0.8,
+		Tracing:          nil,
+		Prompt:           nil,
+		ExpiresAt:        expiresAt,
+		Audio: &AudioConfig{
+			Input: &AudioInput{
+				Format: &AudioFormat{
+					Type: "audio/pcm",
+					Rate: 24000,
+				},
+				Transcription:  nil,
+				NoiseReduction: nil,
+				TurnDetection:  DefaultTurnDetection("realtime"),
+			},
+			Output: &AudioOutput{
+				Format: &AudioFormat{
+					Type: "audio/pcm",
+					Rate: 24000,
+				},
+				Voice: "alloy",
+				Speed: 1.0,
+			},
+		},
+	}
+}
+
+// NewTranscriptionSession creates a session configured for transcription-only mode (STT)
+func NewTranscriptionSession(sessionID, model, language string) *Session {
+	expiresAt := time.Now().Add(1 * time.Hour).Unix()
+
+	return &Session{
+		Type:             "transcription",
+		Object:           "realtime.session",
+		ID:               sessionID,
+		Model:            model,
+		OutputModalities: []string{"text"}, // STT only outputs text
+		Instructions:     "",
+		Tools:            []Tool{},
+		ToolChoice:       "none",
+		MaxOutputTokens:  "inf",
+		Temperature:      0.0,
+		Tracing:          nil,
+		Prompt:           nil,
+		ExpiresAt:        expiresAt,
+		Audio: &AudioConfig{
+			Input: &AudioInput{
+				Format: &AudioFormat{
+					Type: "audio/pcm",
+					Rate: 24000,
+				},
+				Transcription: &TranscriptionConfig{
+					Model:    model,
+					Language: language,
+				},
+				NoiseReduction: &NoiseReduction{
+					Type: "near_field",
+				},
+				TurnDetection: DefaultTurnDetection("transcription"),
+			},
+			Output: nil, // No audio output in transcription mode
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// SessionFields records which session fields a client update actually sent,
+// keyed by dotted path (e.g. "instructions", "audio.input.turn_detection").
+// Decoding into Session alone cannot tell an absent field from an explicit
+// null or empty value; merges consult this to clear fields on request.
+type SessionFields map[string]json.RawMessage
+
+// ParseSessionFields collects the fields present under "session" in a client
+// event. Malformed input yields an empty set, leaving error reporting to the
+// regular event decoding.
+func ParseSessionFields(message []byte) SessionFields {
+	var event struct {
+		Session json.RawMessage `json:"session"`
+	}
+	fields := make(SessionFields)
+	if err := json.Unmarshal(message, &event); err == nil {
+		fields.collect("", event.Session)
+	}
+	return fields
+}
+
+func (f SessionFields) collect(prefix string, raw json.RawMessage) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return
+	}
+	for key, value := range object {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		f[path] = value
+		f.collect(path, value)
+	}
+}
+
+// Sent reports whether the update included path, whatever its value
+func (f SessionFields) Sent(path string) bool {
+	_, ok := f[path]
+	return ok
+}
+
+// Null reports whether the update set path to an explicit null
+func (f SessionFields) Null(path string) bool {
+	value, ok := f[path]
+	return ok && bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
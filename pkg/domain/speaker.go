@@ -0,0 +1,33 @@
+package domain
+
+import "errors"
+
+// SpeakerEmbedder computes voiceprints: embeddings of a voice that lie close
+// together for recordings of the same speaker
+type SpeakerEmbedder interface {
+	// Embed returns the voiceprint of mono samples in [-1, 1] at sampleRate
+	Embed(samples []float32, sampleRate int) ([]float32, error)
+
+	// Close releases any resources held by the embedder
+	Close() error
+}
+
+// SpeakerProfile is an enrolled speaker, identified in transcriptions whose
+// voiceprint is close enough to its own
+type SpeakerProfile struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Samples    int       `json:"samples"`    // Enrollment recordings averaged into Voiceprint
+	CreatedAt  int64     `json:"created_at"` // Unix seconds
+	Voiceprint []float32 `json:"voiceprint,omitempty"`
+}
+
+// Speaker is the enrolled speaker identified in a transcribed turn
+type Speaker struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Similarity float64 `json:"similarity"` // Cosine similarity of the voiceprints, up to 1
+}
+
+// ErrSpeakerNotFound is returned for speaker profiles that do not exist
+var ErrSpeakerNotFound = errors.New("speaker profile not found")
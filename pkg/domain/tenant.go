@@ -0,0 +1,21 @@
+package domain
+
+// Tenant holds the restrictions of the tenant a session belongs to,
+// resolved from the connection's credentials
+type Tenant struct {
+	ID               string
+	AllowedModels    []string // Empty means all models
+	AllowedLanguages []string // Empty means all languages
+	RecordDir        string   // Session recording directory, empty uses the server default
+	SharedQuota      bool     // Has a quota of its own, shared by all its keys and JWT subjects
+}
+
+// AllowsModel reports whether the tenant may use the given model
+func (t *Tenant) AllowsModel(model string) bool {
+	return len(t.AllowedModels) == 0 || oneOf(model, t.AllowedModels...)
+}
+
+// AllowsLanguage reports whether the tenant may transcribe the given language
+func (t *Tenant) AllowsLanguage(language string) bool {
+	return len(t.AllowedLanguages) == 0 || oneOf(language, t.AllowedLanguages...)
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// SessionTranscript is the transcript of a session with its items and
+// words placed on the session's input audio, from the first appended sample
+type SessionTranscript struct {
+	Object     string           `json:"object"` // "realtime.transcript"
+	SessionID  string           `json:"session_id"`
+	Text       string           `json:"text"`        // Transcripts of the items, in order
+	DurationMs int              `json:"duration_ms"` // Input audio appended so far
+	Items      []TranscriptItem `json:"items"`
+}
+
+// TranscriptItem is a transcribed input audio item of a SessionTranscript
+type TranscriptItem struct {
+	ItemID     string       `json:"item_id"`
+	Role       string       `json:"role"`
+	Speaker    *Speaker     `json:"speaker,omitempty"`
+	StartMs    int          `json:"start_ms"`
+	EndMs      int          `json:"end_ms"`
+	Transcript string       `json:"transcript"`
+	Words      []WordTiming `json:"words,omitempty"`
+}
+
+// AdvanceInput moves the session's input audio clock on by d, the duration
+// of appended audio, and returns where that audio starts in milliseconds
+func (s *SessionState) AdvanceInput(d time.Duration) int {
+	return int((time.Duration(s.inputAudio.Add(int64(d))) - d).Milliseconds())
+}
+
+// InputMs returns the duration of the input audio appended so far
+func (s *SessionState) InputMs() int {
+	return int(time.Duration(s.inputAudio.Load()).Milliseconds())
+}
+
+// Transcript assembles the session's transcript from the timings of its
+// transcribed input audio items
+func (s *SessionState) Transcript() *SessionTranscript {
+	transcript := &SessionTranscript{
+		Object:     "realtime.transcript",
+		SessionID:  s.ID,
+		DurationMs: s.InputMs(),
+		Items:      []TranscriptItem{},
+	}
+	var text []string
+	for _, item := range s.Conversation.List() {
+		if len(item.Content) == 0 || item.Content[0].Type != "input_audio" || item.Content[0].Transcript == "" {
+			continue
+		}
+		entry := TranscriptItem{
+			ItemID:     item.ID,
+			Role:       item.Role,
+			Speaker:    item.Speaker,
+			StartMs:    item.AudioStartMs,
+			EndMs:      item.AudioEndMs,
+			Transcript: item.Content[0].Transcript,
+		}
+		for _, word := range item.Words {
+			word.StartMs += item.AudioStartMs
+			word.EndMs += item.AudioStartMs
+			entry.Words = append(entry.Words, word)
+		}
+		transcript.Items = append(transcript.Items, entry)
+		text = append(text, strings.TrimSpace(entry.Transcript))
+	}
+	transcript.Text = strings.Join(text, " ")
+	return transcript
+}
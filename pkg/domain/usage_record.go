@@ -0,0 +1,20 @@
+package domain
+
+// UsageRecord is the usage of an ended session, exported for billing
+type UsageRecord struct {
+	SessionID  string            `json:"session_id"`
+	Type       string            `json:"type"`         // "realtime" or "transcription"
+	APIKeyHash string            `json:"api_key_hash"` // OwnerHash of the credentials that opened the session
+	TenantID   string            `json:"tenant_id,omitempty"`
+	Model      string            `json:"model,omitempty"` // Transcription model when the session ended
+	StartedAt  int64             `json:"started_at"`      // Unix seconds the session started
+	EndedAt    int64             `json:"ended_at"`        // Unix seconds the session ended
+	Usage      SessionUsage      `json:"usage"`
+	Metadata   map[string]string `json:"metadata,omitempty"` // Session metadata set by the client
+}
+
+// UsageSink receives the usage record of each ended session
+type UsageSink interface {
+	// Write delivers a record, returning an error if it may be lost
+	Write(record *UsageRecord) error
+}
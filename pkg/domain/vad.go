@@ -60,6 +60,23 @@ type VADConfig struct {
 
 	// Channels - number of audio channels (usually 1 for mono)
 	Channels int `json:"channels"`
+
+	// Encoding of the audio samples: EncodingPCM16 (default), EncodingG711Ulaw or EncodingG711Alaw
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// ApplyFormat sets the sample rate and encoding from the session's input audio format
+func (c *VADConfig) ApplyFormat(format *AudioFormat) {
+	c.SampleRate = format.SampleRate()
+	c.Encoding = format.Encoding()
+}
+
+// BytesPerSample returns the size of one sample in the configured encoding
+func (c *VADConfig) BytesPerSample() int {
+	if c.Encoding == EncodingG711Ulaw || c.Encoding == EncodingG711Alaw {
+		return 1
+	}
+	return 2
 }
 
 // NewDefaultVADConfig creates a default VAD configuration
@@ -0,0 +1,292 @@
+package domain
+
+import "fmt"
+
+// ValidationError describes a client event field that failed validation.
+// Code and Param map directly onto ErrorDetail.
+type ValidationError struct {
+	Code    string // "invalid_json", "unknown_field", "invalid_type", "missing_field", "invalid_value"
+	Param   string // Dotted path of the offending field, empty if not field-specific
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Param == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Param, e.Message)
+}
+
+// minStatsIntervalMs is the shortest interval between session.stats events
+const minStatsIntervalMs = 1000
+
+// Validator is implemented by client events that check their own fields
+type Validator interface {
+	Validate() *ValidationError
+}
+
+func missingField(param string) *ValidationError {
+	return &ValidationError{Code: CodeMissingField, Param: param, Message: fmt.Sprintf("%s is required", param)}
+}
+
+func invalidValue(param, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Code: CodeInvalidValue, Param: param, Message: fmt.Sprintf(format, args...)}
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks session.update fields
+func (e *SessionUpdateClientEvent) Validate() *ValidationError {
+	if e.Session == nil {
+		return missingField("session")
+	}
+	if e.Session.Type != "" && !oneOf(e.Session.Type, "realtime", "transcription") {
+		return invalidValue("session.type", "must be 'realtime' or 'transcription', got '%s'", e.Session.Type)
+	}
+	if e.Session.BufferWindowMs < 0 {
+		return invalidValue("session.buffer_window_ms", "must not be negative, got %d", e.Session.BufferWindowMs)
+	}
+	if err := validateStatsInterval(e.Session.StatsIntervalMs); err != nil {
+		return err
+	}
+	if err := validateModalities("session.output_modalities", e.Session.OutputModalities); err != nil {
+		return err
+	}
+	if err := ValidateMetadata("session.metadata", e.Session.Metadata); err != nil {
+		return err
+	}
+	if e.Session.Audio != nil && e.Session.Audio.Input != nil {
+		if err := e.Session.Audio.Input.validate("session.audio.input"); err != nil {
+			return err
+		}
+	}
+	if e.Session.Audio != nil && e.Session.Audio.Output != nil {
+		return e.Session.Audio.Output.validate("session.audio.output")
+	}
+	return nil
+}
+
+// validateModalities checks that modalities only names "text" and "audio"
+func validateModalities(param string, modalities []string) *ValidationError {
+	for _, modality := range modalities {
+		if !oneOf(modality, "text", "audio") {
+			return invalidValue(param, "must only contain 'text' and 'audio', got '%s'", modality)
+		}
+	}
+	return nil
+}
+
+// validateVoice checks that voice, if set, is one of Voices
+func validateVoice(param, voice string) *ValidationError {
+	if voice != "" && !oneOf(voice, Voices...) {
+		return invalidValue(param, "unsupported voice '%s'", voice)
+	}
+	return nil
+}
+
+// validateStatsInterval checks stats_interval_ms, which is 0 or at least
+// minStatsIntervalMs so stats cannot flood the connection
+func validateStatsInterval(ms int) *ValidationError {
+	if ms != 0 && ms < minStatsIntervalMs {
+		return invalidValue("session.stats_interval_ms", "must be 0 or at least %d, got %d", minStatsIntervalMs, ms)
+	}
+	return nil
+}
+
+func (in *AudioInput) validate(prefix string) *ValidationError {
+	if in.Format != nil {
+		if err := in.Format.validate(prefix + ".format"); err != nil {
+			return err
+		}
+	}
+	if in.Transcription != nil {
+		if err := in.Transcription.validate(prefix + ".transcription"); err != nil {
+			return err
+		}
+	}
+	if in.NoiseReduction != nil && in.NoiseReduction.Type != "" &&
+		!oneOf(in.NoiseReduction.Type, "near_field", "far_field") {
+		return invalidValue(prefix+".noise_reduction.type",
+			"must be 'near_field' or 'far_field', got '%s'", in.NoiseReduction.Type)
+	}
+	if err := validateEchoTail(prefix+".echo_cancellation.tail_ms", in.EchoCancellation); err != nil {
+		return err
+	}
+	if in.TurnDetection != nil {
+		return in.TurnDetection.validate(prefix + ".turn_detection")
+	}
+	return nil
+}
+
+func (t *TranscriptionConfig) validate(prefix string) *ValidationError {
+	if t.Temperature < 0 || t.Temperature > 1 {
+		return invalidValue(prefix+".temperature", "must be between 0.0 and 1.0, got %g", t.Temperature)
+	}
+	return nil
+}
+
+func (out *AudioOutput) validate(prefix string) *ValidationError {
+	if out.Format != nil {
+		if err := out.Format.validate(prefix + ".format"); err != nil {
+			return err
+		}
+	}
+	if err := validateVoice(prefix+".voice", out.Voice); err != nil {
+		return err
+	}
+	if out.Speed != 0 && (out.Speed < MinOutputSpeed || out.Speed > MaxOutputSpeed) {
+		return invalidValue(prefix+".speed", "must be between %g and %g, got %g", MinOutputSpeed, MaxOutputSpeed, out.Speed)
+	}
+	return nil
+}
+
+// validate checks the format's type and rate. G.711 is only sent at 8kHz.
+func (f *AudioFormat) validate(prefix string) *ValidationError {
+	if f.Type != "" && !oneOf(f.Type, "audio/pcm", "audio/pcmu", "audio/pcma") {
+		return invalidValue(prefix+".type", "must be one of 'audio/pcm', 'audio/pcmu', 'audio/pcma', got '%s'", f.Type)
+	}
+	if f.Rate < 0 {
+		return invalidValue(prefix+".rate", "must be positive, got %d", f.Rate)
+	}
+	if f.Rate != 0 && f.Encoding() != EncodingPCM16 && f.Rate != 8000 {
+		return invalidValue(prefix+".rate", "must be 8000 for '%s', got %d", f.Type, f.Rate)
+	}
+	return nil
+}
+
+func (td *TurnDetection) validate(prefix string) *ValidationError {
+	if td.Type != "" && !oneOf(td.Type, "server_vad", "semantic_vad", TurnDetectionInterval) {
+		return invalidValue(prefix+".type", "must be 'server_vad', 'semantic_vad' or 'interval', got '%s'", td.Type)
+	}
+	if err := validateCommitInterval(prefix+".interval_ms", td.IntervalMs); err != nil {
+		return err
+	}
+	if td.Threshold < 0 || td.Threshold > 1 {
+		return invalidValue(prefix+".threshold", "must be between 0.0 and 1.0, got %g", td.Threshold)
+	}
+	if td.PrefixPaddingMs < 0 {
+		return invalidValue(prefix+".prefix_padding_ms", "must not be negative, got %d", td.PrefixPaddingMs)
+	}
+	if td.SilenceDurationMs < 0 {
+		return invalidValue(prefix+".silence_duration_ms", "must not be negative, got %d", td.SilenceDurationMs)
+	}
+	switch timeout := td.IdleTimeoutMs.(type) {
+	case nil:
+	case float64:
+		if timeout < 0 {
+			return invalidValue(prefix+".idle_timeout_ms", "must not be negative, got %g", timeout)
+		}
+	default:
+		return &ValidationError{Code: CodeInvalidType, Param: prefix + ".idle_timeout_ms",
+			Message: "must be a number or null"}
+	}
+	return nil
+}
+
+// Validate checks transcription_session.update fields
+func (e *TranscriptionSessionUpdateClientEvent) Validate() *ValidationError {
+	if e.Session == nil {
+		return missingField("session")
+	}
+	if e.Session.InputAudioFormat != "" && !oneOf(e.Session.InputAudioFormat, "pcm16", "g711_ulaw", "g711_alaw") {
+		return invalidValue("session.input_audio_format",
+			"must be one of 'pcm16', 'g711_ulaw', 'g711_alaw', got '%s'", e.Session.InputAudioFormat)
+	}
+	if e.Session.BufferWindowMs < 0 {
+		return invalidValue("session.buffer_window_ms", "must not be negative, got %d", e.Session.BufferWindowMs)
+	}
+	if err := validateStatsInterval(e.Session.StatsIntervalMs); err != nil {
+		return err
+	}
+	if err := validateEchoTail("session.input_audio_echo_cancellation.tail_ms", e.Session.InputAudioEchoCancellation); err != nil {
+		return err
+	}
+	if err := ValidateMetadata("session.metadata", e.Session.Metadata); err != nil {
+		return err
+	}
+	if t := e.Session.InputAudioTranscription; t != nil && (t.Temperature < 0 || t.Temperature > 1) {
+		return invalidValue("session.input_audio_transcription.temperature", "must be between 0.0 and 1.0, got %g", t.Temperature)
+	}
+	if td := e.Session.TurnDetection; td != nil {
+		if td.Type != "" && !oneOf(td.Type, "server_vad", "semantic_vad", TurnDetectionInterval) {
+			return invalidValue("session.turn_detection.type",
+				"must be 'server_vad', 'semantic_vad' or 'interval', got '%s'", td.Type)
+		}
+		if err := validateCommitInterval("session.turn_detection.interval_ms", td.IntervalMs); err != nil {
+			return err
+		}
+		if td.Threshold < 0 || td.Threshold > 1 {
+			return invalidValue("session.turn_detection.threshold", "must be between 0.0 and 1.0, got %g", td.Threshold)
+		}
+	}
+	return nil
+}
+
+func validateCommitInterval(param string, ms int) *ValidationError {
+	if ms != 0 && ms < MinCommitIntervalMs {
+		return invalidValue(param, "must be at least %d, got %d", MinCommitIntervalMs, ms)
+	}
+	return nil
+}
+
+func validateEchoTail(param string, ec *EchoCancellation) *ValidationError {
+	if ec != nil && (ec.TailMs < 0 || ec.TailMs > MaxEchoTailMs) {
+		return invalidValue(param, "must be between 0 and %d, got %d", MaxEchoTailMs, ec.TailMs)
+	}
+	return nil
+}
+
+// Validate checks input_audio_buffer.append fields
+func (e *InputAudioBufferAppendEvent) Validate() *ValidationError {
+	if e.Audio == "" {
+		return missingField("audio")
+	}
+	return nil
+}
+
+// Validate checks conversation.item.create fields
+func (e *ConversationItemCreateClientEvent) Validate() *ValidationError {
+	if e.Item == nil {
+		return missingField("item")
+	}
+	if e.Item.Type == "" {
+		return missingField("item.type")
+	}
+	if !oneOf(e.Item.Type, "message", "function_call", "function_call_output") {
+		return invalidValue("item.type",
+			"must be one of 'message', 'function_call', 'function_call_output', got '%s'", e.Item.Type)
+	}
+	if e.Item.Type == "message" && !oneOf(e.Item.Role, "user", "assistant", "system") {
+		return invalidValue("item.role", "must be one of 'user', 'assistant', 'system', got '%s'", e.Item.Role)
+	}
+	return nil
+}
+
+// Validate checks conversation.item.delete fields
+func (e *ConversationItemDeleteEvent) Validate() *ValidationError {
+	if e.ItemID == "" {
+		return missingField("item_id")
+	}
+	return nil
+}
+
+// Validate checks conversation.item.truncate fields
+func (e *ConversationItemTruncateEvent) Validate() *ValidationError {
+	if e.ItemID == "" {
+		return missingField("item_id")
+	}
+	if e.ContentIndex < 0 {
+		return invalidValue("content_index", "must not be negative, got %d", e.ContentIndex)
+	}
+	if e.AudioEndMs < 0 {
+		return invalidValue("audio_end_ms", "must not be negative, got %d", e.AudioEndMs)
+	}
+	return nil
+}
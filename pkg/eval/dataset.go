@@ -0,0 +1,97 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Sample is one utterance of a dataset: a 16-bit PCM WAV file and what is
+// said in it
+type Sample struct {
+	ID       string `json:"id"`       // Defaults to the audio file's name
+	Audio    string `json:"audio"`    // Path of the WAV file, relative to the manifest
+	Text     string `json:"text"`     // Reference transcript
+	Language string `json:"language"` // Overrides Options.Language when set
+}
+
+// LoadDataset reads the samples of a dataset, either a JSONL manifest of
+// samples or a directory of WAV files each with its reference transcript in
+// a .txt file of the same name. Audio paths are resolved, and files are only
+// read when transcribed.
+func LoadDataset(path string) ([]Sample, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var samples []Sample
+	if info.IsDir() {
+		samples, err = readDirectory(path)
+	} else {
+		samples, err = readManifest(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("dataset %s has no samples", path)
+	}
+	return samples, nil
+}
+
+// readManifest reads a JSONL manifest, one Sample per line
+func readManifest(path string) ([]Sample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var sample Sample
+		if err := json.Unmarshal([]byte(text), &sample); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if sample.Audio == "" {
+			return nil, fmt.Errorf("%s:%d: audio is required", path, line)
+		}
+		if !filepath.IsAbs(sample.Audio) {
+			sample.Audio = filepath.Join(filepath.Dir(path), sample.Audio)
+		}
+		if sample.ID == "" {
+			sample.ID = strings.TrimSuffix(filepath.Base(sample.Audio), filepath.Ext(sample.Audio))
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// readDirectory pairs the WAV files of a directory with their transcripts
+func readDirectory(dir string) ([]Sample, error) {
+	audio, err := filepath.Glob(filepath.Join(dir, "*.wav"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(audio)
+	samples := make([]Sample, 0, len(audio))
+	for _, path := range audio {
+		id := strings.TrimSuffix(filepath.Base(path), ".wav")
+		text, err := os.ReadFile(filepath.Join(dir, id+".txt"))
+		if err != nil {
+			return nil, fmt.Errorf("reference transcript of %s: %w", path, err)
+		}
+		samples = append(samples, Sample{ID: id, Audio: path, Text: strings.TrimSpace(string(text))})
+	}
+	return samples, nil
+}
@@ -0,0 +1,202 @@
+// Package eval measures the accuracy and speed of ASR models on a labeled
+// dataset: it transcribes every sample with each model, through a running
+// server or in process, and reports word and character error rates (WER,
+// CER) and latencies per model.
+//
+//	samples, err := eval.LoadDataset("testset/manifest.jsonl")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	report := eval.Run(ctx, samples, &eval.ServerTranscriber{URL: "http://localhost:8080"},
+//		eval.Options{Models: []string{"zipformer", "whisper-base"}})
+//	report.WriteText(os.Stdout)
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/wer"
+)
+
+// Options configure an evaluation
+type Options struct {
+	Models   []string // Models to evaluate, each on every sample
+	Language string   // Language of samples that name none, empty for each model's default
+
+	// Timeout bounds the transcription of one sample, 0 for no limit
+	Timeout time.Duration
+
+	// Progress, when set, is called after each transcription
+	Progress func(model string, result *Result)
+}
+
+// Result is the transcription of one sample by one model
+type Result struct {
+	ID         string  `json:"id"`
+	Reference  string  `json:"reference"`
+	Transcript string  `json:"transcript"`
+	Error      string  `json:"error,omitempty"`
+	WER        float64 `json:"wer"`
+	CER        float64 `json:"cer"`
+	AudioMs    int     `json:"audio_ms"`
+	LatencyMs  int     `json:"latency_ms"`
+}
+
+// Latency summarizes the transcription latencies of a model
+type Latency struct {
+	MeanMs int `json:"mean_ms"`
+	P50Ms  int `json:"p50_ms"`
+	P95Ms  int `json:"p95_ms"`
+	MaxMs  int `json:"max_ms"`
+}
+
+// ModelReport is the evaluation of one model. Error rates are over the words
+// and characters of all transcribed samples, so long samples weigh more.
+type ModelReport struct {
+	Model         string   `json:"model"`
+	Samples       int      `json:"samples"`
+	Failed        int      `json:"failed"` // Not transcribed, left out of the rates
+	WER           float64  `json:"wer"`
+	CER           float64  `json:"cer"`
+	Words         int      `json:"words"` // Of the references
+	Substitutions int      `json:"substitutions"`
+	Deletions     int      `json:"deletions"`
+	Insertions    int      `json:"insertions"`
+	Latency       Latency  `json:"latency"`
+	AudioSeconds  float64  `json:"audio_seconds"`
+	RTF           float64  `json:"rtf"` // Real-time factor: transcription time over audio duration
+	Results       []Result `json:"results"`
+}
+
+// Report is the evaluation of all models
+type Report struct {
+	Dataset string        `json:"dataset,omitempty"`
+	Models  []ModelReport `json:"models"`
+}
+
+// Run transcribes every sample with each model in turn, one at a time so
+// latencies are not skewed by contention, and scores the transcripts. Samples
+// whose audio cannot be read fail for every model.
+func Run(ctx context.Context, samples []Sample, transcriber Transcriber, opts Options) *Report {
+	report := &Report{}
+	for _, model := range opts.Models {
+		report.Models = append(report.Models, runModel(ctx, samples, transcriber, model, &opts))
+	}
+	return report
+}
+
+func runModel(ctx context.Context, samples []Sample, transcriber Transcriber, model string, opts *Options) ModelReport {
+	report := ModelReport{Model: model, Samples: len(samples)}
+	var words, chars wer.Result
+	var latencies []int
+	var took, audioLength time.Duration
+	for _, sample := range samples {
+		result := Result{ID: sample.ID, Reference: sample.Text}
+		transcript, duration, elapsed, err := transcribe(ctx, transcriber, sample, model, opts)
+		result.AudioMs = int(duration.Milliseconds())
+		if err != nil {
+			result.Error = err.Error()
+			report.Failed++
+		} else {
+			sampleWords, sampleChars := wer.Words(sample.Text, transcript), wer.Chars(sample.Text, transcript)
+			words, chars = words.Add(sampleWords), chars.Add(sampleChars)
+			result.Transcript = transcript
+			result.WER, result.CER = sampleWords.Rate(), sampleChars.Rate()
+			result.LatencyMs = int(elapsed.Milliseconds())
+			latencies = append(latencies, result.LatencyMs)
+			took += elapsed
+			audioLength += duration
+		}
+		report.Results = append(report.Results, result)
+		if opts.Progress != nil {
+			opts.Progress(model, &result)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	report.WER, report.CER = words.Rate(), chars.Rate()
+	report.Words = words.Reference
+	report.Substitutions, report.Deletions, report.Insertions = words.Substitutions, words.Deletions, words.Insertions
+	report.Latency = summarize(latencies)
+	report.AudioSeconds = audioLength.Seconds()
+	if audioLength > 0 {
+		report.RTF = took.Seconds() / audioLength.Seconds()
+	}
+	return report
+}
+
+// transcribe transcribes one sample, returning its audio duration and the
+// time the transcription took
+func transcribe(ctx context.Context, transcriber Transcriber, sample Sample, model string,
+	opts *Options) (string, time.Duration, time.Duration, error) {
+	audio, err := ReadAudio(sample.Audio)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	language := sample.Language
+	if language == "" {
+		language = opts.Language
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	started := time.Now()
+	transcript, err := transcriber.Transcribe(ctx, audio, model, language)
+	if err != nil {
+		log.Printf("[WARN] Transcribing %s with %s failed: %v", sample.ID, model, err)
+	}
+	return transcript, audio.Duration(), time.Since(started), err
+}
+
+// summarize returns the mean and percentiles of latencies in milliseconds
+func summarize(latencies []int) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	sorted := append([]int(nil), latencies...)
+	sort.Ints(sorted)
+	sum := 0
+	for _, ms := range sorted {
+		sum += ms
+	}
+	percentile := func(p int) int {
+		// Nearest rank: the smallest latency at least p% of them do not exceed
+		return sorted[max((p*len(sorted)+99)/100-1, 0)]
+	}
+	return Latency{
+		MeanMs: sum / len(sorted),
+		P50Ms:  percentile(50),
+		P95Ms:  percentile(95),
+		MaxMs:  sorted[len(sorted)-1],
+	}
+}
+
+// WriteJSON writes the report, with every sample's result, as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes a table of the models' scores
+func (r *Report) WriteText(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "MODEL\tSAMPLES\tFAILED\tWER\tCER\tS/D/I\tLATENCY MEAN\tP50\tP95\tMAX\tRTF")
+	for _, m := range r.Models {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%.2f%%\t%.2f%%\t%d/%d/%d\t%dms\t%dms\t%dms\t%dms\t%.3f\n",
+			m.Model, m.Samples, m.Failed, m.WER*100, m.CER*100, m.Substitutions, m.Deletions, m.Insertions,
+			m.Latency.MeanMs, m.Latency.P50Ms, m.Latency.P95Ms, m.Latency.MaxMs, m.RTF)
+	}
+	return table.Flush()
+}
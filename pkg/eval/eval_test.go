@@ -0,0 +1,153 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/mock"
+	"github.com/aira-id/gribe/internal/pkg/wav"
+	"github.com/aira-id/gribe/pkg/gribe"
+)
+
+// writeWAV writes a second of silent 48kHz stereo audio
+func writeWAV(t *testing.T, path string) {
+	t.Helper()
+	audio := make([]byte, 48000*2*2)
+	data := append(wav.Header(wav.Format{SampleRate: 48000, Channels: 2, BitsPerSample: 16}, len(audio)), audio...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDataset(t *testing.T) {
+	dir := t.TempDir()
+	writeWAV(t, filepath.Join(dir, "a.wav"))
+	writeWAV(t, filepath.Join(dir, "b.wav"))
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello world\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("hello there"), 0644)
+
+	samples, err := LoadDataset(dir)
+	if err != nil {
+		t.Fatalf("LoadDataset failed: %v", err)
+	}
+	if len(samples) != 2 || samples[0].ID != "a" || samples[0].Text != "hello world" || samples[1].Audio != filepath.Join(dir, "b.wav") {
+		t.Errorf("Unexpected samples of a directory %+v", samples)
+	}
+
+	manifest := filepath.Join(dir, "manifest.jsonl")
+	os.WriteFile(manifest, []byte(`{"audio":"a.wav","text":"hello world","language":"en"}`+"\n\n"+
+		`{"id":"second","audio":"b.wav","text":"hello there"}`+"\n"), 0644)
+	samples, err = LoadDataset(manifest)
+	if err != nil {
+		t.Fatalf("LoadDataset failed: %v", err)
+	}
+	if len(samples) != 2 || samples[0].ID != "a" || samples[0].Language != "en" || samples[0].Audio != filepath.Join(dir, "a.wav") ||
+		samples[1].ID != "second" {
+		t.Errorf("Unexpected samples of a manifest %+v", samples)
+	}
+
+	os.WriteFile(manifest, []byte(`{"text":"no audio"}`), 0644)
+	if _, err := LoadDataset(manifest); err == nil {
+		t.Error("Expected a sample without audio to be refused")
+	}
+}
+
+func TestRunInProcess(t *testing.T) {
+	dir := t.TempDir()
+	writeWAV(t, filepath.Join(dir, "a.wav"))
+	writeWAV(t, filepath.Join(dir, "b.wav"))
+	samples := []Sample{
+		{ID: "a", Audio: filepath.Join(dir, "a.wav"), Text: "Hello world."},
+		{ID: "b", Audio: filepath.Join(dir, "b.wav"), Text: "hello there"},
+		{ID: "missing", Audio: filepath.Join(dir, "missing.wav"), Text: "gone"},
+	}
+
+	provider := mock.New()
+	provider.SetDelay(0, 0)
+	provider.SetMockResults([]string{"hello", " world"})
+	transcriber := NewProviderTranscriber(&gribe.ASRConfig{Models: map[string]gribe.ModelConfig{
+		"local": {Provider: "in-process", Languages: []string{"en"}},
+	}}, map[string]gribe.ASRFactory{
+		"in-process": func(*gribe.ASRConfig, string, *gribe.ModelConfig) (gribe.ASRProvider, error) { return provider, nil },
+	})
+	defer transcriber.Close()
+
+	var progress []string
+	report := Run(context.Background(), samples, transcriber, Options{
+		Models:   []string{"local", "unknown"},
+		Progress: func(model string, result *Result) { progress = append(progress, model+"/"+result.ID) },
+	})
+	if len(report.Models) != 2 || len(progress) != 6 {
+		t.Fatalf("Expected every sample evaluated with both models, got %+v, %v", report, progress)
+	}
+	local := report.Models[0]
+	if local.Samples != 3 || local.Failed != 1 || local.Words != 4 || local.WER != 0.25 || local.Substitutions != 1 {
+		t.Errorf("Unexpected scores %+v", local)
+	}
+	if local.AudioSeconds != 2 || local.Results[0].AudioMs != 1000 || local.Results[0].WER != 0 || local.Results[1].WER != 0.5 {
+		t.Errorf("Unexpected results %+v", local.Results)
+	}
+	if local.Results[2].Error == "" {
+		t.Error("Expected the missing sample to fail")
+	}
+	if config := provider.LastConfig(); config.Language != "en" {
+		t.Errorf("Expected the model's first language by default, got %q", config.Language)
+	}
+	if unknown := report.Models[1]; unknown.Failed != 3 || unknown.WER != 0 {
+		t.Errorf("Expected an unknown model to fail every sample, got %+v", unknown)
+	}
+
+	var text strings.Builder
+	if err := report.WriteText(&text); err != nil || !strings.Contains(text.String(), "local") || !strings.Contains(text.String(), "25.00%") {
+		t.Errorf("Unexpected text report %q (%v)", text.String(), err)
+	}
+	var decoded Report
+	var encoded strings.Builder
+	report.WriteJSON(&encoded)
+	if err := json.Unmarshal([]byte(encoded.String()), &decoded); err != nil || decoded.Models[0].WER != 0.25 {
+		t.Errorf("Unexpected JSON report %s (%v)", encoded.String(), err)
+	}
+}
+
+func TestServerTranscriber(t *testing.T) {
+	dir := t.TempDir()
+	writeWAV(t, filepath.Join(dir, "a.wav"))
+	audio, err := ReadAudio(filepath.Join(dir, "a.wav"))
+	if err != nil {
+		t.Fatalf("ReadAudio failed: %v", err)
+	}
+	if audio.Duration() != time.Second {
+		t.Errorf("Expected a second of audio, got %v", audio.Duration())
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"Invalid API key"}}`))
+			return
+		}
+		if _, _, err := r.FormFile("file"); err != nil || r.FormValue("model") != "zipformer" || r.FormValue("language") != "en" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"text":"Hello world."}`))
+	}))
+	defer server.Close()
+
+	transcriber := &ServerTranscriber{URL: server.URL + "/", APIKey: "key"}
+	transcript, err := transcriber.Transcribe(context.Background(), audio, "zipformer", "en")
+	if err != nil || transcript != "Hello world." {
+		t.Errorf("Expected the server's transcript, got %q (%v)", transcript, err)
+	}
+	transcriber.APIKey = "wrong"
+	if _, err := transcriber.Transcribe(context.Background(), audio, "zipformer", "en"); err == nil || !strings.Contains(err.Error(), "Invalid API key") {
+		t.Errorf("Expected the server's error, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aira-id/gribe/internal/delivery/gateway"
+	"github.com/aira-id/gribe/internal/pkg/wav"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/aira-id/gribe/pkg/gribe"
+)
+
+// Audio is the WAV file of a sample, read for transcription
+type Audio struct {
+	Name       string // File name
+	WAV        []byte // The whole file
+	PCM        []byte // Its little-endian PCM16 samples, within WAV
+	SampleRate int
+	Channels   int
+}
+
+// ReadAudio reads a 16-bit PCM WAV file
+func ReadAudio(path string) (*Audio, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+	format, err := wav.ReadHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	pcm := data[len(data)-r.Len():]
+	return &Audio{
+		Name:       filepath.Base(path),
+		WAV:        data,
+		PCM:        pcm[:len(pcm)/2*2],
+		SampleRate: format.SampleRate,
+		Channels:   format.Channels,
+	}, nil
+}
+
+// Duration returns the length of the audio
+func (a *Audio) Duration() time.Duration {
+	frames := len(a.PCM) / (2 * a.Channels)
+	return time.Duration(frames) * time.Second / time.Duration(a.SampleRate)
+}
+
+// Transcriber transcribes the audio of samples with a model
+type Transcriber interface {
+	// Transcribe returns the transcript of audio in language, which may be
+	// empty for the model's default
+	Transcribe(ctx context.Context, audio *Audio, model, language string) (string, error)
+}
+
+// ServerTranscriber transcribes through the batch transcription endpoint of
+// a running server, so latencies include its whole pipeline: upload, server
+// VAD turns and queueing for the model
+type ServerTranscriber struct {
+	URL    string // Base URL of the server, e.g. http://localhost:8080
+	APIKey string // Sent as a Bearer token when set
+	Client *http.Client
+}
+
+// Transcribe posts audio to /v1/audio/transcriptions
+func (s *ServerTranscriber) Transcribe(ctx context.Context, audio *Audio, model, language string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", audio.Name)
+	if err != nil {
+		return "", err
+	}
+	part.Write(audio.WAV)
+	form.WriteField("model", model)
+	if language != "" {
+		form.WriteField("language", language)
+	}
+	form.WriteField("response_format", "json")
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(s.URL, "/")+"/v1/audio/transcriptions", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error domain.ErrorDetail `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, &failure) == nil && failure.Error.Message != "" {
+			return "", fmt.Errorf("server answered %d: %s", resp.StatusCode, failure.Error.Message)
+		}
+		return "", fmt.Errorf("server answered %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var transcript struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&transcript); err != nil {
+		return "", fmt.Errorf("invalid response: %w", err)
+	}
+	return transcript.Text, nil
+}
+
+// ProviderTranscriber transcribes in process with the ASR providers of a
+// configuration, each sample as one utterance, so latencies are the model's
+// alone
+type ProviderTranscriber struct {
+	registry *usecase.ASRModelRegistry
+}
+
+// NewProviderTranscriber creates a transcriber for the models of cfg, loaded
+// when first used. providers adds provider types as gribe.Options.ASRProviders
+// does.
+func NewProviderTranscriber(cfg *gribe.ASRConfig, providers map[string]gribe.ASRFactory) *ProviderTranscriber {
+	registry := usecase.NewASRModelRegistry(cfg)
+	for providerType, factory := range providers {
+		registry.RegisterProviderType(usecase.ASRProviderType(providerType), factory)
+	}
+	return &ProviderTranscriber{registry: registry}
+}
+
+// Close unloads the models
+func (p *ProviderTranscriber) Close() error {
+	return p.registry.Close()
+}
+
+// Transcribe converts audio to the providers' 16kHz mono and transcribes it
+func (p *ProviderTranscriber) Transcribe(ctx context.Context, audio *Audio, model, language string) (string, error) {
+	if language == "" {
+		languages, err := p.registry.GetModelLanguages(model)
+		if err != nil {
+			return "", err
+		}
+		if len(languages) > 0 {
+			language = languages[0]
+		}
+	}
+	provider, err := p.registry.GetModel(model, language)
+	if err != nil {
+		return "", err
+	}
+	pcm := gateway.NewResampler(audio.SampleRate).Resample(gateway.Downmix(audio.PCM, audio.Channels))
+	config, _ := domain.SupportedOptions(provider, &domain.TranscriptionConfig{Model: model, Language: language})
+	results, err := provider.Transcribe(ctx, pcm, config)
+	if err != nil {
+		return "", err
+	}
+	// Revisable hypotheses are each the full transcript so far
+	var transcript strings.Builder
+	var hypothesis string
+	for chunk := range results {
+		if chunk.Err != nil {
+			return "", chunk.Err
+		}
+		if chunk.Hypothesis != "" {
+			hypothesis = chunk.Hypothesis
+			continue
+		}
+		transcript.WriteString(chunk.Text)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if hypothesis != "" {
+		return hypothesis, nil
+	}
+	return transcript.String(), nil
+}
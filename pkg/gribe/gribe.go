@@ -0,0 +1,81 @@
+// Package gribe embeds the Gribe realtime speech-to-text server in another Go
+// program. A Server serves the same endpoints as the gribe binary, the
+// /v1/realtime WebSocket and the REST APIs, as an http.Handler the program
+// mounts on its own mux, with its own ASR providers and VAD if it wants:
+//
+//	cfg := gribe.LoadConfig("config.yaml")
+//	srv, err := gribe.New(cfg, gribe.Options{
+//		ASRProviders: map[string]gribe.ASRFactory{"my-asr": newMyProvider},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer srv.Close()
+//	srv.Mount(mux)
+//
+// The types below are aliases of Gribe's own, in pkg/config and pkg/domain,
+// so providers written against them plug in unchanged. Those packages hold
+// the types they are built from as well.
+package gribe
+
+import (
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Config is the server configuration, as read from config.yaml
+type Config = config.Config
+
+// ASRConfig is the configuration of the ASR models
+type ASRConfig = config.ASRConfig
+
+// ModelConfig is the configuration of one ASR model
+type ModelConfig = config.ModelConfig
+
+// ASRProvider transcribes audio for a model
+type ASRProvider = domain.ASRProvider
+
+// TranscriptionConfig is the transcription settings passed to an ASRProvider
+type TranscriptionConfig = domain.TranscriptionConfig
+
+// TranscriptionChunk is a partial or final result of an ASRProvider
+type TranscriptionChunk = domain.TranscriptionChunk
+
+// ProviderHealth is what an ASRProvider's HealthCheck reports
+type ProviderHealth = domain.ProviderHealth
+
+// ASRFactory creates the provider of a model whose provider type it is
+// registered for in Options.ASRProviders
+type ASRFactory = usecase.ProviderCreator
+
+// VADProvider detects speech in a session's audio for server VAD
+type VADProvider = domain.VADProvider
+
+// VADConfig is the turn detection settings passed to a VADProvider
+type VADConfig = domain.VADConfig
+
+// VADEvent is a speech boundary found by a VADProvider
+type VADEvent = domain.VADEvent
+
+// VADFactory creates the VADProvider of a session. Its VADEventSpeechStopped
+// events must carry the speech segment's audio, which is what is transcribed.
+type VADFactory = usecase.VADFactory
+
+// Middleware wraps the server's API routes, see Options.Middleware
+type Middleware = middleware.Middleware
+
+// Events a VADProvider sends
+const (
+	VADEventSpeechStarted = domain.VADEventSpeechStarted
+	VADEventSpeechStopped = domain.VADEventSpeechStopped
+	VADEventTimeout       = domain.VADEventTimeout
+)
+
+// LoadConfig reads the configuration from a .yaml, .json or .toml file and
+// the GRIBE_* environment variables, as the gribe binary does. A missing
+// file leaves the defaults and the environment.
+func LoadConfig(path string) *Config {
+	return config.LoadWithYAML(path)
+}
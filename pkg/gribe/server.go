@@ -0,0 +1,303 @@
+package gribe
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+
+	"github.com/aira-id/gribe/internal/delivery/admin"
+	"github.com/aira-id/gribe/internal/delivery/audiosocket"
+	"github.com/aira-id/gribe/internal/delivery/conversations"
+	"github.com/aira-id/gribe/internal/delivery/erasure"
+	"github.com/aira-id/gribe/internal/delivery/mqtt"
+	"github.com/aira-id/gribe/internal/delivery/sessions"
+	"github.com/aira-id/gribe/internal/delivery/sip"
+	"github.com/aira-id/gribe/internal/delivery/transcription"
+	"github.com/aira-id/gribe/internal/delivery/watcher"
+	"github.com/aira-id/gribe/internal/delivery/webrtc"
+	"github.com/aira-id/gribe/internal/delivery/websocket"
+	"github.com/aira-id/gribe/internal/metrics"
+	"github.com/aira-id/gribe/internal/middleware"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// Options customize an embedded server. The zero value serves the models of
+// the configuration with the built-in providers and VAD.
+type Options struct {
+	// ASRProviders creates the providers of models whose provider (in
+	// asr.models) is the key, in addition to "sherpa-onnx" and "whisper-cpp",
+	// which they may also replace
+	ASRProviders map[string]ASRFactory
+
+	// VAD creates the voice activity detector of each session using server
+	// VAD, the built-in energy VAD when nil
+	VAD VADFactory
+
+	// SeparateAdmin leaves /admin/ and /metrics to AdminHandler, for a
+	// listener of its own, instead of serving them on Handler as well
+	SeparateAdmin bool
+
+	// Middleware wraps every API route, inside the server's own request
+	// metrics and access log, e.g. for tracing or the program's own auth
+	Middleware []Middleware
+}
+
+// Server is an embedded Gribe server. Its background services, such as the
+// SIP gateway or MQTT bridge when configured, run from New until Close.
+type Server struct {
+	cfg     *Config
+	handler http.Handler // API routes under the base path
+	admin   *middleware.Router
+	closers []func() // Run by Close in reverse order
+}
+
+// New creates a server serving cfg and starts the background services it
+// enables. cfg is not validated; call cfg.Validate first to refuse broken
+// configurations, as the gribe binary does.
+func New(cfg *Config, opts Options) (*Server, error) {
+	s := &Server{cfg: cfg}
+	if err := s.build(opts); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// build wires the server's components, registering each one that needs
+// stopping with onClose as soon as it exists
+func (s *Server) build(opts Options) error {
+	cfg := s.cfg
+	uc := usecase.NewSessionUsecaseWithConfig(cfg)
+	for providerType, factory := range opts.ASRProviders {
+		uc.ASRRegistry().RegisterProviderType(usecase.ASRProviderType(providerType), factory)
+	}
+	if opts.VAD != nil {
+		uc.SetVADFactory(opts.VAD)
+	}
+	s.onClose(func() { uc.ASRRegistry().Close() })
+
+	// Optional speaker identification of transcribed turns
+	if cfg.ASR.Speaker.Enabled() {
+		speakers, err := usecase.NewSpeakerIdentifierWithConfig(&cfg.ASR)
+		if err != nil {
+			return fmt.Errorf("speaker identification: %w", err)
+		}
+		s.onClose(func() { speakers.Close() })
+		uc.SetSpeakerIdentifier(speakers)
+	}
+
+	// Optional store of ended sessions' conversations
+	var conversationStore domain.ConversationStore
+	if cfg.Conversations.Enabled() {
+		store, err := usecase.NewConversationStoreWithConfig(&cfg.Conversations)
+		if err != nil {
+			return fmt.Errorf("conversation store: %w", err)
+		}
+		uc.SetConversationStore(store)
+		conversationStore = store
+	}
+
+	// Audited deletion of stored data, on request and past its retention
+	// period
+	eraser, err := usecase.NewEraserWithConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("eraser: %w", err)
+	}
+	s.onClose(func() { eraser.Close() })
+	uc.SetEraser(eraser)
+	if cfg.RetentionEnabled() {
+		retention := usecase.NewRetention(cfg, conversationStore, eraser)
+		retention.Start()
+		s.onClose(retention.Close)
+		uc.SetRetention(retention)
+	}
+
+	// Optional export of ended sessions' usage for billing
+	usageSink, err := usecase.NewUsageSinkWithConfig(&cfg.Usage)
+	if err != nil {
+		return fmt.Errorf("usage sink: %w", err)
+	}
+	if usageSink != nil {
+		s.onClose(func() { usageSink.Close() })
+		uc.SetUsageSink(usageSink)
+	}
+
+	// Optional shadow transcription of a sample of segments by another model
+	shadow, err := usecase.NewShadowTranscriberWithConfig(&cfg.ASR, uc.ASRRegistry())
+	if err != nil {
+		return fmt.Errorf("shadow transcription: %w", err)
+	}
+	if shadow != nil {
+		s.onClose(func() { shadow.Close() })
+		uc.SetShadowTranscriber(shadow)
+	}
+
+	// Optional MQTT bridge publishing transcripts of every session
+	if cfg.MQTT.Enabled() {
+		publisher := mqtt.NewPublisher(&cfg.MQTT)
+		if err := publisher.Start(); err != nil {
+			return fmt.Errorf("MQTT: %w", err)
+		}
+		s.onClose(publisher.Close)
+		uc.AddEventObserver(publisher.Observe)
+	}
+
+	// Optional self-test transcribing a known sample with every model before
+	// traffic arrives; failures degrade /health?verbose=1, or with strict
+	// refuse to start
+	if cfg.ASR.SelfTest.Enabled {
+		if err := transcription.SelfTest(uc, &cfg.ASR); err != nil && cfg.ASR.SelfTest.Strict {
+			return fmt.Errorf("self-test: %w", err)
+		}
+	}
+
+	wsHandler := websocket.NewHandler(uc, cfg)
+	s.onClose(wsHandler.Close)
+
+	// Every route is counted in gribe_http_requests_total and, with
+	// server.access_log, logged. REST APIs answer browsers of the allowed
+	// origins (CORS), share the upgrade endpoint's per-IP request rate,
+	// reported in X-RateLimit-* headers, and refuse requests without valid
+	// credentials; the handlers check scopes.
+	observe := []Middleware{middleware.Metrics}
+	if cfg.Server.AccessLog {
+		observe = append(observe, middleware.AccessLog)
+	}
+	mux := middleware.NewRouter(append(observe, opts.Middleware...)...)
+	mux.Handle("/v1/realtime", wsHandler)
+	restMiddleware := []Middleware{
+		func(next http.Handler) http.Handler { return middleware.CORS(cfg, next) },
+		wsHandler.RateLimiter.Middleware,
+		wsHandler.Auth.Middleware,
+	}
+	rest := mux.With(restMiddleware...)
+
+	// Optional WebRTC transport: SDP offers to /v1/realtime/calls
+	if cfg.WebRTC.Enabled {
+		webrtcHandler, err := webrtc.NewHandler(uc, cfg, wsHandler.Auth)
+		if err != nil {
+			return fmt.Errorf("WebRTC: %w", err)
+		}
+		s.onClose(webrtcHandler.Close)
+		rest.Handle("/v1/realtime/calls", webrtcHandler)
+	}
+
+	// Live sessions: read-only event streams (Server-Sent Events) and
+	// transcripts, and erasure of sessions' stored data
+	rest.Handle("/v1/sessions/", sessions.NewHandler(uc, wsHandler.Auth))
+	rest.Handle("/v1/data", erasure.NewHandler(uc, wsHandler.Auth))
+
+	// Conversations of ended sessions, when the conversation store is enabled
+	rest.Handle("/v1/conversations/", conversations.NewHandler(uc, wsHandler.Auth))
+
+	// HTTP transcription endpoints and asynchronous transcription jobs
+	transcriptionHandler := transcription.NewHandler(uc, cfg, wsHandler.Auth)
+	s.onClose(transcriptionHandler.Close)
+	rest.Handle("/v1/audio/", transcriptionHandler)
+	rest.Handle("/v1/transcription-jobs", transcriptionHandler)
+	rest.Handle("/v1/transcription-jobs/", transcriptionHandler)
+
+	health := healthHandler(uc)
+	mux.HandleFunc("/health", health)
+
+	// Operational endpoints: pprof only on the admin handler, admin
+	// endpoints and metrics on the API as well unless the admin handler gets
+	// a listener of its own. All but /health require the admin:read (reads)
+	// or admin:write scope.
+	adminHandler := admin.NewHandler(uc, cfg, wsHandler.Auth, wsHandler.RateLimiter.Abuse)
+	s.admin = middleware.NewRouter(observe...)
+	s.admin.HandleFunc("/health", health)
+	ops := s.admin.With(adminHandler.Protect)
+	ops.HandleFunc("/debug/pprof/", pprof.Index)
+	ops.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	ops.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	ops.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	ops.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	s.admin.Handle("/admin/", adminHandler, restMiddleware...)
+	ops.Handle("/metrics", metrics.Handler())
+	if !opts.SeparateAdmin {
+		rest.Handle("/admin/", adminHandler)
+		rest.Handle("/metrics", metrics.Handler(), adminHandler.Protect)
+	}
+
+	// Optional SIP/RTP gateway transcribing PBX calls
+	if cfg.SIP.Enabled() {
+		gateway := sip.NewGateway(uc, &cfg.SIP)
+		if err := gateway.Start(); err != nil {
+			return fmt.Errorf("SIP gateway: %w", err)
+		}
+		s.onClose(gateway.Close)
+	}
+
+	// Optional Asterisk AudioSocket listener
+	if cfg.AudioSocket.Enabled() {
+		server := audiosocket.NewServer(uc, &cfg.AudioSocket)
+		if err := server.Start(); err != nil {
+			return fmt.Errorf("AudioSocket: %w", err)
+		}
+		s.onClose(server.Close)
+	}
+
+	// Optional directory watcher transcribing dropped audio files
+	if cfg.Watch.Enabled() {
+		w := watcher.NewWatcher(uc, &cfg.Watch)
+		if err := w.Start(); err != nil {
+			return fmt.Errorf("directory watcher: %w", err)
+		}
+		s.onClose(w.Close)
+	}
+
+	s.handler = middleware.BasePath(cfg.Server.BasePath, mux)
+	return nil
+}
+
+// ServeHTTP serves the API under the configured server.base_path
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Mount serves the API on mux under the configured server.base_path, e.g.
+// at /stt/v1/realtime for "/stt", next to the program's own routes
+func (s *Server) Mount(mux *http.ServeMux) {
+	mux.Handle(s.cfg.Server.BasePath+"/", s)
+}
+
+// AdminHandler serves the operational endpoints: /health, /admin/,
+// /metrics and pprof under /debug/pprof/, all but /health to admin
+// credentials only. It is meant for a listener that only operators reach.
+func (s *Server) AdminHandler() http.Handler {
+	return s.admin
+}
+
+// Close stops the background services and releases the loaded models. It
+// does not wait for open connections; shut down the http.Server serving the
+// API first.
+func (s *Server) Close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+	s.closers = nil
+}
+
+// onClose adds a step to Close
+func (s *Server) onClose(close func()) {
+	s.closers = append(s.closers, close)
+}
+
+// healthHandler answers "OK" while the server is up and, with ?verbose=1,
+// the health of each model as JSON, telling a server degraded to failing or
+// mock models from a healthy one. Both answer 200 either way.
+func healthHandler(uc *usecase.SessionUsecase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if verbose, _ := strconv.ParseBool(r.URL.Query().Get("verbose")); !verbose {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("OK"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(uc.Health())
+	}
+}
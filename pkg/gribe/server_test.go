@@ -0,0 +1,156 @@
+package gribe
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/internal/pkg/mock"
+	"github.com/gorilla/websocket"
+)
+
+// countingVAD detects no speech, counting the audio it is given
+type countingVAD struct {
+	chunks *atomic.Int64
+	events chan VADEvent
+}
+
+func (v *countingVAD) ProcessAudio(ctx context.Context, audio []byte) error {
+	v.chunks.Add(1)
+	return nil
+}
+
+func (v *countingVAD) GetEvents() <-chan VADEvent        { return v.events }
+func (v *countingVAD) Configure(config *VADConfig) error { return nil }
+func (v *countingVAD) Reset()                            {}
+func (v *countingVAD) Close() error                      { return nil }
+
+func TestEmbeddedServer(t *testing.T) {
+	cfg := LoadConfig(t.TempDir() + "/missing.yaml")
+	cfg.Server.BasePath = "/stt"
+	cfg.ASR.DefaultModel = "embedded"
+	cfg.Admin.APIKeys = []string{"admin-key"}
+	cfg.ASR.Models = map[string]ModelConfig{
+		"embedded": {Provider: "in-process", Languages: []string{"en"}},
+	}
+
+	var vadChunks atomic.Int64
+	srv, err := New(cfg, Options{
+		ASRProviders: map[string]ASRFactory{
+			"in-process": func(*ASRConfig, string, *ModelConfig) (ASRProvider, error) { return mock.New(), nil },
+		},
+		VAD: func(*VADConfig) VADProvider {
+			return &countingVAD{chunks: &vadChunks, events: make(chan VADEvent)}
+		},
+		SeparateAdmin: true,
+		Middleware: []Middleware{func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Embedder", "yes")
+				next.ServeHTTP(w, r)
+			})
+		}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer srv.Close()
+
+	// Mounted next to the program's own routes
+	mux := http.NewServeMux()
+	mux.HandleFunc("/own", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("own")) })
+	srv.Mount(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for path, status := range map[string]int{"/own": 200, "/stt/health": 200, "/health": 404, "/stt/metrics": 404} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Expected %s to answer %d, got %d", path, status, resp.StatusCode)
+		}
+		if embedded := strings.HasPrefix(path, "/stt/") && status == 200; (resp.Header.Get("X-Embedder") != "") != embedded {
+			t.Errorf("Expected the embedder's middleware on %s: %v", path, embedded)
+		}
+	}
+	resp, err := http.Get(server.URL + "/stt/health?verbose=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var health struct {
+		Status string                            `json:"status"`
+		Models map[string]map[string]interface{} `json:"models"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	if err != nil || health.Status != "ok" || health.Models["embedded"]["status"] != "not_loaded" {
+		t.Errorf("Expected a healthy server with the model not loaded yet, got %+v (%v)", health, err)
+	}
+	for _, path := range []string{"/metrics", "/debug/pprof/"} {
+		for key, status := range map[string]int{"": 403, "admin-key": 200} {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if key != "" {
+				req.Header.Set("Authorization", "Bearer "+key)
+			}
+			srv.AdminHandler().ServeHTTP(recorder, req)
+			if recorder.Code != status {
+				t.Errorf("Expected %s on the admin handler to answer %d with key %q, got %d", path, status, key, recorder.Code)
+			}
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/stt/v1/realtime?intent=transcription", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	send := func(message string) {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(eventType string) map[string]interface{} {
+		t.Helper()
+		for {
+			var event map[string]interface{}
+			if err := conn.ReadJSON(&event); err != nil {
+				t.Fatalf("Expected %s: %v", eventType, err)
+			}
+			if event["type"] == "error" {
+				t.Fatalf("Expected %s, got %v", eventType, event)
+			}
+			if event["type"] == eventType {
+				return event
+			}
+		}
+	}
+
+	expect("transcription_session.created")
+	send(`{"type":"transcription_session.update","session":{"input_audio_transcription":{"model":"embedded","language":"en"},` +
+		`"turn_detection":{"type":"server_vad"}}}`)
+	expect("transcription_session.updated")
+	send(`{"type":"input_audio_buffer.append","audio":"` + base64.StdEncoding.EncodeToString(make([]byte, 32000)) + `"}`)
+	send(`{"type":"input_audio_buffer.commit"}`)
+	completed := expect("conversation.item.input_audio_transcription.completed")
+	if transcript, _ := completed["transcript"].(string); transcript == "" {
+		t.Errorf("Expected a transcript from the registered provider, got %v", completed)
+	}
+	// The VAD runs on a goroutine of its own
+	for i := 0; i < 100 && vadChunks.Load() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if vadChunks.Load() != 1 {
+		t.Errorf("Expected the custom VAD to get the appended audio, got %d chunks", vadChunks.Load())
+	}
+}
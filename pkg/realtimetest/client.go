@@ -0,0 +1,220 @@
+package realtimetest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultTimeout is how long Expect waits for an event unless overridden
+const DefaultTimeout = 5 * time.Second
+
+// ErrClosed is returned when the connection closes while waiting for events
+var ErrClosed = errors.New("realtimetest: connection closed")
+
+// Event is a server event received by the Client
+type Event struct {
+	Type     domain.EventType
+	EventID  string
+	Sequence uint64 // Position among the session's server events
+	Raw      json.RawMessage
+}
+
+// Decode unmarshals the raw event into v (e.g. *domain.SessionCreatedEvent)
+func (e *Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Raw, v)
+}
+
+// Client is a scripted fake realtime client
+type Client struct {
+	conn    *websocket.Conn
+	events  chan *Event
+	done    chan struct{}
+	readErr error
+
+	// Timeout bounds each Next/Expect call
+	Timeout time.Duration
+}
+
+// Dial connects to the server's realtime endpoint.
+// query is merged into the URL (e.g. intent=transcription), header is sent with the upgrade.
+func (s *Server) Dial(query url.Values, header http.Header) (*Client, error) {
+	u := s.URL()
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	conn, resp, err := websocket.DefaultDialer.Dial(u, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("dial %s: %w (status %d)", u, err, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("dial %s: %w", u, err)
+	}
+
+	c := &Client{
+		conn:    conn,
+		events:  make(chan *Event, 256),
+		done:    make(chan struct{}),
+		Timeout: DefaultTimeout,
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// DialTranscription connects with intent=transcription
+func (s *Server) DialTranscription() (*Client, error) {
+	return s.Dial(url.Values{"intent": {"transcription"}}, nil)
+}
+
+// readLoop receives server events until the connection fails
+func (c *Client) readLoop() {
+	defer close(c.done)
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			c.readErr = err
+			return
+		}
+
+		var base struct {
+			domain.BaseEvent
+			Sequence uint64 `json:"sequence"`
+		}
+		if err := json.Unmarshal(message, &base); err != nil {
+			continue
+		}
+		c.events <- &Event{Type: base.Type, EventID: base.EventID, Sequence: base.Sequence, Raw: message}
+	}
+}
+
+// Send writes a client event as JSON
+func (c *Client) Send(event interface{}) error {
+	return c.conn.WriteJSON(event)
+}
+
+// SendRaw writes a raw text frame, useful for malformed-input tests
+func (c *Client) SendRaw(message []byte) error {
+	return c.conn.WriteMessage(websocket.TextMessage, message)
+}
+
+// AppendAudio sends an input_audio_buffer.append event with the given PCM bytes
+func (c *Client) AppendAudio(pcm []byte) error {
+	return c.Send(&domain.InputAudioBufferAppendEvent{
+		BaseEvent: domain.BaseEvent{Type: domain.EventInputAudioBufferAppend},
+		Audio:     base64.StdEncoding.EncodeToString(pcm),
+	})
+}
+
+// Commit sends an input_audio_buffer.commit event
+func (c *Client) Commit() error {
+	return c.Send(&domain.InputAudioBufferCommitEvent{
+		BaseEvent: domain.BaseEvent{Type: domain.EventInputAudioBufferCommit},
+	})
+}
+
+// ConfigureTranscription sends session.update selecting a model and language
+// and waits for session.updated. Only the transcription fields are sent, as a
+// real client would; marshaling domain.Session would send every unset
+// field as an explicit null and clear it.
+func (c *Client) ConfigureTranscription(model, language string) (*Event, error) {
+	err := c.Send(map[string]interface{}{
+		"type": domain.EventSessionUpdate,
+		"session": map[string]interface{}{
+			"audio": map[string]interface{}{
+				"input": map[string]interface{}{
+					"transcription": &domain.TranscriptionConfig{
+						Model:    model,
+						Language: language,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.Expect(domain.EventSessionUpdated)
+}
+
+// Next returns the next server event or an error on timeout/close
+func (c *Client) Next() (*Event, error) {
+	return c.next(c.Timeout)
+}
+
+func (c *Client) next(timeout time.Duration) (*Event, error) {
+	select {
+	case event := <-c.events:
+		return event, nil
+	default:
+	}
+
+	select {
+	case event := <-c.events:
+		return event, nil
+	case <-c.done:
+		// Drain anything queued before the close
+		select {
+		case event := <-c.events:
+			return event, nil
+		default:
+		}
+		if c.readErr != nil {
+			return nil, fmt.Errorf("%w: %v", ErrClosed, c.readErr)
+		}
+		return nil, ErrClosed
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("realtimetest: no event within %s", timeout)
+	}
+}
+
+// Expect waits for an event of the given type, discarding other events.
+// An error event is returned as an error unless eventType is domain.EventError.
+func (c *Client) Expect(eventType domain.EventType) (*Event, error) {
+	deadline := time.Now().Add(c.Timeout)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("realtimetest: no %s event within %s", eventType, c.Timeout)
+		}
+
+		event, err := c.next(remaining)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for %s: %w", eventType, err)
+		}
+
+		if event.Type == eventType {
+			return event, nil
+		}
+		if event.Type == domain.EventError {
+			return nil, fmt.Errorf("waiting for %s: received error event: %s", eventType, event.Raw)
+		}
+	}
+}
+
+// ExpectSequence waits for each event type in order
+func (c *Client) ExpectSequence(eventTypes ...domain.EventType) ([]*Event, error) {
+	events := make([]*Event, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		event, err := c.Expect(eventType)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	c.conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return c.conn.Close()
+}
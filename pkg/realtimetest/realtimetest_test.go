@@ -0,0 +1,122 @@
+package realtimetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+func TestTranscriptionRoundTrip(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.Provider.SetDelay(0, 0)
+	srv.Provider.SetMockResults([]string{"hello", " world"})
+
+	client, err := srv.DialTranscription()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Expect(domain.EventTranscriptionSessionCreated); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.ConfigureTranscription(MockModel, "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.AppendAudio(make([]byte, 3200)); err != nil {
+		t.Fatalf("AppendAudio failed: %v", err)
+	}
+	if err := client.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	events, err := client.ExpectSequence(
+		domain.EventInputAudioBufferCommitted,
+		domain.EventConversationItemCreated,
+		domain.EventConversationItemInputAudioTranscriptionCompleted,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var committed domain.InputAudioBufferCommittedEvent
+	if err := events[0].Decode(&committed); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	var completed domain.ConversationItemInputAudioTranscriptionCompletedEvent
+	if err := events[2].Decode(&completed); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if completed.Transcript != "hello world" {
+		t.Errorf("Expected transcript 'hello world', got %q", completed.Transcript)
+	}
+	// 3200 bytes of 24 kHz PCM16, rounded
+	if committed.AudioDurationMs != 67 || completed.AudioDurationMs != 67 {
+		t.Errorf("Expected 67 ms of audio, got %d and %d ms", committed.AudioDurationMs, completed.AudioDurationMs)
+	}
+
+	// The item is finalized once the transcript is attached
+	doneEvent, err := client.Expect(domain.EventConversationItemDone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var done domain.ConversationItemDoneEvent
+	if err := doneEvent.Decode(&done); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if done.Item.ID != completed.ItemID || done.Item.Content[0].Transcript != "hello world" {
+		t.Errorf("Expected item %s with its transcript, got %s", completed.ItemID, doneEvent.Raw)
+	}
+}
+
+func TestExpectTimeout(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	client, err := srv.Dial(nil, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Expect(domain.EventSessionCreated); err != nil {
+		t.Fatal(err)
+	}
+
+	client.Timeout = 50 * time.Millisecond
+	if _, err := client.Expect(domain.EventSessionUpdated); err == nil {
+		t.Error("Expected timeout error, got nil")
+	}
+}
+
+func TestErrorEventSurfaced(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	client, err := srv.Dial(nil, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.SendRaw([]byte("not json")); err != nil {
+		t.Fatalf("SendRaw failed: %v", err)
+	}
+
+	event, err := client.Expect(domain.EventError)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errEvent domain.ErrorServerEvent
+	if err := event.Decode(&errEvent); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if errEvent.Error.Code != "invalid_json" {
+		t.Errorf("Expected code 'invalid_json', got %s", errEvent.Error.Code)
+	}
+}
@@ -0,0 +1,121 @@
+// Package realtimetest provides an in-process harness for exercising the
+// realtime protocol end to end, in the spirit of net/http/httptest.
+//
+// A Server runs the real WebSocket handler and session usecase behind an
+// httptest.Server, backed by the mock ASR provider. A Client speaks the
+// protocol over a real WebSocket connection and offers helpers to send
+// client events and wait for server events with timeouts.
+package realtimetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	ws "github.com/aira-id/gribe/internal/delivery/websocket"
+	"github.com/aira-id/gribe/internal/pkg/mock"
+	"github.com/aira-id/gribe/internal/usecase"
+	"github.com/aira-id/gribe/pkg/config"
+	"github.com/aira-id/gribe/pkg/domain"
+)
+
+// MockModel is the model name served by the harness mock provider
+const MockModel = "mock-transcribe"
+
+// Server is an in-process realtime server backed by the mock ASR provider
+type Server struct {
+	*httptest.Server
+
+	Config   *config.Config
+	UseCase  *usecase.SessionUsecase
+	Handler  *ws.Handler
+	Provider *mock.Provider
+}
+
+// NewServer starts a server with default configuration
+func NewServer() *Server {
+	return NewServerWithConfig(DefaultConfig())
+}
+
+// NewServerWithConfig starts a server with the given configuration.
+// The MockModel entry is added to the ASR models if not already present.
+func NewServerWithConfig(cfg *config.Config) *Server {
+	if cfg.ASR.Models == nil {
+		cfg.ASR.Models = make(map[string]config.ModelConfig)
+	}
+	if _, exists := cfg.ASR.Models[MockModel]; !exists {
+		cfg.ASR.Models[MockModel] = config.ModelConfig{
+			Provider:  string(usecase.ProviderMock),
+			Languages: mock.New().GetSupportedLanguages(),
+		}
+	}
+
+	provider := mock.New()
+	uc := usecase.NewSessionUsecaseWithConfig(cfg)
+	uc.ASRRegistry().RegisterProviderType(usecase.ProviderMock,
+		func(*config.ASRConfig, string, *config.ModelConfig) (domain.ASRProvider, error) {
+			return provider, nil
+		})
+
+	handler := ws.NewHandler(uc, cfg)
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/realtime", handler)
+
+	return &Server{
+		Server:   httptest.NewServer(mux),
+		Config:   cfg,
+		UseCase:  uc,
+		Handler:  handler,
+		Provider: provider,
+	}
+}
+
+// DefaultConfig returns a configuration suitable for tests: no auth,
+// generous rate limits and a short transcription timeout.
+func DefaultConfig() *config.Config {
+	return &config.Config{
+		Server: config.ServerConfig{Port: "0"},
+		Audio: config.AudioConfig{
+			MaxBufferSize:        15 * 1024 * 1024,
+			TranscriptionTimeout: 5 * time.Second,
+		},
+		Rate: config.RateLimitConfig{
+			MaxConnectionsPerIP: 100,
+			RequestsPerSecond:   1000,
+			BurstSize:           1000,
+			CleanupInterval:     time.Minute,
+		},
+		ASR: config.ASRConfig{
+			Provider:     "cpu",
+			NumThreads:   1,
+			DefaultModel: MockModel,
+			Models:       make(map[string]config.ModelConfig),
+		},
+		Batch: config.BatchConfig{
+			MaxBytes:     15 * 1024 * 1024,
+			FetchTimeout: 5 * time.Second,
+			Workers:      2,
+			QueueSize:    10,
+			JobRetention: time.Hour,
+
+			CallbackAttempts: 3,
+			CallbackBackoff:  10 * time.Millisecond,
+		},
+	}
+}
+
+// URL returns the WebSocket URL of the realtime endpoint
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.Server.URL, "http") + "/v1/realtime"
+}
+
+// Close shuts down the server and releases handler and registry resources
+func (s *Server) Close() {
+	s.Server.Close()
+	s.Handler.Close()
+	if registry := s.UseCase.ASRRegistry(); registry != nil {
+		registry.Close()
+	}
+}